@@ -34,7 +34,7 @@ export function TokenCertification({ onSubmit }: TokenCertificationProps) {
     if (!formData.caseNumber.trim()) {
       newErrors.caseNumber = 'Case number is required';
     } else if (!/^[A-Z]{3}\d{10}$/.test(formData.caseNumber)) {
-      newErrors.caseNumber = 'Case number must be in format: ABC1234567890';
+      newErrors.caseNumber = 'Case number must be in format: EAC2190000001';
     }
 
     setErrors(newErrors);
@@ -182,7 +182,7 @@ export function TokenCertification({ onSubmit }: TokenCertificationProps) {
               id="caseNumber"
               value={formData.caseNumber}
               onChange={handleInputChange('caseNumber')}
-              placeholder="ABC1234567890"
+              placeholder="EAC2190000001"
               pattern="[A-Z]{3}[0-9]{10}"
               className={`w-full px-4 py-3 border rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-blue-500 transition-colors ${
                 errors.caseNumber ? 'border-red-500' : 'border-gray-300'
@@ -193,7 +193,7 @@ export function TokenCertification({ onSubmit }: TokenCertificationProps) {
               <p className="mt-1 text-sm text-red-600">{errors.caseNumber}</p>
             )}
             <p className="mt-1 text-sm text-gray-500">
-              Format: 3 letters followed by 10 digits (e.g., ABC1234567890)
+              Format: service center code followed by 10 digits (e.g., EAC2190000001)
             </p>
           </div>
 
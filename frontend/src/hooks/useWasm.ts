@@ -1,14 +1,13 @@
 import { useState, useEffect, useCallback, useRef } from 'react';
-import type { WASMResponse, Credentials, TokenCertificationResult } from '../types';
+import type { WASMResponse, Credentials, TokenCertificationResult, GoExports } from '../types';
 import { toast } from 'react-toastify';
 
 declare global {
-  interface Window {
+  interface Window extends GoExports {
     Go: new () => {
       importObject: WebAssembly.Imports;
       run: (instance: WebAssembly.Instance) => void;
     };
-    goProcessCredentials: (credentials: string) => Promise<WASMResponse>;
   }
 }
 
@@ -0,0 +1,38 @@
+import { describe, it, expect, vi, afterEach } from 'vitest';
+import { downloadExport, exportBlob } from '../utils';
+
+const file = {
+  filename: 'uscis-cases.ics',
+  mimeType: 'text/calendar;charset=utf-8',
+  content: 'BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n',
+};
+
+describe('exportBlob', () => {
+  it('builds a Blob with the export content and MIME type', async () => {
+    const blob = exportBlob(file);
+    expect(blob.type).toBe(file.mimeType);
+    expect(await blob.text()).toBe(file.content);
+  });
+});
+
+describe('downloadExport', () => {
+  afterEach(() => {
+    vi.restoreAllMocks();
+  });
+
+  it('downloads the export under its filename', () => {
+    const createObjectURL = vi.fn(() => 'blob:export');
+    const revokeObjectURL = vi.fn();
+    Object.assign(URL, { createObjectURL, revokeObjectURL });
+    let downloaded = '';
+    vi.spyOn(HTMLAnchorElement.prototype, 'click').mockImplementation(function (this: HTMLAnchorElement) {
+      downloaded = this.download;
+    });
+
+    downloadExport(file);
+
+    expect(createObjectURL).toHaveBeenCalledWith(expect.any(Blob));
+    expect(downloaded).toBe(file.filename);
+    expect(revokeObjectURL).toHaveBeenCalledWith('blob:export');
+  });
+});
@@ -14,6 +14,7 @@ export interface ProcessingResult {
 export interface WASMResponse {
   success: boolean;
   result?: ProcessingResult;
+  jobId?: string;
   error?: string;
 }
 
@@ -57,11 +58,83 @@ export interface FormErrors {
 export interface TokenCertificationResult {
   isValid: boolean;
   caseStatus: string;
+  caseStatusCode?: string; // Canonical status code, e.g. "case_approved"
+  caseStage?: string; // Lifecycle stage, e.g. "decision"
+  actionRequired?: boolean;
   lastUpdated: string; // ISO 8601 format
   caseDetails: Record<string, string>;
+  estimate?: ProcessingEstimate;
+  deadlines?: ResponseDeadline[];
+  form?: FormInfo;
+  timeline?: CaseTimeline;
   verificationId: string; // Always generated, present on both success and failure
 }
 
+export interface CaseStatusInfo {
+  code: string;
+  title: string;
+  stage: string;
+  terminal: boolean;
+  actionRequired: boolean;
+}
+
+export interface TimelineEvent {
+  date: string; // ISO 8601 format
+  status: CaseStatusInfo;
+  noticeType?: string;
+  source: 'history' | 'current' | 'generated';
+}
+
+export interface CaseTimeline {
+  receiptNumber: string;
+  events: TimelineEvent[];
+}
+
+export interface FormInfo {
+  id: string;
+  title: string;
+  category: string;
+  nextStatuses: string[];
+  warnings?: string[];
+}
+
+export interface ProcessingEstimate {
+  datasetVersion: string;
+  form: string;
+  category?: string;
+  office: string;
+  receivedAt: string; // ISO 8601 format
+  medianAt: string; // 50th percentile
+  likelyAt: string; // 80th percentile
+  latestAt: string; // 93rd percentile
+  elapsedDays: number;
+  percentElapsed: number;
+  phase: 'early' | 'typical' | 'late' | 'outside_normal';
+}
+
+export interface DeadlineCheckpoint {
+  businessDaysBefore: number;
+  at: string; // ISO 8601 format
+  passed: boolean;
+}
+
+export interface ResponseDeadline {
+  receiptNumber?: string;
+  kind: 'rfe' | 'rie' | 'noid' | 'appeal';
+  label: string;
+  status: string;
+  noticeDate: string; // ISO 8601 format
+  days: number;
+  mailingDays: number;
+  dueAt: string; // Next business day when the period ends on a weekend or holiday
+  rolledOver?: string;
+  checkpoints: DeadlineCheckpoint[] | null;
+  answeredAt?: string;
+  daysRemaining: number;
+  businessDaysRemaining: number;
+  overdue: boolean;
+}
+
 export interface TokenCertificationData {
   readonly token: string;
   readonly caseNumber: string;
@@ -73,3 +146,297 @@ export interface TokenCertificationResponse {
   result?: TokenCertificationResult;
   error?: string;
 }
+
+export interface PriorityDateCurrency {
+  month: string; // ISO 8601 format, first day of the bulletin month
+  chart: 'final_action' | 'dates_for_filing';
+  category: string;
+  country: string;
+  cutoff: string; // "C", "U" or a bulletin date such as "01JAN23"
+  current: boolean;
+  shortByDays: number;
+}
+
+export interface VisaBulletinMovement {
+  chart: 'final_action' | 'dates_for_filing';
+  category: string;
+  country: string;
+  from: string;
+  to: string;
+  months: number;
+  daysAdvanced: number;
+  daysPerMonth: number;
+}
+
+export interface ScanResult {
+  receiptNumber: string;
+  offset: number;
+  record?: Record<string, unknown>;
+  error?: string;
+  fetchedAt: string; // ISO 8601 format
+  cached?: boolean;
+}
+
+export interface ScanReport {
+  origin: string;
+  radius: number;
+  scanned: number;
+  fetched: number;
+  cached: number;
+  failed: number;
+  complete: boolean;
+  byForm: Record<string, number>;
+  byStatus: Record<string, number>;
+  byFormStatus: Record<string, Record<string, number>>;
+  results: ScanResult[];
+}
+
+export interface WatchlistEntry {
+  receiptNumber: string;
+  label?: string;
+  notes?: string;
+  owner?: string;
+  formType?: string;
+  lastStatus?: CaseStatusInfo;
+  timeline?: CaseTimeline;
+  addedAt: string; // ISO 8601 format
+  updatedAt: string;
+  lastCheckedAt?: string;
+}
+
+export type CaseChangeType =
+  | 'status_changed'
+  | 'history_added'
+  | 'stage_advanced'
+  | 'rfe_issued'
+  | 'card_produced'
+  | 'case_transferred';
+
+export interface CaseChangeEvent {
+  id: string;
+  caseNumber: string;
+  type: CaseChangeType;
+  before?: string;
+  after: string;
+  title: string;
+  summary: string;
+  occurredAt: string; // ISO 8601 format
+}
+
+export interface AuditEntry {
+  id: string;
+  time: string; // ISO 8601 format
+  actor: string;
+  action: string; // e.g. "case.status_changed"
+  subject?: string;
+  details?: Record<string, string>;
+}
+
+export interface NotificationRule {
+  types?: CaseChangeType[];
+  receipts?: string[];
+  notifiers: string[];
+}
+
+export interface NotificationSettings {
+  browser: boolean;
+  webhooks?: { name: string; url: string; secret: string }[];
+  rules?: NotificationRule[];
+}
+
+export type WebhookDeliveryStatus = 'pending' | 'delivered' | 'dead';
+
+export interface WebhookAttempt {
+  at: string;
+  statusCode?: number;
+  error?: string;
+  duration: number;
+}
+
+export interface WebhookDelivery {
+  id: string;
+  endpoint: string;
+  eventId: string;
+  eventType: CaseChangeType;
+  receiptNumber: string;
+  payload: CaseChangeEvent & { summary: string };
+  status: WebhookDeliveryStatus;
+  failures: number;
+  attempts?: WebhookAttempt[];
+  lastError?: string;
+  createdAt: string;
+  nextAttemptAt?: string;
+  deliveredAt?: string;
+  deadAt?: string;
+}
+
+export type CalendarAppointmentKind = 'biometrics' | 'interview' | 'oath';
+
+export interface CalendarAppointment {
+  receiptNumber: string;
+  kind: CalendarAppointmentKind;
+  start: string;
+  durationMinutes?: number;
+  location?: string;
+  notes?: string;
+}
+
+// ExportFile is a file produced by a Go export, ready to turn into a
+// download with exportBlob or downloadExport
+export interface ExportFile {
+  filename: string;
+  mimeType: string;
+  content: string;
+}
+
+export interface CalendarExport extends ExportFile {
+  success: boolean;
+  events: number;
+}
+
+export interface StoreKey {
+  id: string;
+  key: string;
+}
+
+export interface PersistentStoreOptions {
+  keys?: StoreKey[];
+}
+
+export interface PersistentStoreInfo {
+  success: boolean;
+  database: string;
+  schemaVersion: number;
+  encrypted: boolean;
+  rewrapped: number;
+}
+
+export type BulkFormat = 'csv' | 'json' | 'ndjson';
+
+export interface ImportRowError {
+  line: number;
+  input?: string;
+  message: string;
+}
+
+export interface ImportReport {
+  rows: number;
+  added: string[];
+  skipped?: ImportRowError[];
+  errors?: ImportRowError[];
+}
+
+export interface CaseExportOptions {
+  format?: BulkFormat;
+  fields?: string[];
+  // comma-separated masks; A-Numbers are masked unless it includes "none"
+  mask?: string;
+  events?: boolean;
+  receiptNumber?: string;
+}
+
+export interface CaseExport extends ExportFile {
+  success: boolean;
+  rows: number;
+}
+
+export interface CaseRecord {
+  receiptNumber: string;
+  formType?: string;
+  status: CaseStatusInfo;
+  description?: string;
+  serviceCenter?: string;
+  submittedAt?: string; // ISO 8601 format
+  updatedAt: string;
+  timeline: CaseTimeline | null;
+}
+
+export interface CaseReport {
+  case: CaseRecord;
+  watched: boolean;
+  form?: FormInfo;
+  estimate?: ProcessingEstimate;
+  deadlines: ResponseDeadline[];
+}
+
+export type JobState = 'queued' | 'running' | 'succeeded' | 'failed' | 'cancelled';
+
+export interface JobProgress {
+  jobId: string;
+  state: JobState;
+  stage?: string;
+  percent: number;
+  message?: string;
+  timestamp: string; // ISO 8601 format
+}
+
+export interface JobSnapshot {
+  id: string;
+  kind: string;
+  state: JobState;
+  progress: JobProgress;
+  error?: string;
+  createdAt: string; // ISO 8601 format
+  startedAt?: string;
+  finishedAt?: string;
+}
+
+export interface CatalogForm {
+  id: string;
+  title: string;
+  category: string;
+  workflow: string;
+  receiptCenters: string[];
+}
+
+export interface HealthStatus {
+  status: string;
+  timestamp: string;
+  version: string;
+  features: string[];
+  checks: Record<string, string>;
+}
+
+// GoResponse is the envelope of every export result: success with the
+// result fields, or an error
+export type GoResponse<T = object> = ({ success: true } & T) | { success: false; error: string };
+
+// GoJSON is a JSON string holding a T. Every Go export returns one, or a
+// Promise that resolves or rejects with one; read it with JSON.parse.
+export type GoJSON<T> = string & { readonly __json?: T };
+
+// GoExports lists the functions the WASM module registers on the global
+// object. JSON arguments are passed as strings.
+export interface GoExports {
+  goProcessCredentials: (credentials: string) => Promise<GoJSON<WASMResponse>>;
+  goCertifyToken: (request: string) => Promise<GoJSON<TokenCertificationResult>>;
+  goGetJob: (jobId: string) => GoJSON<GoResponse<{ job: JobSnapshot }>>;
+  goCancelJob: (jobId: string) => GoJSON<GoResponse<{ jobId: string }>>;
+  goCaseStatus: (request: string) => Promise<GoJSON<GoResponse<CaseReport>>>;
+  goConfigure: (settings: string) => GoJSON<GoResponse<{ certification: boolean }>>;
+  goLoadProcessingTimes: (content: string, format: 'csv' | 'json') => GoJSON<GoResponse<{ version: string; entries: number }>>;
+  goOpenStore: (database?: string, options?: string) => Promise<GoJSON<PersistentStoreInfo>>;
+  goWatchlistAdd: (entry: string) => GoJSON<GoResponse<{ entry: WatchlistEntry }>>;
+  goWatchlistList: (filter?: string) => GoJSON<GoResponse<{ entries: WatchlistEntry[] }>>;
+  goWatchlistRemove: (receiptNumber: string) => GoJSON<GoResponse>;
+  goExportCalendar: (request?: string) => GoJSON<CalendarExport>;
+  goImportReceipts: (content: string, format: BulkFormat, options?: string) => GoJSON<GoResponse<{ report: ImportReport }>>;
+  goExportCases: (options?: string) => GoJSON<CaseExport>;
+  goStartScheduler: (options?: string) => GoJSON<GoResponse<{ environment: Environment; interval: string }>>;
+  goStopScheduler: () => GoJSON<GoResponse>;
+  goConfigureNotifications: (settings: string) => GoJSON<GoResponse<{ notifiers: string[] }>>;
+  goWebhookDeadLetters: () => GoJSON<GoResponse<{ deliveries: WebhookDelivery[] }>>;
+  goWebhookReplay: (deliveryId?: string) => GoJSON<GoResponse<{ replayed: number }>>;
+  goAuditLog: (query?: string) => GoJSON<GoResponse<{ entries: AuditEntry[] }>>;
+  goSetScenario: (scenario: string) => GoJSON<GoResponse<{ scenario: string }>>;
+  goScanNeighbours: (request: string) => GoJSON<GoResponse<{ jobId: string }>>;
+  goLoadVisaBulletin: (content: string, format: 'html' | 'csv') => GoJSON<GoResponse<{ months: string[] }>>;
+  goCheckPriorityDate: (request: string) => GoJSON<GoResponse<{
+    currency: PriorityDateCurrency;
+    movement?: VisaBulletinMovement;
+    projectedCurrent?: string; // YYYY-MM
+  }>>;
+  goListForms: (filter?: string) => GoJSON<GoResponse<{ version: string; forms: CatalogForm[] }>>;
+  goHealthCheck: () => GoJSON<HealthStatus>;
+  goSendRealtimeUpdate: (type: string, data: unknown) => GoJSON<GoResponse<{ message: string }>>;
+}
@@ -0,0 +1,291 @@
+// Command uscis-server serves credential processing, token certification
+// and the case watchlist as a JSON REST API.
+//
+// Usage:
+//
+//	uscis-server [-addr :8080] [-store FILE] [-vault FILE] [-max-body BYTES] [-timeout 30s] [-shutdown-timeout 15s]
+//	             [-poll ENV] [-poll-interval 6h] [-quiet-hours 22:00-06:00]
+//
+// Endpoints:
+//
+//	GET    /v1/health
+//	POST   /v1/credentials/process
+//	POST   /v1/tokens/certify
+//	GET    /v1/cases
+//	POST   /v1/cases
+//	GET    /v1/cases/{receiptNumber}
+//	PATCH  /v1/cases/{receiptNumber}
+//	DELETE /v1/cases/{receiptNumber}
+//	GET    /v1/cases/{receiptNumber}/status
+//
+// Certification tokens are checked with the HS256 key in $JWT_SIGNING_KEY,
+// or the jwt_signing_key secret of the vault. Without a key the
+// certification endpoint is disabled.
+//
+// Watched cases and token revocations are kept in memory unless -store
+// names a store file. With -vault the store is encrypted at rest with the
+// vault's master key; the passphrase is read from $USCIS_SERVER_PASSPHRASE.
+//
+// With -poll the server refreshes the watched cases in that environment in
+// the background, with the same poller as 'uscisctl watch poll', and records
+// every status on the watchlist. No checks start during -quiet-hours (UTC).
+// Every change detected on a watched case is recorded in the store's audit
+// log.
+//
+// On SIGINT or SIGTERM the server stops accepting connections and waits
+// up to -shutdown-timeout for requests in flight to finish.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"MyUSCISgo/internal/server"
+	"MyUSCISgo/pkg/changes"
+	"MyUSCISgo/pkg/logging"
+	"MyUSCISgo/pkg/scheduler"
+	"MyUSCISgo/pkg/service"
+	"MyUSCISgo/pkg/store"
+	"MyUSCISgo/pkg/vault"
+)
+
+// Environment variables
+const (
+	signingKeyEnv = "JWT_SIGNING_KEY"
+	passphraseEnv = "USCIS_SERVER_PASSPHRASE"
+)
+
+// signingKeySecret is the vault secret holding the token signing key
+const signingKeySecret = "jwt_signing_key"
+
+// config is the parsed command line
+type config struct {
+	addr            string
+	storePath       string
+	vaultPath       string
+	maxBody         int64
+	timeout         time.Duration
+	shutdownTimeout time.Duration
+	logLevel        string
+	pollEnv         string
+	poll            scheduler.Config
+}
+
+var logLevels = map[string]logging.LogLevel{
+	"debug": logging.LogLevelDebug,
+	"info":  logging.LogLevelInfo,
+	"warn":  logging.LogLevelWarn,
+	"error": logging.LogLevelError,
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx, os.Args[1:], os.Stderr, os.Getenv)
+	stop()
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "uscis-server: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run parses the command line and serves until ctx is cancelled
+func run(ctx context.Context, args []string, stderr io.Writer, getenv func(string) string) error {
+	cfg, err := parseConfig(args, stderr)
+	if err != nil {
+		return err
+	}
+	logger := logging.NewLogger(logLevels[cfg.logLevel])
+
+	records, signingKey, err := openStore(ctx, cfg, getenv)
+	if err != nil {
+		return err
+	}
+	defer records.Close()
+	if signingKey == "" {
+		signingKey = getenv(signingKeyEnv)
+	}
+	if signingKey == "" {
+		logger.Warn("No token signing key configured, certification is disabled", map[string]interface{}{
+			"env": signingKeyEnv,
+		})
+	}
+
+	svc := service.New(
+		service.WithLogger(logger),
+		service.WithStore(records),
+		service.WithChanges(auditChanges(records, logger)),
+		service.WithSigningKey(signingKey),
+		service.WithTimeout(cfg.timeout),
+	)
+	if cfg.pollEnv != "" {
+		if err := svc.StartPolling(ctx, cfg.pollEnv, cfg.poll); err != nil {
+			return err
+		}
+		defer svc.StopPolling()
+	}
+	api := server.New(svc,
+		server.WithLogger(logger),
+		server.WithMaxBodySize(cfg.maxBody),
+	)
+	ln, err := net.Listen("tcp", cfg.addr)
+	if err != nil {
+		return err
+	}
+	logger.Info("Server listening", map[string]interface{}{
+		"addr": ln.Addr().String(),
+	})
+	return serve(ctx, newHTTPServer(api, cfg), ln, cfg.shutdownTimeout, logger)
+}
+
+// auditChanges returns a bus that records every case change in the
+// store's audit log
+func auditChanges(records *store.Store, logger *logging.Logger) *changes.Bus {
+	bus := changes.NewBus()
+	bus.Subscribe(changes.AuditSink(records.Audit(), func(e changes.Event, err error) {
+		logger.Error("Failed to record case change", err, map[string]interface{}{
+			"caseNumber": e.ReceiptNumber,
+			"type":       string(e.Type),
+		})
+	}))
+	return bus
+}
+
+func parseConfig(args []string, stderr io.Writer) (*config, error) {
+	cfg := &config{}
+	fs := flag.NewFlagSet("uscis-server", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&cfg.addr, "addr", ":8080", "address to listen on")
+	fs.StringVar(&cfg.storePath, "store", "", "store file for watched cases and token revocations (default in memory)")
+	fs.StringVar(&cfg.vaultPath, "vault", "", "vault holding the store encryption key and token signing key")
+	fs.Int64Var(&cfg.maxBody, "max-body", server.DefaultMaxBodySize, "maximum request body size in bytes")
+	fs.DurationVar(&cfg.timeout, "timeout", service.DefaultTimeout, "time allowed to process a request")
+	fs.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", 15*time.Second, "time allowed for requests in flight on shutdown")
+	fs.StringVar(&cfg.logLevel, "log-level", "info", "minimum log level: debug, info, warn or error")
+	fs.StringVar(&cfg.pollEnv, "poll", "", "refresh watched cases in this environment in the background (default off)")
+	cfg.poll = scheduler.DefaultConfig()
+	fs.DurationVar(&cfg.poll.Interval, "poll-interval", cfg.poll.Interval, "time between checks of a case")
+	quietHours := fs.String("quiet-hours", "", "UTC window without checks, such as 22:00-06:00")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if _, ok := logLevels[cfg.logLevel]; !ok {
+		return nil, fmt.Errorf("unknown log level %q", cfg.logLevel)
+	}
+	if cfg.maxBody <= 0 || cfg.timeout <= 0 || cfg.shutdownTimeout <= 0 {
+		return nil, errors.New("-max-body, -timeout and -shutdown-timeout must be positive")
+	}
+	if cfg.vaultPath != "" && cfg.storePath == "" {
+		return nil, errors.New("-vault requires -store")
+	}
+	if cfg.pollEnv != "" {
+		if _, err := service.Environment(cfg.pollEnv); err != nil {
+			return nil, fmt.Errorf("-poll: %w", err)
+		}
+	}
+	if cfg.poll.Interval <= 0 {
+		return nil, errors.New("-poll-interval must be positive")
+	}
+	if *quietHours != "" {
+		quiet, err := scheduler.ParseQuietHours(*quietHours, time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("-quiet-hours: %w", err)
+		}
+		cfg.poll.QuietHours = quiet
+	}
+	return cfg, nil
+}
+
+// openStore opens the configured store and returns the signing key kept in
+// the vault, if any
+func openStore(ctx context.Context, cfg *config, getenv func(string) string) (*store.Store, string, error) {
+	if cfg.storePath == "" {
+		records, err := store.Open(ctx, store.NewMemory())
+		return records, "", err
+	}
+
+	var (
+		v          *vault.Vault
+		signingKey string
+	)
+	if cfg.vaultPath != "" {
+		passphrase := getenv(passphraseEnv)
+		if passphrase == "" {
+			return nil, "", fmt.Errorf("set $%s to unlock the vault", passphraseEnv)
+		}
+		var err error
+		if v, err = vault.Open(cfg.vaultPath, passphrase); err != nil {
+			return nil, "", err
+		}
+		if secret, err := v.Secret(signingKeySecret); err == nil {
+			signingKey = secret
+		}
+	}
+
+	backend, _, err := vault.OpenBackend(ctx, cfg.storePath, v)
+	if err != nil {
+		return nil, "", err
+	}
+	records, err := store.Open(ctx, backend)
+	if err != nil {
+		backend.Close()
+		return nil, "", err
+	}
+	return records, signingKey, nil
+}
+
+// newHTTPServer wraps the API in an http.Server with timeouts that leave
+// room for the request timeout
+func newHTTPServer(api http.Handler, cfg *config) *http.Server {
+	return &http.Server{
+		Handler:           api,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      cfg.timeout + 10*time.Second,
+		IdleTimeout:       2 * time.Minute,
+		MaxHeaderBytes:    32 << 10,
+	}
+}
+
+// serve runs srv on ln until ctx is cancelled, then shuts it down
+// gracefully, waiting up to shutdownTimeout for requests in flight
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, shutdownTimeout time.Duration, logger *logging.Logger) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("Shutting down", map[string]interface{}{
+		"timeout": shutdownTimeout.String(),
+	})
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
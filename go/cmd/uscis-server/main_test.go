@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"MyUSCISgo/pkg/audit"
+	"MyUSCISgo/pkg/changes"
+	"MyUSCISgo/pkg/logging"
+	"MyUSCISgo/pkg/store"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		args    []string
+		wantErr bool
+	}{
+		{nil, false},
+		{[]string{"-addr", "127.0.0.1:0", "-max-body", "1024", "-log-level", "debug"}, false},
+		{[]string{"-log-level", "loud"}, true},
+		{[]string{"-max-body", "0"}, true},
+		{[]string{"-vault", "vault.json"}, true},
+		{[]string{"extra"}, true},
+		{[]string{"-poll", "staging", "-poll-interval", "1h", "-quiet-hours", "22:00-06:00"}, false},
+		{[]string{"-poll", "moon"}, true},
+		{[]string{"-poll-interval", "0"}, true},
+		{[]string{"-quiet-hours", "late"}, true},
+	}
+	for _, tt := range tests {
+		_, err := parseConfig(tt.args, io.Discard)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseConfig(%v) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAuditChanges(t *testing.T) {
+	ctx := context.Background()
+	records, err := store.Open(ctx, store.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer records.Close()
+
+	auditChanges(records, logging.NewLogger(logging.LogLevelFatal)).Publish(changes.Event{
+		ID:            "1",
+		Type:          changes.StatusChanged,
+		ReceiptNumber: "EAC2190050123",
+		After:         "Case Was Approved",
+		DetectedAt:    time.Now(),
+	})
+
+	entries, err := records.Audit().List(ctx, audit.Query{Subject: "EAC2190050123"})
+	if err != nil || len(entries) != 1 || entries[0].Action != "case."+string(changes.StatusChanged) {
+		t.Errorf("audit log = %+v, %v; want the change", entries, err)
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, &http.Server{Handler: handler}, ln, 5*time.Second, logging.NewLogger(logging.LogLevelFatal))
+	}()
+
+	type response struct {
+		body string
+		err  error
+	}
+	got := make(chan response, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/")
+		if err != nil {
+			got <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		got <- response{string(body), err}
+	}()
+
+	<-started
+	cancel()
+	// the listener closes before requests in flight finish
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			break
+		}
+		conn.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("listener still accepting after shutdown began")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(release)
+
+	if r := <-got; r.err != nil || r.body != "done" {
+		t.Errorf("request in flight = %q, %v; want it to finish", r.body, r.err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("serve() = %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"MyUSCISgo/pkg/bulk"
+	"MyUSCISgo/pkg/estimate"
+	"MyUSCISgo/pkg/watchlist"
+)
+
+func runImport(ctx context.Context, e *env, args []string) int {
+	fs, sf := newFlagSet(e, "import", "FILE")
+	formatFlag := fs.String("format", "", "csv, json or ndjson (default from the file extension, or csv)")
+	dryRun := fs.Bool("dry-run", false, "report what would be imported without changing the watchlist")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	maxRows := fs.Int("max-rows", bulk.DefaultMaxRows, "reject files with more rows; 0 means no limit")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	path := fs.Arg(0)
+
+	format, err := pickFormat(*formatFlag, path)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "uscisctl: %v\n", err)
+		return exitUsage
+	}
+	in := e.stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fail(e, err)
+		}
+		defer f.Close()
+		in = f
+	}
+	batch, err := bulk.Parse(in, format, bulk.WithMaxRows(*maxRows))
+	if err != nil {
+		return fail(e, err)
+	}
+
+	return withWatchlist(ctx, e, sf, func(svc *watchlist.Service) int {
+		report := batch.Apply(ctx, svc, *dryRun)
+		if *asJSON {
+			enc := json.NewEncoder(e.stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				return fail(e, err)
+			}
+		} else {
+			printReport(e.stdout, report, *dryRun)
+		}
+		if report.Failed() {
+			return exitPartial
+		}
+		return exitOK
+	})
+}
+
+// printReport writes an import report for people
+func printReport(w io.Writer, r *bulk.Report, dryRun bool) {
+	verb := "added"
+	if dryRun {
+		verb = "would add"
+	}
+	fmt.Fprintf(w, "%d rows: %s %d, skipped %d, rejected %d\n", r.Rows, verb, len(r.Added), len(r.Skipped), len(r.Errors))
+	for _, s := range r.Skipped {
+		fmt.Fprintf(w, "  skipped row %d: %s %s\n", s.Line, s.Input, s.Message)
+	}
+	for _, err := range r.Errors {
+		if err.Input != "" {
+			fmt.Fprintf(w, "  rejected row %d: %q: %s\n", err.Line, err.Input, err.Message)
+		} else {
+			fmt.Fprintf(w, "  rejected row %d: %s\n", err.Line, err.Message)
+		}
+	}
+}
+
+func runExport(ctx context.Context, e *env, args []string) int {
+	fs, sf := newFlagSet(e, "export", "[RECEIPT]")
+	formatFlag := fs.String("format", "", "csv, json or ndjson (default from the -o extension, or csv)")
+	fieldsFlag := fs.String("fields", "", "comma-separated fields to export (default "+joinFields(bulk.DefaultFields)+")")
+	maskFlag := fs.String("mask", "", "personal data to mask: receipts, owners, notes, identifiers or all; identifiers are masked unless the list includes none")
+	events := fs.Bool("events", false, "write one row per timeline event")
+	output := fs.String("o", "-", "output file")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	format, err := pickFormat(*formatFlag, *output)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "uscisctl: %v\n", err)
+		return exitUsage
+	}
+	mask, err := bulk.ParseMask(*maskFlag)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "uscisctl: %v\n", err)
+		return exitUsage
+	}
+	opts := []bulk.ExportOption{bulk.WithMask(mask)}
+	if *fieldsFlag != "" {
+		fields, err := bulk.ParseFields(*fieldsFlag)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "uscisctl: %v\n", err)
+			return exitUsage
+		}
+		opts = append(opts, bulk.WithFields(fields...))
+	}
+	if *events {
+		opts = append(opts, bulk.WithEvents())
+	}
+	exporter, err := bulk.NewExporter(format, opts...)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "uscisctl: %v\n", err)
+		return exitUsage
+	}
+
+	return withWatchlist(ctx, e, sf, func(svc *watchlist.Service) int {
+		var entries []*watchlist.Entry
+		if number := fs.Arg(0); number != "" {
+			entry, err := svc.Get(ctx, number)
+			if err != nil {
+				return fail(e, err)
+			}
+			entries = []*watchlist.Entry{entry}
+		} else if entries, err = svc.List(ctx, watchlist.Filter{}); err != nil {
+			return fail(e, err)
+		}
+
+		out, closeOut := e.stdout, func() error { return nil }
+		if *output != "-" {
+			f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+			if err != nil {
+				return fail(e, err)
+			}
+			out, closeOut = f, f.Close
+		}
+		_, err := exporter.Export(out, bulk.Cases(entries, estimate.NewEstimator(nil, nil)))
+		if closeErr := closeOut(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fail(e, err)
+		}
+		return exitOK
+	})
+}
+
+// pickFormat uses the -format flag, then the file extension, then CSV
+func pickFormat(flagValue, path string) (bulk.Format, error) {
+	if flagValue != "" {
+		return bulk.ParseFormat(flagValue)
+	}
+	if format, err := bulk.FormatForPath(path); err == nil {
+		return format, nil
+	}
+	return bulk.FormatCSV, nil
+}
+
+func joinFields(fields []bulk.Field) string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = string(f)
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"MyUSCISgo/pkg/ical"
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/service"
+	"MyUSCISgo/pkg/watchlist"
+)
+
+func runCalendar(ctx context.Context, e *env, args []string) int {
+	fs, sf := newFlagSet(e, "calendar", "[RECEIPT]")
+	appointments := fs.String("appointments", "", "JSON file with biometrics appointments and interviews to add")
+	notices := fs.Bool("notices", true, "add an event for each notice in the case history")
+	output := fs.String("o", "-", "output file")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	var scheduled []ical.Appointment
+	if *appointments != "" {
+		data, err := os.ReadFile(*appointments)
+		if err != nil {
+			return fail(e, err)
+		}
+		if err := json.Unmarshal(data, &scheduled); err != nil {
+			fmt.Fprintf(e.stderr, "uscisctl: %s: %v\n", *appointments, err)
+			return exitInvalid
+		}
+	}
+
+	return withService(ctx, e, sf, func(svc *service.Service) int {
+		var entries []*watchlist.Entry
+		name := "USCIS cases"
+		if number := fs.Arg(0); number != "" {
+			entry, err := svc.Watchlist().Get(ctx, number)
+			if err != nil {
+				return fail(e, err)
+			}
+			entries = []*watchlist.Entry{entry}
+			name = "USCIS case " + entry.ReceiptNumber
+		} else {
+			var err error
+			if entries, err = svc.Watchlist().List(ctx, watchlist.Filter{}); err != nil {
+				return fail(e, err)
+			}
+		}
+
+		var cases []ical.Case
+		for _, entry := range entries {
+			// cases never looked up have nothing to put on a calendar yet
+			record := entry.Record()
+			if record == nil {
+				continue
+			}
+			c := ical.Case{Record: record, Appointments: scheduled}
+			if caseReceipt, err := receipt.Parse(record.ReceiptNumber); err == nil {
+				c.Estimate = svc.Estimate(caseReceipt, record.FormType, record.Timeline)
+			}
+			cases = append(cases, c)
+		}
+
+		builder := ical.NewBuilder(ical.WithDeadlines(svc.Deadlines()), ical.WithNotices(*notices))
+		data, err := builder.Calendar(name, cases...).Bytes(svc.Clock().Now())
+		if err != nil {
+			return fail(e, err)
+		}
+		if *output == "-" {
+			_, err = e.stdout.Write(data)
+		} else {
+			err = os.WriteFile(*output, data, 0o600)
+		}
+		if err != nil {
+			return fail(e, err)
+		}
+		if len(cases) == 0 {
+			fmt.Fprintln(e.stderr, "uscisctl: no case has been looked up yet; run 'uscisctl status RECEIPT' first")
+		}
+		return exitOK
+	})
+}
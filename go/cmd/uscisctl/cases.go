@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"MyUSCISgo/pkg/scan"
+	"MyUSCISgo/pkg/scheduler"
+	"MyUSCISgo/pkg/service"
+	"MyUSCISgo/pkg/types"
+	"MyUSCISgo/pkg/watchlist"
+)
+
+// environmentFlag documents the -env flag of commands that look up cases
+const environmentFlag = "environment: development, staging or production (default development)"
+
+func runStatus(ctx context.Context, e *env, args []string) int {
+	fs, sf := newFlagSet(e, "status", "RECEIPT")
+	format := formatFlag(fs)
+	environment := fs.String("env", "", environmentFlag)
+	if code, ok := parseOutputFlags(e, fs, args, format, 1); !ok {
+		return code
+	}
+
+	return withService(ctx, e, sf, func(svc *service.Service) int {
+		report, err := svc.CaseStatus(ctx, fs.Arg(0), *environment)
+		if err != nil {
+			return fail(e, err)
+		}
+		if err := printResult(e.stdout, *format, report, func(w io.Writer) { printCase(w, report) }); err != nil {
+			return fail(e, err)
+		}
+		return exitOK
+	})
+}
+
+func runCertify(ctx context.Context, e *env, args []string) int {
+	fs, sf := newFlagSet(e, "certify", "")
+	format := formatFlag(fs)
+	token := fs.String("token", "", "certification token, or - to read it from standard input")
+	caseNumber := fs.String("case", "", "receipt number the token was issued for")
+	environment := fs.String("env", "", environmentFlag)
+	if code, ok := parseOutputFlags(e, fs, args, format, 0); !ok {
+		return code
+	}
+	if *token == "" || *caseNumber == "" {
+		fs.Usage()
+		return exitUsage
+	}
+	value, err := readToken(e, *token)
+	if err != nil {
+		return fail(e, err)
+	}
+
+	return withService(ctx, e, sf, func(svc *service.Service) int {
+		cert, err := svc.Certify(ctx, service.CertifyRequest{
+			Token:       value,
+			CaseNumber:  *caseNumber,
+			Environment: *environment,
+		})
+		if err != nil {
+			return fail(e, err)
+		}
+		err = printResult(e.stdout, *format, cert, func(w io.Writer) {
+			printFields(w, "Verification ID", cert.VerificationID)
+			printCase(w, &cert.CaseReport)
+		})
+		if err != nil {
+			return fail(e, err)
+		}
+		return exitOK
+	})
+}
+
+// printCase writes a case report as table rows
+func printCase(w io.Writer, report *service.CaseReport) {
+	record := report.Case
+	form := record.FormType
+	if report.Form != nil {
+		form = report.Form.ID + " " + report.Form.Title
+	}
+	printFields(w,
+		"Receipt", record.ReceiptNumber,
+		"Form", form,
+		"Status", record.Status.Title,
+		"Code", string(record.Status.Code),
+		"Stage", string(record.Status.Stage),
+		"Action required", yesNo(record.Status.ActionRequired),
+		"Service center", record.ServiceCenter,
+		"Updated", formatDate(record.UpdatedAt),
+		"Watched", yesNo(report.Watched),
+	)
+	if est := report.Estimate; est != nil {
+		printFields(w, "Decision expected", fmt.Sprintf("half by %s, 80%% by %s, 93%% by %s",
+			formatDate(est.MedianAt), formatDate(est.LikelyAt), formatDate(est.LatestAt)))
+	}
+	for _, d := range report.Deadlines {
+		printFields(w, "Deadline", d.Label+" due "+formatDate(d.DueAt))
+	}
+	if report.Form != nil {
+		for _, warning := range report.Form.Warnings {
+			printFields(w, "Warning", warning)
+		}
+	}
+}
+
+func runScan(ctx context.Context, e *env, args []string) int {
+	fs, sf := newFlagSet(e, "scan", "RECEIPT")
+	format := formatFlag(fs)
+	radius := fs.Int("radius", 5, fmt.Sprintf("receipts to check on each side, at most %d", scan.MaxRadius))
+	environment := fs.String("env", "", environmentFlag)
+	if code, ok := parseOutputFlags(e, fs, args, format, 1); !ok {
+		return code
+	}
+
+	return withService(ctx, e, sf, func(svc *service.Service) int {
+		job, err := svc.SubmitScan(ctx, fs.Arg(0), *environment, *radius)
+		if err != nil {
+			return fail(e, err)
+		}
+		result, err := job.Wait(ctx)
+		if err != nil {
+			return fail(e, err)
+		}
+		report := result.(*scan.Report)
+
+		// NDJSON streams the neighbours, the other formats the whole report
+		var v any = report
+		if *format == formatNDJSON {
+			v = report.Results
+		}
+		if err := printResult(e.stdout, *format, v, func(w io.Writer) { printScan(w, report) }); err != nil {
+			return fail(e, err)
+		}
+		if report.Failed > 0 {
+			return exitPartial
+		}
+		return exitOK
+	})
+}
+
+// printScan writes a scan report as a summary and the case count per status
+func printScan(w io.Writer, report *scan.Report) {
+	printFields(w,
+		"Origin", report.Origin,
+		"Radius", fmt.Sprint(report.Radius),
+		"Scanned", fmt.Sprint(report.Scanned),
+		"Failed", fmt.Sprint(report.Failed),
+	)
+	codes := make([]types.CaseStatusCode, 0, len(report.ByStatus))
+	for code := range report.ByStatus {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "STATUS\tCASES")
+	for _, code := range codes {
+		fmt.Fprintf(w, "%s\t%d\n", code, report.ByStatus[code])
+	}
+}
+
+func runWatch(ctx context.Context, e *env, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(e.stderr, "Usage: uscisctl watch add|list|rm|poll [flags]")
+		return exitUsage
+	}
+	switch args[0] {
+	case "add":
+		return watchAdd(ctx, e, args[1:])
+	case "list":
+		return watchList(ctx, e, args[1:])
+	case "rm":
+		return watchRemove(ctx, e, args[1:])
+	case "poll":
+		return watchPoll(ctx, e, args[1:])
+	case "-h", "-help", "help":
+		fmt.Fprintln(e.stderr, "Usage: uscisctl watch add|list|rm|poll [flags]")
+		return exitOK
+	default:
+		fmt.Fprintf(e.stderr, "uscisctl: unknown watch command %q\n", args[0])
+		return exitUsage
+	}
+}
+
+func watchAdd(ctx context.Context, e *env, args []string) int {
+	fs, sf := newFlagSet(e, "watch add", "RECEIPT")
+	format := formatFlag(fs)
+	req := watchlist.AddRequest{}
+	fs.StringVar(&req.Label, "label", "", "label shown for the case")
+	fs.StringVar(&req.Owner, "owner", "", "who the case belongs to")
+	fs.StringVar(&req.Notes, "notes", "", "notes kept with the case")
+	if code, ok := parseOutputFlags(e, fs, args, format, 1); !ok {
+		return code
+	}
+	req.ReceiptNumber = fs.Arg(0)
+
+	return withWatchlist(ctx, e, sf, func(svc *watchlist.Service) int {
+		entry, err := svc.Add(ctx, req)
+		if err != nil {
+			return fail(e, err)
+		}
+		if err := printResult(e.stdout, *format, entry, func(w io.Writer) { printEntries(w, entry) }); err != nil {
+			return fail(e, err)
+		}
+		return exitOK
+	})
+}
+
+func watchList(ctx context.Context, e *env, args []string) int {
+	fs, sf := newFlagSet(e, "watch list", "")
+	format := formatFlag(fs)
+	var filter watchlist.Filter
+	fs.StringVar(&filter.Owner, "owner", "", "only cases of this owner")
+	fs.StringVar(&filter.Label, "label", "", "only cases with this label")
+	fs.StringVar(&filter.Form, "form", "", "only cases on this form, such as I-485")
+	stage := fs.String("stage", "", "only cases at this stage, such as decided")
+	status := fs.String("status", "", "only cases with this status code")
+	fs.BoolVar(&filter.ActionRequired, "action-required", false, "only cases waiting on the applicant")
+	fs.StringVar(&filter.Query, "q", "", "only cases whose receipt, label or notes contain this text")
+	if code, ok := parseOutputFlags(e, fs, args, format, 0); !ok {
+		return code
+	}
+	filter.Stage = types.CaseStage(*stage)
+	filter.Status = types.CaseStatusCode(*status)
+
+	return withWatchlist(ctx, e, sf, func(svc *watchlist.Service) int {
+		entries, err := svc.List(ctx, filter)
+		if err != nil {
+			return fail(e, err)
+		}
+		if entries == nil {
+			entries = []*watchlist.Entry{}
+		}
+		if err := printResult(e.stdout, *format, entries, func(w io.Writer) { printEntries(w, entries...) }); err != nil {
+			return fail(e, err)
+		}
+		return exitOK
+	})
+}
+
+// printEntries writes watched cases as a table
+func printEntries(w io.Writer, entries ...*watchlist.Entry) {
+	fmt.Fprintln(w, "RECEIPT\tLABEL\tOWNER\tFORM\tSTATUS\tCHECKED")
+	for _, entry := range entries {
+		status := ""
+		if entry.LastStatus != nil {
+			status = entry.LastStatus.Title
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", entry.ReceiptNumber, entry.Label, entry.Owner,
+			entry.FormType, status, formatDate(entry.LastCheckedAt))
+	}
+}
+
+func watchRemove(ctx context.Context, e *env, args []string) int {
+	fs, sf := newFlagSet(e, "watch rm", "RECEIPT")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	return withWatchlist(ctx, e, sf, func(svc *watchlist.Service) int {
+		if err := svc.Remove(ctx, fs.Arg(0)); err != nil {
+			return fail(e, err)
+		}
+		return exitOK
+	})
+}
+
+// refreshedCase is what watch poll prints for each case it refreshed
+type refreshedCase struct {
+	ReceiptNumber string `json:"receiptNumber"`
+	Status        string `json:"status"`
+	Title         string `json:"title"`
+}
+
+func watchPoll(ctx context.Context, e *env, args []string) int {
+	fs, sf := newFlagSet(e, "watch poll", "")
+	format := formatFlag(fs)
+	environment := fs.String("env", "", environmentFlag)
+	cfg := scheduler.DefaultConfig()
+	fs.DurationVar(&cfg.Interval, "interval", cfg.Interval, "time between checks of a case")
+	quietHours := fs.String("quiet-hours", "", "UTC window without checks, such as 22:00-06:00")
+	once := fs.Bool("once", false, "run a single pass and print what it checked")
+	if code, ok := parseOutputFlags(e, fs, args, format, 0); !ok {
+		return code
+	}
+	if *quietHours != "" {
+		quiet, err := scheduler.ParseQuietHours(*quietHours, time.UTC)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "uscisctl: -quiet-hours: %v\n", err)
+			return exitUsage
+		}
+		cfg.QuietHours = quiet
+	}
+
+	if *once {
+		// every case is due on a single pass, not spread out as on startup
+		cfg.InitialSpread = time.Nanosecond
+		return withService(ctx, e, sf, func(svc *service.Service) int {
+			poller, err := svc.NewPoller(*environment, cfg)
+			if err != nil {
+				return fail(e, err)
+			}
+			stats, err := poller.RunOnce(ctx)
+			if err != nil {
+				return fail(e, err)
+			}
+			err = printResult(e.stdout, *format, stats, func(w io.Writer) {
+				printFields(w,
+					"Watched", fmt.Sprint(stats.Targets),
+					"Checked", fmt.Sprint(stats.Checked),
+					"Failed", fmt.Sprint(stats.Failed),
+					"Deferred", fmt.Sprint(stats.Deferred),
+					"Quiet hours", yesNo(stats.Quiet),
+				)
+			})
+			if err != nil {
+				return fail(e, err)
+			}
+			if stats.Failed > 0 {
+				return exitPartial
+			}
+			return exitOK
+		})
+	}
+
+	// the poller calls back from a single goroutine
+	printRefreshed := service.WithEvents(func(ev service.Event) {
+		if ev.Type != service.EventCaseRefreshed {
+			return
+		}
+		c := refreshedCase{}
+		c.ReceiptNumber, _ = ev.Data["caseNumber"].(string)
+		c.Status, _ = ev.Data["status"].(string)
+		c.Title, _ = ev.Data["title"].(string)
+		printResult(e.stdout, *format, c, func(w io.Writer) {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", c.ReceiptNumber, c.Status, c.Title)
+		})
+	})
+	return withService(ctx, e, sf, func(svc *service.Service) int {
+		if err := svc.StartPolling(ctx, *environment, cfg); err != nil {
+			return fail(e, err)
+		}
+		fmt.Fprintln(e.stderr, "uscisctl: polling watched cases until interrupted")
+		<-ctx.Done()
+		svc.StopPolling()
+		return exitOK
+	}, printRefreshed)
+}
+
+func runHealth(ctx context.Context, e *env, args []string) int {
+	fs, sf := newFlagSet(e, "health", "")
+	format := formatFlag(fs)
+	if code, ok := parseOutputFlags(e, fs, args, format, 0); !ok {
+		return code
+	}
+
+	return withService(ctx, e, sf, func(svc *service.Service) int {
+		h := svc.Health(ctx)
+		err := printResult(e.stdout, *format, h, func(w io.Writer) {
+			printFields(w, "Status", h.Status, "Version", h.Version)
+			names := make([]string, 0, len(h.Checks))
+			for name := range h.Checks {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				printFields(w, "Check "+name, h.Checks[name])
+			}
+		})
+		if err != nil {
+			return fail(e, err)
+		}
+		if !h.Healthy() {
+			return exitUnavailable
+		}
+		return exitOK
+	})
+}
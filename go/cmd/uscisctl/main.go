@@ -0,0 +1,375 @@
+// Command uscisctl looks up cases, certifies and issues tokens and manages
+// the case watchlist from the command line. Cases and token revocations are
+// kept in a store file shared between runs.
+//
+// Usage:
+//
+//	uscisctl status [-env ENV] RECEIPT
+//	uscisctl certify -token TOKEN|- -case RECEIPT [-env ENV]
+//	uscisctl issue-token -case RECEIPT -subject NAME [-ttl 24h]
+//	uscisctl revoke ID | -token TOKEN|-
+//	uscisctl watch add [-label TEXT] [-owner NAME] [-notes TEXT] RECEIPT
+//	uscisctl watch list [-owner NAME] [-label TEXT] [-form FORM] [-stage STAGE] [-status CODE] [-action-required] [-q TEXT]
+//	uscisctl watch rm RECEIPT
+//	uscisctl watch poll [-env ENV] [-interval 6h] [-quiet-hours 22:00-06:00] [-once]
+//	uscisctl scan [-radius N] [-env ENV] RECEIPT
+//	uscisctl validate-secret [-]
+//	uscisctl health
+//	uscisctl import [-format csv|json|ndjson] [-dry-run] [-json] FILE
+//	uscisctl export [-format csv|json|ndjson] [-fields LIST] [-mask LIST] [-events] [-o FILE] [RECEIPT]
+//	uscisctl calendar [-appointments FILE] [-notices=false] [-o FILE] [RECEIPT]
+//	uscisctl vault init|rotate
+//	uscisctl vault set NAME
+//
+// Commands that print results accept -format table, json or ndjson; NDJSON
+// writes one line per case or check.
+//
+// 'watch poll' refreshes the watched cases with the same poller as
+// 'uscis-server -poll', printing each case it refreshed until interrupted;
+// with -once it makes a single pass and prints what it checked.
+//
+// Every command accepts -store to choose the store file, which otherwise
+// comes from $USCISCTL_STORE or defaults to myuscis/store.db in the user's
+// configuration directory.
+//
+// With a vault, chosen with -vault or $USCISCTL_VAULT or created in the
+// configuration directory by 'uscisctl vault init', the store is encrypted
+// at rest with the master key kept in the vault; the passphrase is read
+// from $USCISCTL_PASSPHRASE. An existing unencrypted store is encrypted the
+// first time it is opened with a vault.
+//
+// Tokens are signed and checked with the HS256 key in the vault secret
+// jwt_signing_key, or $JWT_SIGNING_KEY. validate-secret checks the client
+// credentials in the vault secrets client_id and client_secret, or
+// $USCIS_CLIENT_ID and $USCIS_CLIENT_SECRET; store them with
+// 'uscisctl vault set NAME', which reads the value from standard input.
+//
+// The exit status is 0 on success, 1 on errors, 2 for usage errors, 3 when
+// an import rejected rows or lookups of a scan failed, 4 when a token, secret
+// or input was rejected, 5 when a case or secret was not found and 6 when a
+// lookup failed, timed out or was rate limited, or the health check failed.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+
+	"MyUSCISgo/pkg/logging"
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/service"
+	"MyUSCISgo/pkg/store"
+	"MyUSCISgo/pkg/validation"
+	"MyUSCISgo/pkg/vault"
+	"MyUSCISgo/pkg/watchlist"
+)
+
+// Exit codes
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitUsage       = 2
+	exitPartial     = 3 // some rows of an import were rejected, or lookups of a scan failed
+	exitInvalid     = 4 // a token, secret or input was rejected
+	exitNotFound    = 5 // a case or secret was not found
+	exitUnavailable = 6 // a lookup failed or was rate limited, or the health check failed
+)
+
+// Environment variables
+const (
+	storeEnv      = "USCISCTL_STORE"
+	vaultEnv      = "USCISCTL_VAULT"
+	passphraseEnv = "USCISCTL_PASSPHRASE"
+	signingKeyEnv = "JWT_SIGNING_KEY"
+	clientIDEnv   = "USCIS_CLIENT_ID"
+	secretEnv     = "USCIS_CLIENT_SECRET"
+)
+
+// Vault secrets
+const (
+	signingKeySecret = "jwt_signing_key"
+	clientIDSecret   = "client_id"
+	clientSecret     = "client_secret"
+)
+
+// command is a uscisctl subcommand
+type command struct {
+	summary string
+	run     func(ctx context.Context, env *env, args []string) int
+}
+
+var commands = map[string]command{
+	"status":          {"look up the current status of a case", runStatus},
+	"certify":         {"check a certification token against a case", runCertify},
+	"issue-token":     {"sign a certification token for a case", runIssueToken},
+	"revoke":          {"revoke a certification token", runRevoke},
+	"watch":           {"add, list, remove or poll watched cases", runWatch},
+	"scan":            {"check the receipts filed next to a case", runScan},
+	"validate-secret": {"check the client credentials", runValidateSecret},
+	"health":          {"check the store and token configuration", runHealth},
+	"import":          {"add receipts from a CSV, JSON or NDJSON file to the watchlist", runImport},
+	"export":          {"write watched cases as CSV, JSON or NDJSON", runExport},
+	"calendar":        {"write watched cases as an iCalendar file", runCalendar},
+	"vault":           {"create the key vault, rotate the store encryption key or set a secret", runVault},
+}
+
+// env is what a command runs against
+type env struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+	getenv         func(string) string
+	configDir      func() (string, error)
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], &env{
+		stdin:     os.Stdin,
+		stdout:    os.Stdout,
+		stderr:    os.Stderr,
+		getenv:    os.Getenv,
+		configDir: os.UserConfigDir,
+	})
+	stop()
+	os.Exit(code)
+}
+
+// run executes the command line and returns the exit code
+func run(ctx context.Context, args []string, e *env) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		usage(e.stderr)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(e.stderr, "uscisctl: unknown command %q\n\n", args[0])
+		usage(e.stderr)
+		return exitUsage
+	}
+	return cmd.run(ctx, e, args[1:])
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: uscisctl <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-16s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'uscisctl <command> -h' for the flags of a command.")
+}
+
+// storeFlags locate the store file and the vault holding its key
+type storeFlags struct {
+	store string
+	vault string
+}
+
+// newFlagSet creates the flag set of a command, with the -store and -vault
+// flags every command shares
+func newFlagSet(e *env, name, args string) (*flag.FlagSet, *storeFlags) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: uscisctl %s [flags] %s\n\nFlags:\n", name, args)
+		fs.PrintDefaults()
+	}
+	sf := &storeFlags{}
+	fs.StringVar(&sf.store, "store", "", "store file (default $"+storeEnv+" or the user config directory)")
+	fs.StringVar(&sf.vault, "vault", "", "vault holding the store encryption key (default $"+vaultEnv+")")
+	return fs, sf
+}
+
+// parseFlags parses a command's flags, returning an exit code when the
+// command should stop
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+// defaultPath returns a file in the myuscis configuration directory
+func defaultPath(e *env, name string) (string, error) {
+	dir, err := e.configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "myuscis", name), nil
+}
+
+// storePath resolves the store file from the flag, the environment or the
+// default location
+func storePath(e *env, flagValue string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	if path := e.getenv(storeEnv); path != "" {
+		return path, nil
+	}
+	path, err := defaultPath(e, "store.db")
+	if err != nil {
+		return "", fmt.Errorf("no store file: set -store or $%s: %w", storeEnv, err)
+	}
+	return path, nil
+}
+
+// vaultPath returns the vault file from the flag, the environment or the
+// default location if a vault exists there, or "" when the store is not
+// encrypted
+func vaultPath(e *env, sf *storeFlags) string {
+	if sf.vault != "" {
+		return sf.vault
+	}
+	if path := e.getenv(vaultEnv); path != "" {
+		return path
+	}
+	if path, err := defaultPath(e, "vault.json"); err == nil {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// openVault unseals the vault with the passphrase from the environment
+func openVault(e *env, path string) (*vault.Vault, error) {
+	passphrase := e.getenv(passphraseEnv)
+	if passphrase == "" {
+		return nil, fmt.Errorf("set $%s to unlock the vault", passphraseEnv)
+	}
+	return vault.Open(path, passphrase)
+}
+
+// configuredVault opens the vault of sf, or returns nil when there is none
+func configuredVault(e *env, sf *storeFlags) (*vault.Vault, error) {
+	path := vaultPath(e, sf)
+	if path == "" {
+		return nil, nil
+	}
+	return openVault(e, path)
+}
+
+// credential returns a vault secret, falling back to an environment
+// variable when there is no vault or the secret is not set
+func credential(e *env, v *vault.Vault, secret, envName string) string {
+	if v != nil {
+		if value, err := v.Secret(secret); err == nil && value != "" {
+			return value
+		}
+	}
+	return e.getenv(envName)
+}
+
+// openBackend opens the store file, encrypted with the keys of v unless v
+// is nil. It also returns the file so callers can compact it.
+func openBackend(ctx context.Context, e *env, sf *storeFlags, v *vault.Vault) (store.Backend, *store.File, error) {
+	path, err := storePath(e, sf.store)
+	if err != nil {
+		return nil, nil, err
+	}
+	return vault.OpenBackend(ctx, path, v)
+}
+
+// openStore opens the store, encrypted with the keys of v unless v is nil,
+// and migrates it to the latest schema
+func openStore(ctx context.Context, e *env, sf *storeFlags, v *vault.Vault) (*store.Store, error) {
+	backend, _, err := openBackend(ctx, e, sf, v)
+	if err != nil {
+		return nil, err
+	}
+	s, err := store.Open(ctx, backend)
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// withWatchlist runs fn against the watchlist in the store, closing the
+// store afterwards
+func withWatchlist(ctx context.Context, e *env, sf *storeFlags, fn func(*watchlist.Service) int) int {
+	v, err := configuredVault(e, sf)
+	if err != nil {
+		return fail(e, err)
+	}
+	s, err := openStore(ctx, e, sf, v)
+	if err != nil {
+		return fail(e, err)
+	}
+	code := fn(watchlist.NewService(s.Watchlist()))
+	if err := s.Close(); err != nil && code == exitOK {
+		return fail(e, err)
+	}
+	return code
+}
+
+// withService runs fn against a service over the store, with the token
+// signing key from the vault or the environment, closing the store
+// afterwards. opts are applied after those defaults.
+func withService(ctx context.Context, e *env, sf *storeFlags, fn func(*service.Service) int, opts ...service.Option) int {
+	v, err := configuredVault(e, sf)
+	if err != nil {
+		return fail(e, err)
+	}
+	s, err := openStore(ctx, e, sf, v)
+	if err != nil {
+		return fail(e, err)
+	}
+	svc := service.New(append([]service.Option{
+		// failures are reported by the commands themselves
+		service.WithLogger(logging.NewLogger(logging.LogLevelFatal)),
+		service.WithStore(s),
+		service.WithSigningKey(credential(e, v, signingKeySecret, signingKeyEnv)),
+	}, opts...)...)
+	code := fn(svc)
+	if err := s.Close(); err != nil && code == exitOK {
+		return fail(e, err)
+	}
+	return code
+}
+
+// fail reports an error and returns the exit code for it
+func fail(e *env, err error) int {
+	if errors.Is(err, service.ErrNotConfigured) {
+		err = fmt.Errorf("%w: set $%s or the %s vault secret", err, signingKeyEnv, signingKeySecret)
+	}
+	fmt.Fprintf(e.stderr, "uscisctl: %v\n", err)
+	return exitCode(err)
+}
+
+// exitCode maps an error to the exit code scripts can act on
+func exitCode(err error) int {
+	var (
+		fieldErr validation.ValidationError
+		parseErr *receipt.ParseError
+	)
+	switch {
+	case errors.Is(err, service.ErrInvalidToken), errors.As(err, &fieldErr), errors.As(err, &parseErr):
+		return exitInvalid
+	case errors.Is(err, watchlist.ErrNotFound), errors.Is(err, vault.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, service.ErrRateLimited), errors.Is(err, service.ErrTimeout),
+		errors.Is(err, service.ErrLookupFailed), errors.Is(err, context.DeadlineExceeded):
+		return exitUnavailable
+	}
+	return exitError
+}
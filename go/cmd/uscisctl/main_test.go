@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runCLI runs uscisctl with dir as its configuration directory
+func runCLI(t *testing.T, dir, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	return runCLIEnv(t, dir, nil, stdin, args...)
+}
+
+// runCLIEnv runs uscisctl with environment variables
+func runCLIEnv(t *testing.T, dir string, vars map[string]string, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	e := &env{
+		stdin:     strings.NewReader(stdin),
+		stdout:    &stdout,
+		stderr:    &stderr,
+		getenv:    func(key string) string { return vars[key] },
+		configDir: func() (string, error) { return dir, nil },
+	}
+	code := run(context.Background(), args, e)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"no command", nil, exitUsage},
+		{"help", []string{"help"}, exitOK},
+		{"unknown command", []string{"frobnicate"}, exitUsage},
+		{"command help", []string{"import", "-h"}, exitOK},
+		{"bad flag", []string{"export", "-nope"}, exitUsage},
+		{"missing file", []string{"import"}, exitUsage},
+		{"bad format", []string{"export", "-format", "xml"}, exitUsage},
+		{"bad field", []string{"export", "-fields", "ssn"}, exitUsage},
+		{"bad mask", []string{"export", "-mask", "ssn"}, exitUsage},
+		{"bad output format", []string{"status", "-format", "xml", "EAC2190050123"}, exitUsage},
+		{"missing receipt", []string{"status"}, exitUsage},
+		{"certify without a case", []string{"certify", "-token", "a.b.c"}, exitUsage},
+		{"revoke with an ID and a token", []string{"revoke", "-token", "a.b.c", "id"}, exitUsage},
+		{"unknown watch command", []string{"watch", "edit"}, exitUsage},
+		{"bad quiet hours", []string{"watch", "poll", "-quiet-hours", "late"}, exitUsage},
+		{"vault set without a name", []string{"vault", "set"}, exitUsage},
+		{"calendar with two cases", []string{"calendar", "EAC2190050123", "EAC2190050124"}, exitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _, stderr := runCLI(t, t.TempDir(), "", tt.args...); code != tt.want {
+				t.Errorf("exit code = %d, want %d\n%s", code, tt.want, stderr)
+			}
+		})
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	dir := t.TempDir()
+	input := "receipt,label,owner\nIOE0912345678,Mine,ana@example.com\nEAC2190000001,Dad,\nbad,,\n"
+
+	code, stdout, stderr := runCLI(t, dir, input, "import", "-dry-run", "-")
+	if code != exitPartial || !strings.Contains(stdout, "would add 2") {
+		t.Fatalf("dry run: exit %d\n%s%s", code, stdout, stderr)
+	}
+	code, stdout, _ = runCLI(t, dir, "", "export", "-fields", "receiptNumber")
+	if code != exitOK || stdout != "receiptNumber\n" {
+		t.Fatalf("dry run changed the watchlist: exit %d\n%s", code, stdout)
+	}
+
+	path := filepath.Join(dir, "receipts.csv")
+	if err := os.WriteFile(path, []byte(input), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	code, stdout, stderr = runCLI(t, dir, "", "import", path)
+	if code != exitPartial || !strings.Contains(stdout, "added 2, skipped 0, rejected 1") ||
+		!strings.Contains(stdout, `rejected row 4: "bad"`) {
+		t.Fatalf("import: exit %d\n%s%s", code, stdout, stderr)
+	}
+
+	if code, _, _ := runCLI(t, dir, "", "import", "a.csv", "b.csv"); code != exitUsage {
+		t.Errorf("import with two files: exit %d", code)
+	}
+	code, stdout, stderr = runCLI(t, dir, "\"IOE0912345678\"\n", "import", "-json", "-format", "ndjson", "-")
+	var report struct {
+		Added   []string
+		Skipped []struct{ Line int }
+	}
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil || code != exitOK {
+		t.Fatalf("import -json: exit %d, %v\n%s%s", code, err, stdout, stderr)
+	}
+	if len(report.Added) != 0 || len(report.Skipped) != 1 {
+		t.Errorf("report = %+v, want the receipt skipped", report)
+	}
+
+	code, stdout, stderr = runCLI(t, dir, "", "export", "-fields", "receiptNumber,label,owner", "-mask", "owners,receipts")
+	want := "receiptNumber,label,owner\nEAC******0001,Dad,\nIOE******5678,Mine,a***@example.com\n"
+	if code != exitOK || !sameLines(stdout, want) {
+		t.Errorf("export: exit %d\n%s%s\nwant\n%s", code, stdout, stderr, want)
+	}
+
+	out := filepath.Join(dir, "case.ndjson")
+	code, _, stderr = runCLI(t, dir, "", "export", "-o", out, "-fields", "receiptNumber,label", "ioe0912345678")
+	data, err := os.ReadFile(out)
+	if code != exitOK || err != nil || string(data) != `{"receiptNumber":"IOE0912345678","label":"Mine"}`+"\n" {
+		t.Errorf("export to file: exit %d, %v\n%s%s", code, err, data, stderr)
+	}
+
+	if code, _, _ := runCLI(t, dir, "", "export", "WAC0000000000"); code != exitInvalid {
+		t.Errorf("export of an invalid receipt: exit %d, want %d", code, exitInvalid)
+	}
+	if code, _, _ := runCLI(t, dir, "", "export", "WAC2190000002"); code != exitNotFound {
+		t.Errorf("export of an unwatched case: exit %d, want %d", code, exitNotFound)
+	}
+}
+
+func TestExportMasksIdentifiers(t *testing.T) {
+	dir := t.TempDir()
+	if code, stdout, stderr := runCLI(t, dir, "receipt,label\nIOE0912345678,Mine A123456789\n", "import", "-"); code != exitOK {
+		t.Fatalf("import: exit %d\n%s%s", code, stdout, stderr)
+	}
+
+	for _, tt := range []struct {
+		mask []string
+		want string
+	}{
+		{nil, "label\nMine A*****6789\n"},
+		{[]string{"-mask", "owners"}, "label\nMine A*****6789\n"},
+		{[]string{"-mask", "none"}, "label\nMine A123456789\n"},
+	} {
+		args := append([]string{"export", "-fields", "label"}, tt.mask...)
+		if code, stdout, stderr := runCLI(t, dir, "", args...); code != exitOK || stdout != tt.want {
+			t.Errorf("export %v: exit %d\n%s%s\nwant\n%s", tt.mask, code, stdout, stderr, tt.want)
+		}
+	}
+}
+
+func TestWatchPoll(t *testing.T) {
+	dir := t.TempDir()
+	for _, number := range []string{"EAC2190050123", "IOE0912345678"} {
+		if code, _, stderr := runCLI(t, dir, "", "watch", "add", number); code != exitOK {
+			t.Fatalf("watch add: exit %d\n%s", code, stderr)
+		}
+	}
+
+	code, stdout, stderr := runCLI(t, dir, "", "watch", "poll", "-once", "-format", "json", "-env", "staging")
+	var stats struct{ Targets, Checked, Failed int }
+	if err := json.Unmarshal([]byte(stdout), &stats); err != nil || code != exitOK {
+		t.Fatalf("watch poll: exit %d, %v\n%s%s", code, err, stdout, stderr)
+	}
+	if stats.Targets != 2 || stats.Checked != 2 || stats.Failed != 0 {
+		t.Errorf("stats = %+v, want both cases checked", stats)
+	}
+
+	// the refreshed statuses were recorded on the watchlist
+	code, stdout, _ = runCLI(t, dir, "", "watch", "list", "-format", "ndjson")
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	for _, line := range lines {
+		var entry struct{ LastStatus *struct{ Code string } }
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.LastStatus == nil {
+			t.Errorf("watch list after a poll: exit %d, %v\n%s", code, err, line)
+		}
+	}
+
+	if code, _, _ := runCLI(t, dir, "", "watch", "poll", "-once", "-env", "moon"); code != exitInvalid {
+		t.Errorf("watch poll in an unknown environment: exit %d, want %d", code, exitInvalid)
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	dir := t.TempDir()
+	if code, _, stderr := runCLI(t, dir, "", "watch", "add", "EAC2190050123"); code != exitOK {
+		t.Fatalf("watch add: exit %d\n%s", code, stderr)
+	}
+
+	// nothing is known about the case until it is looked up
+	code, stdout, stderr := runCLI(t, dir, "", "calendar")
+	if code != exitOK || strings.Contains(stdout, "BEGIN:VEVENT") || !strings.Contains(stderr, "uscisctl status") {
+		t.Errorf("calendar before a lookup: exit %d\n%s%s", code, stdout, stderr)
+	}
+	if code, _, stderr := runCLI(t, dir, "", "status", "EAC2190050123"); code != exitOK {
+		t.Fatalf("status: exit %d\n%s", code, stderr)
+	}
+
+	appointments := filepath.Join(dir, "appointments.json")
+	data := `[{"receiptNumber":"EAC2190050123","kind":"interview","start":"2031-03-04T15:00:00Z","location":"Field office"}]`
+	if err := os.WriteFile(appointments, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "case.ics")
+	code, _, stderr = runCLI(t, dir, "", "calendar", "-appointments", appointments, "-o", out, "eac2190050123")
+	ics, err := os.ReadFile(out)
+	if code != exitOK || err != nil {
+		t.Fatalf("calendar to file: exit %d, %v\n%s", code, err, stderr)
+	}
+	for _, want := range []string{"BEGIN:VCALENDAR", "X-WR-CALNAME:USCIS case EAC2190050123", "DTSTART:20310304T150000Z", "LOCATION:Field office"} {
+		if !strings.Contains(string(ics), want) {
+			t.Errorf("calendar is missing %q\n%s", want, ics)
+		}
+	}
+
+	if code, _, _ := runCLI(t, dir, "", "calendar", "WAC2190000002"); code != exitNotFound {
+		t.Errorf("calendar of an unwatched case: exit %d, want %d", code, exitNotFound)
+	}
+	if err := os.WriteFile(appointments, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if code, _, _ := runCLI(t, dir, "", "calendar", "-appointments", appointments); code != exitInvalid {
+		t.Errorf("calendar with bad appointments: exit %d, want %d", code, exitInvalid)
+	}
+}
+
+// sameLines compares the header and the set of rows of two CSV outputs
+func sameLines(got, want string) bool {
+	g, w := strings.Split(got, "\n"), strings.Split(want, "\n")
+	if len(g) != len(w) || g[0] != w[0] {
+		return false
+	}
+	seen := make(map[string]bool)
+	for _, line := range g[1:] {
+		seen[line] = true
+	}
+	for _, line := range w[1:] {
+		if !seen[line] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestVault(t *testing.T) {
+	dir := t.TempDir()
+	vars := map[string]string{passphraseEnv: "correct horse"}
+	storeFile := filepath.Join(dir, "myuscis", "store.db")
+
+	// an unencrypted store is encrypted once a vault exists
+	if code, _, stderr := runCLI(t, dir, "IOE0912345678,Mine\n", "import", "-"); code != exitOK {
+		t.Fatalf("import: exit %d\n%s", code, stderr)
+	}
+	if code, _, stderr := runCLIEnv(t, dir, nil, "", "vault", "init"); code != exitError {
+		t.Errorf("vault init without a passphrase: exit %d\n%s", code, stderr)
+	}
+	code, stdout, stderr := runCLIEnv(t, dir, vars, "", "vault", "init")
+	if code != exitOK || !strings.HasPrefix(stdout, "created vault "+filepath.Join(dir, "myuscis", "vault.json")) {
+		t.Fatalf("vault init: exit %d\n%s%s", code, stdout, stderr)
+	}
+	if code, _, _ := runCLIEnv(t, dir, vars, "", "vault", "init"); code != exitError {
+		t.Errorf("second vault init: exit %d, want %d", code, exitError)
+	}
+
+	if code, _, stderr := runCLIEnv(t, dir, vars, "EAC2190000001,Dad\n", "import", "-"); code != exitOK {
+		t.Fatalf("encrypted import: exit %d\n%s", code, stderr)
+	}
+	data, err := os.ReadFile(storeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"IOE0912345678", "EAC2190000001", "Mine", "Dad"} {
+		if bytes.Contains(data, []byte(secret)) {
+			t.Errorf("store file holds %q in the clear", secret)
+		}
+	}
+
+	if code, _, _ := runCLI(t, dir, "", "export"); code != exitError {
+		t.Errorf("export without the passphrase: exit %d, want %d", code, exitError)
+	}
+	wrong := map[string]string{passphraseEnv: "wrong horse"}
+	if code, _, _ := runCLIEnv(t, dir, wrong, "", "export"); code != exitError {
+		t.Errorf("export with the wrong passphrase: exit %d, want %d", code, exitError)
+	}
+
+	code, stdout, stderr = runCLIEnv(t, dir, vars, "", "vault", "rotate")
+	if code != exitOK || !strings.Contains(stdout, "retired 1 keys") {
+		t.Fatalf("vault rotate: exit %d\n%s%s", code, stdout, stderr)
+	}
+	// the records rewrapped with the new key replaced the old ones in the
+	// log rather than being appended after them
+	rotated, err := os.ReadFile(storeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) > len(data) {
+		t.Errorf("store file grew from %d to %d bytes on rotation, want it compacted", len(data), len(rotated))
+	}
+	code, stdout, stderr = runCLIEnv(t, dir, vars, "", "export", "-fields", "receiptNumber,label")
+	want := "receiptNumber,label\nEAC2190000001,Dad\nIOE0912345678,Mine\n"
+	if code != exitOK || !sameLines(stdout, want) {
+		t.Errorf("export after rotation: exit %d\n%s%s", code, stdout, stderr)
+	}
+}
+
+func TestCaseCommands(t *testing.T) {
+	dir := t.TempDir()
+
+	code, stdout, stderr := runCLI(t, dir, "", "watch", "add", "-label", "Mine", "-owner", "ana@example.com", "eac2190050123")
+	if code != exitOK || !strings.Contains(stdout, "EAC2190050123") {
+		t.Fatalf("watch add: exit %d\n%s%s", code, stdout, stderr)
+	}
+	if code, _, _ := runCLI(t, dir, "", "watch", "add", "EAC2190050123"); code != exitError {
+		t.Errorf("second watch add: exit %d, want %d", code, exitError)
+	}
+
+	code, stdout, stderr = runCLI(t, dir, "", "status", "-format", "json", "EAC2190050123")
+	var report struct {
+		Case    struct{ ReceiptNumber string }
+		Watched bool
+	}
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil || code != exitOK {
+		t.Fatalf("status: exit %d, %v\n%s%s", code, err, stdout, stderr)
+	}
+	if report.Case.ReceiptNumber != "EAC2190050123" || !report.Watched {
+		t.Errorf("status = %+v", report)
+	}
+	if code, _, _ := runCLI(t, dir, "", "status", "XYZ123"); code != exitInvalid {
+		t.Errorf("status of an invalid receipt: exit %d, want %d", code, exitInvalid)
+	}
+
+	// the status lookup was recorded on the watched case
+	code, stdout, _ = runCLI(t, dir, "", "watch", "list", "-format", "ndjson", "-owner", "ana@example.com")
+	var entry struct {
+		ReceiptNumber string
+		LastStatus    *struct{ Code string }
+	}
+	if err := json.Unmarshal([]byte(stdout), &entry); err != nil || code != exitOK || entry.LastStatus == nil {
+		t.Errorf("watch list: exit %d, %v\n%s", code, err, stdout)
+	}
+	if code, stdout, _ = runCLI(t, dir, "", "watch", "list", "-format", "json", "-owner", "bob"); code != exitOK || strings.TrimSpace(stdout) != "[]" {
+		t.Errorf("filtered watch list: exit %d\n%s", code, stdout)
+	}
+
+	code, stdout, stderr = runCLI(t, dir, "", "scan", "-radius", "1", "EAC2190050123")
+	if code != exitOK || !strings.Contains(stdout, "Scanned:  2") {
+		t.Errorf("scan: exit %d\n%s%s", code, stdout, stderr)
+	}
+
+	if code, _, stderr := runCLI(t, dir, "", "watch", "rm", "EAC2190050123"); code != exitOK {
+		t.Errorf("watch rm: exit %d\n%s", code, stderr)
+	}
+	if code, _, _ := runCLI(t, dir, "", "watch", "rm", "EAC2190050123"); code != exitNotFound {
+		t.Errorf("second watch rm: exit %d, want %d", code, exitNotFound)
+	}
+
+	code, stdout, stderr = runCLI(t, dir, "", "health")
+	if code != exitOK || !strings.Contains(stdout, "not configured") {
+		t.Errorf("health: exit %d\n%s%s", code, stdout, stderr)
+	}
+}
+
+func TestTokenCommands(t *testing.T) {
+	dir := t.TempDir()
+	vars := map[string]string{passphraseEnv: "correct horse"}
+	const receipt = "IOE0912345678"
+
+	if code, _, stderr := runCLIEnv(t, dir, nil, "", "issue-token", "-case", receipt, "-subject", "ana"); code != exitError ||
+		!strings.Contains(stderr, signingKeyEnv) {
+		t.Errorf("issue-token without a key: exit %d\n%s", code, stderr)
+	}
+
+	// the signing key is read from the vault
+	if code, _, stderr := runCLIEnv(t, dir, vars, "", "vault", "init"); code != exitOK {
+		t.Fatalf("vault init: exit %d\n%s", code, stderr)
+	}
+	if code, _, stderr := runCLIEnv(t, dir, vars, "vault-key\n", "vault", "set", signingKeySecret); code != exitOK {
+		t.Fatalf("vault set: exit %d\n%s", code, stderr)
+	}
+	code, stdout, stderr := runCLIEnv(t, dir, vars, "", "issue-token", "-format", "json", "-case", receipt, "-subject", "ana")
+	var issued issuedToken
+	if err := json.Unmarshal([]byte(stdout), &issued); err != nil || code != exitOK {
+		t.Fatalf("issue-token: exit %d, %v\n%s%s", code, err, stdout, stderr)
+	}
+
+	code, stdout, stderr = runCLIEnv(t, dir, vars, issued.Token+"\n", "certify", "-token", "-", "-case", receipt)
+	if code != exitOK || !strings.Contains(stdout, "Verification ID:") {
+		t.Errorf("certify: exit %d\n%s%s", code, stdout, stderr)
+	}
+	vaultFirst := map[string]string{passphraseEnv: vars[passphraseEnv], signingKeyEnv: "other-key"}
+	if code, _, stderr := runCLIEnv(t, dir, vaultFirst, "", "certify", "-token", issued.Token, "-case", receipt); code != exitOK {
+		t.Errorf("certify with another key in the environment: exit %d\n%s", code, stderr)
+	}
+	if code, _, _ := runCLIEnv(t, dir, vars, "", "certify", "-token", issued.Token, "-case", "EAC2190050123"); code != exitInvalid {
+		t.Errorf("certify for another case: exit %d, want %d", code, exitInvalid)
+	}
+
+	code, stdout, stderr = runCLIEnv(t, dir, vars, "", "revoke", "-format", "ndjson", "-token", issued.Token)
+	if code != exitOK || stdout != fmt.Sprintf(`{"id":%q,"revoked":true}`+"\n", issued.ID) {
+		t.Errorf("revoke: exit %d\n%s%s", code, stdout, stderr)
+	}
+	if code, _, _ := runCLIEnv(t, dir, vars, "", "certify", "-token", issued.Token, "-case", receipt); code != exitInvalid {
+		t.Errorf("certify with a revoked token: exit %d, want %d", code, exitInvalid)
+	}
+}
+
+func TestValidateSecret(t *testing.T) {
+	tests := []struct {
+		name   string
+		vars   map[string]string
+		stdin  string
+		args   []string
+		want   int
+		failed string
+	}{
+		{"strong secret", map[string]string{secretEnv: "Xk9#mQ2vLp7w"}, "", nil, exitOK, ""},
+		{"weak pattern", map[string]string{secretEnv: "password1"}, "", nil, exitInvalid, "secret strength"},
+		{"no number", map[string]string{secretEnv: "abcdefghij"}, "", nil, exitInvalid, "secret format"},
+		{"from stdin", map[string]string{secretEnv: "password1"}, "Xk9#mQ2vLp7w\n", []string{"-"}, exitOK, ""},
+		{"missing", nil, "", nil, exitError, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"validate-secret", "-format", "json"}, tt.args...)
+			code, stdout, stderr := runCLIEnv(t, t.TempDir(), tt.vars, tt.stdin, args...)
+			if code != tt.want {
+				t.Fatalf("exit %d, want %d\n%s%s", code, tt.want, stdout, stderr)
+			}
+			if tt.failed == "" {
+				return
+			}
+			var checks []secretCheck
+			if err := json.Unmarshal([]byte(stdout), &checks); err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range checks {
+				if c.Check == tt.failed && !c.Passed {
+					return
+				}
+			}
+			t.Errorf("checks = %+v, want %s to fail", checks, tt.failed)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"text/tabwriter"
+	"time"
+)
+
+// Output formats of the commands that print results
+const (
+	formatTable  = "table"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+)
+
+// formatFlag adds the -format flag choosing how results are printed
+func formatFlag(fs *flag.FlagSet) *string {
+	return fs.String("format", formatTable, "output format: table, json or ndjson")
+}
+
+// parseOutputFlags parses the flags of a command that prints results and
+// checks the output format and that the command was given nargs
+// arguments, or any number when nargs is negative
+func parseOutputFlags(e *env, fs *flag.FlagSet, args []string, format *string, nargs int) (int, bool) {
+	if code, ok := parseFlags(fs, args); !ok {
+		return code, false
+	}
+	switch *format {
+	case formatTable, formatJSON, formatNDJSON:
+	default:
+		fmt.Fprintf(e.stderr, "uscisctl: unknown output format %q\n", *format)
+		return exitUsage, false
+	}
+	if nargs >= 0 && fs.NArg() != nargs {
+		fs.Usage()
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+// printResult writes v as indented JSON, as NDJSON with one line per
+// element when v is a slice, or as the table written by table
+func printResult(w io.Writer, format string, v any, table func(w io.Writer)) error {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case formatNDJSON:
+		enc := json.NewEncoder(w)
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice {
+			for i := range rv.Len() {
+				if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return enc.Encode(v)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+// printFields writes name and value pairs as table rows, skipping empty
+// values
+func printFields(w io.Writer, pairs ...string) {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			fmt.Fprintf(w, "%s:\t%s\n", pairs[i], pairs[i+1])
+		}
+	}
+}
+
+// formatDate renders a date for a table, or "" for the zero time
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// yesNo renders a flag for a table
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
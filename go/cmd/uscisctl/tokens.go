@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"MyUSCISgo/pkg/security"
+	"MyUSCISgo/pkg/service"
+	"MyUSCISgo/pkg/validation"
+)
+
+// maxTokenSize bounds a token read from standard input
+const maxTokenSize = 16 << 10
+
+// issuedToken is what issue-token prints
+type issuedToken struct {
+	Token      string    `json:"token"`
+	ID         string    `json:"id"`
+	Subject    string    `json:"subject"`
+	CaseNumber string    `json:"caseNumber"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// revokedToken is what revoke prints
+type revokedToken struct {
+	ID      string `json:"id"`
+	Revoked bool   `json:"revoked"`
+}
+
+// secretCheck is the outcome of one check of validate-secret
+type secretCheck struct {
+	Check   string `json:"check"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// readToken returns a token flag value, reading it from standard input
+// when it is "-" so it stays out of the process list
+func readToken(e *env, value string) (string, error) {
+	if value != "-" {
+		return value, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(e.stdin, maxTokenSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxTokenSize {
+		return "", fmt.Errorf("token is longer than %d bytes", maxTokenSize)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", errors.New("no token on standard input")
+	}
+	return token, nil
+}
+
+func runIssueToken(ctx context.Context, e *env, args []string) int {
+	fs, sf := newFlagSet(e, "issue-token", "")
+	format := formatFlag(fs)
+	caseNumber := fs.String("case", "", "receipt number the token certifies")
+	subject := fs.String("subject", "", "who the token is issued to")
+	ttl := fs.Duration("ttl", 24*time.Hour, "how long the token is valid")
+	if code, ok := parseOutputFlags(e, fs, args, format, 0); !ok {
+		return code
+	}
+	if *caseNumber == "" || *subject == "" {
+		fs.Usage()
+		return exitUsage
+	}
+
+	return withService(ctx, e, sf, func(svc *service.Service) int {
+		token, claims, err := svc.IssueToken(ctx, *subject, *caseNumber, *ttl)
+		if err != nil {
+			return fail(e, err)
+		}
+		issued := issuedToken{
+			Token:      token,
+			ID:         claims.ID(),
+			Subject:    claims.Subject,
+			CaseNumber: claims.CaseNumber,
+			ExpiresAt:  time.Unix(claims.ExpiresAt, 0).UTC(),
+		}
+		err = printResult(e.stdout, *format, issued, func(w io.Writer) {
+			printFields(w,
+				"Token", issued.Token,
+				"ID", issued.ID,
+				"Case", issued.CaseNumber,
+				"Expires", issued.ExpiresAt.Format(time.RFC3339),
+			)
+		})
+		if err != nil {
+			return fail(e, err)
+		}
+		return exitOK
+	})
+}
+
+func runRevoke(ctx context.Context, e *env, args []string) int {
+	fs, sf := newFlagSet(e, "revoke", "ID")
+	format := formatFlag(fs)
+	token := fs.String("token", "", "revoke this token instead of an ID, or - to read it from standard input")
+	if code, ok := parseOutputFlags(e, fs, args, format, -1); !ok {
+		return code
+	}
+	// exactly one of an ID or -token
+	if (*token == "") == (fs.NArg() == 0) || fs.NArg() > 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	value, err := readToken(e, *token)
+	if err != nil {
+		return fail(e, err)
+	}
+
+	return withService(ctx, e, sf, func(svc *service.Service) int {
+		id := fs.Arg(0)
+		if value != "" {
+			claims, err := svc.ParseToken(value)
+			if err != nil {
+				return fail(e, err)
+			}
+			id = claims.ID()
+		}
+		if err := svc.RevokeToken(ctx, id); err != nil {
+			return fail(e, err)
+		}
+		revoked := revokedToken{ID: id, Revoked: true}
+		err := printResult(e.stdout, *format, revoked, func(w io.Writer) {
+			printFields(w, "Revoked", revoked.ID)
+		})
+		if err != nil {
+			return fail(e, err)
+		}
+		return exitOK
+	})
+}
+
+func runValidateSecret(ctx context.Context, e *env, args []string) int {
+	fs, sf := newFlagSet(e, "validate-secret", "[-]")
+	format := formatFlag(fs)
+	if code, ok := parseOutputFlags(e, fs, args, format, -1); !ok {
+		return code
+	}
+	if fs.NArg() > 1 || (fs.NArg() == 1 && fs.Arg(0) != "-") {
+		fs.Usage()
+		return exitUsage
+	}
+
+	v, err := configuredVault(e, sf)
+	if err != nil {
+		return fail(e, err)
+	}
+	clientID := credential(e, v, clientIDSecret, clientIDEnv)
+	secret := credential(e, v, clientSecret, secretEnv)
+	if fs.NArg() == 1 {
+		if secret, err = readToken(e, "-"); err != nil {
+			return fail(e, err)
+		}
+	}
+	if secret == "" {
+		return fail(e, fmt.Errorf("no client secret: set $%s or the %s vault secret, or pass - to read it from standard input",
+			secretEnv, clientSecret))
+	}
+
+	checks := checkSecret(clientID, secret)
+	err = printResult(e.stdout, *format, checks, func(w io.Writer) {
+		fmt.Fprintln(w, "CHECK\tRESULT\tMESSAGE")
+		for _, c := range checks {
+			result := "ok"
+			if !c.Passed {
+				result = "failed"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", c.Check, result, c.Message)
+		}
+	})
+	if err != nil {
+		return fail(e, err)
+	}
+	for _, c := range checks {
+		if !c.Passed {
+			return exitInvalid
+		}
+	}
+	return exitOK
+}
+
+// checkSecret runs the credential checks of the processing flow on a
+// client secret, and on the client ID when one is set
+func checkSecret(clientID, secret string) []secretCheck {
+	var checks []secretCheck
+	add := func(name string, err error) {
+		c := secretCheck{Check: name, Passed: err == nil}
+		if err != nil {
+			c.Message = err.Error()
+		}
+		checks = append(checks, c)
+	}
+	if clientID != "" {
+		add("client ID", validation.ValidateClientID(clientID))
+	}
+	add("secret format", validation.ValidateClientSecret(secret))
+	add("secret strength", security.ValidateSecretFormat(secret))
+	return checks
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"MyUSCISgo/pkg/store"
+	"MyUSCISgo/pkg/vault"
+)
+
+// maxSecretSize bounds a secret read by 'vault set'
+const maxSecretSize = 64 << 10
+
+func runVault(ctx context.Context, e *env, args []string) int {
+	fs, sf := newFlagSet(e, "vault", "init|rotate|set NAME")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	want := 1
+	if fs.Arg(0) == "set" {
+		want = 2
+	}
+	if fs.NArg() != want {
+		fs.Usage()
+		return exitUsage
+	}
+	switch fs.Arg(0) {
+	case "init":
+		return vaultInit(e, sf)
+	case "rotate":
+		return vaultRotate(ctx, e, sf)
+	case "set":
+		return vaultSet(e, sf, fs.Arg(1))
+	default:
+		fmt.Fprintf(e.stderr, "uscisctl: unknown vault command %q\n", fs.Arg(0))
+		fs.Usage()
+		return exitUsage
+	}
+}
+
+// vaultInit creates a vault; the store is encrypted with its key the next
+// time it is opened
+func vaultInit(e *env, sf *storeFlags) int {
+	path := vaultPath(e, sf)
+	if path == "" {
+		var err error
+		if path, err = defaultPath(e, "vault.json"); err != nil {
+			return fail(e, fmt.Errorf("no vault file: set -vault or $%s: %w", vaultEnv, err))
+		}
+	}
+	passphrase := e.getenv(passphraseEnv)
+	if passphrase == "" {
+		return fail(e, fmt.Errorf("set $%s to the passphrase for the new vault", passphraseEnv))
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fail(e, err)
+	}
+	v, err := vault.Create(path, passphrase)
+	if err != nil {
+		return fail(e, err)
+	}
+	fmt.Fprintf(e.stdout, "created vault %s with key %s\n", v.Path(), v.CurrentKey().ID)
+	return exitOK
+}
+
+// vaultRotate adds a new master key, rewraps the store's data keys with it,
+// compacts the store file so no record wrapped with an old key is left in
+// its log and retires the old keys
+func vaultRotate(ctx context.Context, e *env, sf *storeFlags) int {
+	path := vaultPath(e, sf)
+	if path == "" {
+		return fail(e, errors.New("no vault: run 'uscisctl vault init' first"))
+	}
+	v, err := openVault(e, path)
+	if err != nil {
+		return fail(e, err)
+	}
+	key, err := v.RotateKey()
+	if err != nil {
+		return fail(e, err)
+	}
+
+	// the old keys stay in the vault until every record is rewrapped, so an
+	// interrupted rotation can be run again
+	backend, file, err := openBackend(ctx, e, sf, v)
+	if err != nil {
+		return fail(e, err)
+	}
+	rewrapped, err := backend.(*store.Encrypted).Rotate(ctx)
+	if err == nil {
+		err = file.Compact()
+	}
+	if closeErr := backend.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fail(e, err)
+	}
+	retired, err := v.RetireKeys()
+	if err != nil {
+		return fail(e, err)
+	}
+	fmt.Fprintf(e.stdout, "rotated to key %s: rewrapped %d records, retired %d keys\n", key.ID, rewrapped, retired)
+	return exitOK
+}
+
+// vaultSet stores a secret read from standard input, such as the token
+// signing key or the client credentials
+func vaultSet(e *env, sf *storeFlags, name string) int {
+	path := vaultPath(e, sf)
+	if path == "" {
+		return fail(e, errors.New("no vault: run 'uscisctl vault init' first"))
+	}
+	v, err := openVault(e, path)
+	if err != nil {
+		return fail(e, err)
+	}
+	value, err := io.ReadAll(io.LimitReader(e.stdin, maxSecretSize+1))
+	if err != nil {
+		return fail(e, err)
+	}
+	if len(value) > maxSecretSize {
+		return fail(e, fmt.Errorf("secret %s is longer than %d bytes", name, maxSecretSize))
+	}
+	secret := strings.TrimRight(string(value), "\r\n")
+	if secret == "" {
+		return fail(e, fmt.Errorf("no value for secret %s on standard input", name))
+	}
+	if err := v.SetSecret(name, secret); err != nil {
+		return fail(e, err)
+	}
+	fmt.Fprintf(e.stdout, "stored secret %s in %s\n", name, v.Path())
+	return exitOK
+}
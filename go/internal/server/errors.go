@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/service"
+	"MyUSCISgo/pkg/validation"
+	"MyUSCISgo/pkg/watchlist"
+)
+
+// Error codes reported in envelopes
+const (
+	codeInvalidRequest   = "invalid_request"
+	codeValidation       = "validation_failed"
+	codeInvalidReceipt   = "invalid_receipt"
+	codeInvalidToken     = "invalid_token"
+	codeNotFound         = "not_found"
+	codeConflict         = "conflict"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeTooLarge         = "payload_too_large"
+	codeUnsupportedMedia = "unsupported_media_type"
+	codeRateLimited      = "rate_limited"
+	codeProcessingFailed = "processing_failed"
+	codeUpstreamFailed   = "upstream_failed"
+	codeTimeout          = "timeout"
+	codeCancelled        = "cancelled"
+	codeNotConfigured    = "not_configured"
+	codeInternal         = "internal"
+)
+
+// apiError is an error reported to the client with a status and code
+type apiError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+	// RequestID is filled in when the envelope is written
+	RequestID string `json:"requestId,omitempty"`
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+// errorf creates an apiError
+func errorf(status int, code, format string, args ...any) *apiError {
+	return &apiError{Status: status, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// invalidField reports an invalid request field
+func invalidField(field, message string) *apiError {
+	return &apiError{Status: http.StatusUnprocessableEntity, Code: codeValidation, Message: message, Field: field}
+}
+
+func errTooLarge(limit int64) *apiError {
+	return errorf(http.StatusRequestEntityTooLarge, codeTooLarge, "request body exceeds %d bytes", limit)
+}
+
+// toAPIError maps an error from the packages the server is built on to
+// the status and code it is reported with. Unrecognised errors become
+// internal errors without their message, which may carry details the
+// client should not see.
+func toAPIError(err error) (*apiError, bool) {
+	var (
+		apiErr   *apiError
+		parseErr *receipt.ParseError
+		fieldErr validation.ValidationError
+		limited  *service.RateLimitError
+	)
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr, true
+	case errors.As(err, &limited):
+		return errorf(http.StatusTooManyRequests, codeRateLimited, "%v", limited), true
+	case errors.As(err, &parseErr):
+		return &apiError{Status: http.StatusUnprocessableEntity, Code: codeInvalidReceipt, Message: parseErr.Error(), Field: "receiptNumber"}, true
+	case errors.As(err, &fieldErr):
+		return invalidField(fieldErr.Field, fieldErr.Message), true
+	case errors.Is(err, watchlist.ErrNotFound):
+		return errorf(http.StatusNotFound, codeNotFound, "%v", err), true
+	case errors.Is(err, watchlist.ErrAlreadyWatched):
+		return errorf(http.StatusConflict, codeConflict, "%v", err), true
+	case errors.Is(err, service.ErrInvalidToken):
+		return errorf(http.StatusUnauthorized, codeInvalidToken, "%v", service.ErrInvalidToken), true
+	case errors.Is(err, service.ErrNotConfigured):
+		return errorf(http.StatusServiceUnavailable, codeNotConfigured, "%v", service.ErrNotConfigured), true
+	case errors.Is(err, service.ErrProcessingFailed):
+		return errorf(http.StatusBadGateway, codeProcessingFailed, "%v", err), true
+	case errors.Is(err, service.ErrLookupFailed):
+		return errorf(http.StatusBadGateway, codeUpstreamFailed, "%v", service.ErrLookupFailed), true
+	case errors.Is(err, service.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return errorf(http.StatusGatewayTimeout, codeTimeout, "request timed out"), true
+	case errors.Is(err, context.Canceled):
+		return errorf(http.StatusServiceUnavailable, codeCancelled, "request cancelled"), true
+	}
+	return errorf(http.StatusInternalServerError, codeInternal, "internal error"), false
+}
+
+// writeError writes err as an error envelope
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr, known := toAPIError(err)
+	if !known {
+		s.logger.Error("Unhandled error in HTTP handler", err, map[string]interface{}{
+			"path": r.URL.Path,
+		})
+	}
+	var limited *service.RateLimitError
+	if errors.As(err, &limited) {
+		w.Header().Set("Retry-After", fmt.Sprint(int(limited.RetryAfter.Seconds())))
+	}
+	body := *apiErr
+	body.RequestID, _ = r.Context().Value(requestIDKey{}).(string)
+	s.writeJSON(w, apiErr.Status, map[string]*apiError{"error": &body})
+}
+
+// writeJSON writes v as the response body
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		s.logger.Error("Failed to encode response", err)
+		status = http.StatusInternalServerError
+		data = []byte(`{"error":{"code":"internal","message":"failed to encode response"}}`)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
+
+// decodeJSON reads a request body holding a single JSON object. Unknown
+// fields are rejected so that misspelled options do not pass silently.
+func decodeJSON(r *http.Request, v any) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, err := mime.ParseMediaType(ct); err != nil || mt != "application/json" {
+			return errorf(http.StatusUnsupportedMediaType, codeUnsupportedMedia, "request body must be application/json")
+		}
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return errorf(http.StatusBadRequest, codeInvalidRequest, "request body is empty")
+		}
+		return bodyError(err)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		if err != nil {
+			return bodyError(err)
+		}
+		return errorf(http.StatusBadRequest, codeInvalidRequest, "request body must hold a single JSON object")
+	}
+	return nil
+}
+
+// bodyError reports a request body that could not be decoded
+func bodyError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return errTooLarge(tooLarge.Limit)
+	}
+	return errorf(http.StatusBadRequest, codeInvalidRequest, "invalid JSON body: %v", err)
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/service"
+	"MyUSCISgo/pkg/types"
+	"MyUSCISgo/pkg/watchlist"
+)
+
+// healthReport is the body of GET /v1/health
+type healthReport struct {
+	*service.Health
+	UptimeSeconds int64 `json:"uptimeSeconds"`
+}
+
+// health reports whether the server can reach its store. It is not rate
+// limited so that load balancers can poll it.
+func (s *Server) health(w http.ResponseWriter, r *http.Request) error {
+	report := healthReport{
+		Health:        s.svc.Health(r.Context()),
+		UptimeSeconds: int64(s.clock.Now().Sub(s.started).Seconds()),
+	}
+	status := http.StatusOK
+	if !report.Healthy() {
+		status = http.StatusServiceUnavailable
+	}
+	s.writeJSON(w, status, report)
+	return nil
+}
+
+// processCredentials validates credentials and runs them through the
+// processor, waiting for the job to finish
+func (s *Server) processCredentials(w http.ResponseWriter, r *http.Request) error {
+	var creds types.Credentials
+	if err := decodeJSON(r, &creds); err != nil {
+		return err
+	}
+	result, err := s.svc.ProcessCredentials(r.Context(), &creds)
+	if err != nil {
+		return err
+	}
+	s.writeJSON(w, http.StatusOK, result)
+	return nil
+}
+
+// certifyToken checks a certification token against a case and reports
+// the case's current status
+func (s *Server) certifyToken(w http.ResponseWriter, r *http.Request) error {
+	var req service.CertifyRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+	cert, err := s.svc.Certify(r.Context(), req)
+	var parseErr *receipt.ParseError
+	if errors.As(err, &parseErr) {
+		return &apiError{Status: http.StatusUnprocessableEntity, Code: codeInvalidReceipt, Message: parseErr.Error(), Field: "caseNumber"}
+	}
+	if err != nil {
+		return err
+	}
+	s.writeJSON(w, http.StatusOK, cert)
+	return nil
+}
+
+// caseStatus looks up the current status of a case
+func (s *Server) caseStatus(w http.ResponseWriter, r *http.Request) error {
+	report, err := s.svc.CaseStatus(r.Context(), r.PathValue("receiptNumber"), r.URL.Query().Get("environment"))
+	if err != nil {
+		return err
+	}
+	s.writeJSON(w, http.StatusOK, report)
+	return nil
+}
+
+// caseList is the body of GET /v1/cases
+type caseList struct {
+	Cases []*watchlist.Entry `json:"cases"`
+}
+
+// listCases lists watched cases, filtered by the query parameters owner,
+// label, form, stage, status, actionRequired and q
+func (s *Server) listCases(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	filter := watchlist.Filter{
+		Owner:  q.Get("owner"),
+		Label:  q.Get("label"),
+		Form:   q.Get("form"),
+		Stage:  types.CaseStage(q.Get("stage")),
+		Status: types.CaseStatusCode(q.Get("status")),
+		Query:  q.Get("q"),
+	}
+	if v := q.Get("actionRequired"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return invalidField("actionRequired", "actionRequired must be true or false")
+		}
+		filter.ActionRequired = b
+	}
+
+	entries, err := s.svc.Watchlist().List(r.Context(), filter)
+	if err != nil {
+		return err
+	}
+	s.writeJSON(w, http.StatusOK, caseList{Cases: entries})
+	return nil
+}
+
+// addCase starts watching a case
+func (s *Server) addCase(w http.ResponseWriter, r *http.Request) error {
+	var req watchlist.AddRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+	entry, err := s.svc.Watchlist().Add(r.Context(), req)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", "/v1/cases/"+entry.ReceiptNumber)
+	s.writeJSON(w, http.StatusCreated, entry)
+	return nil
+}
+
+// getCase returns a watched case
+func (s *Server) getCase(w http.ResponseWriter, r *http.Request) error {
+	entry, err := s.svc.Watchlist().Get(r.Context(), r.PathValue("receiptNumber"))
+	if err != nil {
+		return err
+	}
+	s.writeJSON(w, http.StatusOK, entry)
+	return nil
+}
+
+// editCase changes the label, notes or owner of a watched case
+func (s *Server) editCase(w http.ResponseWriter, r *http.Request) error {
+	var u watchlist.Update
+	if err := decodeJSON(r, &u); err != nil {
+		return err
+	}
+	entry, err := s.svc.Watchlist().Edit(r.Context(), r.PathValue("receiptNumber"), u)
+	if err != nil {
+		return err
+	}
+	s.writeJSON(w, http.StatusOK, entry)
+	return nil
+}
+
+// removeCase stops watching a case
+func (s *Server) removeCase(w http.ResponseWriter, r *http.Request) error {
+	if err := s.svc.Watchlist().Remove(r.Context(), r.PathValue("receiptNumber")); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
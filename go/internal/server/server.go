@@ -0,0 +1,244 @@
+// Package server exposes credential processing, token certification and
+// the case watchlist as a versioned JSON REST API.
+//
+// Every error is reported as an envelope of the form
+//
+//	{"error": {"code": "validation_failed", "message": "...", "field": "clientId", "requestId": "..."}}
+//
+// with a matching HTTP status, so clients can branch on the code without
+// parsing messages.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/logging"
+	"MyUSCISgo/pkg/service"
+)
+
+const (
+	// Version is the API version reported by the health endpoint
+	Version = service.Version
+	// DefaultMaxBodySize caps request bodies
+	DefaultMaxBodySize = 64 << 10
+
+	// RequestIDHeader carries the request ID in both directions
+	RequestIDHeader = "X-Request-ID"
+	maxRequestIDLen = 64
+)
+
+// Server serves the REST API over a service. It implements http.Handler.
+type Server struct {
+	svc     *service.Service
+	logger  *logging.Logger
+	clock   clock.Clock
+	maxBody int64
+	started time.Time
+	mux     *http.ServeMux
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithLogger sets the logger
+func WithLogger(logger *logging.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithClock sets the clock used for request timing and uptime
+func WithClock(c clock.Clock) Option {
+	return func(s *Server) {
+		s.clock = c
+	}
+}
+
+// WithMaxBodySize caps request bodies at n bytes
+func WithMaxBodySize(n int64) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.maxBody = n
+		}
+	}
+}
+
+// New creates a server for svc. Processing, certification, rate limits,
+// timeouts and storage are configured on the service.
+func New(svc *service.Service, opts ...Option) *Server {
+	s := &Server{
+		svc:     svc,
+		logger:  logging.NewLogger(logging.LogLevelInfo),
+		clock:   clock.Real(),
+		maxBody: DefaultMaxBodySize,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.started = s.clock.Now()
+	s.mux = s.routes()
+	return s
+}
+
+// routes registers the API. Methods are dispatched by the server rather
+// than the mux so that unsupported methods get an error envelope.
+func (s *Server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	s.handle(mux, "/v1/health", methods{http.MethodGet: s.health})
+	s.handle(mux, "/v1/credentials/process", methods{http.MethodPost: s.processCredentials})
+	s.handle(mux, "/v1/tokens/certify", methods{http.MethodPost: s.certifyToken})
+	s.handle(mux, "/v1/cases", methods{
+		http.MethodGet:  s.listCases,
+		http.MethodPost: s.addCase,
+	})
+	s.handle(mux, "/v1/cases/{receiptNumber}", methods{
+		http.MethodGet:    s.getCase,
+		http.MethodPatch:  s.editCase,
+		http.MethodDelete: s.removeCase,
+	})
+	s.handle(mux, "/v1/cases/{receiptNumber}/status", methods{http.MethodGet: s.caseStatus})
+	mux.Handle("/", s.endpoint(func(w http.ResponseWriter, r *http.Request) error {
+		return errorf(http.StatusNotFound, codeNotFound, "no endpoint at %s", r.URL.Path)
+	}))
+	return mux
+}
+
+// handlerFunc serves a request, returning an error to be reported in an
+// envelope
+type handlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// methods maps HTTP methods to the handlers of one path
+type methods map[string]handlerFunc
+
+func (s *Server) handle(mux *http.ServeMux, pattern string, m methods) {
+	allowed := make([]string, 0, len(m)+1)
+	for method := range m {
+		allowed = append(allowed, method)
+	}
+	if _, ok := m[http.MethodGet]; ok {
+		allowed = append(allowed, http.MethodHead)
+	}
+	sort.Strings(allowed)
+	allow := strings.Join(allowed, ", ")
+
+	mux.Handle(pattern, s.endpoint(func(w http.ResponseWriter, r *http.Request) error {
+		method := r.Method
+		if method == http.MethodHead {
+			method = http.MethodGet
+		}
+		fn, ok := m[method]
+		if !ok {
+			w.Header().Set("Allow", allow)
+			return errorf(http.StatusMethodNotAllowed, codeMethodNotAllowed, "%s does not support %s", r.URL.Path, r.Method)
+		}
+		return fn(w, r)
+	}))
+}
+
+// endpoint adapts a handlerFunc, writing its error as an envelope
+func (s *Server) endpoint(fn handlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := fn(w, r); err != nil {
+			s.writeError(w, r, err)
+		}
+	})
+}
+
+// ServeHTTP assigns a request ID, limits the body, recovers from panics and
+// logs the request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := s.clock.Now()
+	id := requestID(r)
+	w.Header().Set(RequestIDHeader, id)
+	r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+	defer func() {
+		if v := recover(); v != nil {
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			s.logger.Error("Panic in HTTP handler", fmt.Errorf("%v", v), map[string]interface{}{
+				"requestId": id,
+				"path":      r.URL.Path,
+				"stack":     string(debug.Stack()),
+			})
+			if !rec.wroteHeader {
+				s.writeError(rec, r, errorf(http.StatusInternalServerError, codeInternal, "internal error"))
+			}
+		}
+		s.logger.Info("HTTP request", map[string]interface{}{
+			"requestId":  id,
+			"method":     r.Method,
+			"path":       r.URL.Path,
+			"status":     rec.status,
+			"bytes":      rec.written,
+			"durationMs": s.clock.Now().Sub(start).Milliseconds(),
+		})
+	}()
+
+	if r.ContentLength > s.maxBody {
+		s.writeError(rec, r, errTooLarge(s.maxBody))
+		return
+	}
+	r.Body = http.MaxBytesReader(rec, r.Body, s.maxBody)
+	s.mux.ServeHTTP(rec, r)
+}
+
+// requestIDKey is the context key of the request ID
+type requestIDKey struct{}
+
+// requestID returns the caller's request ID if it is usable, or a new one
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); id != "" && len(id) <= maxRequestIDLen && printable(id) {
+		return id
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func printable(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] <= ' ' || s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// statusRecorder remembers the status and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	written     int64
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = status, true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.written += int64(n)
+	return n, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,298 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"MyUSCISgo/pkg/logging"
+	"MyUSCISgo/pkg/ratelimit"
+	"MyUSCISgo/pkg/service"
+	"MyUSCISgo/pkg/store"
+)
+
+const (
+	testKey     = "test-signing-key"
+	testReceipt = "EAC2190050123"
+)
+
+func quietLogger() *logging.Logger {
+	return logging.NewLogger(logging.LogLevelFatal)
+}
+
+func newTestService(opts ...service.Option) *service.Service {
+	base := []service.Option{service.WithLogger(quietLogger()), service.WithSigningKey(testKey)}
+	return service.New(append(base, opts...)...)
+}
+
+func newTestServer(t *testing.T, opts ...service.Option) *Server {
+	t.Helper()
+	return New(newTestService(opts...), WithLogger(quietLogger()))
+}
+
+// do sends a request to s and decodes the JSON response into a map
+func do(t *testing.T, s http.Handler, method, target, body string, header ...string) (*http.Response, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	resp := rec.Result()
+	var decoded map[string]any
+	if rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), &decoded); err != nil {
+			t.Fatalf("%s %s: response is not JSON: %v\n%s", method, target, err, rec.Body)
+		}
+	}
+	return resp, decoded
+}
+
+// errorCode returns the code of an error envelope
+func errorCode(body map[string]any) string {
+	envelope, _ := body["error"].(map[string]any)
+	code, _ := envelope["code"].(string)
+	return code
+}
+
+// signToken creates an HS256 certification token
+func signToken(t *testing.T, key string, claims service.Claims) string {
+	t.Helper()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func validClaims(caseNumber string) service.Claims {
+	now := time.Now()
+	return service.Claims{
+		Issuer:     service.TokenIssuer,
+		Audience:   service.TokenAudience,
+		Subject:    "applicant-1",
+		IssuedAt:   now.Add(-time.Minute).Unix(),
+		ExpiresAt:  now.Add(time.Hour).Unix(),
+		CaseNumber: caseNumber,
+	}
+}
+
+func TestHealth(t *testing.T) {
+	s := newTestServer(t)
+	resp, body := do(t, s, http.MethodGet, "/v1/health", "", RequestIDHeader, "trace-1")
+	if resp.StatusCode != http.StatusOK || body["status"] != "healthy" || body["version"] != Version {
+		t.Errorf("GET /v1/health = %d %v", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get(RequestIDHeader); got != "trace-1" {
+		t.Errorf("request ID = %q, want the caller's", got)
+	}
+
+	_, body = do(t, New(service.New(service.WithLogger(quietLogger())), WithLogger(quietLogger())), http.MethodGet, "/v1/health", "")
+	if checks, _ := body["checks"].(map[string]any); checks["certification"] != "not configured" {
+		t.Errorf("health without a signing key reports certification %v", checks["certification"])
+	}
+
+	records, err := store.Open(context.Background(), store.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	records.Close()
+	resp, body = do(t, newTestServer(t, service.WithStore(records)), http.MethodGet, "/v1/health", "")
+	if resp.StatusCode != http.StatusServiceUnavailable || body["status"] != "unhealthy" {
+		t.Errorf("health with a closed store = %d %v", resp.StatusCode, body)
+	}
+}
+
+func TestErrorEnvelopes(t *testing.T) {
+	s := New(newTestService(), WithLogger(quietLogger()), WithMaxBodySize(64))
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		body        string
+		contentType string
+		status      int
+		code        string
+	}{
+		{"unknown path", http.MethodGet, "/v1/nothing", "", "", http.StatusNotFound, codeNotFound},
+		{"wrong method", http.MethodDelete, "/v1/health", "", "", http.StatusMethodNotAllowed, codeMethodNotAllowed},
+		{"empty body", http.MethodPost, "/v1/credentials/process", "", "", http.StatusBadRequest, codeInvalidRequest},
+		{"malformed JSON", http.MethodPost, "/v1/credentials/process", `{"clientId":`, "", http.StatusBadRequest, codeInvalidRequest},
+		{"unknown field", http.MethodPost, "/v1/credentials/process", `{"clientKey":"x"}`, "", http.StatusBadRequest, codeInvalidRequest},
+		{"trailing data", http.MethodPost, "/v1/cases", `{"receiptNumber":"EAC2190050123"} {}`, "", http.StatusBadRequest, codeInvalidRequest},
+		{"wrong content type", http.MethodPost, "/v1/cases", `{}`, "text/plain", http.StatusUnsupportedMediaType, codeUnsupportedMedia},
+		{"too large", http.MethodPost, "/v1/cases", `{"receiptNumber":"EAC2190050123","notes":"` + strings.Repeat("x", 100) + `"}`, "", http.StatusRequestEntityTooLarge, codeTooLarge},
+		{"invalid credentials", http.MethodPost, "/v1/credentials/process", `{"clientId":"","clientSecret":"","environment":"moon"}`, "", http.StatusUnprocessableEntity, codeValidation},
+		{"invalid receipt", http.MethodGet, "/v1/cases/XYZ123/status", "", "", http.StatusUnprocessableEntity, codeInvalidReceipt},
+		{"invalid environment", http.MethodGet, "/v1/cases/EAC2190050123/status?environment=moon", "", "", http.StatusUnprocessableEntity, codeValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header []string
+			if tt.contentType != "" {
+				header = []string{"Content-Type", tt.contentType}
+			}
+			resp, body := do(t, s, tt.method, tt.target, tt.body, header...)
+			if resp.StatusCode != tt.status || errorCode(body) != tt.code {
+				t.Errorf("%s %s = %d %v, want %d %s", tt.method, tt.target, resp.StatusCode, body, tt.status, tt.code)
+			}
+			envelope, _ := body["error"].(map[string]any)
+			if envelope["message"] == "" || envelope["requestId"] != resp.Header.Get(RequestIDHeader) {
+				t.Errorf("envelope = %v, want a message and the request ID", envelope)
+			}
+		})
+	}
+
+	resp, _ := do(t, s, http.MethodPut, "/v1/cases/EAC2190050123", "")
+	if got := resp.Header.Get("Allow"); got != "DELETE, GET, HEAD, PATCH" {
+		t.Errorf("Allow = %q", got)
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	s := newTestServer(t)
+	s.mux.HandleFunc("/panic", func(http.ResponseWriter, *http.Request) { panic("boom") })
+	resp, body := do(t, s, http.MethodGet, "/panic", "")
+	if resp.StatusCode != http.StatusInternalServerError || errorCode(body) != codeInternal {
+		t.Errorf("panicking handler = %d %v", resp.StatusCode, body)
+	}
+}
+
+func TestProcessCredentials(t *testing.T) {
+	s := newTestServer(t, service.WithRateLimiter(ratelimit.NewRateLimiter(1, time.Minute), time.Minute))
+	creds := `{"clientId":"test-client-123","clientSecret":"Str0ngRandomValue!","environment":"development"}`
+	resp, body := do(t, s, http.MethodPost, "/v1/credentials/process", creds)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /v1/credentials/process = %d %v", resp.StatusCode, body)
+	}
+	result, _ := body["result"].(map[string]any)
+	if body["jobId"] == "" || result["baseURL"] == nil {
+		t.Errorf("result = %v", body)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		t.Errorf("Content-Type = %q", resp.Header.Get("Content-Type"))
+	}
+
+	resp, body = do(t, s, http.MethodPost, "/v1/credentials/process", creds)
+	if resp.StatusCode != http.StatusTooManyRequests || errorCode(body) != codeRateLimited || resp.Header.Get("Retry-After") != "60" {
+		t.Errorf("second request = %d %v, Retry-After %q", resp.StatusCode, body, resp.Header.Get("Retry-After"))
+	}
+}
+
+func TestCertifyToken(t *testing.T) {
+	s := newTestServer(t)
+	certify := func(token, caseNumber string) (*http.Response, map[string]any) {
+		body, _ := json.Marshal(service.CertifyRequest{Token: token, CaseNumber: caseNumber, Environment: "development"})
+		return do(t, s, http.MethodPost, "/v1/tokens/certify", string(body))
+	}
+
+	resp, body := certify(signToken(t, testKey, validClaims(testReceipt)), "eac-2190050123")
+	if resp.StatusCode != http.StatusOK || body["isValid"] != true || body["verificationId"] == "" {
+		t.Fatalf("certify = %d %v", resp.StatusCode, body)
+	}
+	if record, _ := body["case"].(map[string]any); record["receiptNumber"] != testReceipt {
+		t.Errorf("case = %v", body["case"])
+	}
+
+	expired := validClaims("LIN2190050123")
+	expired.ExpiresAt = time.Now().Add(-time.Hour).Unix()
+	tests := []struct {
+		name       string
+		token      string
+		caseNumber string
+	}{
+		{"wrong key", signToken(t, "other-key", validClaims("WAC2190050123")), "WAC2190050123"},
+		{"other case", signToken(t, testKey, validClaims("SRC2190050123")), "MSC2190050123"},
+		{"expired", signToken(t, testKey, expired), "LIN2190050123"},
+		{"malformed", "not.a.jwt.at.all", "IOE0912345678"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := certify(tt.token, tt.caseNumber)
+			if resp.StatusCode != http.StatusUnauthorized || errorCode(body) != codeInvalidToken {
+				t.Errorf("certify = %d %v, want 401 %s", resp.StatusCode, body, codeInvalidToken)
+			}
+		})
+	}
+
+	claims := validClaims("NBC2190050123")
+	if err := s.svc.RevokeToken(context.Background(), claims.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if resp, body := certify(signToken(t, testKey, claims), claims.CaseNumber); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("revoked token = %d %v", resp.StatusCode, body)
+	}
+
+	unconfigured := New(service.New(service.WithLogger(quietLogger())), WithLogger(quietLogger()))
+	req, _ := json.Marshal(service.CertifyRequest{Token: "a.b.c", CaseNumber: testReceipt})
+	if resp, body := do(t, unconfigured, http.MethodPost, "/v1/tokens/certify", string(req)); resp.StatusCode != http.StatusServiceUnavailable || errorCode(body) != codeNotConfigured {
+		t.Errorf("certify without a key = %d %v", resp.StatusCode, body)
+	}
+}
+
+func TestCases(t *testing.T) {
+	s := newTestServer(t)
+
+	resp, body := do(t, s, http.MethodPost, "/v1/cases", `{"receiptNumber":"eac2190050123","label":"Parents"}`)
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("Location") != "/v1/cases/"+testReceipt {
+		t.Fatalf("POST /v1/cases = %d %v, Location %q", resp.StatusCode, body, resp.Header.Get("Location"))
+	}
+	if resp, body := do(t, s, http.MethodPost, "/v1/cases", `{"receiptNumber":"EAC2190050123"}`); resp.StatusCode != http.StatusConflict {
+		t.Errorf("adding twice = %d %v", resp.StatusCode, body)
+	}
+	if resp, body := do(t, s, http.MethodPost, "/v1/cases", `{"receiptNumber":"EAC2190050124","label":"a\u0000b"}`); resp.StatusCode != http.StatusUnprocessableEntity || errorCode(body) != codeValidation {
+		t.Errorf("control characters in label = %d %v", resp.StatusCode, body)
+	}
+
+	resp, body = do(t, s, http.MethodPatch, "/v1/cases/"+testReceipt, `{"owner":"sam"}`)
+	if resp.StatusCode != http.StatusOK || body["owner"] != "sam" || body["label"] != "Parents" {
+		t.Errorf("PATCH = %d %v", resp.StatusCode, body)
+	}
+
+	resp, body = do(t, s, http.MethodGet, "/v1/cases/"+testReceipt+"/status", "")
+	if resp.StatusCode != http.StatusOK || body["watched"] != true {
+		t.Fatalf("GET status = %d %v", resp.StatusCode, body)
+	}
+	if _, ok := body["deadlines"].([]any); !ok {
+		t.Errorf("deadlines = %v, want a list", body["deadlines"])
+	}
+	if _, body := do(t, s, http.MethodGet, "/v1/cases/"+testReceipt, ""); body["lastStatus"] == nil {
+		t.Errorf("status lookup was not recorded on the watched case: %v", body)
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  int
+	}{{"", 1}, {"?owner=sam", 1}, {"?owner=alex", 0}, {"?q=parents", 1}} {
+		_, body := do(t, s, http.MethodGet, "/v1/cases"+tt.query, "")
+		if cases, _ := body["cases"].([]any); len(cases) != tt.want {
+			t.Errorf("GET /v1/cases%s = %d cases, want %d", tt.query, len(cases), tt.want)
+		}
+	}
+	if resp, body := do(t, s, http.MethodGet, "/v1/cases?actionRequired=maybe", ""); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("invalid filter = %d %v", resp.StatusCode, body)
+	}
+
+	if resp, _ := do(t, s, http.MethodDelete, "/v1/cases/"+testReceipt, ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE = %d", resp.StatusCode)
+	}
+	if resp, body := do(t, s, http.MethodGet, "/v1/cases/"+testReceipt, ""); resp.StatusCode != http.StatusNotFound || errorCode(body) != codeNotFound {
+		t.Errorf("GET after DELETE = %d %v", resp.StatusCode, body)
+	}
+}
@@ -3,183 +3,198 @@
 package wasm
 
 import (
+	"bytes"
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"regexp"
 	"runtime/debug"
 	"strings"
-	"sync"
 	"syscall/js"
 	"time"
 
+	"MyUSCISgo/pkg/audit"
+	"MyUSCISgo/pkg/bulk"
+	"MyUSCISgo/pkg/casegen"
+	"MyUSCISgo/pkg/changes"
+	"MyUSCISgo/pkg/estimate"
+	"MyUSCISgo/pkg/forms"
+	"MyUSCISgo/pkg/ical"
 	"MyUSCISgo/pkg/logging"
-	"MyUSCISgo/pkg/processing"
+	"MyUSCISgo/pkg/notify"
 	"MyUSCISgo/pkg/ratelimit"
-	"MyUSCISgo/pkg/security"
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/scan"
+	"MyUSCISgo/pkg/scheduler"
+	"MyUSCISgo/pkg/service"
+	"MyUSCISgo/pkg/store"
 	"MyUSCISgo/pkg/types"
-	"MyUSCISgo/pkg/validation"
+	"MyUSCISgo/pkg/visabulletin"
+	"MyUSCISgo/pkg/watchlist"
+	"MyUSCISgo/pkg/webhook"
 )
 
 const (
-	// ProcessingTimeoutMsg is the error message for processing timeouts
-	ProcessingTimeoutMsg = "Processing timeout"
 	// PanicMsg is the error message for Go panics
 	PanicMsg = "Go panic: %v"
-	// JWT validation constants
-	JWTIssuer    = "uscis-api"
-	JWTAudience  = "uscis-client"
-	JWTAlgorithm = "HS256"
-	// Token validation rate limiting
-	TokenValidationRateLimit = 100 // requests per minute per IP
+	// JobRetention is how long finished jobs remain queryable
+	JobRetention = service.JobRetention
+	// DefaultDatabase is the IndexedDB database tracked cases and token
+	// revocations are saved in
+	DefaultDatabase = "myuscis"
 )
 
-// JWTClaims represents the standard JWT claims
-type JWTClaims struct {
-	Issuer     string `json:"iss"`
-	Subject    string `json:"sub"`
-	Audience   string `json:"aud"`
-	ExpiresAt  int64  `json:"exp"`
-	IssuedAt   int64  `json:"iat"`
-	CaseNumber string `json:"case_number"`
-}
-
-// TokenValidationConfig holds configuration for token validation
-type TokenValidationConfig struct {
-	SigningKey       string
-	Issuer           string
-	Audience         string
-	ClockSkew        time.Duration
-	EnableRevocation bool
+// Handler adapts the service to JavaScript: it decodes arguments, runs
+// the service flows off the event loop and encodes their results
+type Handler struct {
+	svc       *service.Service
+	logger    *logging.Logger
+	bulletins *visabulletin.History
+	scanCache *scan.MemoryCache
+	cache     *store.Cache
+	records   *store.Store
+	changeBus *changes.Bus
+	auditLog  *store.AuditLog
+	notifier  *notify.Dispatcher
+	outbox    *webhook.Outbox
 }
 
-// TokenStore represents a secure token storage interface
-type TokenStore interface {
-	IsRevoked(tokenID string) bool
-	IsValid(tokenID string) bool
-}
+// NewHandler creates a new WASM handler
+func NewHandler() *Handler {
+	var h *Handler
+	// records live in memory until OpenStore attaches IndexedDB
+	cache := store.NewCache(store.OnWriteError(func(err error) { h.storeWriteFailed(err) }))
+	records, err := store.Open(context.Background(), cache)
+	if err != nil {
+		// an unattached cache cannot fail to migrate
+		panic(err)
+	}
 
-// InMemoryTokenStore provides a simple in-memory token store
-type InMemoryTokenStore struct {
-	mu      sync.RWMutex
-	revoked map[string]time.Time
-	valid   map[string]time.Time
+	h = &Handler{
+		logger:    logging.NewLogger(logging.LogLevelInfo),
+		bulletins: visabulletin.NewHistory(),
+		scanCache: scan.NewMemoryCache(scan.DefaultCacheTTL, nil),
+		cache:     cache,
+		records:   records,
+		changeBus: changes.NewBus(),
+		auditLog:  records.Audit(),
+	}
+	h.svc = service.New(
+		service.WithLogger(h.logger),
+		service.WithStore(records),
+		service.WithChanges(h.changeBus),
+		service.WithEvents(h.deliverEvent),
+	)
+	h.notifier = notify.NewDispatcher(notify.WithLogger(h.logger))
+	h.notifier.Register(h.browserNotifier())
+	h.outbox = webhook.New(records.Webhooks(),
+		webhook.WithLogger(h.logger),
+		webhook.WithPolicy(webhook.Policy{RatePerMinute: NotificationRateLimit}),
+	)
+	h.changeBus.Subscribe(h.announceChange)
+	h.changeBus.Subscribe(changes.AuditSink(h.auditLog, func(e changes.Event, err error) {
+		h.logger.Error("Failed to record case change", err, map[string]interface{}{
+			"caseNumber": e.ReceiptNumber,
+			"type":       string(e.Type),
+		})
+	}))
+	h.changeBus.Subscribe(h.notifier.Subscriber())
+	return h
 }
 
-// NewInMemoryTokenStore creates a new in-memory token store
-func NewInMemoryTokenStore() *InMemoryTokenStore {
-	return &InMemoryTokenStore{
-		revoked: make(map[string]time.Time),
-		valid:   make(map[string]time.Time),
+// deliverEvent forwards a service event to JavaScript. Certifications go
+// to the realtime callback, everything else to the progress callback.
+func (h *Handler) deliverEvent(e service.Event) {
+	if e.Type == service.EventTokenCertified {
+		h.SendRealtimeUpdate(js.Null(), []js.Value{js.ValueOf(e.Type), js.ValueOf(e.Data)})
+		return
 	}
+	h.sendProgressUpdate(e.Type, e.Data)
 }
 
-// IsRevoked checks if a token is revoked
-func (s *InMemoryTokenStore) IsRevoked(tokenID string) bool {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	_, exists := s.revoked[tokenID]
-	return exists
-}
+// ProcessCredentialsAsync handles the async processing of credentials from JavaScript
+func (h *Handler) ProcessCredentialsAsync(this js.Value, args []js.Value) any {
+	defer h.recoverPanic("ProcessCredentialsAsync")
 
-// IsValid checks if a token is in the valid token list
-func (s *InMemoryTokenStore) IsValid(tokenID string) bool {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	_, exists := s.valid[tokenID]
-	return exists
-}
+	h.logger.Info("Received credentials processing request from JavaScript")
 
-// AddValidToken adds a token to the valid list
-func (s *InMemoryTokenStore) AddValidToken(tokenID string, expiresAt time.Time) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.valid[tokenID] = expiresAt
-}
+	if len(args) != 1 {
+		err := fmt.Errorf("invalid number of arguments: expected 1, got %d", len(args))
+		h.logger.Error("Invalid arguments", err)
+		return js.Global().Get("Promise").Call("reject", h.createErrorResponse(err.Error()))
+	}
 
-// generateSecureTokenHash creates a secure hash of the token for logging purposes
-func generateSecureTokenHash(token string) string {
-	if token == "" {
-		return ""
+	var creds types.Credentials
+	if err := json.Unmarshal([]byte(args[0].String()), &creds); err != nil {
+		h.logger.Error("Failed to parse credentials JSON", err)
+		return js.Global().Get("Promise").Call("reject",
+			h.createErrorResponse(fmt.Sprintf("Failed to parse credentials: %v", err)))
 	}
-	hash := sha256.Sum256([]byte(token))
-	return fmt.Sprintf("%x", hash)
-}
 
-// RevokeToken marks a token as revoked
-func (s *InMemoryTokenStore) RevokeToken(tokenID string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.revoked[tokenID] = time.Now()
-	delete(s.valid, tokenID)
+	return h.createPromise(func(resolve, reject js.Value) {
+		// Processing waits on timers, so it must not block the event loop
+		go func() {
+			result, err := h.svc.ProcessCredentials(context.Background(), &creds)
+			if err != nil {
+				reject.Invoke(h.createErrorResponse(err.Error()))
+				return
+			}
+			resolve.Invoke(h.createJobSuccessResponse(result.JobID, result.Result))
+		}()
+	})
 }
 
-// loadSecureSigningKey loads the JWT signing key from secure configuration
-func loadSecureSigningKey() string {
-	// Load from environment variable first
-	if key := getEnvVar("JWT_SIGNING_KEY"); key != "" {
-		return key
-	}
+// CertifyTokenAsync handles token certification requests from JavaScript
+func (h *Handler) CertifyTokenAsync(this js.Value, args []js.Value) any {
+	defer h.recoverPanic("CertifyTokenAsync")
 
-	// Fallback to default key for development (CHANGE THIS IN PRODUCTION)
-	// This should only be used in development environments
-	return "default-development-signing-key-change-in-production"
-}
+	h.logger.Info("Received token certification request from JavaScript")
 
-// getEnvVar gets an environment variable (WASM-compatible)
-func getEnvVar(key string) string {
-	// In WASM environment, we can't directly access environment variables
-	// This would need to be passed from JavaScript or use a different mechanism
-	// For now, return empty string to trigger fallback
-	return ""
-}
+	if len(args) != 1 {
+		err := fmt.Errorf("invalid number of arguments: expected 1, got %d", len(args))
+		h.logger.Error("Invalid arguments", err)
+		return js.Global().Get("Promise").Call("reject", h.createErrorResponse(err.Error()))
+	}
 
-// Package-level compiled regex for case number validation
-var caseNumberRegex = regexp.MustCompile(`^[A-Z]{3}\d{10}$`)
+	var req service.CertifyRequest
+	if err := json.Unmarshal([]byte(args[0].String()), &req); err != nil {
+		h.logger.Error("Failed to parse token data JSON", err)
+		return js.Global().Get("Promise").Call("reject",
+			h.createErrorResponse(fmt.Sprintf("Failed to parse token data: %v", err)))
+	}
 
-// Handler handles WASM function calls from JavaScript
-type Handler struct {
-	processor         *processing.Processor
-	logger            *logging.Logger
-	rateLimiter       *ratelimit.RateLimiter
-	tokenStore        TokenStore
-	tokenConfig       *TokenValidationConfig
-	validationLimiter *ratelimit.RateLimiter
+	return h.createPromise(func(resolve, reject js.Value) {
+		go func() {
+			cert, err := h.svc.Certify(context.Background(), req)
+			if err != nil {
+				reject.Invoke(h.createErrorResponse(err.Error()))
+				return
+			}
+			jsonData, err := json.Marshal(cert)
+			if err != nil {
+				h.logger.Error("Failed to marshal certification result", err)
+				reject.Invoke(h.createErrorResponse("Failed to create certification result"))
+				return
+			}
+			resolve.Invoke(js.ValueOf(string(jsonData)))
+		}()
+	})
 }
 
-// NewHandler creates a new WASM handler
-func NewHandler() *Handler {
-	return &Handler{
-		processor:   processing.NewProcessor(),
-		logger:      logging.NewLogger(logging.LogLevelInfo),
-		rateLimiter: ratelimit.NewRateLimiter(10, time.Minute), // 10 requests per minute
-		tokenStore:  NewInMemoryTokenStore(),
-		tokenConfig: &TokenValidationConfig{
-			SigningKey:       loadSecureSigningKey(),
-			Issuer:           JWTIssuer,
-			Audience:         JWTAudience,
-			ClockSkew:        5 * time.Minute,
-			EnableRevocation: true,
-		},
-		validationLimiter: ratelimit.NewRateLimiter(TokenValidationRateLimit, time.Minute),
-	}
+// RevokeToken revokes a token by ID
+func (h *Handler) RevokeToken(tokenID string) error {
+	return h.svc.RevokeToken(context.Background(), tokenID)
 }
 
-// ProcessCredentialsAsync handles the async processing of credentials from JavaScript
-func (h *Handler) ProcessCredentialsAsync(this js.Value, args []js.Value) any {
-	defer func() {
-		if r := recover(); r != nil {
-			h.logger.Error("Panic in ProcessCredentialsAsync", fmt.Errorf("%v", r), map[string]interface{}{
-				"stack": string(debug.Stack()),
-			})
-			js.Global().Get("console").Call("error", fmt.Sprintf(PanicMsg, r))
-		}
-	}()
+// AddValidToken registers an issued token until it expires
+func (h *Handler) AddValidToken(tokenID string, expiresAt time.Time) error {
+	return h.svc.RegisterToken(context.Background(), tokenID, expiresAt)
+}
 
-	h.logger.Info("Received credentials processing request from JavaScript")
+// CaseStatusAsync handles case status lookups from JavaScript
+func (h *Handler) CaseStatusAsync(this js.Value, args []js.Value) any {
+	defer h.recoverPanic("CaseStatusAsync")
 
 	if len(args) != 1 {
 		err := fmt.Errorf("invalid number of arguments: expected 1, got %d", len(args))
@@ -187,95 +202,95 @@ func (h *Handler) ProcessCredentialsAsync(this js.Value, args []js.Value) any {
 		return js.Global().Get("Promise").Call("reject", h.createErrorResponse(err.Error()))
 	}
 
-	// Parse JSON input
-	credJSON := args[0].String()
-	h.logger.Debug("Parsing credentials JSON", map[string]interface{}{
-		"jsonLength": len(credJSON),
-	})
-
-	var creds types.Credentials
-	if err := json.Unmarshal([]byte(credJSON), &creds); err != nil {
-		h.logger.Error("Failed to parse credentials JSON", err)
+	var request struct {
+		CaseNumber  string `json:"caseNumber"`
+		Environment string `json:"environment"`
+	}
+	if err := json.Unmarshal([]byte(args[0].String()), &request); err != nil {
+		h.logger.Error("Failed to parse case status request JSON", err)
 		return js.Global().Get("Promise").Call("reject",
-			h.createErrorResponse(fmt.Sprintf("Failed to parse credentials: %v", err)))
+			h.createErrorResponse(fmt.Sprintf("Failed to parse case status request: %v", err)))
 	}
 
-	// Validate credentials
-	if err := validation.ValidateCredentials(&creds); err != nil {
-		h.logger.Error("Credential validation failed", err, logging.SanitizeLogData(map[string]interface{}{
-			"clientId":    creds.ClientID,
-			"environment": creds.Environment,
-		}))
-		return js.Global().Get("Promise").Call("reject", h.createErrorResponse(err.Error()))
+	return h.createPromise(func(resolve, reject js.Value) {
+		go func() {
+			report, err := h.svc.CaseStatus(context.Background(), request.CaseNumber, request.Environment)
+			if err != nil {
+				reject.Invoke(h.createErrorResponse(err.Error()))
+				return
+			}
+			jsonData, err := json.Marshal(struct {
+				Success bool `json:"success"`
+				*service.CaseReport
+			}{true, report})
+			if err != nil {
+				h.logger.Error("Failed to marshal case status", err)
+				reject.Invoke(h.createErrorResponse("Failed to create case status response"))
+				return
+			}
+			resolve.Invoke(js.ValueOf(string(jsonData)))
+		}()
+	})
+}
+
+// HealthCheck reports the service health as JSON
+func (h *Handler) HealthCheck(this js.Value, args []js.Value) any {
+	jsonData, err := json.Marshal(h.svc.Health(context.Background()))
+	if err != nil {
+		h.logger.Error("Failed to marshal health check response", err)
+		return js.ValueOf(`{"status":"error","message":"Failed to create health response"}`)
 	}
+	return js.ValueOf(string(jsonData))
+}
 
-	// Rate limiting check - use client identifier derived from validated credentials
-	rateLimitKey := fmt.Sprintf("%s:%s", creds.Environment, creds.ClientID)
-	if !h.rateLimiter.Allow(rateLimitKey) {
-		h.logger.Warn("Rate limit exceeded", map[string]interface{}{
-			"rateLimitKey": rateLimitKey,
-			"clientId":     creds.ClientID,
-			"environment":  creds.Environment,
+// recoverPanic logs a panic in a JavaScript entry point instead of
+// crashing the module
+func (h *Handler) recoverPanic(name string) {
+	if r := recover(); r != nil {
+		h.logger.Error("Panic in "+name, fmt.Errorf("%v", r), map[string]interface{}{
+			"stack": string(debug.Stack()),
 		})
-		return js.Global().Get("Promise").Call("reject", h.createErrorResponse("Rate limit exceeded. Please try again later."))
+		js.Global().Get("console").Call("error", fmt.Sprintf(PanicMsg, r))
 	}
+}
 
-	h.logger.Info("Credentials validated successfully", map[string]interface{}{
-		"clientId":    creds.ClientID,
-		"environment": creds.Environment,
-	})
+// GetJob returns a snapshot of a processing job to JavaScript
+func (h *Handler) GetJob(this js.Value, args []js.Value) any {
+	if len(args) != 1 {
+		err := fmt.Errorf("invalid number of arguments: expected 1, got %d", len(args))
+		h.logger.Error("Invalid arguments for job lookup", err)
+		return h.createErrorResponse(err.Error())
+	}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	job, err := h.svc.Processor().Jobs().Get(args[0].String())
+	if err != nil {
+		return h.createErrorResponse(err.Error())
+	}
 
-	// Send initial progress update
-	h.sendProgressUpdate("validation_started", map[string]interface{}{
-		"clientId":    creds.ClientID,
-		"environment": creds.Environment,
+	return h.createSuccessResponse(map[string]interface{}{
+		"job": job.Snapshot(),
 	})
+}
 
-	// Process asynchronously
-	resultCh, errCh := h.processor.ProcessCredentialsAsync(ctx, &creds)
+// CancelJob cancels a processing job on behalf of JavaScript
+func (h *Handler) CancelJob(this js.Value, args []js.Value) any {
+	if len(args) != 1 {
+		err := fmt.Errorf("invalid number of arguments: expected 1, got %d", len(args))
+		h.logger.Error("Invalid arguments for job cancellation", err)
+		return h.createErrorResponse(err.Error())
+	}
 
-	// Return a Promise
-	return h.createPromise(func(resolve, reject js.Value) {
-		select {
-		case result := <-resultCh:
-			h.sendProgressUpdate("processing_completed", map[string]interface{}{
-				"clientId":    creds.ClientID,
-				"environment": creds.Environment,
-				"success":     true,
-			})
-			h.logger.Info("Processing completed successfully", map[string]interface{}{
-				"clientId":    creds.ClientID,
-				"environment": creds.Environment,
-			})
-			resolve.Invoke(h.createSuccessResponse(result))
-		case err := <-errCh:
-			h.sendProgressUpdate("processing_failed", map[string]interface{}{
-				"clientId":    creds.ClientID,
-				"environment": creds.Environment,
-				"error":       err.Error(),
-			})
-			h.logger.Error("Processing failed", err, map[string]interface{}{
-				"clientId":    creds.ClientID,
-				"environment": creds.Environment,
-			})
-			reject.Invoke(h.createErrorResponse(err.Error()))
-		case <-ctx.Done():
-			err := ctx.Err()
-			h.sendProgressUpdate("processing_timeout", map[string]interface{}{
-				"clientId":    creds.ClientID,
-				"environment": creds.Environment,
-				"error":       ProcessingTimeoutMsg,
-			})
-			h.logger.Error(ProcessingTimeoutMsg, err, map[string]interface{}{
-				"clientId":    creds.ClientID,
-				"environment": creds.Environment,
-			})
-			reject.Invoke(h.createErrorResponse(ProcessingTimeoutMsg))
-		}
+	jobID := args[0].String()
+	if err := h.svc.Processor().Jobs().Cancel(jobID); err != nil {
+		return h.createErrorResponse(err.Error())
+	}
+
+	h.logger.Info("Job cancelled", map[string]interface{}{
+		"jobId": jobID,
+	})
+
+	return h.createSuccessResponse(map[string]interface{}{
+		"jobId": jobID,
 	})
 }
 
@@ -290,11 +305,30 @@ func (h *Handler) createPromise(executor func(resolve, reject js.Value)) js.Valu
 	}))
 }
 
-// createSuccessResponse creates a success response
-func (h *Handler) createSuccessResponse(result *types.ProcessingResult) js.Value {
+// createJobSuccessResponse creates a success response for a finished job
+func (h *Handler) createJobSuccessResponse(jobID string, result *types.ProcessingResult) js.Value {
 	response := types.WASMResponse{
 		Success: true,
 		Result:  result,
+		JobID:   jobID,
+	}
+
+	jsonData, err := json.Marshal(response)
+	if err != nil {
+		h.logger.Error("Failed to marshal success response", err)
+		return h.createErrorResponse("Failed to create response")
+	}
+
+	return js.ValueOf(string(jsonData))
+}
+
+// createSuccessResponse creates a success response holding fields. Like
+// every other export result it is a JSON string, so JavaScript parses all
+// results the same way.
+func (h *Handler) createSuccessResponse(fields map[string]interface{}) js.Value {
+	response := map[string]interface{}{"success": true}
+	for k, v := range fields {
+		response[k] = v
 	}
 
 	jsonData, err := json.Marshal(response)
@@ -344,31 +378,6 @@ func (h *Handler) sendProgressUpdate(updateType string, data map[string]interfac
 	}
 }
 
-// HealthCheck provides a simple health check function
-func (h *Handler) HealthCheck(this js.Value, args []js.Value) any {
-	h.logger.Debug("Health check requested")
-
-	response := map[string]interface{}{
-		"status":    "healthy",
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"version":   "1.0.0",
-		"features": []string{
-			"async-processing",
-			"security-validation",
-			"structured-logging",
-			"environment-specific-logic",
-		},
-	}
-
-	jsonData, err := json.Marshal(response)
-	if err != nil {
-		h.logger.Error("Failed to marshal health check response", err)
-		return js.ValueOf(`{"status":"error","message":"Failed to create health response"}`)
-	}
-
-	return js.ValueOf(string(jsonData))
-}
-
 // SendRealtimeUpdate sends real-time updates to JavaScript
 func (h *Handler) SendRealtimeUpdate(this js.Value, args []js.Value) any {
 	defer func() {
@@ -463,533 +472,891 @@ func (h *Handler) SendRealtimeUpdate(this js.Value, args []js.Value) any {
 		jsCallback.Invoke(string(jsonData))
 	}
 
-	return js.ValueOf(map[string]interface{}{
-		"success": true,
+	return h.createSuccessResponse(map[string]interface{}{
 		"message": "Realtime update sent",
 	})
 }
 
-// CertifyTokenAsync handles token certification requests from JavaScript
-func (h *Handler) CertifyTokenAsync(this js.Value, args []js.Value) any {
-	defer func() {
-		if r := recover(); r != nil {
-			h.logger.Error("Panic in CertifyTokenAsync", fmt.Errorf("%v", r), map[string]interface{}{
-				"stack": string(debug.Stack()),
-			})
-			js.Global().Get("console").Call("error", fmt.Sprintf(PanicMsg, r))
-		}
-	}()
-
-	h.logger.Info("Received token certification request from JavaScript")
+// storeKey is host-provided master key material for OpenStore
+type storeKey struct {
+	ID  string `json:"id"`
+	Key string `json:"key"` // base64, 32 bytes
+}
 
-	if len(args) != 1 {
-		err := fmt.Errorf("invalid number of arguments: expected 1, got %d", len(args))
-		h.logger.Error("Invalid arguments", err)
-		return js.Global().Get("Promise").Call("reject", h.createErrorResponse(err.Error()))
+// OpenStore saves tracked cases and token revocations in IndexedDB so they
+// survive reloads. It takes an optional database name and an optional JSON
+// object with "keys", a list of {id, key} master keys. With keys, records
+// are encrypted at rest with the first key; data wrapped with the others is
+// rewrapped, and a database saved without encryption is encrypted. It
+// returns a Promise that resolves once the saved data has been loaded.
+// Until then, and in browsers without IndexedDB, everything is kept in
+// memory only.
+func (h *Handler) OpenStore(this js.Value, args []js.Value) any {
+	name := DefaultDatabase
+	if len(args) > 0 && args[0].Type() == js.TypeString && args[0].String() != "" {
+		name = args[0].String()
+	}
+	var opts struct {
+		Keys []storeKey `json:"keys"`
+	}
+	if len(args) > 1 && args[1].Type() == js.TypeString && args[1].String() != "" {
+		if err := json.Unmarshal([]byte(args[1].String()), &opts); err != nil {
+			return h.createErrorResponse(fmt.Sprintf("Failed to parse store options: %v", err))
+		}
+	}
+	keys, err := storeKeyring(opts.Keys)
+	if err != nil {
+		return h.createErrorResponse(err.Error())
 	}
 
-	// Parse JSON input
-	tokenDataJSON := args[0].String()
-	h.logger.Debug("Parsing token certification data", map[string]interface{}{
-		"jsonLength": len(tokenDataJSON),
+	return h.createPromise(func(resolve, reject js.Value) {
+		// IndexedDB answers through the event loop, so wait for it on a
+		// goroutine instead of blocking this callback
+		go func() {
+			version, rewrapped, err := h.openStore(name, keys)
+			if err != nil {
+				h.logger.Error("Failed to open persistent store", err, map[string]interface{}{
+					"database": name,
+				})
+				reject.Invoke(h.createErrorResponse(err.Error()))
+				return
+			}
+			h.logger.Info("Persistent store opened", map[string]interface{}{
+				"database":      name,
+				"schemaVersion": version,
+				"encrypted":     keys != nil,
+				"rewrapped":     rewrapped,
+			})
+			resolve.Invoke(h.createSuccessResponse(map[string]interface{}{
+				"database":      name,
+				"schemaVersion": version,
+				"encrypted":     keys != nil,
+				"rewrapped":     rewrapped,
+			}))
+		}()
 	})
+}
 
-	var tokenData struct {
-		Token       string `json:"token"`
-		CaseNumber  string `json:"caseNumber"`
-		Environment string `json:"environment"`
+// storeKeyring decodes OpenStore keys; no keys means no encryption
+func storeKeyring(keys []storeKey) (*store.Keyring, error) {
+	if len(keys) == 0 {
+		return nil, nil
 	}
-
-	if err := json.Unmarshal([]byte(tokenDataJSON), &tokenData); err != nil {
-		h.logger.Error("Failed to parse token data JSON", err)
-		return js.Global().Get("Promise").Call("reject",
-			h.createErrorResponse(fmt.Sprintf("Failed to parse token data: %v", err)))
+	decoded := make([]store.Key, len(keys))
+	for i, k := range keys {
+		material, err := base64.StdEncoding.DecodeString(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid store key %q: %v", k.ID, err)
+		}
+		decoded[i] = store.Key{ID: k.ID, Material: material}
 	}
+	return store.NewKeyring(decoded[0], decoded[1:]...)
+}
+
+// openStore attaches the named IndexedDB database to the record cache,
+// encrypting it when keys are given, and migrates the saved data. It
+// returns the schema version and the number of rewrapped records.
+func (h *Handler) openStore(name string, keys *store.Keyring) (int, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 
-	// Validate token data
-	if tokenData.Token == "" {
-		return js.Global().Get("Promise").Call("reject", h.createErrorResponse("Token is required"))
+	db, err := store.OpenIndexedDB(ctx, name)
+	if err != nil {
+		return 0, 0, err
+	}
+	var backend store.Backend = db
+	rewrapped := 0
+	if keys != nil {
+		encrypted, err := store.OpenEncrypted(ctx, db, keys, store.EncryptExisting())
+		if err != nil {
+			db.Close()
+			return 0, 0, err
+		}
+		if rewrapped, err = encrypted.Rotate(ctx); err != nil {
+			db.Close()
+			return 0, 0, err
+		}
+		backend = encrypted
+	}
+	if err := h.cache.Attach(ctx, backend); err != nil {
+		backend.Close()
+		return 0, 0, err
 	}
+	version, err := store.Migrate(ctx, h.cache, store.Migrations())
+	return version, rewrapped, err
+}
 
-	if tokenData.CaseNumber == "" {
-		return js.Global().Get("Promise").Call("reject", h.createErrorResponse("Case number is required"))
+// storeWriteFailed reports changes that could not be saved
+func (h *Handler) storeWriteFailed(err error) {
+	if errors.Is(err, store.ErrQuotaExceeded) {
+		h.logger.Warn("Browser storage is full; recent changes will be lost on reload", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
 	}
+	h.logger.Error("Failed to save changes", err)
+}
 
-	// Validate case number format (simple regex check)
-	if !caseNumberRegex.MatchString(tokenData.CaseNumber) {
-		return js.Global().Get("Promise").Call("reject", h.createErrorResponse("Invalid case number format"))
+// LoadProcessingTimes replaces the processing-times dataset with one supplied
+// by JavaScript. It takes the file contents and a format of "csv" or "json".
+func (h *Handler) LoadProcessingTimes(this js.Value, args []js.Value) any {
+	if len(args) != 2 {
+		err := fmt.Errorf("invalid number of arguments: expected 2, got %d", len(args))
+		h.logger.Error("Invalid arguments for processing times", err)
+		return h.createErrorResponse(err.Error())
 	}
 
-	// Rate limiting check
-	rateLimitKey := fmt.Sprintf("certify:%s", tokenData.CaseNumber)
-	if !h.rateLimiter.Allow(rateLimitKey) {
-		h.logger.Warn("Rate limit exceeded for token certification", map[string]interface{}{
-			"rateLimitKey": rateLimitKey,
-			"caseNumber":   tokenData.CaseNumber,
-		})
-		return js.Global().Get("Promise").Call("reject", h.createErrorResponse("Rate limit exceeded. Please try again later."))
+	var (
+		dataset *estimate.Dataset
+		err     error
+	)
+	data := strings.NewReader(args[0].String())
+	switch strings.ToLower(args[1].String()) {
+	case "csv":
+		dataset, err = estimate.LoadCSV(data)
+	case "json":
+		dataset, err = estimate.LoadJSON(data)
+	default:
+		err = fmt.Errorf("unsupported processing times format %q", args[1].String())
+	}
+	if err != nil {
+		h.logger.Error("Failed to load processing times", err)
+		return h.createErrorResponse(err.Error())
 	}
 
-	h.logger.Info("Token data validated successfully", map[string]interface{}{
-		"caseNumber":  tokenData.CaseNumber,
-		"environment": tokenData.Environment,
+	h.svc.SetEstimator(estimate.NewEstimator(dataset, nil))
+	h.logger.Info("Processing times loaded", map[string]interface{}{
+		"version": dataset.Version,
+		"entries": len(dataset.Entries),
 	})
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// Create channels for async processing
-	resultCh := make(chan map[string]interface{}, 1)
-	errCh := make(chan error, 1)
+	return h.createSuccessResponse(map[string]interface{}{
+		"version": dataset.Version,
+		"entries": len(dataset.Entries),
+	})
+}
 
-	// Process token certification asynchronously
-	go func() {
-		defer cancel()
+// Configure applies host settings given as a JSON object. "signingKey" is
+// the key certification tokens are signed with; until one is given
+// certification fails with service.ErrNotConfigured.
+func (h *Handler) Configure(this js.Value, args []js.Value) any {
+	if len(args) != 1 {
+		err := fmt.Errorf("invalid number of arguments: expected 1, got %d", len(args))
+		h.logger.Error("Invalid arguments for configuration", err)
+		return h.createErrorResponse(err.Error())
+	}
 
-		// Validate token against case number (simple validation logic)
-		h.logger.Info("Validating token against case number", map[string]interface{}{
-			"caseNumber": tokenData.CaseNumber,
-			"tokenHash":  generateSecureTokenHash(tokenData.Token), // Log secure hash instead of raw token
-		})
+	var settings struct {
+		SigningKey string `json:"signingKey"`
+	}
+	if err := json.Unmarshal([]byte(args[0].String()), &settings); err != nil {
+		h.logger.Error("Failed to parse configuration", err)
+		return h.createErrorResponse("invalid configuration: " + err.Error())
+	}
 
-		// Simple token validation logic (in production, this would be more sophisticated)
-		isValidToken := h.validateToken(tokenData.Token, tokenData.CaseNumber)
-		if !isValidToken {
-			errCh <- fmt.Errorf("invalid token for case number")
-			return
-		}
+	h.svc.SetSigningKey(settings.SigningKey)
+	configured := settings.SigningKey != ""
+	h.logger.Info("Configuration applied", map[string]interface{}{
+		"certification": configured,
+	})
 
-		// Generate verification ID using secure token generation
-		verificationID, err := security.GenerateSecureToken(tokenData.CaseNumber)
-		if err != nil {
-			h.logger.Error("Failed to generate verification ID", err)
-			// Fallback to timestamp-based ID
-			verificationID = fmt.Sprintf("CERT-%d", time.Now().Unix())
-		}
+	return h.createSuccessResponse(map[string]interface{}{
+		"certification": configured,
+	})
+}
 
-		// Generate dynamic case details based on case number
-		caseDetails := h.generateCaseDetails(tokenData.CaseNumber, tokenData.Environment)
+// announceChange forwards a detected case change to JavaScript
+func (h *Handler) announceChange(e changes.Event) {
+	h.logger.Info("Case change detected", map[string]interface{}{
+		"caseNumber": e.ReceiptNumber,
+		"type":       string(e.Type),
+	})
+	h.sendProgressUpdate("case_changed", map[string]interface{}{
+		"id":         e.ID,
+		"caseNumber": e.ReceiptNumber,
+		"type":       string(e.Type),
+		"before":     e.Before,
+		"after":      e.After,
+		"title":      e.Status.Title,
+		"summary":    e.Summary(),
+		"occurredAt": e.OccurredAt.Format(time.RFC3339),
+	})
+}
 
-		// Create certification result
-		result := map[string]interface{}{
-			"isValid":        true,
-			"caseStatus":     caseDetails["Current Status"],
-			"lastUpdated":    time.Now().UTC().Format(time.RFC3339),
-			"caseDetails":    caseDetails,
-			"verificationId": verificationID,
-		}
+// NotificationRateLimit caps deliveries per notifier or webhook endpoint per
+// minute
+const NotificationRateLimit = 20
 
-		// Send realtime update
-		h.SendRealtimeUpdate(js.Null(), []js.Value{
-			js.ValueOf("token_certified"),
-			js.ValueOf(map[string]interface{}{
-				"caseNumber":     tokenData.CaseNumber,
-				"verificationId": verificationID,
-				"timestamp":      time.Now().UTC().Format(time.RFC3339),
-			}),
-		})
+// browserNotifier returns the rate-limited desktop notification channel
+func (h *Handler) browserNotifier() notify.Notifier {
+	return notify.WithRateLimit(notify.NewBrowser("browser", ""), ratelimit.NewRateLimiter(NotificationRateLimit, time.Minute))
+}
 
-		// Send result through channel
-		resultCh <- result
-	}()
+// ConfigureNotifications replaces the notification channels and routing
+// rules. It takes a JSON object with "browser" (bool), "webhooks" (a list
+// of {"name", "url", "secret"}) and "rules" (a list of {"types",
+// "receipts", "notifiers"}); without rules every change goes to every
+// channel. Webhooks are delivered through the outbox, which retries failed
+// deliveries and keeps those that keep failing in a dead-letter queue.
+func (h *Handler) ConfigureNotifications(this js.Value, args []js.Value) any {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return h.createErrorResponse("Expected 1 argument: notification settings JSON")
+	}
+	var request struct {
+		Browser  bool `json:"browser"`
+		Webhooks []struct {
+			Name   string `json:"name"`
+			URL    string `json:"url"`
+			Secret string `json:"secret"`
+		} `json:"webhooks"`
+		Rules []notify.Rule `json:"rules"`
+	}
+	if err := json.Unmarshal([]byte(args[0].String()), &request); err != nil {
+		return h.createErrorResponse(fmt.Sprintf("Failed to parse notification settings: %v", err))
+	}
 
-	// Return a Promise
-	return h.createPromise(func(resolve, reject js.Value) {
-		select {
-		case result := <-resultCh:
-			h.logger.Info("Token certification completed successfully", map[string]interface{}{
-				"caseNumber": tokenData.CaseNumber,
-			})
+	var notifiers []notify.Notifier
+	if request.Browser {
+		notifiers = append(notifiers, h.browserNotifier())
+	}
+	endpoints := make([]webhook.Endpoint, len(request.Webhooks))
+	for i, w := range request.Webhooks {
+		endpoints[i] = webhook.Endpoint{Name: w.Name, URL: w.URL, Secret: []byte(w.Secret)}
+		if err := endpoints[i].Validate(); err != nil {
+			return h.createErrorResponse(err.Error())
+		}
+		// queue every event; the outbox paces deliveries per endpoint
+		notifiers = append(notifiers, h.outbox.Notifier(w.Name))
+	}
+	if err := h.notifier.Reset(notifiers, request.Rules); err != nil {
+		return h.createErrorResponse(err.Error())
+	}
+	if err := h.setWebhookEndpoints(endpoints); err != nil {
+		return h.createErrorResponse(err.Error())
+	}
 
-			// Create success response
-			jsonData, err := json.Marshal(result)
-			if err != nil {
-				h.logger.Error("Failed to marshal certification result", err)
-				reject.Invoke(h.createErrorResponse("Failed to create certification result"))
-				return
-			}
+	names := h.notifier.Notifiers()
+	h.logger.Info("Notification channels configured", map[string]interface{}{
+		"notifiers": names,
+		"rules":     len(request.Rules),
+	})
+	return h.createSuccessResponse(map[string]interface{}{
+		"notifiers": names,
+	})
+}
 
-			resolve.Invoke(js.ValueOf(string(jsonData)))
-		case err := <-errCh:
-			h.logger.Error("Token certification failed", err, map[string]interface{}{
-				"caseNumber": tokenData.CaseNumber,
-			})
-			reject.Invoke(h.createErrorResponse(err.Error()))
-		case <-ctx.Done():
-			err := ctx.Err()
-			h.logger.Error("Token certification timeout", err, map[string]interface{}{
-				"caseNumber": tokenData.CaseNumber,
-			})
-			reject.Invoke(h.createErrorResponse("Token certification timeout"))
+// setWebhookEndpoints makes endpoints the outbox's only endpoints and runs
+// the delivery loop while there are any
+func (h *Handler) setWebhookEndpoints(endpoints []webhook.Endpoint) error {
+	keep := make(map[string]bool, len(endpoints))
+	for _, ep := range endpoints {
+		if err := h.outbox.SetEndpoint(ep); err != nil {
+			return err
 		}
+		keep[ep.Name] = true
+	}
+	for _, name := range h.outbox.Endpoints() {
+		if !keep[name] {
+			h.outbox.RemoveEndpoint(name)
+		}
+	}
+	if len(endpoints) == 0 {
+		h.outbox.Stop()
+		return nil
+	}
+	if err := h.outbox.Start(context.Background()); err != nil && !errors.Is(err, webhook.ErrRunning) {
+		return err
+	}
+	return nil
+}
+
+// WebhookDeadLetters lists webhook deliveries that exhausted their retries
+func (h *Handler) WebhookDeadLetters(this js.Value, args []js.Value) any {
+	dead, err := h.outbox.DeadLetters(context.Background())
+	if err != nil {
+		h.logger.Error("Failed to list webhook dead letters", err)
+		return h.createErrorResponse("Failed to list webhook dead letters")
+	}
+	if dead == nil {
+		dead = []*webhook.Delivery{}
+	}
+	return h.createSuccessResponse(map[string]interface{}{
+		"deliveries": dead,
 	})
 }
 
-// validateToken performs comprehensive cryptographic token validation
-func (h *Handler) validateToken(token, caseNumber string) bool {
-	// Rate limiting check for validation attempts
-	if !h.validationLimiter.Allow("token_validation") {
-		h.logger.Warn("Token validation rate limit exceeded", map[string]interface{}{
-			"action": "token_validation",
+// WebhookReplay requeues dead webhook deliveries. It takes a delivery ID,
+// or no argument to replay the whole dead-letter queue.
+func (h *Handler) WebhookReplay(this js.Value, args []js.Value) any {
+	ctx := context.Background()
+	if len(args) > 0 && args[0].Type() == js.TypeString && args[0].String() != "" {
+		if _, err := h.outbox.Replay(ctx, args[0].String()); err != nil {
+			return h.createErrorResponse(err.Error())
+		}
+		return h.createSuccessResponse(map[string]interface{}{
+			"replayed": 1,
 		})
-		return false
 	}
 
-	// Basic input validation
-	if len(token) == 0 {
-		h.logger.Info("Token validation failed: empty token", map[string]interface{}{
-			"caseNumber": caseNumber,
-		})
-		return false
+	n, err := h.outbox.ReplayAll(ctx, "")
+	if err != nil {
+		return h.createErrorResponse(err.Error())
 	}
+	return h.createSuccessResponse(map[string]interface{}{
+		"replayed": n,
+	})
+}
 
-	if len(caseNumber) != 13 {
-		h.logger.Info("Token validation failed: invalid case number format", map[string]interface{}{
-			"caseNumber":  caseNumber,
-			"tokenLength": len(token),
-		})
-		return false
+// AuditLog lists audit entries. It takes an optional JSON query with
+// "actor", "action", "subject", "since" and "limit" fields.
+func (h *Handler) AuditLog(this js.Value, args []js.Value) any {
+	var query audit.Query
+	if len(args) > 0 && args[0].Type() == js.TypeString && args[0].String() != "" {
+		if err := json.Unmarshal([]byte(args[0].String()), &query); err != nil {
+			return h.createErrorResponse(fmt.Sprintf("Failed to parse audit query: %v", err))
+		}
 	}
 
-	// Parse and validate JWT
-	claims, tokenID, err := h.parseAndValidateJWT(token)
+	entries, err := h.auditLog.List(context.Background(), query)
 	if err != nil {
-		h.logger.Info("Token validation failed: JWT parsing/validation error", map[string]interface{}{
-			"caseNumber": caseNumber,
-			"error":      err.Error(),
-		})
-		return false
+		h.logger.Error("Failed to list audit log", err)
+		return h.createErrorResponse("Failed to list audit log")
 	}
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+	return h.createSuccessResponse(map[string]interface{}{
+		"entries": entries,
+	})
+}
 
-	// Validate claims
-	if !h.validateClaims(claims, caseNumber) {
-		h.logger.Info("Token validation failed: claims validation error", map[string]interface{}{
-			"caseNumber": caseNumber,
-			"subject":    claims.Subject,
-			"issuer":     claims.Issuer,
-			"audience":   claims.Audience,
-		})
-		return false
+// ExportCalendar exports watched cases as an iCalendar file. It takes an
+// optional JSON object with "receiptNumber" to export a single case and
+// "appointments" read from notices. The result carries the file contents,
+// a filename and the MIME type; the frontend's downloadExport turns it into
+// a Blob download.
+func (h *Handler) ExportCalendar(this js.Value, args []js.Value) any {
+	var req struct {
+		ReceiptNumber string             `json:"receiptNumber"`
+		Appointments  []ical.Appointment `json:"appointments"`
+	}
+	if len(args) > 0 && args[0].Type() == js.TypeString && args[0].String() != "" {
+		if err := json.Unmarshal([]byte(args[0].String()), &req); err != nil {
+			return h.createErrorResponse(fmt.Sprintf("Failed to parse calendar export: %v", err))
+		}
 	}
 
-	// Check token revocation if enabled
-	if h.tokenConfig.EnableRevocation && h.tokenStore.IsRevoked(tokenID) {
-		h.logger.Info("Token validation failed: token revoked", map[string]interface{}{
-			"caseNumber": caseNumber,
-			"tokenID":    tokenID,
-		})
-		return false
+	ctx := context.Background()
+	var entries []*watchlist.Entry
+	if req.ReceiptNumber != "" {
+		entry, err := h.svc.Watchlist().Get(ctx, req.ReceiptNumber)
+		if err != nil {
+			return h.createErrorResponse(err.Error())
+		}
+		entries = []*watchlist.Entry{entry}
+	} else {
+		var err error
+		if entries, err = h.svc.Watchlist().List(ctx, watchlist.Filter{}); err != nil {
+			h.logger.Error("Failed to list watchlist", err)
+			return h.createErrorResponse(err.Error())
+		}
 	}
 
-	// Check if token is in valid token list (if using allowlist)
-	if h.tokenStore.IsValid(tokenID) {
-		h.logger.Info("Token validation failed: token not in valid list", map[string]interface{}{
-			"caseNumber": caseNumber,
-			"tokenID":    tokenID,
-		})
-		return false
+	var cases []ical.Case
+	for _, entry := range entries {
+		record := entry.Record()
+		if record == nil {
+			continue
+		}
+		c := ical.Case{Record: record, Appointments: req.Appointments}
+		if caseReceipt, err := receipt.Parse(record.ReceiptNumber); err == nil {
+			c.Estimate = h.svc.Estimate(caseReceipt, record.FormType, record.Timeline)
+		}
+		cases = append(cases, c)
 	}
 
-	h.logger.Info("Token validation successful", map[string]interface{}{
-		"caseNumber": caseNumber,
-		"tokenID":    tokenID,
-		"expiresAt":  time.Unix(claims.ExpiresAt, 0),
+	name, filename := "USCIS cases", "uscis-cases.ics"
+	if req.ReceiptNumber != "" {
+		number := receipt.Normalize(req.ReceiptNumber)
+		name, filename = "USCIS case "+number, strings.ToLower(number)+".ics"
+	}
+	cal := ical.NewBuilder(ical.WithDeadlines(h.svc.Deadlines())).Calendar(name, cases...)
+	data, err := cal.Bytes(h.svc.Clock().Now())
+	if err != nil {
+		h.logger.Error("Failed to encode calendar", err)
+		return h.createErrorResponse("Failed to create calendar")
+	}
+	h.logger.Info("Calendar exported", map[string]interface{}{
+		"cases":  len(cases),
+		"events": len(cal.Events),
 	})
 
-	return true
-}
-
-// RevokeToken revokes a token by ID
-func (h *Handler) RevokeToken(tokenID string) {
-	if store, ok := h.tokenStore.(*InMemoryTokenStore); ok {
-		store.RevokeToken(tokenID)
-		h.logger.Info("Token revoked", map[string]interface{}{
-			"tokenID": tokenID,
-		})
+	result := map[string]interface{}{
+		"filename": filename,
+		"mimeType": ical.MIMEType,
+		"events":   len(cal.Events),
+		"content":  string(data),
 	}
+	return h.createSuccessResponse(result)
 }
 
-// AddValidToken adds a token to the valid token list
-func (h *Handler) AddValidToken(tokenID string, expiresAt time.Time) {
-	if store, ok := h.tokenStore.(*InMemoryTokenStore); ok {
-		store.AddValidToken(tokenID, expiresAt)
-		h.logger.Info("Token added to valid list", map[string]interface{}{
-			"tokenID":   tokenID,
-			"expiresAt": expiresAt,
-		})
+// ImportReceipts adds receipts from a CSV, JSON or NDJSON list to the
+// watchlist. It takes the file contents, the format and an optional JSON
+// object with a "dryRun" field, and returns a report of the rows added,
+// skipped as already watched and rejected.
+func (h *Handler) ImportReceipts(this js.Value, args []js.Value) any {
+	if len(args) < 2 {
+		return h.createErrorResponse(fmt.Sprintf("invalid number of arguments: expected at least 2, got %d", len(args)))
+	}
+	format, err := bulk.ParseFormat(args[1].String())
+	if err != nil {
+		return h.createErrorResponse(err.Error())
+	}
+	var opts struct {
+		DryRun bool `json:"dryRun"`
+	}
+	if len(args) > 2 && args[2].Type() == js.TypeString && args[2].String() != "" {
+		if err := json.Unmarshal([]byte(args[2].String()), &opts); err != nil {
+			return h.createErrorResponse(fmt.Sprintf("Failed to parse import options: %v", err))
+		}
 	}
-}
 
-// GetTokenValidationStats returns validation statistics
-func (h *Handler) GetTokenValidationStats() map[string]interface{} {
-	return map[string]interface{}{
-		"config": map[string]interface{}{
-			"issuer":           h.tokenConfig.Issuer,
-			"audience":         h.tokenConfig.Audience,
-			"clockSkew":        h.tokenConfig.ClockSkew,
-			"enableRevocation": h.tokenConfig.EnableRevocation,
-		},
+	batch, err := bulk.Parse(strings.NewReader(args[0].String()), format)
+	if err != nil {
+		return h.createErrorResponse(err.Error())
 	}
+	report := batch.Apply(context.Background(), h.svc.Watchlist(), opts.DryRun)
+	h.logger.Info("Receipts imported", map[string]interface{}{
+		"rows":    report.Rows,
+		"added":   len(report.Added),
+		"skipped": len(report.Skipped),
+		"errors":  len(report.Errors),
+		"dryRun":  opts.DryRun,
+	})
+	return h.createSuccessResponse(map[string]interface{}{"report": report})
 }
 
-// parseAndValidateJWT parses and validates JWT signature
-func (h *Handler) parseAndValidateJWT(token string) (*JWTClaims, string, error) {
-	// Split JWT into parts
-	parts := strings.Split(token, ".")
-	if len(parts) != 3 {
-		return nil, "", fmt.Errorf("invalid JWT format: expected 3 parts, got %d", len(parts))
+// ExportCases exports watched cases. It takes an optional JSON object with
+// "format" (csv, json or ndjson), "fields", "mask" (e.g. "owners,notes" or
+// "all"; A-Numbers are masked unless it includes "none"), "events" to write
+// one row per timeline event and "receiptNumber" to export a single case. Like ExportCalendar it returns the file contents,
+// filename and MIME type for downloadExport.
+func (h *Handler) ExportCases(this js.Value, args []js.Value) any {
+	var req struct {
+		Format        string   `json:"format"`
+		Fields        []string `json:"fields"`
+		Mask          string   `json:"mask"`
+		Events        bool     `json:"events"`
+		ReceiptNumber string   `json:"receiptNumber"`
+	}
+	if len(args) > 0 && args[0].Type() == js.TypeString && args[0].String() != "" {
+		if err := json.Unmarshal([]byte(args[0].String()), &req); err != nil {
+			return h.createErrorResponse(fmt.Sprintf("Failed to parse case export: %v", err))
+		}
 	}
 
-	// Decode header
-	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	format := bulk.FormatCSV
+	if req.Format != "" {
+		var err error
+		if format, err = bulk.ParseFormat(req.Format); err != nil {
+			return h.createErrorResponse(err.Error())
+		}
+	}
+	mask, err := bulk.ParseMask(req.Mask)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to decode JWT header: %w", err)
+		return h.createErrorResponse(err.Error())
 	}
-
-	var header map[string]interface{}
-	if err := json.Unmarshal(headerJSON, &header); err != nil {
-		return nil, "", fmt.Errorf("failed to parse JWT header: %w", err)
+	opts := []bulk.ExportOption{bulk.WithMask(mask)}
+	if len(req.Fields) > 0 {
+		fields, err := bulk.ParseFields(strings.Join(req.Fields, ","))
+		if err != nil {
+			return h.createErrorResponse(err.Error())
+		}
+		opts = append(opts, bulk.WithFields(fields...))
+	}
+	if req.Events {
+		opts = append(opts, bulk.WithEvents())
+	}
+	exporter, err := bulk.NewExporter(format, opts...)
+	if err != nil {
+		return h.createErrorResponse(err.Error())
 	}
 
-	// Verify algorithm
-	if alg, ok := header["alg"].(string); !ok || alg != JWTAlgorithm {
-		return nil, "", fmt.Errorf("unsupported JWT algorithm: expected %s, got %v", JWTAlgorithm, header["alg"])
+	ctx := context.Background()
+	var entries []*watchlist.Entry
+	if req.ReceiptNumber != "" {
+		entry, err := h.svc.Watchlist().Get(ctx, req.ReceiptNumber)
+		if err != nil {
+			return h.createErrorResponse(err.Error())
+		}
+		entries = []*watchlist.Entry{entry}
+	} else if entries, err = h.svc.Watchlist().List(ctx, watchlist.Filter{}); err != nil {
+		h.logger.Error("Failed to list watchlist", err)
+		return h.createErrorResponse(err.Error())
 	}
 
-	// Decode payload
-	payloadJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	var buf bytes.Buffer
+	rows, err := exporter.Export(&buf, bulk.Cases(entries, h.svc.Estimator()))
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to decode JWT payload: %w", err)
+		h.logger.Error("Failed to export cases", err)
+		return h.createErrorResponse("Failed to export cases")
 	}
+	h.logger.Info("Cases exported", map[string]interface{}{
+		"format": string(format),
+		"cases":  len(entries),
+		"rows":   rows,
+		"mask":   mask.String(),
+	})
 
-	var claims JWTClaims
-	if err := json.Unmarshal(payloadJSON, &claims); err != nil {
-		return nil, "", fmt.Errorf("failed to parse JWT claims: %w", err)
+	filename := "uscis-cases." + format.Extension()
+	if req.ReceiptNumber != "" {
+		filename = strings.ToLower(receipt.Normalize(req.ReceiptNumber)) + "." + format.Extension()
+	}
+	result := map[string]interface{}{
+		"filename": filename,
+		"mimeType": format.MIMEType(),
+		"rows":     rows,
+		"content":  buf.String(),
 	}
+	return h.createSuccessResponse(result)
+}
 
-	// Verify signature using constant-time comparison
-	expectedSignature := h.generateSignature(parts[0] + "." + parts[1])
-	actualSignature, err := base64.RawURLEncoding.DecodeString(parts[2])
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to decode JWT signature: %w", err)
+// WatchlistAdd starts watching a case. It takes a JSON object with
+// "receiptNumber" and optional "label", "notes" and "owner" fields.
+func (h *Handler) WatchlistAdd(this js.Value, args []js.Value) any {
+	if len(args) != 1 {
+		return h.createErrorResponse(fmt.Sprintf("invalid number of arguments: expected 1, got %d", len(args)))
 	}
 
-	if !hmac.Equal(expectedSignature, actualSignature) {
-		return nil, "", fmt.Errorf("JWT signature verification failed")
+	var req watchlist.AddRequest
+	if err := json.Unmarshal([]byte(args[0].String()), &req); err != nil {
+		return h.createErrorResponse(fmt.Sprintf("Failed to parse watchlist entry: %v", err))
 	}
 
-	// Generate token ID from claims (using subject + issued at for uniqueness)
-	tokenID := fmt.Sprintf("%s-%d", claims.Subject, claims.IssuedAt)
+	entry, err := h.svc.Watchlist().Add(context.Background(), req)
+	if err != nil {
+		return h.createErrorResponse(err.Error())
+	}
+	h.logger.Info("Case added to watchlist", map[string]interface{}{
+		"caseNumber": entry.ReceiptNumber,
+	})
 
-	return &claims, tokenID, nil
+	return h.createSuccessResponse(map[string]interface{}{"entry": entry})
 }
 
-// validateClaims validates JWT claims
-func (h *Handler) validateClaims(claims *JWTClaims, expectedCaseNumber string) bool {
-	now := time.Now()
-
-	// Validate issuer
-	if claims.Issuer != h.tokenConfig.Issuer {
-		return false
+// WatchlistList returns watched cases, optionally filtered by a JSON object
+// with "owner", "label", "form", "stage", "status", "actionRequired" and
+// "query" fields
+func (h *Handler) WatchlistList(this js.Value, args []js.Value) any {
+	var filter watchlist.Filter
+	if len(args) > 0 && args[0].Type() == js.TypeString && args[0].String() != "" {
+		if err := json.Unmarshal([]byte(args[0].String()), &filter); err != nil {
+			return h.createErrorResponse(fmt.Sprintf("Failed to parse watchlist filter: %v", err))
+		}
 	}
 
-	// Validate audience
-	if claims.Audience != h.tokenConfig.Audience {
-		return false
+	entries, err := h.svc.Watchlist().List(context.Background(), filter)
+	if err != nil {
+		h.logger.Error("Failed to list watchlist", err)
+		return h.createErrorResponse(err.Error())
 	}
+	return h.createSuccessResponse(map[string]interface{}{"entries": entries})
+}
 
-	// Validate expiration with clock skew tolerance
-	expirationTime := time.Unix(claims.ExpiresAt, 0)
-	if now.After(expirationTime.Add(h.tokenConfig.ClockSkew)) {
-		return false
+// WatchlistRemove stops watching the case with the given receipt number
+func (h *Handler) WatchlistRemove(this js.Value, args []js.Value) any {
+	if len(args) != 1 {
+		return h.createErrorResponse(fmt.Sprintf("invalid number of arguments: expected 1, got %d", len(args)))
 	}
 
-	// Validate issued at (not too far in the future)
-	issuedTime := time.Unix(claims.IssuedAt, 0)
-	if issuedTime.After(now.Add(h.tokenConfig.ClockSkew)) {
-		return false
+	if err := h.svc.Watchlist().Remove(context.Background(), args[0].String()); err != nil {
+		return h.createErrorResponse(err.Error())
 	}
+	return h.createSuccessResponse(nil)
+}
 
-	// Validate case number matches
-	if claims.CaseNumber != expectedCaseNumber {
-		return false
+// StartScheduler starts background polling of watched cases. It takes an
+// optional JSON object with "environment", "intervalMinutes" and
+// "quietHours" (e.g. "22:00-06:00" in UTC). Refreshed cases are stored on the
+// watchlist and announced with case_refreshed updates.
+func (h *Handler) StartScheduler(this js.Value, args []js.Value) any {
+	request := struct {
+		Environment     string `json:"environment"`
+		IntervalMinutes int    `json:"intervalMinutes"`
+		QuietHours      string `json:"quietHours"`
+	}{Environment: "development"}
+	if len(args) > 0 && args[0].Type() == js.TypeString && args[0].String() != "" {
+		if err := json.Unmarshal([]byte(args[0].String()), &request); err != nil {
+			return h.createErrorResponse(fmt.Sprintf("Failed to parse scheduler options: %v", err))
+		}
+	}
+	cfg := scheduler.DefaultConfig()
+	if request.IntervalMinutes > 0 {
+		cfg.Interval = time.Duration(request.IntervalMinutes) * time.Minute
+	}
+	if request.QuietHours != "" {
+		quiet, err := scheduler.ParseQuietHours(request.QuietHours, time.UTC)
+		if err != nil {
+			return h.createErrorResponse(err.Error())
+		}
+		cfg.QuietHours = quiet
 	}
 
-	// Validate case number format
-	if !h.isValidCaseNumberFormat(claims.CaseNumber) {
-		return false
+	if err := h.svc.StartPolling(context.Background(), request.Environment, cfg); err != nil {
+		return h.createErrorResponse(err.Error())
 	}
 
-	return true
+	return h.createSuccessResponse(map[string]interface{}{
+		"environment": request.Environment,
+		"interval":    cfg.Interval.String(),
+	})
 }
 
-// generateSignature generates HMAC signature for JWT
-func (h *Handler) generateSignature(data string) []byte {
-	mac := hmac.New(sha256.New, []byte(h.tokenConfig.SigningKey))
-	mac.Write([]byte(data))
-	return mac.Sum(nil)
+// StopScheduler stops background polling
+func (h *Handler) StopScheduler(this js.Value, args []js.Value) any {
+	h.svc.StopPolling()
+	return h.createSuccessResponse(nil)
 }
 
-// isValidCaseNumberFormat validates USCIS case number format
-func (h *Handler) isValidCaseNumberFormat(caseNumber string) bool {
-	// USCIS case numbers are typically 3 letters followed by 10 digits
-	return caseNumberRegex.MatchString(caseNumber)
-}
+// SetScenario selects the scenario pack used for generated development and
+// staging cases. It takes a scenario name; "mixed" or an empty name restores
+// the default spread of outcomes.
+func (h *Handler) SetScenario(this js.Value, args []js.Value) any {
+	name := ""
+	if len(args) > 0 && args[0].Type() == js.TypeString {
+		name = args[0].String()
+	}
 
-// parseDigit safely parses a single digit character to integer
-func (h *Handler) parseDigit(digit byte) (int, error) {
-	if digit < '0' || digit > '9' {
-		return 0, fmt.Errorf("invalid digit: %c", digit)
+	scenario, err := casegen.ParseScenario(name)
+	if err != nil {
+		return h.createErrorResponse(err.Error())
 	}
-	return int(digit - '0'), nil
+	h.svc.Processor().Cases().SetScenario(scenario)
+	h.logger.Info("Case scenario changed", map[string]interface{}{
+		"scenario": string(scenario),
+	})
+
+	return h.createSuccessResponse(map[string]interface{}{
+		"scenario": string(scenario),
+	})
 }
 
-// safeGetDigit safely gets a digit value from caseDigits with bounds checking
-func (h *Handler) safeGetDigit(caseDigits string, index int, defaultValue int) int {
-	if index >= len(caseDigits) {
-		return defaultValue
+// ScanNeighbours starts a scan of the receipts adjacent to a case. It takes
+// a JSON object with "caseNumber", "environment" and "radius" and resolves
+// immediately with the job ID; progress arrives as job_progress updates and
+// the report is read with goGetJob. Results are cached so repeating a scan
+// resumes where the previous one stopped.
+func (h *Handler) ScanNeighbours(this js.Value, args []js.Value) any {
+	if len(args) != 1 {
+		return h.createErrorResponse(fmt.Sprintf("invalid number of arguments: expected 1, got %d", len(args)))
 	}
-	if digit, err := h.parseDigit(caseDigits[index]); err == nil {
-		return digit
+
+	var request struct {
+		CaseNumber  string `json:"caseNumber"`
+		Environment string `json:"environment"`
+		Radius      int    `json:"radius"`
+	}
+	if err := json.Unmarshal([]byte(args[0].String()), &request); err != nil {
+		return h.createErrorResponse(fmt.Sprintf("Failed to parse scan request: %v", err))
 	}
-	return defaultValue
+
+	job, err := h.svc.SubmitScan(context.Background(), request.CaseNumber, request.Environment, request.Radius, scan.WithCache(h.scanCache))
+	if err != nil {
+		return h.createErrorResponse(err.Error())
+	}
+
+	return h.createSuccessResponse(map[string]interface{}{
+		"jobId": job.ID(),
+	})
 }
 
-// generateCaseDetails creates dynamic case details based on case number
-func (h *Handler) generateCaseDetails(caseNumber, environment string) map[string]string {
-	const (
-		caseApproved = "Case Was Approved"
-		caseReview   = "Case Is Being Actively Reviewed"
-		caseRFE      = "Request for Evidence Was Sent"
-		caseTransfer = "Case Was Transferred"
-		dateFormat   = "%04d-%02d-%02d"
-	)
+// LoadVisaBulletin adds visa bulletins supplied by JavaScript to the
+// bulletin history. It takes the file contents and a format of "html" or
+// "csv"; a CSV file may hold several months.
+func (h *Handler) LoadVisaBulletin(this js.Value, args []js.Value) any {
+	if len(args) != 2 {
+		err := fmt.Errorf("invalid number of arguments: expected 2, got %d", len(args))
+		h.logger.Error("Invalid arguments for visa bulletin", err)
+		return h.createErrorResponse(err.Error())
+	}
 
-	// Extract information from case number to make it more realistic
-	casePrefix := caseNumber[:3]
-	caseDigits := caseNumber[3:]
-
-	// Determine processing center based on case prefix
-	var processingCenter string
-	switch casePrefix {
-	case "ABC":
-		processingCenter = "Texas Service Center"
-	case "DEF":
-		processingCenter = "California Service Center"
-	case "GHI":
-		processingCenter = "Nebraska Service Center"
-	case "JKL":
-		processingCenter = "Vermont Service Center"
+	var (
+		bulletins []*visabulletin.Bulletin
+		err       error
+	)
+	data := strings.NewReader(args[0].String())
+	switch strings.ToLower(args[1].String()) {
+	case "html":
+		var b *visabulletin.Bulletin
+		if b, err = visabulletin.ParseHTML(data, time.Time{}); err == nil {
+			bulletins = []*visabulletin.Bulletin{b}
+		}
+	case "csv":
+		bulletins, err = visabulletin.ParseCSV(data)
 	default:
-		processingCenter = "National Benefits Center"
+		err = fmt.Errorf("unsupported visa bulletin format %q", args[1].String())
+	}
+	if err != nil {
+		h.logger.Error("Failed to load visa bulletin", err)
+		return h.createErrorResponse(err.Error())
 	}
 
-	// Generate priority date from case digits with validation
-	baseYear := 2020
-	var priorityDate string
+	months := make([]string, len(bulletins))
+	for i, b := range bulletins {
+		h.bulletins.Add(b)
+		months[i] = b.Month.Format("2006-01")
+	}
+	h.logger.Info("Visa bulletins loaded", map[string]interface{}{
+		"months":  len(bulletins),
+		"history": h.bulletins.Len(),
+	})
 
-	// Validate caseDigits length and content
-	if len(caseDigits) < 3 {
-		h.logger.Warn("Case number too short for date generation, using defaults", map[string]interface{}{
-			"caseNumber": caseNumber,
-			"length":     len(caseDigits),
-		})
-		// Use safe defaults
-		priorityDate = fmt.Sprintf(dateFormat, baseYear, 1, 1)
-	} else {
-		// Safely parse digits with validation
-		yearDigit, err1 := h.parseDigit(caseDigits[0])
-		monthDigit, err2 := h.parseDigit(caseDigits[1])
-		dayDigit, err3 := h.parseDigit(caseDigits[2])
-
-		if err1 != nil || err2 != nil || err3 != nil {
-			h.logger.Warn("Invalid digits in case number, using defaults", map[string]interface{}{
-				"caseNumber": caseNumber,
-				"errors":     []string{err1.Error(), err2.Error(), err3.Error()},
-			})
-			// Use safe defaults
-			priorityDate = fmt.Sprintf(dateFormat, baseYear, 1, 1)
-		} else {
-			// Safe arithmetic with validated digits
-			year := baseYear + yearDigit*2 // 2020, 2022, 2024, etc.
-			month := monthDigit*3 + 1      // 1, 4, 7, 10
-			// Clamp month to valid range 1-12
-			if month < 1 {
-				month = 1
-			} else if month > 12 {
-				month = 12
-			}
+	return h.createSuccessResponse(map[string]interface{}{
+		"months": months,
+	})
+}
 
-			day := dayDigit*3 + 1 // 1, 4, 7, 10, 13, 16, 19, 22, 25, 28
-			// Clamp day to valid range 1-28 (safe for all months)
-			if day < 1 {
-				day = 1
-			} else if day > 28 {
-				day = 28
-			}
+// CheckPriorityDate reports whether a priority date is current in the latest
+// loaded visa bulletin. It takes a JSON object with "priorityDate"
+// (YYYY-MM-DD), "category", "country" and an optional "chart".
+func (h *Handler) CheckPriorityDate(this js.Value, args []js.Value) any {
+	if len(args) != 1 {
+		return h.createErrorResponse(fmt.Sprintf("invalid number of arguments: expected 1, got %d", len(args)))
+	}
 
-			priorityDate = fmt.Sprintf(dateFormat, year, month, day)
-		}
+	var req struct {
+		PriorityDate string `json:"priorityDate"`
+		Category     string `json:"category"`
+		Country      string `json:"country"`
+		Chart        string `json:"chart"`
+	}
+	if err := json.Unmarshal([]byte(args[0].String()), &req); err != nil {
+		return h.createErrorResponse(fmt.Sprintf("Failed to parse priority date request: %v", err))
 	}
 
-	// Determine case status based on environment and case number
-	var currentStatus string
-	var approvalDate string
+	priorityDate, err := time.Parse("2006-01-02", req.PriorityDate)
+	if err != nil {
+		return h.createErrorResponse("priorityDate must be formatted as YYYY-MM-DD")
+	}
+	category, ok := visabulletin.NormalizeCategory(req.Category)
+	if !ok {
+		return h.createErrorResponse(fmt.Sprintf("unknown visa category %q", req.Category))
+	}
+	chart := visabulletin.ChartFinalAction
+	if req.Chart != "" {
+		chart = visabulletin.Chart(req.Chart)
+	}
+	// countries without their own column are charged to the worldwide one
+	country, ok := visabulletin.NormalizeCountry(req.Country)
+	if !ok {
+		country = visabulletin.CountryAll
+	}
 
-	if environment == "production" {
-		// In production, mix of statuses
-		statusOptions := []string{caseApproved, caseReview, caseRFE, caseTransfer}
-		statusDigit := h.safeGetDigit(caseDigits, 0, 0)
-		statusIndex := statusDigit % len(statusOptions)
-		currentStatus = statusOptions[statusIndex]
+	bulletin, ok := h.bulletins.Latest()
+	if !ok {
+		return h.createErrorResponse("no visa bulletin loaded")
+	}
+	currency, err := bulletin.Check(priorityDate, chart, category, country)
+	if err != nil {
+		return h.createErrorResponse(err.Error())
+	}
 
-		if currentStatus == caseApproved {
-			// Generate approval date within last 6 months using safe digit parsing
-			monthOffset := h.safeGetDigit(caseDigits, 1, 1)
-			dayOffset := h.safeGetDigit(caseDigits, 2, 1)
-			approvalTime := time.Now().AddDate(0, -monthOffset, -dayOffset)
-			approvalDate = approvalTime.Format("2006-01-02")
+	response := map[string]interface{}{
+		"currency": currency,
+	}
+	if movement, err := h.bulletins.Movement(chart, category, country, 12); err == nil {
+		response["movement"] = movement
+		if month, ok := movement.ProjectCurrent(currency); ok {
+			response["projectedCurrent"] = month.Format("2006-01")
 		}
-	} else {
-		// In development, mostly approved for testing
-		currentStatus = caseApproved
-		dayOffset := h.safeGetDigit(caseDigits, 0, 1)
-		approvalDate = time.Now().AddDate(0, -1, -dayOffset).Format("2006-01-02")
-	}
-
-	// Determine case type based on case number pattern with safe digit checking
-	var caseType string
-	firstDigit := h.safeGetDigit(caseDigits, 0, 0)
-	secondDigit := h.safeGetDigit(caseDigits, 1, 0)
-	switch {
-	case firstDigit >= 5:
-		caseType = "I-485 Application to Register Permanent Residence"
-	case secondDigit >= 5:
-		caseType = "I-130 Petition for Alien Relative"
-	default:
-		caseType = "I-765 Application for Employment Authorization"
 	}
 
-	return map[string]string{
-		"Case Type":            caseType,
-		"Priority Date":        priorityDate,
-		"Processing Center":    processingCenter,
-		"Current Status":       currentStatus,
-		"Approval Notice Date": approvalDate,
+	return h.createSuccessResponse(response)
+}
+
+// ListForms returns the form catalog to JavaScript, optionally filtered by a
+// JSON object with "category" and "center" fields
+func (h *Handler) ListForms(this js.Value, args []js.Value) any {
+	var filter forms.Filter
+	if len(args) > 0 && args[0].Type() == js.TypeString && args[0].String() != "" {
+		if err := json.Unmarshal([]byte(args[0].String()), &filter); err != nil {
+			return h.createErrorResponse(fmt.Sprintf("Failed to parse form filter: %v", err))
+		}
 	}
+
+	return h.createSuccessResponse(map[string]interface{}{
+		"version": forms.Default().Version,
+		"forms":   forms.List(filter),
+	})
 }
 
-// RegisterFunctions registers all WASM functions with JavaScript
+// RegisterFunctions registers all WASM functions with JavaScript. Every
+// function returns, or returns a Promise settled with, a JSON string with a
+// "success" field and either the result fields or an "error".
 func (h *Handler) RegisterFunctions() {
 	h.logger.Info("Registering WASM functions with JavaScript")
 
+	// Register the host configuration
+	js.Global().Set("goConfigure", js.FuncOf(h.Configure))
+
 	// Register the main processing function
 	js.Global().Set("goProcessCredentials", js.FuncOf(h.ProcessCredentialsAsync))
 
 	// Register token certification function
 	js.Global().Set("goCertifyToken", js.FuncOf(h.CertifyTokenAsync))
 
+	// Register job lookup and cancellation functions
+	js.Global().Set("goGetJob", js.FuncOf(h.GetJob))
+	js.Global().Set("goCancelJob", js.FuncOf(h.CancelJob))
+
+	// Register the case status lookup function
+	js.Global().Set("goCaseStatus", js.FuncOf(h.CaseStatusAsync))
+
+	// Register the processing-times dataset loader
+	js.Global().Set("goLoadProcessingTimes", js.FuncOf(h.LoadProcessingTimes))
+
+	// Register the persistent store
+	js.Global().Set("goOpenStore", js.FuncOf(h.OpenStore))
+
+	// Register the watchlist functions
+	js.Global().Set("goWatchlistAdd", js.FuncOf(h.WatchlistAdd))
+	js.Global().Set("goWatchlistList", js.FuncOf(h.WatchlistList))
+	js.Global().Set("goWatchlistRemove", js.FuncOf(h.WatchlistRemove))
+
+	// Register the calendar export
+	js.Global().Set("goExportCalendar", js.FuncOf(h.ExportCalendar))
+
+	// Register bulk import and export
+	js.Global().Set("goImportReceipts", js.FuncOf(h.ImportReceipts))
+	js.Global().Set("goExportCases", js.FuncOf(h.ExportCases))
+
+	// Register the background polling scheduler
+	js.Global().Set("goStartScheduler", js.FuncOf(h.StartScheduler))
+	js.Global().Set("goStopScheduler", js.FuncOf(h.StopScheduler))
+
+	// Register the notification settings
+	js.Global().Set("goConfigureNotifications", js.FuncOf(h.ConfigureNotifications))
+
+	// Register the webhook dead-letter queue functions
+	js.Global().Set("goWebhookDeadLetters", js.FuncOf(h.WebhookDeadLetters))
+	js.Global().Set("goWebhookReplay", js.FuncOf(h.WebhookReplay))
+
+	// Register the audit log viewer
+	js.Global().Set("goAuditLog", js.FuncOf(h.AuditLog))
+
+	// Register the development scenario selector
+	js.Global().Set("goSetScenario", js.FuncOf(h.SetScenario))
+
+	// Register the receipt neighbourhood scanner
+	js.Global().Set("goScanNeighbours", js.FuncOf(h.ScanNeighbours))
+
+	// Register the visa bulletin loader and priority date checker
+	js.Global().Set("goLoadVisaBulletin", js.FuncOf(h.LoadVisaBulletin))
+	js.Global().Set("goCheckPriorityDate", js.FuncOf(h.CheckPriorityDate))
+
+	// Register the form catalog query function
+	js.Global().Set("goListForms", js.FuncOf(h.ListForms))
+
 	// Register a health check function
 	js.Global().Set("goHealthCheck", js.FuncOf(h.HealthCheck))
 
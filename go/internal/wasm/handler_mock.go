@@ -3,15 +3,32 @@
 package wasm
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
+	"MyUSCISgo/pkg/audit"
+	"MyUSCISgo/pkg/bulk"
+	"MyUSCISgo/pkg/casegen"
+	"MyUSCISgo/pkg/changes"
+	"MyUSCISgo/pkg/forms"
+	"MyUSCISgo/pkg/ical"
 	"MyUSCISgo/pkg/logging"
-	"MyUSCISgo/pkg/processing"
+	"MyUSCISgo/pkg/notify"
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/scan"
+	"MyUSCISgo/pkg/scheduler"
+	"MyUSCISgo/pkg/service"
+	"MyUSCISgo/pkg/store"
 	"MyUSCISgo/pkg/types"
-	"MyUSCISgo/pkg/validation"
+	"MyUSCISgo/pkg/watchlist"
+	"MyUSCISgo/pkg/webhook"
 )
 
 // MockJSValue simulates js.Value for non-WASM builds
@@ -26,15 +43,83 @@ type MockJSFunc struct {
 
 // Handler handles WASM function calls from JavaScript (mock version for non-WASM builds)
 type Handler struct {
-	processor *processing.Processor
+	svc       *service.Service
 	logger    *logging.Logger
+	cache     *store.Cache
+	changeBus *changes.Bus
+	auditLog  *store.AuditLog
+	notifier  *notify.Dispatcher
+	outbox    *webhook.Outbox
+
+	realtimeMu sync.RWMutex
+	realtime   func(service.Event)
+}
+
+// loadSecureSigningKey loads the JWT signing key from the environment.
+// Without one certification is disabled and reports
+// service.ErrNotConfigured.
+func loadSecureSigningKey() string {
+	return os.Getenv("JWT_SIGNING_KEY")
 }
 
 // NewHandler creates a new WASM handler
 func NewHandler() *Handler {
-	return &Handler{
-		processor: processing.NewProcessor(),
+	var h *Handler
+	cache := store.NewCache(store.OnWriteError(func(err error) {
+		h.logger.Error("Failed to save changes", err)
+	}))
+	records, err := store.Open(context.Background(), cache)
+	if err != nil {
+		// an unattached cache cannot fail to migrate
+		panic(err)
+	}
+
+	h = &Handler{
 		logger:    logging.NewLogger(logging.LogLevelInfo),
+		cache:     cache,
+		changeBus: changes.NewBus(),
+		auditLog:  records.Audit(),
+	}
+	h.svc = service.New(
+		service.WithLogger(h.logger),
+		service.WithStore(records),
+		service.WithChanges(h.changeBus),
+		service.WithSigningKey(loadSecureSigningKey()),
+		service.WithEvents(h.deliverEvent),
+	)
+	h.notifier = notify.NewDispatcher(notify.WithLogger(h.logger))
+	h.outbox = webhook.New(records.Webhooks(), webhook.WithLogger(h.logger))
+	h.changeBus.Subscribe(func(e changes.Event) {
+		h.logger.Info("Case change detected", map[string]interface{}{
+			"caseNumber": e.ReceiptNumber,
+			"type":       string(e.Type),
+		})
+	})
+	h.changeBus.Subscribe(changes.AuditSink(h.auditLog, func(e changes.Event, err error) {
+		h.logger.Error("Failed to record case change", err, map[string]interface{}{
+			"caseNumber": e.ReceiptNumber,
+			"type":       string(e.Type),
+		})
+	}))
+	h.changeBus.Subscribe(h.notifier.Subscriber())
+	return h
+}
+
+// SetRealtimeCallback sets the function progress and realtime updates are
+// sent to, standing in for the JavaScript callbacks (mock version)
+func (h *Handler) SetRealtimeCallback(fn func(service.Event)) {
+	h.realtimeMu.Lock()
+	defer h.realtimeMu.Unlock()
+	h.realtime = fn
+}
+
+// deliverEvent forwards a service event to the realtime callback
+func (h *Handler) deliverEvent(e service.Event) {
+	h.realtimeMu.RLock()
+	fn := h.realtime
+	h.realtimeMu.RUnlock()
+	if fn != nil {
+		fn(e)
 	}
 }
 
@@ -48,43 +133,394 @@ func (h *Handler) ProcessCredentialsAsync(input string) (string, error) {
 		return "", fmt.Errorf("failed to parse credentials: %w", err)
 	}
 
-	// Validate credentials
-	if err := validation.ValidateCredentials(&creds); err != nil {
-		h.logger.Error("Credential validation failed", err)
+	result, err := h.svc.ProcessCredentials(context.Background(), &creds)
+	if err != nil {
 		return "", err
 	}
 
-	h.logger.Info("Credentials validated successfully", map[string]interface{}{
-		"clientId":    creds.ClientID,
-		"environment": creds.Environment,
+	jsonData, err := json.Marshal(types.WASMResponse{
+		Success: true,
+		Result:  result.Result,
+		JobID:   result.JobID,
 	})
+	if err != nil {
+		h.logger.Error("Failed to marshal response", err)
+		return "", fmt.Errorf("failed to create response: %w", err)
+	}
+
+	return string(jsonData), nil
+}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+// CertifyTokenAsync checks a certification token against a case (mock version)
+func (h *Handler) CertifyTokenAsync(input string) (string, error) {
+	var req service.CertifyRequest
+	if err := json.Unmarshal([]byte(input), &req); err != nil {
+		h.logger.Error("Failed to parse token data JSON", err)
+		return "", fmt.Errorf("failed to parse token data: %w", err)
+	}
 
-	// Process synchronously for non-WASM builds
-	result, err := h.processor.ProcessCredentialsSync(ctx, &creds)
+	cert, err := h.svc.Certify(context.Background(), req)
 	if err != nil {
-		h.logger.Error("Processing failed", err)
 		return "", err
 	}
 
-	response := types.WASMResponse{
-		Success: true,
-		Result:  result,
+	jsonData, err := json.Marshal(cert)
+	if err != nil {
+		h.logger.Error("Failed to marshal certification result", err)
+		return "", fmt.Errorf("failed to create certification result: %w", err)
 	}
+	return string(jsonData), nil
+}
+
+// RevokeToken revokes a token by ID (mock version)
+func (h *Handler) RevokeToken(tokenID string) error {
+	return h.svc.RevokeToken(context.Background(), tokenID)
+}
+
+// AddValidToken registers an issued token until it expires (mock version)
+func (h *Handler) AddValidToken(tokenID string, expiresAt time.Time) error {
+	return h.svc.RegisterToken(context.Background(), tokenID, expiresAt)
+}
 
-	jsonData, err := json.Marshal(response)
+// HealthCheck reports the service health as JSON (mock version)
+func (h *Handler) HealthCheck() string {
+	jsonData, err := json.Marshal(h.svc.Health(context.Background()))
 	if err != nil {
-		h.logger.Error("Failed to marshal response", err)
-		return "", fmt.Errorf("failed to create response: %w", err)
+		h.logger.Error("Failed to marshal health check response", err)
+		return `{"status":"error","message":"Failed to create health response"}`
 	}
-
-	return string(jsonData), nil
+	return string(jsonData)
 }
 
 // RegisterFunctions is a no-op for non-WASM builds
 func (h *Handler) RegisterFunctions() {
 	h.logger.Info("WASM functions registration skipped (non-WASM build)")
 }
+
+// GetJob returns a JSON snapshot of a processing job (mock version)
+func (h *Handler) GetJob(jobID string) (string, error) {
+	job, err := h.svc.Processor().Jobs().Get(jobID)
+	if err != nil {
+		return "", err
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"success": true,
+		"job":     job.Snapshot(),
+	})
+	if err != nil {
+		h.logger.Error("Failed to marshal job snapshot", err)
+		return "", fmt.Errorf("failed to create job response: %w", err)
+	}
+
+	return string(jsonData), nil
+}
+
+// CancelJob cancels a processing job (mock version)
+func (h *Handler) CancelJob(jobID string) error {
+	if err := h.svc.Processor().Jobs().Cancel(jobID); err != nil {
+		return err
+	}
+
+	h.logger.Info("Job cancelled", map[string]interface{}{
+		"jobId": jobID,
+	})
+	return nil
+}
+
+// ListForms returns the form catalog as JSON (mock version)
+func (h *Handler) ListForms(filter forms.Filter) (string, error) {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"success": true,
+		"version": forms.Default().Version,
+		"forms":   forms.List(filter),
+	})
+	if err != nil {
+		h.logger.Error("Failed to marshal form catalog", err)
+		return "", fmt.Errorf("failed to create form catalog response: %w", err)
+	}
+
+	return string(jsonData), nil
+}
+
+// CaseStatus looks up the status and history of a case (mock version)
+func (h *Handler) CaseStatus(caseNumber, environment string) (string, error) {
+	report, err := h.svc.CaseStatus(context.Background(), caseNumber, environment)
+	if err != nil {
+		return "", err
+	}
+
+	jsonData, err := json.Marshal(struct {
+		Success bool `json:"success"`
+		*service.CaseReport
+	}{true, report})
+	if err != nil {
+		h.logger.Error("Failed to marshal case status", err)
+		return "", fmt.Errorf("failed to create case status response: %w", err)
+	}
+
+	return string(jsonData), nil
+}
+
+// ConfigureNotifications replaces the notification channels and routing
+// rules (mock version). Callers build the notifiers: outbox webhooks from
+// WebhookNotifier, or email wrapped with notify.WithRetry and
+// notify.WithRateLimit.
+func (h *Handler) ConfigureNotifications(notifiers []notify.Notifier, rules []notify.Rule) error {
+	if err := h.notifier.Reset(notifiers, rules); err != nil {
+		return err
+	}
+	h.logger.Info("Notification channels configured", map[string]interface{}{
+		"notifiers": h.notifier.Notifiers(),
+		"rules":     len(rules),
+	})
+	return nil
+}
+
+// WebhookNotifier registers an outbox endpoint and returns the notifier
+// that queues deliveries for it (mock version). The outbox delivery loop
+// starts with the first endpoint.
+func (h *Handler) WebhookNotifier(ep webhook.Endpoint) (notify.Notifier, error) {
+	if err := h.outbox.SetEndpoint(ep); err != nil {
+		return nil, err
+	}
+	if err := h.outbox.Start(context.Background()); err != nil && !errors.Is(err, webhook.ErrRunning) {
+		return nil, err
+	}
+	return h.outbox.Notifier(ep.Name), nil
+}
+
+// WebhookDeadLetters lists webhook deliveries that exhausted their retries
+// (mock version)
+func (h *Handler) WebhookDeadLetters() ([]*webhook.Delivery, error) {
+	return h.outbox.DeadLetters(context.Background())
+}
+
+// WebhookReplay requeues a dead webhook delivery, or the whole dead-letter
+// queue when id is empty (mock version)
+func (h *Handler) WebhookReplay(id string) (int, error) {
+	if id == "" {
+		return h.outbox.ReplayAll(context.Background(), "")
+	}
+	if _, err := h.outbox.Replay(context.Background(), id); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// AuditLog lists audit entries matching query (mock version)
+func (h *Handler) AuditLog(query audit.Query) (string, error) {
+	entries, err := h.auditLog.List(context.Background(), query)
+	if err != nil {
+		h.logger.Error("Failed to list audit log", err)
+		return "", err
+	}
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"success": true,
+		"entries": entries,
+	})
+	if err != nil {
+		h.logger.Error("Failed to marshal audit log", err)
+		return "", fmt.Errorf("failed to create audit log response: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+// SetScenario selects the scenario pack used for generated cases (mock version)
+func (h *Handler) SetScenario(name string) error {
+	scenario, err := casegen.ParseScenario(name)
+	if err != nil {
+		return err
+	}
+	h.svc.Processor().Cases().SetScenario(scenario)
+	h.logger.Info("Case scenario changed", map[string]interface{}{
+		"scenario": string(scenario),
+	})
+	return nil
+}
+
+// ScanNeighbours scans the receipts adjacent to a case and returns the
+// aggregate report as JSON (mock version). The scan runs synchronously.
+func (h *Handler) ScanNeighbours(caseNumber, environment string, radius int) (string, error) {
+	ctx := context.Background()
+	job, err := h.svc.SubmitScan(ctx, caseNumber, environment, radius)
+	if err != nil {
+		return "", err
+	}
+	result, err := job.Wait(ctx)
+	if err != nil {
+		h.logger.Error("Receipt scan failed", err)
+		return "", err
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"success": true,
+		"jobId":   job.ID(),
+		"report":  result.(*scan.Report),
+	})
+	if err != nil {
+		h.logger.Error("Failed to marshal scan report", err)
+		return "", fmt.Errorf("failed to create scan response: %w", err)
+	}
+
+	return string(jsonData), nil
+}
+
+// ExportCalendar exports watched cases as an iCalendar file; an empty
+// receipt number exports the whole watchlist (mock version)
+func (h *Handler) ExportCalendar(receiptNumber string, appointments []ical.Appointment) ([]byte, error) {
+	ctx := context.Background()
+	var entries []*watchlist.Entry
+	if receiptNumber != "" {
+		entry, err := h.svc.Watchlist().Get(ctx, receiptNumber)
+		if err != nil {
+			return nil, err
+		}
+		entries = []*watchlist.Entry{entry}
+	} else {
+		var err error
+		if entries, err = h.svc.Watchlist().List(ctx, watchlist.Filter{}); err != nil {
+			return nil, err
+		}
+	}
+
+	var cases []ical.Case
+	for _, entry := range entries {
+		record := entry.Record()
+		if record == nil {
+			continue
+		}
+		c := ical.Case{Record: record, Appointments: appointments}
+		if caseReceipt, err := receipt.Parse(record.ReceiptNumber); err == nil {
+			c.Estimate = h.svc.Estimate(caseReceipt, record.FormType, record.Timeline)
+		}
+		cases = append(cases, c)
+	}
+
+	name := "USCIS cases"
+	if receiptNumber != "" {
+		name = "USCIS case " + receipt.Normalize(receiptNumber)
+	}
+	return ical.NewBuilder(ical.WithDeadlines(h.svc.Deadlines())).Calendar(name, cases...).Bytes(h.svc.Clock().Now())
+}
+
+// ImportReceipts adds receipts from a CSV, JSON or NDJSON list to the
+// watchlist; with dryRun nothing is added (mock version)
+func (h *Handler) ImportReceipts(content string, format bulk.Format, dryRun bool) (*bulk.Report, error) {
+	batch, err := bulk.Parse(strings.NewReader(content), format)
+	if err != nil {
+		return nil, err
+	}
+	report := batch.Apply(context.Background(), h.svc.Watchlist(), dryRun)
+	h.logger.Info("Receipts imported", map[string]interface{}{
+		"rows":    report.Rows,
+		"added":   len(report.Added),
+		"skipped": len(report.Skipped),
+		"errors":  len(report.Errors),
+		"dryRun":  dryRun,
+	})
+	return report, nil
+}
+
+// ExportCases exports watched cases; an empty receipt number exports the
+// whole watchlist (mock version)
+func (h *Handler) ExportCases(receiptNumber string, format bulk.Format, opts ...bulk.ExportOption) ([]byte, error) {
+	exporter, err := bulk.NewExporter(format, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	var entries []*watchlist.Entry
+	if receiptNumber != "" {
+		entry, err := h.svc.Watchlist().Get(ctx, receiptNumber)
+		if err != nil {
+			return nil, err
+		}
+		entries = []*watchlist.Entry{entry}
+	} else if entries, err = h.svc.Watchlist().List(ctx, watchlist.Filter{}); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if _, err := exporter.Export(&buf, bulk.Cases(entries, h.svc.Estimator())); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// OpenStore saves tracked cases in a backend, such as a store.File, and
+// returns the schema version of the saved data and the number of records
+// rewrapped. With keys the backend is encrypted at rest, as in the browser
+// (mock version).
+func (h *Handler) OpenStore(ctx context.Context, backend store.Backend, keys *store.Keyring) (int, int, error) {
+	rewrapped := 0
+	if keys != nil {
+		encrypted, err := store.OpenEncrypted(ctx, backend, keys, store.EncryptExisting())
+		if err != nil {
+			return 0, 0, err
+		}
+		if rewrapped, err = encrypted.Rotate(ctx); err != nil {
+			return 0, 0, err
+		}
+		backend = encrypted
+	}
+	if err := h.cache.Attach(ctx, backend); err != nil {
+		return 0, 0, err
+	}
+	version, err := store.Migrate(ctx, h.cache, store.Migrations())
+	return version, rewrapped, err
+}
+
+// WatchlistAdd starts watching a case and returns the entry as JSON (mock version)
+func (h *Handler) WatchlistAdd(req watchlist.AddRequest) (string, error) {
+	entry, err := h.svc.Watchlist().Add(context.Background(), req)
+	if err != nil {
+		return "", err
+	}
+	h.logger.Info("Case added to watchlist", map[string]interface{}{
+		"caseNumber": entry.ReceiptNumber,
+	})
+	return h.watchlistResponse("entry", entry)
+}
+
+// WatchlistList returns the watched cases matching filter as JSON (mock version)
+func (h *Handler) WatchlistList(filter watchlist.Filter) (string, error) {
+	entries, err := h.svc.Watchlist().List(context.Background(), filter)
+	if err != nil {
+		h.logger.Error("Failed to list watchlist", err)
+		return "", err
+	}
+	return h.watchlistResponse("entries", entries)
+}
+
+// WatchlistRemove stops watching a case (mock version)
+func (h *Handler) WatchlistRemove(receiptNumber string) error {
+	return h.svc.Watchlist().Remove(context.Background(), receiptNumber)
+}
+
+// watchlistResponse marshals a successful watchlist result
+func (h *Handler) watchlistResponse(key string, value any) (string, error) {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"success": true,
+		key:       value,
+	})
+	if err != nil {
+		h.logger.Error("Failed to marshal watchlist response", err)
+		return "", fmt.Errorf("failed to create watchlist response: %w", err)
+	}
+	return string(jsonData), nil
+}
+
+// StartScheduler starts background polling of watched cases in env (mock version)
+func (h *Handler) StartScheduler(env string, cfg scheduler.Config) error {
+	return h.svc.StartPolling(context.Background(), env, cfg)
+}
+
+// StopScheduler stops background polling (mock version)
+func (h *Handler) StopScheduler() {
+	h.svc.StopPolling()
+}
@@ -0,0 +1,189 @@
+// Package audit records who did what to which case, for later review.
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"MyUSCISgo/pkg/clock"
+)
+
+// DefaultCapacity is the number of entries a MemoryLog keeps by default
+const DefaultCapacity = 1000
+
+// ActorSystem marks entries recorded by the application itself rather than
+// on behalf of a user
+const ActorSystem = "system"
+
+// ErrInvalidEntry is returned when appending an entry without an action
+var ErrInvalidEntry = errors.New("audit entry must have an action")
+
+// Entry is a single audit record
+type Entry struct {
+	ID      string            `json:"id"`
+	Time    time.Time         `json:"time"`
+	Actor   string            `json:"actor"`
+	Action  string            `json:"action"`
+	Subject string            `json:"subject,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// Query selects audit entries. Zero fields match everything.
+type Query struct {
+	Actor   string    `json:"actor,omitempty"`
+	Action  string    `json:"action,omitempty"`
+	Subject string    `json:"subject,omitempty"`
+	Since   time.Time `json:"since,omitzero"`
+	// Limit caps the number of entries returned, keeping the most recent
+	Limit int `json:"limit,omitempty"`
+}
+
+// Matches reports whether e satisfies the query
+func (q Query) Matches(e Entry) bool {
+	if q.Actor != "" && e.Actor != q.Actor {
+		return false
+	}
+	if q.Action != "" && e.Action != q.Action {
+		return false
+	}
+	if q.Subject != "" && e.Subject != q.Subject {
+		return false
+	}
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	return true
+}
+
+// Log is an append-only audit trail. List returns entries oldest first.
+type Log interface {
+	Append(ctx context.Context, entry Entry) error
+	List(ctx context.Context, query Query) ([]Entry, error)
+}
+
+// MemoryLog is a bounded in-memory Log that drops the oldest entries once
+// full. It is safe for concurrent use.
+type MemoryLog struct {
+	mu      sync.RWMutex
+	clock   clock.Clock
+	entries []Entry
+	start   int
+	size    int
+}
+
+// MemoryOption configures a MemoryLog
+type MemoryOption func(*MemoryLog)
+
+// WithClock sets the clock used to stamp entries appended without a time
+func WithClock(c clock.Clock) MemoryOption {
+	return func(m *MemoryLog) {
+		m.clock = c
+	}
+}
+
+// NewMemoryLog creates a log holding up to capacity entries. A capacity of
+// zero or less uses DefaultCapacity.
+func NewMemoryLog(capacity int, opts ...MemoryOption) *MemoryLog {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	m := &MemoryLog{
+		clock:   clock.Real(),
+		entries: make([]Entry, capacity),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Prepare validates an entry before it is stored and fills in a missing ID,
+// time (from now) and actor. The returned entry does not share its details
+// with the original. Log implementations call it from Append.
+func Prepare(entry Entry, now time.Time) (Entry, error) {
+	if entry.Action == "" {
+		return Entry{}, ErrInvalidEntry
+	}
+	if entry.ID == "" {
+		entry.ID = newID()
+	}
+	if entry.Time.IsZero() {
+		entry.Time = now
+	}
+	entry.Time = entry.Time.UTC()
+	if entry.Actor == "" {
+		entry.Actor = ActorSystem
+	}
+	entry.Details = cloneDetails(entry.Details)
+	return entry, nil
+}
+
+// Append records an entry, filling in a missing ID, time and actor
+func (m *MemoryLog) Append(ctx context.Context, entry Entry) error {
+	entry, err := Prepare(entry, m.clock.Now())
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	idx := (m.start + m.size) % len(m.entries)
+	m.entries[idx] = entry
+	if m.size < len(m.entries) {
+		m.size++
+	} else {
+		m.start = (m.start + 1) % len(m.entries)
+	}
+	return nil
+}
+
+// List returns the entries matching query, oldest first
+func (m *MemoryLog) List(ctx context.Context, query Query) ([]Entry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []Entry
+	for i := 0; i < m.size; i++ {
+		e := m.entries[(m.start+i)%len(m.entries)]
+		if query.Matches(e) {
+			e.Details = cloneDetails(e.Details)
+			out = append(out, e)
+		}
+	}
+	if query.Limit > 0 && len(out) > query.Limit {
+		out = out[len(out)-query.Limit:]
+	}
+	return out, nil
+}
+
+// Len returns the number of entries held
+func (m *MemoryLog) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.size
+}
+
+// cloneDetails copies details so stored entries are never shared
+func cloneDetails(d map[string]string) map[string]string {
+	if d == nil {
+		return nil
+	}
+	c := make(map[string]string, len(d))
+	for k, v := range d {
+		c[k] = v
+	}
+	return c
+}
+
+// newID generates a random entry identifier
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("audit-%d", time.Now().UnixNano())
+	}
+	return "audit-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"MyUSCISgo/pkg/clock"
+)
+
+var testEpoch = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestMemoryLogAppend(t *testing.T) {
+	ctx := context.Background()
+	fc := clock.NewFake(testEpoch)
+	log := NewMemoryLog(10, WithClock(fc))
+
+	details := map[string]string{"k": "v"}
+	if err := log.Append(ctx, Entry{Action: "case.watch", Subject: "EAC2190000001", Details: details}); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	details["k"] = "changed"
+	if err := log.Append(ctx, Entry{}); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("Append(empty) error = %v, want ErrInvalidEntry", err)
+	}
+
+	entries, _ := log.List(ctx, Query{})
+	if len(entries) != 1 {
+		t.Fatalf("List() returned %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.ID == "" || e.Actor != ActorSystem || !e.Time.Equal(testEpoch) || e.Details["k"] != "v" {
+		t.Errorf("entry = %+v", e)
+	}
+	entries[0].Details["k"] = "mutated"
+	if again, _ := log.List(ctx, Query{}); again[0].Details["k"] != "v" {
+		t.Error("List() shares details with the stored entry")
+	}
+}
+
+func TestMemoryLogQuery(t *testing.T) {
+	ctx := context.Background()
+	log := NewMemoryLog(3)
+	for i := 0; i < 5; i++ {
+		err := log.Append(ctx, Entry{
+			Time:    testEpoch.Add(time.Duration(i) * time.Hour),
+			Actor:   []string{"alex", "sam"}[i%2],
+			Action:  "case.refresh",
+			Subject: fmt.Sprintf("EAC219000000%d", i),
+		})
+		if err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	if log.Len() != 3 {
+		t.Errorf("Len() = %d, want 3 after wrapping", log.Len())
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"all, oldest dropped", Query{}, []string{"EAC2190000002", "EAC2190000003", "EAC2190000004"}},
+		{"by actor", Query{Actor: "alex"}, []string{"EAC2190000002", "EAC2190000004"}},
+		{"by subject", Query{Subject: "EAC2190000003"}, []string{"EAC2190000003"}},
+		{"since", Query{Since: testEpoch.Add(3 * time.Hour)}, []string{"EAC2190000003", "EAC2190000004"}},
+		{"limit keeps newest", Query{Limit: 1}, []string{"EAC2190000004"}},
+		{"no match", Query{Action: "case.remove"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := log.List(ctx, tt.query)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Subject)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("List() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
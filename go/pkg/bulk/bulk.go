@@ -0,0 +1,60 @@
+// Package bulk imports lists of receipt numbers into the watchlist and
+// exports tracked cases, their timelines and decision estimates as CSV,
+// JSON or newline-delimited JSON.
+package bulk
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Format is a file format for import and export
+type Format string
+
+const (
+	// FormatCSV is comma-separated values with a header row
+	FormatCSV Format = "csv"
+	// FormatJSON is a single JSON array
+	FormatJSON Format = "json"
+	// FormatNDJSON is one JSON value per line
+	FormatNDJSON Format = "ndjson"
+)
+
+// ErrUnknownFormat is returned for a format other than csv, json or ndjson
+var ErrUnknownFormat = errors.New("unknown format")
+
+// ParseFormat parses a format name, accepting "jsonl" for NDJSON
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case FormatCSV, FormatJSON, FormatNDJSON:
+		return f, nil
+	case "jsonl":
+		return FormatNDJSON, nil
+	default:
+		return "", fmt.Errorf("%w %q (expected csv, json or ndjson)", ErrUnknownFormat, s)
+	}
+}
+
+// FormatForPath picks a format from a file extension
+func FormatForPath(path string) (Format, error) {
+	return ParseFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// MIMEType returns the media type of files in the format
+func (f Format) MIMEType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "application/json"
+	}
+}
+
+// Extension returns the file extension for the format, without the dot
+func (f Format) Extension() string {
+	return string(f)
+}
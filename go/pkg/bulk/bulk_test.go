@@ -0,0 +1,318 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"MyUSCISgo/pkg/estimate"
+	"MyUSCISgo/pkg/types"
+	"MyUSCISgo/pkg/watchlist"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Format
+		wantErr bool
+	}{
+		{"csv", FormatCSV, false},
+		{" JSON ", FormatJSON, false},
+		{"ndjson", FormatNDJSON, false},
+		{"jsonl", FormatNDJSON, false},
+		{"xlsx", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if f, err := FormatForPath("/tmp/cases.NDJSON"); err != nil || f != FormatNDJSON {
+		t.Errorf("FormatForPath() = %q, %v", f, err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   Format
+		input    string
+		wantRows []Row
+		wantErrs []RowError
+	}{
+		{
+			name:   "csv with header",
+			format: FormatCSV,
+			input: "Owner,Receipt Number,Label\n" +
+				"ana@example.com, ioe-0912345678 ,Mine\n" +
+				"# a comment\n" +
+				"\n" +
+				"bo,EAC2190000001,\n",
+			wantRows: []Row{
+				{Line: 2, ReceiptNumber: "IOE0912345678", Label: "Mine", Owner: "ana@example.com"},
+				{Line: 5, ReceiptNumber: "EAC2190000001", Owner: "bo"},
+			},
+		},
+		{
+			name:   "csv without header",
+			format: FormatCSV,
+			input:  "IOE0912345678,Mine,call lawyer\nWAC2190000002\n",
+			wantRows: []Row{
+				{Line: 1, ReceiptNumber: "IOE0912345678", Label: "Mine", Notes: "call lawyer"},
+				{Line: 2, ReceiptNumber: "WAC2190000002"},
+			},
+		},
+		{
+			name:   "csv row errors",
+			format: FormatCSV,
+			input:  "receipt\nXYZ0912345678\nIOE0912345678\nIOE-0912345678\nEAC21\"9\n",
+			wantRows: []Row{
+				{Line: 3, ReceiptNumber: "IOE0912345678"},
+			},
+			wantErrs: []RowError{
+				{Line: 2, Input: "XYZ0912345678"},
+				{Line: 4, Input: "IOE-0912345678", Message: "duplicate of row 3"},
+				{Line: 5},
+			},
+		},
+		{
+			name:   "json strings and objects",
+			format: FormatJSON,
+			input:  `["IOE0912345678", {"receiptNumber": "EAC2190000001", "label": "Dad", "owner": "ana"}, 42, "nope"]`,
+			wantRows: []Row{
+				{Line: 1, ReceiptNumber: "IOE0912345678"},
+				{Line: 2, ReceiptNumber: "EAC2190000001", Label: "Dad", Owner: "ana"},
+			},
+			wantErrs: []RowError{
+				{Line: 3, Message: "expected a receipt number or an object with a receiptNumber field"},
+				{Line: 4, Input: "nope"},
+			},
+		},
+		{
+			name:   "ndjson",
+			format: FormatNDJSON,
+			input:  "\"IOE0912345678\"\n\n{\"receiptNumber\":\"EAC2190000001\",\"notes\":\" n \"}\n{bad\n",
+			wantRows: []Row{
+				{Line: 1, ReceiptNumber: "IOE0912345678"},
+				{Line: 3, ReceiptNumber: "EAC2190000001", Notes: "n"},
+			},
+			wantErrs: []RowError{
+				{Line: 4, Message: "expected a receipt number or an object with a receiptNumber field"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch, err := Parse(strings.NewReader(tt.input), tt.format)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if fmt.Sprint(batch.Rows) != fmt.Sprint(tt.wantRows) {
+				t.Errorf("Rows = %+v\nwant %+v", batch.Rows, tt.wantRows)
+			}
+			if len(batch.Errors) != len(tt.wantErrs) {
+				t.Fatalf("Errors = %+v, want %d", batch.Errors, len(tt.wantErrs))
+			}
+			for i, want := range tt.wantErrs {
+				got := batch.Errors[i]
+				if got.Line != want.Line || got.Input != want.Input || got.Message == "" ||
+					(want.Message != "" && got.Message != want.Message) {
+					t.Errorf("Errors[%d] = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	input := "IOE0912345678\nIOE0912345679\nIOE0912345680\n"
+	if _, err := Parse(strings.NewReader(input), FormatCSV, WithMaxRows(2)); !errors.Is(err, ErrTooManyRows) {
+		t.Errorf("Parse() error = %v, want ErrTooManyRows", err)
+	}
+	if _, err := Parse(strings.NewReader(`{"not": "an array"}`), FormatJSON); err == nil {
+		t.Errorf("Parse() accepted a JSON object")
+	}
+	if _, err := Parse(strings.NewReader(input), "xml"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Parse() error = %v, want ErrUnknownFormat", err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	svc := watchlist.NewService(watchlist.NewMemoryStorage())
+	if _, err := svc.Add(ctx, watchlist.AddRequest{ReceiptNumber: "EAC2190000001"}); err != nil {
+		t.Fatal(err)
+	}
+	batch, err := Parse(strings.NewReader("IOE0912345678,Mine\nEAC2190000001\nbad\n"), FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dry := batch.Apply(ctx, svc, true)
+	if len(dry.Added) != 1 || svc.Contains(ctx, "IOE0912345678") {
+		t.Errorf("dry run = %+v, and the case was added: %v", dry, svc.Contains(ctx, "IOE0912345678"))
+	}
+
+	report := batch.Apply(ctx, svc, false)
+	if report.Rows != 3 || fmt.Sprint(report.Added) != "[IOE0912345678]" {
+		t.Errorf("report = %+v", report)
+	}
+	if len(report.Skipped) != 1 || report.Skipped[0].Line != 2 {
+		t.Errorf("Skipped = %+v, want row 2", report.Skipped)
+	}
+	if !report.Failed() || report.Errors[0].Line != 3 {
+		t.Errorf("Errors = %+v, want row 3", report.Errors)
+	}
+	if entry, err := svc.Get(ctx, "IOE0912345678"); err != nil || entry.Label != "Mine" {
+		t.Errorf("Get() = %+v, %v", entry, err)
+	}
+}
+
+func testCases() []Case {
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC) }
+	status := types.ParseCaseStatus("Request for Evidence Was Sent")
+	return []Case{
+		{
+			Entry: &watchlist.Entry{
+				ReceiptNumber: "IOE0912345678",
+				Label:         "=HYPERLINK(\"x\") for A123456789",
+				Owner:         "ana.lopez@example.com",
+				Notes:         "A-Number A-123-456-789",
+				FormType:      "I-485",
+				LastStatus:    &status,
+				Timeline: types.NewCaseTimeline("IOE0912345678",
+					types.NewTimelineEvent(day(2), "Case Was Received", types.SourceHistory),
+					types.NewTimelineEvent(day(20), "Request for Evidence Was Sent", types.SourceCurrent)),
+				AddedAt: day(1),
+			},
+			Estimate: &estimate.Estimate{LikelyAt: day(30), Phase: estimate.PhaseEarly, PercentElapsed: 12.5},
+		},
+		{
+			Entry: &watchlist.Entry{ReceiptNumber: "EAC2190000001", AddedAt: day(3)},
+		},
+	}
+}
+
+func export(t *testing.T, format Format, cases []Case, opts ...ExportOption) string {
+	t.Helper()
+	e, err := NewExporter(format, opts...)
+	if err != nil {
+		t.Fatalf("NewExporter() error = %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err := e.Export(&buf, cases); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	return buf.String()
+}
+
+func TestExportCSV(t *testing.T) {
+	got := export(t, FormatCSV, testCases(),
+		WithFields(FieldReceipt, FieldLabel, FieldStatusCode, FieldEvents, FieldLastEventAt, FieldEstimateLikely, FieldPercentElapsed))
+	want := "receiptNumber,label,statusCode,events,lastEventAt,estimateLikely,percentElapsed\n" +
+		"IOE0912345678,\"'=HYPERLINK(\"\"x\"\") for A123456789\",rfe_sent,2,2025-01-20T00:00:00Z,2025-01-30T00:00:00Z,12.5\n" +
+		"EAC2190000001,,,0,,,\n"
+	if got != want {
+		t.Errorf("Export() =\n%s\nwant\n%s", got, want)
+	}
+
+	if empty := export(t, FormatCSV, nil, WithFields(FieldReceipt)); empty != "receiptNumber\n" {
+		t.Errorf("Export() with no cases = %q, want just the header", empty)
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	got := export(t, FormatJSON, testCases(), WithFields(FieldReceipt, FieldStage, FieldEstimatePhase, FieldTimeline))
+	var rows []map[string]any
+	if err := json.Unmarshal([]byte(got), &rows); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, got)
+	}
+	if len(rows) != 2 || rows[0]["stage"] != "rfe" || rows[1]["stage"] != nil {
+		t.Fatalf("rows = %v", rows)
+	}
+	if rows[0]["estimatePhase"] != "early" || rows[1]["estimatePhase"] != nil {
+		t.Errorf("estimatePhase = %v, %v", rows[0]["estimatePhase"], rows[1]["estimatePhase"])
+	}
+	if events, ok := rows[0]["timeline"].([]any); !ok || len(events) != 2 {
+		t.Errorf("timeline = %v", rows[0]["timeline"])
+	}
+	if !strings.HasPrefix(got, `[`+"\n"+`  {"receiptNumber":"IOE0912345678","stage":`) {
+		t.Errorf("fields are not written in order:\n%s", got)
+	}
+
+	if empty := export(t, FormatJSON, nil); empty != "[]\n" {
+		t.Errorf("Export() with no cases = %q", empty)
+	}
+}
+
+func TestExportNDJSONEvents(t *testing.T) {
+	got := export(t, FormatNDJSON, testCases(), WithEvents(), WithMask(MaskReceipts))
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want one per event:\n%s", len(lines), got)
+	}
+	want := `{"receiptNumber":"IOE******5678","label":"=HYPERLINK(\"x\") for A123456789","eventDate":"2025-01-02T00:00:00Z","eventStatus":"Case Was Received","eventCode":"case_received","eventSource":"history"}`
+	if lines[0] != want {
+		t.Errorf("line 1 = %s\nwant     %s", lines[0], want)
+	}
+
+	if _, err := NewExporter(FormatCSV, WithFields(FieldEventDate)); err == nil {
+		t.Errorf("NewExporter() accepted an event field without WithEvents")
+	}
+	if _, err := NewExporter(FormatCSV, WithFields("ssn")); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("NewExporter() error = %v, want ErrUnknownField", err)
+	}
+}
+
+func TestExportMasking(t *testing.T) {
+	fields := WithFields(FieldReceipt, FieldLabel, FieldOwner, FieldNotes)
+	tests := []struct {
+		mask Mask
+		want string
+	}{
+		{MaskNone, `{"receiptNumber":"IOE0912345678","label":"=HYPERLINK(\"x\") for A123456789","owner":"ana.lopez@example.com","notes":"A-Number A-123-456-789"}`},
+		{MaskOwners | MaskIdentifiers, `{"receiptNumber":"IOE0912345678","label":"=HYPERLINK(\"x\") for A*****6789","owner":"a***@example.com","notes":"A-Number A*****6789"}`},
+		{MaskAll, `{"receiptNumber":"IOE******5678","label":"=HYPERLINK(\"x\") for A*****6789","owner":"a***@example.com","notes":null}`},
+	}
+	for _, tt := range tests {
+		got := strings.SplitN(export(t, FormatNDJSON, testCases(), fields, WithMask(tt.mask)), "\n", 2)[0]
+		if got != tt.want {
+			t.Errorf("mask %s:\n got %s\nwant %s", tt.mask, got, tt.want)
+		}
+	}
+}
+
+func TestParseMaskAndFields(t *testing.T) {
+	masks := []struct {
+		list string
+		want Mask
+	}{
+		{"", MaskIdentifiers},
+		{"receipts, owners", MaskReceipts | MaskOwners | MaskIdentifiers},
+		{"none", MaskNone},
+		{"owners,None", MaskOwners},
+	}
+	for _, tt := range masks {
+		if m, err := ParseMask(tt.list); err != nil || m != tt.want {
+			t.Errorf("ParseMask(%q) = %v, %v; want %v", tt.list, m, err, tt.want)
+		}
+	}
+	if m, err := ParseMask("all"); err != nil || m != MaskAll {
+		t.Errorf("ParseMask(all) = %v, %v", m, err)
+	}
+	if _, err := ParseMask("ssn"); err == nil {
+		t.Errorf("ParseMask() accepted an unknown mask")
+	}
+	if f, err := ParseFields("receiptNumber, status,,"); err != nil || len(f) != 2 {
+		t.Errorf("ParseFields() = %v, %v", f, err)
+	}
+	if _, err := ParseFields("receiptNumber,ssn"); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("ParseFields() error = %v, want ErrUnknownField", err)
+	}
+}
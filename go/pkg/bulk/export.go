@@ -0,0 +1,500 @@
+package bulk
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"MyUSCISgo/pkg/estimate"
+	"MyUSCISgo/pkg/forms"
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/types"
+	"MyUSCISgo/pkg/watchlist"
+)
+
+// Field is an exported column
+type Field string
+
+// Case fields, one row per case
+const (
+	FieldReceipt        Field = "receiptNumber"
+	FieldLabel          Field = "label"
+	FieldOwner          Field = "owner"
+	FieldNotes          Field = "notes"
+	FieldForm           Field = "formType"
+	FieldServiceCenter  Field = "serviceCenter"
+	FieldStatus         Field = "status"
+	FieldStatusCode     Field = "statusCode"
+	FieldStage          Field = "stage"
+	FieldActionRequired Field = "actionRequired"
+	FieldAddedAt        Field = "addedAt"
+	FieldUpdatedAt      Field = "updatedAt"
+	FieldLastCheckedAt  Field = "lastCheckedAt"
+	FieldEvents         Field = "events"
+	FieldLastEventAt    Field = "lastEventAt"
+	FieldTimeline       Field = "timeline"
+	FieldEstimateMedian Field = "estimateMedian"
+	FieldEstimateLikely Field = "estimateLikely"
+	FieldEstimateLatest Field = "estimateLatest"
+	FieldEstimatePhase  Field = "estimatePhase"
+	FieldPercentElapsed Field = "percentElapsed"
+)
+
+// Event fields, one row per timeline event with WithEvents
+const (
+	FieldEventDate   Field = "eventDate"
+	FieldEventStatus Field = "eventStatus"
+	FieldEventCode   Field = "eventCode"
+	FieldEventSource Field = "eventSource"
+)
+
+// DefaultFields are exported when no fields are selected
+var DefaultFields = []Field{
+	FieldReceipt, FieldLabel, FieldOwner, FieldForm, FieldServiceCenter,
+	FieldStatus, FieldStage, FieldActionRequired, FieldLastEventAt,
+	FieldEstimateLikely, FieldEstimatePhase,
+}
+
+// DefaultEventFields are exported with WithEvents when no fields are selected
+var DefaultEventFields = []Field{
+	FieldReceipt, FieldLabel, FieldEventDate, FieldEventStatus, FieldEventCode, FieldEventSource,
+}
+
+// ErrUnknownField is returned for a field that cannot be exported
+var ErrUnknownField = errors.New("unknown field")
+
+// Case is a tracked case with its decision estimate, if any
+type Case struct {
+	Entry    *watchlist.Entry
+	Estimate *estimate.Estimate
+}
+
+// Cases pairs watchlist entries with estimates from est, which may be nil
+func Cases(entries []*watchlist.Entry, est *estimate.Estimator) []Case {
+	cases := make([]Case, len(entries))
+	for i, e := range entries {
+		cases[i] = Case{Entry: e, Estimate: estimateFor(e, est)}
+	}
+	return cases
+}
+
+// estimateFor estimates the decision window of a watched case that has not
+// been decided
+func estimateFor(e *watchlist.Entry, est *estimate.Estimator) *estimate.Estimate {
+	if est == nil {
+		return nil
+	}
+	if record := e.Record(); record != nil && record.Decided() {
+		return nil
+	}
+	form, ok := forms.ParseCaseType(e.FormType)
+	if !ok {
+		return nil
+	}
+	parsed, err := receipt.Parse(e.ReceiptNumber)
+	if err != nil {
+		return nil
+	}
+	req := estimate.Request{Receipt: parsed, Form: form.ID}
+	if e.Timeline != nil {
+		if received := e.Timeline.EventsWithStatus(types.StatusReceived); len(received) > 0 {
+			req.ReceivedAt = received[0].Date
+		}
+	}
+	result, err := est.Estimate(req)
+	if err != nil {
+		return nil
+	}
+	return result
+}
+
+// field extracts a column from a case and, for event rows, an event
+type field struct {
+	event bool
+	value func(c Case, ev *types.TimelineEvent, m Mask) any
+}
+
+func fromEstimate(get func(*estimate.Estimate) any) func(Case, *types.TimelineEvent, Mask) any {
+	return func(c Case, _ *types.TimelineEvent, _ Mask) any {
+		if c.Estimate == nil {
+			return nil
+		}
+		return get(c.Estimate)
+	}
+}
+
+func fromStatus(get func(*types.CaseStatus) any) func(Case, *types.TimelineEvent, Mask) any {
+	return func(c Case, _ *types.TimelineEvent, _ Mask) any {
+		if s := c.Entry.LastStatus; s != nil {
+			return get(s)
+		}
+		return nil
+	}
+}
+
+func fromEvent(get func(*types.TimelineEvent) any) func(Case, *types.TimelineEvent, Mask) any {
+	return func(_ Case, ev *types.TimelineEvent, _ Mask) any {
+		return get(ev)
+	}
+}
+
+var fields = map[Field]field{
+	FieldReceipt: {value: func(c Case, _ *types.TimelineEvent, m Mask) any {
+		if m&MaskReceipts != 0 {
+			return maskReceipt(c.Entry.ReceiptNumber)
+		}
+		return c.Entry.ReceiptNumber
+	}},
+	FieldLabel: {value: func(c Case, _ *types.TimelineEvent, m Mask) any {
+		if m&MaskIdentifiers != 0 {
+			return maskText(c.Entry.Label)
+		}
+		return c.Entry.Label
+	}},
+	FieldOwner: {value: func(c Case, _ *types.TimelineEvent, m Mask) any {
+		if m&MaskOwners != 0 {
+			return maskOwner(c.Entry.Owner)
+		}
+		return c.Entry.Owner
+	}},
+	FieldNotes: {value: func(c Case, _ *types.TimelineEvent, m Mask) any {
+		switch {
+		case m&MaskNotes != 0:
+			return nil
+		case m&MaskIdentifiers != 0:
+			return maskText(c.Entry.Notes)
+		}
+		return c.Entry.Notes
+	}},
+	FieldForm:          {value: func(c Case, _ *types.TimelineEvent, _ Mask) any { return c.Entry.FormType }},
+	FieldServiceCenter: {value: func(c Case, _ *types.TimelineEvent, _ Mask) any { return c.Entry.ServiceCenter }},
+	FieldStatus:        {value: fromStatus(func(s *types.CaseStatus) any { return s.Title })},
+	FieldStatusCode:    {value: fromStatus(func(s *types.CaseStatus) any { return string(s.Code) })},
+	FieldStage:         {value: fromStatus(func(s *types.CaseStatus) any { return string(s.Stage) })},
+	FieldActionRequired: {value: fromStatus(func(s *types.CaseStatus) any {
+		return s.ActionRequired
+	})},
+	FieldAddedAt:       {value: func(c Case, _ *types.TimelineEvent, _ Mask) any { return c.Entry.AddedAt }},
+	FieldUpdatedAt:     {value: func(c Case, _ *types.TimelineEvent, _ Mask) any { return c.Entry.UpdatedAt }},
+	FieldLastCheckedAt: {value: func(c Case, _ *types.TimelineEvent, _ Mask) any { return c.Entry.LastCheckedAt }},
+	FieldEvents: {value: func(c Case, _ *types.TimelineEvent, _ Mask) any {
+		return c.Entry.Timeline.Len()
+	}},
+	FieldLastEventAt: {value: func(c Case, _ *types.TimelineEvent, _ Mask) any {
+		if latest, ok := c.Entry.Timeline.Latest(); ok {
+			return latest.Date
+		}
+		return nil
+	}},
+	FieldTimeline: {value: func(c Case, _ *types.TimelineEvent, _ Mask) any {
+		if c.Entry.Timeline == nil {
+			return []types.TimelineEvent{}
+		}
+		return c.Entry.Timeline.Events
+	}},
+	FieldEstimateMedian: {value: fromEstimate(func(e *estimate.Estimate) any { return e.MedianAt })},
+	FieldEstimateLikely: {value: fromEstimate(func(e *estimate.Estimate) any { return e.LikelyAt })},
+	FieldEstimateLatest: {value: fromEstimate(func(e *estimate.Estimate) any { return e.LatestAt })},
+	FieldEstimatePhase:  {value: fromEstimate(func(e *estimate.Estimate) any { return string(e.Phase) })},
+	FieldPercentElapsed: {value: fromEstimate(func(e *estimate.Estimate) any { return e.PercentElapsed })},
+
+	FieldEventDate:   {event: true, value: fromEvent(func(ev *types.TimelineEvent) any { return ev.Date })},
+	FieldEventStatus: {event: true, value: fromEvent(func(ev *types.TimelineEvent) any { return ev.Status.Title })},
+	FieldEventCode:   {event: true, value: fromEvent(func(ev *types.TimelineEvent) any { return string(ev.Status.Code) })},
+	FieldEventSource: {event: true, value: fromEvent(func(ev *types.TimelineEvent) any { return string(ev.Source) })},
+}
+
+// ParseFields parses a comma-separated list of field names
+func ParseFields(s string) ([]Field, error) {
+	var out []Field
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := fields[Field(name)]; !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownField, name)
+		}
+		out = append(out, Field(name))
+	}
+	return out, nil
+}
+
+// Exporter writes cases in a format
+type Exporter struct {
+	format Format
+	fields []Field
+	mask   Mask
+	events bool
+}
+
+// ExportOption configures an Exporter
+type ExportOption func(*Exporter)
+
+// WithFields selects and orders the exported fields
+func WithFields(fields ...Field) ExportOption {
+	return func(e *Exporter) {
+		e.fields = append([]Field(nil), fields...)
+	}
+}
+
+// WithMask hides personal data
+func WithMask(m Mask) ExportOption {
+	return func(e *Exporter) {
+		e.mask = m
+	}
+}
+
+// WithEvents writes one row per timeline event instead of one per case,
+// which allows the event fields
+func WithEvents() ExportOption {
+	return func(e *Exporter) {
+		e.events = true
+	}
+}
+
+// NewExporter creates an exporter, checking the selected fields
+func NewExporter(format Format, opts ...ExportOption) (*Exporter, error) {
+	if _, err := ParseFormat(string(format)); err != nil {
+		return nil, err
+	}
+	e := &Exporter{format: format}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if len(e.fields) == 0 {
+		e.fields = DefaultFields
+		if e.events {
+			e.fields = DefaultEventFields
+		}
+	}
+	for _, f := range e.fields {
+		spec, ok := fields[f]
+		if !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownField, f)
+		}
+		if spec.event && !e.events {
+			return nil, fmt.Errorf("field %q is only available when exporting events", f)
+		}
+	}
+	return e, nil
+}
+
+// Format returns the output format
+func (e *Exporter) Format() Format {
+	return e.format
+}
+
+// Fields returns the exported fields in order
+func (e *Exporter) Fields() []Field {
+	return append([]Field(nil), e.fields...)
+}
+
+// Export writes every case and returns the number of rows written
+func (e *Exporter) Export(w io.Writer, cases []Case) (int, error) {
+	out := e.NewWriter(w)
+	for _, c := range cases {
+		if err := out.Write(c); err != nil {
+			return out.Rows(), err
+		}
+	}
+	return out.Rows(), out.Close()
+}
+
+// NewWriter returns a Writer that streams rows to w
+func (e *Exporter) NewWriter(w io.Writer) *Writer {
+	buf := bufio.NewWriter(w)
+	out := &Writer{e: e, w: buf}
+	if e.format == FormatCSV {
+		out.csv = csv.NewWriter(buf)
+	}
+	return out
+}
+
+// Writer streams exported rows. NDJSON rows are flushed as they are
+// written; Close must be called to finish the output.
+type Writer struct {
+	e       *Exporter
+	w       *bufio.Writer
+	csv     *csv.Writer
+	rows    int
+	started bool
+}
+
+// Rows returns the number of rows written so far
+func (w *Writer) Rows() int {
+	return w.rows
+}
+
+// start writes the CSV header or the opening of a JSON array
+func (w *Writer) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	switch w.e.format {
+	case FormatCSV:
+		header := make([]string, len(w.e.fields))
+		for i, f := range w.e.fields {
+			header[i] = string(f)
+		}
+		return w.csv.Write(header)
+	case FormatJSON:
+		_, err := w.w.WriteString("[")
+		return err
+	}
+	return nil
+}
+
+// Write writes a case, or one row per timeline event with WithEvents
+func (w *Writer) Write(c Case) error {
+	if c.Entry == nil {
+		return errors.New("case has no watchlist entry")
+	}
+	if err := w.start(); err != nil {
+		return err
+	}
+	if !w.e.events {
+		return w.row(c, nil)
+	}
+	if c.Entry.Timeline == nil {
+		return nil
+	}
+	for i := range c.Entry.Timeline.Events {
+		if err := w.row(c, &c.Entry.Timeline.Events[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// row writes one row
+func (w *Writer) row(c Case, ev *types.TimelineEvent) error {
+	values := make([]any, len(w.e.fields))
+	for i, f := range w.e.fields {
+		values[i] = fields[f].value(c, ev, w.e.mask)
+	}
+
+	var err error
+	switch w.e.format {
+	case FormatCSV:
+		err = w.csvRow(values)
+	case FormatJSON:
+		if w.rows > 0 {
+			if _, err := w.w.WriteString(","); err != nil {
+				return err
+			}
+		}
+		if _, err := w.w.WriteString("\n  "); err != nil {
+			return err
+		}
+		err = w.object(values)
+	case FormatNDJSON:
+		if err = w.object(values); err == nil {
+			if err = w.w.WriteByte('\n'); err == nil {
+				err = w.w.Flush()
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	w.rows++
+	return nil
+}
+
+// object writes values as a JSON object with the fields in order
+func (w *Writer) object(values []any) error {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, f := range w.e.fields {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(string(f))
+		b.Write(key)
+		b.WriteByte(':')
+		v := values[i]
+		if t, ok := v.(time.Time); ok && t.IsZero() {
+			v = nil
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", f, err)
+		}
+		b.Write(data)
+	}
+	b.WriteByte('}')
+	_, err := w.w.WriteString(b.String())
+	return err
+}
+
+// csvRow writes values as CSV cells
+func (w *Writer) csvRow(values []any) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		cell, err := csvCell(v)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", w.e.fields[i], err)
+		}
+		record[i] = cell
+	}
+	return w.csv.Write(record)
+}
+
+// csvCell formats a value for a CSV cell. Text that a spreadsheet would
+// run as a formula is prefixed with an apostrophe.
+func csvCell(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			return "'" + v, nil
+		}
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		if v.IsZero() {
+			return "", nil
+		}
+		return v.UTC().Format(time.RFC3339), nil
+	default:
+		data, err := json.Marshal(v)
+		return string(data), err
+	}
+}
+
+// Close finishes the output and flushes it
+func (w *Writer) Close() error {
+	if err := w.start(); err != nil {
+		return err
+	}
+	switch w.e.format {
+	case FormatCSV:
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	case FormatJSON:
+		closing := "]\n"
+		if w.rows > 0 {
+			closing = "\n]\n"
+		}
+		if _, err := w.w.WriteString(closing); err != nil {
+			return err
+		}
+	}
+	return w.w.Flush()
+}
@@ -0,0 +1,335 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/watchlist"
+)
+
+// DefaultMaxRows is the largest import accepted by default
+const DefaultMaxRows = 5000
+
+// ErrTooManyRows is returned when an import exceeds its row limit
+var ErrTooManyRows = errors.New("too many rows")
+
+// Row is a valid receipt read from an import, with its normalized number
+type Row struct {
+	Line          int    `json:"line"`
+	ReceiptNumber string `json:"receiptNumber"`
+	Label         string `json:"label,omitempty"`
+	Notes         string `json:"notes,omitempty"`
+	Owner         string `json:"owner,omitempty"`
+}
+
+// RowError explains why a row was not imported. Line is the line in a CSV
+// or NDJSON file and the 1-based element in a JSON array.
+type RowError struct {
+	Line    int    `json:"line"`
+	Input   string `json:"input,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Line, e.Message)
+}
+
+// Batch is the result of parsing an import
+type Batch struct {
+	Rows   []Row      `json:"rows"`
+	Errors []RowError `json:"errors,omitempty"`
+}
+
+// ImportOption configures Parse
+type ImportOption func(*importer)
+
+// WithMaxRows caps the number of rows read; zero means no limit
+func WithMaxRows(n int) ImportOption {
+	return func(imp *importer) {
+		imp.maxRows = n
+	}
+}
+
+// importer collects rows, validating receipts and dropping duplicates
+type importer struct {
+	maxRows int
+	batch   Batch
+	seen    map[string]int
+	rows    int
+}
+
+// Parse reads receipts from a CSV, JSON or NDJSON import. Rows with an
+// invalid or repeated receipt number are reported in Batch.Errors and the
+// rest are returned; an error is only returned when the input as a whole
+// cannot be read.
+//
+// CSV files may start with a header naming the receipt, label, notes and
+// owner columns in any order; without one the columns are taken in that
+// order. Lines starting with # are comments. JSON and NDJSON rows are
+// either receipt strings or objects with receiptNumber, label, notes and
+// owner fields.
+func Parse(r io.Reader, format Format, opts ...ImportOption) (*Batch, error) {
+	imp := &importer{maxRows: DefaultMaxRows, seen: make(map[string]int)}
+	for _, opt := range opts {
+		opt(imp)
+	}
+
+	var err error
+	switch format {
+	case FormatCSV:
+		err = imp.parseCSV(r)
+	case FormatJSON:
+		err = imp.parseJSON(r)
+	case FormatNDJSON:
+		err = imp.parseNDJSON(r)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &imp.batch, nil
+}
+
+// add validates one row
+func (imp *importer) add(line int, in jsonRow) error {
+	imp.rows++
+	if imp.maxRows > 0 && imp.rows > imp.maxRows {
+		return fmt.Errorf("%w: the limit is %d", ErrTooManyRows, imp.maxRows)
+	}
+
+	parsed, err := receipt.Parse(in.ReceiptNumber)
+	if err != nil {
+		message := err.Error()
+		var parseErr *receipt.ParseError
+		if errors.As(err, &parseErr) {
+			message = parseErr.Message
+		}
+		imp.batch.Errors = append(imp.batch.Errors, RowError{Line: line, Input: in.ReceiptNumber, Message: message})
+		return nil
+	}
+	if first, ok := imp.seen[parsed.Number]; ok {
+		imp.batch.Errors = append(imp.batch.Errors, RowError{
+			Line:    line,
+			Input:   in.ReceiptNumber,
+			Message: fmt.Sprintf("duplicate of row %d", first),
+		})
+		return nil
+	}
+	imp.seen[parsed.Number] = line
+	imp.batch.Rows = append(imp.batch.Rows, Row{
+		Line:          line,
+		ReceiptNumber: parsed.Number,
+		Label:         strings.TrimSpace(in.Label),
+		Notes:         strings.TrimSpace(in.Notes),
+		Owner:         strings.TrimSpace(in.Owner),
+	})
+	return nil
+}
+
+// columnAliases maps header names, lowercased with separators removed, to
+// the column they hold
+var columnAliases = map[string]string{
+	"receipt":       "receipt",
+	"receiptnumber": "receipt",
+	"receiptno":     "receipt",
+	"case":          "receipt",
+	"casenumber":    "receipt",
+	"label":         "label",
+	"name":          "label",
+	"nickname":      "label",
+	"notes":         "notes",
+	"note":          "notes",
+	"comment":       "notes",
+	"comments":      "notes",
+	"owner":         "owner",
+	"assignee":      "owner",
+	"email":         "owner",
+}
+
+// headerColumns maps a header row to column positions, or reports false
+// when the row is data
+func headerColumns(record []string) (map[string]int, bool) {
+	columns := make(map[string]int)
+	for i, name := range record {
+		key := strings.NewReplacer("_", "", "-", "", " ", "", ".", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+		if column, ok := columnAliases[key]; ok {
+			if _, dup := columns[column]; !dup {
+				columns[column] = i
+			}
+		}
+	}
+	_, ok := columns["receipt"]
+	return columns, ok
+}
+
+func (imp *importer) parseCSV(r io.Reader) error {
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	columns := map[string]int{"receipt": 0, "label": 1, "notes": 2, "owner": 3}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			imp.rows++
+			imp.batch.Errors = append(imp.batch.Errors, RowError{Line: parseErr.Line, Message: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read receipts CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if first {
+			if header, ok := headerColumns(record); ok {
+				columns = header
+				continue
+			}
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		row := jsonRow{
+			ReceiptNumber: field(record, "receipt"),
+			Label:         field(record, "label"),
+			Notes:         field(record, "notes"),
+			Owner:         field(record, "owner"),
+		}
+		if err := imp.add(line, row); err != nil {
+			return err
+		}
+	}
+}
+
+// jsonRow is an imported row, written as an object or a bare receipt string
+type jsonRow struct {
+	ReceiptNumber string `json:"receiptNumber"`
+	Label         string `json:"label"`
+	Notes         string `json:"notes"`
+	Owner         string `json:"owner"`
+}
+
+func (r *jsonRow) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &r.ReceiptNumber)
+	}
+	type plain jsonRow
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+func (imp *importer) parseJSON(r io.Reader) error {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return fmt.Errorf("failed to parse receipts JSON: expected an array: %w", err)
+	}
+	for i, data := range raw {
+		if err := imp.addJSON(i+1, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *importer) parseNDJSON(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 || data[0] == '#' {
+			continue
+		}
+		if err := imp.addJSON(line, data); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read receipts NDJSON: %w", err)
+	}
+	return nil
+}
+
+// addJSON decodes one JSON row, reporting malformed rows as row errors
+func (imp *importer) addJSON(line int, data []byte) error {
+	var row jsonRow
+	if err := json.Unmarshal(data, &row); err != nil {
+		imp.rows++
+		imp.batch.Errors = append(imp.batch.Errors, RowError{
+			Line:    line,
+			Message: "expected a receipt number or an object with a receiptNumber field",
+		})
+		return nil
+	}
+	return imp.add(line, row)
+}
+
+// Report describes the outcome of applying a batch to the watchlist
+type Report struct {
+	// Rows is the number of data rows read, valid or not
+	Rows    int        `json:"rows"`
+	Added   []string   `json:"added"`
+	Skipped []RowError `json:"skipped,omitempty"`
+	Errors  []RowError `json:"errors,omitempty"`
+}
+
+// Failed reports whether any row could not be imported
+func (r *Report) Failed() bool {
+	return len(r.Errors) > 0
+}
+
+// count returns the number of data rows in the batch, valid or not
+func (b *Batch) count() int {
+	return len(b.Rows) + len(b.Errors)
+}
+
+// Apply adds the batch's receipts to the watchlist. Receipts that are
+// already watched are skipped, and with dryRun nothing is added.
+func (b *Batch) Apply(ctx context.Context, svc *watchlist.Service, dryRun bool) *Report {
+	report := &Report{Rows: b.count(), Added: []string{}, Errors: append([]RowError(nil), b.Errors...)}
+	for _, row := range b.Rows {
+		if err := ctx.Err(); err != nil {
+			report.Errors = append(report.Errors, RowError{Line: row.Line, Input: row.ReceiptNumber, Message: err.Error()})
+			continue
+		}
+		if svc.Contains(ctx, row.ReceiptNumber) {
+			report.Skipped = append(report.Skipped, RowError{Line: row.Line, Input: row.ReceiptNumber, Message: "already watched"})
+			continue
+		}
+		if dryRun {
+			report.Added = append(report.Added, row.ReceiptNumber)
+			continue
+		}
+		_, err := svc.Add(ctx, watchlist.AddRequest{
+			ReceiptNumber: row.ReceiptNumber,
+			Label:         row.Label,
+			Notes:         row.Notes,
+			Owner:         row.Owner,
+		})
+		if err != nil {
+			report.Errors = append(report.Errors, RowError{Line: row.Line, Input: row.ReceiptNumber, Message: err.Error()})
+			continue
+		}
+		report.Added = append(report.Added, row.ReceiptNumber)
+	}
+	return report
+}
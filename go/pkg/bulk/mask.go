@@ -0,0 +1,123 @@
+package bulk
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"MyUSCISgo/pkg/validation"
+)
+
+// Mask selects personal data to hide in an export
+type Mask uint8
+
+const (
+	// MaskReceipts hides all but the service center and last four digits
+	// of receipt numbers
+	MaskReceipts Mask = 1 << iota
+	// MaskOwners hides owner names and the local part of owner emails
+	MaskOwners
+	// MaskNotes leaves free-text notes out entirely
+	MaskNotes
+	// MaskIdentifiers masks A-Numbers written in labels and notes
+	MaskIdentifiers
+
+	// MaskNone exports everything as stored
+	MaskNone Mask = 0
+	// MaskAll applies every mask
+	MaskAll = MaskReceipts | MaskOwners | MaskNotes | MaskIdentifiers
+	// DefaultMask is applied to every export that does not opt out with
+	// "none": A-Numbers are never exported in full by accident
+	DefaultMask = MaskIdentifiers
+)
+
+var maskNames = []struct {
+	name string
+	mask Mask
+}{
+	{"receipts", MaskReceipts},
+	{"owners", MaskOwners},
+	{"notes", MaskNotes},
+	{"identifiers", MaskIdentifiers},
+}
+
+// ParseMask parses a comma-separated list of receipts, owners, notes and
+// identifiers, or "all". DefaultMask is added unless the list includes
+// "none", so "" masks identifiers, "none" exports everything as stored and
+// "none,owners" masks owners alone.
+func ParseMask(s string) (Mask, error) {
+	m, optOut := Mask(0), false
+	for _, part := range strings.Split(s, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		switch part {
+		case "":
+			continue
+		case "none":
+			optOut = true
+			continue
+		case "all":
+			m |= MaskAll
+			continue
+		}
+		found := false
+		for _, n := range maskNames {
+			if n.name == part {
+				m |= n.mask
+				found = true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown mask %q (expected receipts, owners, notes, identifiers, all or none)", part)
+		}
+	}
+	if !optOut {
+		m |= DefaultMask
+	}
+	return m, nil
+}
+
+// String lists the masks that are set
+func (m Mask) String() string {
+	if m == MaskNone {
+		return "none"
+	}
+	var names []string
+	for _, n := range maskNames {
+		if m&n.mask != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// aNumberPattern finds A-Numbers such as A123456789 or A-123-456-789 in
+// free text
+var aNumberPattern = regexp.MustCompile(`\b[Aa][- ]?\d{2,3}[- ]?\d{3}[- ]?\d{3}\b`)
+
+// maskText masks identifiers in free text
+func maskText(s string) string {
+	return aNumberPattern.ReplaceAllStringFunc(s, func(match string) string {
+		return validation.MaskIdentifier(validation.KindANumber, match)
+	})
+}
+
+// maskReceipt keeps the service center and last four digits
+func maskReceipt(number string) string {
+	if len(number) <= 7 {
+		return strings.Repeat("*", len(number))
+	}
+	return number[:3] + strings.Repeat("*", len(number)-7) + number[len(number)-4:]
+}
+
+// maskOwner keeps the first character and an email domain
+func maskOwner(owner string) string {
+	if owner == "" {
+		return ""
+	}
+	local, domain, isEmail := strings.Cut(owner, "@")
+	masked := string([]rune(local)[:1]) + "***"
+	if isEmail {
+		return masked + "@" + domain
+	}
+	return masked
+}
@@ -0,0 +1,199 @@
+// Package casegen generates realistic, reproducible case records for the
+// development and staging environments. The same receipt number and
+// scenario always produce the same form, storyline and relative dates.
+package casegen
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/forms"
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/types"
+)
+
+const (
+	// fallbackForm is used when the catalog has no form for a center
+	fallbackForm = "I-765"
+	// upstreamDateLayout matches the dates in USCIS case-status payloads
+	upstreamDateLayout = "01-02-2006"
+)
+
+// Generator produces fake case records. It is safe for concurrent use.
+type Generator struct {
+	clock clock.Clock
+
+	mu       sync.RWMutex
+	scenario Scenario
+}
+
+// Option configures a Generator
+type Option func(*Generator)
+
+// WithClock sets the clock generated dates are relative to
+func WithClock(c clock.Clock) Option {
+	return func(g *Generator) { g.clock = c }
+}
+
+// WithScenario sets the initial default scenario
+func WithScenario(s Scenario) Option {
+	return func(g *Generator) { g.scenario = s }
+}
+
+// NewGenerator creates a generator that defaults to ScenarioMixed
+func NewGenerator(opts ...Option) *Generator {
+	g := &Generator{clock: clock.Real(), scenario: ScenarioMixed}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Scenario returns the default scenario
+func (g *Generator) Scenario() Scenario {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.scenario
+}
+
+// SetScenario changes the default scenario used by Generate
+func (g *Generator) SetScenario(s Scenario) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.scenario = s
+}
+
+// Generate builds a case record using the default scenario
+func (g *Generator) Generate(r receipt.Receipt) *types.CaseRecord {
+	return g.GenerateScenario(r, g.Scenario())
+}
+
+// GenerateScenario builds a case record following scenario. Dates are
+// placed so the storyline ends shortly before the generator's current time;
+// stalled cases end many months earlier.
+func (g *Generator) GenerateScenario(r receipt.Receipt, scenario Scenario) *types.CaseRecord {
+	if scenario == ScenarioMixed || scenario == "" {
+		scenario = pickScenario(r)
+	}
+	rng := newRand(r.Number, string(scenario))
+
+	form := pickForm(r, rng)
+	steps, ok := paths[scenario][form.Workflow]
+	if !ok {
+		steps = paths[scenario]["benefit"]
+	}
+
+	// Lay out the gaps first, then anchor the last event relative to now
+	gaps := make([]int, len(steps))
+	total := 0
+	for i, s := range steps {
+		gaps[i] = s.minDays + rng.IntN(s.maxDays-s.minDays+1)
+		total += gaps[i]
+	}
+	idle := 1 + rng.IntN(30)
+	if scenario == ScenarioStalled {
+		idle = 240 + rng.IntN(180)
+	}
+
+	now := g.clock.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	date := today.AddDate(0, 0, -(total + idle))
+
+	timeline := types.NewCaseTimeline(r.Number)
+	for i, s := range steps {
+		date = date.AddDate(0, 0, gaps[i])
+		timeline.Add(types.NewTimelineEvent(date, s.code.Title(), types.SourceGenerated))
+	}
+
+	latest, _ := timeline.Latest()
+	first, _ := timeline.First()
+	record := &types.CaseRecord{
+		ReceiptNumber: r.Number,
+		FormType:      form.ID,
+		Status:        latest.Status,
+		Description:   descriptions[latest.Status.Code],
+		ServiceCenter: r.Center.Name,
+		SubmittedAt:   first.Date,
+		UpdatedAt:     latest.Date,
+		Timeline:      timeline,
+	}
+	if len(timeline.EventsWithStatus(types.StatusTransferred)) > 0 {
+		record.ServiceCenter = transferOffice(r)
+		if latest.Status.Code == types.StatusTransferred {
+			record.Description = fmt.Sprintf(transferDescription, form.ID, record.ServiceCenter)
+		}
+	}
+	return record
+}
+
+// transferOffice is the office a transferred case moves to
+func transferOffice(r receipt.Receipt) string {
+	if r.Center.Canonical == "MSC" {
+		return "Potomac Service Center"
+	}
+	return "National Benefits Center"
+}
+
+// Response builds the upstream case-status payload for a generated case
+func (g *Generator) Response(r receipt.Receipt) *types.CaseStatusResponse {
+	record := g.Generate(r)
+	cs := types.UpstreamCaseStatus{
+		ReceiptNumber:     record.ReceiptNumber,
+		FormType:          record.FormType,
+		SubmittedDate:     record.SubmittedAt.Format(upstreamDateLayout),
+		ModifiedDate:      record.UpdatedAt.Format(upstreamDateLayout),
+		CurrentStatus:     record.Status.Title,
+		StatusDescription: record.Description,
+		Office:            record.ServiceCenter,
+	}
+	events := record.Timeline.Events
+	for _, e := range events[:len(events)-1] {
+		cs.History = append(cs.History, types.CaseStatusHistoryEntry{
+			Title: e.Status.Title,
+			Date:  e.Date.Format(upstreamDateLayout),
+		})
+	}
+	return &types.CaseStatusResponse{CaseStatus: cs}
+}
+
+// newRand seeds a generator from an FNV-1a hash of the inputs
+func newRand(parts ...string) *rand.Rand {
+	h := fnv.New128a()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	sum := h.Sum(nil)
+	return rand.New(rand.NewPCG(binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:])))
+}
+
+// pickScenario chooses a weighted scenario from the receipt number alone
+func pickScenario(r receipt.Receipt) Scenario {
+	total := 0
+	for _, w := range mixedWeights {
+		total += w.weight
+	}
+	n := newRand(r.Number).IntN(total)
+	for _, w := range mixedWeights {
+		if n < w.weight {
+			return w.scenario
+		}
+		n -= w.weight
+	}
+	return ScenarioHappyPath
+}
+
+// pickForm chooses a form typically receipted at the receipt's center
+func pickForm(r receipt.Receipt, rng *rand.Rand) *forms.Form {
+	candidates := forms.List(forms.Filter{Center: r.Center.Code})
+	if len(candidates) == 0 {
+		form, _ := forms.Lookup(fallbackForm)
+		return form
+	}
+	return candidates[rng.IntN(len(candidates))]
+}
@@ -0,0 +1,196 @@
+package casegen
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/forms"
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/types"
+)
+
+var testNow = time.Date(2025, 6, 15, 14, 30, 0, 0, time.UTC)
+
+func mustParse(t *testing.T, s string) receipt.Receipt {
+	t.Helper()
+	r, err := receipt.Parse(s)
+	if err != nil {
+		t.Fatalf("receipt.Parse(%q) error = %v", s, err)
+	}
+	return r
+}
+
+func TestParseScenario(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Scenario
+		wantErr bool
+	}{
+		{"", ScenarioMixed, false},
+		{"mixed", ScenarioMixed, false},
+		{" RFE_Approval ", ScenarioRFEApproval, false},
+		{"stalled", ScenarioStalled, false},
+		{"approved", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseScenario(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseScenario(%q) = %q, %v; want %q, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPathsAreValidForEveryForm(t *testing.T) {
+	for _, form := range forms.Default().Forms {
+		for _, scenario := range Scenarios() {
+			steps, ok := paths[scenario][form.Workflow]
+			if !ok {
+				t.Errorf("%s has no %s path for workflow %q", scenario, form.ID, form.Workflow)
+				continue
+			}
+			history := make([]types.CaseStatusCode, len(steps))
+			for i, s := range steps {
+				history[i] = s.code
+			}
+			if err := form.ValidateHistory(history); err != nil {
+				t.Errorf("%s %s: %v", scenario, form.ID, err)
+			}
+		}
+	}
+}
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	r := mustParse(t, "EAC2190000001")
+	a := NewGenerator(WithClock(clock.NewFake(testNow))).GenerateScenario(r, ScenarioRFEApproval)
+	b := NewGenerator(WithClock(clock.NewFake(testNow))).GenerateScenario(r, ScenarioRFEApproval)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("same receipt and scenario produced different records:\n%+v\n%+v", a, b)
+	}
+
+	other := NewGenerator(WithClock(clock.NewFake(testNow))).GenerateScenario(mustParse(t, "EAC2190000002"), ScenarioRFEApproval)
+	if reflect.DeepEqual(a.Timeline, other.Timeline) {
+		t.Error("different receipts should produce different timelines")
+	}
+}
+
+func TestGenerateScenarios(t *testing.T) {
+	fc := clock.NewFake(testNow)
+	g := NewGenerator(WithClock(fc))
+
+	tests := []struct {
+		scenario  Scenario
+		receipt   string
+		want      []types.CaseStatusCode // codes that must appear in the timeline
+		decided   bool
+		minIdle   time.Duration
+		maxIdle   time.Duration
+		finalCode types.CaseStatusCode
+	}{
+		{ScenarioHappyPath, "WAC2190012345", []types.CaseStatusCode{types.StatusApproved}, true, 24 * time.Hour, 31 * 24 * time.Hour, ""},
+		{ScenarioRFEApproval, "WAC2190012345", []types.CaseStatusCode{types.StatusRFESent, types.StatusRFEResponseReceived, types.StatusApproved}, true, 24 * time.Hour, 31 * 24 * time.Hour, ""},
+		{ScenarioTransfer, "SRC2190012345", []types.CaseStatusCode{types.StatusTransferred, types.StatusApproved}, true, 24 * time.Hour, 31 * 24 * time.Hour, ""},
+		{ScenarioDenial, "LIN2190012345", []types.CaseStatusCode{types.StatusDenied}, true, 24 * time.Hour, 31 * 24 * time.Hour, types.StatusDenied},
+		{ScenarioStalled, "MSC2190012345", nil, false, 240 * 24 * time.Hour, 420 * 24 * time.Hour, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.scenario), func(t *testing.T) {
+			r := mustParse(t, tt.receipt)
+			record := g.GenerateScenario(r, tt.scenario)
+
+			// a transferred case ends up at another office
+			center := r.Center.Name
+			if tt.scenario == ScenarioTransfer {
+				center = "National Benefits Center"
+			}
+			if record.ReceiptNumber != r.Number || record.ServiceCenter != center {
+				t.Errorf("record identity = %s at %s", record.ReceiptNumber, record.ServiceCenter)
+			}
+			form, ok := forms.Lookup(record.FormType)
+			if !ok || !form.AllowsCenter(r.Center) {
+				t.Errorf("form %q is not receipted at %s", record.FormType, r.Center.Code)
+			}
+
+			first, _ := record.Timeline.First()
+			if first.Status.Code != types.StatusReceived || !record.SubmittedAt.Equal(first.Date) {
+				t.Errorf("first event = %+v, submitted %v", first, record.SubmittedAt)
+			}
+			for _, code := range tt.want {
+				if len(record.Timeline.EventsWithStatus(code)) == 0 {
+					t.Errorf("timeline has no %s event", code)
+				}
+			}
+			decided := len(record.Timeline.EventsWithStatus(types.StatusApproved, types.StatusDenied)) > 0
+			if decided != tt.decided {
+				t.Errorf("decision reached = %v, want %v", decided, tt.decided)
+			}
+			if tt.finalCode != "" && record.Status.Code != tt.finalCode {
+				t.Errorf("final status = %s, want %s", record.Status.Code, tt.finalCode)
+			}
+
+			idle := record.Timeline.TimeSinceLastUpdate(fc.Now())
+			if idle < tt.minIdle || idle > tt.maxIdle {
+				t.Errorf("last update %v ago, want between %v and %v", idle, tt.minIdle, tt.maxIdle)
+			}
+		})
+	}
+}
+
+func TestDefaultScenario(t *testing.T) {
+	g := NewGenerator(WithClock(clock.NewFake(testNow)))
+	if g.Scenario() != ScenarioMixed {
+		t.Fatalf("default scenario = %q, want mixed", g.Scenario())
+	}
+
+	r := mustParse(t, "IOE0912345678")
+	mixed := g.Generate(r)
+	if want := g.GenerateScenario(r, pickScenario(r)); !reflect.DeepEqual(mixed, want) {
+		t.Error("mixed mode should follow the scenario picked for the receipt")
+	}
+
+	g.SetScenario(ScenarioDenial)
+	if got := g.Generate(r); got.Status.Code != types.StatusDenied {
+		t.Errorf("after SetScenario(denial) status = %s", got.Status.Code)
+	}
+
+	seen := make(map[Scenario]bool)
+	base := mustParse(t, "EAC2190000000")
+	for i := 0; i < 200; i++ {
+		n, _ := base.Offset(i)
+		seen[pickScenario(n)] = true
+	}
+	if len(seen) != len(Scenarios()) {
+		t.Errorf("mixed mode picked %d of %d scenarios over 200 receipts", len(seen), len(Scenarios()))
+	}
+}
+
+func TestResponseRoundTrip(t *testing.T) {
+	g := NewGenerator(WithClock(clock.NewFake(testNow)), WithScenario(ScenarioTransfer))
+	r := mustParse(t, "EAC2190050123")
+
+	data, err := json.Marshal(g.Response(r))
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	resp, err := types.ParseCaseStatusResponse(data)
+	if err != nil {
+		t.Fatalf("ParseCaseStatusResponse() error = %v", err)
+	}
+
+	got, want := resp.Record(), g.Generate(r)
+	if got.Status.Code != want.Status.Code || got.FormType != want.FormType {
+		t.Errorf("round trip = %s %s, want %s %s", got.FormType, got.Status.Code, want.FormType, want.Status.Code)
+	}
+	if got.ServiceCenter != want.ServiceCenter {
+		t.Errorf("round trip office = %q, want %q", got.ServiceCenter, want.ServiceCenter)
+	}
+	if got.Timeline.Len() != want.Timeline.Len() {
+		t.Errorf("round trip timeline has %d events, want %d", got.Timeline.Len(), want.Timeline.Len())
+	}
+	if !got.SubmittedAt.Equal(want.SubmittedAt) || !got.UpdatedAt.Equal(want.UpdatedAt) {
+		t.Errorf("round trip dates = %v..%v, want %v..%v", got.SubmittedAt, got.UpdatedAt, want.SubmittedAt, want.UpdatedAt)
+	}
+}
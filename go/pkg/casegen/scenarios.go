@@ -0,0 +1,128 @@
+package casegen
+
+import (
+	"fmt"
+	"strings"
+
+	"MyUSCISgo/pkg/types"
+)
+
+// Scenario selects the storyline a generated case follows
+type Scenario string
+
+const (
+	// ScenarioMixed picks one of the other scenarios per receipt number, so a
+	// set of receipts shows a realistic spread of outcomes
+	ScenarioMixed       Scenario = "mixed"
+	ScenarioHappyPath   Scenario = "happy_path"
+	ScenarioRFEApproval Scenario = "rfe_approval"
+	ScenarioTransfer    Scenario = "transfer"
+	ScenarioDenial      Scenario = "denial"
+	ScenarioStalled     Scenario = "stalled"
+)
+
+// Scenarios lists the concrete scenario packs, excluding ScenarioMixed
+func Scenarios() []Scenario {
+	return []Scenario{ScenarioHappyPath, ScenarioRFEApproval, ScenarioTransfer, ScenarioDenial, ScenarioStalled}
+}
+
+// ParseScenario parses a scenario name; an empty name means ScenarioMixed
+func ParseScenario(s string) (Scenario, error) {
+	name := Scenario(strings.ToLower(strings.TrimSpace(s)))
+	if name == "" || name == ScenarioMixed {
+		return ScenarioMixed, nil
+	}
+	for _, sc := range Scenarios() {
+		if sc == name {
+			return sc, nil
+		}
+	}
+	return "", fmt.Errorf("unknown scenario %q", s)
+}
+
+// mixedWeights controls how often each scenario is chosen in mixed mode
+var mixedWeights = []struct {
+	scenario Scenario
+	weight   int
+}{
+	{ScenarioHappyPath, 40},
+	{ScenarioRFEApproval, 20},
+	{ScenarioTransfer, 10},
+	{ScenarioDenial, 10},
+	{ScenarioStalled, 20},
+}
+
+// step is one status change, taking place between minDays and maxDays
+// after the previous one
+type step struct {
+	code             types.CaseStatusCode
+	minDays, maxDays int
+}
+
+var (
+	received    = []step{{types.StatusReceived, 0, 0}}
+	biometrics  = []step{{types.StatusBiometricsScheduled, 10, 25}, {types.StatusFingerprintsTaken, 14, 35}}
+	review      = []step{{types.StatusActivelyReviewed, 30, 120}}
+	rfe         = []step{{types.StatusRFESent, 10, 60}, {types.StatusRFEResponseReceived, 30, 84}}
+	transfer    = []step{{types.StatusTransferred, 20, 90}}
+	approval    = []step{{types.StatusApproved, 20, 90}}
+	denial      = []step{{types.StatusDenied, 30, 120}}
+	cardMailing = []step{{types.StatusCardProduced, 0, 3}, {types.StatusCardMailed, 3, 10}, {types.StatusCardDelivered, 2, 6}}
+	interview   = []step{{types.StatusInterviewScheduled, 60, 200}, {types.StatusInterviewCompleted, 20, 45}}
+	oath        = []step{{types.StatusApproved, 0, 14}, {types.StatusOathScheduled, 7, 45}}
+	interviewNo = []step{{types.StatusDenied, 0, 30}}
+)
+
+func path(parts ...[]step) []step {
+	var out []step
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// paths holds the storyline of each scenario for each catalog workflow.
+// Every path is a valid history under the workflow's transition rules.
+var paths = map[Scenario]map[string][]step{
+	ScenarioHappyPath: {
+		"petition":       path(received, review, approval),
+		"benefit":        path(received, biometrics, review, approval, cardMailing),
+		"naturalization": path(received, biometrics, interview, oath),
+	},
+	ScenarioRFEApproval: {
+		"petition":       path(received, review, rfe, approval),
+		"benefit":        path(received, biometrics, review, rfe, approval, cardMailing),
+		"naturalization": path(received, biometrics, review, rfe, interview, oath),
+	},
+	ScenarioTransfer: {
+		"petition":       path(received, transfer, review, approval),
+		"benefit":        path(received, biometrics, transfer, review, approval, cardMailing),
+		"naturalization": path(received, biometrics, transfer, interview, oath),
+	},
+	ScenarioDenial: {
+		"petition":       path(received, review, rfe, denial),
+		"benefit":        path(received, biometrics, review, rfe, denial),
+		"naturalization": path(received, biometrics, interview, interviewNo),
+	},
+	ScenarioStalled: {
+		"petition":       path(received, review),
+		"benefit":        path(received, biometrics, review),
+		"naturalization": path(received, biometrics),
+	},
+}
+
+// descriptions are the case status texts shown with the latest status
+var descriptions = map[types.CaseStatusCode]string{
+	types.StatusReceived:            "We received your case and sent you a receipt notice that describes how we will process your case.",
+	types.StatusFingerprintsTaken:   "We took your fingerprints and will use them to conduct a background check.",
+	types.StatusActivelyReviewed:    "We are actively reviewing your case. We will let you know if we need anything from you.",
+	types.StatusRFEResponseReceived: "We received your response to our Request for Evidence and are reviewing your case.",
+	types.StatusApproved:            "We approved your case and mailed you an approval notice.",
+	types.StatusDenied:              "We denied your case and mailed you a decision notice explaining why.",
+	types.StatusCardDelivered:       "The Post Office delivered your new card to the address we have on file.",
+	types.StatusOathScheduled:       "We scheduled your oath ceremony and mailed you a notice with the date and location.",
+}
+
+// transferDescription is the status text of a transfer, naming the form
+// and the office that took the case over
+const transferDescription = "We transferred your Form %s to our %s location, which now has jurisdiction over your case."
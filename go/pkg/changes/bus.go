@@ -0,0 +1,102 @@
+package changes
+
+import (
+	"context"
+	"sync"
+
+	"MyUSCISgo/pkg/audit"
+)
+
+// Subscriber receives published events
+type Subscriber func(Event)
+
+// Bus fans events out to subscribers. Delivery is synchronous and in
+// subscription order; subscribers that do slow work should hand it off.
+// A Bus is safe for concurrent use.
+type Bus struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   []subscription
+}
+
+type subscription struct {
+	id int
+	fn Subscriber
+}
+
+// NewBus creates a bus with no subscribers
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers fn and returns a function that removes it
+func (b *Bus) Subscribe(fn Subscriber) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	id := b.nextID
+	b.subs = append(b.subs, subscription{id: id, fn: fn})
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			for i, s := range b.subs {
+				if s.id == id {
+					b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+					return
+				}
+			}
+		})
+	}
+}
+
+// Publish delivers each event to every subscriber
+func (b *Bus) Publish(events ...Event) {
+	if len(events) == 0 {
+		return
+	}
+	b.mu.RLock()
+	subs := make([]Subscriber, len(b.subs))
+	for i, s := range b.subs {
+		subs[i] = s.fn
+	}
+	b.mu.RUnlock()
+
+	for _, e := range events {
+		for _, fn := range subs {
+			fn(e)
+		}
+	}
+}
+
+// AuditSink returns a subscriber that records events in log. Append errors
+// are passed to onError, which may be nil.
+func AuditSink(log audit.Log, onError func(Event, error)) Subscriber {
+	return func(e Event) {
+		err := log.Append(context.Background(), AuditEntry(e))
+		if err != nil && onError != nil {
+			onError(e, err)
+		}
+	}
+}
+
+// AuditEntry converts an event into an audit record
+func AuditEntry(e Event) audit.Entry {
+	details := map[string]string{
+		"after":      e.After,
+		"occurredAt": e.OccurredAt.Format("2006-01-02"),
+		"summary":    e.Summary(),
+	}
+	if e.Before != "" {
+		details["before"] = e.Before
+	}
+	return audit.Entry{
+		ID:      "change-" + e.ID,
+		Time:    e.DetectedAt,
+		Actor:   audit.ActorSystem,
+		Action:  "case." + string(e.Type),
+		Subject: e.ReceiptNumber,
+		Details: details,
+	}
+}
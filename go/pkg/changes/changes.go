@@ -0,0 +1,224 @@
+// Package changes compares successive snapshots of a case and reports what
+// moved between them as typed events.
+package changes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"MyUSCISgo/pkg/types"
+)
+
+// Type identifies the kind of change an event describes
+type Type string
+
+const (
+	// StatusChanged reports a new current status
+	StatusChanged Type = "status_changed"
+	// HistoryAdded reports an entry that appeared in the status history
+	HistoryAdded Type = "history_added"
+	// StageAdvanced reports a move to a later lifecycle stage
+	StageAdvanced Type = "stage_advanced"
+	// RFEIssued reports a request for evidence, request for initial evidence
+	// or notice of intent to deny
+	RFEIssued Type = "rfe_issued"
+	// CardProduced reports that card production has started
+	CardProduced Type = "card_produced"
+	// CaseTransferred reports a move to a different office
+	CaseTransferred Type = "case_transferred"
+)
+
+// Types returns every event type in the order Diff emits them
+func Types() []Type {
+	return []Type{StatusChanged, StageAdvanced, RFEIssued, CardProduced, CaseTransferred, HistoryAdded}
+}
+
+// Event is a single detected change. Before and After hold the values that
+// changed: status codes, stage names, office names or, for history entries,
+// the status of the new entry.
+type Event struct {
+	// ID is derived from the event content, so detecting the same change
+	// twice yields the same ID
+	ID            string           `json:"id"`
+	Type          Type             `json:"type"`
+	ReceiptNumber string           `json:"receiptNumber"`
+	Before        string           `json:"before,omitempty"`
+	After         string           `json:"after"`
+	Status        types.CaseStatus `json:"status"`
+	OccurredAt    time.Time        `json:"occurredAt"`
+	DetectedAt    time.Time        `json:"detectedAt"`
+}
+
+// Summary describes the event in a short sentence
+func (e Event) Summary() string {
+	switch e.Type {
+	case StatusChanged:
+		return fmt.Sprintf("%s: status changed to %q", e.ReceiptNumber, e.Status.Title)
+	case HistoryAdded:
+		return fmt.Sprintf("%s: %q added to history", e.ReceiptNumber, e.Status.Title)
+	case StageAdvanced:
+		return fmt.Sprintf("%s: advanced from %s to %s", e.ReceiptNumber, e.Before, e.After)
+	case RFEIssued:
+		return fmt.Sprintf("%s: %s", e.ReceiptNumber, e.Status.Title)
+	case CardProduced:
+		return fmt.Sprintf("%s: card is being produced", e.ReceiptNumber)
+	case CaseTransferred:
+		if e.Before != "" && e.After != "" {
+			return fmt.Sprintf("%s: transferred from %s to %s", e.ReceiptNumber, e.Before, e.After)
+		}
+		return fmt.Sprintf("%s: transferred to a new office", e.ReceiptNumber)
+	default:
+		return fmt.Sprintf("%s: %s", e.ReceiptNumber, e.Type)
+	}
+}
+
+// rfeCodes are the statuses that ask the applicant for more evidence
+var rfeCodes = map[types.CaseStatusCode]bool{
+	types.StatusRFESent:  true,
+	types.StatusRIESent:  true,
+	types.StatusNOIDSent: true,
+}
+
+// Diff returns the changes between two snapshots of the same case, in the
+// order of Types. A nil before is the first observation of a case and yields
+// no events, since there is nothing to compare against.
+func Diff(before, after *types.CaseRecord, now time.Time) []Event {
+	if before == nil || after == nil {
+		return nil
+	}
+	d := differ{receipt: after.ReceiptNumber, now: now.UTC()}
+
+	changedAt := after.UpdatedAt
+	if changedAt.IsZero() {
+		changedAt = now
+	}
+	statusChanged := before.Status.Code != after.Status.Code ||
+		(!after.Status.IsKnown() && before.Status.Title != after.Status.Title)
+
+	// Statuses reached since the last snapshot, from both the new history
+	// entries and the current status
+	added := newEvents(before.Timeline, after.Timeline)
+	reached := make([]types.TimelineEvent, 0, len(added)+1)
+	reached = append(reached, added...)
+	if statusChanged {
+		current := types.TimelineEvent{Date: changedAt, Status: after.Status}
+		if !containsKey(reached, current.Key()) && !containsStatus(reached, after.Status.Code) {
+			reached = append(reached, current)
+		}
+	}
+
+	if statusChanged {
+		d.add(StatusChanged, statusValue(before.Status), statusValue(after.Status), after.Status, changedAt)
+	}
+	if after.Status.Stage.Order() > before.Status.Stage.Order() {
+		d.add(StageAdvanced, before.Status.Stage.String(), after.Status.Stage.String(), after.Status, changedAt)
+	}
+	for _, e := range reached {
+		if rfeCodes[e.Status.Code] {
+			d.add(RFEIssued, statusValue(before.Status), string(e.Status.Code), e.Status, e.Date)
+		}
+	}
+	for _, e := range reached {
+		if e.Status.Code == types.StatusCardProduced {
+			d.add(CardProduced, statusValue(before.Status), string(e.Status.Code), e.Status, e.Date)
+			break
+		}
+	}
+	if before.ServiceCenter != "" && after.ServiceCenter != "" && before.ServiceCenter != after.ServiceCenter {
+		d.add(CaseTransferred, before.ServiceCenter, after.ServiceCenter, after.Status, changedAt)
+	} else {
+		for _, e := range reached {
+			if e.Status.Code == types.StatusTransferred {
+				d.add(CaseTransferred, before.ServiceCenter, after.ServiceCenter, e.Status, e.Date)
+				break
+			}
+		}
+	}
+	for _, e := range added {
+		d.add(HistoryAdded, "", statusValue(e.Status), e.Status, e.Date)
+	}
+	return d.events
+}
+
+// differ accumulates the events of one Diff call
+type differ struct {
+	receipt string
+	now     time.Time
+	events  []Event
+}
+
+// add appends an event with a content-derived ID
+func (d *differ) add(t Type, before, after string, status types.CaseStatus, occurred time.Time) {
+	occurred = occurred.UTC()
+	d.events = append(d.events, Event{
+		ID:            eventID(d.receipt, t, before, after, occurred),
+		Type:          t,
+		ReceiptNumber: d.receipt,
+		Before:        before,
+		After:         after,
+		Status:        status,
+		OccurredAt:    occurred,
+		DetectedAt:    d.now,
+	})
+}
+
+// eventID hashes the fields that identify a change
+func eventID(receipt string, t Type, before, after string, occurred time.Time) string {
+	sum := sha256.Sum256([]byte(receipt + "|" + string(t) + "|" + before + "|" + after + "|" + occurred.Format("2006-01-02")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// newEvents returns the events of after that before does not have
+func newEvents(before, after *types.CaseTimeline) []types.TimelineEvent {
+	if after == nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	if before != nil {
+		for _, e := range before.Events {
+			seen[e.Key()] = true
+		}
+	}
+	var added []types.TimelineEvent
+	for _, e := range after.Events {
+		if !seen[e.Key()] {
+			seen[e.Key()] = true
+			added = append(added, e)
+		}
+	}
+	return added
+}
+
+// containsKey reports whether an event with the de-duplication key is present
+func containsKey(events []types.TimelineEvent, key string) bool {
+	for _, e := range events {
+		if e.Key() == key {
+			return true
+		}
+	}
+	return false
+}
+
+// containsStatus reports whether an event with the known status is present
+func containsStatus(events []types.TimelineEvent, code types.CaseStatusCode) bool {
+	if code == types.StatusUnknown {
+		return false
+	}
+	for _, e := range events {
+		if e.Status.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// statusValue names a status by code, falling back to the title for
+// statuses the catalog does not know
+func statusValue(s types.CaseStatus) string {
+	if s.IsKnown() {
+		return string(s.Code)
+	}
+	return s.Title
+}
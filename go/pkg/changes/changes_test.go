@@ -0,0 +1,233 @@
+package changes
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"MyUSCISgo/pkg/audit"
+	"MyUSCISgo/pkg/types"
+)
+
+var testNow = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func day(d int) time.Time {
+	return time.Date(2025, 5, d, 0, 0, 0, 0, time.UTC)
+}
+
+// record builds a snapshot whose current status is the last of titles, with
+// one history entry per title on consecutive days starting May 1
+func record(center string, titles ...string) *types.CaseRecord {
+	r := &types.CaseRecord{
+		ReceiptNumber: "EAC2190000001",
+		ServiceCenter: center,
+		Timeline:      types.NewCaseTimeline("EAC2190000001"),
+	}
+	for i, title := range titles {
+		r.Timeline.Add(types.NewTimelineEvent(day(i+1), title, types.SourceHistory))
+		r.Status = types.ParseCaseStatus(title)
+		r.UpdatedAt = day(i + 1)
+	}
+	return r
+}
+
+type change struct {
+	Type   Type
+	Before string
+	After  string
+}
+
+func TestDiff(t *testing.T) {
+	const (
+		received    = "Case Was Received"
+		transferred = "Case Was Transferred And A New Office Has Jurisdiction"
+		fingerprint = "Case Was Updated To Show Fingerprints Were Taken"
+		rfe         = "Request for Evidence Was Sent"
+		approved    = "Case Was Approved"
+		card        = "New Card Is Being Produced"
+	)
+	tests := []struct {
+		name   string
+		before *types.CaseRecord
+		after  *types.CaseRecord
+		want   []change
+	}{
+		{
+			name:   "first observation",
+			before: nil,
+			after:  record("", received),
+			want:   nil,
+		},
+		{
+			name:   "unchanged",
+			before: record("", received),
+			after:  record("", received),
+			want:   nil,
+		},
+		{
+			name:   "rfe issued",
+			before: record("", received, fingerprint),
+			after:  record("", received, fingerprint, rfe),
+			want: []change{
+				{StatusChanged, "fingerprints_taken", "rfe_sent"},
+				{StageAdvanced, "biometrics", "rfe"},
+				{RFEIssued, "fingerprints_taken", "rfe_sent"},
+				{HistoryAdded, "", "rfe_sent"},
+			},
+		},
+		{
+			name:   "approved then card produced between checks",
+			before: record("", received, fingerprint),
+			after:  record("", received, fingerprint, approved, card),
+			want: []change{
+				{StatusChanged, "fingerprints_taken", "card_being_produced"},
+				{StageAdvanced, "biometrics", "card_production"},
+				{CardProduced, "fingerprints_taken", "card_being_produced"},
+				{HistoryAdded, "", "case_approved"},
+				{HistoryAdded, "", "card_being_produced"},
+			},
+		},
+		{
+			name:   "transfer by status",
+			before: record("", received),
+			after:  record("", received, transferred),
+			want: []change{
+				{StatusChanged, "case_received", "case_transferred"},
+				{CaseTransferred, "", ""},
+				{HistoryAdded, "", "case_transferred"},
+			},
+		},
+		{
+			name:   "transfer by office",
+			before: record("Vermont Service Center", received),
+			after:  record("National Benefits Center", received),
+			want: []change{
+				{CaseTransferred, "Vermont Service Center", "National Benefits Center"},
+			},
+		},
+		{
+			name:   "history backfilled without status change",
+			before: record("", received, fingerprint),
+			after: func() *types.CaseRecord {
+				r := record("", received, fingerprint)
+				r.Timeline.Add(types.NewTimelineEvent(day(1).Add(-24*time.Hour), "Fee Was Waived", types.SourceHistory))
+				return r
+			}(),
+			want: []change{
+				{HistoryAdded, "", "fee_waived"},
+			},
+		},
+		{
+			name: "status change without history",
+			before: &types.CaseRecord{
+				ReceiptNumber: "EAC2190000001",
+				Status:        types.ParseCaseStatus(received),
+			},
+			after: &types.CaseRecord{
+				ReceiptNumber: "EAC2190000001",
+				Status:        types.ParseCaseStatus(rfe),
+			},
+			want: []change{
+				{StatusChanged, "case_received", "rfe_sent"},
+				{StageAdvanced, "received", "rfe"},
+				{RFEIssued, "case_received", "rfe_sent"},
+			},
+		},
+		{
+			name:   "unknown status compared by title",
+			before: record("", "Something Odd Happened"),
+			after:  record("", "Something Else Happened"),
+			want: []change{
+				{StatusChanged, "Something Odd Happened", "Something Else Happened"},
+				{HistoryAdded, "", "Something Else Happened"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := Diff(tt.before, tt.after, testNow)
+			var got []change
+			for _, e := range events {
+				got = append(got, change{e.Type, e.Before, e.After})
+				if e.ReceiptNumber != "EAC2190000001" || !e.DetectedAt.Equal(testNow) || e.ID == "" {
+					t.Errorf("event %+v missing identity fields", e)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() =\n  %+v\nwant\n  %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffIDsAreStable(t *testing.T) {
+	before := record("", "Case Was Received")
+	after := record("", "Case Was Received", "Case Was Approved")
+
+	first := Diff(before, after, testNow)
+	second := Diff(before, after, testNow.Add(time.Hour))
+	if len(first) == 0 || len(first) != len(second) {
+		t.Fatalf("Diff() returned %d and %d events", len(first), len(second))
+	}
+	seen := make(map[string]bool)
+	for i := range first {
+		if first[i].ID != second[i].ID {
+			t.Errorf("event %d ID changed between detections: %s vs %s", i, first[i].ID, second[i].ID)
+		}
+		if seen[first[i].ID] {
+			t.Errorf("duplicate ID %s", first[i].ID)
+		}
+		seen[first[i].ID] = true
+	}
+	if !first[0].OccurredAt.Equal(day(2)) {
+		t.Errorf("OccurredAt = %v, want %v", first[0].OccurredAt, day(2))
+	}
+}
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	var a, b []Type
+	unsubA := bus.Subscribe(func(e Event) { a = append(a, e.Type) })
+	bus.Subscribe(func(e Event) { b = append(b, e.Type) })
+
+	bus.Publish(Event{Type: StatusChanged}, Event{Type: HistoryAdded})
+	unsubA()
+	unsubA()
+	bus.Publish(Event{Type: RFEIssued})
+	bus.Publish()
+
+	if want := []Type{StatusChanged, HistoryAdded}; !reflect.DeepEqual(a, want) {
+		t.Errorf("first subscriber got %v, want %v", a, want)
+	}
+	if want := []Type{StatusChanged, HistoryAdded, RFEIssued}; !reflect.DeepEqual(b, want) {
+		t.Errorf("second subscriber got %v, want %v", b, want)
+	}
+}
+
+func TestAuditSink(t *testing.T) {
+	log := audit.NewMemoryLog(10)
+	bus := NewBus()
+	bus.Subscribe(AuditSink(log, func(e Event, err error) {
+		t.Errorf("Append(%s) error = %v", e.ID, err)
+	}))
+
+	events := Diff(record("", "Case Was Received"), record("", "Case Was Received", "Request for Evidence Was Sent"), testNow)
+	bus.Publish(events...)
+
+	entries, err := log.List(context.Background(), audit.Query{Action: "case.rfe_issued"})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("List() returned %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Subject != "EAC2190000001" || e.Actor != audit.ActorSystem || !e.Time.Equal(testNow) {
+		t.Errorf("entry = %+v", e)
+	}
+	if e.Details["before"] != "case_received" || e.Details["after"] != "rfe_sent" || e.Details["summary"] == "" {
+		t.Errorf("details = %v", e.Details)
+	}
+}
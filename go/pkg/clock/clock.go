@@ -0,0 +1,109 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock abstracts the passage of time so that time-dependent code can be
+// tested deterministically
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock delegates to the time package
+type realClock struct{}
+
+// Now returns the current wall-clock time
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// After waits for the duration to elapse and then sends the current time
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Real returns a Clock backed by the system clock
+func Real() Clock {
+	return realClock{}
+}
+
+// fakeWaiter is a pending After call on a Fake clock
+type fakeWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// Fake is a manually advanced Clock for tests
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+// NewFake creates a fake clock set to the given time
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now returns the fake clock's current time
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// After returns a channel that fires once the fake clock has been advanced by d
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+	f.waiters = append(f.waiters, fakeWaiter{deadline: f.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the fake clock forward and fires any expired After channels
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+	pending := f.waiters[:0]
+	for _, w := range f.waiters {
+		if !w.deadline.After(f.now) {
+			w.ch <- f.now
+			continue
+		}
+		pending = append(pending, w)
+	}
+	f.waiters = pending
+}
+
+// Set moves the fake clock to an absolute time, firing expired After channels
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	d := now.Sub(f.now)
+	f.mu.Unlock()
+	if d > 0 {
+		f.Advance(d)
+		return
+	}
+	f.mu.Lock()
+	f.now = now
+	f.mu.Unlock()
+}
+
+// Waiters returns the number of pending After calls, which lets tests wait
+// until a goroutine is blocked on the clock before advancing it
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
@@ -0,0 +1,294 @@
+// Package deadline computes response deadlines for USCIS notices, such as a
+// Request for Evidence, together with reminder checkpoints leading up to
+// them.
+package deadline
+
+import (
+	"sort"
+	"time"
+
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/holiday"
+	"MyUSCISgo/pkg/types"
+)
+
+// MailingDays is the allowance added to a response period when the notice
+// is served by mail (8 CFR 103.8(b))
+const MailingDays = 3
+
+// Kind identifies the response a deadline is for
+type Kind string
+
+const (
+	// KindRFE is a response to a Request for Evidence
+	KindRFE Kind = "rfe"
+	// KindRIE is a response to a Request for Initial Evidence
+	KindRIE Kind = "rie"
+	// KindNOID is a response to a Notice of Intent to Deny
+	KindNOID Kind = "noid"
+	// KindAppeal is an appeal or motion (Form I-290B) after a denial
+	KindAppeal Kind = "appeal"
+)
+
+// Rule describes the response period that starts with a notice
+type Rule struct {
+	Kind  Kind   `json:"kind"`
+	Label string `json:"label"`
+	// Days is the response period in calendar days
+	Days int `json:"days"`
+	// MailingDays is added to Days when the notice is mailed
+	MailingDays int `json:"mailingDays"`
+	// AnsweredBy lists statuses that show the response was received
+	AnsweredBy []types.CaseStatusCode `json:"answeredBy,omitempty"`
+}
+
+// DefaultRules maps the statuses that start a response period to their
+// rules. The due date printed on the notice always takes precedence; these
+// are the maximum periods the regulations allow.
+func DefaultRules() map[types.CaseStatusCode]Rule {
+	rfe := []types.CaseStatusCode{types.StatusRFEResponseReceived}
+	return map[types.CaseStatusCode]Rule{
+		types.StatusRFESent:  {Kind: KindRFE, Label: "Request for Evidence", Days: 84, MailingDays: MailingDays, AnsweredBy: rfe},
+		types.StatusRIESent:  {Kind: KindRIE, Label: "Request for Initial Evidence", Days: 84, MailingDays: MailingDays, AnsweredBy: rfe},
+		types.StatusNOIDSent: {Kind: KindNOID, Label: "Notice of Intent to Deny", Days: 30, MailingDays: MailingDays, AnsweredBy: rfe},
+		types.StatusDenied:   {Kind: KindAppeal, Label: "Appeal or motion", Days: 30, MailingDays: MailingDays},
+	}
+}
+
+// DefaultCheckpoints are the reminder checkpoints, in business days before
+// the due date
+var DefaultCheckpoints = []int{20, 10, 5, 2}
+
+// Checkpoint is a reminder before a deadline
+type Checkpoint struct {
+	BusinessDaysBefore int       `json:"businessDaysBefore"`
+	At                 time.Time `json:"at"`
+	Passed             bool      `json:"passed"`
+}
+
+// Deadline is a response deadline attached to a timeline event
+type Deadline struct {
+	ReceiptNumber string               `json:"receiptNumber,omitempty"`
+	Kind          Kind                 `json:"kind"`
+	Label         string               `json:"label"`
+	Status        types.CaseStatusCode `json:"status"`
+	NoticeDate    time.Time            `json:"noticeDate"`
+	Days          int                  `json:"days"`
+	MailingDays   int                  `json:"mailingDays"`
+	// DueAt is the last day a response is accepted: the notice date plus
+	// the response and mailing days, moved to the next business day when it
+	// falls on a weekend or federal holiday
+	DueAt time.Time `json:"dueAt"`
+	// RolledOver names the holiday or weekend that moved the due date
+	RolledOver            string       `json:"rolledOver,omitempty"`
+	Checkpoints           []Checkpoint `json:"checkpoints"`
+	AnsweredAt            time.Time    `json:"answeredAt,omitzero"`
+	DaysRemaining         int          `json:"daysRemaining"`
+	BusinessDaysRemaining int          `json:"businessDaysRemaining"`
+	Overdue               bool         `json:"overdue"`
+}
+
+// Answered reports whether USCIS recorded a response
+func (d Deadline) Answered() bool {
+	return !d.AnsweredAt.IsZero()
+}
+
+// Open reports whether the deadline still needs a response
+func (d Deadline) Open() bool {
+	return !d.Answered() && !d.Overdue
+}
+
+// NextCheckpoint returns the first checkpoint that has not passed
+func (d Deadline) NextCheckpoint() (Checkpoint, bool) {
+	for _, c := range d.Checkpoints {
+		if !c.Passed {
+			return c, true
+		}
+	}
+	return Checkpoint{}, false
+}
+
+// Calculator computes deadlines
+type Calculator struct {
+	clock       clock.Clock
+	calendar    *holiday.Calendar
+	rules       map[types.CaseStatusCode]Rule
+	checkpoints []int
+	mailed      bool
+}
+
+// Option configures a Calculator
+type Option func(*Calculator)
+
+// WithClock sets the clock used for remaining days and passed checkpoints
+func WithClock(c clock.Clock) Option {
+	return func(calc *Calculator) {
+		calc.clock = c
+	}
+}
+
+// WithCalendar sets the business-day calendar, for example one with extra
+// closures
+func WithCalendar(c *holiday.Calendar) Option {
+	return func(calc *Calculator) {
+		calc.calendar = c
+	}
+}
+
+// WithRules replaces the response periods
+func WithRules(rules map[types.CaseStatusCode]Rule) Option {
+	return func(calc *Calculator) {
+		calc.rules = rules
+	}
+}
+
+// WithCheckpoints sets the reminder checkpoints in business days before the
+// due date
+func WithCheckpoints(businessDays ...int) Option {
+	return func(calc *Calculator) {
+		calc.checkpoints = append([]int(nil), businessDays...)
+	}
+}
+
+// WithMailing controls whether mailing days are added; turn it off for
+// notices served electronically. It is on by default.
+func WithMailing(mailed bool) Option {
+	return func(calc *Calculator) {
+		calc.mailed = mailed
+	}
+}
+
+// New creates a calculator with the default rules, checkpoints and federal
+// calendar
+func New(opts ...Option) *Calculator {
+	calc := &Calculator{
+		clock:       clock.Real(),
+		calendar:    holiday.Default(),
+		rules:       DefaultRules(),
+		checkpoints: DefaultCheckpoints,
+		mailed:      true,
+	}
+	for _, opt := range opts {
+		opt(calc)
+	}
+	if calc.clock == nil {
+		calc.clock = clock.Real()
+	}
+	if calc.calendar == nil {
+		calc.calendar = holiday.Default()
+	}
+	return calc
+}
+
+// Calendar returns the business-day calendar used by the calculator
+func (c *Calculator) Calendar() *holiday.Calendar {
+	return c.calendar
+}
+
+// Rule returns the response period started by a status, if any
+func (c *Calculator) Rule(code types.CaseStatusCode) (Rule, bool) {
+	r, ok := c.rules[code]
+	return r, ok
+}
+
+// Compute returns the deadline for a notice sent on noticeDate
+func (c *Calculator) Compute(rule Rule, noticeDate time.Time) Deadline {
+	notice := holiday.Day(noticeDate.UTC())
+	d := Deadline{
+		Kind:       rule.Kind,
+		Label:      rule.Label,
+		NoticeDate: notice,
+		Days:       rule.Days,
+	}
+	if c.mailed {
+		d.MailingDays = rule.MailingDays
+	}
+
+	due := notice.AddDate(0, 0, d.Days+d.MailingDays)
+	d.DueAt = c.calendar.NextBusinessDay(due)
+	if !d.DueAt.Equal(due) {
+		if name, ok := c.calendar.Closure(due); ok {
+			d.RolledOver = name
+		} else {
+			d.RolledOver = due.Weekday().String()
+		}
+	}
+
+	for _, n := range c.checkpoints {
+		at := c.calendar.AddBusinessDays(d.DueAt, -n)
+		if at.Before(notice) {
+			continue
+		}
+		d.Checkpoints = append(d.Checkpoints, Checkpoint{BusinessDaysBefore: n, At: at})
+	}
+	sort.Slice(d.Checkpoints, func(i, j int) bool {
+		return d.Checkpoints[i].At.Before(d.Checkpoints[j].At)
+	})
+
+	c.refresh(&d)
+	return d
+}
+
+// refresh updates the fields that depend on the current time
+func (c *Calculator) refresh(d *Deadline) {
+	today := holiday.Day(c.clock.Now().UTC())
+	d.DaysRemaining = int(d.DueAt.Sub(today).Hours() / 24)
+	d.BusinessDaysRemaining = c.calendar.BusinessDaysBetween(today, d.DueAt)
+	d.Overdue = !d.Answered() && today.After(d.DueAt)
+	for i := range d.Checkpoints {
+		d.Checkpoints[i].Passed = !today.Before(d.Checkpoints[i].At)
+	}
+}
+
+// ForTimeline returns the deadline started by each notice on a timeline, in
+// timeline order. A deadline counts as answered when a status in its rule's
+// AnsweredBy appears on or after the notice and before the next notice of
+// the same kind.
+func (c *Calculator) ForTimeline(timeline *types.CaseTimeline) []Deadline {
+	if timeline == nil {
+		return nil
+	}
+	var deadlines []Deadline
+	for i, e := range timeline.Events {
+		rule, ok := c.rules[e.Status.Code]
+		if !ok {
+			continue
+		}
+		d := c.Compute(rule, e.Date)
+		d.ReceiptNumber = timeline.ReceiptNumber
+		d.Status = e.Status.Code
+		if answered, ok := answeredAt(timeline.Events[i+1:], rule, e.Status.Code); ok {
+			d.AnsweredAt = answered
+			c.refresh(&d)
+		}
+		deadlines = append(deadlines, d)
+	}
+	return deadlines
+}
+
+// answeredAt finds the first answering status among later events, stopping
+// at a repeat of the notice
+func answeredAt(later []types.TimelineEvent, rule Rule, notice types.CaseStatusCode) (time.Time, bool) {
+	for _, e := range later {
+		if e.Status.Code == notice {
+			break
+		}
+		for _, code := range rule.AnsweredBy {
+			if e.Status.Code == code {
+				return e.Date, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// Open returns the deadlines on a timeline that still need a response
+func (c *Calculator) Open(timeline *types.CaseTimeline) []Deadline {
+	var open []Deadline
+	for _, d := range c.ForTimeline(timeline) {
+		if d.Open() {
+			open = append(open, d)
+		}
+	}
+	return open
+}
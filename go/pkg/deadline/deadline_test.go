@@ -0,0 +1,129 @@
+package deadline
+
+import (
+	"testing"
+	"time"
+
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/types"
+)
+
+func day(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestCompute(t *testing.T) {
+	rfe, _ := New().Rule(types.StatusRFESent)
+	tests := []struct {
+		name       string
+		notice     time.Time
+		mailed     bool
+		wantDue    time.Time
+		rolledOver string
+	}{
+		{"business day", day(2025, 2, 3), true, day(2025, 5, 1), ""},
+		{"falls on a holiday", day(2025, 2, 28), true, day(2025, 5, 27), "Memorial Day"},
+		{"falls on a weekend", day(2025, 2, 5), true, day(2025, 5, 5), "Saturday"},
+		{"served electronically", day(2025, 2, 3), false, day(2025, 4, 28), ""},
+		{"notice time ignored", time.Date(2025, 2, 3, 22, 30, 0, 0, time.UTC), true, day(2025, 5, 1), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calc := New(WithMailing(tt.mailed), WithClock(clock.NewFake(day(2025, 1, 1))))
+			d := calc.Compute(rfe, tt.notice)
+			if !d.DueAt.Equal(tt.wantDue) {
+				t.Errorf("DueAt = %s, want %s", d.DueAt.Format("2006-01-02"), tt.wantDue.Format("2006-01-02"))
+			}
+			if d.RolledOver != tt.rolledOver {
+				t.Errorf("RolledOver = %q, want %q", d.RolledOver, tt.rolledOver)
+			}
+		})
+	}
+}
+
+func TestCheckpoints(t *testing.T) {
+	fake := clock.NewFake(time.Date(2025, 4, 20, 9, 0, 0, 0, time.UTC))
+	rfe, _ := New().Rule(types.StatusRFESent)
+	d := New(WithClock(fake)).Compute(rfe, day(2025, 2, 3))
+
+	want := []struct {
+		before int
+		at     time.Time
+		passed bool
+	}{
+		{20, day(2025, 4, 3), true},
+		{10, day(2025, 4, 17), true},
+		{5, day(2025, 4, 24), false},
+		{2, day(2025, 4, 29), false},
+	}
+	if len(d.Checkpoints) != len(want) {
+		t.Fatalf("checkpoints = %d, want %d", len(d.Checkpoints), len(want))
+	}
+	for i, w := range want {
+		c := d.Checkpoints[i]
+		if c.BusinessDaysBefore != w.before || !c.At.Equal(w.at) || c.Passed != w.passed {
+			t.Errorf("checkpoint %d = %+v, want %d days before on %s (passed %v)", i, c, w.before, w.at.Format("2006-01-02"), w.passed)
+		}
+	}
+	if next, ok := d.NextCheckpoint(); !ok || next.BusinessDaysBefore != 5 {
+		t.Errorf("NextCheckpoint() = %+v, %v", next, ok)
+	}
+	if d.DaysRemaining != 11 || d.BusinessDaysRemaining != 9 {
+		t.Errorf("remaining = %d days, %d business days; want 11 and 9", d.DaysRemaining, d.BusinessDaysRemaining)
+	}
+	if !d.Open() || d.Overdue {
+		t.Errorf("deadline should be open")
+	}
+
+	short := New(WithClock(fake), WithCheckpoints(100, 1)).Compute(rfe, day(2025, 2, 3))
+	if len(short.Checkpoints) != 1 || short.Checkpoints[0].BusinessDaysBefore != 1 {
+		t.Errorf("checkpoints before the notice should be dropped: %+v", short.Checkpoints)
+	}
+}
+
+func TestForTimeline(t *testing.T) {
+	timeline := types.NewCaseTimeline("IOE0912345678",
+		types.NewTimelineEvent(day(2024, 6, 3), "Case Was Received", types.SourceHistory),
+		types.NewTimelineEvent(day(2024, 8, 5), "Request for Evidence Was Sent", types.SourceHistory),
+		types.NewTimelineEvent(day(2024, 9, 16), "Response To USCIS' Request For Evidence Was Received", types.SourceHistory),
+		types.NewTimelineEvent(day(2025, 2, 3), "Request for Evidence Was Sent", types.SourceHistory),
+	)
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		answered []bool
+		overdue  []bool
+		open     int
+	}{
+		{"before second due date", day(2025, 3, 1), []bool{true, false}, []bool{false, false}, 1},
+		{"after second due date", day(2025, 5, 2), []bool{true, false}, []bool{false, true}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calc := New(WithClock(clock.NewFake(tt.now)))
+			deadlines := calc.ForTimeline(timeline)
+			if len(deadlines) != 2 {
+				t.Fatalf("ForTimeline() returned %d deadlines, want 2", len(deadlines))
+			}
+			for i, d := range deadlines {
+				if d.Kind != KindRFE || d.ReceiptNumber != "IOE0912345678" || d.Status != types.StatusRFESent {
+					t.Errorf("deadline %d = %+v", i, d)
+				}
+				if d.Answered() != tt.answered[i] {
+					t.Errorf("deadline %d answered = %v, want %v", i, d.Answered(), tt.answered[i])
+				}
+				if d.Overdue != tt.overdue[i] {
+					t.Errorf("deadline %d overdue = %v, want %v", i, d.Overdue, tt.overdue[i])
+				}
+			}
+			if got := len(calc.Open(timeline)); got != tt.open {
+				t.Errorf("Open() = %d deadlines, want %d", got, tt.open)
+			}
+		})
+	}
+
+	if got := New().ForTimeline(nil); got != nil {
+		t.Errorf("ForTimeline(nil) = %v, want nil", got)
+	}
+}
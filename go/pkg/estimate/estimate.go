@@ -0,0 +1,357 @@
+package estimate
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/forms"
+	"MyUSCISgo/pkg/receipt"
+)
+
+//go:embed processing_times.csv
+var embeddedDataset []byte
+
+// AnyOffice matches cases receipted at any office
+const AnyOffice = "*"
+
+// daysPerMonth converts fractional months into days
+const daysPerMonth = 30.44
+
+// ErrNoProcessingTime is returned when the dataset has no entry for a form
+var ErrNoProcessingTime = errors.New("no processing time available")
+
+// Entry is a single row of the processing-times dataset
+type Entry struct {
+	Form     string  `json:"form"`
+	Category string  `json:"category,omitempty"`
+	Office   string  `json:"office"`
+	P50      float64 `json:"p50"`
+	P80      float64 `json:"p80"`
+	P93      float64 `json:"p93"`
+}
+
+// validate checks that the percentiles are positive and non-decreasing
+func (e Entry) validate() error {
+	if e.Form == "" {
+		return errors.New("form is required")
+	}
+	if e.P50 <= 0 || e.P80 < e.P50 || e.P93 < e.P80 {
+		return fmt.Errorf("percentiles for %s must be positive and non-decreasing", e.Form)
+	}
+	return nil
+}
+
+// Dataset is a versioned table of processing times
+type Dataset struct {
+	Version string  `json:"version"`
+	Entries []Entry `json:"entries"`
+}
+
+// normalize canonicalizes form IDs, offices and categories
+func (d *Dataset) normalize() error {
+	for i := range d.Entries {
+		e := &d.Entries[i]
+		e.Form = forms.NormalizeID(e.Form)
+		e.Category = strings.ToLower(strings.TrimSpace(e.Category))
+		e.Office = strings.ToUpper(strings.TrimSpace(e.Office))
+		if e.Office == "" {
+			e.Office = AnyOffice
+		}
+		if err := e.validate(); err != nil {
+			return fmt.Errorf("entry %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// LoadCSV reads a dataset from CSV with the header
+// form,category,office,p50,p80,p93. Lines starting with # are comments; a
+// "# version: X" comment sets the dataset version.
+func LoadCSV(r io.Reader) (*Dataset, error) {
+	d := &Dataset{}
+	var body bytes.Buffer
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") {
+			comment := strings.TrimSpace(strings.TrimPrefix(trimmed, "#"))
+			if v, ok := strings.CutPrefix(comment, "version:"); ok {
+				d.Version = strings.TrimSpace(v)
+			}
+			continue
+		}
+		if trimmed == "" {
+			continue
+		}
+		body.WriteString(line)
+		body.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read processing times: %w", err)
+	}
+
+	records, err := csv.NewReader(&body).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse processing times CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("processing times CSV is empty")
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"form", "office", "p50", "p80", "p93"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("processing times CSV is missing column %q", required)
+		}
+	}
+
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	for n, row := range records[1:] {
+		e := Entry{
+			Form:     field(row, "form"),
+			Category: field(row, "category"),
+			Office:   field(row, "office"),
+		}
+		for name, dst := range map[string]*float64{"p50": &e.P50, "p80": &e.P80, "p93": &e.P93} {
+			v, err := strconv.ParseFloat(field(row, name), 64)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: invalid %s value %q", n+2, name, field(row, name))
+			}
+			*dst = v
+		}
+		d.Entries = append(d.Entries, e)
+	}
+
+	if err := d.normalize(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// LoadJSON reads a dataset from JSON
+func LoadJSON(r io.Reader) (*Dataset, error) {
+	d := &Dataset{}
+	if err := json.NewDecoder(r).Decode(d); err != nil {
+		return nil, fmt.Errorf("failed to parse processing times JSON: %w", err)
+	}
+	if err := d.normalize(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// LoadFile reads a dataset from a .csv or .json file
+func LoadFile(path string) (*Dataset, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open processing times: %w", err)
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return LoadCSV(f)
+	case ".json":
+		return LoadJSON(f)
+	default:
+		return nil, fmt.Errorf("unsupported processing times format %q", filepath.Ext(path))
+	}
+}
+
+// defaultDataset is parsed once from the embedded CSV
+var defaultDataset = mustLoadEmbedded()
+
+func mustLoadEmbedded() *Dataset {
+	d, err := LoadCSV(bytes.NewReader(embeddedDataset))
+	if err != nil {
+		panic(fmt.Sprintf("embedded processing times are invalid: %v", err))
+	}
+	return d
+}
+
+// Default returns the embedded dataset
+func Default() *Dataset {
+	return defaultDataset
+}
+
+// Lookup returns the most specific entry for a form, category and office.
+// An exact category beats a wildcard category, which beats an exact office.
+func (d *Dataset) Lookup(form, category, office string) (Entry, bool) {
+	form = forms.NormalizeID(form)
+	category = strings.ToLower(strings.TrimSpace(category))
+	office = strings.ToUpper(strings.TrimSpace(office))
+
+	best, bestScore := Entry{}, -1
+	for _, e := range d.Entries {
+		if e.Form != form {
+			continue
+		}
+		score := 0
+		switch {
+		case e.Category == "":
+		case e.Category == category:
+			score += 2
+		default:
+			continue
+		}
+		switch {
+		case e.Office == AnyOffice:
+		case e.Office == office:
+			score++
+		default:
+			continue
+		}
+		if score > bestScore {
+			best, bestScore = e, score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+// Phase describes where a case sits within its decision window
+type Phase string
+
+const (
+	// PhaseEarly means fewer than half of similar cases had been decided
+	PhaseEarly Phase = "early"
+	// PhaseTypical means the case is between the 50th and 80th percentile
+	PhaseTypical Phase = "typical"
+	// PhaseLate means the case is between the 80th and 93rd percentile
+	PhaseLate Phase = "late"
+	// PhaseOutsideNormal means the case has passed the 93rd percentile
+	PhaseOutsideNormal Phase = "outside_normal"
+)
+
+// Request describes the case to estimate
+type Request struct {
+	Receipt  receipt.Receipt
+	Form     string
+	Category string
+	// ReceivedAt overrides the date derived from the receipt number, which
+	// is required for electronic and lockbox receipts
+	ReceivedAt time.Time
+}
+
+// Estimate is an estimated decision window for a case
+type Estimate struct {
+	DatasetVersion string    `json:"datasetVersion"`
+	Form           string    `json:"form"`
+	Category       string    `json:"category,omitempty"`
+	Office         string    `json:"office"`
+	ReceivedAt     time.Time `json:"receivedAt"`
+	// MedianAt, LikelyAt and LatestAt are when 50%, 80% and 93% of
+	// similar cases were decided
+	MedianAt       time.Time `json:"medianAt"`
+	LikelyAt       time.Time `json:"likelyAt"`
+	LatestAt       time.Time `json:"latestAt"`
+	ElapsedDays    int       `json:"elapsedDays"`
+	PercentElapsed float64   `json:"percentElapsed"`
+	Phase          Phase     `json:"phase"`
+}
+
+// Estimator produces decision windows from a dataset
+type Estimator struct {
+	dataset *Dataset
+	clock   clock.Clock
+}
+
+// NewEstimator creates an estimator; a nil dataset uses the embedded one
+func NewEstimator(dataset *Dataset, c clock.Clock) *Estimator {
+	if dataset == nil {
+		dataset = Default()
+	}
+	if c == nil {
+		c = clock.Real()
+	}
+	return &Estimator{dataset: dataset, clock: c}
+}
+
+// Dataset returns the dataset used by the estimator
+func (e *Estimator) Dataset() *Dataset {
+	return e.dataset
+}
+
+// addMonths adds fractional months to t
+func addMonths(t time.Time, months float64) time.Time {
+	return t.Add(time.Duration(months * daysPerMonth * float64(24*time.Hour)))
+}
+
+// Estimate computes the decision window for a case and how far through it the case is
+func (e *Estimator) Estimate(req Request) (*Estimate, error) {
+	form := req.Form
+	if form == "" {
+		return nil, errors.New("form type is required")
+	}
+
+	entry, ok := e.dataset.Lookup(form, req.Category, req.Receipt.Center.Canonical)
+	if !ok {
+		return nil, fmt.Errorf("%w for %s", ErrNoProcessingTime, forms.NormalizeID(form))
+	}
+
+	received := req.ReceivedAt
+	if received.IsZero() {
+		approx, ok := req.Receipt.ApproxReceivedDate()
+		if !ok {
+			return nil, fmt.Errorf("receipt %s does not encode a date; a received date is required", req.Receipt.Number)
+		}
+		received = approx
+	}
+	received = received.UTC()
+
+	now := e.clock.Now().UTC()
+	est := &Estimate{
+		DatasetVersion: e.dataset.Version,
+		Form:           entry.Form,
+		Category:       entry.Category,
+		Office:         entry.Office,
+		ReceivedAt:     received,
+		MedianAt:       addMonths(received, entry.P50),
+		LikelyAt:       addMonths(received, entry.P80),
+		LatestAt:       addMonths(received, entry.P93),
+	}
+
+	elapsed := now.Sub(received)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	est.ElapsedDays = int(elapsed.Hours() / 24)
+
+	window := est.LatestAt.Sub(received)
+	est.PercentElapsed = float64(int(elapsed.Seconds()/window.Seconds()*1000)) / 10
+
+	switch {
+	case now.Before(est.MedianAt):
+		est.Phase = PhaseEarly
+	case now.Before(est.LikelyAt):
+		est.Phase = PhaseTypical
+	case now.Before(est.LatestAt):
+		est.Phase = PhaseLate
+	default:
+		est.Phase = PhaseOutsideNormal
+	}
+
+	return est, nil
+}
@@ -0,0 +1,180 @@
+package estimate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/receipt"
+)
+
+func mustParse(t *testing.T, s string) receipt.Receipt {
+	t.Helper()
+	r, err := receipt.Parse(s)
+	if err != nil {
+		t.Fatalf("receipt.Parse(%q) error = %v", s, err)
+	}
+	return r
+}
+
+func TestDefaultDataset(t *testing.T) {
+	d := Default()
+	if d.Version != "2025-06" {
+		t.Errorf("Version = %q", d.Version)
+	}
+	if len(d.Entries) == 0 {
+		t.Fatal("embedded dataset is empty")
+	}
+	for _, form := range []string{"I-130", "I-485", "I-765", "N-400"} {
+		if _, ok := d.Lookup(form, "", "MSC"); !ok {
+			t.Errorf("no entry for %s", form)
+		}
+	}
+}
+
+func TestLookupSpecificity(t *testing.T) {
+	d := Default()
+
+	tests := []struct {
+		name, form, category, office string
+		wantCategory, wantOffice     string
+	}{
+		{"exact office", "I-140", "", "LIN", "", "LIN"},
+		{"wildcard office", "I-140", "", "MSC", "", "*"},
+		{"category match", "I-765", "c09", "MSC", "c09", "*"},
+		{"unknown category falls back", "I-765", "c33", "MSC", "", "*"},
+		{"normalized form id", "i129", "", "EAC", "", "EAC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, ok := d.Lookup(tt.form, tt.category, tt.office)
+			if !ok {
+				t.Fatal("Lookup() found nothing")
+			}
+			if e.Category != tt.wantCategory || e.Office != tt.wantOffice {
+				t.Errorf("Lookup() = %+v, want category %q office %q", e, tt.wantCategory, tt.wantOffice)
+			}
+		})
+	}
+
+	if _, ok := d.Lookup("I-999", "", "EAC"); ok {
+		t.Error("Lookup() should not match unknown forms")
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	d, err := LoadCSV(strings.NewReader("# version: test\nform,category,office,p50,p80,p93\nI-485,,*,10,15,20\n"))
+	if err != nil {
+		t.Fatalf("LoadCSV() error = %v", err)
+	}
+
+	received := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		now       time.Time
+		wantPhase Phase
+	}{
+		{"early", received.AddDate(0, 3, 0), PhaseEarly},
+		{"typical", received.AddDate(0, 12, 0), PhaseTypical},
+		{"late", received.AddDate(0, 17, 0), PhaseLate},
+		{"outside normal", received.AddDate(2, 0, 0), PhaseOutsideNormal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEstimator(d, clock.NewFake(tt.now))
+			est, err := e.Estimate(Request{
+				Receipt:    mustParse(t, "IOE0912345678"),
+				Form:       "I-485",
+				ReceivedAt: received,
+			})
+			if err != nil {
+				t.Fatalf("Estimate() error = %v", err)
+			}
+			if est.Phase != tt.wantPhase {
+				t.Errorf("Phase = %s, want %s", est.Phase, tt.wantPhase)
+			}
+			if !est.MedianAt.Before(est.LikelyAt) || !est.LikelyAt.Before(est.LatestAt) {
+				t.Errorf("window out of order: %+v", est)
+			}
+			if est.DatasetVersion != "test" {
+				t.Errorf("DatasetVersion = %q", est.DatasetVersion)
+			}
+		})
+	}
+}
+
+func TestEstimateUsesReceiptDate(t *testing.T) {
+	now := time.Date(2023, 7, 31, 0, 0, 0, 0, time.UTC)
+	e := NewEstimator(nil, clock.NewFake(now))
+
+	// LIN23 workday 123 is January 31, 2023 - six months before now
+	est, err := e.Estimate(Request{Receipt: mustParse(t, "LIN2312351234"), Form: "I-140"})
+	if err != nil {
+		t.Fatalf("Estimate() error = %v", err)
+	}
+	if got := est.ReceivedAt.Format("2006-01-02"); got != "2023-01-31" {
+		t.Errorf("ReceivedAt = %s", got)
+	}
+	if est.Office != "LIN" || est.ElapsedDays != 181 {
+		t.Errorf("estimate = %+v", est)
+	}
+	if est.PercentElapsed <= 0 || est.PercentElapsed >= 100 {
+		t.Errorf("PercentElapsed = %v", est.PercentElapsed)
+	}
+}
+
+func TestEstimateErrors(t *testing.T) {
+	e := NewEstimator(nil, clock.NewFake(time.Now()))
+
+	if _, err := e.Estimate(Request{Receipt: mustParse(t, "EAC2100150123"), Form: "I-999"}); !errors.Is(err, ErrNoProcessingTime) {
+		t.Errorf("unknown form error = %v", err)
+	}
+	if _, err := e.Estimate(Request{Receipt: mustParse(t, "IOE0912345678"), Form: "I-485"}); err == nil {
+		t.Error("expected error when no received date is available")
+	}
+	if _, err := e.Estimate(Request{Receipt: mustParse(t, "EAC2100150123")}); err == nil {
+		t.Error("expected error when form is missing")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := map[string]string{
+		"missing column":   "form,office,p50,p80\nI-485,*,1,2\n",
+		"bad number":       "form,category,office,p50,p80,p93\nI-485,,*,ten,15,20\n",
+		"decreasing times": "form,category,office,p50,p80,p93\nI-485,,*,10,5,20\n",
+	}
+	for name, input := range tests {
+		if _, err := LoadCSV(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "times.json")
+	data := `{"version":"user-1","entries":[{"form":"n400","office":"","p50":5,"p80":7,"p93":9}]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if d.Version != "user-1" || d.Entries[0].Form != "N-400" || d.Entries[0].Office != AnyOffice {
+		t.Errorf("dataset = %+v", d)
+	}
+
+	xmlPath := filepath.Join(dir, "times.xml")
+	if err := os.WriteFile(xmlPath, []byte("<times/>"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFile(xmlPath); err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("LoadFile(xml) error = %v, want unsupported format", err)
+	}
+}
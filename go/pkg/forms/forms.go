@@ -0,0 +1,248 @@
+package forms
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/types"
+)
+
+//go:embed catalog.json
+var embeddedCatalog []byte
+
+// Category groups forms by the kind of benefit requested
+type Category string
+
+const (
+	CategoryFamily                  Category = "family"
+	CategoryEmployment              Category = "employment"
+	CategoryEmploymentAuthorization Category = "employment_authorization"
+	CategoryPermanentResidence      Category = "permanent_residence"
+	CategoryNonimmigrant            Category = "nonimmigrant"
+	CategoryHumanitarian            Category = "humanitarian"
+	CategoryTravel                  Category = "travel"
+	CategoryCitizenship             Category = "citizenship"
+)
+
+// Workflow describes which status transitions are valid for a group of forms
+type Workflow struct {
+	// Anytime lists statuses that may follow any other status
+	Anytime     []types.CaseStatusCode                          `json:"anytime"`
+	Transitions map[types.CaseStatusCode][]types.CaseStatusCode `json:"transitions"`
+}
+
+// Form is a single entry in the form catalog
+type Form struct {
+	ID             string   `json:"id"`
+	Title          string   `json:"title"`
+	Category       Category `json:"category"`
+	Workflow       string   `json:"workflow"`
+	ReceiptCenters []string `json:"receiptCenters"`
+
+	workflow *Workflow
+}
+
+// Name returns the form ID and title, e.g. "I-130 Petition for Alien Relative"
+func (f *Form) Name() string {
+	return f.ID + " " + f.Title
+}
+
+// AllowsCenter reports whether the form is typically receipted at the center
+func (f *Form) AllowsCenter(center receipt.ServiceCenter) bool {
+	for _, code := range f.ReceiptCenters {
+		if code == center.Canonical || code == center.Code {
+			return true
+		}
+	}
+	return false
+}
+
+// CanTransition reports whether a case for this form may move between the
+// two statuses. Repeating a status and transitions involving statuses
+// outside the taxonomy are always allowed, since USCIS titles change over
+// time and an unknown title is not evidence of an invalid case.
+func (f *Form) CanTransition(from, to types.CaseStatusCode) bool {
+	if from == to || from == types.StatusUnknown || to == types.StatusUnknown {
+		return true
+	}
+	if f.workflow == nil {
+		return true
+	}
+	for _, code := range f.workflow.Anytime {
+		if code == to {
+			return true
+		}
+	}
+	for _, code := range f.workflow.Transitions[from] {
+		if code == to {
+			return true
+		}
+	}
+	return false
+}
+
+// HasStatus reports whether the status appears anywhere in the form's workflow
+func (f *Form) HasStatus(code types.CaseStatusCode) bool {
+	if f.workflow == nil || code == types.StatusUnknown {
+		return true
+	}
+	for _, c := range f.workflow.Anytime {
+		if c == code {
+			return true
+		}
+	}
+	for from, targets := range f.workflow.Transitions {
+		if from == code {
+			return true
+		}
+		for _, c := range targets {
+			if c == code {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NextStatuses returns the statuses that may follow from
+func (f *Form) NextStatuses(from types.CaseStatusCode) []types.CaseStatusCode {
+	if f.workflow == nil {
+		return nil
+	}
+	next := append([]types.CaseStatusCode{}, f.workflow.Transitions[from]...)
+	return append(next, f.workflow.Anytime...)
+}
+
+// ValidateHistory checks that each consecutive pair of statuses is a valid transition
+func (f *Form) ValidateHistory(history []types.CaseStatusCode) error {
+	for i := 1; i < len(history); i++ {
+		if !f.CanTransition(history[i-1], history[i]) {
+			return fmt.Errorf("%s cases cannot move from %s to %s", f.ID, history[i-1], history[i])
+		}
+	}
+	return nil
+}
+
+// Catalog is a queryable set of forms
+type Catalog struct {
+	Version   string               `json:"version"`
+	Workflows map[string]*Workflow `json:"workflows"`
+	Forms     []*Form              `json:"forms"`
+
+	byID map[string]*Form
+}
+
+// LoadCatalog reads a catalog from JSON
+func LoadCatalog(r io.Reader) (*Catalog, error) {
+	var c Catalog
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return nil, fmt.Errorf("failed to decode form catalog: %w", err)
+	}
+
+	c.byID = make(map[string]*Form, len(c.Forms))
+	for _, f := range c.Forms {
+		id := NormalizeID(f.ID)
+		if _, dup := c.byID[id]; dup {
+			return nil, fmt.Errorf("duplicate form %s in catalog", f.ID)
+		}
+		wf, ok := c.Workflows[f.Workflow]
+		if !ok {
+			return nil, fmt.Errorf("form %s references unknown workflow %q", f.ID, f.Workflow)
+		}
+		f.workflow = wf
+		c.byID[id] = f
+	}
+
+	sort.Slice(c.Forms, func(i, j int) bool {
+		return c.Forms[i].ID < c.Forms[j].ID
+	})
+	return &c, nil
+}
+
+// defaultCatalog is parsed once from the embedded catalog
+var defaultCatalog = mustLoadEmbedded()
+
+func mustLoadEmbedded() *Catalog {
+	c, err := LoadCatalog(bytes.NewReader(embeddedCatalog))
+	if err != nil {
+		panic(fmt.Sprintf("embedded form catalog is invalid: %v", err))
+	}
+	return c
+}
+
+// Default returns the embedded form catalog
+func Default() *Catalog {
+	return defaultCatalog
+}
+
+// NormalizeID converts user input such as "i485" or "I 485" into the
+// catalog form "I-485"
+func NormalizeID(id string) string {
+	id = strings.ToUpper(strings.TrimSpace(id))
+	id = strings.NewReplacer(" ", "", "-", "").Replace(id)
+	if len(id) < 2 {
+		return id
+	}
+	return id[:1] + "-" + id[1:]
+}
+
+// Lookup returns the form with the given ID
+func (c *Catalog) Lookup(id string) (*Form, bool) {
+	f, ok := c.byID[NormalizeID(id)]
+	return f, ok
+}
+
+// Filter narrows the forms returned by List. Zero values match everything.
+type Filter struct {
+	Category Category `json:"category,omitempty"`
+	Center   string   `json:"center,omitempty"`
+}
+
+// List returns the forms matching the filter, sorted by ID
+func (c *Catalog) List(filter Filter) []*Form {
+	var center receipt.ServiceCenter
+	if filter.Center != "" {
+		var ok bool
+		if center, ok = receipt.LookupCenter(filter.Center); !ok {
+			return nil
+		}
+	}
+
+	var result []*Form
+	for _, f := range c.Forms {
+		if filter.Category != "" && f.Category != filter.Category {
+			continue
+		}
+		if filter.Center != "" && !f.AllowsCenter(center) {
+			continue
+		}
+		result = append(result, f)
+	}
+	return result
+}
+
+// Lookup returns the form with the given ID from the default catalog
+func Lookup(id string) (*Form, bool) {
+	return defaultCatalog.Lookup(id)
+}
+
+// List returns matching forms from the default catalog
+func List(filter Filter) []*Form {
+	return defaultCatalog.List(filter)
+}
+
+// ParseCaseType extracts the form from a case type such as
+// "I-485 Application to Register Permanent Residence"
+func ParseCaseType(caseType string) (*Form, bool) {
+	fields := strings.Fields(caseType)
+	if len(fields) == 0 {
+		return nil, false
+	}
+	return Lookup(fields[0])
+}
@@ -0,0 +1,130 @@
+package forms
+
+import (
+	"strings"
+	"testing"
+
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/types"
+)
+
+func TestDefaultCatalogCoversCoreForms(t *testing.T) {
+	for _, id := range []string{"I-129", "I-130", "I-131", "I-140", "I-485", "I-539", "I-751", "I-765", "I-821D", "N-400", "N-600"} {
+		f, ok := Lookup(id)
+		if !ok {
+			t.Errorf("Lookup(%q) not found", id)
+			continue
+		}
+		if f.Title == "" || f.Category == "" || len(f.ReceiptCenters) == 0 {
+			t.Errorf("form %s is missing metadata: %+v", id, f)
+		}
+		for _, code := range f.ReceiptCenters {
+			if _, ok := receipt.LookupCenter(code); !ok {
+				t.Errorf("form %s lists unknown center %s", id, code)
+			}
+		}
+	}
+}
+
+func TestCatalogStatusesAreKnown(t *testing.T) {
+	for name, wf := range Default().Workflows {
+		codes := append([]types.CaseStatusCode{}, wf.Anytime...)
+		for from, targets := range wf.Transitions {
+			codes = append(codes, from)
+			codes = append(codes, targets...)
+		}
+		for _, code := range codes {
+			if _, ok := types.CaseStatusFromCode(code); !ok {
+				t.Errorf("workflow %s references unknown status %s", name, code)
+			}
+		}
+	}
+}
+
+func TestNormalizeID(t *testing.T) {
+	tests := map[string]string{
+		"I-485":  "I-485",
+		"i485":   "I-485",
+		" I 765": "I-765",
+		"n400":   "N-400",
+		"I-821d": "I-821D",
+	}
+	for in, want := range tests {
+		if got := NormalizeID(in); got != want {
+			t.Errorf("NormalizeID(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	citizenship := List(Filter{Category: CategoryCitizenship})
+	if len(citizenship) == 0 {
+		t.Fatal("expected citizenship forms")
+	}
+	for _, f := range citizenship {
+		if f.Category != CategoryCitizenship {
+			t.Errorf("form %s has category %s", f.ID, f.Category)
+		}
+	}
+
+	// NBC is an alias of MSC and should match forms listing MSC
+	nbc := List(Filter{Center: "NBC"})
+	msc := List(Filter{Center: "MSC"})
+	if len(nbc) == 0 || len(nbc) != len(msc) {
+		t.Errorf("List(NBC) = %d forms, List(MSC) = %d forms", len(nbc), len(msc))
+	}
+
+	if got := List(Filter{Center: "ZZZ"}); got != nil {
+		t.Errorf("List(unknown center) = %v, want nil", got)
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	i485, _ := Lookup("I-485")
+	i130, _ := Lookup("I-130")
+
+	tests := []struct {
+		name string
+		form *Form
+		from types.CaseStatusCode
+		to   types.CaseStatusCode
+		want bool
+	}{
+		{"received to biometrics", i485, types.StatusReceived, types.StatusBiometricsScheduled, true},
+		{"approved to card", i485, types.StatusApproved, types.StatusCardProduced, true},
+		{"card delivered to rfe", i485, types.StatusCardDelivered, types.StatusRFESent, false},
+		{"petition has no cards", i130, types.StatusApproved, types.StatusCardMailed, false},
+		{"transfer allowed anytime", i130, types.StatusRFEResponseReceived, types.StatusTransferred, true},
+		{"unknown is lenient", i130, types.StatusUnknown, types.StatusApproved, true},
+		{"repeat is allowed", i130, types.StatusActivelyReviewed, types.StatusActivelyReviewed, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.form.CanTransition(tt.from, tt.to); got != tt.want {
+				t.Errorf("CanTransition(%s, %s) = %t, want %t", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+
+	err := i130.ValidateHistory([]types.CaseStatusCode{types.StatusReceived, types.StatusApproved, types.StatusCardMailed})
+	if err == nil || !strings.Contains(err.Error(), "cannot move") {
+		t.Errorf("ValidateHistory() error = %v", err)
+	}
+	if !i485.HasStatus(types.StatusCardDelivered) || i130.HasStatus(types.StatusCardDelivered) {
+		t.Error("HasStatus() mismatch for card statuses")
+	}
+}
+
+func TestLoadCatalogRejectsUnknownWorkflow(t *testing.T) {
+	_, err := LoadCatalog(strings.NewReader(`{"workflows":{},"forms":[{"id":"I-1","workflow":"missing"}]}`))
+	if err == nil || !strings.Contains(err.Error(), "unknown workflow") {
+		t.Errorf("LoadCatalog() error = %v", err)
+	}
+}
+
+func TestParseCaseType(t *testing.T) {
+	f, ok := ParseCaseType("I-485 Application to Register Permanent Residence")
+	if !ok || f.ID != "I-485" {
+		t.Errorf("ParseCaseType() = %v, %t", f, ok)
+	}
+}
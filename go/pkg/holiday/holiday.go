@@ -0,0 +1,241 @@
+// Package holiday provides the US federal holiday calendar and business-day
+// arithmetic used for USCIS deadlines.
+package holiday
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Holiday is a federal holiday in a given year. Observed is the day federal
+// offices close, which moves to the nearest weekday when the holiday falls
+// on a weekend.
+type Holiday struct {
+	Name     string    `json:"name"`
+	Date     time.Time `json:"date"`
+	Observed time.Time `json:"observed"`
+}
+
+// rule computes the date of a holiday in a year, or false when the holiday
+// was not observed that year
+type rule struct {
+	name string
+	date func(year int) (time.Time, bool)
+}
+
+// fixed returns a rule for a holiday on the same date every year
+func fixed(month time.Month, day int) func(int) (time.Time, bool) {
+	return func(year int) (time.Time, bool) {
+		return date(year, month, day), true
+	}
+}
+
+// nthWeekday returns a rule for the nth weekday of a month; n of -1 means
+// the last one
+func nthWeekday(month time.Month, weekday time.Weekday, n int) func(int) (time.Time, bool) {
+	return func(year int) (time.Time, bool) {
+		if n < 0 {
+			last := date(year, month+1, 0)
+			offset := (int(last.Weekday()) - int(weekday) + 7) % 7
+			return last.AddDate(0, 0, -offset), true
+		}
+		first := date(year, month, 1)
+		offset := (int(weekday) - int(first.Weekday()) + 7) % 7
+		return first.AddDate(0, 0, offset+7*(n-1)), true
+	}
+}
+
+// federalRules are the holidays of 5 U.S.C. 6103 in calendar order
+var federalRules = []rule{
+	{"New Year's Day", fixed(time.January, 1)},
+	{"Birthday of Martin Luther King, Jr.", nthWeekday(time.January, time.Monday, 3)},
+	{"Washington's Birthday", nthWeekday(time.February, time.Monday, 3)},
+	{"Memorial Day", nthWeekday(time.May, time.Monday, -1)},
+	{"Juneteenth National Independence Day", func(year int) (time.Time, bool) {
+		return date(year, time.June, 19), year >= 2021
+	}},
+	{"Independence Day", fixed(time.July, 4)},
+	{"Labor Day", nthWeekday(time.September, time.Monday, 1)},
+	{"Columbus Day", nthWeekday(time.October, time.Monday, 2)},
+	{"Veterans Day", fixed(time.November, 11)},
+	{"Thanksgiving Day", nthWeekday(time.November, time.Thursday, 4)},
+	{"Christmas Day", fixed(time.December, 25)},
+}
+
+// Federal returns the federal holidays of a year in date order
+func Federal(year int) []Holiday {
+	holidays := make([]Holiday, 0, len(federalRules))
+	for _, r := range federalRules {
+		d, ok := r.date(year)
+		if !ok {
+			continue
+		}
+		holidays = append(holidays, Holiday{Name: r.name, Date: d, Observed: observed(d)})
+	}
+	return holidays
+}
+
+// observed moves a Saturday holiday to Friday and a Sunday holiday to Monday
+func observed(d time.Time) time.Time {
+	switch d.Weekday() {
+	case time.Saturday:
+		return d.AddDate(0, 0, -1)
+	case time.Sunday:
+		return d.AddDate(0, 0, 1)
+	}
+	return d
+}
+
+// date returns midnight UTC on a date; out-of-range days normalize
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// Day truncates t to midnight UTC on its calendar date. Deadlines are whole
+// days, so every Calendar method works on days.
+func Day(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return date(y, m, d)
+}
+
+// Calendar answers business-day questions: weekdays that are neither
+// observed federal holidays nor extra closures. It is safe for concurrent
+// use.
+type Calendar struct {
+	mu       sync.Mutex
+	years    map[int]map[time.Time]string
+	closures map[time.Time]string
+}
+
+// NewCalendar creates a federal calendar. Closures adds days offices were
+// closed outside the holiday schedule, such as a lapse in appropriations or
+// an executive order.
+func NewCalendar(closures map[time.Time]string) *Calendar {
+	c := &Calendar{
+		years:    make(map[int]map[time.Time]string),
+		closures: make(map[time.Time]string, len(closures)),
+	}
+	for d, name := range closures {
+		c.closures[Day(d)] = name
+	}
+	return c
+}
+
+// defaultCalendar is shared by Default
+var defaultCalendar = NewCalendar(nil)
+
+// Default returns the federal calendar without extra closures
+func Default() *Calendar {
+	return defaultCalendar
+}
+
+// observedIn returns the observed holidays of a year, keyed by day. A New
+// Year's Day on a Saturday is observed on December 31 of the year before,
+// so each year also pulls in the following January.
+func (c *Calendar) observedIn(year int) map[time.Time]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if days, ok := c.years[year]; ok {
+		return days
+	}
+	days := make(map[time.Time]string)
+	for _, y := range []int{year, year + 1} {
+		for _, h := range Federal(y) {
+			if h.Observed.Year() == year {
+				days[h.Observed] = h.Name
+			}
+		}
+	}
+	c.years[year] = days
+	return days
+}
+
+// Closure returns the name of the holiday or closure on t's date, if any
+func (c *Calendar) Closure(t time.Time) (string, bool) {
+	d := Day(t)
+	if name, ok := c.observedIn(d.Year())[d]; ok {
+		return name, true
+	}
+	name, ok := c.closures[d]
+	return name, ok
+}
+
+// IsBusinessDay reports whether federal offices are open on t's date
+func (c *Calendar) IsBusinessDay(t time.Time) bool {
+	switch t.Weekday() {
+	case time.Saturday, time.Sunday:
+		return false
+	}
+	_, closed := c.Closure(t)
+	return !closed
+}
+
+// NextBusinessDay returns t's date if it is a business day, otherwise the
+// first business day after it. A period whose last day falls on a weekend
+// or holiday runs until the end of this day.
+func (c *Calendar) NextBusinessDay(t time.Time) time.Time {
+	d := Day(t)
+	for !c.IsBusinessDay(d) {
+		d = d.AddDate(0, 0, 1)
+	}
+	return d
+}
+
+// AddBusinessDays moves n business days from t's date; a negative n moves
+// backwards. Zero returns t's date unchanged.
+func (c *Calendar) AddBusinessDays(t time.Time, n int) time.Time {
+	d := Day(t)
+	step := 1
+	if n < 0 {
+		step, n = -1, -n
+	}
+	for n > 0 {
+		d = d.AddDate(0, 0, step)
+		if c.IsBusinessDay(d) {
+			n--
+		}
+	}
+	return d
+}
+
+// BusinessDaysBetween counts the business days after from up to and
+// including to. It is negative when to is before from.
+func (c *Calendar) BusinessDaysBetween(from, to time.Time) int {
+	start, end := Day(from), Day(to)
+	sign := 1
+	if end.Before(start) {
+		start, end, sign = end, start, -1
+	}
+	n := 0
+	for d := start.AddDate(0, 0, 1); !d.After(end); d = d.AddDate(0, 0, 1) {
+		if c.IsBusinessDay(d) {
+			n++
+		}
+	}
+	return sign * n
+}
+
+// Closures returns the observed holidays and extra closures between from
+// and to inclusive, in date order
+func (c *Calendar) Closures(from, to time.Time) []Holiday {
+	var out []Holiday
+	// the following January may be observed on the last day of the range
+	for year := Day(from).Year(); year <= Day(to).Year()+1; year++ {
+		out = append(out, Federal(year)...)
+	}
+	for d, name := range c.closures {
+		out = append(out, Holiday{Name: name, Date: d, Observed: d})
+	}
+	start, end := Day(from), Day(to)
+	filtered := out[:0]
+	for _, h := range out {
+		if !h.Observed.Before(start) && !h.Observed.After(end) {
+			filtered = append(filtered, h)
+		}
+	}
+	sort.Slice(filtered, func(i, j int) bool {
+		return filtered[i].Observed.Before(filtered[j].Observed)
+	})
+	return filtered
+}
@@ -0,0 +1,121 @@
+package holiday
+
+import (
+	"testing"
+	"time"
+)
+
+func day(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestFederal(t *testing.T) {
+	want := map[string]time.Time{
+		"New Year's Day":                       day(2025, 1, 1),
+		"Birthday of Martin Luther King, Jr.":  day(2025, 1, 20),
+		"Washington's Birthday":                day(2025, 2, 17),
+		"Memorial Day":                         day(2025, 5, 26),
+		"Juneteenth National Independence Day": day(2025, 6, 19),
+		"Independence Day":                     day(2025, 7, 4),
+		"Labor Day":                            day(2025, 9, 1),
+		"Columbus Day":                         day(2025, 10, 13),
+		"Veterans Day":                         day(2025, 11, 11),
+		"Thanksgiving Day":                     day(2025, 11, 27),
+		"Christmas Day":                        day(2025, 12, 25),
+	}
+	got := Federal(2025)
+	if len(got) != len(want) {
+		t.Fatalf("Federal(2025) returned %d holidays, want %d", len(got), len(want))
+	}
+	for i, h := range got {
+		if !h.Date.Equal(want[h.Name]) {
+			t.Errorf("%s = %s, want %s", h.Name, h.Date.Format("2006-01-02"), want[h.Name].Format("2006-01-02"))
+		}
+		if i > 0 && h.Date.Before(got[i-1].Date) {
+			t.Errorf("holidays not in date order at %s", h.Name)
+		}
+	}
+
+	if n := len(Federal(2020)); n != 10 {
+		t.Errorf("Federal(2020) returned %d holidays, want 10 (before Juneteenth)", n)
+	}
+}
+
+func TestIsBusinessDay(t *testing.T) {
+	c := NewCalendar(map[time.Time]string{
+		time.Date(2025, 3, 12, 15, 0, 0, 0, time.UTC): "Office closure",
+	})
+	tests := []struct {
+		name string
+		date time.Time
+		want bool
+	}{
+		{"weekday", day(2025, 3, 11), true},
+		{"saturday", day(2025, 3, 15), false},
+		{"sunday", day(2025, 3, 16), false},
+		{"holiday", day(2025, 7, 4), false},
+		{"saturday holiday observed friday", day(2026, 7, 3), false},
+		{"sunday holiday observed monday", day(2023, 1, 2), false},
+		{"new year observed the year before", day(2021, 12, 31), false},
+		{"juneteenth observed friday", day(2021, 6, 18), false},
+		{"extra closure", day(2025, 3, 12), false},
+		{"time of day ignored", time.Date(2025, 7, 4, 23, 59, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.IsBusinessDay(tt.date); got != tt.want {
+				t.Errorf("IsBusinessDay(%s) = %v, want %v", tt.date.Format("2006-01-02"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBusinessDayArithmetic(t *testing.T) {
+	c := Default()
+
+	if got := c.NextBusinessDay(day(2025, 5, 24)); !got.Equal(day(2025, 5, 27)) {
+		t.Errorf("NextBusinessDay(Sat before Memorial Day) = %s, want 2025-05-27", got.Format("2006-01-02"))
+	}
+	if got := c.NextBusinessDay(day(2025, 5, 28)); !got.Equal(day(2025, 5, 28)) {
+		t.Errorf("NextBusinessDay(business day) = %s, want same day", got.Format("2006-01-02"))
+	}
+
+	tests := []struct {
+		from time.Time
+		n    int
+		want time.Time
+	}{
+		{day(2025, 5, 22), 2, day(2025, 5, 27)},
+		{day(2025, 5, 27), -1, day(2025, 5, 23)},
+		{day(2025, 12, 24), 1, day(2025, 12, 26)},
+		{day(2025, 3, 12), 0, day(2025, 3, 12)},
+	}
+	for _, tt := range tests {
+		if got := c.AddBusinessDays(tt.from, tt.n); !got.Equal(tt.want) {
+			t.Errorf("AddBusinessDays(%s, %d) = %s, want %s", tt.from.Format("2006-01-02"), tt.n, got.Format("2006-01-02"), tt.want.Format("2006-01-02"))
+		}
+		if tt.n != 0 {
+			if got := c.BusinessDaysBetween(tt.from, tt.want); got != tt.n {
+				t.Errorf("BusinessDaysBetween(%s, %s) = %d, want %d", tt.from.Format("2006-01-02"), tt.want.Format("2006-01-02"), got, tt.n)
+			}
+		}
+	}
+}
+
+func TestClosures(t *testing.T) {
+	c := NewCalendar(map[time.Time]string{day(2021, 12, 30): "Weather"})
+	got := c.Closures(day(2021, 12, 1), day(2021, 12, 31))
+	var names []string
+	for _, h := range got {
+		names = append(names, h.Name)
+	}
+	want := []string{"Christmas Day", "Weather", "New Year's Day"}
+	if len(names) != len(want) {
+		t.Fatalf("Closures() = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("Closures()[%d] = %s, want %s", i, names[i], want[i])
+		}
+	}
+}
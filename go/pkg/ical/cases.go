@@ -0,0 +1,293 @@
+package ical
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"MyUSCISgo/pkg/deadline"
+	"MyUSCISgo/pkg/estimate"
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/types"
+)
+
+const (
+	// DefaultAppointmentDuration is used for appointments without a length
+	DefaultAppointmentDuration = time.Hour
+
+	// uidDomain qualifies generated UIDs
+	uidDomain = "myuscis"
+)
+
+// Kind classifies the calendar entries produced for a case
+type Kind string
+
+const (
+	// KindNotice marks a status change from the case timeline
+	KindNotice Kind = "notice"
+	// KindBiometrics marks a biometrics appointment
+	KindBiometrics Kind = "biometrics"
+	// KindInterview marks an interview appointment
+	KindInterview Kind = "interview"
+	// KindOath marks an oath ceremony
+	KindOath Kind = "oath"
+	// KindResponseDue marks the due date of a response, such as to an RFE
+	// or NOID, or of an appeal
+	KindResponseDue Kind = "response_due"
+	// KindDecisionWindow marks the estimated decision window
+	KindDecisionWindow Kind = "decision_window"
+)
+
+// IsAppointment reports whether the kind is an appointment users attend
+func (k Kind) IsAppointment() bool {
+	return k == KindBiometrics || k == KindInterview || k == KindOath
+}
+
+// defaultReminders are the alarms attached to each kind unless overridden
+var defaultReminders = map[Kind][]time.Duration{
+	KindBiometrics:  {7 * 24 * time.Hour, 24 * time.Hour},
+	KindInterview:   {14 * 24 * time.Hour, 24 * time.Hour},
+	KindOath:        {7 * 24 * time.Hour, 24 * time.Hour},
+	KindResponseDue: {30 * 24 * time.Hour, 14 * 24 * time.Hour, 3 * 24 * time.Hour},
+}
+
+// Appointment is a scheduled appointment read from a notice. Case statuses
+// only say that an appointment notice was mailed, so the date, time and
+// location must come from the user.
+type Appointment struct {
+	ReceiptNumber string    `json:"receiptNumber"`
+	Kind          Kind      `json:"kind"`
+	Start         time.Time `json:"start"`
+	Minutes       int       `json:"durationMinutes,omitempty"`
+	Location      string    `json:"location,omitempty"`
+	Notes         string    `json:"notes,omitempty"`
+}
+
+// Case is everything known about a case that can appear on a calendar.
+// Record is required; Estimate and Appointments are optional.
+type Case struct {
+	Record       *types.CaseRecord
+	Estimate     *estimate.Estimate
+	Appointments []Appointment
+}
+
+// Builder turns cases into calendar events
+type Builder struct {
+	reminders map[Kind][]time.Duration
+	notices   bool
+	deadlines *deadline.Calculator
+}
+
+// Option configures a Builder
+type Option func(*Builder)
+
+// WithReminders replaces the alarms attached to events of a kind; no
+// durations removes them
+func WithReminders(kind Kind, before ...time.Duration) Option {
+	return func(b *Builder) {
+		b.reminders[kind] = append([]time.Duration(nil), before...)
+	}
+}
+
+// WithNotices controls whether every timeline status change is exported as
+// an all-day event. It is on by default.
+func WithNotices(enabled bool) Option {
+	return func(b *Builder) {
+		b.notices = enabled
+	}
+}
+
+// WithDeadlines sets the calculator for response due dates
+func WithDeadlines(calc *deadline.Calculator) Option {
+	return func(b *Builder) {
+		b.deadlines = calc
+	}
+}
+
+// NewBuilder creates a builder with the default reminders
+func NewBuilder(opts ...Option) *Builder {
+	b := &Builder{
+		reminders: make(map[Kind][]time.Duration, len(defaultReminders)),
+		notices:   true,
+		deadlines: deadline.New(),
+	}
+	for kind, before := range defaultReminders {
+		b.reminders[kind] = before
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// UID returns the stable identifier of a calendar entry. The key
+// distinguishes entries of the same kind; entries that should replace each
+// other on re-import, such as a rescheduled interview, share a key.
+func UID(receiptNumber string, kind Kind, key string) string {
+	sum := sha256.Sum256([]byte(receipt.Normalize(receiptNumber) + "|" + string(kind) + "|" + key))
+	return hex.EncodeToString(sum[:16]) + "@" + uidDomain
+}
+
+// Calendar builds a calendar from cases, ordered by start time
+func (b *Builder) Calendar(name string, cases ...Case) *Calendar {
+	cal := &Calendar{Name: name}
+	for _, c := range cases {
+		cal.Events = append(cal.Events, b.Events(c)...)
+	}
+	sort.SliceStable(cal.Events, func(i, j int) bool {
+		if !cal.Events[i].Start.Equal(cal.Events[j].Start) {
+			return cal.Events[i].Start.Before(cal.Events[j].Start)
+		}
+		return cal.Events[i].UID < cal.Events[j].UID
+	})
+	return cal
+}
+
+// Events returns the calendar entries for a case: timeline notices,
+// response due dates, appointments and the estimated decision window
+func (b *Builder) Events(c Case) []Event {
+	record := c.Record
+	if record == nil {
+		return nil
+	}
+	number := receipt.Normalize(record.ReceiptNumber)
+
+	var events []Event
+	if record.Timeline != nil {
+		if b.notices {
+			for _, e := range record.Timeline.Events {
+				events = append(events, b.notice(number, e))
+			}
+		}
+		for _, d := range b.deadlines.ForTimeline(record.Timeline) {
+			events = append(events, b.responseDue(number, d))
+		}
+	}
+	for _, a := range c.Appointments {
+		if receipt.Normalize(a.ReceiptNumber) != number || !a.Kind.IsAppointment() || a.Start.IsZero() {
+			continue
+		}
+		events = append(events, b.appointment(number, a))
+	}
+	// a decided case has no decision window left to wait for
+	if c.Estimate != nil && !record.Decided() {
+		events = append(events, b.decisionWindow(number, c.Estimate))
+	}
+	return events
+}
+
+// notice is an all-day entry for a status change
+func (b *Builder) notice(number string, e types.TimelineEvent) Event {
+	description := e.Status.Title
+	if e.NoticeType != "" {
+		description += "\nNotice: " + e.NoticeType
+	}
+	return Event{
+		UID:         UID(number, KindNotice, e.Key()),
+		Summary:     fmt.Sprintf("%s: %s", number, e.Status.Title),
+		Description: description,
+		Start:       dateOf(e.Date),
+		AllDay:      true,
+		Categories:  []string{"USCIS", string(KindNotice)},
+	}
+}
+
+// responseDue is an all-day entry on the day a response is due. Once
+// USCIS records a response the entry keeps its place but loses its
+// reminders.
+func (b *Builder) responseDue(number string, d deadline.Deadline) Event {
+	description := fmt.Sprintf("%s deadline for the notice sent %s (%d days plus %d for mailing).",
+		d.Label, d.NoticeDate.Format("January 2, 2006"), d.Days, d.MailingDays)
+	if d.RolledOver != "" {
+		description += fmt.Sprintf(" Moved past %s to the next business day.", d.RolledOver)
+	}
+	description += " The date printed on the notice takes precedence."
+	event := Event{
+		// keyed like the notice so the UID survives changes to the rules
+		UID:        UID(number, KindResponseDue, d.NoticeDate.Format("2006-01-02")+"|"+string(d.Status)),
+		Summary:    fmt.Sprintf("%s: %s deadline", number, d.Label),
+		Start:      d.DueAt,
+		AllDay:     true,
+		Categories: []string{"USCIS", string(KindResponseDue)},
+	}
+	if d.Answered() {
+		description += fmt.Sprintf("\nResponse received %s.", d.AnsweredAt.Format("January 2, 2006"))
+	} else {
+		event.Alarms = b.alarms(KindResponseDue, event.Summary)
+	}
+	event.Description = description
+	return event
+}
+
+// appointment is a timed entry for an appointment. One entry is kept per
+// kind so a rescheduled appointment replaces the old one.
+func (b *Builder) appointment(number string, a Appointment) Event {
+	duration := time.Duration(a.Minutes) * time.Minute
+	if duration <= 0 {
+		duration = DefaultAppointmentDuration
+	}
+	title := map[Kind]string{
+		KindBiometrics: "Biometrics appointment",
+		KindInterview:  "Interview",
+		KindOath:       "Oath ceremony",
+	}[a.Kind]
+	summary := fmt.Sprintf("%s: %s", number, title)
+	return Event{
+		UID:         UID(number, a.Kind, ""),
+		Summary:     summary,
+		Description: strings.TrimSpace(a.Notes + "\nBring the appointment notice and photo identification."),
+		Location:    a.Location,
+		Start:       a.Start.UTC(),
+		End:         a.Start.UTC().Add(duration),
+		Categories:  []string{"USCIS", string(a.Kind)},
+		Alarms:      b.alarms(a.Kind, summary),
+	}
+}
+
+// decisionWindow is an all-day entry spanning the 50th to 93rd percentile
+// of processing times. Its UID does not depend on the dates, so a revised
+// estimate replaces the previous one.
+func (b *Builder) decisionWindow(number string, est *estimate.Estimate) Event {
+	start := dateOf(est.MedianAt)
+	end := dateOf(est.LatestAt).AddDate(0, 0, 1)
+	description := fmt.Sprintf("Half of %s cases at %s are decided by %s, 80%% by %s and 93%% by %s.",
+		est.Form, est.Office,
+		est.MedianAt.Format("January 2, 2006"),
+		est.LikelyAt.Format("January 2, 2006"),
+		est.LatestAt.Format("January 2, 2006"))
+	if est.DatasetVersion != "" {
+		description += "\nProcessing times: " + est.DatasetVersion
+	}
+	return Event{
+		UID:         UID(number, KindDecisionWindow, ""),
+		Summary:     fmt.Sprintf("%s: estimated decision window", number),
+		Description: description,
+		Start:       start,
+		End:         end,
+		AllDay:      true,
+		Categories:  []string{"USCIS", string(KindDecisionWindow)},
+		Alarms:      b.alarms(KindDecisionWindow, ""),
+	}
+}
+
+// alarms returns the configured reminders for a kind
+func (b *Builder) alarms(kind Kind, description string) []Alarm {
+	before := b.reminders[kind]
+	if len(before) == 0 {
+		return nil
+	}
+	alarms := make([]Alarm, len(before))
+	for i, d := range before {
+		alarms[i] = Alarm{Before: d, Description: description}
+	}
+	return alarms
+}
+
+// dateOf truncates t to its UTC date
+func dateOf(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
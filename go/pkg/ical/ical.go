@@ -0,0 +1,241 @@
+// Package ical writes case events and deadlines as RFC 5545 iCalendar
+// files that calendar applications can import.
+package ical
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// MIMEType is the media type of an encoded calendar
+	MIMEType = "text/calendar; charset=utf-8"
+	// ProductID identifies the application that produced a calendar
+	ProductID = "-//MyUSCISgo//Case Calendar//EN"
+
+	// maxLineOctets is the longest content line allowed before folding
+	maxLineOctets = 75
+
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405Z"
+)
+
+// ErrInvalidEvent is returned when an event cannot be encoded
+var ErrInvalidEvent = errors.New("invalid calendar event")
+
+// Alarm is a display reminder shown some time before an event starts
+type Alarm struct {
+	Before      time.Duration `json:"before"`
+	Description string        `json:"description,omitempty"`
+}
+
+// Event is a single calendar entry. All-day events use only the date of
+// Start and End; End is exclusive and defaults to the day after Start.
+type Event struct {
+	UID         string    `json:"uid"`
+	Summary     string    `json:"summary"`
+	Description string    `json:"description,omitempty"`
+	Location    string    `json:"location,omitempty"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end,omitzero"`
+	AllDay      bool      `json:"allDay,omitempty"`
+	Categories  []string  `json:"categories,omitempty"`
+	Alarms      []Alarm   `json:"alarms,omitempty"`
+}
+
+// validate checks the fields required by RFC 5545
+func (e Event) validate() error {
+	if e.UID == "" {
+		return fmt.Errorf("%w: uid is required", ErrInvalidEvent)
+	}
+	if e.Start.IsZero() {
+		return fmt.Errorf("%w: %s has no start", ErrInvalidEvent, e.UID)
+	}
+	if !e.End.IsZero() && e.End.Before(e.Start) {
+		return fmt.Errorf("%w: %s ends before it starts", ErrInvalidEvent, e.UID)
+	}
+	for _, a := range e.Alarms {
+		if a.Before < 0 {
+			return fmt.Errorf("%w: %s has an alarm after the event", ErrInvalidEvent, e.UID)
+		}
+	}
+	return nil
+}
+
+// Calendar is a named collection of events
+type Calendar struct {
+	Name   string  `json:"name,omitempty"`
+	Events []Event `json:"events"`
+}
+
+// Encode writes the calendar to w. Stamp is written as the DTSTAMP of every
+// event; calendar applications use it to decide whether a re-imported event
+// with a known UID replaces the stored copy.
+func (c *Calendar) Encode(w io.Writer, stamp time.Time) error {
+	for _, e := range c.Events {
+		if err := e.validate(); err != nil {
+			return err
+		}
+	}
+
+	enc := &encoder{}
+	enc.line("BEGIN", "VCALENDAR")
+	enc.line("VERSION", "2.0")
+	enc.line("PRODID", ProductID)
+	enc.line("CALSCALE", "GREGORIAN")
+	enc.line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		enc.line("X-WR-CALNAME", escapeText(c.Name))
+	}
+	for _, e := range c.Events {
+		enc.event(e, stamp.UTC())
+	}
+	enc.line("END", "VCALENDAR")
+
+	_, err := w.Write(enc.buf.Bytes())
+	return err
+}
+
+// Bytes returns the encoded calendar
+func (c *Calendar) Bytes(stamp time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.Encode(&buf, stamp); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encoder accumulates folded content lines
+type encoder struct {
+	buf bytes.Buffer
+}
+
+// event writes a VEVENT and its alarms
+func (enc *encoder) event(e Event, stamp time.Time) {
+	enc.line("BEGIN", "VEVENT")
+	enc.line("UID", e.UID)
+	enc.line("DTSTAMP", stamp.Format(dateTimeLayout))
+	if e.AllDay {
+		start := e.Start.Format(dateLayout)
+		end := e.End
+		if !end.After(e.Start) {
+			end = e.Start.AddDate(0, 0, 1)
+		}
+		enc.line("DTSTART;VALUE=DATE", start)
+		enc.line("DTEND;VALUE=DATE", end.Format(dateLayout))
+	} else {
+		enc.line("DTSTART", e.Start.UTC().Format(dateTimeLayout))
+		if !e.End.IsZero() {
+			enc.line("DTEND", e.End.UTC().Format(dateTimeLayout))
+		}
+	}
+	enc.line("SUMMARY", escapeText(e.Summary))
+	if e.Description != "" {
+		enc.line("DESCRIPTION", escapeText(e.Description))
+	}
+	if e.Location != "" {
+		enc.line("LOCATION", escapeText(e.Location))
+	}
+	if len(e.Categories) > 0 {
+		escaped := make([]string, len(e.Categories))
+		for i, c := range e.Categories {
+			escaped[i] = escapeText(c)
+		}
+		enc.line("CATEGORIES", strings.Join(escaped, ","))
+	}
+	enc.line("TRANSP", "TRANSPARENT")
+	for _, a := range e.Alarms {
+		description := a.Description
+		if description == "" {
+			description = e.Summary
+		}
+		enc.line("BEGIN", "VALARM")
+		enc.line("ACTION", "DISPLAY")
+		enc.line("TRIGGER", "-"+formatDuration(a.Before))
+		enc.line("DESCRIPTION", escapeText(description))
+		enc.line("END", "VALARM")
+	}
+	enc.line("END", "VEVENT")
+}
+
+// line writes a property, folding it into lines of at most 75 octets. Folds
+// never split a UTF-8 sequence; continuation lines start with a space.
+func (enc *encoder) line(name, value string) {
+	s := name + ":" + value
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		enc.buf.WriteString(s[:cut])
+		enc.buf.WriteString("\r\n ")
+		s = s[cut:]
+		// the leading space counts towards the continuation line
+		limit = maxLineOctets - 1
+	}
+	enc.buf.WriteString(s)
+	enc.buf.WriteString("\r\n")
+}
+
+// escapeText escapes a TEXT value: backslashes, semicolons, commas and line
+// breaks. Other control characters are dropped.
+func escapeText(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ReplaceAll(s, "\r\n", "\n") {
+		switch {
+		case r == '\\' || r == ';' || r == ',':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// formatDuration renders a non-negative duration as an RFC 5545 DURATION,
+// e.g. P14D, PT2H or P1DT30M
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d <= 0 {
+		return "PT0S"
+	}
+	days := int64(d / (24 * time.Hour))
+	d -= time.Duration(days) * 24 * time.Hour
+	hours := int64(d / time.Hour)
+	d -= time.Duration(hours) * time.Hour
+	minutes := int64(d / time.Minute)
+	d -= time.Duration(minutes) * time.Minute
+	seconds := int64(d / time.Second)
+
+	var b strings.Builder
+	b.WriteByte('P')
+	if days > 0 {
+		b.WriteString(strconv.FormatInt(days, 10) + "D")
+	}
+	if hours > 0 || minutes > 0 || seconds > 0 {
+		b.WriteByte('T')
+		if hours > 0 {
+			b.WriteString(strconv.FormatInt(hours, 10) + "H")
+		}
+		if minutes > 0 {
+			b.WriteString(strconv.FormatInt(minutes, 10) + "M")
+		}
+		if seconds > 0 {
+			b.WriteString(strconv.FormatInt(seconds, 10) + "S")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,284 @@
+package ical
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"MyUSCISgo/pkg/estimate"
+	"MyUSCISgo/pkg/types"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// unfold reverses line folding and splits the calendar into content lines
+func unfold(t *testing.T, data string) []string {
+	t.Helper()
+	if !strings.HasSuffix(data, "\r\n") {
+		t.Fatalf("calendar does not end with CRLF")
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(data, "\r\n"), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("line exceeds %d octets: %q", maxLineOctets, line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("fold split a UTF-8 sequence: %q", line)
+		}
+	}
+	return strings.Split(strings.ReplaceAll(strings.TrimSuffix(data, "\r\n"), "\r\n ", ""), "\r\n")
+}
+
+func TestEncode(t *testing.T) {
+	stamp := time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC)
+	long := strings.Repeat("Évidence requested; reply, please\\ ", 6)
+	cal := &Calendar{
+		Name: "Cases, mine",
+		Events: []Event{
+			{
+				UID:         "a@myuscis",
+				Summary:     "All day",
+				Description: long + "\nsecond line",
+				Start:       date(2025, 3, 10),
+				AllDay:      true,
+				Categories:  []string{"USCIS", "a,b"},
+				Alarms:      []Alarm{{Before: 26*time.Hour + 30*time.Minute}},
+			},
+			{
+				UID:      "b@myuscis",
+				Summary:  "Timed",
+				Location: "Room 1",
+				Start:    time.Date(2025, 3, 11, 9, 0, 0, 0, time.FixedZone("EST", -5*3600)),
+				End:      time.Date(2025, 3, 11, 10, 0, 0, 0, time.FixedZone("EST", -5*3600)),
+			},
+		},
+	}
+
+	data, err := cal.Bytes(stamp)
+	if err != nil {
+		t.Fatalf("Bytes() error = %v", err)
+	}
+	lines := unfold(t, string(data))
+
+	wantLines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:" + ProductID,
+		`X-WR-CALNAME:Cases\, mine`,
+		"DTSTAMP:20250301T123000Z",
+		"DTSTART;VALUE=DATE:20250310",
+		"DTEND;VALUE=DATE:20250311",
+		"CATEGORIES:USCIS,a\\,b",
+		"TRIGGER:-P1DT2H30M",
+		"DESCRIPTION:All day",
+		"DTSTART:20250311T140000Z",
+		"DTEND:20250311T150000Z",
+		"LOCATION:Room 1",
+		"END:VCALENDAR",
+	}
+	joined := "\n" + strings.Join(lines, "\n") + "\n"
+	for _, want := range wantLines {
+		if !strings.Contains(joined, "\n"+want+"\n") {
+			t.Errorf("calendar missing line %q\n%s", want, data)
+		}
+	}
+	wantDescription := "DESCRIPTION:" + strings.Repeat(`Évidence requested\; reply\, please\\ `, 6) + `\nsecond line`
+	if !strings.Contains(joined, "\n"+wantDescription+"\n") {
+		t.Errorf("description not escaped and unfolded correctly\n%s", joined)
+	}
+	if got := strings.Count(joined, "BEGIN:VEVENT"); got != 2 {
+		t.Errorf("VEVENT count = %d, want 2", got)
+	}
+	if got := strings.Count(joined, "BEGIN:VALARM"); got != 1 {
+		t.Errorf("VALARM count = %d, want 1", got)
+	}
+}
+
+func TestEncodeRejectsInvalidEvents(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+	}{
+		{"missing uid", Event{Summary: "x", Start: date(2025, 1, 1)}},
+		{"missing start", Event{UID: "x"}},
+		{"ends before start", Event{UID: "x", Start: date(2025, 1, 2), End: date(2025, 1, 1)}},
+		{"alarm after event", Event{UID: "x", Start: date(2025, 1, 1), Alarms: []Alarm{{Before: -time.Hour}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cal := &Calendar{Events: []Event{tt.event}}
+			if _, err := cal.Bytes(time.Now()); !errors.Is(err, ErrInvalidEvent) {
+				t.Errorf("Bytes() error = %v, want ErrInvalidEvent", err)
+			}
+		})
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{0, "PT0S"},
+		{14 * 24 * time.Hour, "P14D"},
+		{2 * time.Hour, "PT2H"},
+		{24*time.Hour + 30*time.Minute, "P1DT30M"},
+		{90 * time.Second, "PT1M30S"},
+	}
+	for _, tt := range tests {
+		if got := formatDuration(tt.in); got != tt.want {
+			t.Errorf("formatDuration(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func rfeCase(responded bool) Case {
+	timeline := types.NewCaseTimeline("IOE0912345678",
+		types.NewTimelineEvent(date(2025, 1, 6), "Case Was Received", types.SourceHistory),
+		types.NewTimelineEvent(date(2025, 2, 3), "Request for Evidence Was Sent", types.SourceHistory),
+	)
+	if responded {
+		timeline.Add(types.NewTimelineEvent(date(2025, 3, 20), "Response To USCIS' Request For Evidence Was Received", types.SourceHistory))
+	}
+	latest, _ := timeline.Latest()
+	return Case{
+		Record: &types.CaseRecord{
+			ReceiptNumber: "ioe0912345678",
+			FormType:      "I-485",
+			Status:        latest.Status,
+			Timeline:      timeline,
+		},
+		Estimate: &estimate.Estimate{
+			DatasetVersion: "2025-01",
+			Form:           "I-485",
+			Office:         "NBC",
+			ReceivedAt:     date(2025, 1, 6),
+			MedianAt:       date(2025, 9, 1),
+			LikelyAt:       date(2025, 12, 1),
+			LatestAt:       date(2026, 3, 1),
+		},
+		Appointments: []Appointment{
+			{ReceiptNumber: "IOE0912345678", Kind: KindInterview, Start: time.Date(2025, 4, 2, 14, 0, 0, 0, time.UTC), Location: "Field office"},
+			{ReceiptNumber: "IOE0000000000", Kind: KindInterview, Start: date(2025, 4, 3)},
+			{ReceiptNumber: "IOE0912345678", Kind: KindNotice, Start: date(2025, 4, 3)},
+		},
+	}
+}
+
+func TestBuilderEvents(t *testing.T) {
+	events := NewBuilder().Events(rfeCase(false))
+
+	byCategory := map[string][]Event{}
+	for _, e := range events {
+		byCategory[e.Categories[1]] = append(byCategory[e.Categories[1]], e)
+	}
+	if got := len(byCategory[string(KindNotice)]); got != 2 {
+		t.Errorf("notice events = %d, want 2", got)
+	}
+	if got := len(byCategory[string(KindInterview)]); got != 1 {
+		t.Fatalf("interview events = %d, want 1 (other receipts and kinds are skipped)", got)
+	}
+
+	due := byCategory[string(KindResponseDue)]
+	if len(due) != 1 {
+		t.Fatalf("response due events = %d, want 1", len(due))
+	}
+	if want := date(2025, 5, 1); !due[0].Start.Equal(want) || !due[0].AllDay {
+		t.Errorf("response due = %v (all day %v), want %v", due[0].Start, due[0].AllDay, want)
+	}
+	if len(due[0].Alarms) != 3 {
+		t.Errorf("response due alarms = %d, want 3", len(due[0].Alarms))
+	}
+
+	interview := byCategory[string(KindInterview)][0]
+	if !interview.End.Equal(interview.Start.Add(DefaultAppointmentDuration)) || interview.Location != "Field office" {
+		t.Errorf("interview = %+v", interview)
+	}
+
+	window := byCategory[string(KindDecisionWindow)]
+	if len(window) != 1 {
+		t.Fatalf("decision window events = %d, want 1", len(window))
+	}
+	if !window[0].Start.Equal(date(2025, 9, 1)) || !window[0].End.Equal(date(2026, 3, 2)) {
+		t.Errorf("decision window = %v to %v", window[0].Start, window[0].End)
+	}
+
+	responded := NewBuilder().Events(rfeCase(true))
+	for _, e := range responded {
+		if e.Categories[1] == string(KindResponseDue) {
+			if len(e.Alarms) != 0 {
+				t.Errorf("answered RFE still has %d alarms", len(e.Alarms))
+			}
+			if e.UID != due[0].UID {
+				t.Errorf("response due UID changed after response: %s != %s", e.UID, due[0].UID)
+			}
+		}
+	}
+}
+
+func TestBuilderSkipsDecidedCases(t *testing.T) {
+	c := rfeCase(true)
+	c.Record.Timeline.Add(types.NewTimelineEvent(date(2025, 6, 2), "Case Was Approved", types.SourceHistory))
+	latest, _ := c.Record.Timeline.Latest()
+	c.Record.Status = latest.Status
+	for _, e := range NewBuilder().Events(c) {
+		if e.Categories[1] == string(KindDecisionWindow) {
+			t.Errorf("decision window exported for an approved case: %+v", e)
+		}
+	}
+}
+
+func TestBuilderUIDsAreStable(t *testing.T) {
+	first := rfeCase(false)
+	second := rfeCase(false)
+	second.Estimate.MedianAt = date(2025, 10, 1)
+	second.Appointments[0].Start = time.Date(2025, 5, 9, 10, 0, 0, 0, time.UTC)
+
+	uids := func(events []Event) map[string]bool {
+		set := map[string]bool{}
+		for _, e := range events {
+			if set[e.UID] {
+				t.Errorf("duplicate UID %s", e.UID)
+			}
+			set[e.UID] = true
+		}
+		return set
+	}
+	a, b := uids(NewBuilder().Events(first)), uids(NewBuilder().Events(second))
+	if len(a) != len(b) {
+		t.Fatalf("UID counts differ: %d != %d", len(a), len(b))
+	}
+	for uid := range a {
+		if !b[uid] {
+			t.Errorf("UID %s not stable across a reschedule and a revised estimate", uid)
+		}
+	}
+}
+
+func TestBuilderOptions(t *testing.T) {
+	b := NewBuilder(WithNotices(false), WithReminders(KindResponseDue), WithReminders(KindDecisionWindow, 7*24*time.Hour))
+	cal := b.Calendar("Cases", rfeCase(false))
+	for i, e := range cal.Events {
+		switch e.Categories[1] {
+		case string(KindNotice):
+			t.Errorf("notice exported with notices disabled")
+		case string(KindResponseDue):
+			if len(e.Alarms) != 0 {
+				t.Errorf("response due alarms = %d, want 0", len(e.Alarms))
+			}
+		case string(KindDecisionWindow):
+			if len(e.Alarms) != 1 || e.Alarms[0].Before != 7*24*time.Hour {
+				t.Errorf("decision window alarms = %+v", e.Alarms)
+			}
+		}
+		if i > 0 && cal.Events[i].Start.Before(cal.Events[i-1].Start) {
+			t.Errorf("events not ordered by start")
+		}
+	}
+	if _, err := cal.Bytes(time.Now()); err != nil {
+		t.Errorf("Bytes() error = %v", err)
+	}
+}
@@ -0,0 +1,327 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"MyUSCISgo/pkg/clock"
+)
+
+// State represents the lifecycle state of a job
+type State string
+
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	StateCancelled State = "cancelled"
+)
+
+// String returns the string representation of the state
+func (s State) String() string {
+	return string(s)
+}
+
+// IsTerminal reports whether the state is final
+func (s State) IsTerminal() bool {
+	switch s {
+	case StateSucceeded, StateFailed, StateCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// validTransitions lists the states reachable from each state
+var validTransitions = map[State][]State{
+	StateQueued:  {StateRunning, StateCancelled},
+	StateRunning: {StateSucceeded, StateFailed, StateCancelled},
+}
+
+// canTransition reports whether a job may move from one state to another
+func canTransition(from, to State) bool {
+	for _, s := range validTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrCancelled is returned by Wait when the job was cancelled
+var ErrCancelled = errors.New("job cancelled")
+
+// Progress is a progress report emitted by a running job
+type Progress struct {
+	JobID     string    `json:"jobId"`
+	State     State     `json:"state"`
+	Stage     string    `json:"stage,omitempty"`
+	Percent   int       `json:"percent"`
+	Message   string    `json:"message,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ReportFunc is used by job functions to publish progress
+type ReportFunc func(stage string, percent int, message string)
+
+// Func is the unit of work executed by a job
+type Func func(ctx context.Context, report ReportFunc) (any, error)
+
+// Snapshot is a point-in-time, JSON-friendly view of a job
+type Snapshot struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind"`
+	State      State      `json:"state"`
+	Progress   Progress   `json:"progress"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// Job is a handle to an asynchronous unit of work
+type Job struct {
+	id    string
+	kind  string
+	clock clock.Clock
+
+	mu          sync.Mutex
+	state       State
+	progress    Progress
+	result      any
+	err         error
+	createdAt   time.Time
+	startedAt   time.Time
+	finishedAt  time.Time
+	cancel      context.CancelFunc
+	done        chan struct{}
+	subscribers map[int]chan Progress
+	nextSubID   int
+}
+
+// newJob creates a queued job
+func newJob(id, kind string, c clock.Clock, cancel context.CancelFunc) *Job {
+	now := c.Now()
+	return &Job{
+		id:          id,
+		kind:        kind,
+		clock:       c,
+		state:       StateQueued,
+		progress:    Progress{JobID: id, State: StateQueued, Timestamp: now},
+		createdAt:   now,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+		subscribers: make(map[int]chan Progress),
+	}
+}
+
+// ID returns the job identifier
+func (j *Job) ID() string {
+	return j.id
+}
+
+// Kind returns the kind of work the job performs
+func (j *Job) Kind() string {
+	return j.kind
+}
+
+// State returns the current job state
+func (j *Job) State() State {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state
+}
+
+// Done returns a channel that is closed when the job reaches a terminal state
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Cancel requests cancellation of the job. A queued job is cancelled
+// immediately; a running job is cancelled through its context.
+func (j *Job) Cancel() {
+	j.cancel()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state == StateQueued {
+		j.finishLocked(StateCancelled, nil, ErrCancelled)
+	}
+}
+
+// Wait blocks until the job finishes or ctx is done and returns the job result
+func (j *Job) Wait(ctx context.Context) (any, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-j.done:
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.result, j.err
+}
+
+// Subscribe returns a channel of progress updates and a function to stop
+// receiving them. The latest progress is delivered immediately, and the
+// channel is closed once the job finishes. Slow subscribers miss
+// intermediate updates rather than blocking the job.
+func (j *Job) Subscribe() (<-chan Progress, func()) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	ch := make(chan Progress, 8)
+	ch <- j.progress
+	if j.state.IsTerminal() {
+		close(ch)
+		return ch, func() {}
+	}
+
+	id := j.nextSubID
+	j.nextSubID++
+	j.subscribers[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			j.mu.Lock()
+			defer j.mu.Unlock()
+			if sub, ok := j.subscribers[id]; ok {
+				delete(j.subscribers, id)
+				close(sub)
+			}
+		})
+	}
+}
+
+// Snapshot returns a point-in-time view of the job
+func (j *Job) Snapshot() Snapshot {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	snap := Snapshot{
+		ID:        j.id,
+		Kind:      j.kind,
+		State:     j.state,
+		Progress:  j.progress,
+		CreatedAt: j.createdAt,
+	}
+	if j.err != nil {
+		snap.Error = j.err.Error()
+	}
+	if !j.startedAt.IsZero() {
+		started := j.startedAt
+		snap.StartedAt = &started
+	}
+	if !j.finishedAt.IsZero() {
+		finished := j.finishedAt
+		snap.FinishedAt = &finished
+	}
+	return snap
+}
+
+// run executes fn and records its outcome
+func (j *Job) run(ctx context.Context, fn Func) {
+	j.mu.Lock()
+	if !canTransition(j.state, StateRunning) {
+		j.mu.Unlock()
+		return
+	}
+	j.state = StateRunning
+	j.startedAt = j.clock.Now()
+	j.publishLocked("", 0, "")
+	j.mu.Unlock()
+
+	result, err := j.invoke(ctx, fn)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	switch {
+	case err == nil:
+		j.finishLocked(StateSucceeded, result, nil)
+	case ctx.Err() != nil && errors.Is(err, ctx.Err()):
+		j.finishLocked(StateCancelled, nil, ErrCancelled)
+	default:
+		j.finishLocked(StateFailed, nil, err)
+	}
+}
+
+// invoke calls fn, converting panics into errors
+func (j *Job) invoke(ctx context.Context, fn Func) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return fn(ctx, j.report)
+}
+
+// report publishes progress from the job function
+func (j *Job) report(stage string, percent int, message string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state != StateRunning {
+		return
+	}
+	j.publishLocked(stage, percent, message)
+}
+
+// publishLocked records progress and fans it out to subscribers
+func (j *Job) publishLocked(stage string, percent int, message string) {
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+	if stage == "" {
+		stage = j.progress.Stage
+	}
+
+	j.progress = Progress{
+		JobID:     j.id,
+		State:     j.state,
+		Stage:     stage,
+		Percent:   percent,
+		Message:   message,
+		Timestamp: j.clock.Now(),
+	}
+
+	for _, ch := range j.subscribers {
+		select {
+		case ch <- j.progress:
+		default:
+		}
+	}
+}
+
+// finishLocked moves the job to a terminal state and releases waiters
+func (j *Job) finishLocked(state State, result any, err error) {
+	if !canTransition(j.state, state) {
+		return
+	}
+	j.state = state
+	j.result = result
+	j.err = err
+	j.finishedAt = j.clock.Now()
+
+	percent := j.progress.Percent
+	if state == StateSucceeded {
+		percent = 100
+	}
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+	j.publishLocked("", percent, message)
+
+	for id, ch := range j.subscribers {
+		close(ch)
+		delete(j.subscribers, id)
+	}
+	close(j.done)
+	j.cancel()
+}
@@ -0,0 +1,217 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"MyUSCISgo/pkg/clock"
+)
+
+var testEpoch = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func waitDone(t *testing.T, job *Job) {
+	t.Helper()
+	select {
+	case <-job.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("job %s did not finish, state %s", job.ID(), job.State())
+	}
+}
+
+func TestJobSucceeds(t *testing.T) {
+	r := NewRegistry(WithClock(clock.NewFake(testEpoch)))
+
+	job := r.Submit(context.Background(), "test", func(ctx context.Context, report ReportFunc) (any, error) {
+		report("step", 50, "halfway")
+		return "ok", nil
+	})
+
+	result, err := job.Wait(context.Background())
+	if err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if result != "ok" {
+		t.Errorf("result = %v, want ok", result)
+	}
+
+	snap := job.Snapshot()
+	if snap.State != StateSucceeded || snap.Progress.Percent != 100 {
+		t.Errorf("snapshot = %+v, want succeeded at 100%%", snap)
+	}
+	if snap.StartedAt == nil || snap.FinishedAt == nil {
+		t.Error("snapshot should record start and finish times")
+	}
+}
+
+func TestJobFails(t *testing.T) {
+	r := NewRegistry()
+	wantErr := errors.New("boom")
+
+	job := r.Submit(context.Background(), "test", func(ctx context.Context, report ReportFunc) (any, error) {
+		return nil, wantErr
+	})
+
+	if _, err := job.Wait(context.Background()); !errors.Is(err, wantErr) {
+		t.Errorf("Wait() error = %v, want %v", err, wantErr)
+	}
+	if job.State() != StateFailed {
+		t.Errorf("state = %s, want failed", job.State())
+	}
+	if job.Snapshot().Error != "boom" {
+		t.Errorf("snapshot error = %q", job.Snapshot().Error)
+	}
+}
+
+func TestJobPanicBecomesFailure(t *testing.T) {
+	r := NewRegistry()
+
+	job := r.Submit(context.Background(), "test", func(ctx context.Context, report ReportFunc) (any, error) {
+		panic("kaboom")
+	})
+
+	waitDone(t, job)
+	if job.State() != StateFailed {
+		t.Errorf("state = %s, want failed", job.State())
+	}
+}
+
+func TestCancelRunningJob(t *testing.T) {
+	r := NewRegistry()
+	started := make(chan struct{})
+
+	job := r.Submit(context.Background(), "test", func(ctx context.Context, report ReportFunc) (any, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	<-started
+	if err := r.Cancel(job.ID()); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+
+	if _, err := job.Wait(context.Background()); !errors.Is(err, ErrCancelled) {
+		t.Errorf("Wait() error = %v, want ErrCancelled", err)
+	}
+	if job.State() != StateCancelled {
+		t.Errorf("state = %s, want cancelled", job.State())
+	}
+}
+
+func TestCancelQueuedJob(t *testing.T) {
+	r := NewRegistry(WithMaxConcurrent(1))
+	release := make(chan struct{})
+
+	blocker := r.Submit(context.Background(), "test", func(ctx context.Context, report ReportFunc) (any, error) {
+		<-release
+		return nil, nil
+	})
+	queued := r.Submit(context.Background(), "test", func(ctx context.Context, report ReportFunc) (any, error) {
+		t.Error("cancelled job should never run")
+		return nil, nil
+	})
+
+	if queued.State() != StateQueued {
+		t.Fatalf("state = %s, want queued", queued.State())
+	}
+	queued.Cancel()
+	waitDone(t, queued)
+	if queued.State() != StateCancelled {
+		t.Errorf("state = %s, want cancelled", queued.State())
+	}
+
+	close(release)
+	waitDone(t, blocker)
+}
+
+func TestSubscribeReceivesProgress(t *testing.T) {
+	r := NewRegistry()
+	proceed := make(chan struct{})
+
+	job := r.Submit(context.Background(), "test", func(ctx context.Context, report ReportFunc) (any, error) {
+		<-proceed
+		report("fetch", 40, "")
+		return nil, nil
+	})
+
+	updates, stop := job.Subscribe()
+	defer stop()
+	close(proceed)
+
+	var sawStage bool
+	var last Progress
+	for p := range updates {
+		if p.Stage == "fetch" && p.Percent == 40 {
+			sawStage = true
+		}
+		last = p
+	}
+
+	if !sawStage {
+		t.Error("subscriber did not receive reported progress")
+	}
+	if last.State != StateSucceeded {
+		t.Errorf("last update state = %s, want succeeded", last.State)
+	}
+}
+
+func TestWaitHonoursContext(t *testing.T) {
+	r := NewRegistry()
+	release := make(chan struct{})
+	defer close(release)
+
+	job := r.Submit(context.Background(), "test", func(ctx context.Context, report ReportFunc) (any, error) {
+		<-release
+		return nil, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := job.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestRegistryListAndPurge(t *testing.T) {
+	fc := clock.NewFake(testEpoch)
+	r := NewRegistry(WithClock(fc))
+	release := make(chan struct{})
+
+	done := r.Submit(context.Background(), "fast", func(ctx context.Context, report ReportFunc) (any, error) {
+		return nil, nil
+	})
+	waitDone(t, done)
+	running := r.Submit(context.Background(), "slow", func(ctx context.Context, report ReportFunc) (any, error) {
+		<-release
+		return nil, nil
+	})
+
+	if got := len(r.List(Filter{})); got != 2 {
+		t.Errorf("List() returned %d jobs, want 2", got)
+	}
+	if got := r.List(Filter{Kind: "fast"}); len(got) != 1 || got[0].ID != done.ID() {
+		t.Errorf("List(kind=fast) = %+v", got)
+	}
+	if got := r.List(Filter{State: StateSucceeded}); len(got) != 1 {
+		t.Errorf("List(state=succeeded) returned %d jobs, want 1", len(got))
+	}
+
+	if n := r.Purge(time.Minute); n != 0 {
+		t.Errorf("Purge() removed %d recent jobs, want 0", n)
+	}
+	fc.Advance(2 * time.Minute)
+	if n := r.Purge(time.Minute); n != 1 {
+		t.Errorf("Purge() removed %d jobs, want 1", n)
+	}
+	if _, err := r.Get(done.ID()); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Get() after purge error = %v, want ErrJobNotFound", err)
+	}
+	if _, err := r.Get(running.ID()); err != nil {
+		t.Errorf("running job should survive purge: %v", err)
+	}
+
+	close(release)
+	waitDone(t, running)
+}
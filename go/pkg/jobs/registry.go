@@ -0,0 +1,174 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"MyUSCISgo/pkg/clock"
+)
+
+// ErrJobNotFound is returned when a job ID is not known to the registry
+var ErrJobNotFound = errors.New("job not found")
+
+// Registry tracks submitted jobs and runs them with bounded concurrency
+type Registry struct {
+	mu    sync.RWMutex
+	jobs  map[string]*Job
+	clock clock.Clock
+	slots chan struct{}
+}
+
+// Option configures a Registry
+type Option func(*Registry)
+
+// WithClock sets the clock used for job timestamps
+func WithClock(c clock.Clock) Option {
+	return func(r *Registry) {
+		if c != nil {
+			r.clock = c
+		}
+	}
+}
+
+// WithMaxConcurrent limits how many jobs run at once; extra jobs stay queued
+func WithMaxConcurrent(n int) Option {
+	return func(r *Registry) {
+		if n > 0 {
+			r.slots = make(chan struct{}, n)
+		}
+	}
+}
+
+// NewRegistry creates a new job registry
+func NewRegistry(opts ...Option) *Registry {
+	r := &Registry{
+		jobs:  make(map[string]*Job),
+		clock: clock.Real(),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// newJobID generates a random job identifier
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("job-%d", time.Now().UnixNano())
+	}
+	return "job-" + hex.EncodeToString(b)
+}
+
+// Submit registers a job and starts it in the background. Cancelling ctx
+// cancels the job.
+func (r *Registry) Submit(ctx context.Context, kind string, fn Func) *Job {
+	jobCtx, cancel := context.WithCancel(ctx)
+	job := newJob(newJobID(), kind, r.clock, cancel)
+
+	r.mu.Lock()
+	r.jobs[job.id] = job
+	r.mu.Unlock()
+
+	go func() {
+		if r.slots != nil {
+			select {
+			case r.slots <- struct{}{}:
+				defer func() { <-r.slots }()
+			case <-jobCtx.Done():
+				job.Cancel()
+				return
+			}
+		}
+		job.run(jobCtx, fn)
+	}()
+
+	return job
+}
+
+// Get returns the job with the given ID
+func (r *Registry) Get(id string) (*Job, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// Cancel cancels the job with the given ID
+func (r *Registry) Cancel(id string) error {
+	job, err := r.Get(id)
+	if err != nil {
+		return err
+	}
+	job.Cancel()
+	return nil
+}
+
+// Filter narrows the jobs returned by List. Zero values match everything.
+type Filter struct {
+	Kind  string
+	State State
+}
+
+// Matches reports whether the snapshot satisfies the filter
+func (f Filter) Matches(s Snapshot) bool {
+	if f.Kind != "" && s.Kind != f.Kind {
+		return false
+	}
+	if f.State != "" && s.State != f.State {
+		return false
+	}
+	return true
+}
+
+// List returns snapshots of matching jobs, oldest first
+func (r *Registry) List(filter Filter) []Snapshot {
+	r.mu.RLock()
+	snapshots := make([]Snapshot, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		if snap := job.Snapshot(); filter.Matches(snap) {
+			snapshots = append(snapshots, snap)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(snapshots, func(i, k int) bool {
+		if snapshots[i].CreatedAt.Equal(snapshots[k].CreatedAt) {
+			return snapshots[i].ID < snapshots[k].ID
+		}
+		return snapshots[i].CreatedAt.Before(snapshots[k].CreatedAt)
+	})
+	return snapshots
+}
+
+// Purge removes finished jobs that completed more than olderThan ago and
+// returns the number removed
+func (r *Registry) Purge(olderThan time.Duration) int {
+	cutoff := r.clock.Now().Add(-olderThan)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	removed := 0
+	for id, job := range r.jobs {
+		snap := job.Snapshot()
+		if !snap.State.IsTerminal() || snap.FinishedAt == nil {
+			continue
+		}
+		if snap.FinishedAt.After(cutoff) {
+			continue
+		}
+		delete(r.jobs, id)
+		removed++
+	}
+	return removed
+}
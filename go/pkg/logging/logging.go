@@ -6,6 +6,8 @@ import (
 	"runtime"
 	"strings"
 	"time"
+
+	"MyUSCISgo/pkg/validation"
 )
 
 // LogLevel represents the severity level of a log entry
@@ -205,6 +207,10 @@ func SanitizeLogData(data map[string]interface{}) map[string]interface{} {
 		case "authorization", "authorization_header":
 			sanitized[k] = sanitizeAuthorizationHeader(v, redacted)
 		default:
+			if kind, ok := validation.IdentifierKindForField(k); ok {
+				sanitized[k] = sanitizeIdentifier(kind, v, redacted)
+				continue
+			}
 			sanitized[k] = v
 		}
 	}
@@ -212,6 +218,16 @@ func SanitizeLogData(data map[string]interface{}) map[string]interface{} {
 	return sanitized
 }
 
+// sanitizeIdentifier masks immigration identifiers such as A-Numbers so only
+// their last characters are logged
+func sanitizeIdentifier(kind validation.IdentifierKind, value interface{}, redacted string) string {
+	str, ok := value.(string)
+	if !ok {
+		return redacted
+	}
+	return validation.MaskIdentifier(kind, str)
+}
+
 // sanitizeAuthorizationHeader safely masks authorization headers
 func sanitizeAuthorizationHeader(value interface{}, redacted string) string {
 	str, ok := value.(string)
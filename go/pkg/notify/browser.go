@@ -0,0 +1,72 @@
+//go:build js && wasm
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"syscall/js"
+
+	"MyUSCISgo/pkg/changes"
+)
+
+var (
+	// ErrBrowserUnsupported is returned when the Notification API is missing
+	ErrBrowserUnsupported = errors.New("browser does not support notifications")
+	// ErrBrowserPermission is returned when the user has not granted
+	// notification permission
+	ErrBrowserPermission = errors.New("notification permission not granted")
+)
+
+// Browser shows events as desktop notifications through the browser's
+// Notification API
+type Browser struct {
+	name string
+	icon string
+}
+
+// NewBrowser creates a browser notifier. icon is an optional image URL.
+func NewBrowser(name, icon string) *Browser {
+	return &Browser{name: name, icon: icon}
+}
+
+// Name returns the notifier's name
+func (b *Browser) Name() string {
+	return b.name
+}
+
+// Permission returns the current notification permission: "granted",
+// "denied" or "default", or "" when notifications are unsupported
+func (b *Browser) Permission() string {
+	api := js.Global().Get("Notification")
+	if api.IsUndefined() {
+		return ""
+	}
+	return api.Get("permission").String()
+}
+
+// Notify shows e as a notification. Notifications for the same event
+// replace each other through the event ID tag.
+func (b *Browser) Notify(ctx context.Context, e changes.Event) error {
+	api := js.Global().Get("Notification")
+	if api.IsUndefined() {
+		return Permanent(ErrBrowserUnsupported)
+	}
+	if api.Get("permission").String() != "granted" {
+		return Permanent(ErrBrowserPermission)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	msg := Format(e)
+	options := map[string]interface{}{
+		"body": msg.Body,
+		"tag":  e.ID,
+	}
+	if b.icon != "" {
+		options["icon"] = b.icon
+	}
+	api.New(msg.Subject, options)
+	return nil
+}
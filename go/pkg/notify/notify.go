@@ -0,0 +1,311 @@
+// Package notify delivers case change events to the people following a
+// case. A Dispatcher routes each event to notifiers (email, the browser,
+// webhook outbox endpoints) according to a list of rules; notifiers can be
+// wrapped with retry and rate limiting.
+package notify
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"MyUSCISgo/pkg/changes"
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/logging"
+	"MyUSCISgo/pkg/receipt"
+)
+
+// DefaultTimeout bounds a single asynchronous dispatch, including retries
+const DefaultTimeout = 2 * time.Minute
+
+var (
+	// ErrUnknownNotifier is returned when a rule names an unregistered notifier
+	ErrUnknownNotifier = errors.New("unknown notifier")
+	// ErrRateLimited is returned when a rate-limited notifier is over its limit
+	ErrRateLimited = errors.New("notifier rate limit exceeded")
+)
+
+// Notifier delivers events over one channel. Name identifies the notifier
+// in routing rules and must be unique within a Dispatcher.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, e changes.Event) error
+}
+
+// Rule routes matching events to notifiers. Empty Types or Receipts match
+// every event type or receipt.
+type Rule struct {
+	Types     []changes.Type `json:"types,omitempty"`
+	Receipts  []string       `json:"receipts,omitempty"`
+	Notifiers []string       `json:"notifiers"`
+}
+
+// Matches reports whether the rule applies to e
+func (r Rule) Matches(e changes.Event) bool {
+	if len(r.Types) > 0 && !slices.Contains(r.Types, e.Type) {
+		return false
+	}
+	if len(r.Receipts) > 0 && !slices.ContainsFunc(r.Receipts, func(s string) bool {
+		return receipt.Normalize(s) == e.ReceiptNumber
+	}) {
+		return false
+	}
+	return true
+}
+
+// Message is the human-readable form of an event used by email and browser
+// notifiers
+type Message struct {
+	Subject string
+	Body    string
+}
+
+// Format renders an event as a message
+func Format(e changes.Event) Message {
+	body := e.Summary() + "\n"
+	if e.Status.Title != "" {
+		body += "\nCurrent status: " + e.Status.Title
+	}
+	if e.Before != "" {
+		body += fmt.Sprintf("\nChanged from: %s\nChanged to: %s", e.Before, e.After)
+	}
+	body += "\nDate: " + e.OccurredAt.Format("January 2, 2006") + "\n"
+	return Message{
+		Subject: "Case " + e.ReceiptNumber + ": " + subjects[e.Type],
+		Body:    body,
+	}
+}
+
+// subjects are the message subjects for each event type
+var subjects = map[changes.Type]string{
+	changes.StatusChanged:   "status updated",
+	changes.HistoryAdded:    "new history entry",
+	changes.StageAdvanced:   "moved to the next stage",
+	changes.RFEIssued:       "evidence requested",
+	changes.CardProduced:    "card being produced",
+	changes.CaseTransferred: "transferred to a new office",
+}
+
+// options holds the settings shared by the dispatcher and notifiers
+type options struct {
+	logger  *logging.Logger
+	clock   clock.Clock
+	timeout time.Duration
+}
+
+// newOptions applies opts over the defaults
+func newOptions(opts []Option) options {
+	o := options{
+		logger:  logging.NewLogger(logging.LogLevelInfo),
+		clock:   clock.Real(),
+		timeout: DefaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Option configures a Dispatcher or notifier
+type Option func(*options)
+
+// WithLogger sets the logger
+func WithLogger(logger *logging.Logger) Option {
+	return func(o *options) {
+		if logger != nil {
+			o.logger = logger
+		}
+	}
+}
+
+// WithClock sets the time source
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		if c != nil {
+			o.clock = c
+		}
+	}
+}
+
+// WithTimeout bounds each asynchronous dispatch
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.timeout = d
+		}
+	}
+}
+
+// Dispatcher routes events to registered notifiers. Without rules every
+// event goes to every notifier. It is safe for concurrent use.
+type Dispatcher struct {
+	mu        sync.RWMutex
+	notifiers map[string]Notifier
+	rules     []Rule
+	opts      options
+	inflight  sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher with no notifiers
+func NewDispatcher(opts ...Option) *Dispatcher {
+	return &Dispatcher{
+		notifiers: make(map[string]Notifier),
+		opts:      newOptions(opts),
+	}
+}
+
+// Register adds a notifier, replacing any with the same name
+func (d *Dispatcher) Register(n Notifier) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.notifiers[n.Name()] = n
+}
+
+// Unregister removes a notifier and reports whether it was registered
+func (d *Dispatcher) Unregister(name string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.notifiers[name]
+	delete(d.notifiers, name)
+	return ok
+}
+
+// Notifiers returns the names of the registered notifiers, sorted
+func (d *Dispatcher) Notifiers() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	names := make([]string, 0, len(d.notifiers))
+	for name := range d.notifiers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// SetRules replaces the routing rules. Every notifier a rule names must be
+// registered.
+func (d *Dispatcher) SetRules(rules []Rule) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := validateRules(rules, d.notifiers); err != nil {
+		return err
+	}
+	d.rules = slices.Clone(rules)
+	return nil
+}
+
+// Reset replaces all notifiers and rules at once. On error the dispatcher
+// is left unchanged.
+func (d *Dispatcher) Reset(notifiers []Notifier, rules []Rule) error {
+	byName := make(map[string]Notifier, len(notifiers))
+	for _, n := range notifiers {
+		if _, dup := byName[n.Name()]; dup {
+			return fmt.Errorf("duplicate notifier %q", n.Name())
+		}
+		byName[n.Name()] = n
+	}
+	if err := validateRules(rules, byName); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.notifiers = byName
+	d.rules = slices.Clone(rules)
+	return nil
+}
+
+// validateRules checks that every rule names only known notifiers
+func validateRules(rules []Rule, notifiers map[string]Notifier) error {
+	for i, r := range rules {
+		if len(r.Notifiers) == 0 {
+			return fmt.Errorf("rule %d names no notifiers", i+1)
+		}
+		for _, name := range r.Notifiers {
+			if _, ok := notifiers[name]; !ok {
+				return fmt.Errorf("rule %d: %w %q", i+1, ErrUnknownNotifier, name)
+			}
+		}
+	}
+	return nil
+}
+
+// Route returns the notifiers an event should go to, each at most once
+func (d *Dispatcher) Route(e changes.Event) []Notifier {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if len(d.rules) == 0 {
+		all := make([]Notifier, 0, len(d.notifiers))
+		for _, n := range d.notifiers {
+			all = append(all, n)
+		}
+		slices.SortFunc(all, func(a, b Notifier) int {
+			return cmp.Compare(a.Name(), b.Name())
+		})
+		return all
+	}
+
+	var routed []Notifier
+	seen := make(map[string]bool)
+	for _, r := range d.rules {
+		if !r.Matches(e) {
+			continue
+		}
+		for _, name := range r.Notifiers {
+			n, ok := d.notifiers[name]
+			if !ok || seen[name] {
+				continue
+			}
+			seen[name] = true
+			routed = append(routed, n)
+		}
+	}
+	return routed
+}
+
+// Dispatch sends e to every routed notifier concurrently and joins their
+// errors
+func (d *Dispatcher) Dispatch(ctx context.Context, e changes.Event) error {
+	routed := d.Route(e)
+	errs := make([]error, len(routed))
+	var wg sync.WaitGroup
+	for i, n := range routed {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := n.Notify(ctx, e); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", n.Name(), err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Subscriber returns a change-bus subscriber that dispatches each event in
+// the background and logs delivery failures
+func (d *Dispatcher) Subscriber() changes.Subscriber {
+	return func(e changes.Event) {
+		d.inflight.Add(1)
+		go func() {
+			defer d.inflight.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), d.opts.timeout)
+			defer cancel()
+			if err := d.Dispatch(ctx, e); err != nil {
+				d.opts.logger.Error("Failed to deliver case notification", err, map[string]interface{}{
+					"caseNumber": e.ReceiptNumber,
+					"type":       string(e.Type),
+					"eventId":    e.ID,
+				})
+			}
+		}()
+	}
+}
+
+// Wait blocks until background dispatches started by Subscriber finish
+func (d *Dispatcher) Wait() {
+	d.inflight.Wait()
+}
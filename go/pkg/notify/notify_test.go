@@ -0,0 +1,221 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"MyUSCISgo/pkg/changes"
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/ratelimit"
+	"MyUSCISgo/pkg/types"
+)
+
+var testEpoch = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func testEvent(t changes.Type, receiptNumber string) changes.Event {
+	return changes.Event{
+		ID:            "evt-" + string(t),
+		Type:          t,
+		ReceiptNumber: receiptNumber,
+		Before:        "case_received",
+		After:         "rfe_sent",
+		Status:        types.ParseCaseStatus("Request for Evidence Was Sent"),
+		OccurredAt:    testEpoch.AddDate(0, 0, -1),
+		DetectedAt:    testEpoch,
+	}
+}
+
+// recorder is a Notifier that records deliveries and fails on demand
+type recorder struct {
+	name string
+	mu   sync.Mutex
+	got  []changes.Event
+	errs []error
+}
+
+func (r *recorder) Name() string { return r.name }
+
+func (r *recorder) Notify(ctx context.Context, e changes.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.got = append(r.got, e)
+	if len(r.errs) > 0 {
+		err := r.errs[0]
+		r.errs = r.errs[1:]
+		return err
+	}
+	return nil
+}
+
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.got)
+}
+
+func TestDispatcherRouting(t *testing.T) {
+	team := &recorder{name: "team"}
+	pager := &recorder{name: "pager"}
+	d := NewDispatcher()
+	d.Register(team)
+	d.Register(pager)
+
+	if got := d.Route(testEvent(changes.HistoryAdded, "EAC2190000001")); len(got) != 2 {
+		t.Errorf("without rules Route() = %d notifiers, want all 2", len(got))
+	}
+
+	if err := d.SetRules([]Rule{{Notifiers: []string{"email"}}}); !errors.Is(err, ErrUnknownNotifier) {
+		t.Errorf("SetRules() with unknown notifier error = %v", err)
+	}
+	if err := d.SetRules([]Rule{{Types: []changes.Type{changes.RFEIssued}}}); err == nil {
+		t.Error("SetRules() accepted a rule without notifiers")
+	}
+	err := d.SetRules([]Rule{
+		{Notifiers: []string{"team"}},
+		{Types: []changes.Type{changes.RFEIssued, changes.CardProduced}, Receipts: []string{"eac-2190000001"}, Notifiers: []string{"pager", "team"}},
+	})
+	if err != nil {
+		t.Fatalf("SetRules() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		event changes.Event
+		want  []string
+	}{
+		{"catch-all only", testEvent(changes.HistoryAdded, "EAC2190000001"), []string{"team"}},
+		{"matching type and receipt", testEvent(changes.RFEIssued, "EAC2190000001"), []string{"team", "pager"}},
+		{"other receipt", testEvent(changes.RFEIssued, "EAC2190000002"), []string{"team"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, n := range d.Route(tt.event) {
+				got = append(got, n.Name())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Route() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	pager.errs = []error{errors.New("pager down")}
+	err = d.Dispatch(context.Background(), testEvent(changes.RFEIssued, "EAC2190000001"))
+	if err == nil || !strings.Contains(err.Error(), "pager: pager down") {
+		t.Errorf("Dispatch() error = %v, want pager failure", err)
+	}
+	if team.count() != 1 || pager.count() != 1 {
+		t.Errorf("deliveries team=%d pager=%d, want 1 each", team.count(), pager.count())
+	}
+
+	if err := d.Reset([]Notifier{team, &recorder{name: "team"}}, nil); err == nil {
+		t.Error("Reset() accepted duplicate notifier names")
+	}
+	if err := d.Reset([]Notifier{team}, []Rule{{Notifiers: []string{"pager"}}}); !errors.Is(err, ErrUnknownNotifier) {
+		t.Errorf("Reset() with unknown notifier error = %v", err)
+	}
+	if got := d.Notifiers(); !reflect.DeepEqual(got, []string{"pager", "team"}) {
+		t.Errorf("failed Reset() changed notifiers to %v", got)
+	}
+
+	bus := changes.NewBus()
+	bus.Subscribe(d.Subscriber())
+	bus.Publish(testEvent(changes.CardProduced, "EAC2190000001"))
+	d.Wait()
+	if team.count() != 2 || pager.count() != 2 {
+		t.Errorf("after bus publish team=%d pager=%d, want 2 each", team.count(), pager.count())
+	}
+
+	if err := d.Reset([]Notifier{pager}, nil); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+	if err := d.Dispatch(context.Background(), testEvent(changes.HistoryAdded, "EAC2190000009")); err != nil {
+		t.Fatalf("Dispatch() error = %v", err)
+	}
+	if team.count() != 2 || pager.count() != 3 {
+		t.Errorf("after Reset() team=%d pager=%d, want 2 and 3", team.count(), pager.count())
+	}
+}
+
+// runWithClock runs fn while advancing fc whenever something waits on it
+func runWithClock(fc *clock.Fake, step time.Duration, fn func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	for {
+		select {
+		case err := <-done:
+			return err
+		default:
+		}
+		if fc.Waiters() > 0 {
+			fc.Advance(step)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	transient := errors.New("connection reset")
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   bool
+	}{
+		{"first try succeeds", nil, 1, false},
+		{"recovers", []error{transient, transient}, 3, false},
+		{"gives up", []error{transient, transient, transient, transient}, 3, true},
+		{"permanent", []error{Permanent(errors.New("bad request"))}, 1, true},
+		{"rate limited", []error{ErrRateLimited}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc := clock.NewFake(testEpoch)
+			rec := &recorder{name: "hook", errs: tt.errs}
+			n := WithRetry(rec, RetryPolicy{Attempts: 3, Base: time.Second, Max: time.Second}, fc)
+			if n.Name() != "hook" {
+				t.Errorf("Name() = %q", n.Name())
+			}
+			err := runWithClock(fc, time.Second, func() error {
+				return n.Notify(context.Background(), testEvent(changes.StatusChanged, "EAC2190000001"))
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Notify() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if rec.count() != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", rec.count(), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestWithRateLimit(t *testing.T) {
+	rec := &recorder{name: "hook"}
+	n := WithRateLimit(rec, ratelimit.NewRateLimiter(2, time.Hour))
+	var limited int
+	for i := 0; i < 4; i++ {
+		if err := n.Notify(context.Background(), testEvent(changes.StatusChanged, "EAC2190000001")); errors.Is(err, ErrRateLimited) {
+			limited++
+		}
+	}
+	if rec.count() != 2 || limited != 2 {
+		t.Errorf("delivered %d, limited %d; want 2 and 2", rec.count(), limited)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	msg := Format(testEvent(changes.RFEIssued, "EAC2190000001"))
+	if msg.Subject != "Case EAC2190000001: evidence requested" {
+		t.Errorf("Subject = %q", msg.Subject)
+	}
+	for _, want := range []string{"Request for Evidence Was Sent", "Changed from: case_received", "May 31, 2025"} {
+		if !strings.Contains(msg.Body, want) {
+			t.Errorf("Body = %q, want it to contain %q", msg.Body, want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+
+	"MyUSCISgo/pkg/changes"
+	"MyUSCISgo/pkg/clock"
+)
+
+// Email sends events as plain-text mail through an SMTP server
+type Email struct {
+	name  string
+	addr  string
+	from  *mail.Address
+	to    []*mail.Address
+	auth  smtp.Auth
+	clock clock.Clock
+}
+
+// NewEmail creates an email notifier that delivers through the SMTP server
+// at addr ("host:port"). auth may be nil for servers that accept
+// unauthenticated mail; STARTTLS is used whenever the server offers it.
+func NewEmail(name, addr, from string, to []string, auth smtp.Auth, opts ...Option) (*Email, error) {
+	if name == "" {
+		return nil, errors.New("email notifier name cannot be empty")
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("email %s: invalid server address %q", name, addr)
+	}
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("email %s: invalid sender %q: %w", name, from, err)
+	}
+	if len(to) == 0 {
+		return nil, fmt.Errorf("email %s: at least one recipient is required", name)
+	}
+	recipients := make([]*mail.Address, len(to))
+	for i, addr := range to {
+		if recipients[i], err = mail.ParseAddress(addr); err != nil {
+			return nil, fmt.Errorf("email %s: invalid recipient %q: %w", name, addr, err)
+		}
+	}
+	o := newOptions(opts)
+	return &Email{name: name, addr: addr, from: sender, to: recipients, auth: auth, clock: o.clock}, nil
+}
+
+// Name returns the notifier's name
+func (m *Email) Name() string {
+	return m.name
+}
+
+// Notify mails e to every recipient
+func (m *Email) Notify(ctx context.Context, e changes.Event) error {
+	msg := m.compose(e)
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to mail server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	host, _, _ := net.SplitHostPort(m.addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("mail server handshake failed: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}); err != nil {
+			return fmt.Errorf("mail server STARTTLS failed: %w", err)
+		}
+	}
+	if m.auth != nil {
+		if ok, _ := c.Extension("AUTH"); ok {
+			if err := c.Auth(m.auth); err != nil {
+				return Permanent(fmt.Errorf("mail server authentication failed: %w", err))
+			}
+		}
+	}
+	if err := c.Mail(m.from.Address); err != nil {
+		return fmt.Errorf("mail server rejected sender: %w", err)
+	}
+	for _, rcpt := range m.to {
+		if err := c.Rcpt(rcpt.Address); err != nil {
+			return fmt.Errorf("mail server rejected recipient %s: %w", rcpt.Address, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("mail server refused message: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("mail server rejected message: %w", err)
+	}
+	return c.Quit()
+}
+
+// compose builds the RFC 5322 message for e
+func (m *Email) compose(e changes.Event) []byte {
+	msg := Format(e)
+	to := make([]string, len(m.to))
+	for i, a := range m.to {
+		to[i] = a.String()
+	}
+
+	var b bytes.Buffer
+	header := func(k, v string) {
+		b.WriteString(k + ": " + v + "\r\n")
+	}
+	header("From", m.from.String())
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", stripNewlines(msg.Subject)))
+	header("Date", m.clock.Now().Format("Mon, 02 Jan 2006 15:04:05 -0700"))
+	header("Message-ID", fmt.Sprintf("<%s.%s@myuscis>", e.ID, e.DetectedAt.Format("20060102150405")))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "8bit")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// stripNewlines keeps user-influenced text from injecting headers
+func stripNewlines(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
@@ -0,0 +1,186 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"MyUSCISgo/pkg/changes"
+	"MyUSCISgo/pkg/clock"
+)
+
+// smtpMessage is a message captured by the SMTP stand-in
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// smtpStandIn is a minimal in-process SMTP server that accepts every
+// message, optionally rejecting recipients
+type smtpStandIn struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	reject   string
+	messages []smtpMessage
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &smtpStandIn{ln: ln}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *smtpStandIn) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *smtpStandIn) received() []smtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpMessage(nil), s.messages...)
+}
+
+func (s *smtpStandIn) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpStandIn) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		_, _ = conn.Write([]byte(line + "\r\n"))
+	}
+
+	var msg smtpMessage
+	reply("220 localhost stand-in ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg = smtpMessage{from: envelopeAddress(line[len("MAIL FROM:"):])}
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			rcpt := envelopeAddress(line[len("RCPT TO:"):])
+			s.mu.Lock()
+			rejected := rcpt == s.reject
+			s.mu.Unlock()
+			if rejected {
+				reply("550 No such user")
+				continue
+			}
+			msg.to = append(msg.to, rcpt)
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 OK queued")
+		case cmd == "RSET", cmd == "NOOP":
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// envelopeAddress extracts the address from "<addr> PARAMS"
+func envelopeAddress(s string) string {
+	s = strings.TrimSpace(s)
+	if end := strings.IndexByte(s, '>'); end >= 0 {
+		s = s[:end]
+	}
+	return strings.TrimPrefix(s, "<")
+}
+
+func TestEmail(t *testing.T) {
+	srv := newSMTPStandIn(t)
+
+	if _, err := NewEmail("mail", srv.addr(), "not an address", []string{"a@example.com"}, nil); err == nil {
+		t.Error("NewEmail() accepted an invalid sender")
+	}
+	if _, err := NewEmail("mail", srv.addr(), "alerts@example.com", nil, nil); err == nil {
+		t.Error("NewEmail() accepted no recipients")
+	}
+
+	m, err := NewEmail("mail", srv.addr(), "Case Alerts <alerts@example.com>",
+		[]string{"alex@example.com", "Sam <sam@example.com>"}, nil, WithClock(clock.NewFake(testEpoch)))
+	if err != nil {
+		t.Fatalf("NewEmail() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	event := testEvent(changes.RFEIssued, "EAC2190000001")
+	if err := m.Notify(ctx, event); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	got := srv.received()
+	if len(got) != 1 {
+		t.Fatalf("stand-in received %d messages, want 1", len(got))
+	}
+	msg := got[0]
+	if msg.from != "alerts@example.com" || strings.Join(msg.to, ",") != "alex@example.com,sam@example.com" {
+		t.Errorf("envelope from=%q to=%v", msg.from, msg.to)
+	}
+	for _, want := range []string{
+		"Subject: Case EAC2190000001: evidence requested\r\n",
+		"Date: Sun, 01 Jun 2025 12:00:00 +0000\r\n",
+		"Message-ID: <evt-rfe_issued.",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"\r\n\r\nEAC2190000001: Request for Evidence Was Sent\r\n",
+	} {
+		if !strings.Contains(msg.data, want) {
+			t.Errorf("message missing %q:\n%s", want, msg.data)
+		}
+	}
+
+	srv.mu.Lock()
+	srv.reject = "sam@example.com"
+	srv.mu.Unlock()
+	if err := m.Notify(ctx, event); err == nil || !strings.Contains(err.Error(), "sam@example.com") {
+		t.Errorf("Notify() with rejected recipient error = %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"MyUSCISgo/pkg/changes"
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/ratelimit"
+)
+
+// permanentError marks a failure that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent or is a rate
+// limit or context error
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p) ||
+		errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// RetryPolicy controls how often and how patiently a notifier is retried
+type RetryPolicy struct {
+	// Attempts is the total number of tries, including the first
+	Attempts int
+	// Base is the delay before the first retry; it doubles on each retry
+	Base time.Duration
+	// Max caps the delay between tries
+	Max time.Duration
+}
+
+// DefaultRetryPolicy returns the policy used when a zero policy is given
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{Attempts: 4, Base: 2 * time.Second, Max: 30 * time.Second}
+}
+
+// retrying retries a notifier with exponential backoff
+type retrying struct {
+	Notifier
+	policy RetryPolicy
+	clock  clock.Clock
+}
+
+// WithRetry wraps n so that failed deliveries are retried according to
+// policy. Permanent errors are returned immediately. A nil clock uses real
+// time.
+func WithRetry(n Notifier, policy RetryPolicy, c clock.Clock) Notifier {
+	d := DefaultRetryPolicy()
+	if policy.Attempts <= 0 {
+		policy.Attempts = d.Attempts
+	}
+	if policy.Base <= 0 {
+		policy.Base = d.Base
+	}
+	if policy.Max <= 0 {
+		policy.Max = d.Max
+	}
+	if c == nil {
+		c = clock.Real()
+	}
+	return &retrying{Notifier: n, policy: policy, clock: c}
+}
+
+// Notify delivers e, retrying transient failures
+func (r *retrying) Notify(ctx context.Context, e changes.Event) error {
+	delay := r.policy.Base
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = r.Notifier.Notify(ctx, e)
+		if err == nil || IsPermanent(err) || attempt >= r.policy.Attempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-r.clock.After(delay):
+		}
+		delay = min(delay*2, r.policy.Max)
+	}
+}
+
+// rateLimited drops deliveries beyond a limiter's allowance
+type rateLimited struct {
+	Notifier
+	limiter *ratelimit.RateLimiter
+}
+
+// WithRateLimit wraps n so that deliveries over the limiter's allowance
+// fail with ErrRateLimited. Wrap a retrying notifier, not the other way
+// round, so that one delivery uses one unit of allowance.
+func WithRateLimit(n Notifier, limiter *ratelimit.RateLimiter) Notifier {
+	return &rateLimited{Notifier: n, limiter: limiter}
+}
+
+// Notify delivers e if the notifier is within its limit
+func (r *rateLimited) Notify(ctx context.Context, e changes.Event) error {
+	if !r.limiter.Allow(r.Name()) {
+		return ErrRateLimited
+	}
+	return r.Notifier.Notify(ctx, e)
+}
@@ -0,0 +1,186 @@
+package processing
+
+import (
+	"context"
+	"net/http"
+
+	"MyUSCISgo/pkg/casegen"
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/jobs"
+	"MyUSCISgo/pkg/logging"
+	"MyUSCISgo/pkg/security"
+	"MyUSCISgo/pkg/types"
+)
+
+// TokenProvider issues and validates the tokens used during processing
+type TokenProvider interface {
+	GenerateSecureToken(clientID string) (string, error)
+	GenerateOAuthToken(ctx context.Context, clientID, clientSecret string) (*security.OAuthToken, error)
+	RefreshOAuthToken(ctx context.Context, clientID, clientSecret, refreshToken string) (*security.OAuthToken, error)
+	ValidateOAuthToken(token *security.OAuthToken) error
+}
+
+// securityTokenProvider is the default TokenProvider backed by the security package
+type securityTokenProvider struct{}
+
+func (securityTokenProvider) GenerateSecureToken(clientID string) (string, error) {
+	return security.GenerateSecureToken(clientID)
+}
+
+func (securityTokenProvider) GenerateOAuthToken(ctx context.Context, clientID, clientSecret string) (*security.OAuthToken, error) {
+	return security.GenerateOAuthToken(ctx, clientID, clientSecret)
+}
+
+func (securityTokenProvider) RefreshOAuthToken(ctx context.Context, clientID, clientSecret, refreshToken string) (*security.OAuthToken, error) {
+	return security.RefreshOAuthToken(ctx, clientID, clientSecret, refreshToken)
+}
+
+func (securityTokenProvider) ValidateOAuthToken(token *security.OAuthToken) error {
+	return security.ValidateOAuthToken(token)
+}
+
+// DefaultTokenProvider returns the TokenProvider backed by the security package
+func DefaultTokenProvider() TokenProvider {
+	return securityTokenProvider{}
+}
+
+// HTTPDoer is the subset of *http.Client used for upstream calls
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Option configures a Processor
+type Option func(*Processor)
+
+// WithLogger sets the logger used by the processor
+func WithLogger(logger *logging.Logger) Option {
+	return func(p *Processor) {
+		if logger != nil {
+			p.logger = logger
+		}
+	}
+}
+
+// WithTokenProvider sets the source of secure and OAuth tokens
+func WithTokenProvider(provider TokenProvider) Option {
+	return func(p *Processor) {
+		if provider != nil {
+			p.tokens = provider
+		}
+	}
+}
+
+// WithHTTPClient routes upstream API calls over the given client instead of
+// the built-in simulator. It has no effect when WithUpstream is also given.
+func WithHTTPClient(client HTTPDoer) Option {
+	return func(p *Processor) {
+		p.httpClient = client
+	}
+}
+
+// WithClock sets the clock used for delays and timestamps
+func WithClock(c clock.Clock) Option {
+	return func(p *Processor) {
+		if c != nil {
+			p.clock = c
+		}
+	}
+}
+
+// WithEnvironments sets the registry that maps environments to API settings
+func WithEnvironments(registry EnvironmentRegistry) Option {
+	return func(p *Processor) {
+		if registry != nil {
+			p.environments = registry
+		}
+	}
+}
+
+// WithUpstream sets the client used to reach the USCIS API
+func WithUpstream(upstream UpstreamClient) Option {
+	return func(p *Processor) {
+		if upstream != nil {
+			p.upstream = upstream
+		}
+	}
+}
+
+// WithJobRegistry sets the registry used to track asynchronous processing jobs
+func WithJobRegistry(registry *jobs.Registry) Option {
+	return func(p *Processor) {
+		if registry != nil {
+			p.jobs = registry
+		}
+	}
+}
+
+// EnvironmentConfig describes the API settings for a single environment
+type EnvironmentConfig struct {
+	BaseURL  string
+	AuthMode string
+	Config   map[string]string
+}
+
+// EnvironmentRegistry resolves the API settings for an environment
+type EnvironmentRegistry interface {
+	Lookup(env types.Environment) (EnvironmentConfig, bool)
+}
+
+// StaticEnvironments is an EnvironmentRegistry backed by a fixed map
+type StaticEnvironments map[types.Environment]EnvironmentConfig
+
+// Lookup returns the settings registered for env
+func (s StaticEnvironments) Lookup(env types.Environment) (EnvironmentConfig, bool) {
+	cfg, ok := s[env]
+	return cfg, ok
+}
+
+// DefaultEnvironments returns the built-in development, staging and production settings
+func DefaultEnvironments() StaticEnvironments {
+	return StaticEnvironments{
+		types.EnvDevelopment: {
+			BaseURL:  "https://api-int.uscis.gov/case-status",
+			AuthMode: "oauth",
+			Config: map[string]string{
+				"debug":          "true",
+				"timeout":        "30s",
+				"retryCount":     "3",
+				"oauth_endpoint": "https://api-int.uscis.gov/oauth/token",
+				"api_version":    "v1",
+			},
+		},
+		types.EnvStaging: {
+			BaseURL:  "https://api-staging.uscis.gov/case-status",
+			AuthMode: "oauth",
+			Config: map[string]string{
+				"debug":          "false",
+				"timeout":        "60s",
+				"retryCount":     "5",
+				"oauth_endpoint": "https://api-staging.uscis.gov/oauth/token",
+				"api_version":    "v1",
+			},
+		},
+		types.EnvProduction: {
+			BaseURL:  "https://api.uscis.gov/case-status",
+			AuthMode: "oauth",
+			Config: map[string]string{
+				"debug":          "false",
+				"timeout":        "120s",
+				"retryCount":     "10",
+				"rateLimit":      "1000",
+				"oauth_endpoint": "https://api.uscis.gov/oauth/token",
+				"api_version":    "v1",
+			},
+		},
+	}
+}
+
+// WithCaseGenerator sets the generator that supplies fake case records for
+// the simulated upstream and the development and staging environments
+func WithCaseGenerator(g *casegen.Generator) Option {
+	return func(p *Processor) {
+		if g != nil {
+			p.cases = g
+		}
+	}
+}
@@ -2,24 +2,65 @@ package processing
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"MyUSCISgo/pkg/casegen"
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/jobs"
 	"MyUSCISgo/pkg/logging"
+	"MyUSCISgo/pkg/receipt"
 	"MyUSCISgo/pkg/security"
 	"MyUSCISgo/pkg/types"
 )
 
 // Processor handles the processing of credentials based on environment
 type Processor struct {
-	logger *logging.Logger
+	logger       *logging.Logger
+	tokens       TokenProvider
+	httpClient   HTTPDoer
+	clock        clock.Clock
+	environments EnvironmentRegistry
+	upstream     UpstreamClient
+	jobs         *jobs.Registry
+	cases        *casegen.Generator
 }
 
-// NewProcessor creates a new processor instance
-func NewProcessor() *Processor {
-	return &Processor{
-		logger: logging.NewLogger(logging.LogLevelInfo),
+// JobKindCredentials is the job kind used for credential processing
+const JobKindCredentials = "process_credentials"
+
+// NewProcessor creates a new processor instance. Without options it uses the
+// security package for tokens, the system clock, the built-in environment
+// settings and a simulated USCIS API. Its case lookups are only answered for
+// development and staging; production lookups need WithHTTPClient or
+// WithUpstream.
+func NewProcessor(opts ...Option) *Processor {
+	p := &Processor{
+		logger:       logging.NewLogger(logging.LogLevelInfo),
+		tokens:       DefaultTokenProvider(),
+		clock:        clock.Real(),
+		environments: DefaultEnvironments(),
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if p.jobs == nil {
+		p.jobs = jobs.NewRegistry(jobs.WithClock(p.clock))
+	}
+	if p.cases == nil {
+		p.cases = casegen.NewGenerator(casegen.WithClock(p.clock))
+	}
+	if p.upstream == nil {
+		if p.httpClient != nil {
+			p.upstream = NewHTTPUpstream(p.httpClient, p.logger, p.clock)
+		} else {
+			p.upstream = NewSimulatedUpstream(p.logger, p.clock, p.cases)
+		}
+	}
+
+	return p
 }
 
 // maskTokenHint creates a non-sensitive hint from a token for logging/debugging purposes
@@ -63,14 +104,21 @@ func createSafeResult(result *types.ProcessingResult) *types.ProcessingResult {
 	return safeResult
 }
 
-// ProcessCredentialsAsync processes credentials asynchronously using Go concurrency features
-func (p *Processor) ProcessCredentialsAsync(ctx context.Context, creds *types.Credentials) (<-chan *types.ProcessingResult, <-chan error) {
-	resultCh := make(chan *types.ProcessingResult, 1)
-	errCh := make(chan error, 1)
+// Jobs returns the registry that tracks submitted processing jobs
+func (p *Processor) Jobs() *jobs.Registry {
+	return p.jobs
+}
 
-	go func() {
-		defer close(resultCh)
-		defer close(errCh)
+// Cases returns the generator used for fake case records
+func (p *Processor) Cases() *casegen.Generator {
+	return p.cases
+}
+
+// SubmitCredentials starts credential processing as a tracked job. The job
+// result is a *types.ProcessingResult.
+func (p *Processor) SubmitCredentials(ctx context.Context, creds *types.Credentials) *jobs.Job {
+	return p.jobs.Submit(ctx, JobKindCredentials, func(ctx context.Context, report jobs.ReportFunc) (any, error) {
+		report("security_check", 0, "Validating credential security")
 
 		// Create secure version of credentials
 		secureCreds, err := security.SecureCredentials(creds)
@@ -79,8 +127,7 @@ func (p *Processor) ProcessCredentialsAsync(ctx context.Context, creds *types.Cr
 				"clientId":    creds.ClientID,
 				"environment": creds.Environment,
 			}))
-			errCh <- fmt.Errorf("security validation failed: %w", err)
-			return
+			return nil, fmt.Errorf("security validation failed: %w", err)
 		}
 
 		p.logger.Info("Starting credential processing", map[string]interface{}{
@@ -88,15 +135,13 @@ func (p *Processor) ProcessCredentialsAsync(ctx context.Context, creds *types.Cr
 			"environment": secureCreds.Environment,
 		})
 
-		// Simulate async processing with context support
-		result, err := p.processWithContext(ctx, creds) // Pass original creds, not secureCreds
+		result, err := p.process(ctx, creds, report) // Pass original creds, not secureCreds
 		if err != nil {
 			p.logger.Error("Processing failed", err, map[string]interface{}{
 				"clientId":    secureCreds.ClientID,
 				"environment": secureCreds.Environment,
 			})
-			errCh <- err
-			return
+			return nil, err
 		}
 
 		p.logger.Info("Credential processing completed", map[string]interface{}{
@@ -104,11 +149,36 @@ func (p *Processor) ProcessCredentialsAsync(ctx context.Context, creds *types.Cr
 			"environment": secureCreds.Environment,
 		})
 
-		resultCh <- result
+		return result, nil
+	})
+}
+
+// ProcessCredentialsAsync processes credentials asynchronously using Go concurrency features
+func (p *Processor) ProcessCredentialsAsync(ctx context.Context, creds *types.Credentials) (<-chan *types.ProcessingResult, <-chan error) {
+	resultCh := make(chan *types.ProcessingResult, 1)
+	errCh := make(chan error, 1)
+
+	job := p.SubmitCredentials(ctx, creds)
+
+	go func() {
+		defer close(resultCh)
+		defer close(errCh)
+
+		result, err := job.Wait(context.Background())
+		if err != nil {
+			if errors.Is(err, jobs.ErrCancelled) && ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			errCh <- err
+			return
+		}
+		resultCh <- result.(*types.ProcessingResult)
 	}()
 
 	return resultCh, errCh
 }
+
+// ProcessCredentialsSync processes credentials and blocks until the result is available
 func (p *Processor) ProcessCredentialsSync(ctx context.Context, creds *types.Credentials) (*types.ProcessingResult, error) {
 	// Create secure version of credentials
 	secureCreds, err := security.SecureCredentials(creds)
@@ -137,6 +207,11 @@ func (p *Processor) ProcessCredentialsSync(ctx context.Context, creds *types.Cre
 
 // processWithContext processes credentials with context support
 func (p *Processor) processWithContext(ctx context.Context, creds *types.Credentials) (*types.ProcessingResult, error) {
+	return p.process(ctx, creds, func(string, int, string) {})
+}
+
+// process runs the processing pipeline, reporting progress as it goes
+func (p *Processor) process(ctx context.Context, creds *types.Credentials, report jobs.ReportFunc) (*types.ProcessingResult, error) {
 	// Check if context is cancelled
 	select {
 	case <-ctx.Done():
@@ -151,7 +226,8 @@ func (p *Processor) processWithContext(ctx context.Context, creds *types.Credent
 	}
 
 	// Generate secure token
-	token, err := security.GenerateSecureToken(creds.ClientID)
+	report("token_generation", 20, "Generating tokens")
+	token, err := p.tokens.GenerateSecureToken(creds.ClientID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
@@ -160,7 +236,7 @@ func (p *Processor) processWithContext(ctx context.Context, creds *types.Credent
 	oauthCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	oauthToken, err := security.GenerateOAuthToken(oauthCtx, creds.ClientID, creds.ClientSecret)
+	oauthToken, err := p.tokens.GenerateOAuthToken(oauthCtx, creds.ClientID, creds.ClientSecret)
 	if err != nil {
 		p.logger.Error("Failed to generate OAuth token", err, logging.SanitizeLogData(map[string]interface{}{
 			"clientId":    secureCreds.ClientID, // Use secureCreds for logging
@@ -176,44 +252,24 @@ func (p *Processor) processWithContext(ctx context.Context, creds *types.Credent
 		Config:     make(map[string]string),
 	}
 
-	switch types.ToEnvironment(creds.Environment) {
-	case types.EnvDevelopment:
-		result.BaseURL = "https://api-int.uscis.gov/case-status"
-		result.AuthMode = "oauth"
-		result.Config["debug"] = "true"
-		result.Config["timeout"] = "30s"
-		result.Config["retryCount"] = "3"
-		result.Config["oauth_endpoint"] = "https://api-int.uscis.gov/oauth/token"
-		result.Config["api_version"] = "v1"
-
-	case types.EnvStaging:
-		result.BaseURL = "https://api-staging.uscis.gov/case-status"
-		result.AuthMode = "oauth"
-		result.Config["debug"] = "false"
-		result.Config["timeout"] = "60s"
-		result.Config["retryCount"] = "5"
-		result.Config["oauth_endpoint"] = "https://api-staging.uscis.gov/oauth/token"
-		result.Config["api_version"] = "v1"
-
-	case types.EnvProduction:
-		result.BaseURL = "https://api.uscis.gov/case-status"
-		result.AuthMode = "oauth"
-		result.Config["debug"] = "false"
-		result.Config["timeout"] = "120s"
-		result.Config["retryCount"] = "10"
-		result.Config["rateLimit"] = "1000"
-		result.Config["oauth_endpoint"] = "https://api.uscis.gov/oauth/token"
-		result.Config["api_version"] = "v1"
+	report("environment_config", 40, "Applying environment configuration")
+	if envCfg, ok := p.environments.Lookup(types.ToEnvironment(creds.Environment)); ok {
+		result.BaseURL = envCfg.BaseURL
+		result.AuthMode = envCfg.AuthMode
+		for k, v := range envCfg.Config {
+			result.Config[k] = v
+		}
 	}
 
 	// Simulate some processing time
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
-	case <-time.After(100 * time.Millisecond):
+	case <-p.clock.After(100 * time.Millisecond):
 	}
 
 	// Validate OAuth token
+	report("token_validation", 60, "Validating OAuth token")
 	if result.OAuthToken != nil {
 		securityToken := &security.OAuthToken{
 			AccessToken: result.OAuthToken.AccessToken,
@@ -226,7 +282,7 @@ func (p *Processor) processWithContext(ctx context.Context, creds *types.Credent
 			securityToken.ExpiresAt = expiresAt
 		}
 
-		if err := security.ValidateOAuthToken(securityToken); err != nil {
+		if err := p.tokens.ValidateOAuthToken(securityToken); err != nil {
 			p.logger.Warn("OAuth token validation failed, attempting refresh", map[string]interface{}{
 				"clientId":    creds.ClientID,
 				"environment": creds.Environment,
@@ -234,7 +290,7 @@ func (p *Processor) processWithContext(ctx context.Context, creds *types.Credent
 			})
 
 			// Attempt to refresh the token
-			newToken, refreshErr := security.RefreshOAuthToken(ctx, creds.ClientID, creds.ClientSecret, "")
+			newToken, refreshErr := p.tokens.RefreshOAuthToken(ctx, creds.ClientID, creds.ClientSecret, "")
 			if refreshErr != nil {
 				p.logger.Error("OAuth token refresh failed", refreshErr, logging.SanitizeLogData(map[string]interface{}{
 					"clientId":    creds.ClientID,
@@ -254,6 +310,7 @@ func (p *Processor) processWithContext(ctx context.Context, creds *types.Credent
 	}
 
 	// Optionally simulate USCIS API call
+	report("upstream_call", 80, "Calling USCIS API")
 	if err := p.SimulateAPI(ctx, result, creds.Environment); err != nil {
 		return nil, err
 	}
@@ -300,59 +357,60 @@ func (p *Processor) addEnvironmentSpecificProcessing(ctx context.Context, result
 	return nil
 }
 
-// SimulateAPI simulates API calls for different environments
+// SimulateAPI performs the upstream USCIS API call for the given environment
 func (p *Processor) SimulateAPI(ctx context.Context, result *types.ProcessingResult, env string) error {
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	default:
+	return p.upstream.CallAPI(ctx, result, types.ToEnvironment(env))
+}
+
+// FetchCaseStatus looks up the current status and history of a case. The
+// token is forwarded to the upstream API and may be nil for simulated
+// environments. The record's ServiceCenter is the office named upstream and
+// is left empty when the response names none, so an office learned from an
+// earlier transfer notice is not mistaken for a move back.
+func (p *Processor) FetchCaseStatus(ctx context.Context, env string, receiptNumber string, token *types.OAuthToken) (*types.CaseRecord, error) {
+	r, err := receipt.Parse(receiptNumber)
+	if err != nil {
+		return nil, err
 	}
 
-	p.logger.Info("Simulating USCIS API call", map[string]interface{}{
-		"environment": env,
-		"baseURL":     result.BaseURL,
-		"authMode":    result.AuthMode,
-		"hasToken":    result.OAuthToken != nil,
-	})
+	environment := types.ToEnvironment(env)
+	envCfg, ok := p.environments.Lookup(environment)
+	if !ok {
+		return nil, fmt.Errorf("unknown environment: %s", env)
+	}
 
-	// Validate OAuth token before API call
-	if result.OAuthToken != nil {
-		p.logger.Debug("Validating OAuth token for API call", map[string]interface{}{
-			"tokenType": result.OAuthToken.TokenType,
-			"scope":     result.OAuthToken.Scope,
+	data, err := p.upstream.FetchCaseStatus(ctx, CaseStatusRequest{
+		Environment:   environment,
+		BaseURL:       envCfg.BaseURL,
+		ReceiptNumber: r.Number,
+		Token:         token,
+	})
+	if err != nil {
+		p.logger.Error("Case status lookup failed", err, map[string]interface{}{
+			"caseNumber":  r.Number,
+			"environment": env,
 		})
+		return nil, err
 	}
 
-	// Simulate network delay
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-time.After(200 * time.Millisecond):
+	resp, err := types.ParseCaseStatusResponse(data)
+	if err != nil {
+		return nil, err
 	}
 
-	// Simulate different responses based on environment
-	switch types.ToEnvironment(env) {
-	case types.EnvDevelopment:
-		result.Config["apiStatus"] = "mock_success"
-		result.Config["responseTime"] = "50ms"
-		result.Config["oauth_valid"] = "true"
-	case types.EnvStaging:
-		result.Config["apiStatus"] = "test_success"
-		result.Config["responseTime"] = "150ms"
-		result.Config["oauth_valid"] = "true"
-	case types.EnvProduction:
-		result.Config["apiStatus"] = "live_success"
-		result.Config["responseTime"] = "300ms"
-		result.Config["oauth_valid"] = "true"
+	record := resp.Record()
+	if record.UpdatedAt.IsZero() {
+		record.UpdatedAt = p.clock.Now().UTC()
 	}
 
-	p.logger.Info("USCIS API simulation completed", map[string]interface{}{
-		"environment":  env,
-		"apiStatus":    result.Config["apiStatus"],
-		"responseTime": result.Config["responseTime"],
+	p.logger.Info("Case status retrieved", map[string]interface{}{
+		"caseNumber":  r.Number,
+		"environment": env,
+		"status":      record.Status.Code,
+		"events":      record.Timeline.Len(),
 	})
 
-	return nil
+	return record, nil
 }
 
 // convertToTypesOAuthToken converts security.OAuthToken to types.OAuthToken
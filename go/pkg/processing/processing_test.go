@@ -0,0 +1,428 @@
+package processing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"MyUSCISgo/pkg/casegen"
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/logging"
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/scan"
+	"MyUSCISgo/pkg/security"
+	"MyUSCISgo/pkg/types"
+)
+
+var testEpoch = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeTokens is a deterministic TokenProvider
+type fakeTokens struct {
+	clock      clock.Clock
+	oauthErr   error
+	invalid    bool
+	refreshed  int
+	validCalls int
+}
+
+func (f *fakeTokens) GenerateSecureToken(clientID string) (string, error) {
+	return "secure-token-for-" + clientID, nil
+}
+
+func (f *fakeTokens) GenerateOAuthToken(ctx context.Context, clientID, clientSecret string) (*security.OAuthToken, error) {
+	if f.oauthErr != nil {
+		return nil, f.oauthErr
+	}
+	return &security.OAuthToken{
+		AccessToken: "access-" + clientID,
+		TokenType:   "Bearer",
+		ExpiresIn:   3600,
+		ExpiresAt:   f.clock.Now().Add(time.Hour),
+		Scope:       "case-status:read",
+	}, nil
+}
+
+func (f *fakeTokens) RefreshOAuthToken(ctx context.Context, clientID, clientSecret, refreshToken string) (*security.OAuthToken, error) {
+	f.refreshed++
+	token, err := f.GenerateOAuthToken(ctx, clientID, clientSecret)
+	if token != nil {
+		token.Scope = "case-status:refreshed"
+	}
+	return token, err
+}
+
+func (f *fakeTokens) ValidateOAuthToken(token *security.OAuthToken) error {
+	f.validCalls++
+	if f.invalid {
+		return errors.New("token has expired")
+	}
+	return nil
+}
+
+// fakeUpstream records calls instead of reaching the API
+type fakeUpstream struct {
+	calls   []types.Environment
+	err     error
+	payload []byte
+}
+
+func (f *fakeUpstream) CallAPI(ctx context.Context, result *types.ProcessingResult, env types.Environment) error {
+	f.calls = append(f.calls, env)
+	result.Config["apiStatus"] = "fake"
+	return f.err
+}
+
+func (f *fakeUpstream) FetchCaseStatus(ctx context.Context, req CaseStatusRequest) ([]byte, error) {
+	f.calls = append(f.calls, req.Environment)
+	return f.payload, f.err
+}
+
+var testCreds = &types.Credentials{
+	ClientID:     "test-client-123",
+	ClientSecret: "Str0ngRandomValue!",
+	Environment:  "development",
+}
+
+// runWithClock runs fn while advancing the fake clock whenever it is waited on
+func runWithClock(t *testing.T, fc *clock.Fake, fn func() (*types.ProcessingResult, error)) (*types.ProcessingResult, error) {
+	t.Helper()
+
+	type outcome struct {
+		result *types.ProcessingResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := fn()
+		done <- outcome{result, err}
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case o := <-done:
+			return o.result, o.err
+		case <-deadline:
+			t.Fatal("processing did not finish")
+		default:
+		}
+		if fc.Waiters() > 0 {
+			fc.Advance(time.Second)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func newTestProcessor(fc *clock.Fake, tokens *fakeTokens, upstream UpstreamClient, opts ...Option) *Processor {
+	base := []Option{
+		WithClock(fc),
+		WithTokenProvider(tokens),
+		WithUpstream(upstream),
+		WithLogger(logging.NewLogger(logging.LogLevelFatal)),
+	}
+	return NewProcessor(append(base, opts...)...)
+}
+
+func TestNewProcessorDefaults(t *testing.T) {
+	p := NewProcessor()
+
+	if p.logger == nil || p.tokens == nil || p.clock == nil || p.environments == nil {
+		t.Fatal("NewProcessor() left a dependency unset")
+	}
+	if _, ok := p.upstream.(*simulatedUpstream); !ok {
+		t.Errorf("default upstream = %T, want *simulatedUpstream", p.upstream)
+	}
+
+	withHTTP := NewProcessor(WithHTTPClient(http.DefaultClient))
+	if _, ok := withHTTP.upstream.(*httpUpstream); !ok {
+		t.Errorf("upstream with HTTP client = %T, want *httpUpstream", withHTTP.upstream)
+	}
+}
+
+func TestProcessCredentialsSyncDeterministic(t *testing.T) {
+	fc := clock.NewFake(testEpoch)
+	tokens := &fakeTokens{clock: fc}
+	upstream := &fakeUpstream{}
+	p := newTestProcessor(fc, tokens, upstream)
+
+	result, err := runWithClock(t, fc, func() (*types.ProcessingResult, error) {
+		return p.ProcessCredentialsSync(context.Background(), testCreds)
+	})
+	if err != nil {
+		t.Fatalf("ProcessCredentialsSync() error = %v", err)
+	}
+
+	if result.BaseURL != "https://api-int.uscis.gov/case-status" {
+		t.Errorf("BaseURL = %q", result.BaseURL)
+	}
+	if result.TokenHint != "secu****-123" {
+		t.Errorf("TokenHint = %q, want masked hint", result.TokenHint)
+	}
+	if result.OAuthToken == nil || result.OAuthToken.AccessToken != "" {
+		t.Errorf("OAuthToken access token should be scrubbed, got %+v", result.OAuthToken)
+	}
+	if want := testEpoch.Add(time.Hour).Format(time.RFC3339); result.OAuthToken.ExpiresAt != want {
+		t.Errorf("ExpiresAt = %q, want %q", result.OAuthToken.ExpiresAt, want)
+	}
+	if result.Config["apiStatus"] != "fake" {
+		t.Errorf("apiStatus = %q, want upstream value", result.Config["apiStatus"])
+	}
+	if len(upstream.calls) != 1 || upstream.calls[0] != types.EnvDevelopment {
+		t.Errorf("upstream calls = %v", upstream.calls)
+	}
+	if tokens.refreshed != 0 {
+		t.Errorf("refreshed = %d, want 0", tokens.refreshed)
+	}
+}
+
+func TestProcessRefreshesInvalidToken(t *testing.T) {
+	fc := clock.NewFake(testEpoch)
+	tokens := &fakeTokens{clock: fc, invalid: true}
+	p := newTestProcessor(fc, tokens, &fakeUpstream{})
+
+	result, err := runWithClock(t, fc, func() (*types.ProcessingResult, error) {
+		return p.ProcessCredentialsSync(context.Background(), testCreds)
+	})
+	if err != nil {
+		t.Fatalf("ProcessCredentialsSync() error = %v", err)
+	}
+	if tokens.refreshed != 1 {
+		t.Errorf("refreshed = %d, want 1", tokens.refreshed)
+	}
+	if result.OAuthToken.Scope != "case-status:refreshed" {
+		t.Errorf("Scope = %q, want refreshed token", result.OAuthToken.Scope)
+	}
+}
+
+func TestProcessErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		tokens      *fakeTokens
+		upstreamErr error
+		errContains string
+	}{
+		{
+			name:        "oauth failure",
+			tokens:      &fakeTokens{oauthErr: errors.New("boom")},
+			errContains: "failed to generate OAuth token",
+		},
+		{
+			name:        "upstream failure",
+			tokens:      &fakeTokens{},
+			upstreamErr: errors.New("upstream down"),
+			errContains: "upstream down",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc := clock.NewFake(testEpoch)
+			tt.tokens.clock = fc
+			p := newTestProcessor(fc, tt.tokens, &fakeUpstream{err: tt.upstreamErr})
+
+			_, err := runWithClock(t, fc, func() (*types.ProcessingResult, error) {
+				return p.ProcessCredentialsSync(context.Background(), testCreds)
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("error = %v, want error containing %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
+func TestCustomEnvironmentRegistry(t *testing.T) {
+	fc := clock.NewFake(testEpoch)
+	registry := StaticEnvironments{
+		types.EnvDevelopment: {
+			BaseURL:  "http://localhost:9999/case-status",
+			AuthMode: "none",
+			Config:   map[string]string{"debug": "true"},
+		},
+	}
+	p := newTestProcessor(fc, &fakeTokens{clock: fc}, &fakeUpstream{}, WithEnvironments(registry))
+
+	result, err := runWithClock(t, fc, func() (*types.ProcessingResult, error) {
+		return p.ProcessCredentialsSync(context.Background(), testCreds)
+	})
+	if err != nil {
+		t.Fatalf("ProcessCredentialsSync() error = %v", err)
+	}
+	if result.BaseURL != "http://localhost:9999/case-status" || result.AuthMode != "none" {
+		t.Errorf("result = %+v, want registry settings", result)
+	}
+	if _, ok := result.Config["oauth_endpoint"]; ok {
+		t.Error("default environment settings leaked into custom registry result")
+	}
+}
+
+func TestProcessHonoursCancellation(t *testing.T) {
+	fc := clock.NewFake(testEpoch)
+	p := newTestProcessor(fc, &fakeTokens{clock: fc}, &fakeUpstream{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := p.ProcessCredentialsSync(ctx, testCreds); !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+}
+
+// roundTripFunc adapts a function to HTTPDoer
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestHTTPUpstream(t *testing.T) {
+	fc := clock.NewFake(testEpoch)
+	var gotAuth string
+	client := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		gotAuth = req.Header.Get("Authorization")
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	})
+	upstream := NewHTTPUpstream(client, logging.NewLogger(logging.LogLevelFatal), fc)
+
+	result := &types.ProcessingResult{
+		BaseURL:    "https://api-int.uscis.gov/case-status",
+		OAuthToken: &types.OAuthToken{AccessToken: "abc", TokenType: "Bearer"},
+		Config:     map[string]string{},
+	}
+	if err := upstream.CallAPI(context.Background(), result, types.EnvDevelopment); err != nil {
+		t.Fatalf("CallAPI() error = %v", err)
+	}
+	if gotAuth != "Bearer abc" {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+	if result.Config["apiStatus"] != "http_200" || result.Config["oauth_valid"] != "true" {
+		t.Errorf("config = %v", result.Config)
+	}
+}
+
+func TestSubmitCredentialsTracksJob(t *testing.T) {
+	fc := clock.NewFake(testEpoch)
+	p := newTestProcessor(fc, &fakeTokens{clock: fc}, &fakeUpstream{})
+
+	job := p.SubmitCredentials(context.Background(), testCreds)
+	updates, stop := job.Subscribe()
+	defer stop()
+
+	result, err := runWithClock(t, fc, func() (*types.ProcessingResult, error) {
+		res, err := job.Wait(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		return res.(*types.ProcessingResult), nil
+	})
+	if err != nil {
+		t.Fatalf("job error = %v", err)
+	}
+	if result.BaseURL == "" {
+		t.Error("job result missing BaseURL")
+	}
+
+	stages := map[string]bool{}
+	for progress := range updates {
+		stages[progress.Stage] = true
+	}
+	if !stages["upstream_call"] {
+		t.Errorf("stages = %v, want upstream_call reported", stages)
+	}
+
+	if _, err := p.Jobs().Get(job.ID()); err != nil {
+		t.Errorf("job not found in registry: %v", err)
+	}
+}
+
+func TestFetchCaseStatus(t *testing.T) {
+	fc := clock.NewFake(testEpoch)
+	payload := []byte(`{"case_status":{"receiptNumber":"EAC2190050123","formType":"I-130",
+		"modifiedDate":"02-01-2025 09:00:00","current_case_status_text_en":"Case Was Approved",
+		"hist_case_status":[{"completed_text_en":"Case Was Received","date":"11-01-2024"}]}}`)
+	p := newTestProcessor(fc, &fakeTokens{clock: fc}, &fakeUpstream{payload: payload})
+
+	record, err := p.FetchCaseStatus(context.Background(), "development", "eac-2190050123", nil)
+	if err != nil {
+		t.Fatalf("FetchCaseStatus() error = %v", err)
+	}
+	if record.Status.Code != types.StatusApproved || record.ServiceCenter != "" {
+		t.Errorf("record = %+v, want no service center when the response names none", record)
+	}
+	if record.Timeline.Len() != 2 {
+		t.Errorf("timeline has %d events, want 2", record.Timeline.Len())
+	}
+
+	if _, err := p.FetchCaseStatus(context.Background(), "development", "ABC1234567890", nil); err == nil {
+		t.Error("expected error for unknown service center")
+	}
+}
+
+func TestSimulatedCaseStatus(t *testing.T) {
+	fc := clock.NewFake(testEpoch)
+	cases := casegen.NewGenerator(casegen.WithClock(fc), casegen.WithScenario(casegen.ScenarioStalled))
+	p := NewProcessor(WithClock(fc), WithLogger(logging.NewLogger(logging.LogLevelFatal)), WithCaseGenerator(cases))
+
+	var record *types.CaseRecord
+	_, err := runWithClock(t, fc, func() (*types.ProcessingResult, error) {
+		var err error
+		record, err = p.FetchCaseStatus(context.Background(), "staging", "LIN2312351234", nil)
+		return nil, err
+	})
+	if err != nil {
+		t.Fatalf("FetchCaseStatus() error = %v", err)
+	}
+
+	origin, _ := receipt.Parse("LIN2312351234")
+	want := cases.Generate(origin)
+	if record.ReceiptNumber != want.ReceiptNumber || record.FormType != want.FormType {
+		t.Errorf("record = %+v, want %+v", record, want)
+	}
+	if record.Status.Code != want.Status.Code || record.Timeline.Len() != want.Timeline.Len() {
+		t.Errorf("status %s with %d events, want %s with %d events",
+			record.Status.Code, record.Timeline.Len(), want.Status.Code, want.Timeline.Len())
+	}
+	if stage := record.Timeline.CurrentStage(); stage == types.StageDecision {
+		t.Errorf("stalled case reached stage %s", stage)
+	}
+
+	// generated cases are never returned as production data
+	if _, err := p.FetchCaseStatus(context.Background(), "production", "LIN2312351234", nil); !errors.Is(err, ErrNoUpstream) {
+		t.Errorf("production FetchCaseStatus() error = %v, want %v", err, ErrNoUpstream)
+	}
+	if p.Cases() != cases {
+		t.Error("Cases() should return the configured generator")
+	}
+}
+
+func TestSubmitScan(t *testing.T) {
+	fc := clock.NewFake(testEpoch)
+	payload := []byte(`{"case_status":{"receiptNumber":"EAC2190050123","formType":"I-130",
+		"current_case_status_text_en":"Case Was Received"}}`)
+	upstream := &fakeUpstream{payload: payload}
+	p := newTestProcessor(fc, &fakeTokens{clock: fc}, upstream)
+
+	origin, err := receipt.Parse("EAC2190050123")
+	if err != nil {
+		t.Fatalf("receipt.Parse() error = %v", err)
+	}
+	job := p.SubmitScan(context.Background(), "development", origin, 2, scan.WithDelay(0))
+	if job.Kind() != JobKindScan {
+		t.Errorf("job kind = %q, want %q", job.Kind(), JobKindScan)
+	}
+
+	result, err := job.Wait(context.Background())
+	if err != nil {
+		t.Fatalf("scan job error = %v", err)
+	}
+	report := result.(*scan.Report)
+	if report.Scanned != 4 || report.ByForm["I-130"] != 4 || report.ByStatus[types.StatusReceived] != 4 {
+		t.Errorf("report = %+v", report)
+	}
+	if len(upstream.calls) != 4 || upstream.calls[0] != types.EnvDevelopment {
+		t.Errorf("upstream calls = %v, want 4 development lookups", upstream.calls)
+	}
+}
@@ -0,0 +1,29 @@
+package processing
+
+import (
+	"context"
+
+	"MyUSCISgo/pkg/jobs"
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/scan"
+	"MyUSCISgo/pkg/types"
+)
+
+// JobKindScan is the job kind used for receipt neighbourhood scans
+const JobKindScan = "receipt_scan"
+
+// SubmitScan starts a neighbourhood scan around origin as a tracked job.
+// Lookups use the processor's upstream for env; opts can supply a cache to
+// resume earlier scans and a rate limiter shared with other callers. The
+// job result is a *scan.Report.
+func (p *Processor) SubmitScan(ctx context.Context, env string, origin receipt.Receipt, radius int, opts ...scan.Option) *jobs.Job {
+	fetcher := scan.FetcherFunc(func(ctx context.Context, receiptNumber string) (*types.CaseRecord, error) {
+		return p.FetchCaseStatus(ctx, env, receiptNumber, nil)
+	})
+	opts = append([]scan.Option{scan.WithClock(p.clock), scan.WithLogger(p.logger), scan.WithEnvironment(env)}, opts...)
+	scanner := scan.NewScanner(fetcher, opts...)
+
+	return p.jobs.Submit(ctx, JobKindScan, func(ctx context.Context, report jobs.ReportFunc) (any, error) {
+		return scanner.Scan(ctx, origin, radius, report)
+	})
+}
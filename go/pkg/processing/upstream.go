@@ -0,0 +1,243 @@
+package processing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"MyUSCISgo/pkg/casegen"
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/logging"
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/types"
+)
+
+// maxCaseStatusBytes bounds the size of an upstream case-status payload
+const maxCaseStatusBytes = 1 << 20
+
+// ErrNoUpstream is returned by the simulator for production case lookups,
+// which must be made through an HTTP upstream
+var ErrNoUpstream = errors.New("no USCIS API client is configured for production")
+
+// CaseStatusRequest describes a single case-status lookup
+type CaseStatusRequest struct {
+	Environment   types.Environment
+	BaseURL       string
+	ReceiptNumber string
+	Token         *types.OAuthToken
+}
+
+// UpstreamClient is the client used to reach the USCIS API
+type UpstreamClient interface {
+	// CallAPI performs the API call made at the end of credential processing
+	CallAPI(ctx context.Context, result *types.ProcessingResult, env types.Environment) error
+	// FetchCaseStatus returns the raw case-status payload for a receipt number
+	FetchCaseStatus(ctx context.Context, req CaseStatusRequest) ([]byte, error)
+}
+
+// simulatedUpstream fakes the USCIS API with a fixed delay, canned API
+// responses and case records from the scenario generator. Case records are
+// only generated for development and staging so they are never passed off
+// as production data.
+type simulatedUpstream struct {
+	logger *logging.Logger
+	clock  clock.Clock
+	cases  *casegen.Generator
+}
+
+// NewSimulatedUpstream creates an UpstreamClient that simulates the USCIS API.
+// Case statuses come from cases, or from a generator on c when cases is nil.
+func NewSimulatedUpstream(logger *logging.Logger, c clock.Clock, cases *casegen.Generator) UpstreamClient {
+	if cases == nil {
+		cases = casegen.NewGenerator(casegen.WithClock(c))
+	}
+	return &simulatedUpstream{logger: logger, clock: c, cases: cases}
+}
+
+// CallAPI simulates an API call for the given environment
+func (s *simulatedUpstream) CallAPI(ctx context.Context, result *types.ProcessingResult, env types.Environment) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	s.logger.Info("Simulating USCIS API call", map[string]interface{}{
+		"environment": env.String(),
+		"baseURL":     result.BaseURL,
+		"authMode":    result.AuthMode,
+		"hasToken":    result.OAuthToken != nil,
+	})
+
+	// Validate OAuth token before API call
+	if result.OAuthToken != nil {
+		s.logger.Debug("Validating OAuth token for API call", map[string]interface{}{
+			"tokenType": result.OAuthToken.TokenType,
+			"scope":     result.OAuthToken.Scope,
+		})
+	}
+
+	// Simulate network delay
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.clock.After(200 * time.Millisecond):
+	}
+
+	// Simulate different responses based on environment
+	switch env {
+	case types.EnvDevelopment:
+		result.Config["apiStatus"] = "mock_success"
+		result.Config["responseTime"] = "50ms"
+		result.Config["oauth_valid"] = "true"
+	case types.EnvStaging:
+		result.Config["apiStatus"] = "test_success"
+		result.Config["responseTime"] = "150ms"
+		result.Config["oauth_valid"] = "true"
+	case types.EnvProduction:
+		result.Config["apiStatus"] = "live_success"
+		result.Config["responseTime"] = "300ms"
+		result.Config["oauth_valid"] = "true"
+	}
+
+	s.logger.Info("USCIS API simulation completed", map[string]interface{}{
+		"environment":  env.String(),
+		"apiStatus":    result.Config["apiStatus"],
+		"responseTime": result.Config["responseTime"],
+	})
+
+	return nil
+}
+
+// FetchCaseStatus simulates the case-status endpoint with a payload derived
+// from the receipt number and the current time
+func (s *simulatedUpstream) FetchCaseStatus(ctx context.Context, req CaseStatusRequest) ([]byte, error) {
+	if err := s.generates(req.Environment); err != nil {
+		return nil, err
+	}
+	r, err := receipt.Parse(req.ReceiptNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.clock.After(200 * time.Millisecond):
+	}
+
+	payload := s.cases.Response(r)
+	payload.Message = "Simulated response for " + req.Environment.String()
+
+	s.logger.Debug("Simulated case status lookup", map[string]interface{}{
+		"environment": req.Environment.String(),
+		"caseNumber":  r.Number,
+	})
+
+	return json.Marshal(payload)
+}
+
+// generates checks that env is one the simulator may generate cases for
+func (s *simulatedUpstream) generates(env types.Environment) error {
+	switch env {
+	case types.EnvDevelopment, types.EnvStaging:
+		return nil
+	case types.EnvProduction:
+		s.logger.Warn("Refusing to generate a production case", map[string]interface{}{
+			"environment": env.String(),
+		})
+		return ErrNoUpstream
+	default:
+		return fmt.Errorf("unknown environment: %s", env)
+	}
+}
+
+// httpUpstream reaches the USCIS API over HTTP
+type httpUpstream struct {
+	client HTTPDoer
+	logger *logging.Logger
+	clock  clock.Clock
+}
+
+// NewHTTPUpstream creates an UpstreamClient that probes the environment's base URL
+func NewHTTPUpstream(client HTTPDoer, logger *logging.Logger, c clock.Clock) UpstreamClient {
+	return &httpUpstream{client: client, logger: logger, clock: c}
+}
+
+// CallAPI issues an authenticated request against the base URL and records the outcome
+func (h *httpUpstream) CallAPI(ctx context.Context, result *types.ProcessingResult, env types.Environment) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, result.BaseURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build upstream request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if result.OAuthToken != nil && result.OAuthToken.AccessToken != "" {
+		req.Header.Set("Authorization", result.OAuthToken.TokenType+" "+result.OAuthToken.AccessToken)
+	}
+
+	start := h.clock.Now()
+	resp, err := h.client.Do(req)
+	if err != nil {
+		h.logger.Error("USCIS API call failed", err, map[string]interface{}{
+			"environment": env.String(),
+			"baseURL":     result.BaseURL,
+		})
+		return fmt.Errorf("upstream request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	elapsed := h.clock.Now().Sub(start)
+
+	result.Config["apiStatus"] = fmt.Sprintf("http_%d", resp.StatusCode)
+	result.Config["responseTime"] = elapsed.Round(time.Millisecond).String()
+	result.Config["oauth_valid"] = fmt.Sprintf("%t", resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden)
+
+	h.logger.Info("USCIS API call completed", map[string]interface{}{
+		"environment":  env.String(),
+		"apiStatus":    result.Config["apiStatus"],
+		"responseTime": result.Config["responseTime"],
+	})
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("upstream returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// FetchCaseStatus requests GET {BaseURL}/{receiptNumber} and returns the payload
+func (h *httpUpstream) FetchCaseStatus(ctx context.Context, req CaseStatusRequest) ([]byte, error) {
+	endpoint := strings.TrimRight(req.BaseURL, "/") + "/" + url.PathEscape(req.ReceiptNumber)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build case status request: %w", err)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if req.Token != nil && req.Token.AccessToken != "" {
+		httpReq.Header.Set("Authorization", req.Token.TokenType+" "+req.Token.AccessToken)
+	}
+
+	resp, err := h.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("case status request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCaseStatusBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read case status response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		h.logger.Warn("Case status request rejected", map[string]interface{}{
+			"environment": req.Environment.String(),
+			"status":      resp.StatusCode,
+		})
+		return nil, fmt.Errorf("case status request returned status %d", resp.StatusCode)
+	}
+
+	return body, nil
+}
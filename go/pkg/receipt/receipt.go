@@ -0,0 +1,201 @@
+package receipt
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Length is the number of characters in a USCIS receipt number
+const Length = 13
+
+// ServiceCenter describes a USCIS office that issues receipt numbers
+type ServiceCenter struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+	// Canonical is the code of the primary prefix when Code is an alias
+	// (for example VSC for EAC)
+	Canonical string `json:"canonical"`
+	// Electronic reports whether the center issues receipts for online
+	// filings, which do not follow the year/workday layout
+	Electronic bool `json:"electronic"`
+}
+
+// serviceCenters lists the receipt prefixes in use by USCIS
+var serviceCenters = map[string]ServiceCenter{
+	"EAC": {Code: "EAC", Name: "Vermont Service Center", Canonical: "EAC"},
+	"VSC": {Code: "VSC", Name: "Vermont Service Center", Canonical: "EAC"},
+	"WAC": {Code: "WAC", Name: "California Service Center", Canonical: "WAC"},
+	"CSC": {Code: "CSC", Name: "California Service Center", Canonical: "WAC"},
+	"LIN": {Code: "LIN", Name: "Nebraska Service Center", Canonical: "LIN"},
+	"NSC": {Code: "NSC", Name: "Nebraska Service Center", Canonical: "LIN"},
+	"SRC": {Code: "SRC", Name: "Texas Service Center", Canonical: "SRC"},
+	"TSC": {Code: "TSC", Name: "Texas Service Center", Canonical: "SRC"},
+	"MSC": {Code: "MSC", Name: "National Benefits Center", Canonical: "MSC"},
+	"NBC": {Code: "NBC", Name: "National Benefits Center", Canonical: "MSC"},
+	"YSC": {Code: "YSC", Name: "Potomac Service Center", Canonical: "YSC"},
+	"IOE": {Code: "IOE", Name: "USCIS Electronic Immigration System", Canonical: "IOE", Electronic: true},
+}
+
+// LookupCenter returns the service center for a receipt prefix
+func LookupCenter(code string) (ServiceCenter, bool) {
+	center, ok := serviceCenters[strings.ToUpper(code)]
+	return center, ok
+}
+
+// Centers returns all known service centers sorted by code
+func Centers() []ServiceCenter {
+	centers := make([]ServiceCenter, 0, len(serviceCenters))
+	for _, c := range serviceCenters {
+		centers = append(centers, c)
+	}
+	sort.Slice(centers, func(i, j int) bool {
+		return centers[i].Code < centers[j].Code
+	})
+	return centers
+}
+
+// knownCenterCodes returns the sorted list of center codes for error messages
+func knownCenterCodes() string {
+	codes := make([]string, 0, len(serviceCenters))
+	for code := range serviceCenters {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return strings.Join(codes, ", ")
+}
+
+// ParseError describes why a receipt number could not be parsed
+type ParseError struct {
+	Input   string
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid receipt number %q: %s", e.Input, e.Message)
+}
+
+// Receipt is a parsed USCIS receipt number.
+//
+// Paper-filed receipts follow the layout CCCYYDDDSSSSS: a three letter
+// service center, the two digit fiscal year, the computer workday within
+// that fiscal year and a five digit sequence number. Electronic (IOE)
+// receipts carry ten opaque digits instead.
+type Receipt struct {
+	Number     string        `json:"number"`
+	Center     ServiceCenter `json:"center"`
+	FiscalYear int           `json:"fiscalYear,omitempty"`
+	Workday    int           `json:"workday,omitempty"`
+	Sequence   int           `json:"sequence"`
+}
+
+// Normalize uppercases a receipt number and strips spaces, dashes and
+// surrounding whitespace
+func Normalize(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	return strings.NewReplacer(" ", "", "-", "").Replace(s)
+}
+
+// Parse parses and validates a receipt number
+func Parse(s string) (Receipt, error) {
+	number := Normalize(s)
+	if number == "" {
+		return Receipt{}, &ParseError{Input: s, Message: "receipt number cannot be empty"}
+	}
+	if len(number) != Length {
+		return Receipt{}, &ParseError{Input: s, Message: fmt.Sprintf("must be %d characters: 3 letters followed by 10 digits", Length)}
+	}
+
+	prefix, digits := number[:3], number[3:]
+	for _, r := range prefix {
+		if r < 'A' || r > 'Z' {
+			return Receipt{}, &ParseError{Input: s, Message: "must start with a 3 letter service center code"}
+		}
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return Receipt{}, &ParseError{Input: s, Message: "service center code must be followed by 10 digits"}
+		}
+	}
+
+	center, ok := serviceCenters[prefix]
+	if !ok {
+		return Receipt{}, &ParseError{Input: s, Message: fmt.Sprintf("unknown service center %q (expected one of %s)", prefix, knownCenterCodes())}
+	}
+
+	r := Receipt{Number: number, Center: center}
+	if center.Electronic {
+		r.Sequence, _ = strconv.Atoi(digits)
+		return r, nil
+	}
+
+	year, _ := strconv.Atoi(digits[0:2])
+	workday, _ := strconv.Atoi(digits[2:5])
+	sequence, _ := strconv.Atoi(digits[5:])
+	if workday == 0 {
+		return Receipt{}, &ParseError{Input: s, Message: "computer workday cannot be 000"}
+	}
+
+	r.FiscalYear = 2000 + year
+	r.Workday = workday
+	r.Sequence = sequence
+	return r, nil
+}
+
+// IsValid reports whether s is a parseable receipt number
+func IsValid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+// HasWorkday reports whether the receipt encodes a fiscal year and workday
+func (r Receipt) HasWorkday() bool {
+	return !r.Center.Electronic
+}
+
+// String returns the normalized receipt number
+func (r Receipt) String() string {
+	return r.Number
+}
+
+// maxCalendarWorkday is the highest workday value that maps onto a day of
+// the fiscal year; larger values (such as the 9xx lockbox series) are batch
+// identifiers rather than dates
+const maxCalendarWorkday = 366
+
+// ApproxReceivedDate estimates when the receipt was issued from its fiscal
+// year and workday. The fiscal year starts on October 1 of the previous
+// calendar year. It reports false for electronic and lockbox receipts whose
+// digits do not encode a date.
+func (r Receipt) ApproxReceivedDate() (time.Time, bool) {
+	if !r.HasWorkday() || r.Workday > maxCalendarWorkday {
+		return time.Time{}, false
+	}
+	start := time.Date(r.FiscalYear-1, time.October, 1, 0, 0, 0, 0, time.UTC)
+	return start.AddDate(0, 0, r.Workday-1), true
+}
+
+// Offset returns the receipt delta positions away in the same center, fiscal
+// year and workday batch. It reports false when the sequence would fall
+// outside the range the batch can encode.
+func (r Receipt) Offset(delta int) (Receipt, bool) {
+	seq := r.Sequence + delta
+	if r.HasWorkday() {
+		if seq < 0 || seq > 99999 {
+			return Receipt{}, false
+		}
+		n := r
+		n.Sequence = seq
+		n.Number = fmt.Sprintf("%s%02d%03d%05d", r.Center.Code, r.FiscalYear%100, r.Workday, seq)
+		return n, true
+	}
+	if seq < 0 || seq > 9999999999 {
+		return Receipt{}, false
+	}
+	n := r
+	n.Sequence = seq
+	n.Number = fmt.Sprintf("%s%010d", r.Center.Code, seq)
+	return n, true
+}
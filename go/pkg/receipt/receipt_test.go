@@ -0,0 +1,195 @@
+package receipt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		wantNumber   string
+		wantCenter   string
+		wantYear     int
+		wantWorkday  int
+		wantSequence int
+	}{
+		{
+			name:         "vermont paper receipt",
+			input:        "EAC2190050123",
+			wantNumber:   "EAC2190050123",
+			wantCenter:   "Vermont Service Center",
+			wantYear:     2021,
+			wantWorkday:  900,
+			wantSequence: 50123,
+		},
+		{
+			name:         "lowercase with dashes and spaces",
+			input:        "  lin-23-123-51234 ",
+			wantNumber:   "LIN2312351234",
+			wantCenter:   "Nebraska Service Center",
+			wantYear:     2023,
+			wantWorkday:  123,
+			wantSequence: 51234,
+		},
+		{
+			name:         "national benefits center",
+			input:        "MSC2491234567",
+			wantNumber:   "MSC2491234567",
+			wantCenter:   "National Benefits Center",
+			wantYear:     2024,
+			wantWorkday:  912,
+			wantSequence: 34567,
+		},
+		{
+			name:         "potomac",
+			input:        "YSC2390000001",
+			wantNumber:   "YSC2390000001",
+			wantCenter:   "Potomac Service Center",
+			wantYear:     2023,
+			wantWorkday:  900,
+			wantSequence: 1,
+		},
+		{
+			name:         "electronic receipt",
+			input:        "IOE0912345678",
+			wantNumber:   "IOE0912345678",
+			wantCenter:   "USCIS Electronic Immigration System",
+			wantSequence: 912345678,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			if r.Number != tt.wantNumber {
+				t.Errorf("Number = %q, want %q", r.Number, tt.wantNumber)
+			}
+			if r.Center.Name != tt.wantCenter {
+				t.Errorf("Center = %q, want %q", r.Center.Name, tt.wantCenter)
+			}
+			if r.FiscalYear != tt.wantYear || r.Workday != tt.wantWorkday || r.Sequence != tt.wantSequence {
+				t.Errorf("got year=%d workday=%d sequence=%d, want %d/%d/%d",
+					r.FiscalYear, r.Workday, r.Sequence, tt.wantYear, tt.wantWorkday, tt.wantSequence)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		errContains string
+	}{
+		{"empty", "", "cannot be empty"},
+		{"too short", "EAC123", "must be 13 characters"},
+		{"digits in prefix", "E4C2190050123", "3 letter service center"},
+		{"letters in digits", "EAC21900501X3", "followed by 10 digits"},
+		{"unknown center", "ABC1234567890", `unknown service center "ABC"`},
+		{"zero workday", "EAC2100050123", "workday cannot be 000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			if err == nil {
+				t.Fatalf("Parse(%q) expected error", tt.input)
+			}
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Errorf("error type = %T, want *ParseError", err)
+			}
+			if !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("error = %q, want it to contain %q", err.Error(), tt.errContains)
+			}
+		})
+	}
+}
+
+func TestUnknownCenterListsKnownCodes(t *testing.T) {
+	_, err := Parse("ZZZ2190050123")
+	if err == nil || !strings.Contains(err.Error(), "EAC") || !strings.Contains(err.Error(), "WAC") {
+		t.Errorf("error = %v, want list of known centers", err)
+	}
+}
+
+func TestCenterAliases(t *testing.T) {
+	for alias, canonical := range map[string]string{"VSC": "EAC", "CSC": "WAC", "NSC": "LIN", "TSC": "SRC", "NBC": "MSC"} {
+		c, ok := LookupCenter(alias)
+		if !ok || c.Canonical != canonical {
+			t.Errorf("LookupCenter(%q) = %+v, want canonical %s", alias, c, canonical)
+		}
+	}
+	if len(Centers()) != len(serviceCenters) {
+		t.Errorf("Centers() returned %d entries", len(Centers()))
+	}
+}
+
+func TestApproxReceivedDate(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   string
+		wantOK bool
+	}{
+		{"EAC2100150123", "2020-10-01", true},
+		{"LIN2312351234", "2023-01-31", true},
+		{"MSC2491234567", "", false}, // lockbox 9xx series
+		{"IOE0912345678", "", false}, // electronic
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.input)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.input, err)
+		}
+		got, ok := r.ApproxReceivedDate()
+		if ok != tt.wantOK {
+			t.Errorf("%s: ok = %t, want %t", tt.input, ok, tt.wantOK)
+			continue
+		}
+		if ok && got.Format("2006-01-02") != tt.want {
+			t.Errorf("%s: date = %s, want %s", tt.input, got.Format("2006-01-02"), tt.want)
+		}
+	}
+}
+
+func TestOffset(t *testing.T) {
+	tests := []struct {
+		input  string
+		delta  int
+		want   string
+		wantOK bool
+	}{
+		{"EAC2190000001", 5, "EAC2190000006", true},
+		{"EAC2190000001", -1, "EAC2190000000", true},
+		{"EAC2190000001", -2, "", false},
+		{"LIN2312399999", 1, "", false},
+		{"LIN2312300100", 0, "LIN2312300100", true},
+		{"IOE0912345678", -10, "IOE0912345668", true},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.input)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.input, err)
+		}
+		got, ok := r.Offset(tt.delta)
+		if ok != tt.wantOK {
+			t.Errorf("%s%+d: ok = %t, want %t", tt.input, tt.delta, ok, tt.wantOK)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if got.Number != tt.want {
+			t.Errorf("%s%+d = %s, want %s", tt.input, tt.delta, got.Number, tt.want)
+		}
+		if reparsed, err := Parse(got.Number); err != nil || reparsed != got {
+			t.Errorf("%s%+d does not round-trip: %+v, %v", tt.input, tt.delta, reparsed, err)
+		}
+	}
+}
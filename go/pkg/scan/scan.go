@@ -0,0 +1,371 @@
+// Package scan checks the receipts adjacent to a case to show how similar
+// cases filed in the same batch are moving.
+package scan
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/jobs"
+	"MyUSCISgo/pkg/logging"
+	"MyUSCISgo/pkg/ratelimit"
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/types"
+)
+
+const (
+	// MaxRadius caps how many receipts on each side a single scan may visit
+	MaxRadius = 250
+	// DefaultDelay is the pause between consecutive upstream lookups
+	DefaultDelay = 500 * time.Millisecond
+	// limiterKey identifies scan traffic in the shared rate limiter
+	limiterKey = "receipt-scan"
+	// DefaultCacheTTL is how long a cached lookup is reused
+	DefaultCacheTTL = time.Hour
+)
+
+// Fetcher looks up the current status of a single receipt
+type Fetcher interface {
+	FetchCaseStatus(ctx context.Context, receiptNumber string) (*types.CaseRecord, error)
+}
+
+// FetcherFunc adapts a function to the Fetcher interface
+type FetcherFunc func(ctx context.Context, receiptNumber string) (*types.CaseRecord, error)
+
+// FetchCaseStatus calls f
+func (f FetcherFunc) FetchCaseStatus(ctx context.Context, receiptNumber string) (*types.CaseRecord, error) {
+	return f(ctx, receiptNumber)
+}
+
+// Result is the outcome of looking up one neighbour
+type Result struct {
+	ReceiptNumber string            `json:"receiptNumber"`
+	Offset        int               `json:"offset"`
+	Record        *types.CaseRecord `json:"record,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	FetchedAt     time.Time         `json:"fetchedAt"`
+	Cached        bool              `json:"cached,omitempty"`
+}
+
+// Cache stores successful lookups so an interrupted scan can resume. Keys
+// combine the environment and the receipt number, so lookups against one
+// environment are never served to another.
+type Cache interface {
+	Get(key string) (Result, bool)
+	Put(key string, result Result)
+}
+
+// cacheKey is the cache key of a receipt looked up in env
+func cacheKey(env, receiptNumber string) string {
+	return env + "/" + receiptNumber
+}
+
+// cachedResult is a result with the time it was stored
+type cachedResult struct {
+	result   Result
+	storedAt time.Time
+}
+
+// MemoryCache is an in-memory Cache whose results expire after a TTL. It
+// is safe for concurrent use.
+type MemoryCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	clock   clock.Clock
+	results map[string]cachedResult
+}
+
+// NewMemoryCache creates an empty in-memory cache whose results expire
+// after ttl, or DefaultCacheTTL when ttl is not positive. A nil clock uses
+// the real time.
+func NewMemoryCache(ttl time.Duration, c clock.Clock) *MemoryCache {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	if c == nil {
+		c = clock.Real()
+	}
+	return &MemoryCache{ttl: ttl, clock: c, results: make(map[string]cachedResult)}
+}
+
+// Get returns a cached result that has not expired
+func (c *MemoryCache) Get(key string) (Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.results[key]
+	if !ok {
+		return Result{}, false
+	}
+	if c.clock.Now().Sub(cached.storedAt) >= c.ttl {
+		delete(c.results, key)
+		return Result{}, false
+	}
+	return cached.result, true
+}
+
+// Put stores a result
+func (c *MemoryCache) Put(key string, result Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock.Now()
+	for k, cached := range c.results {
+		if now.Sub(cached.storedAt) >= c.ttl {
+			delete(c.results, k)
+		}
+	}
+	c.results[key] = cachedResult{result: result, storedAt: now}
+}
+
+// Len returns the number of cached results that have not expired
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock.Now()
+	n := 0
+	for _, cached := range c.results {
+		if now.Sub(cached.storedAt) < c.ttl {
+			n++
+		}
+	}
+	return n
+}
+
+// Report aggregates the statuses of a receipt's neighbours
+type Report struct {
+	Origin   string `json:"origin"`
+	Radius   int    `json:"radius"`
+	Scanned  int    `json:"scanned"`
+	Fetched  int    `json:"fetched"`
+	Cached   int    `json:"cached"`
+	Failed   int    `json:"failed"`
+	Complete bool   `json:"complete"`
+	// ByForm counts found cases per form type
+	ByForm map[string]int `json:"byForm"`
+	// ByStatus counts found cases per canonical status code
+	ByStatus map[types.CaseStatusCode]int `json:"byStatus"`
+	// ByFormStatus breaks the status counts down per form type
+	ByFormStatus map[string]map[types.CaseStatusCode]int `json:"byFormStatus"`
+	Results      []Result                                `json:"results"`
+}
+
+func newReport(origin receipt.Receipt, radius int) *Report {
+	return &Report{
+		Origin:       origin.Number,
+		Radius:       radius,
+		ByForm:       make(map[string]int),
+		ByStatus:     make(map[types.CaseStatusCode]int),
+		ByFormStatus: make(map[string]map[types.CaseStatusCode]int),
+	}
+}
+
+func (r *Report) add(result Result) {
+	r.Scanned++
+	r.Results = append(r.Results, result)
+	switch {
+	case result.Error != "":
+		r.Failed++
+		return
+	case result.Cached:
+		r.Cached++
+	default:
+		r.Fetched++
+	}
+
+	form := result.Record.FormType
+	if form == "" {
+		form = "unknown"
+	}
+	code := result.Record.Status.Code
+	r.ByForm[form]++
+	r.ByStatus[code]++
+	if r.ByFormStatus[form] == nil {
+		r.ByFormStatus[form] = make(map[types.CaseStatusCode]int)
+	}
+	r.ByFormStatus[form][code]++
+}
+
+// Neighbours lists the receipts within radius of r in the same center,
+// fiscal year and workday, ordered by distance and then by sequence. The
+// origin receipt itself is not included.
+func Neighbours(r receipt.Receipt, radius int) ([]receipt.Receipt, error) {
+	if radius < 1 || radius > MaxRadius {
+		return nil, fmt.Errorf("radius must be between 1 and %d", MaxRadius)
+	}
+	out := make([]receipt.Receipt, 0, 2*radius)
+	for d := 1; d <= radius; d++ {
+		if n, ok := r.Offset(-d); ok {
+			out = append(out, n)
+		}
+		if n, ok := r.Offset(d); ok {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
+
+// Scanner walks a receipt's neighbourhood. Lookups go through a shared rate
+// limiter and are spaced out by a fixed delay so a scan stays polite to
+// the upstream service.
+type Scanner struct {
+	fetcher Fetcher
+	limiter *ratelimit.RateLimiter
+	cache   Cache
+	clock   clock.Clock
+	logger  *logging.Logger
+	delay   time.Duration
+	env     string
+}
+
+// Option configures a Scanner
+type Option func(*Scanner)
+
+// WithRateLimiter shares a rate limiter with other upstream callers
+func WithRateLimiter(rl *ratelimit.RateLimiter) Option {
+	return func(s *Scanner) { s.limiter = rl }
+}
+
+// WithCache sets the cache used to resume scans
+func WithCache(c Cache) Option {
+	return func(s *Scanner) { s.cache = c }
+}
+
+// WithEnvironment names the environment lookups are made against, so
+// cached results are only reused for scans of the same environment
+func WithEnvironment(env string) Option {
+	return func(s *Scanner) { s.env = env }
+}
+
+// WithClock sets the clock used for pacing
+func WithClock(c clock.Clock) Option {
+	return func(s *Scanner) { s.clock = c }
+}
+
+// WithLogger sets the scanner logger
+func WithLogger(l *logging.Logger) Option {
+	return func(s *Scanner) { s.logger = l }
+}
+
+// WithDelay sets the pause between upstream lookups
+func WithDelay(d time.Duration) Option {
+	return func(s *Scanner) { s.delay = d }
+}
+
+// NewScanner creates a scanner that looks up statuses with fetcher
+func NewScanner(fetcher Fetcher, opts ...Option) *Scanner {
+	s := &Scanner{
+		fetcher: fetcher,
+		clock:   clock.Real(),
+		delay:   DefaultDelay,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.cache == nil {
+		s.cache = NewMemoryCache(DefaultCacheTTL, s.clock)
+	}
+	if s.logger == nil {
+		s.logger = logging.NewLogger(logging.LogLevelInfo)
+	}
+	if s.limiter == nil {
+		s.limiter = ratelimit.NewRateLimiter(60, time.Minute)
+	}
+	return s
+}
+
+// Scan looks up every neighbour of origin within radius. Cached results are
+// reused without contacting the upstream service. When ctx is cancelled the
+// partial report is returned together with the context error; running the
+// same scan again resumes from the cache.
+func (s *Scanner) Scan(ctx context.Context, origin receipt.Receipt, radius int, report jobs.ReportFunc) (*Report, error) {
+	neighbours, err := Neighbours(origin, radius)
+	if err != nil {
+		return nil, err
+	}
+	if report == nil {
+		report = func(string, int, string) {}
+	}
+
+	result := newReport(origin, radius)
+	fetched := 0
+	for i, n := range neighbours {
+		offset := n.Sequence - origin.Sequence
+		if cached, ok := s.cache.Get(cacheKey(s.env, n.Number)); ok {
+			cached.Offset = offset
+			cached.Cached = true
+			result.add(cached)
+			report("scan", (i+1)*100/len(neighbours), n.Number)
+			continue
+		}
+
+		if err := s.pace(ctx, fetched > 0); err != nil {
+			s.finish(result)
+			return result, err
+		}
+		fetched++
+
+		r := Result{ReceiptNumber: n.Number, Offset: offset, FetchedAt: s.clock.Now()}
+		record, err := s.fetcher.FetchCaseStatus(ctx, n.Number)
+		switch {
+		case ctx.Err() != nil:
+			s.finish(result)
+			return result, ctx.Err()
+		case err != nil:
+			r.Error = err.Error()
+		case record == nil:
+			r.Error = "no case record returned"
+		default:
+			r.Record = record
+			s.cache.Put(cacheKey(s.env, n.Number), r)
+		}
+		result.add(r)
+		report("scan", (i+1)*100/len(neighbours), n.Number)
+	}
+
+	result.Complete = true
+	s.finish(result)
+	s.logger.Info("Receipt scan completed", map[string]interface{}{
+		"origin":  origin.Number,
+		"scanned": result.Scanned,
+		"fetched": result.Fetched,
+		"cached":  result.Cached,
+		"failed":  result.Failed,
+	})
+	return result, nil
+}
+
+// pace waits for the inter-request delay and for rate limiter capacity
+func (s *Scanner) pace(ctx context.Context, delay bool) error {
+	if delay && s.delay > 0 {
+		if err := s.sleep(ctx, s.delay); err != nil {
+			return err
+		}
+	}
+	for !s.limiter.Allow(limiterKey) {
+		wait := s.delay
+		if wait <= 0 {
+			wait = time.Second
+		}
+		if err := s.sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Scanner) sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.clock.After(d):
+		return nil
+	}
+}
+
+// finish orders results by offset so reports are stable across resumes
+func (s *Scanner) finish(r *Report) {
+	sort.Slice(r.Results, func(i, j int) bool { return r.Results[i].Offset < r.Results[j].Offset })
+}
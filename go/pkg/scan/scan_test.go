@@ -0,0 +1,259 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/types"
+)
+
+// fakeFetcher serves canned records and records every lookup
+type fakeFetcher struct {
+	mu      sync.Mutex
+	records map[string]*types.CaseRecord
+	calls   []string
+	onCall  func(n int)
+}
+
+func (f *fakeFetcher) FetchCaseStatus(ctx context.Context, receiptNumber string) (*types.CaseRecord, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, receiptNumber)
+	n := len(f.calls)
+	f.mu.Unlock()
+	if f.onCall != nil {
+		f.onCall(n)
+	}
+	if r, ok := f.records[receiptNumber]; ok {
+		return r, nil
+	}
+	return nil, errors.New("case not found")
+}
+
+func (f *fakeFetcher) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.calls)
+}
+
+func record(number, form string, code types.CaseStatusCode) *types.CaseRecord {
+	status, _ := types.CaseStatusFromCode(code)
+	return &types.CaseRecord{ReceiptNumber: number, FormType: form, Status: status}
+}
+
+func mustParse(t *testing.T, s string) receipt.Receipt {
+	t.Helper()
+	r, err := receipt.Parse(s)
+	if err != nil {
+		t.Fatalf("receipt.Parse(%q) error = %v", s, err)
+	}
+	return r
+}
+
+// runScan runs a scan while advancing the fake clock whenever it is waited on
+func runScan(t *testing.T, fc *clock.Fake, fn func() (*Report, error)) (*Report, error) {
+	t.Helper()
+
+	type outcome struct {
+		report *Report
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		report, err := fn()
+		done <- outcome{report, err}
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case o := <-done:
+			return o.report, o.err
+		case <-deadline:
+			t.Fatal("scan did not finish")
+		default:
+		}
+		if fc.Waiters() > 0 {
+			fc.Advance(time.Second)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNeighbours(t *testing.T) {
+	tests := []struct {
+		name    string
+		origin  string
+		radius  int
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "middle of batch",
+			origin: "LIN2312300100",
+			radius: 2,
+			want:   []string{"LIN2312300099", "LIN2312300101", "LIN2312300098", "LIN2312300102"},
+		},
+		{
+			name:   "start of batch",
+			origin: "EAC2190000001",
+			radius: 2,
+			want:   []string{"EAC2190000000", "EAC2190000002", "EAC2190000003"},
+		},
+		{name: "zero radius", origin: "EAC2190000001", radius: 0, wantErr: true},
+		{name: "radius too large", origin: "EAC2190000001", radius: MaxRadius + 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Neighbours(mustParse(t, tt.origin), tt.radius)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Neighbours() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Neighbours() returned %d receipts, want %d", len(got), len(tt.want))
+			}
+			for i, r := range got {
+				if r.Number != tt.want[i] {
+					t.Errorf("Neighbours()[%d] = %s, want %s", i, r.Number, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func newFixture() *fakeFetcher {
+	return &fakeFetcher{records: map[string]*types.CaseRecord{
+		"IOE0900000098": record("IOE0900000098", "I-130", types.StatusApproved),
+		"IOE0900000099": record("IOE0900000099", "I-130", types.StatusApproved),
+		"IOE0900000101": record("IOE0900000101", "I-485", types.StatusReceived),
+		"IOE0900000102": record("IOE0900000102", "I-130", types.StatusReceived),
+	}}
+}
+
+func TestScanAggregates(t *testing.T) {
+	fc := clock.NewFake(time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC))
+	fetcher := newFixture()
+	s := NewScanner(fetcher, WithClock(fc), WithDelay(time.Second))
+	start := fc.Now()
+
+	report, err := runScan(t, fc, func() (*Report, error) {
+		return s.Scan(context.Background(), mustParse(t, "IOE0900000100"), 3, nil)
+	})
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	if !report.Complete || report.Scanned != 6 || report.Fetched != 4 || report.Failed != 2 {
+		t.Errorf("report totals = complete %v scanned %d fetched %d failed %d; want true 6 4 2",
+			report.Complete, report.Scanned, report.Fetched, report.Failed)
+	}
+	if report.ByForm["I-130"] != 3 || report.ByForm["I-485"] != 1 {
+		t.Errorf("ByForm = %v", report.ByForm)
+	}
+	if report.ByStatus[types.StatusApproved] != 2 || report.ByStatus[types.StatusReceived] != 2 {
+		t.Errorf("ByStatus = %v", report.ByStatus)
+	}
+	if report.ByFormStatus["I-130"][types.StatusReceived] != 1 {
+		t.Errorf("ByFormStatus = %v", report.ByFormStatus)
+	}
+	for i := 1; i < len(report.Results); i++ {
+		if report.Results[i-1].Offset >= report.Results[i].Offset {
+			t.Fatalf("results not ordered by offset: %+v", report.Results)
+		}
+	}
+	if elapsed := fc.Now().Sub(start); elapsed < 5*time.Second {
+		t.Errorf("scan of 6 receipts took %v of clock time, want at least 5s of pacing", elapsed)
+	}
+}
+
+func TestScanResumesFromCache(t *testing.T) {
+	fc := clock.NewFake(time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC))
+	cache := NewMemoryCache(time.Hour, fc)
+	origin := mustParse(t, "IOE0900000100")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupted := newFixture()
+	interrupted.onCall = func(n int) {
+		if n == 3 {
+			cancel()
+		}
+	}
+	s := NewScanner(interrupted, WithClock(fc), WithDelay(time.Second), WithCache(cache))
+	partial, err := runScan(t, fc, func() (*Report, error) {
+		return s.Scan(ctx, origin, 2, nil)
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Scan() error = %v, want context.Canceled", err)
+	}
+	if partial == nil || partial.Complete || partial.Scanned != 2 {
+		t.Fatalf("partial report = %+v, want 2 incomplete results", partial)
+	}
+	if cache.Len() != 2 {
+		t.Fatalf("cache holds %d results, want 2", cache.Len())
+	}
+
+	resumed := newFixture()
+	s = NewScanner(resumed, WithClock(fc), WithDelay(time.Second), WithCache(cache))
+	var stages []int
+	report, err := runScan(t, fc, func() (*Report, error) {
+		return s.Scan(context.Background(), origin, 2, func(stage string, percent int, message string) {
+			stages = append(stages, percent)
+		})
+	})
+	if err != nil {
+		t.Fatalf("resumed Scan() error = %v", err)
+	}
+	if resumed.callCount() != 2 {
+		t.Errorf("resumed scan made %d lookups, want 2", resumed.callCount())
+	}
+	if report.Cached != 2 || report.Scanned != 4 || !report.Complete {
+		t.Errorf("resumed report = cached %d scanned %d complete %v", report.Cached, report.Scanned, report.Complete)
+	}
+	if len(stages) == 0 || stages[len(stages)-1] != 100 {
+		t.Errorf("progress reports = %v, want to end at 100", stages)
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	fc := clock.NewFake(time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC))
+	cache := NewMemoryCache(time.Hour, fc)
+	result := Result{ReceiptNumber: "IOE0900000101"}
+	cache.Put(cacheKey("development", result.ReceiptNumber), result)
+
+	if _, ok := cache.Get(cacheKey("production", result.ReceiptNumber)); ok {
+		t.Error("Get() served a development result for production")
+	}
+	fc.Advance(59 * time.Minute)
+	if got, ok := cache.Get(cacheKey("development", result.ReceiptNumber)); !ok || got.ReceiptNumber != result.ReceiptNumber {
+		t.Errorf("Get() before the TTL = %+v, %v", got, ok)
+	}
+	fc.Advance(time.Minute)
+	if _, ok := cache.Get(cacheKey("development", result.ReceiptNumber)); ok || cache.Len() != 0 {
+		t.Errorf("Get() after the TTL found the result, %d cached", cache.Len())
+	}
+}
+
+func TestScanCacheIsPerEnvironment(t *testing.T) {
+	fc := clock.NewFake(time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC))
+	cache := NewMemoryCache(time.Hour, fc)
+	origin := mustParse(t, "IOE0900000100")
+
+	for _, env := range []string{"development", "staging"} {
+		fetcher := newFixture()
+		s := NewScanner(fetcher, WithClock(fc), WithDelay(0), WithCache(cache), WithEnvironment(env))
+		report, err := runScan(t, fc, func() (*Report, error) {
+			return s.Scan(context.Background(), origin, 1, nil)
+		})
+		if err != nil {
+			t.Fatalf("Scan() in %s error = %v", env, err)
+		}
+		if report.Cached != 0 || fetcher.callCount() != 2 {
+			t.Errorf("scan in %s: %d cached, %d lookups, want every receipt looked up", env, report.Cached, fetcher.callCount())
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QuietHours is a daily window during which no polling happens. The window
+// may wrap past midnight, e.g. 22:00-06:00.
+type QuietHours struct {
+	// Start and End are offsets from local midnight
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+// ParseQuietHours parses a window such as "22:00-06:00" in loc (UTC when nil)
+func ParseQuietHours(s string, loc *time.Location) (*QuietHours, error) {
+	from, to, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		return nil, fmt.Errorf("quiet hours %q must look like HH:MM-HH:MM", s)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return nil, err
+	}
+	if start == end {
+		return nil, fmt.Errorf("quiet hours %q start and end must differ", s)
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	return &QuietHours{Start: start, End: end, Location: loc}, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q: use HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t falls inside the quiet window
+func (q *QuietHours) Contains(t time.Time) bool {
+	if q == nil {
+		return false
+	}
+	loc := q.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+	offset := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute + time.Duration(local.Second())*time.Second
+	if q.Start < q.End {
+		return offset >= q.Start && offset < q.End
+	}
+	return offset >= q.Start || offset < q.End
+}
+
+// String formats the window as HH:MM-HH:MM
+func (q *QuietHours) String() string {
+	format := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return format(q.Start) + "-" + format(q.End)
+}
@@ -0,0 +1,465 @@
+// Package scheduler periodically refreshes the status of tracked cases.
+// Work is spread with jitter, paced by a shared rate limiter and a per-tick
+// quota, backed off per receipt after errors, and suspended during quiet
+// hours. The scheduler runs as a goroutine in both WASM and native builds.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/logging"
+	"MyUSCISgo/pkg/ratelimit"
+	"MyUSCISgo/pkg/types"
+	"MyUSCISgo/pkg/watchlist"
+)
+
+// limiterKey identifies scheduler traffic in the shared rate limiter
+const limiterKey = "scheduler"
+
+// ErrRunning is returned by Start when the scheduler is already running
+var ErrRunning = errors.New("scheduler is already running")
+
+// Target is a receipt to poll together with its last known status
+type Target struct {
+	ReceiptNumber string
+	LastStatus    *types.CaseStatus
+}
+
+// Source lists the receipts to poll
+type Source interface {
+	Targets(ctx context.Context) ([]Target, error)
+}
+
+// SourceFunc adapts a function to the Source interface
+type SourceFunc func(ctx context.Context) ([]Target, error)
+
+// Targets calls f
+func (f SourceFunc) Targets(ctx context.Context) ([]Target, error) {
+	return f(ctx)
+}
+
+// FromWatchlist polls every receipt on a watchlist
+func FromWatchlist(svc *watchlist.Service) Source {
+	return SourceFunc(func(ctx context.Context) ([]Target, error) {
+		entries, err := svc.List(ctx, watchlist.Filter{})
+		if err != nil {
+			return nil, err
+		}
+		targets := make([]Target, len(entries))
+		for i, e := range entries {
+			targets[i] = Target{ReceiptNumber: e.ReceiptNumber, LastStatus: e.LastStatus}
+		}
+		return targets, nil
+	})
+}
+
+// Fetcher looks up the current status of a receipt
+type Fetcher interface {
+	FetchCaseStatus(ctx context.Context, receiptNumber string) (*types.CaseRecord, error)
+}
+
+// FetcherFunc adapts a function to the Fetcher interface
+type FetcherFunc func(ctx context.Context, receiptNumber string) (*types.CaseRecord, error)
+
+// FetchCaseStatus calls f
+func (f FetcherFunc) FetchCaseStatus(ctx context.Context, receiptNumber string) (*types.CaseRecord, error) {
+	return f(ctx, receiptNumber)
+}
+
+// ResultFunc receives every successfully fetched record
+type ResultFunc func(ctx context.Context, record *types.CaseRecord)
+
+// Config tunes polling. Zero fields take the defaults from DefaultConfig.
+type Config struct {
+	// Interval is how often active cases are polled
+	Interval time.Duration
+	// TerminalInterval is how often cases in a terminal status are polled
+	TerminalInterval time.Duration
+	// Jitter randomizes each interval by up to this fraction either way
+	Jitter float64
+	// InitialSpread spreads the first poll of newly seen receipts
+	InitialSpread time.Duration
+	// Tick is how often the scheduler looks for due receipts
+	Tick time.Duration
+	// MaxPerTick caps upstream calls per tick
+	MaxPerTick int
+	// BackoffBase and BackoffMax bound the per-receipt exponential backoff
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// QuietHours suspends polling; nil disables quiet hours
+	QuietHours *QuietHours
+}
+
+// DefaultConfig returns the default polling configuration
+func DefaultConfig() Config {
+	return Config{
+		Interval:         6 * time.Hour,
+		TerminalInterval: 7 * 24 * time.Hour,
+		Jitter:           0.2,
+		InitialSpread:    10 * time.Minute,
+		Tick:             time.Minute,
+		MaxPerTick:       5,
+		BackoffBase:      5 * time.Minute,
+		BackoffMax:       12 * time.Hour,
+	}
+}
+
+func (c Config) withDefaults() Config {
+	d := DefaultConfig()
+	if c.Interval <= 0 {
+		c.Interval = d.Interval
+	}
+	if c.TerminalInterval <= 0 {
+		c.TerminalInterval = d.TerminalInterval
+	}
+	if c.Jitter <= 0 || c.Jitter >= 1 {
+		c.Jitter = d.Jitter
+	}
+	if c.InitialSpread <= 0 {
+		c.InitialSpread = d.InitialSpread
+	}
+	if c.Tick <= 0 {
+		c.Tick = d.Tick
+	}
+	if c.MaxPerTick <= 0 {
+		c.MaxPerTick = d.MaxPerTick
+	}
+	if c.BackoffBase <= 0 {
+		c.BackoffBase = d.BackoffBase
+	}
+	if c.BackoffMax <= 0 {
+		c.BackoffMax = d.BackoffMax
+	}
+	return c
+}
+
+// ReceiptState is the scheduling state of one receipt
+type ReceiptState struct {
+	ReceiptNumber string    `json:"receiptNumber"`
+	NextCheck     time.Time `json:"nextCheck"`
+	LastCheck     time.Time `json:"lastCheck,omitzero"`
+	Failures      int       `json:"failures,omitempty"`
+	LastError     string    `json:"lastError,omitempty"`
+}
+
+// Stats summarizes one scheduling pass
+type Stats struct {
+	Targets  int  `json:"targets"`
+	Due      int  `json:"due"`
+	Checked  int  `json:"checked"`
+	Failed   int  `json:"failed"`
+	Deferred int  `json:"deferred"`
+	Quiet    bool `json:"quiet,omitempty"`
+}
+
+// Scheduler polls the receipts of a Source
+type Scheduler struct {
+	source   Source
+	fetcher  Fetcher
+	onResult ResultFunc
+	config   Config
+	limiter  *ratelimit.RateLimiter
+	clock    clock.Clock
+	logger   *logging.Logger
+
+	mu     sync.Mutex
+	rng    *rand.Rand
+	states map[string]*ReceiptState
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Option configures a Scheduler
+type Option func(*Scheduler)
+
+// WithConfig sets the polling configuration
+func WithConfig(c Config) Option {
+	return func(s *Scheduler) { s.config = c }
+}
+
+// WithRateLimiter shares a rate limiter with other upstream callers
+func WithRateLimiter(rl *ratelimit.RateLimiter) Option {
+	return func(s *Scheduler) { s.limiter = rl }
+}
+
+// WithClock sets the clock used for scheduling
+func WithClock(c clock.Clock) Option {
+	return func(s *Scheduler) { s.clock = c }
+}
+
+// WithLogger sets the scheduler logger
+func WithLogger(l *logging.Logger) Option {
+	return func(s *Scheduler) { s.logger = l }
+}
+
+// WithResultFunc sets the callback that receives fetched records
+func WithResultFunc(fn ResultFunc) Option {
+	return func(s *Scheduler) { s.onResult = fn }
+}
+
+// WithRand sets the random source used for jitter
+func WithRand(r *rand.Rand) Option {
+	return func(s *Scheduler) { s.rng = r }
+}
+
+// New creates a scheduler that polls the receipts of source with fetcher
+func New(source Source, fetcher Fetcher, opts ...Option) *Scheduler {
+	s := &Scheduler{
+		source:  source,
+		fetcher: fetcher,
+		config:  DefaultConfig(),
+		clock:   clock.Real(),
+		states:  make(map[string]*ReceiptState),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.config = s.config.withDefaults()
+	if s.logger == nil {
+		s.logger = logging.NewLogger(logging.LogLevelInfo)
+	}
+	if s.limiter == nil {
+		s.limiter = ratelimit.NewRateLimiter(30, time.Minute)
+	}
+	if s.rng == nil {
+		s.rng = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+	return s
+}
+
+// Start runs the scheduler in a background goroutine until Stop is called
+// or ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return ErrRunning
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	s.cancel, s.done = cancel, done
+	go func() {
+		defer close(done)
+		s.loop(ctx)
+	}()
+
+	s.logger.Info("Scheduler started", map[string]interface{}{
+		"interval": s.config.Interval.String(),
+		"tick":     s.config.Tick.String(),
+	})
+	return nil
+}
+
+// Stop halts the scheduler and waits for an in-flight pass to finish. It is
+// safe to call when the scheduler is not running.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+	s.logger.Info("Scheduler stopped")
+}
+
+// Running reports whether the scheduler loop is active
+func (s *Scheduler) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cancel != nil
+}
+
+func (s *Scheduler) loop(ctx context.Context) {
+	for {
+		if _, err := s.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Warn("Scheduler pass failed", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(s.config.Tick):
+		}
+	}
+}
+
+// RunOnce performs a single scheduling pass: it refreshes the target list,
+// then checks due receipts, most overdue first, until the per-tick quota or
+// the rate limiter stops it
+func (s *Scheduler) RunOnce(ctx context.Context) (Stats, error) {
+	targets, err := s.source.Targets(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	now := s.clock.Now()
+	stats := Stats{Targets: len(targets)}
+	due := s.sync(targets, now)
+	stats.Due = len(due)
+
+	if s.config.QuietHours.Contains(now) {
+		stats.Quiet = true
+		stats.Deferred = len(due)
+		return stats, nil
+	}
+
+	for i, t := range due {
+		if ctx.Err() != nil {
+			return stats, ctx.Err()
+		}
+		if stats.Checked+stats.Failed >= s.config.MaxPerTick || !s.limiter.Allow(limiterKey) {
+			stats.Deferred = len(due) - i
+			break
+		}
+
+		record, err := s.fetcher.FetchCaseStatus(ctx, t.ReceiptNumber)
+		if ctx.Err() != nil {
+			return stats, ctx.Err()
+		}
+		if err == nil && record == nil {
+			err = errors.New("no case record returned")
+		}
+		if err != nil {
+			stats.Failed++
+			s.failed(t.ReceiptNumber, err)
+			continue
+		}
+
+		stats.Checked++
+		s.succeeded(t.ReceiptNumber, record.Status)
+		if s.onResult != nil {
+			s.onResult(ctx, record)
+		}
+	}
+
+	if stats.Checked+stats.Failed > 0 {
+		s.logger.Debug("Scheduler pass completed", map[string]interface{}{
+			"due":      stats.Due,
+			"checked":  stats.Checked,
+			"failed":   stats.Failed,
+			"deferred": stats.Deferred,
+		})
+	}
+	return stats, nil
+}
+
+// sync adds new targets, drops removed ones and returns the due targets
+// ordered by how overdue they are
+func (s *Scheduler) sync(targets []Target, now time.Time) []Target {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool, len(targets))
+	var due []Target
+	for _, t := range targets {
+		seen[t.ReceiptNumber] = true
+		st, ok := s.states[t.ReceiptNumber]
+		if !ok {
+			st = &ReceiptState{ReceiptNumber: t.ReceiptNumber, NextCheck: now}
+			if t.LastStatus != nil {
+				// Receipts with a known status were checked before, so their
+				// first poll is spread out to avoid a burst on startup
+				st.NextCheck = now.Add(time.Duration(s.rng.Int64N(int64(s.config.InitialSpread))))
+			}
+			s.states[t.ReceiptNumber] = st
+		}
+		if !st.NextCheck.After(now) {
+			due = append(due, t)
+		}
+	}
+	for number := range s.states {
+		if !seen[number] {
+			delete(s.states, number)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return s.states[due[i].ReceiptNumber].NextCheck.Before(s.states[due[j].ReceiptNumber].NextCheck)
+	})
+	return due
+}
+
+func (s *Scheduler) succeeded(receiptNumber string, status types.CaseStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.states[receiptNumber]
+	if !ok {
+		return
+	}
+	now := s.clock.Now()
+	interval := s.config.Interval
+	if status.Terminal {
+		interval = s.config.TerminalInterval
+	}
+	st.LastCheck = now
+	st.Failures = 0
+	st.LastError = ""
+	st.NextCheck = now.Add(s.jitter(interval))
+}
+
+func (s *Scheduler) failed(receiptNumber string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.states[receiptNumber]
+	if !ok {
+		return
+	}
+	now := s.clock.Now()
+	st.LastCheck = now
+	st.Failures++
+	st.LastError = err.Error()
+
+	backoff := s.config.BackoffBase
+	for i := 1; i < st.Failures && backoff < s.config.BackoffMax; i++ {
+		backoff *= 2
+	}
+	st.NextCheck = now.Add(s.jitter(min(backoff, s.config.BackoffMax)))
+
+	s.logger.Warn("Scheduled status check failed", map[string]interface{}{
+		"caseNumber": receiptNumber,
+		"failures":   st.Failures,
+		"retryIn":    st.NextCheck.Sub(now).String(),
+		"error":      err.Error(),
+	})
+}
+
+// jitter randomizes d by up to the configured fraction either way
+func (s *Scheduler) jitter(d time.Duration) time.Duration {
+	f := 1 + s.config.Jitter*(2*s.rng.Float64()-1)
+	return time.Duration(float64(d) * f)
+}
+
+// States returns the scheduling state of every known receipt
+func (s *Scheduler) States() []ReceiptState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]ReceiptState, 0, len(s.states))
+	for _, st := range s.states {
+		out = append(out, *st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ReceiptNumber < out[j].ReceiptNumber })
+	return out
+}
+
+// CheckNow makes a receipt due on the next pass
+func (s *Scheduler) CheckNow(receiptNumber string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.states[receiptNumber]
+	if ok {
+		st.NextCheck = s.clock.Now()
+	}
+	return ok
+}
@@ -0,0 +1,340 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/logging"
+	"MyUSCISgo/pkg/ratelimit"
+	"MyUSCISgo/pkg/types"
+	"MyUSCISgo/pkg/watchlist"
+)
+
+var testEpoch = time.Date(2025, 3, 3, 15, 0, 0, 0, time.UTC)
+
+// fakeFetcher returns a fixed status per receipt and counts calls
+type fakeFetcher struct {
+	mu       sync.Mutex
+	statuses map[string]types.CaseStatusCode
+	failing  map[string]bool
+	calls    map[string]int
+}
+
+func newFakeFetcher() *fakeFetcher {
+	return &fakeFetcher{
+		statuses: make(map[string]types.CaseStatusCode),
+		failing:  make(map[string]bool),
+		calls:    make(map[string]int),
+	}
+}
+
+func (f *fakeFetcher) FetchCaseStatus(ctx context.Context, receiptNumber string) (*types.CaseRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[receiptNumber]++
+	if f.failing[receiptNumber] {
+		return nil, errors.New("upstream unavailable")
+	}
+	code, ok := f.statuses[receiptNumber]
+	if !ok {
+		code = types.StatusActivelyReviewed
+	}
+	status, _ := types.CaseStatusFromCode(code)
+	return &types.CaseRecord{ReceiptNumber: receiptNumber, Status: status}, nil
+}
+
+func (f *fakeFetcher) total() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, c := range f.calls {
+		n += c
+	}
+	return n
+}
+
+func staticSource(numbers ...string) SourceFunc {
+	return func(ctx context.Context) ([]Target, error) {
+		targets := make([]Target, len(numbers))
+		for i, n := range numbers {
+			targets[i] = Target{ReceiptNumber: n}
+		}
+		return targets, nil
+	}
+}
+
+func newTestScheduler(fc *clock.Fake, source Source, fetcher Fetcher, cfg Config, opts ...Option) *Scheduler {
+	opts = append([]Option{
+		WithClock(fc),
+		WithConfig(cfg),
+		WithRand(rand.New(rand.NewPCG(1, 2))),
+		WithRateLimiter(ratelimit.NewRateLimiter(1000, time.Minute)),
+		WithLogger(logging.NewLogger(logging.LogLevelFatal)),
+	}, opts...)
+	return New(source, fetcher, opts...)
+}
+
+func stateOf(t *testing.T, s *Scheduler, number string) ReceiptState {
+	t.Helper()
+	for _, st := range s.States() {
+		if st.ReceiptNumber == number {
+			return st
+		}
+	}
+	t.Fatalf("no state for %s", number)
+	return ReceiptState{}
+}
+
+func within(got, want time.Duration, jitter float64) bool {
+	lo := time.Duration(float64(want) * (1 - jitter))
+	hi := time.Duration(float64(want) * (1 + jitter))
+	return got >= lo && got <= hi
+}
+
+func TestRunOnceSchedulesByStatus(t *testing.T) {
+	ctx := context.Background()
+	fc := clock.NewFake(testEpoch)
+	fetcher := newFakeFetcher()
+	fetcher.statuses["EAC2190000002"] = types.StatusDenied
+	fetcher.statuses["EAC2190000003"] = types.StatusApproved
+
+	var results []string
+	s := newTestScheduler(fc, staticSource("EAC2190000001", "EAC2190000002", "EAC2190000003"), fetcher, Config{},
+		WithResultFunc(func(ctx context.Context, r *types.CaseRecord) { results = append(results, r.ReceiptNumber) }))
+
+	stats, err := s.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	if stats.Due != 3 || stats.Checked != 3 || len(results) != 3 {
+		t.Fatalf("stats = %+v, results = %v", stats, results)
+	}
+
+	cfg := DefaultConfig()
+	active := stateOf(t, s, "EAC2190000001").NextCheck.Sub(testEpoch)
+	if !within(active, cfg.Interval, cfg.Jitter) {
+		t.Errorf("active case next check in %v, want about %v", active, cfg.Interval)
+	}
+	terminal := stateOf(t, s, "EAC2190000002").NextCheck.Sub(testEpoch)
+	if !within(terminal, cfg.TerminalInterval, cfg.Jitter) {
+		t.Errorf("terminal case next check in %v, want about %v", terminal, cfg.TerminalInterval)
+	}
+	// an approval is followed by card production or an oath, so it is
+	// polled like any other active case
+	approved := stateOf(t, s, "EAC2190000003").NextCheck.Sub(testEpoch)
+	if !within(approved, cfg.Interval, cfg.Jitter) {
+		t.Errorf("approved case next check in %v, want about %v", approved, cfg.Interval)
+	}
+
+	// Nothing is due again until the interval has passed
+	stats, _ = s.RunOnce(ctx)
+	if stats.Due != 0 {
+		t.Errorf("second pass found %d due receipts, want 0", stats.Due)
+	}
+	fc.Advance(max(active, approved))
+	stats, _ = s.RunOnce(ctx)
+	if stats.Checked != 2 || fetcher.calls["EAC2190000001"] != 2 || fetcher.calls["EAC2190000003"] != 2 {
+		t.Errorf("after the interval stats = %+v, calls = %v", stats, fetcher.calls)
+	}
+}
+
+func TestBackoffOnErrors(t *testing.T) {
+	ctx := context.Background()
+	fc := clock.NewFake(testEpoch)
+	fetcher := newFakeFetcher()
+	fetcher.failing["EAC2190000001"] = true
+
+	cfg := Config{BackoffBase: time.Minute, BackoffMax: 10 * time.Minute, Jitter: 0.1}
+	s := newTestScheduler(fc, staticSource("EAC2190000001"), fetcher, cfg)
+
+	for i, want := range []time.Duration{1, 2, 4, 8, 10, 10} {
+		stats, err := s.RunOnce(ctx)
+		if err != nil || stats.Failed != 1 {
+			t.Fatalf("pass %d: stats = %+v, err = %v", i, stats, err)
+		}
+		st := stateOf(t, s, "EAC2190000001")
+		wait := st.NextCheck.Sub(fc.Now())
+		if st.Failures != i+1 || !within(wait, want*time.Minute, 0.1) {
+			t.Errorf("pass %d: failures = %d, retry in %v, want %d and about %v", i, st.Failures, wait, i+1, want*time.Minute)
+		}
+		fc.Advance(wait)
+	}
+
+	fetcher.failing["EAC2190000001"] = false
+	if _, err := s.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	if st := stateOf(t, s, "EAC2190000001"); st.Failures != 0 || st.LastError != "" {
+		t.Errorf("state after recovery = %+v", st)
+	}
+}
+
+func TestQuotaAndRateLimit(t *testing.T) {
+	ctx := context.Background()
+	fc := clock.NewFake(testEpoch)
+	source := staticSource("EAC2190000001", "EAC2190000002", "EAC2190000003", "EAC2190000004")
+
+	fetcher := newFakeFetcher()
+	s := newTestScheduler(fc, source, fetcher, Config{MaxPerTick: 3})
+	stats, _ := s.RunOnce(ctx)
+	if stats.Checked != 3 || stats.Deferred != 1 {
+		t.Errorf("quota: stats = %+v, want 3 checked and 1 deferred", stats)
+	}
+	stats, _ = s.RunOnce(ctx)
+	if stats.Checked != 1 {
+		t.Errorf("deferred receipt was not checked on the next pass: %+v", stats)
+	}
+
+	limited := newFakeFetcher()
+	s = newTestScheduler(fc, source, limited, Config{MaxPerTick: 10},
+		WithRateLimiter(ratelimit.NewRateLimiter(2, time.Minute)))
+	stats, _ = s.RunOnce(ctx)
+	if stats.Checked != 2 || stats.Deferred != 2 || limited.total() != 2 {
+		t.Errorf("rate limit: stats = %+v, calls = %d", stats, limited.total())
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	tests := []struct {
+		window string
+		at     string
+		want   bool
+	}{
+		{"22:00-06:00", "23:30", true},
+		{"22:00-06:00", "05:59", true},
+		{"22:00-06:00", "06:00", false},
+		{"22:00-06:00", "12:00", false},
+		{"01:00-03:30", "02:00", true},
+		{"01:00-03:30", "03:30", false},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuietHours(tt.window, nil)
+		if err != nil {
+			t.Fatalf("ParseQuietHours(%q) error = %v", tt.window, err)
+		}
+		at, _ := time.Parse("15:04", tt.at)
+		ts := time.Date(2025, 3, 3, at.Hour(), at.Minute(), 0, 0, time.UTC)
+		if got := q.Contains(ts); got != tt.want {
+			t.Errorf("%s contains %s = %v, want %v", tt.window, tt.at, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"22:00", "25:00-01:00", "10:00-10:00"} {
+		if _, err := ParseQuietHours(bad, nil); err == nil {
+			t.Errorf("ParseQuietHours(%q) expected error", bad)
+		}
+	}
+
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	q, _ := ParseQuietHours("09:00-11:00", ny)
+	fc := clock.NewFake(testEpoch) // 15:00 UTC is 10:00 in New York
+	fetcher := newFakeFetcher()
+	s := newTestScheduler(fc, staticSource("EAC2190000001"), fetcher, Config{QuietHours: q})
+	stats, _ := s.RunOnce(context.Background())
+	if !stats.Quiet || stats.Deferred != 1 || fetcher.total() != 0 {
+		t.Errorf("quiet pass stats = %+v, calls = %d", stats, fetcher.total())
+	}
+}
+
+func TestRemovedTargetsAreDropped(t *testing.T) {
+	ctx := context.Background()
+	fc := clock.NewFake(testEpoch)
+	numbers := []string{"EAC2190000001", "EAC2190000002"}
+	source := SourceFunc(func(ctx context.Context) ([]Target, error) {
+		return staticSource(numbers...)(ctx)
+	})
+	s := newTestScheduler(fc, source, newFakeFetcher(), Config{})
+	s.RunOnce(ctx)
+
+	numbers = numbers[:1]
+	s.RunOnce(ctx)
+	if states := s.States(); len(states) != 1 || states[0].ReceiptNumber != "EAC2190000001" {
+		t.Errorf("States() = %+v", states)
+	}
+	if !s.CheckNow("EAC2190000001") || s.CheckNow("EAC2190000002") {
+		t.Error("CheckNow() should only succeed for known receipts")
+	}
+	if stats, _ := s.RunOnce(ctx); stats.Checked != 1 {
+		t.Errorf("CheckNow() did not make the receipt due: %+v", stats)
+	}
+}
+
+func TestWatchlistSourceSpreadsKnownReceipts(t *testing.T) {
+	ctx := context.Background()
+	fc := clock.NewFake(testEpoch)
+	wl := watchlist.NewService(nil, watchlist.WithClock(fc))
+	for _, n := range []string{"EAC2190000001", "EAC2190000002"} {
+		if _, err := wl.Add(ctx, watchlist.AddRequest{ReceiptNumber: n}); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	status, _ := types.CaseStatusFromCode(types.StatusReceived)
+	if _, err := wl.RecordStatus(ctx, &types.CaseRecord{ReceiptNumber: "EAC2190000002", Status: status, Timeline: types.NewCaseTimeline("EAC2190000002")}); err != nil {
+		t.Fatalf("RecordStatus() error = %v", err)
+	}
+
+	fetcher := newFakeFetcher()
+	s := newTestScheduler(fc, FromWatchlist(wl), fetcher, Config{InitialSpread: time.Hour},
+		WithResultFunc(func(ctx context.Context, r *types.CaseRecord) { wl.RecordStatus(ctx, r) }))
+	stats, err := s.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	if stats.Targets != 2 || stats.Checked != 1 || fetcher.calls["EAC2190000001"] != 1 {
+		t.Errorf("stats = %+v, calls = %v; only the never-checked receipt should be due", stats, fetcher.calls)
+	}
+	if next := stateOf(t, s, "EAC2190000002").NextCheck; next.Before(testEpoch) || next.After(testEpoch.Add(time.Hour)) {
+		t.Errorf("known receipt first check at %v, want within the initial spread", next)
+	}
+
+	entry, _ := wl.Get(ctx, "EAC2190000001")
+	if entry.LastStatus == nil || entry.LastStatus.Code != types.StatusActivelyReviewed {
+		t.Errorf("watchlist entry not updated: %+v", entry.LastStatus)
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	fc := clock.NewFake(testEpoch)
+	fetcher := newFakeFetcher()
+	s := newTestScheduler(fc, staticSource("EAC2190000001"), fetcher, Config{Tick: time.Minute, Interval: time.Minute, Jitter: 0.01})
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := s.Start(context.Background()); !errors.Is(err, ErrRunning) {
+		t.Errorf("second Start() error = %v, want ErrRunning", err)
+	}
+
+	deadline := time.After(5 * time.Second)
+	for fetcher.total() < 3 {
+		select {
+		case <-deadline:
+			t.Fatalf("scheduler made %d calls, want 3", fetcher.total())
+		default:
+		}
+		if fc.Waiters() > 0 {
+			fc.Advance(2 * time.Minute)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	s.Stop()
+	if s.Running() {
+		t.Error("Running() = true after Stop()")
+	}
+	calls := fetcher.total()
+	fc.Advance(time.Hour)
+	time.Sleep(10 * time.Millisecond)
+	if fetcher.total() != calls {
+		t.Error("scheduler kept polling after Stop()")
+	}
+	s.Stop() // stopping twice is a no-op
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"MyUSCISgo/pkg/changes"
+	"MyUSCISgo/pkg/deadline"
+	"MyUSCISgo/pkg/estimate"
+	"MyUSCISgo/pkg/forms"
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/types"
+	"MyUSCISgo/pkg/validation"
+	"MyUSCISgo/pkg/watchlist"
+)
+
+// CaseReport is a case's status with what is derived from it
+type CaseReport struct {
+	Case      *types.CaseRecord   `json:"case"`
+	Watched   bool                `json:"watched"`
+	Form      *FormInfo           `json:"form,omitempty"`
+	Estimate  *estimate.Estimate  `json:"estimate,omitempty"`
+	Deadlines []deadline.Deadline `json:"deadlines"`
+}
+
+// FormInfo describes the form a case was filed on, with warnings when the
+// case is inconsistent with the form catalog
+type FormInfo struct {
+	ID           string                 `json:"id"`
+	Title        string                 `json:"title"`
+	Category     forms.Category         `json:"category"`
+	NextStatuses []types.CaseStatusCode `json:"nextStatuses"`
+	Warnings     []string               `json:"warnings,omitempty"`
+}
+
+// Environment validates an environment name, defaulting to development
+func Environment(env string) (string, error) {
+	if env == "" {
+		return string(types.EnvDevelopment), nil
+	}
+	if err := validation.ValidateEnvironment(env); err != nil {
+		return "", err
+	}
+	return string(types.ToEnvironment(env)), nil
+}
+
+// CaseStatus looks up the current status of a case. The error is a
+// *receipt.ParseError or validation.ValidationError for bad input, a
+// *RateLimitError, ErrTimeout, or wraps ErrLookupFailed.
+func (s *Service) CaseStatus(ctx context.Context, receiptNumber, env string) (*CaseReport, error) {
+	caseReceipt, err := receipt.Parse(receiptNumber)
+	if err != nil {
+		return nil, err
+	}
+	env, err = Environment(env)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.allow("status:" + caseReceipt.Number); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	return s.lookupCase(ctx, caseReceipt, env)
+}
+
+// lookupCase fetches a case through the processor, records the status on
+// the watchlist if the case is watched and adds the form, estimate and
+// deadlines
+func (s *Service) lookupCase(ctx context.Context, caseReceipt receipt.Receipt, env string) (*CaseReport, error) {
+	record, err := s.processor.FetchCaseStatus(ctx, env, caseReceipt.Number, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			s.logger.Error("Case status timeout", ctx.Err(), map[string]interface{}{
+				"caseNumber": caseReceipt.Number,
+			})
+			return nil, contextError(ctx)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrLookupFailed, err)
+	}
+
+	watched := s.RecordStatus(ctx, record)
+	if record.ServiceCenter == "" {
+		// the office a watched case was last transferred to, or else the
+		// one that issued the receipt
+		record.ServiceCenter = caseReceipt.Center.Name
+		if entry, err := s.watchlist.Get(ctx, caseReceipt.Number); err == nil && entry.ServiceCenter != "" {
+			record.ServiceCenter = entry.ServiceCenter
+		}
+	}
+	report := &CaseReport{
+		Case:      record,
+		Watched:   watched,
+		Deadlines: s.deadlines.ForTimeline(record.Timeline),
+	}
+	if report.Deadlines == nil {
+		report.Deadlines = []deadline.Deadline{}
+	}
+	if form, ok := forms.Lookup(record.FormType); ok {
+		report.Form = s.describeForm(form, caseReceipt, record.Status)
+		if !record.Decided() {
+			report.Estimate = s.Estimate(caseReceipt, form.ID, record.Timeline)
+		}
+	}
+	return report, nil
+}
+
+// RecordStatus stores a fetched status on the watchlist entry for the
+// case and publishes what changed since the previous check. It reports
+// whether the case is watched.
+func (s *Service) RecordStatus(ctx context.Context, record *types.CaseRecord) bool {
+	previous, err := s.watchlist.Get(ctx, record.ReceiptNumber)
+	if errors.Is(err, watchlist.ErrNotFound) {
+		return false
+	}
+	if _, err := s.watchlist.RecordStatus(ctx, record); err != nil {
+		if !errors.Is(err, watchlist.ErrNotFound) {
+			s.logger.Warn("Failed to update watchlist entry", map[string]interface{}{
+				"caseNumber": record.ReceiptNumber,
+				"error":      err.Error(),
+			})
+		}
+		return false
+	}
+	if s.changes != nil {
+		s.changes.Publish(changes.Diff(previous.Record(), record, s.clock.Now())...)
+	}
+	return true
+}
+
+// describeForm checks that the receipt center and current status are
+// consistent with a case's form
+func (s *Service) describeForm(form *forms.Form, caseReceipt receipt.Receipt, status types.CaseStatus) *FormInfo {
+	info := &FormInfo{
+		ID:           form.ID,
+		Title:        form.Title,
+		Category:     form.Category,
+		NextStatuses: form.NextStatuses(status.Code),
+	}
+	if !form.AllowsCenter(caseReceipt.Center) {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("%s is not normally receipted at %s", form.ID, caseReceipt.Center.Name))
+	}
+	if !form.HasStatus(status.Code) {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("status %s is not part of the %s workflow", status.Code, form.ID))
+	}
+	if len(info.Warnings) > 0 {
+		s.logger.Warn("Case details inconsistent with form catalog", map[string]interface{}{
+			"caseNumber": caseReceipt.Number,
+			"warnings":   info.Warnings,
+		})
+	}
+	return info
+}
+
+// Estimate estimates when a case on form will be decided, using the
+// received event from the timeline when the receipt number does not encode
+// a date. It returns nil when there is no processing-times data for the
+// case.
+func (s *Service) Estimate(caseReceipt receipt.Receipt, form string, timeline *types.CaseTimeline) *estimate.Estimate {
+	req := estimate.Request{Receipt: caseReceipt, Form: form}
+	if timeline != nil {
+		if received := timeline.EventsWithStatus(types.StatusReceived); len(received) > 0 {
+			req.ReceivedAt = received[0].Date
+		}
+	}
+
+	est, err := s.estimator.Load().Estimate(req)
+	if err != nil {
+		s.logger.Debug("Processing time estimate unavailable", map[string]interface{}{
+			"caseNumber": caseReceipt.Number,
+			"form":       form,
+			"error":      err.Error(),
+		})
+		return nil
+	}
+	return est
+}
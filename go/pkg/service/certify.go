@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"MyUSCISgo/pkg/forms"
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/security"
+	"MyUSCISgo/pkg/types"
+	"MyUSCISgo/pkg/validation"
+)
+
+// CertifyRequest asks for a token to be checked against a case
+type CertifyRequest struct {
+	Token       string `json:"token"`
+	CaseNumber  string `json:"caseNumber"`
+	Environment string `json:"environment"`
+}
+
+// Certification is the result of a successful token certification
+type Certification struct {
+	IsValid        bool                 `json:"isValid"`
+	VerificationID string               `json:"verificationId"`
+	CaseStatus     string               `json:"caseStatus"`
+	CaseStatusCode types.CaseStatusCode `json:"caseStatusCode"`
+	CaseStage      types.CaseStage      `json:"caseStage"`
+	ActionRequired bool                 `json:"actionRequired"`
+	LastUpdated    string               `json:"lastUpdated"`
+	CaseDetails    map[string]string    `json:"caseDetails"`
+	Timeline       *types.CaseTimeline  `json:"timeline"`
+	CaseReport
+}
+
+// Certify checks a certification token against a case and reports the
+// case's current status. The error is a validation.ValidationError or
+// *receipt.ParseError for bad input, ErrNotConfigured, a *RateLimitError,
+// ErrInvalidToken, ErrTimeout, or wraps ErrLookupFailed.
+func (s *Service) Certify(ctx context.Context, req CertifyRequest) (*Certification, error) {
+	if req.Token == "" {
+		return nil, validation.ValidationError{Field: "token", Message: "token is required"}
+	}
+	if req.CaseNumber == "" {
+		return nil, validation.ValidationError{Field: "caseNumber", Message: "case number is required"}
+	}
+	caseReceipt, err := receipt.Parse(req.CaseNumber)
+	if err != nil {
+		return nil, err
+	}
+	env, err := Environment(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+	if s.TokenConfig().SigningKey == "" {
+		return nil, ErrNotConfigured
+	}
+	if err := s.allow("certify:" + caseReceipt.Number); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	claims, err := s.VerifyToken(ctx, req.Token, caseReceipt.Number)
+	if err != nil {
+		s.logger.Info("Token validation failed", map[string]interface{}{
+			"caseNumber": caseReceipt.Number,
+			"error":      err.Error(),
+		})
+		var limited *RateLimitError
+		if errors.As(err, &limited) {
+			return nil, err
+		}
+		return nil, ErrInvalidToken
+	}
+
+	report, err := s.lookupCase(ctx, caseReceipt, env)
+	if err != nil {
+		return nil, err
+	}
+
+	verificationID, err := security.GenerateSecureToken(caseReceipt.Number)
+	if err != nil {
+		s.logger.Error("Failed to generate verification ID", err)
+		verificationID = fmt.Sprintf("CERT-%d", s.clock.Now().Unix())
+	}
+	s.logger.Info("Token certification completed successfully", map[string]interface{}{
+		"caseNumber": caseReceipt.Number,
+		"tokenID":    claims.ID(),
+	})
+	s.emit(EventTokenCertified, map[string]interface{}{
+		"caseNumber":     caseReceipt.Number,
+		"verificationId": verificationID,
+		"timestamp":      s.clock.Now().UTC().Format(time.RFC3339),
+	})
+
+	record := report.Case
+	return &Certification{
+		IsValid:        true,
+		VerificationID: verificationID,
+		CaseStatus:     record.Status.Title,
+		CaseStatusCode: record.Status.Code,
+		CaseStage:      record.Status.Stage,
+		ActionRequired: record.Status.ActionRequired,
+		LastUpdated:    record.UpdatedAt.UTC().Format(time.RFC3339),
+		CaseDetails:    CaseDetails(record),
+		Timeline:       record.Timeline,
+		CaseReport:     *report,
+	}, nil
+}
+
+// CaseDetails renders a case record as the labelled details shown with a
+// certification
+func CaseDetails(record *types.CaseRecord) map[string]string {
+	details := map[string]string{
+		"Case Type":         record.FormType,
+		"Processing Center": record.ServiceCenter,
+		"Current Status":    record.Status.Title,
+	}
+	if !record.SubmittedAt.IsZero() {
+		details["Priority Date"] = record.SubmittedAt.Format("2006-01-02")
+	}
+	if form, ok := forms.Lookup(record.FormType); ok {
+		details["Case Type"] = form.Name()
+		details["Form Category"] = string(form.Category)
+	}
+	if record.Timeline != nil {
+		if approved := record.Timeline.EventsWithStatus(types.StatusApproved); len(approved) > 0 {
+			details["Approval Notice Date"] = approved[len(approved)-1].Date.Format("2006-01-02")
+		}
+	}
+	return details
+}
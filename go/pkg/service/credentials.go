@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"MyUSCISgo/pkg/jobs"
+	"MyUSCISgo/pkg/logging"
+	"MyUSCISgo/pkg/types"
+	"MyUSCISgo/pkg/validation"
+)
+
+// Realtime event types
+const (
+	EventValidationStarted   = "validation_started"
+	EventJobCreated          = "job_created"
+	EventJobProgress         = "job_progress"
+	EventProcessingCompleted = "processing_completed"
+	EventProcessingTimeout   = "processing_timeout"
+	EventProcessingFailed    = "processing_failed"
+	EventTokenCertified      = "token_certified"
+	EventCaseRefreshed       = "case_refreshed"
+)
+
+// Event is a realtime update about work in progress
+type Event struct {
+	Type string                 `json:"type"`
+	Data map[string]interface{} `json:"data"`
+	Time time.Time              `json:"timestamp"`
+}
+
+// emit sends an event if an event function is set
+func (s *Service) emit(eventType string, data map[string]interface{}) {
+	if s.events != nil {
+		s.events(Event{Type: eventType, Data: data, Time: s.clock.Now().UTC()})
+	}
+}
+
+// ProcessResult is the outcome of credential processing
+type ProcessResult struct {
+	JobID  string                  `json:"jobId"`
+	Result *types.ProcessingResult `json:"result"`
+}
+
+// ValidateCredentials checks the format of credentials. Its error is a
+// validation.ValidationError.
+func (s *Service) ValidateCredentials(creds *types.Credentials) error {
+	if err := validation.ValidateCredentials(creds); err != nil {
+		s.logger.Info("Credential validation failed", logging.SanitizeLogData(map[string]interface{}{
+			"clientId":    creds.ClientID,
+			"environment": creds.Environment,
+			"error":       err.Error(),
+		}))
+		return validation.ValidationError{Message: err.Error()}
+	}
+	return nil
+}
+
+// SubmitCredentials validates and rate limits credentials and starts
+// processing them as a tracked job. The job stops when ctx is done.
+func (s *Service) SubmitCredentials(ctx context.Context, creds *types.Credentials) (*jobs.Job, error) {
+	if err := s.ValidateCredentials(creds); err != nil {
+		return nil, err
+	}
+	if err := s.allow(fmt.Sprintf("%s:%s", creds.Environment, creds.ClientID)); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Credentials validated successfully", map[string]interface{}{
+		"clientId":    creds.ClientID,
+		"environment": creds.Environment,
+	})
+	s.emit(EventValidationStarted, map[string]interface{}{
+		"clientId":    creds.ClientID,
+		"environment": creds.Environment,
+	})
+
+	// Purge finished jobs so the registry does not grow without bound
+	s.processor.Jobs().Purge(JobRetention)
+	job := s.processor.SubmitCredentials(ctx, creds)
+	s.emit(EventJobCreated, map[string]interface{}{
+		"jobId":       job.ID(),
+		"clientId":    creds.ClientID,
+		"environment": creds.Environment,
+	})
+	if s.events != nil {
+		go s.forwardJobProgress(job)
+	}
+	return job, nil
+}
+
+// ProcessCredentials submits credentials and waits for the result
+func (s *Service) ProcessCredentials(ctx context.Context, creds *types.Credentials) (*ProcessResult, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	job, err := s.SubmitCredentials(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{
+		"jobId":       job.ID(),
+		"clientId":    creds.ClientID,
+		"environment": creds.Environment,
+	}
+
+	result, err := job.Wait(ctx)
+	switch {
+	case err == nil:
+		s.emit(EventProcessingCompleted, withField(fields, "success", true))
+		s.logger.Info("Processing completed successfully", fields)
+		return &ProcessResult{JobID: job.ID(), Result: result.(*types.ProcessingResult)}, nil
+	case ctx.Err() != nil:
+		job.Cancel()
+		err := contextError(ctx)
+		s.emit(EventProcessingTimeout, withField(fields, "error", err.Error()))
+		s.logger.Error("Processing did not finish", err, fields)
+		return nil, err
+	default:
+		s.emit(EventProcessingFailed, withField(fields, "error", err.Error()))
+		s.logger.Error("Processing failed", err, fields)
+		return nil, fmt.Errorf("%w: %w", ErrProcessingFailed, err)
+	}
+}
+
+// forwardJobProgress relays job progress reports as events
+func (s *Service) forwardJobProgress(job *jobs.Job) {
+	updates, stop := job.Subscribe()
+	defer stop()
+
+	for progress := range updates {
+		s.emit(EventJobProgress, map[string]interface{}{
+			"jobId":   progress.JobID,
+			"state":   progress.State.String(),
+			"stage":   progress.Stage,
+			"percent": progress.Percent,
+			"message": progress.Message,
+		})
+	}
+}
+
+// withField returns a copy of fields with one more field
+func withField(fields map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(fields)+1)
+	for k, v := range fields {
+		out[k] = v
+	}
+	out[key] = value
+	return out
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+
+	"MyUSCISgo/pkg/scheduler"
+	"MyUSCISgo/pkg/types"
+)
+
+// NewPoller creates a scheduler that refreshes the watched cases in env.
+// Each refreshed status is recorded on the watchlist, which publishes what
+// changed, and sent as an EventCaseRefreshed event. The error is a
+// validation.ValidationError for an unknown environment.
+func (s *Service) NewPoller(env string, cfg scheduler.Config) (*scheduler.Scheduler, error) {
+	env, err := Environment(env)
+	if err != nil {
+		return nil, err
+	}
+	fetcher := scheduler.FetcherFunc(func(ctx context.Context, receiptNumber string) (*types.CaseRecord, error) {
+		ctx, cancel := s.withTimeout(ctx)
+		defer cancel()
+		return s.processor.FetchCaseStatus(ctx, env, receiptNumber, nil)
+	})
+	return scheduler.New(scheduler.FromWatchlist(s.watchlist), fetcher,
+		scheduler.WithConfig(cfg),
+		scheduler.WithRateLimiter(s.limiter),
+		scheduler.WithClock(s.clock),
+		scheduler.WithLogger(s.logger),
+		scheduler.WithResultFunc(func(ctx context.Context, record *types.CaseRecord) {
+			s.RecordStatus(ctx, record)
+			s.emit(EventCaseRefreshed, map[string]interface{}{
+				"caseNumber":  record.ReceiptNumber,
+				"status":      string(record.Status.Code),
+				"title":       record.Status.Title,
+				"environment": env,
+			})
+		}),
+	), nil
+}
+
+// StartPolling starts refreshing the watched cases in env in the
+// background, replacing the poller started before. Polling stops when
+// StopPolling is called or ctx is done.
+func (s *Service) StartPolling(ctx context.Context, env string, cfg scheduler.Config) error {
+	poller, err := s.NewPoller(env, cfg)
+	if err != nil {
+		return err
+	}
+
+	s.pollerMu.Lock()
+	defer s.pollerMu.Unlock()
+	if s.poller != nil {
+		s.poller.Stop()
+		s.poller = nil
+	}
+	if err := poller.Start(ctx); err != nil {
+		return err
+	}
+	s.poller = poller
+	return nil
+}
+
+// StopPolling stops the poller started by StartPolling and waits for a
+// pass in flight to finish. It is safe to call when nothing is polling.
+func (s *Service) StopPolling() {
+	s.pollerMu.Lock()
+	defer s.pollerMu.Unlock()
+	if s.poller != nil {
+		s.poller.Stop()
+		s.poller = nil
+	}
+}
+
+// Polling reports whether the poller started by StartPolling is running
+func (s *Service) Polling() bool {
+	s.pollerMu.Lock()
+	defer s.pollerMu.Unlock()
+	return s.poller != nil && s.poller.Running()
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"MyUSCISgo/pkg/jobs"
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/scan"
+	"MyUSCISgo/pkg/validation"
+)
+
+// SubmitScan validates a neighbourhood scan around receiptNumber and
+// starts it as a tracked job. Lookups are paced by the scan rate limiter;
+// opts can add a cache to resume earlier scans. The job stops when ctx is
+// done or after ScanTimeout, and its result is a *scan.Report.
+func (s *Service) SubmitScan(ctx context.Context, receiptNumber, env string, radius int, opts ...scan.Option) (*jobs.Job, error) {
+	origin, err := receipt.Parse(receiptNumber)
+	if err != nil {
+		return nil, err
+	}
+	env, err = Environment(env)
+	if err != nil {
+		return nil, err
+	}
+	if radius < 1 || radius > scan.MaxRadius {
+		return nil, validation.ValidationError{
+			Field:   "radius",
+			Message: fmt.Sprintf("radius must be between 1 and %d", scan.MaxRadius),
+		}
+	}
+
+	s.processor.Jobs().Purge(JobRetention)
+	opts = append([]scan.Option{scan.WithRateLimiter(s.scanLimiter)}, opts...)
+	ctx, cancel := context.WithTimeout(ctx, ScanTimeout)
+	job := s.processor.SubmitScan(ctx, env, origin, radius, opts...)
+	go func() {
+		<-job.Done()
+		cancel()
+	}()
+	s.logger.Info("Receipt scan started", map[string]interface{}{
+		"jobId":      job.ID(),
+		"caseNumber": origin.Number,
+		"radius":     radius,
+	})
+	if s.events != nil {
+		go s.forwardJobProgress(job)
+	}
+	return job, nil
+}
@@ -0,0 +1,361 @@
+// Package service holds the flows behind every transport: validating and
+// rate limiting requests, processing credentials, certifying tokens,
+// revoking them, looking up cases, polling watched cases and reporting
+// health. The WebAssembly handler, its native counterpart, the REST server
+// and uscisctl are adapters that decode requests, call a Service and encode
+// its typed results and errors.
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"MyUSCISgo/pkg/changes"
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/deadline"
+	"MyUSCISgo/pkg/estimate"
+	"MyUSCISgo/pkg/logging"
+	"MyUSCISgo/pkg/processing"
+	"MyUSCISgo/pkg/ratelimit"
+	"MyUSCISgo/pkg/scheduler"
+	"MyUSCISgo/pkg/store"
+	"MyUSCISgo/pkg/watchlist"
+)
+
+const (
+	// Version is reported by the health check
+	Version = "1.0.0"
+	// DefaultTimeout bounds processing, certification and case lookups
+	DefaultTimeout = 30 * time.Second
+	// DefaultRateLimit is the number of requests allowed per client or case
+	// within DefaultRateWindow
+	DefaultRateLimit = 10
+	// DefaultRateWindow is the rate limit window
+	DefaultRateWindow = time.Minute
+	// TokenValidationRateLimit caps token validation attempts per minute
+	TokenValidationRateLimit = 100
+	// JobRetention is how long finished jobs remain queryable
+	JobRetention = 10 * time.Minute
+	// ScanRateLimit is the number of neighbourhood scan lookups allowed per
+	// minute. Scans have their own budget so they neither starve nor wait on
+	// the per-case limit.
+	ScanRateLimit = 60
+	// ScanTimeout bounds a neighbourhood scan. It leaves room for the
+	// largest scan, 2*scan.MaxRadius lookups at ScanRateLimit.
+	ScanTimeout = 15 * time.Minute
+)
+
+var (
+	// ErrRateLimited is matched by errors for requests over the rate limit
+	ErrRateLimited = errors.New("rate limit exceeded, please try again later")
+	// ErrTimeout is returned when a request does not finish in time
+	ErrTimeout = errors.New("processing timeout")
+	// ErrProcessingFailed wraps errors from credential processing
+	ErrProcessingFailed = errors.New("processing failed")
+	// ErrLookupFailed wraps errors from case status lookups
+	ErrLookupFailed = errors.New("case status lookup failed")
+	// ErrInvalidToken is returned for a certification token that is not
+	// valid for the case
+	ErrInvalidToken = errors.New("invalid token for case number")
+	// ErrNotConfigured is returned by certification without a signing key
+	ErrNotConfigured = errors.New("token certification is not configured")
+)
+
+// RateLimitError reports a request over the rate limit
+type RateLimitError struct {
+	Key        string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return ErrRateLimited.Error()
+}
+
+// Is makes RateLimitError match ErrRateLimited
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// Service runs the shared flows. It is safe for concurrent use.
+type Service struct {
+	processor         *processing.Processor
+	logger            *logging.Logger
+	clock             clock.Clock
+	limiter           *ratelimit.RateLimiter
+	window            time.Duration
+	validationLimiter *ratelimit.RateLimiter
+	scanLimiter       *ratelimit.RateLimiter
+	records           *store.Store
+	watchlist         *watchlist.Service
+	changes           *changes.Bus
+	deadlines         *deadline.Calculator
+	estimator         atomic.Pointer[estimate.Estimator]
+	tokens            atomic.Pointer[TokenConfig]
+	timeout           time.Duration
+	events            func(Event)
+
+	pollerMu sync.Mutex
+	poller   *scheduler.Scheduler
+}
+
+// Option configures a Service
+type Option func(*Service)
+
+// WithProcessor sets the processor credentials and cases are handled by
+func WithProcessor(p *processing.Processor) Option {
+	return func(s *Service) {
+		s.processor = p
+	}
+}
+
+// WithLogger sets the logger
+func WithLogger(logger *logging.Logger) Option {
+	return func(s *Service) {
+		s.logger = logger
+	}
+}
+
+// WithClock sets the clock used for token validation and timestamps
+func WithClock(c clock.Clock) Option {
+	return func(s *Service) {
+		s.clock = c
+	}
+}
+
+// WithRateLimiter sets the limiter applied per client and case. The window
+// is reported to callers that exceed it.
+func WithRateLimiter(rl *ratelimit.RateLimiter, window time.Duration) Option {
+	return func(s *Service) {
+		s.limiter, s.window = rl, window
+	}
+}
+
+// WithScanRateLimiter sets the limiter neighbourhood scan lookups are paced
+// by
+func WithScanRateLimiter(rl *ratelimit.RateLimiter) Option {
+	return func(s *Service) {
+		s.scanLimiter = rl
+	}
+}
+
+// WithStore keeps watched cases and token revocations in a store and
+// includes it in the health check. Without one they are kept in memory.
+func WithStore(records *store.Store) Option {
+	return func(s *Service) {
+		s.records = records
+	}
+}
+
+// WithWatchlist sets the watchlist looked-up statuses are recorded on.
+// Without one a watchlist over the store is used.
+func WithWatchlist(w *watchlist.Service) Option {
+	return func(s *Service) {
+		s.watchlist = w
+	}
+}
+
+// WithChanges publishes what changed on a watched case when a new status
+// is recorded
+func WithChanges(bus *changes.Bus) Option {
+	return func(s *Service) {
+		s.changes = bus
+	}
+}
+
+// WithDeadlines sets the calculator for response deadlines
+func WithDeadlines(d *deadline.Calculator) Option {
+	return func(s *Service) {
+		s.deadlines = d
+	}
+}
+
+// WithEstimator sets the processing-times estimator
+func WithEstimator(e *estimate.Estimator) Option {
+	return func(s *Service) {
+		s.estimator.Store(e)
+	}
+}
+
+// WithTokenConfig sets what certification tokens are checked against
+func WithTokenConfig(cfg TokenConfig) Option {
+	return func(s *Service) {
+		s.tokens.Store(&cfg)
+	}
+}
+
+// WithSigningKey sets the key certification tokens are signed with
+func WithSigningKey(key string) Option {
+	return func(s *Service) {
+		cfg := *s.tokens.Load()
+		cfg.SigningKey = key
+		s.tokens.Store(&cfg)
+	}
+}
+
+// WithTimeout bounds processing, certification and case lookups
+func WithTimeout(d time.Duration) Option {
+	return func(s *Service) {
+		if d > 0 {
+			s.timeout = d
+		}
+	}
+}
+
+// WithEvents sets the function realtime events are sent to. It is called
+// from the goroutine doing the work and must not block.
+func WithEvents(fn func(Event)) Option {
+	return func(s *Service) {
+		s.events = fn
+	}
+}
+
+// New creates a service
+func New(opts ...Option) *Service {
+	s := &Service{
+		logger:            logging.NewLogger(logging.LogLevelInfo),
+		clock:             clock.Real(),
+		validationLimiter: ratelimit.NewRateLimiter(TokenValidationRateLimit, time.Minute),
+		scanLimiter:       ratelimit.NewRateLimiter(ScanRateLimit, time.Minute),
+		timeout:           DefaultTimeout,
+	}
+	tokens := DefaultTokenConfig()
+	s.tokens.Store(&tokens)
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.processor == nil {
+		s.processor = processing.NewProcessor(processing.WithLogger(s.logger), processing.WithClock(s.clock))
+	}
+	if s.limiter == nil {
+		s.limiter, s.window = ratelimit.NewRateLimiter(DefaultRateLimit, DefaultRateWindow), DefaultRateWindow
+	}
+	if s.records == nil {
+		records, err := store.Open(context.Background(), store.NewMemory(), store.WithClock(s.clock))
+		if err != nil {
+			// a new memory store cannot fail to migrate
+			panic(err)
+		}
+		s.records = records
+	}
+	if s.watchlist == nil {
+		s.watchlist = watchlist.NewService(s.records.Watchlist(), watchlist.WithClock(s.clock))
+	}
+	if s.deadlines == nil {
+		s.deadlines = deadline.New(deadline.WithClock(s.clock))
+	}
+	if s.estimator.Load() == nil {
+		s.estimator.Store(estimate.NewEstimator(nil, s.clock))
+	}
+	return s
+}
+
+// Processor returns the processor the service runs on
+func (s *Service) Processor() *processing.Processor {
+	return s.processor
+}
+
+// Clock returns the clock the service reads the time from, so adapters
+// stamp what they produce with the same time
+func (s *Service) Clock() clock.Clock {
+	return s.clock
+}
+
+// RateLimiter returns the limiter applied per client and case, so other
+// work on behalf of the same callers can share it
+func (s *Service) RateLimiter() *ratelimit.RateLimiter {
+	return s.limiter
+}
+
+// Store returns the store tokens and watched cases are kept in
+func (s *Service) Store() *store.Store {
+	return s.records
+}
+
+// Watchlist returns the watchlist looked-up statuses are recorded on
+func (s *Service) Watchlist() *watchlist.Service {
+	return s.watchlist
+}
+
+// Deadlines returns the calculator for response deadlines
+func (s *Service) Deadlines() *deadline.Calculator {
+	return s.deadlines
+}
+
+// Estimator returns the current processing-times estimator
+func (s *Service) Estimator() *estimate.Estimator {
+	return s.estimator.Load()
+}
+
+// SetEstimator replaces the processing-times estimator
+func (s *Service) SetEstimator(e *estimate.Estimator) {
+	s.estimator.Store(e)
+}
+
+// allow applies the rate limit to key
+func (s *Service) allow(key string) error {
+	if s.limiter.Allow(key) {
+		return nil
+	}
+	s.logger.Warn("Rate limit exceeded", map[string]interface{}{
+		"rateLimitKey": key,
+	})
+	return &RateLimitError{Key: key, RetryAfter: s.window}
+}
+
+// withTimeout bounds ctx by the service timeout
+func (s *Service) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, s.timeout)
+}
+
+// contextError maps a context error to ErrTimeout when the deadline passed
+func contextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return ctx.Err()
+}
+
+// Health reports whether the service can reach what it depends on
+type Health struct {
+	Status    string            `json:"status"`
+	Timestamp string            `json:"timestamp"`
+	Version   string            `json:"version"`
+	Features  []string          `json:"features"`
+	Checks    map[string]string `json:"checks"`
+}
+
+// Healthy reports whether every check passed
+func (h *Health) Healthy() bool {
+	return h.Status == "healthy"
+}
+
+// Health checks the store and certification configuration
+func (s *Service) Health(ctx context.Context) *Health {
+	s.logger.Debug("Health check requested")
+	h := &Health{
+		Status:    "healthy",
+		Timestamp: s.clock.Now().UTC().Format(time.RFC3339),
+		Version:   Version,
+		Features: []string{
+			"async-processing",
+			"security-validation",
+			"structured-logging",
+			"environment-specific-logic",
+			"case-watchlist",
+		},
+		Checks: map[string]string{"store": "ok", "certification": "ok"},
+	}
+	if s.TokenConfig().SigningKey == "" {
+		h.Checks["certification"] = "not configured"
+	}
+	if err := s.records.Backend().View(ctx, func(store.Tx) error { return nil }); err != nil {
+		s.logger.Error("Health check failed to reach the store", err)
+		h.Status = "unhealthy"
+		h.Checks["store"] = "unavailable"
+	}
+	return h
+}
@@ -0,0 +1,468 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"MyUSCISgo/pkg/casegen"
+	"MyUSCISgo/pkg/changes"
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/logging"
+	"MyUSCISgo/pkg/processing"
+	"MyUSCISgo/pkg/ratelimit"
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/scan"
+	"MyUSCISgo/pkg/scheduler"
+	"MyUSCISgo/pkg/store"
+	"MyUSCISgo/pkg/types"
+	"MyUSCISgo/pkg/validation"
+	"MyUSCISgo/pkg/watchlist"
+)
+
+const (
+	testKey     = "test-signing-key"
+	testReceipt = "EAC2190050123"
+)
+
+// eventLog collects emitted events
+type eventLog struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (l *eventLog) add(e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, e)
+}
+
+func (l *eventLog) types() map[string]bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	seen := make(map[string]bool)
+	for _, e := range l.events {
+		seen[e.Type] = true
+	}
+	return seen
+}
+
+func newTestService(opts ...Option) *Service {
+	base := []Option{WithLogger(logging.NewLogger(logging.LogLevelFatal)), WithSigningKey(testKey)}
+	return New(append(base, opts...)...)
+}
+
+func signToken(t *testing.T, key string, claims Claims) string {
+	t.Helper()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func validClaims(caseNumber string) Claims {
+	now := time.Now()
+	return Claims{
+		Issuer:     TokenIssuer,
+		Audience:   TokenAudience,
+		Subject:    "applicant-1",
+		IssuedAt:   now.Add(-time.Minute).Unix(),
+		ExpiresAt:  now.Add(time.Hour).Unix(),
+		CaseNumber: caseNumber,
+	}
+}
+
+func TestProcessCredentials(t *testing.T) {
+	var log eventLog
+	s := newTestService(WithRateLimiter(ratelimit.NewRateLimiter(1, time.Minute), time.Minute), WithEvents(log.add))
+	creds := &types.Credentials{ClientID: "test-client-123", ClientSecret: "Str0ngRandomValue!", Environment: "development"}
+
+	result, err := s.ProcessCredentials(context.Background(), creds)
+	if err != nil {
+		t.Fatalf("ProcessCredentials() error = %v", err)
+	}
+	if result.JobID == "" || result.Result == nil {
+		t.Errorf("ProcessCredentials() = %+v", result)
+	}
+	for _, want := range []string{EventValidationStarted, EventJobCreated, EventProcessingCompleted} {
+		if !log.types()[want] {
+			t.Errorf("no %s event", want)
+		}
+	}
+
+	var limited *RateLimitError
+	if _, err := s.ProcessCredentials(context.Background(), creds); !errors.As(err, &limited) || !errors.Is(err, ErrRateLimited) || limited.RetryAfter != time.Minute {
+		t.Errorf("second request error = %v, want a rate limit error", err)
+	}
+
+	var invalid validation.ValidationError
+	if _, err := s.ProcessCredentials(context.Background(), &types.Credentials{Environment: "moon"}); !errors.As(err, &invalid) {
+		t.Errorf("invalid credentials error = %v, want a validation error", err)
+	}
+}
+
+func TestProductionEnvironment(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+
+	creds := &types.Credentials{ClientID: "test-client-123", ClientSecret: "Str0ngRandomValue!", Environment: "production"}
+	result, err := s.ProcessCredentials(ctx, creds)
+	if err != nil {
+		t.Fatalf("production ProcessCredentials() error = %v", err)
+	}
+	if result.Result == nil || result.Result.Config["apiStatus"] != "live_success" {
+		t.Errorf("production ProcessCredentials() = %+v", result.Result)
+	}
+
+	// generated cases are never returned as production data
+	if _, err := s.CaseStatus(ctx, testReceipt, "production"); !errors.Is(err, ErrLookupFailed) || !errors.Is(err, processing.ErrNoUpstream) {
+		t.Errorf("production CaseStatus() error = %v, want %v", err, processing.ErrNoUpstream)
+	}
+}
+
+func TestCertify(t *testing.T) {
+	var log eventLog
+	s := newTestService(WithEvents(log.add))
+	certify := func(token, caseNumber string) (*Certification, error) {
+		return s.Certify(context.Background(), CertifyRequest{Token: token, CaseNumber: caseNumber})
+	}
+
+	cert, err := certify(signToken(t, testKey, validClaims(testReceipt)), "eac-2190050123")
+	if err != nil {
+		t.Fatalf("Certify() error = %v", err)
+	}
+	if !cert.IsValid || cert.VerificationID == "" || cert.Case.ReceiptNumber != testReceipt || cert.CaseDetails["Current Status"] != cert.CaseStatus {
+		t.Errorf("Certify() = %+v", cert)
+	}
+	if !log.types()[EventTokenCertified] {
+		t.Errorf("no %s event", EventTokenCertified)
+	}
+
+	expired := validClaims("LIN2190050123")
+	expired.ExpiresAt = time.Now().Add(-time.Hour).Unix()
+	revoked := validClaims("NBC2190050123")
+	if err := s.RevokeToken(context.Background(), revoked.ID()); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		fieldErr validation.ValidationError
+		parseErr *receipt.ParseError
+	)
+	tests := []struct {
+		name       string
+		token      string
+		caseNumber string
+		match      func(error) bool
+	}{
+		{"missing token", "", testReceipt, func(err error) bool { return errors.As(err, &fieldErr) && fieldErr.Field == "token" }},
+		{"invalid receipt", "a.b.c", "XYZ123", func(err error) bool { return errors.As(err, &parseErr) }},
+		{"wrong key", signToken(t, "other-key", validClaims("WAC2190050123")), "WAC2190050123", isInvalidToken},
+		{"other case", signToken(t, testKey, validClaims("SRC2190050123")), "MSC2190050123", isInvalidToken},
+		{"expired", signToken(t, testKey, expired), "LIN2190050123", isInvalidToken},
+		{"revoked", signToken(t, testKey, revoked), revoked.CaseNumber, isInvalidToken},
+		{"malformed", "not.a.jwt.at.all", "IOE0912345678", isInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := certify(tt.token, tt.caseNumber); !tt.match(err) {
+				t.Errorf("Certify() error = %v", err)
+			}
+		})
+	}
+
+	unconfigured := New(WithLogger(logging.NewLogger(logging.LogLevelFatal)))
+	if _, err := unconfigured.Certify(context.Background(), CertifyRequest{Token: "a.b.c", CaseNumber: testReceipt}); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("Certify() without a key error = %v, want %v", err, ErrNotConfigured)
+	}
+}
+
+func isInvalidToken(err error) bool {
+	return errors.Is(err, ErrInvalidToken)
+}
+
+func TestIssueToken(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+
+	token, claims, err := s.IssueToken(ctx, "applicant-1", "eac2190050123", time.Hour)
+	if err != nil {
+		t.Fatalf("IssueToken() error = %v", err)
+	}
+	if claims.CaseNumber != testReceipt || claims.Issuer != TokenIssuer || claims.Audience != TokenAudience {
+		t.Errorf("IssueToken() claims = %+v", claims)
+	}
+	if _, err := s.VerifyToken(ctx, token, testReceipt); err != nil {
+		t.Errorf("VerifyToken() of an issued token error = %v", err)
+	}
+	parsed, err := s.ParseToken(token)
+	if err != nil || parsed.ID() != claims.ID() {
+		t.Fatalf("ParseToken() = %+v, %v", parsed, err)
+	}
+	if err := s.RevokeToken(ctx, parsed.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.VerifyToken(ctx, token, testReceipt); err == nil {
+		t.Error("VerifyToken() accepted a revoked token")
+	}
+	if _, err := s.ParseToken(signToken(t, "other-key", validClaims(testReceipt))); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseToken() with the wrong key error = %v, want %v", err, ErrInvalidToken)
+	}
+
+	var (
+		fieldErr validation.ValidationError
+		parseErr *receipt.ParseError
+	)
+	if _, _, err := s.IssueToken(ctx, " ", testReceipt, time.Hour); !errors.As(err, &fieldErr) || fieldErr.Field != "subject" {
+		t.Errorf("IssueToken() without a subject error = %v", err)
+	}
+	if _, _, err := s.IssueToken(ctx, "applicant-1", testReceipt, 0); !errors.As(err, &fieldErr) || fieldErr.Field != "ttl" {
+		t.Errorf("IssueToken() without a lifetime error = %v", err)
+	}
+	if _, _, err := s.IssueToken(ctx, "applicant-1", "XYZ123", time.Hour); !errors.As(err, &parseErr) {
+		t.Errorf("IssueToken() with a bad receipt error = %v", err)
+	}
+	unconfigured := New(WithLogger(logging.NewLogger(logging.LogLevelFatal)))
+	if _, _, err := unconfigured.IssueToken(ctx, "applicant-1", testReceipt, time.Hour); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("IssueToken() without a key error = %v, want %v", err, ErrNotConfigured)
+	}
+}
+
+func TestSetSigningKey(t *testing.T) {
+	s := New(WithLogger(logging.NewLogger(logging.LogLevelFatal)), WithTokenConfig(TokenConfig{Issuer: "custom", Audience: TokenAudience}))
+	ctx := context.Background()
+
+	if h := s.Health(ctx); h.Checks["certification"] != "not configured" {
+		t.Errorf("Health() without a key = %+v", h)
+	}
+	if _, _, err := s.IssueToken(ctx, "applicant-1", testReceipt, time.Hour); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("IssueToken() without a key error = %v, want %v", err, ErrNotConfigured)
+	}
+
+	s.SetSigningKey(testKey)
+	if cfg := s.TokenConfig(); cfg.SigningKey != testKey || cfg.Issuer != "custom" {
+		t.Errorf("TokenConfig() after SetSigningKey = %+v", cfg)
+	}
+	if h := s.Health(ctx); h.Checks["certification"] != "ok" {
+		t.Errorf("Health() with a key = %+v", h)
+	}
+	token, _, err := s.IssueToken(ctx, "applicant-1", testReceipt, time.Hour)
+	if err != nil {
+		t.Fatalf("IssueToken() error = %v", err)
+	}
+	if _, err := s.VerifyToken(ctx, token, testReceipt); err != nil {
+		t.Errorf("VerifyToken() error = %v", err)
+	}
+
+	s.SetSigningKey("")
+	if _, err := s.VerifyToken(ctx, token, testReceipt); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("VerifyToken() after clearing the key error = %v, want %v", err, ErrNotConfigured)
+	}
+}
+
+func TestCaseStatus(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+	if _, err := s.Watchlist().Add(ctx, watchlist.AddRequest{ReceiptNumber: testReceipt}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := s.CaseStatus(ctx, testReceipt, "")
+	if err != nil {
+		t.Fatalf("CaseStatus() error = %v", err)
+	}
+	if !report.Watched || report.Deadlines == nil {
+		t.Errorf("CaseStatus() = %+v", report)
+	}
+	if entry, err := s.Watchlist().Get(ctx, testReceipt); err != nil || entry.LastStatus == nil {
+		t.Errorf("status was not recorded on the watched case: %+v, %v", entry, err)
+	}
+
+	var invalid validation.ValidationError
+	if _, err := s.CaseStatus(ctx, testReceipt, "moon"); !errors.As(err, &invalid) {
+		t.Errorf("invalid environment error = %v", err)
+	}
+}
+
+// decidedUpstream answers case lookups with an approved I-485
+type decidedUpstream struct {
+	processing.UpstreamClient
+}
+
+func (decidedUpstream) FetchCaseStatus(ctx context.Context, req processing.CaseStatusRequest) ([]byte, error) {
+	return json.Marshal(types.CaseStatusResponse{CaseStatus: types.UpstreamCaseStatus{
+		ReceiptNumber: req.ReceiptNumber,
+		FormType:      "I-485",
+		ModifiedDate:  "06-03-2024",
+		CurrentStatus: "Case Was Approved",
+		History: []types.CaseStatusHistoryEntry{
+			{Title: "Case Was Received", Date: "01-15-2024"},
+			{Title: "Case Was Approved", Date: "06-03-2024"},
+		},
+	}})
+}
+
+func TestCaseStatusDecided(t *testing.T) {
+	processor := processing.NewProcessor(processing.WithLogger(logging.NewLogger(logging.LogLevelFatal)), processing.WithUpstream(decidedUpstream{}))
+	report, err := newTestService(WithProcessor(processor)).CaseStatus(context.Background(), testReceipt, "")
+	if err != nil {
+		t.Fatalf("CaseStatus() error = %v", err)
+	}
+	if report.Form == nil {
+		t.Fatalf("CaseStatus() form = nil, want I-485")
+	}
+	if report.Estimate != nil {
+		t.Errorf("CaseStatus() of an approved case estimated a decision: %+v", report.Estimate)
+	}
+}
+
+func TestCaseTransfer(t *testing.T) {
+	cases := casegen.NewGenerator(casegen.WithScenario(casegen.ScenarioHappyPath))
+	processor := processing.NewProcessor(processing.WithLogger(logging.NewLogger(logging.LogLevelFatal)), processing.WithCaseGenerator(cases))
+	bus := changes.NewBus()
+	var mu sync.Mutex
+	var transfers []changes.Event
+	bus.Subscribe(func(e changes.Event) {
+		if e.Type == changes.CaseTransferred {
+			mu.Lock()
+			defer mu.Unlock()
+			transfers = append(transfers, e)
+		}
+	})
+	s := newTestService(WithProcessor(processor), WithChanges(bus))
+	ctx := context.Background()
+	if _, err := s.Watchlist().Add(ctx, watchlist.AddRequest{ReceiptNumber: testReceipt}); err != nil {
+		t.Fatal(err)
+	}
+
+	lookup := func() *CaseReport {
+		t.Helper()
+		report, err := s.CaseStatus(ctx, testReceipt, "")
+		if err != nil {
+			t.Fatalf("CaseStatus() error = %v", err)
+		}
+		return report
+	}
+	if report := lookup(); report.Case.ServiceCenter != "Vermont Service Center" {
+		t.Errorf("office before the transfer = %q", report.Case.ServiceCenter)
+	}
+
+	cases.SetScenario(casegen.ScenarioTransfer)
+	if report := lookup(); report.Case.ServiceCenter != "National Benefits Center" {
+		t.Errorf("office after the transfer = %q", report.Case.ServiceCenter)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(transfers) != 1 || transfers[0].Before != "Vermont Service Center" || transfers[0].After != "National Benefits Center" {
+		t.Errorf("transfer events = %+v, want one from Vermont to the National Benefits Center", transfers)
+	}
+}
+
+func TestSubmitScan(t *testing.T) {
+	if largest := time.Duration(2*scan.MaxRadius) * time.Minute / ScanRateLimit; largest >= ScanTimeout {
+		t.Fatalf("the largest scan needs %v, longer than ScanTimeout %v", largest, ScanTimeout)
+	}
+
+	// an exhausted per-case limit does not hold up scans
+	limiter := ratelimit.NewRateLimiter(1, time.Hour)
+	limiter.Allow("receipt-scan")
+	s := newTestService(WithRateLimiter(limiter, time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	job, err := s.SubmitScan(ctx, testReceipt, "", 1)
+	if err != nil {
+		t.Fatalf("SubmitScan() error = %v", err)
+	}
+	result, err := job.Wait(ctx)
+	if err != nil {
+		t.Fatalf("scan error = %v", err)
+	}
+	if report := result.(*scan.Report); !report.Complete || report.Scanned != 2 {
+		t.Errorf("scan report = %+v", report)
+	}
+
+	var invalid validation.ValidationError
+	if _, err := s.SubmitScan(ctx, testReceipt, "", scan.MaxRadius+1); !errors.As(err, &invalid) {
+		t.Errorf("SubmitScan() over the maximum radius error = %v", err)
+	}
+}
+
+func TestPolling(t *testing.T) {
+	events := &eventLog{}
+	s := newTestService(WithEvents(events.add))
+	ctx := context.Background()
+	if _, err := s.Watchlist().Add(ctx, watchlist.AddRequest{ReceiptNumber: testReceipt}); err != nil {
+		t.Fatal(err)
+	}
+
+	poller, err := s.NewPoller("staging", scheduler.DefaultConfig())
+	if err != nil {
+		t.Fatalf("NewPoller() error = %v", err)
+	}
+	stats, err := poller.RunOnce(ctx)
+	if err != nil || stats.Checked != 1 {
+		t.Fatalf("RunOnce() = %+v, %v; want the watched case checked", stats, err)
+	}
+	entry, err := s.Watchlist().Get(ctx, testReceipt)
+	if err != nil || entry.LastStatus == nil {
+		t.Errorf("watched case after a poll = %+v, %v; want its status recorded", entry, err)
+	}
+	if !events.types()[EventCaseRefreshed] {
+		t.Errorf("events = %v, want %s", events.types(), EventCaseRefreshed)
+	}
+
+	var invalid validation.ValidationError
+	if _, err := s.NewPoller("moon", scheduler.DefaultConfig()); !errors.As(err, &invalid) {
+		t.Errorf("NewPoller() in an unknown environment error = %v", err)
+	}
+
+	if err := s.StartPolling(ctx, "", scheduler.DefaultConfig()); err != nil || !s.Polling() {
+		t.Fatalf("StartPolling() error = %v, Polling() = %v", err, s.Polling())
+	}
+	// starting again replaces the running poller
+	if err := s.StartPolling(ctx, "staging", scheduler.DefaultConfig()); err != nil {
+		t.Errorf("second StartPolling() error = %v", err)
+	}
+	s.StopPolling()
+	if s.Polling() {
+		t.Error("Polling() after StopPolling() = true")
+	}
+	s.StopPolling()
+}
+
+func TestClock(t *testing.T) {
+	now := time.Date(2025, 3, 14, 9, 0, 0, 0, time.UTC)
+	s := newTestService(WithClock(clock.NewFake(now)))
+	if got := s.Clock().Now(); !got.Equal(now) {
+		t.Errorf("Clock().Now() = %v, want %v", got, now)
+	}
+	if h := s.Health(context.Background()); h.Timestamp != "2025-03-14T09:00:00Z" {
+		t.Errorf("Health() timestamp = %s, want the service clock", h.Timestamp)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	if h := newTestService().Health(context.Background()); !h.Healthy() || h.Checks["certification"] != "ok" {
+		t.Errorf("Health() = %+v", h)
+	}
+
+	records, err := store.Open(context.Background(), store.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	records.Close()
+	if h := newTestService(WithStore(records)).Health(context.Background()); h.Healthy() || h.Checks["store"] != "unavailable" {
+		t.Errorf("Health() with a closed store = %+v", h)
+	}
+}
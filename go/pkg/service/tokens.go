@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/store"
+	"MyUSCISgo/pkg/validation"
+)
+
+// Certification token constants
+const (
+	TokenIssuer    = "uscis-api"
+	TokenAudience  = "uscis-client"
+	TokenAlgorithm = "HS256"
+)
+
+// Claims are the claims of a certification token
+type Claims struct {
+	Issuer     string `json:"iss"`
+	Subject    string `json:"sub"`
+	Audience   string `json:"aud"`
+	ExpiresAt  int64  `json:"exp"`
+	IssuedAt   int64  `json:"iat"`
+	CaseNumber string `json:"case_number"`
+}
+
+// ID identifies the token the claims belong to, for revocation
+func (c *Claims) ID() string {
+	return fmt.Sprintf("%s-%d", c.Subject, c.IssuedAt)
+}
+
+// TokenConfig holds what certification tokens are checked against
+type TokenConfig struct {
+	SigningKey       string
+	Issuer           string
+	Audience         string
+	ClockSkew        time.Duration
+	EnableRevocation bool
+}
+
+// DefaultTokenConfig returns the token checks without a signing key
+func DefaultTokenConfig() TokenConfig {
+	return TokenConfig{
+		Issuer:           TokenIssuer,
+		Audience:         TokenAudience,
+		ClockSkew:        5 * time.Minute,
+		EnableRevocation: true,
+	}
+}
+
+// TokenConfig returns the token checks in use
+func (s *Service) TokenConfig() TokenConfig {
+	return *s.tokens.Load()
+}
+
+// SetSigningKey replaces the key certification tokens are signed with,
+// for hosts that only learn it after the service is created. An empty key
+// disables certification.
+func (s *Service) SetSigningKey(key string) {
+	cfg := s.TokenConfig()
+	cfg.SigningKey = key
+	s.tokens.Store(&cfg)
+}
+
+// VerifyToken checks the signature, claims and revocation of a token
+// issued for caseNumber. The returned error says why a token was rejected
+// and should be logged rather than shown; callers report ErrInvalidToken.
+func (s *Service) VerifyToken(ctx context.Context, token, caseNumber string) (*Claims, error) {
+	cfg := s.TokenConfig()
+	if cfg.SigningKey == "" {
+		return nil, ErrNotConfigured
+	}
+	if !s.validationLimiter.Allow("token_validation") {
+		return nil, &RateLimitError{Key: "token_validation", RetryAfter: time.Minute}
+	}
+	if !receipt.IsValid(caseNumber) {
+		return nil, fmt.Errorf("invalid case number format %q", caseNumber)
+	}
+
+	claims, err := s.parseToken(cfg.SigningKey, token)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	switch {
+	case claims.Issuer != cfg.Issuer:
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	case claims.Audience != cfg.Audience:
+		return nil, fmt.Errorf("unexpected audience %q", claims.Audience)
+	case now.After(time.Unix(claims.ExpiresAt, 0).Add(cfg.ClockSkew)):
+		return nil, errors.New("token has expired")
+	case time.Unix(claims.IssuedAt, 0).After(now.Add(cfg.ClockSkew)):
+		return nil, errors.New("token is issued in the future")
+	case claims.CaseNumber != caseNumber:
+		return nil, errors.New("token was issued for another case")
+	}
+
+	if cfg.EnableRevocation {
+		revoked, err := s.records.Tokens().IsRevoked(ctx, claims.ID())
+		if err != nil {
+			// a token whose state cannot be read is treated as revoked
+			return nil, fmt.Errorf("failed to check revocation: %w", err)
+		}
+		if revoked {
+			return nil, errors.New("token has been revoked")
+		}
+	}
+	return claims, nil
+}
+
+// IssueToken signs a certification token for subject on caseNumber that
+// expires after ttl, and registers it so it can be revoked. The error is a
+// validation.ValidationError or *receipt.ParseError for bad input, or
+// ErrNotConfigured without a signing key.
+func (s *Service) IssueToken(ctx context.Context, subject, caseNumber string, ttl time.Duration) (string, *Claims, error) {
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		return "", nil, validation.ValidationError{Field: "subject", Message: "subject is required"}
+	}
+	if ttl <= 0 {
+		return "", nil, validation.ValidationError{Field: "ttl", Message: "token lifetime must be positive"}
+	}
+	caseReceipt, err := receipt.Parse(caseNumber)
+	if err != nil {
+		return "", nil, err
+	}
+	cfg := s.TokenConfig()
+	if cfg.SigningKey == "" {
+		return "", nil, ErrNotConfigured
+	}
+
+	now := s.clock.Now()
+	claims := &Claims{
+		Issuer:     cfg.Issuer,
+		Subject:    subject,
+		Audience:   cfg.Audience,
+		ExpiresAt:  now.Add(ttl).Unix(),
+		IssuedAt:   now.Unix(),
+		CaseNumber: caseReceipt.Number,
+	}
+	header, err := json.Marshal(map[string]string{"alg": TokenAlgorithm, "typ": "JWT"})
+	if err != nil {
+		return "", nil, err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	token := signed + "." + base64.RawURLEncoding.EncodeToString(sign(cfg.SigningKey, signed))
+
+	if err := s.RegisterToken(ctx, claims.ID(), time.Unix(claims.ExpiresAt, 0)); err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
+}
+
+// ParseToken checks the signature of a token and returns its claims
+// without validating them, so a token can be revoked by value
+func (s *Service) ParseToken(token string) (*Claims, error) {
+	key := s.TokenConfig().SigningKey
+	if key == "" {
+		return nil, ErrNotConfigured
+	}
+	claims, err := s.parseToken(key, token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	return claims, nil
+}
+
+// parseToken verifies an HS256 JWT signed with key and returns its claims
+func (s *Service) parseToken(key, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid JWT format: expected 3 parts, got %d", len(parts))
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JWT header: %w", err)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, fmt.Errorf("failed to parse JWT header: %w", err)
+	}
+	if header.Alg != TokenAlgorithm {
+		return nil, fmt.Errorf("unsupported JWT algorithm: expected %s, got %q", TokenAlgorithm, header.Alg)
+	}
+
+	// Verify the signature before looking at the claims
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JWT signature: %w", err)
+	}
+	if !hmac.Equal(sign(key, parts[0]+"."+parts[1]), signature) {
+		return nil, errors.New("JWT signature verification failed")
+	}
+
+	payloadJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JWT payload: %w", err)
+	}
+	var claims Claims
+	if err := json.Unmarshal(payloadJSON, &claims); err != nil {
+		return nil, fmt.Errorf("failed to parse JWT claims: %w", err)
+	}
+	return &claims, nil
+}
+
+// sign computes the HS256 signature of data with key
+func sign(key, data string) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// RevokeToken marks a token as revoked
+func (s *Service) RevokeToken(ctx context.Context, tokenID string) error {
+	if tokenID == "" {
+		return errors.New("token ID is empty")
+	}
+	if err := s.records.Tokens().Revoke(ctx, tokenID); err != nil {
+		return err
+	}
+	s.logger.Info("Token revoked", map[string]interface{}{
+		"tokenID": tokenID,
+	})
+	return nil
+}
+
+// RegisterToken records a token as issued until it expires
+func (s *Service) RegisterToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	if err := s.records.Tokens().Put(ctx, &store.Token{ID: tokenID, ExpiresAt: expiresAt}); err != nil {
+		return err
+	}
+	s.logger.Info("Token added to valid list", map[string]interface{}{
+		"tokenID":   tokenID,
+		"expiresAt": expiresAt,
+	})
+	return nil
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"MyUSCISgo/pkg/audit"
+)
+
+// errStopScan ends a scan early without failing it
+var errStopScan = errors.New("store: stop scan")
+
+// auditKey orders entries by time; the ID keeps keys unique
+func auditKey(e audit.Entry) string {
+	return timeKey(e.Time) + "/" + e.ID
+}
+
+// timeKey renders t so keys sort chronologically
+func timeKey(t time.Time) string {
+	return fmt.Sprintf("%016x", uint64(t.UnixNano()))
+}
+
+// AuditLog stores audit entries. It implements audit.Log and, unlike
+// audit.MemoryLog, never drops entries on its own; call Prune to bound it.
+type AuditLog struct {
+	s *Store
+}
+
+// Append records an entry, filling in a missing ID, time and actor
+func (r *AuditLog) Append(ctx context.Context, entry audit.Entry) error {
+	entry, err := audit.Prepare(entry, r.s.clock.Now())
+	if err != nil {
+		return err
+	}
+	return r.s.backend.Update(ctx, func(tx Tx) error {
+		return putJSON(tx, BucketAudit, auditKey(entry), entry)
+	})
+}
+
+// List returns the entries matching query, oldest first
+func (r *AuditLog) List(ctx context.Context, query audit.Query) ([]audit.Entry, error) {
+	rng := Range{Reverse: query.Limit > 0}
+	if !query.Since.IsZero() {
+		rng.Start = timeKey(query.Since)
+	}
+	var out []audit.Entry
+	err := r.s.backend.View(ctx, func(tx Tx) error {
+		err := scanJSON(tx, BucketAudit, rng, func(_ string, data []byte) error {
+			var e audit.Entry
+			if err := json.Unmarshal(data, &e); err != nil {
+				return err
+			}
+			if !query.Matches(e) {
+				return nil
+			}
+			out = append(out, e)
+			if query.Limit > 0 && len(out) >= query.Limit {
+				return errStopScan
+			}
+			return nil
+		})
+		if errors.Is(err, errStopScan) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rng.Reverse {
+		slices.Reverse(out)
+	}
+	return out, nil
+}
+
+// Prune removes entries recorded before the cutoff and returns the number
+// removed
+func (r *AuditLog) Prune(ctx context.Context, before time.Time) (int, error) {
+	removed := 0
+	err := r.s.backend.Update(ctx, func(tx Tx) error {
+		var keys []string
+		if err := tx.Scan(BucketAudit, Range{End: timeKey(before)}, func(key string, _ []byte) error {
+			keys = append(keys, key)
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := tx.Delete(BucketAudit, key); err != nil {
+				return err
+			}
+		}
+		removed = len(keys)
+		return nil
+	})
+	return removed, err
+}
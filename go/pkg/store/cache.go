@@ -0,0 +1,253 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrAttached is returned when attaching a Cache that already has a target
+var ErrAttached = errors.New("store: cache is already attached")
+
+// Cache is a Backend that serves every transaction from memory and writes
+// committed changes through to a target backend in the background, in
+// commit order. It lets code that must not block on I/O, such as a
+// synchronous JavaScript callback in the browser, use a backend that does,
+// such as IndexedDB.
+//
+// A Cache starts out unattached and purely in memory; Attach loads a target
+// and starts writing through to it. A commit returns before it reaches the
+// target, so a write that fails there, for example with ErrQuotaExceeded,
+// is only reported to the error handler and by Flush.
+type Cache struct {
+	*engine
+	onError func(error)
+
+	mu        sync.Mutex
+	target    Backend
+	attaching bool
+	queue     []changes
+	writing   bool
+	progress  chan struct{}
+	err       error
+}
+
+// CacheOption configures a Cache
+type CacheOption func(*Cache)
+
+// OnWriteError sets a function called when changes cannot be written to
+// the target. Those changes stay in memory but are not persisted.
+func OnWriteError(fn func(error)) CacheOption {
+	return func(c *Cache) {
+		c.onError = fn
+	}
+}
+
+// NewCache creates an empty, unattached cache
+func NewCache(opts ...CacheOption) *Cache {
+	c := &Cache{engine: newEngine(), progress: make(chan struct{})}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.engine.commit = c.enqueue
+	return c
+}
+
+// enqueue records a commit for the target. It runs with the engine locked.
+func (c *Cache) enqueue(ch changes) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.target == nil && !c.attaching {
+		return nil
+	}
+	c.queue = append(c.queue, ch)
+	if c.target != nil && !c.writing {
+		c.writing = true
+		go c.drain()
+	}
+	return nil
+}
+
+// Attach loads the target's data into the cache and writes every later
+// commit through to it. Records present in both keep the target's value;
+// records only in the cache, including any committed while the target
+// loads, are written to the target.
+func (c *Cache) Attach(ctx context.Context, target Backend) error {
+	c.mu.Lock()
+	if c.target != nil || c.attaching {
+		c.mu.Unlock()
+		return ErrAttached
+	}
+	c.attaching = true
+	c.mu.Unlock()
+
+	// read the target without holding the engine lock, so transactions on
+	// the cache keep running while it loads
+	loaded := make(map[string]map[string][]byte)
+	err := target.View(ctx, func(tx Tx) error {
+		for _, bucket := range Buckets() {
+			loaded[bucket] = make(map[string][]byte)
+			if err := tx.Scan(bucket, Range{}, func(key string, value []byte) error {
+				loaded[bucket][key] = value
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	c.engine.mu.Lock()
+	defer c.engine.mu.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attaching = false
+	pending := c.queue
+	c.queue = nil
+	if err != nil {
+		return err
+	}
+	if c.closed {
+		return ErrClosed
+	}
+
+	// commits made while loading are newer than the target
+	changed := make(map[string]map[string]bool)
+	for _, ch := range pending {
+		for bucket, writes := range ch {
+			for key := range writes {
+				if changed[bucket] == nil {
+					changed[bucket] = make(map[string]bool)
+				}
+				changed[bucket][key] = true
+			}
+		}
+	}
+	initial := make(changes)
+	for bucket, values := range c.buckets {
+		for key, value := range values {
+			if _, ok := loaded[bucket][key]; !ok && !changed[bucket][key] {
+				if initial[bucket] == nil {
+					initial[bucket] = make(map[string]write)
+				}
+				initial[bucket][key] = write{value: value}
+			}
+		}
+	}
+	for bucket, values := range loaded {
+		for key, value := range values {
+			if !changed[bucket][key] {
+				c.buckets[bucket][key] = value
+			}
+		}
+	}
+
+	c.target = target
+	if len(initial) > 0 {
+		c.queue = append(c.queue, initial)
+	}
+	c.queue = append(c.queue, pending...)
+	if len(c.queue) > 0 {
+		c.writing = true
+		go c.drain()
+	}
+	return nil
+}
+
+// Attached reports whether the cache writes through to a target
+func (c *Cache) Attached() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.target != nil
+}
+
+// drain writes queued commits to the target until the queue is empty
+func (c *Cache) drain() {
+	for {
+		c.mu.Lock()
+		if len(c.queue) == 0 {
+			c.writing = false
+			close(c.progress)
+			c.progress = make(chan struct{})
+			c.mu.Unlock()
+			return
+		}
+		next, target := c.queue[0], c.target
+		c.queue = c.queue[1:]
+		c.mu.Unlock()
+
+		err := target.Update(context.Background(), func(tx Tx) error {
+			for bucket, writes := range next {
+				for key, w := range writes {
+					var err error
+					if w.deleted {
+						// the target may never have seen the record
+						if err = tx.Delete(bucket, key); errors.Is(err, ErrNotFound) {
+							err = nil
+						}
+					} else {
+						err = tx.Put(bucket, key, w.value)
+					}
+					if err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			c.mu.Lock()
+			c.err = err
+			c.mu.Unlock()
+			if c.onError != nil {
+				c.onError(err)
+			}
+		}
+	}
+}
+
+// Flush waits until every commit so far has been written to the target and
+// returns the first write error since the last Flush
+func (c *Cache) Flush(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		if !c.writing {
+			err := c.err
+			c.err = nil
+			c.mu.Unlock()
+			return err
+		}
+		progress := c.progress
+		c.mu.Unlock()
+
+		select {
+		case <-progress:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Close flushes pending writes, closes the target and releases the data;
+// later transactions fail with ErrClosed
+func (c *Cache) Close() error {
+	c.engine.mu.Lock()
+	if c.closed {
+		c.engine.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.buckets = nil
+	c.engine.mu.Unlock()
+
+	err := c.Flush(context.Background())
+	c.mu.Lock()
+	target := c.target
+	c.mu.Unlock()
+	if target != nil {
+		if closeErr := target.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,199 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/types"
+)
+
+// caseKey is the key of a case: its normalized receipt number
+func caseKey(receiptNumber string) (string, error) {
+	key := receipt.Normalize(receiptNumber)
+	if key == "" {
+		return "", fmt.Errorf("store: %w", ErrEmptyKey)
+	}
+	return key, nil
+}
+
+// Cases stores case records. The timeline of a record is kept in the
+// timeline repository and merged on every Put, so the stored history grows
+// even when upstream only returns recent events.
+type Cases struct {
+	s *Store
+}
+
+// Get returns a case with its accumulated timeline
+func (r *Cases) Get(ctx context.Context, receiptNumber string) (*types.CaseRecord, error) {
+	key, err := caseKey(receiptNumber)
+	if err != nil {
+		return nil, err
+	}
+	var record *types.CaseRecord
+	err = r.s.backend.View(ctx, func(tx Tx) error {
+		record = &types.CaseRecord{}
+		if err := getJSON(tx, BucketCases, key, record); err != nil {
+			return err
+		}
+		return attachTimeline(tx, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Put stores a case and merges its timeline into the stored history
+func (r *Cases) Put(ctx context.Context, record *types.CaseRecord) error {
+	if record == nil {
+		return errors.New("store: case record is nil")
+	}
+	key, err := caseKey(record.ReceiptNumber)
+	if err != nil {
+		return err
+	}
+	stored := *record
+	stored.ReceiptNumber = key
+	stored.Timeline = nil
+	return r.s.backend.Update(ctx, func(tx Tx) error {
+		if err := putJSON(tx, BucketCases, key, &stored); err != nil {
+			return err
+		}
+		if record.Timeline == nil {
+			return nil
+		}
+		_, err := mergeTimeline(tx, key, record.Timeline)
+		return err
+	})
+}
+
+// Delete removes a case and its timeline
+func (r *Cases) Delete(ctx context.Context, receiptNumber string) error {
+	key, err := caseKey(receiptNumber)
+	if err != nil {
+		return err
+	}
+	return r.s.backend.Update(ctx, func(tx Tx) error {
+		if err := tx.Delete(BucketCases, key); err != nil {
+			return err
+		}
+		if err := tx.Delete(BucketTimelines, key); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		return nil
+	})
+}
+
+// List returns every case ordered by receipt number
+func (r *Cases) List(ctx context.Context) ([]*types.CaseRecord, error) {
+	var records []*types.CaseRecord
+	err := r.s.backend.View(ctx, func(tx Tx) error {
+		if err := scanJSON(tx, BucketCases, Range{}, func(_ string, data []byte) error {
+			record := &types.CaseRecord{}
+			if err := json.Unmarshal(data, record); err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := attachTimeline(tx, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// attachTimeline sets a record's timeline from the timeline repository
+func attachTimeline(tx Tx, record *types.CaseRecord) error {
+	timeline := &types.CaseTimeline{}
+	err := getJSON(tx, BucketTimelines, record.ReceiptNumber, timeline)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		record.Timeline = types.NewCaseTimeline(record.ReceiptNumber)
+	case err != nil:
+		return err
+	default:
+		record.Timeline = timeline
+	}
+	return nil
+}
+
+// Timelines stores the accumulated status history of cases
+type Timelines struct {
+	s *Store
+}
+
+// Get returns the stored timeline of a case
+func (r *Timelines) Get(ctx context.Context, receiptNumber string) (*types.CaseTimeline, error) {
+	key, err := caseKey(receiptNumber)
+	if err != nil {
+		return nil, err
+	}
+	var timeline *types.CaseTimeline
+	err = r.s.backend.View(ctx, func(tx Tx) error {
+		timeline = &types.CaseTimeline{}
+		return getJSON(tx, BucketTimelines, key, timeline)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return timeline, nil
+}
+
+// Merge adds the events of timeline to the stored history and returns the
+// number of new events
+func (r *Timelines) Merge(ctx context.Context, timeline *types.CaseTimeline) (int, error) {
+	if timeline == nil {
+		return 0, nil
+	}
+	key, err := caseKey(timeline.ReceiptNumber)
+	if err != nil {
+		return 0, err
+	}
+	var added int
+	err = r.s.backend.Update(ctx, func(tx Tx) error {
+		var err error
+		added, err = mergeTimeline(tx, key, timeline)
+		return err
+	})
+	return added, err
+}
+
+// Delete removes the stored timeline of a case
+func (r *Timelines) Delete(ctx context.Context, receiptNumber string) error {
+	key, err := caseKey(receiptNumber)
+	if err != nil {
+		return err
+	}
+	return r.s.backend.Update(ctx, func(tx Tx) error {
+		return tx.Delete(BucketTimelines, key)
+	})
+}
+
+// mergeTimeline merges events into the stored timeline under key
+func mergeTimeline(tx Tx, key string, timeline *types.CaseTimeline) (int, error) {
+	stored := &types.CaseTimeline{}
+	err := getJSON(tx, BucketTimelines, key, stored)
+	if errors.Is(err, ErrNotFound) {
+		stored = types.NewCaseTimeline(key)
+	} else if err != nil {
+		return 0, err
+	}
+	stored.ReceiptNumber = key
+	added := stored.Merge(timeline)
+	if added == 0 && err == nil {
+		return 0, nil
+	}
+	return added, putJSON(tx, BucketTimelines, key, stored)
+}
@@ -0,0 +1,527 @@
+package store
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// KeySize is the length of master keys and record data keys
+const KeySize = 32
+
+const (
+	// keyringKey holds the encryption header in the meta bucket of the
+	// wrapped backend; it is hidden from callers
+	keyringKey = "_keyring"
+	// recordVersion starts every encrypted record
+	recordVersion = 1
+	// wrappedKeySize is a data key sealed with AES-GCM: nonce, key and tag
+	wrappedKeySize = 12 + KeySize + 16
+)
+
+var (
+	// ErrWrongKey is returned when no key in the keyring opens a store
+	ErrWrongKey = errors.New("store: encryption key does not match")
+	// ErrUnknownKey is returned for a record wrapped with a key that is not
+	// in the keyring
+	ErrUnknownKey = errors.New("store: record is encrypted with an unknown key")
+	// ErrDecrypt is returned when a record fails authentication, because
+	// it was modified or moved to another key
+	ErrDecrypt = errors.New("store: record cannot be decrypted")
+	// ErrNotEncrypted is returned when opening a backend that already holds
+	// unencrypted records without EncryptExisting
+	ErrNotEncrypted = errors.New("store: backend holds unencrypted data")
+)
+
+// blindBuckets are keyed by receipt numbers and token IDs. Their keys are
+// stored as HMACs so lookups by exact key still work without revealing
+// the key; range scans decrypt the bucket and filter in memory. The audit
+// and job buckets keep their time-ordered, random keys in the clear so
+// range scans stay cheap.
+var blindBuckets = map[string]bool{
+	BucketCases:     true,
+	BucketTimelines: true,
+	BucketWatchlist: true,
+	BucketTokens:    true,
+}
+
+// Key is master key material, such as a key from a vault or one supplied
+// by the host application
+type Key struct {
+	ID       string
+	Material []byte
+}
+
+// Keyring holds the key that wraps new data keys and older keys still
+// needed to unwrap existing ones
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring creates a keyring that wraps with primary and can unwrap with
+// primary or any previous key
+func NewKeyring(primary Key, previous ...Key) (*Keyring, error) {
+	k := &Keyring{primary: primary.ID, keys: make(map[string]cipher.AEAD)}
+	for _, key := range append([]Key{primary}, previous...) {
+		if key.ID == "" || len(key.ID) > 255 {
+			return nil, fmt.Errorf("store: key ID must be 1 to 255 bytes, got %d", len(key.ID))
+		}
+		if len(key.Material) != KeySize {
+			return nil, fmt.Errorf("store: key %q must be %d bytes, got %d", key.ID, KeySize, len(key.Material))
+		}
+		if _, dup := k.keys[key.ID]; dup {
+			return nil, fmt.Errorf("store: duplicate key %q", key.ID)
+		}
+		aead, err := newAEAD(key.Material)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[key.ID] = aead
+	}
+	return k, nil
+}
+
+// Primary returns the ID of the key used for new records
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// wrap seals a data key with the primary key
+func (k *Keyring) wrap(dataKey, aad []byte) []byte {
+	return k.keys[k.primary].Seal(nil, nil, dataKey, aad)
+}
+
+// unwrap opens a data key sealed with the key id
+func (k *Keyring) unwrap(id string, wrapped, aad []byte) ([]byte, error) {
+	aead, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	dataKey, err := aead.Open(nil, nil, wrapped, aad)
+	if err != nil {
+		return nil, err
+	}
+	return dataKey, nil
+}
+
+// newAEAD returns AES-256-GCM with random nonces prepended to ciphertexts
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCMWithRandomNonce(block)
+}
+
+// keyringHeader records the blind index key, wrapped like a data key
+type keyringHeader struct {
+	Version  int    `json:"version"`
+	KeyID    string `json:"keyId"`
+	IndexKey []byte `json:"indexKey"`
+}
+
+// keyringAAD binds the wrapped index key to its purpose
+var keyringAAD = []byte("myuscis/store/index-key")
+
+// Encrypted is a Backend that encrypts every value in another backend with
+// envelope encryption. Each record gets a fresh AES-256-GCM data key,
+// which is wrapped with the keyring's primary key and stored next to the
+// ciphertext, so rotating the master key only rewraps data keys. Records
+// are bound to their bucket and key, and keys in buckets indexed by receipt
+// number are replaced with blind HMAC indexes.
+type Encrypted struct {
+	backend  Backend
+	keys     *Keyring
+	index    []byte
+	migrate  bool
+	migrated int
+}
+
+// EncryptOption configures an Encrypted backend
+type EncryptOption func(*Encrypted)
+
+// EncryptExisting encrypts the records of a backend that was written
+// without encryption, in one transaction, instead of failing with
+// ErrNotEncrypted. Backends that keep history, such as a File log, still
+// hold the plaintext until they are compacted.
+func EncryptExisting() EncryptOption {
+	return func(e *Encrypted) {
+		e.migrate = true
+	}
+}
+
+// OpenEncrypted wraps a backend with encryption. A new backend is set up
+// for keys; an existing one must have been encrypted with a key in the
+// keyring.
+func OpenEncrypted(ctx context.Context, backend Backend, keys *Keyring, opts ...EncryptOption) (*Encrypted, error) {
+	e := &Encrypted{backend: backend, keys: keys}
+	for _, opt := range opts {
+		opt(e)
+	}
+	err := backend.Update(ctx, func(tx Tx) error {
+		data, err := tx.Get(BucketMeta, keyringKey)
+		if errors.Is(err, ErrNotFound) {
+			return e.setup(tx)
+		}
+		if err != nil {
+			return err
+		}
+		var header keyringHeader
+		if err := json.Unmarshal(data, &header); err != nil {
+			return fmt.Errorf("store: invalid encryption header: %w", err)
+		}
+		index, err := keys.unwrap(header.KeyID, header.IndexKey, keyringAAD)
+		if err != nil {
+			return fmt.Errorf("%w: the store was encrypted with key %q", ErrWrongKey, header.KeyID)
+		}
+		e.index = index
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// setup creates the index key of a new store, encrypting existing records
+// when allowed
+func (e *Encrypted) setup(tx Tx) error {
+	type record struct {
+		bucket, key string
+		value       []byte
+	}
+	var existing []record
+	for _, bucket := range Buckets() {
+		err := tx.Scan(bucket, Range{}, func(key string, value []byte) error {
+			if !e.migrate {
+				return ErrNotEncrypted
+			}
+			existing = append(existing, record{bucket, key, value})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	e.index = make([]byte, KeySize)
+	rand.Read(e.index)
+	if err := e.putHeader(tx); err != nil {
+		return err
+	}
+	e.migrated = len(existing)
+	etx := &encryptedTx{e: e, tx: tx}
+	for _, r := range existing {
+		if err := tx.Delete(r.bucket, r.key); err != nil {
+			return err
+		}
+		if err := etx.Put(r.bucket, r.key, r.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putHeader stores the index key wrapped with the primary key
+func (e *Encrypted) putHeader(tx Tx) error {
+	data, err := json.Marshal(keyringHeader{
+		Version:  recordVersion,
+		KeyID:    e.keys.primary,
+		IndexKey: e.keys.wrap(e.index, keyringAAD),
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Put(BucketMeta, keyringKey, data)
+}
+
+// Migrated returns the number of unencrypted records encrypted when the
+// backend was opened with EncryptExisting
+func (e *Encrypted) Migrated() int {
+	return e.migrated
+}
+
+// View runs fn in a read-only transaction
+func (e *Encrypted) View(ctx context.Context, fn func(Tx) error) error {
+	return e.backend.View(ctx, func(tx Tx) error {
+		return fn(&encryptedTx{e: e, tx: tx})
+	})
+}
+
+// Update runs fn in a read-write transaction
+func (e *Encrypted) Update(ctx context.Context, fn func(Tx) error) error {
+	return e.backend.Update(ctx, func(tx Tx) error {
+		return fn(&encryptedTx{e: e, tx: tx})
+	})
+}
+
+// Close closes the wrapped backend
+func (e *Encrypted) Close() error {
+	return e.backend.Close()
+}
+
+// Rotate rewraps every data key, and the index key, that is not wrapped
+// with the keyring's primary key. Payloads are not re-encrypted. It
+// returns the number of records rewrapped; afterwards previous keys are no
+// longer needed.
+func (e *Encrypted) Rotate(ctx context.Context) (int, error) {
+	var rewrapped int
+	err := e.backend.Update(ctx, func(tx Tx) error {
+		rewrapped = 0
+		stale := false
+		for _, bucket := range Buckets() {
+			updates := make(map[string][]byte)
+			err := tx.Scan(bucket, Range{}, func(key string, data []byte) error {
+				if hidden(bucket, key) {
+					var header keyringHeader
+					if err := json.Unmarshal(data, &header); err != nil {
+						return fmt.Errorf("store: invalid encryption header: %w", err)
+					}
+					stale = header.KeyID != e.keys.primary
+					return nil
+				}
+				rec, err := parseRecord(data)
+				if err != nil {
+					return fmt.Errorf("%w %s/%s", err, bucket, key)
+				}
+				if rec.keyID == e.keys.primary {
+					return nil
+				}
+				aad := recordAAD(bucket, key)
+				dataKey, err := e.keys.unwrap(rec.keyID, rec.wrapped, aad)
+				if err != nil {
+					return fmt.Errorf("store: failed to rewrap %s/%s: %w", bucket, key, err)
+				}
+				rec.keyID, rec.wrapped = e.keys.primary, e.keys.wrap(dataKey, aad)
+				updates[key] = rec.bytes()
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for key, data := range updates {
+				if err := tx.Put(bucket, key, data); err != nil {
+					return err
+				}
+			}
+			rewrapped += len(updates)
+		}
+		if !stale {
+			return nil
+		}
+		return e.putHeader(tx)
+	})
+	return rewrapped, err
+}
+
+// storedKey is the key a record is kept under in the wrapped backend
+func (e *Encrypted) storedKey(bucket, key string) string {
+	if !blindBuckets[bucket] || key == "" {
+		return key
+	}
+	mac := hmac.New(sha256.New, e.index)
+	mac.Write([]byte(bucket))
+	mac.Write([]byte{0})
+	mac.Write([]byte(key))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// recordAAD binds a record to where it is stored
+func recordAAD(bucket, storedKey string) []byte {
+	return []byte(bucket + "\x00" + storedKey)
+}
+
+// record is an encrypted value: the ID of the wrapping key, the wrapped
+// data key and the payload sealed with the data key
+type record struct {
+	keyID   string
+	wrapped []byte
+	payload []byte
+}
+
+func parseRecord(data []byte) (record, error) {
+	if len(data) < 2 || data[0] != recordVersion {
+		return record{}, ErrDecrypt
+	}
+	n := int(data[1])
+	if len(data) < 2+n+wrappedKeySize {
+		return record{}, ErrDecrypt
+	}
+	return record{
+		keyID:   string(data[2 : 2+n]),
+		wrapped: data[2+n : 2+n+wrappedKeySize],
+		payload: data[2+n+wrappedKeySize:],
+	}, nil
+}
+
+func (r record) bytes() []byte {
+	data := make([]byte, 0, 2+len(r.keyID)+len(r.wrapped)+len(r.payload))
+	data = append(data, recordVersion, byte(len(r.keyID)))
+	data = append(data, r.keyID...)
+	data = append(data, r.wrapped...)
+	return append(data, r.payload...)
+}
+
+// seal encrypts a value under a fresh data key. The payload carries the
+// plaintext key, which blind buckets need to answer range scans.
+func (e *Encrypted) seal(bucket, stored, key string, value []byte) ([]byte, error) {
+	dataKey := make([]byte, KeySize)
+	rand.Read(dataKey)
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := binary.AppendUvarint(nil, uint64(len(key)))
+	plaintext = append(append(plaintext, key...), value...)
+
+	aad := recordAAD(bucket, stored)
+	return record{
+		keyID:   e.keys.primary,
+		wrapped: e.keys.wrap(dataKey, aad),
+		payload: aead.Seal(nil, nil, plaintext, aad),
+	}.bytes(), nil
+}
+
+// open decrypts a record, returning its plaintext key and value
+func (e *Encrypted) open(bucket, stored string, data []byte) (string, []byte, error) {
+	rec, err := parseRecord(data)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w %s/%s", err, bucket, stored)
+	}
+	aad := recordAAD(bucket, stored)
+	dataKey, err := e.keys.unwrap(rec.keyID, rec.wrapped, aad)
+	if errors.Is(err, ErrUnknownKey) {
+		return "", nil, err
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("%w %s/%s", ErrDecrypt, bucket, stored)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", nil, err
+	}
+	plaintext, err := aead.Open(nil, nil, rec.payload, aad)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w %s/%s", ErrDecrypt, bucket, stored)
+	}
+	n, size := binary.Uvarint(plaintext)
+	if size <= 0 || uint64(len(plaintext)-size) < n {
+		return "", nil, fmt.Errorf("%w %s/%s", ErrDecrypt, bucket, stored)
+	}
+	key := string(plaintext[size : size+int(n)])
+	return key, plaintext[size+int(n):], nil
+}
+
+// encryptedTx encrypts and decrypts around a transaction of the wrapped
+// backend
+type encryptedTx struct {
+	e  *Encrypted
+	tx Tx
+}
+
+// hidden reports whether a key is the encryption header
+func hidden(bucket, key string) bool {
+	return bucket == BucketMeta && key == keyringKey
+}
+
+func (t *encryptedTx) Get(bucket, key string) ([]byte, error) {
+	if hidden(bucket, key) {
+		return nil, ErrNotFound
+	}
+	stored := t.e.storedKey(bucket, key)
+	data, err := t.tx.Get(bucket, stored)
+	if err != nil {
+		return nil, err
+	}
+	plainKey, value, err := t.e.open(bucket, stored, data)
+	if err != nil {
+		return nil, err
+	}
+	if plainKey != key {
+		return nil, fmt.Errorf("%w %s/%s: key mismatch", ErrDecrypt, bucket, stored)
+	}
+	return value, nil
+}
+
+func (t *encryptedTx) Put(bucket, key string, value []byte) error {
+	if hidden(bucket, key) {
+		return fmt.Errorf("store: key %q is reserved", key)
+	}
+	stored := t.e.storedKey(bucket, key)
+	if stored == "" || !IsBucket(bucket) {
+		// let the wrapped backend report the error
+		return t.tx.Put(bucket, stored, value)
+	}
+	data, err := t.e.seal(bucket, stored, key, value)
+	if err != nil {
+		return err
+	}
+	return t.tx.Put(bucket, stored, data)
+}
+
+func (t *encryptedTx) Delete(bucket, key string) error {
+	if hidden(bucket, key) {
+		return ErrNotFound
+	}
+	return t.tx.Delete(bucket, t.e.storedKey(bucket, key))
+}
+
+func (t *encryptedTx) Scan(bucket string, r Range, fn func(key string, value []byte) error) error {
+	if !blindBuckets[bucket] && bucket != BucketMeta {
+		return t.tx.Scan(bucket, r, func(key string, data []byte) error {
+			_, value, err := t.e.open(bucket, key, data)
+			if err != nil {
+				return err
+			}
+			return fn(key, value)
+		})
+	}
+
+	// blind keys are not ordered, so decrypt the bucket and sort
+	type entry struct {
+		key   string
+		value []byte
+	}
+	var entries []entry
+	err := t.tx.Scan(bucket, Range{}, func(stored string, data []byte) error {
+		if hidden(bucket, stored) {
+			return nil
+		}
+		key, value, err := t.e.open(bucket, stored, data)
+		if err != nil {
+			return err
+		}
+		if r.Contains(key) {
+			entries = append(entries, entry{key, value})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if r.Reverse {
+			return entries[i].key > entries[j].key
+		}
+		return entries[i].key < entries[j].key
+	})
+	if r.Limit > 0 && len(entries) > r.Limit {
+		entries = entries[:r.Limit]
+	}
+	for _, en := range entries {
+		if err := fn(en.key, en.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
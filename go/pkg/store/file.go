@@ -0,0 +1,402 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	// fileMagic starts every store file
+	fileMagic = "MYUSCIS1"
+	// frameHeaderSize is the length and checksum before each frame
+	frameHeaderSize = 8
+	// maxFrameSize bounds a single frame so a corrupt length cannot cause a
+	// huge allocation
+	maxFrameSize = 64 << 20
+	// compactionFrameSize is the target size of frames written by Compact
+	compactionFrameSize = 1 << 20
+	// minCompactSize is the log size below which File never compacts
+	// automatically
+	minCompactSize = 1 << 20
+)
+
+// ErrCorrupt is returned when a store file cannot be read
+var ErrCorrupt = errors.New("store: file is corrupt")
+
+// logOp is one write in a log frame
+type logOp struct {
+	Bucket  string `json:"b"`
+	Key     string `json:"k"`
+	Value   []byte `json:"v,omitempty"`
+	Deleted bool   `json:"d,omitempty"`
+}
+
+// File is an embedded Backend that keeps its data in memory and appends
+// every committed transaction to a log file. Each transaction is one
+// checksummed frame, so a crash mid-write loses at most that transaction;
+// the torn frame is discarded when the file is next opened. A damaged frame
+// anywhere else fails the open with ErrCorrupt rather than losing the
+// transactions after it. The log is rewritten without overwritten and
+// deleted values once it grows to twice the size of the live data.
+//
+// File is written in pure Go and builds everywhere, including js/wasm. A
+// file must only be opened by one process at a time.
+type File struct {
+	*engine
+	path     string
+	f        *os.File
+	sync     bool
+	size     int64
+	liveSize int64
+}
+
+// FileOption configures a File
+type FileOption func(*File)
+
+// WithSync controls whether every commit is flushed to stable storage
+// before it returns. It is on by default; turning it off trades durability
+// of the latest commits for speed.
+func WithSync(sync bool) FileOption {
+	return func(f *File) {
+		f.sync = sync
+	}
+}
+
+// OpenFile opens or creates the store file at path and loads its contents
+func OpenFile(path string, opts ...FileOption) (*File, error) {
+	s := &File{engine: newEngine(), path: path, sync: true}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to open %s: %w", path, err)
+	}
+	s.f = f
+	if err := s.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	s.engine.commit = s.append
+	s.engine.applied = s.maybeCompact
+	return s, nil
+}
+
+// load replays the log into memory and truncates a torn final frame. A
+// frame that is cut short or fails its checksum is only torn when no intact
+// frame follows it; otherwise its length or contents were damaged and
+// truncating would drop committed transactions.
+func (s *File) load() error {
+	data, err := io.ReadAll(s.f)
+	if err != nil {
+		return fmt.Errorf("store: failed to read %s: %w", s.path, err)
+	}
+	if len(data) == 0 {
+		if _, err := s.f.Write([]byte(fileMagic)); err != nil {
+			return fmt.Errorf("store: failed to initialize %s: %w", s.path, err)
+		}
+		s.size = int64(len(fileMagic))
+		return s.flush()
+	}
+	if len(data) < len(fileMagic) || string(data[:len(fileMagic)]) != fileMagic {
+		return fmt.Errorf("%w: %s is not a store file", ErrCorrupt, s.path)
+	}
+
+	offset := len(fileMagic)
+	for offset < len(data) {
+		ops, n, err := decodeFrame(data[offset:])
+		if err != nil {
+			torn := errors.Is(err, errShortFrame) || offset+n == len(data)
+			if torn && !intactFrameIn(data[offset+1:]) {
+				break
+			}
+			return fmt.Errorf("%w: frame at offset %d of %s: %w", ErrCorrupt, offset, s.path, err)
+		}
+		for _, op := range ops {
+			if !IsBucket(op.Bucket) {
+				return fmt.Errorf("%w: unknown bucket %q in %s", ErrCorrupt, op.Bucket, s.path)
+			}
+			if op.Deleted {
+				delete(s.buckets[op.Bucket], op.Key)
+			} else {
+				s.buckets[op.Bucket][op.Key] = op.Value
+			}
+		}
+		offset += n
+	}
+	if offset < len(data) {
+		if err := s.f.Truncate(int64(offset)); err != nil {
+			return fmt.Errorf("store: failed to discard torn write in %s: %w", s.path, err)
+		}
+	}
+	s.size = int64(offset)
+	s.liveSize = s.measure()
+	if _, err := s.f.Seek(s.size, io.SeekStart); err != nil {
+		return fmt.Errorf("store: failed to seek %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// Frame decoding errors
+var (
+	// errShortFrame is a frame cut off by the end of the file
+	errShortFrame = errors.New("frame is cut short")
+	// errBadFrame is a complete frame whose checksum or contents are wrong
+	errBadFrame = errors.New("frame checksum mismatch")
+)
+
+// decodeFrame parses the frame at the start of data, returning its ops and
+// length. It returns errShortFrame when data ends inside the frame, and
+// errBadFrame with the frame's length when the frame is complete but
+// damaged.
+func decodeFrame(data []byte) ([]logOp, int, error) {
+	if len(data) < frameHeaderSize {
+		return nil, 0, errShortFrame
+	}
+	length := binary.BigEndian.Uint32(data[0:4])
+	sum := binary.BigEndian.Uint32(data[4:8])
+	if length > maxFrameSize {
+		return nil, 0, fmt.Errorf("%w: length %d exceeds the limit", errBadFrame, length)
+	}
+	n := frameHeaderSize + int(length)
+	if len(data) < n {
+		return nil, 0, errShortFrame
+	}
+	payload := data[frameHeaderSize:n]
+	if crc32.ChecksumIEEE(payload) != sum {
+		return nil, n, errBadFrame
+	}
+	var ops []logOp
+	if err := json.Unmarshal(payload, &ops); err != nil {
+		return nil, n, fmt.Errorf("%w: %w", errBadFrame, err)
+	}
+	return ops, n, nil
+}
+
+// intactFrameIn reports whether a complete frame with a good checksum starts
+// anywhere in data
+func intactFrameIn(data []byte) bool {
+	for i := range data {
+		if _, _, err := decodeFrame(data[i:]); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeFrame returns ops as a checksummed frame
+func encodeFrame(ops []logOp) ([]byte, error) {
+	payload, err := json.Marshal(ops)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > maxFrameSize {
+		return nil, fmt.Errorf("store: transaction of %d bytes exceeds the %d byte limit", len(payload), maxFrameSize)
+	}
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(payload))
+	return append(frame, payload...), nil
+}
+
+// append writes a transaction to the log. It runs with the engine locked,
+// before the changes are applied.
+func (s *File) append(c changes) error {
+	var (
+		ops  []logOp
+		live = s.liveSize
+	)
+	for _, bucket := range Buckets() {
+		keys := make([]string, 0, len(c[bucket]))
+		for k := range c[bucket] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			w := c[bucket][k]
+			ops = append(ops, logOp{Bucket: bucket, Key: k, Value: w.value, Deleted: w.deleted})
+			if old, ok := s.buckets[bucket][k]; ok {
+				live -= entrySize(k, old)
+			}
+			if !w.deleted {
+				live += entrySize(k, w.value)
+			}
+		}
+	}
+	frame, err := encodeFrame(ops)
+	if err != nil {
+		return err
+	}
+	if _, err := s.f.Write(frame); err != nil {
+		// drop whatever part of the frame reached the file so later frames
+		// are not stranded behind it
+		_ = s.f.Truncate(s.size)
+		_, _ = s.f.Seek(s.size, io.SeekStart)
+		return fmt.Errorf("store: failed to write %s: %w", s.path, err)
+	}
+	s.size += int64(len(frame))
+	s.liveSize = live
+	return s.flush()
+}
+
+// maybeCompact compacts the log once it is mostly overwritten values. The
+// commit that triggered it is already durable, so a failed compaction is
+// simply retried after a later commit.
+func (s *File) maybeCompact() {
+	if s.size > minCompactSize && s.size > 2*s.liveSize {
+		_ = s.compactLocked()
+	}
+}
+
+// entrySize approximates the space a live key takes in a compacted log
+func entrySize(key string, value []byte) int64 {
+	// base64 grows values by a third; the rest is JSON framing
+	return int64(len(key) + len(value)*4/3 + 24)
+}
+
+// measure computes the live size of the committed data
+func (s *File) measure() int64 {
+	var n int64
+	for _, keys := range s.buckets {
+		for k, v := range keys {
+			n += entrySize(k, v)
+		}
+	}
+	return n
+}
+
+// flush syncs the file when durability is requested
+func (s *File) flush() error {
+	if !s.sync {
+		return nil
+	}
+	if err := s.f.Sync(); err != nil {
+		return fmt.Errorf("store: failed to sync %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// Compact rewrites the log so it holds only live values
+func (s *File) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	return s.compactLocked()
+}
+
+// compactLocked writes the live data to a temporary file and renames it
+// over the log
+func (s *File) compactLocked() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".compact*")
+	if err != nil {
+		return fmt.Errorf("store: failed to compact %s: %w", s.path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	var size int64
+	write := func(b []byte) error {
+		_, err := tmp.Write(b)
+		size += int64(len(b))
+		return err
+	}
+	if err := write([]byte(fileMagic)); err != nil {
+		tmp.Close()
+		return fmt.Errorf("store: failed to compact %s: %w", s.path, err)
+	}
+
+	var (
+		batch     []logOp
+		batchSize int
+	)
+	writeBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		frame, err := encodeFrame(batch)
+		if err != nil {
+			return err
+		}
+		batch, batchSize = batch[:0], 0
+		return write(frame)
+	}
+	for _, bucket := range Buckets() {
+		keys := make([]string, 0, len(s.buckets[bucket]))
+		for k := range s.buckets[bucket] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := s.buckets[bucket][k]
+			batch = append(batch, logOp{Bucket: bucket, Key: k, Value: v})
+			batchSize += len(k) + len(v)
+			if batchSize >= compactionFrameSize {
+				if err := writeBatch(); err != nil {
+					tmp.Close()
+					return fmt.Errorf("store: failed to compact %s: %w", s.path, err)
+				}
+			}
+		}
+	}
+	if err := writeBatch(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("store: failed to compact %s: %w", s.path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("store: failed to compact %s: %w", s.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("store: failed to compact %s: %w", s.path, err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("store: failed to compact %s: %w", s.path, err)
+	}
+
+	f, err := os.OpenFile(s.path, os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("store: failed to reopen %s: %w", s.path, err)
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		f.Close()
+		return fmt.Errorf("store: failed to reopen %s: %w", s.path, err)
+	}
+	s.f.Close()
+	s.f = f
+	s.size = size
+	s.liveSize = s.measure()
+	return nil
+}
+
+// Size returns the current size of the log file in bytes
+func (s *File) Size() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.size
+}
+
+// Close flushes and closes the file; later transactions fail with
+// ErrClosed
+func (s *File) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.buckets = nil
+	if err := s.flush(); err != nil {
+		s.f.Close()
+		return err
+	}
+	return s.f.Close()
+}
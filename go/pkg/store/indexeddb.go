@@ -0,0 +1,477 @@
+//go:build js && wasm
+
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall/js"
+)
+
+// IndexedDBVersion is the IndexedDB schema version. Bump it whenever
+// Buckets changes so the upgrade creates the new object stores; changes to
+// the records themselves belong in a Migration.
+const IndexedDBVersion = 2
+
+// ErrUnavailable is returned when the host has no IndexedDB
+var ErrUnavailable = errors.New("store: IndexedDB is not available")
+
+// IndexedDB is a Backend that keeps each bucket in an IndexedDB object store
+// of the same name, with the record key as the out-of-line key and the
+// value as a Uint8Array.
+//
+// Every method waits for IndexedDB events, which are delivered by the
+// JavaScript event loop, so they must not be called from inside a js.Func
+// callback; run them on their own goroutine, or put a Cache in front.
+//
+// IndexedDB transactions commit as soon as they have no pending requests,
+// so they cannot stay open while Go code runs. Reads in a View therefore
+// each see the latest committed data, and an Update buffers its writes and
+// commits them in a single readwrite transaction once fn returns. Updates
+// from this process are serialized; other tabs sharing the database are
+// not coordinated beyond IndexedDB's own atomicity.
+//
+// Keys are compared as UTF-16 strings by IndexedDB and as bytes by Go,
+// which agree for the ASCII keys the repositories use.
+type IndexedDB struct {
+	name      string
+	db        js.Value
+	mu        sync.Mutex
+	closed    atomic.Bool
+	onVersion js.Func
+}
+
+// OpenIndexedDB opens or creates the named database, creating an object
+// store for every bucket that does not have one yet
+func OpenIndexedDB(ctx context.Context, name string) (*IndexedDB, error) {
+	factory := js.Global().Get("indexedDB")
+	if factory.IsUndefined() || factory.IsNull() {
+		return nil, ErrUnavailable
+	}
+	req, err := call(func() js.Value { return factory.Call("open", name, IndexedDBVersion) })
+	if err != nil {
+		return nil, err
+	}
+
+	upgrade := js.FuncOf(func(this js.Value, args []js.Value) any {
+		db := req.Get("result")
+		for _, bucket := range Buckets() {
+			if !db.Get("objectStoreNames").Call("contains", bucket).Bool() {
+				db.Call("createObjectStore", bucket)
+			}
+		}
+		return nil
+	})
+	req.Set("onupgradeneeded", upgrade)
+	err = listen(ctx, req, "success", nil, "error")
+	req.Set("onupgradeneeded", js.Null())
+	upgrade.Release()
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to open IndexedDB %q: %w", name, err)
+	}
+
+	d := &IndexedDB{name: name, db: req.Get("result")}
+	// let a newer version of the app upgrade the database in another tab
+	d.onVersion = js.FuncOf(func(this js.Value, args []js.Value) any {
+		d.closed.Store(true)
+		d.db.Call("close")
+		return nil
+	})
+	d.db.Set("onversionchange", d.onVersion)
+	return d, nil
+}
+
+// Name returns the database name
+func (d *IndexedDB) Name() string {
+	return d.name
+}
+
+// View runs fn with read access
+func (d *IndexedDB) View(ctx context.Context, fn func(Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d.closed.Load() {
+		return ErrClosed
+	}
+	tx := &idbTx{d: d, ctx: ctx}
+	defer func() { tx.done = true }()
+	return fn(tx)
+}
+
+// Update runs fn and commits its writes in one IndexedDB transaction if fn
+// returns nil. A commit rejected for lack of space fails with
+// ErrQuotaExceeded and leaves the database unchanged.
+func (d *IndexedDB) Update(ctx context.Context, fn func(Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed.Load() {
+		return ErrClosed
+	}
+	tx := &idbTx{d: d, ctx: ctx, writable: true, writes: make(changes)}
+	err := fn(tx)
+	tx.done = true
+	if err != nil {
+		return err
+	}
+	if len(tx.writes) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.commit(tx.writes)
+}
+
+// commit writes changes in a readwrite transaction and waits for it to
+// complete. It is not cancelled by the context: once requests are issued
+// the outcome must be known.
+func (d *IndexedDB) commit(c changes) error {
+	var names []string
+	for _, bucket := range Buckets() {
+		if len(c[bucket]) > 0 {
+			names = append(names, bucket)
+		}
+	}
+	t, err := d.transaction(names, "readwrite")
+	if err != nil {
+		return err
+	}
+	for _, bucket := range names {
+		objects := t.Call("objectStore", bucket)
+		for key, w := range c[bucket] {
+			if w.deleted {
+				_, err = call(func() js.Value { return objects.Call("delete", key) })
+			} else {
+				_, err = call(func() js.Value { return objects.Call("put", toJS(w.value), key) })
+			}
+			if err != nil {
+				_, _ = call(func() js.Value { return t.Call("abort") })
+				return err
+			}
+		}
+	}
+	// a failed request aborts the transaction and sets its error
+	return listen(context.Background(), t, "complete", nil, "abort")
+}
+
+// transaction starts an IndexedDB transaction over buckets
+func (d *IndexedDB) transaction(buckets []string, mode string) (js.Value, error) {
+	if d.closed.Load() {
+		return js.Value{}, ErrClosed
+	}
+	names := make([]any, len(buckets))
+	for i, b := range buckets {
+		names[i] = b
+	}
+	return call(func() js.Value { return d.db.Call("transaction", names, mode) })
+}
+
+// get reads one committed value
+func (d *IndexedDB) get(ctx context.Context, bucket, key string) ([]byte, bool, error) {
+	t, err := d.transaction([]string{bucket}, "readonly")
+	if err != nil {
+		return nil, false, err
+	}
+	req, err := call(func() js.Value { return t.Call("objectStore", bucket).Call("get", key) })
+	if err != nil {
+		return nil, false, err
+	}
+	if err := listen(ctx, req, "success", nil, "error"); err != nil {
+		return nil, false, err
+	}
+	value := req.Get("result")
+	if value.IsUndefined() {
+		return nil, false, nil
+	}
+	return toBytes(value), true, nil
+}
+
+// scan reads the committed keys in r with a cursor. The limit is applied
+// only when set, since pending writes may hide some keys.
+func (d *IndexedDB) scan(ctx context.Context, bucket string, r Range, limit int) (map[string][]byte, error) {
+	lower, upper := r.Start, r.End
+	if r.Prefix > lower {
+		lower = r.Prefix
+	}
+	if end := prefixEnd(r.Prefix); end != "" && (upper == "" || end < upper) {
+		upper = end
+	}
+	if lower != "" && upper != "" && lower >= upper {
+		return nil, nil
+	}
+	t, err := d.transaction([]string{bucket}, "readonly")
+	if err != nil {
+		return nil, err
+	}
+	keyRange := js.Undefined()
+	ranges := js.Global().Get("IDBKeyRange")
+	switch {
+	case lower != "" && upper != "":
+		keyRange = ranges.Call("bound", lower, upper, false, true)
+	case lower != "":
+		keyRange = ranges.Call("lowerBound", lower)
+	case upper != "":
+		keyRange = ranges.Call("upperBound", upper, true)
+	}
+	direction := "next"
+	if r.Reverse {
+		direction = "prev"
+	}
+	req, err := call(func() js.Value {
+		return t.Call("objectStore", bucket).Call("openCursor", keyRange, direction)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string][]byte)
+	err = listen(ctx, req, "success", func() bool {
+		cursor := req.Get("result")
+		if cursor.IsNull() || cursor.IsUndefined() {
+			return true
+		}
+		key := cursor.Get("key").String()
+		if !strings.HasPrefix(key, r.Prefix) && (key < r.Prefix) == r.Reverse {
+			// past the prefix in the scan direction
+			return true
+		}
+		if r.Contains(key) {
+			found[key] = toBytes(cursor.Get("value"))
+			if limit > 0 && len(found) >= limit {
+				return true
+			}
+		}
+		cursor.Call("continue")
+		return false
+	}, "error")
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// prefixEnd returns the smallest key after every key with prefix, or ""
+// when there is no such ASCII key
+func prefixEnd(prefix string) string {
+	if prefix == "" || prefix[len(prefix)-1] >= 0x7f {
+		return ""
+	}
+	return prefix[:len(prefix)-1] + string(prefix[len(prefix)-1]+1)
+}
+
+// Close closes the database; later transactions fail with ErrClosed
+func (d *IndexedDB) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed.Swap(true) {
+		return nil
+	}
+	d.db.Call("close")
+	d.db.Set("onversionchange", js.Null())
+	d.onVersion.Release()
+	return nil
+}
+
+// DeleteIndexedDB deletes the named database. It waits until every open
+// connection to it is closed.
+func DeleteIndexedDB(ctx context.Context, name string) error {
+	factory := js.Global().Get("indexedDB")
+	if factory.IsUndefined() || factory.IsNull() {
+		return ErrUnavailable
+	}
+	req, err := call(func() js.Value { return factory.Call("deleteDatabase", name) })
+	if err != nil {
+		return err
+	}
+	return listen(ctx, req, "success", nil, "error")
+}
+
+// idbTx is a transaction over an IndexedDB. Reads go to the database and
+// writes are buffered until the Update commits.
+type idbTx struct {
+	d        *IndexedDB
+	ctx      context.Context
+	writes   changes
+	writable bool
+	done     bool
+}
+
+func (tx *idbTx) check(bucket string) error {
+	if tx.done {
+		return errTxDone
+	}
+	if !IsBucket(bucket) {
+		return ErrUnknownBucket
+	}
+	return nil
+}
+
+func (tx *idbTx) lookup(bucket, key string) ([]byte, bool, error) {
+	if w, ok := tx.writes[bucket][key]; ok {
+		return w.value, !w.deleted, nil
+	}
+	return tx.d.get(tx.ctx, bucket, key)
+}
+
+func (tx *idbTx) Get(bucket, key string) ([]byte, error) {
+	if err := tx.check(bucket); err != nil {
+		return nil, err
+	}
+	v, ok, err := tx.lookup(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, v...), nil
+}
+
+// overlay returns a memTx that sees the buffered writes on top of base
+func (tx *idbTx) overlay(bucket string, base map[string][]byte) *memTx {
+	return &memTx{
+		base:     map[string]map[string][]byte{bucket: base},
+		writes:   tx.writes,
+		writable: tx.writable,
+	}
+}
+
+func (tx *idbTx) Put(bucket, key string, value []byte) error {
+	if err := tx.check(bucket); err != nil {
+		return err
+	}
+	return tx.overlay(bucket, nil).Put(bucket, key, value)
+}
+
+func (tx *idbTx) Delete(bucket, key string) error {
+	if err := tx.check(bucket); err != nil {
+		return err
+	}
+	if !tx.writable {
+		return ErrReadOnly
+	}
+	_, ok, err := tx.lookup(bucket, key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+	return tx.overlay(bucket, map[string][]byte{key: nil}).Delete(bucket, key)
+}
+
+func (tx *idbTx) Scan(bucket string, r Range, fn func(key string, value []byte) error) error {
+	if err := tx.check(bucket); err != nil {
+		return err
+	}
+	// fn may write during the scan, so only a read-only scan can stop early
+	limit := 0
+	if !tx.writable {
+		limit = r.Limit
+	}
+	base, err := tx.d.scan(tx.ctx, bucket, r, limit)
+	if err != nil {
+		return err
+	}
+	return tx.overlay(bucket, base).Scan(bucket, r, fn)
+}
+
+// listen waits for target to fire the success event, calling done after
+// each one until it reports true, or for one of the failure events. A nil
+// done stops at the first success.
+func listen(ctx context.Context, target js.Value, success string, done func() bool, failures ...string) error {
+	result := make(chan error, 1)
+	finish := func(err error) {
+		select {
+		case result <- err:
+		default:
+		}
+	}
+
+	handlers := map[string]js.Func{
+		success: js.FuncOf(func(this js.Value, args []js.Value) any {
+			if done == nil || done() {
+				finish(nil)
+			}
+			return nil
+		}),
+	}
+	for _, event := range failures {
+		handlers[event] = js.FuncOf(func(this js.Value, args []js.Value) any {
+			var cause js.Value
+			if len(args) > 0 {
+				// errors from a request bubble up to its transaction
+				cause = args[0].Get("target").Get("error")
+			}
+			if cause.IsUndefined() || cause.IsNull() {
+				cause = target.Get("error")
+			}
+			finish(domError(cause))
+			return nil
+		})
+	}
+	for event, fn := range handlers {
+		target.Set("on"+event, fn)
+	}
+	defer func() {
+		for event, fn := range handlers {
+			target.Set("on"+event, js.Null())
+			fn.Release()
+		}
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// domError converts a DOMException into an error
+func domError(v js.Value) error {
+	if v.IsUndefined() || v.IsNull() {
+		return errors.New("store: IndexedDB transaction aborted")
+	}
+	name, message := v.Get("name").String(), v.Get("message").String()
+	if name == "QuotaExceededError" {
+		return fmt.Errorf("%w: %s", ErrQuotaExceeded, message)
+	}
+	return fmt.Errorf("store: IndexedDB %s: %s", name, message)
+}
+
+// call runs fn and turns a JavaScript exception into an error
+func call(fn func() js.Value) (v js.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			jsErr, ok := r.(js.Error)
+			if !ok {
+				panic(r)
+			}
+			err = domError(jsErr.Value)
+		}
+	}()
+	return fn(), nil
+}
+
+// toJS copies a value into a Uint8Array
+func toJS(b []byte) js.Value {
+	array := js.Global().Get("Uint8Array").New(len(b))
+	js.CopyBytesToJS(array, b)
+	return array
+}
+
+// toBytes copies a stored Uint8Array into Go
+func toBytes(v js.Value) []byte {
+	b := make([]byte, v.Get("length").Int())
+	js.CopyBytesToGo(b, v)
+	return b
+}
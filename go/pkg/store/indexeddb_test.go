@@ -0,0 +1,118 @@
+//go:build js && wasm
+
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"syscall/js"
+	"testing"
+
+	"MyUSCISgo/pkg/store"
+	"MyUSCISgo/pkg/store/storetest"
+)
+
+// These tests need an IndexedDB implementation. Under Node, load a polyfill
+// such as fake-indexeddb before the Go runtime:
+//
+//	NODE_OPTIONS="--require fake-indexeddb/auto" GOOS=js GOARCH=wasm \
+//		go test -exec="$(go env GOROOT)/lib/wasm/go_js_wasm_exec" ./pkg/store/
+
+var databases atomic.Int64
+
+// newDatabaseName returns a database name that is deleted after the test
+func newDatabaseName(t *testing.T) string {
+	t.Helper()
+	if v := js.Global().Get("indexedDB"); v.IsUndefined() || v.IsNull() {
+		t.Skip("IndexedDB is not available; load a polyfill to run this test")
+	}
+	name := fmt.Sprintf("storetest-%d", databases.Add(1))
+	t.Cleanup(func() {
+		if err := store.DeleteIndexedDB(context.Background(), name); err != nil {
+			t.Errorf("DeleteIndexedDB() error = %v", err)
+		}
+	})
+	return name
+}
+
+func openIndexedDB(t *testing.T, name string) *store.IndexedDB {
+	t.Helper()
+	db, err := store.OpenIndexedDB(context.Background(), name)
+	if err != nil {
+		t.Fatalf("OpenIndexedDB() error = %v", err)
+	}
+	return db
+}
+
+func TestIndexedDB(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Backend {
+		return openIndexedDB(t, newDatabaseName(t))
+	})
+}
+
+func TestEncryptedIndexedDB(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Backend {
+		return openEncrypted(t, openIndexedDB(t, newDatabaseName(t)), keyring(t, testKey("k1", 1)))
+	})
+}
+
+func TestIndexedDBReopen(t *testing.T) {
+	ctx := context.Background()
+	name := newDatabaseName(t)
+	db := openIndexedDB(t, name)
+	putKeys(t, db, 5, "persisted")
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := openIndexedDB(t, name)
+	defer reopened.Close()
+	want := store.NewMemory()
+	putKeys(t, want, 5, "persisted")
+	if ok, err := storetest.Equal(ctx, reopened, want); err != nil || !ok {
+		t.Errorf("reopened database differs from what was written (err = %v)", err)
+	}
+	if v, err := store.SchemaVersion(ctx, reopened); err != nil || v != 0 {
+		t.Errorf("SchemaVersion() = %d, %v; want 0 before migrating", v, err)
+	}
+}
+
+func TestIndexedDBClosed(t *testing.T) {
+	db := openIndexedDB(t, newDatabaseName(t))
+	db.Close()
+	err := db.View(context.Background(), func(tx store.Tx) error { return nil })
+	if !errors.Is(err, store.ErrClosed) {
+		t.Errorf("View() after Close error = %v, want ErrClosed", err)
+	}
+}
+
+func TestCacheOverIndexedDB(t *testing.T) {
+	ctx := context.Background()
+	name := newDatabaseName(t)
+	c := store.NewCache()
+	putKeys(t, c, 2, "before attach")
+	if err := c.Attach(ctx, openIndexedDB(t, name)); err != nil {
+		t.Fatalf("Attach() error = %v", err)
+	}
+	putKeys(t, c, 1, "after attach")
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	db := openIndexedDB(t, name)
+	defer db.Close()
+	for key, want := range map[string]string{"key-000": "after attach", "key-001": "before attach"} {
+		err := db.View(ctx, func(tx store.Tx) error {
+			v, err := tx.Get(store.BucketCases, key)
+			if err != nil || string(v) != want {
+				return fmt.Errorf("Get(%s) = %q, %v; want %q", key, v, err, want)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
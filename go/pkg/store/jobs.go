@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"MyUSCISgo/pkg/jobs"
+)
+
+// Jobs stores job snapshots so finished jobs stay queryable after a restart
+type Jobs struct {
+	s *Store
+}
+
+// Get returns a job snapshot by ID
+func (r *Jobs) Get(ctx context.Context, id string) (*jobs.Snapshot, error) {
+	var snap *jobs.Snapshot
+	err := r.s.backend.View(ctx, func(tx Tx) error {
+		snap = &jobs.Snapshot{}
+		return getJSON(tx, BucketJobs, id, snap)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// Put inserts or replaces a job snapshot
+func (r *Jobs) Put(ctx context.Context, snapshot jobs.Snapshot) error {
+	if snapshot.ID == "" {
+		return ErrEmptyKey
+	}
+	return r.s.backend.Update(ctx, func(tx Tx) error {
+		return putJSON(tx, BucketJobs, snapshot.ID, snapshot)
+	})
+}
+
+// Delete removes a job snapshot
+func (r *Jobs) Delete(ctx context.Context, id string) error {
+	return r.s.backend.Update(ctx, func(tx Tx) error {
+		return tx.Delete(BucketJobs, id)
+	})
+}
+
+// List returns matching snapshots, oldest first
+func (r *Jobs) List(ctx context.Context, filter jobs.Filter) ([]jobs.Snapshot, error) {
+	var snapshots []jobs.Snapshot
+	err := r.s.backend.View(ctx, func(tx Tx) error {
+		return scanJSON(tx, BucketJobs, Range{}, func(_ string, data []byte) error {
+			var snap jobs.Snapshot
+			if err := json.Unmarshal(data, &snap); err != nil {
+				return err
+			}
+			if filter.Matches(snap) {
+				snapshots = append(snapshots, snap)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(snapshots, func(i, k int) bool {
+		if snapshots[i].CreatedAt.Equal(snapshots[k].CreatedAt) {
+			return snapshots[i].ID < snapshots[k].ID
+		}
+		return snapshots[i].CreatedAt.Before(snapshots[k].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// Purge removes finished jobs that completed more than olderThan ago and
+// returns the number removed
+func (r *Jobs) Purge(ctx context.Context, olderThan time.Duration) (int, error) {
+	cutoff := r.s.clock.Now().Add(-olderThan)
+	removed := 0
+	err := r.s.backend.Update(ctx, func(tx Tx) error {
+		var stale []string
+		err := scanJSON(tx, BucketJobs, Range{}, func(key string, data []byte) error {
+			var snap jobs.Snapshot
+			if err := json.Unmarshal(data, &snap); err != nil {
+				return err
+			}
+			if snap.State.IsTerminal() && snap.FinishedAt != nil && !snap.FinishedAt.After(cutoff) {
+				stale = append(stale, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range stale {
+			if err := tx.Delete(BucketJobs, key); err != nil {
+				return err
+			}
+		}
+		removed = len(stale)
+		return nil
+	})
+	return removed, err
+}
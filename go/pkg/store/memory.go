@@ -0,0 +1,240 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// errTxDone is returned when a transaction is used after its function returned
+var errTxDone = errors.New("store: transaction has finished")
+
+// write is a pending change in a transaction
+type write struct {
+	value   []byte
+	deleted bool
+}
+
+// changes are the writes of a transaction, by bucket and key
+type changes map[string]map[string]write
+
+// engine is the in-memory key-value map shared by Memory and File. Updates
+// are serialized; views run concurrently with each other.
+type engine struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string][]byte
+	closed  bool
+	// commit, if set, persists a transaction's changes before they are
+	// applied; an error aborts the transaction
+	commit func(changes) error
+	// applied, if set, runs after committed changes have been applied
+	applied func()
+}
+
+func newEngine() *engine {
+	e := &engine{buckets: make(map[string]map[string][]byte)}
+	for _, b := range Buckets() {
+		e.buckets[b] = make(map[string][]byte)
+	}
+	return e
+}
+
+// View runs fn in a read-only transaction
+func (e *engine) View(ctx context.Context, fn func(Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		return ErrClosed
+	}
+	tx := &memTx{base: e.buckets}
+	defer func() { tx.done = true }()
+	return fn(tx)
+}
+
+// Update runs fn in a read-write transaction and commits its writes if fn
+// returns nil
+func (e *engine) Update(ctx context.Context, fn func(Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return ErrClosed
+	}
+	tx := &memTx{base: e.buckets, writable: true, writes: make(changes)}
+	err := fn(tx)
+	tx.done = true
+	if err != nil {
+		return err
+	}
+	if len(tx.writes) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if e.commit != nil {
+		if err := e.commit(tx.writes); err != nil {
+			return err
+		}
+	}
+	e.apply(tx.writes)
+	if e.applied != nil {
+		e.applied()
+	}
+	return nil
+}
+
+// apply merges committed changes into the buckets
+func (e *engine) apply(c changes) {
+	for bucket, writes := range c {
+		for key, w := range writes {
+			if w.deleted {
+				delete(e.buckets[bucket], key)
+			} else {
+				e.buckets[bucket][key] = w.value
+			}
+		}
+	}
+}
+
+// memTx is a transaction over an engine
+type memTx struct {
+	base     map[string]map[string][]byte
+	writes   changes
+	writable bool
+	done     bool
+}
+
+func (tx *memTx) check(bucket string) error {
+	if tx.done {
+		return errTxDone
+	}
+	if !IsBucket(bucket) {
+		return ErrUnknownBucket
+	}
+	return nil
+}
+
+// lookup returns the value of a key as seen by the transaction
+func (tx *memTx) lookup(bucket, key string) ([]byte, bool) {
+	if w, ok := tx.writes[bucket][key]; ok {
+		return w.value, !w.deleted
+	}
+	v, ok := tx.base[bucket][key]
+	return v, ok
+}
+
+func (tx *memTx) Get(bucket, key string) ([]byte, error) {
+	if err := tx.check(bucket); err != nil {
+		return nil, err
+	}
+	v, ok := tx.lookup(bucket, key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return bytes.Clone(v), nil
+}
+
+func (tx *memTx) set(bucket, key string, w write) {
+	if tx.writes[bucket] == nil {
+		tx.writes[bucket] = make(map[string]write)
+	}
+	tx.writes[bucket][key] = w
+}
+
+func (tx *memTx) Put(bucket, key string, value []byte) error {
+	if err := tx.check(bucket); err != nil {
+		return err
+	}
+	if !tx.writable {
+		return ErrReadOnly
+	}
+	if key == "" {
+		return ErrEmptyKey
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	tx.set(bucket, key, write{value: bytes.Clone(value)})
+	return nil
+}
+
+func (tx *memTx) Delete(bucket, key string) error {
+	if err := tx.check(bucket); err != nil {
+		return err
+	}
+	if !tx.writable {
+		return ErrReadOnly
+	}
+	if _, ok := tx.lookup(bucket, key); !ok {
+		return ErrNotFound
+	}
+	tx.set(bucket, key, write{deleted: true})
+	return nil
+}
+
+func (tx *memTx) Scan(bucket string, r Range, fn func(key string, value []byte) error) error {
+	if err := tx.check(bucket); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(tx.base[bucket])+len(tx.writes[bucket]))
+	for k := range tx.base[bucket] {
+		if r.Contains(k) {
+			keys = append(keys, k)
+		}
+	}
+	for k, w := range tx.writes[bucket] {
+		if _, inBase := tx.base[bucket][k]; !inBase && !w.deleted && r.Contains(k) {
+			keys = append(keys, k)
+		}
+	}
+	if r.Reverse {
+		sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	} else {
+		sort.Strings(keys)
+	}
+
+	visited := 0
+	for _, k := range keys {
+		if r.Limit > 0 && visited >= r.Limit {
+			break
+		}
+		// fn may have changed the bucket earlier in the scan
+		v, ok := tx.lookup(bucket, k)
+		if !ok {
+			continue
+		}
+		visited++
+		if err := fn(k, bytes.Clone(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Memory is a Backend that keeps everything in memory. It is meant for
+// tests and for callers that do not need persistence.
+type Memory struct {
+	*engine
+}
+
+// NewMemory creates an empty in-memory backend
+func NewMemory() *Memory {
+	return &Memory{engine: newEngine()}
+}
+
+// Close releases the data; later transactions fail with ErrClosed
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	m.buckets = nil
+	return nil
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// schemaVersionKey holds the schema version in the meta bucket
+const schemaVersionKey = "schema_version"
+
+// ErrSchemaTooNew is returned when a backend was written by a newer
+// version of the application than the one opening it
+var ErrSchemaTooNew = errors.New("store: schema is newer than this build supports")
+
+// Migration moves stored data from Version-1 to Version. Each migration runs
+// in its own transaction together with the version bump, so a failed
+// migration leaves the data at the previous version.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx Tx) error
+}
+
+// Migrations returns the built-in migrations in version order
+func Migrations() []Migration {
+	return []Migration{
+		{Version: 1, Name: "initial schema", Up: func(Tx) error { return nil }},
+	}
+}
+
+// SchemaVersion returns the schema version recorded in a backend, or zero
+// for a new backend
+func SchemaVersion(ctx context.Context, backend Backend) (int, error) {
+	var version int
+	err := backend.View(ctx, func(tx Tx) error {
+		var err error
+		version, err = readVersion(tx)
+		return err
+	})
+	return version, err
+}
+
+func readVersion(tx Tx) (int, error) {
+	data, err := tx.Get(BucketMeta, schemaVersionKey)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	version, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0, fmt.Errorf("store: invalid schema version %q", data)
+	}
+	return version, nil
+}
+
+// Migrate runs the migrations newer than the backend's schema version and
+// returns the resulting version. Migrations must be numbered 1, 2, 3 and
+// so on without gaps.
+func Migrate(ctx context.Context, backend Backend, migrations []Migration) (int, error) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return 0, fmt.Errorf("store: migration %q has version %d, want %d", m.Name, m.Version, i+1)
+		}
+		if m.Up == nil {
+			return 0, fmt.Errorf("store: migration %d has no Up function", m.Version)
+		}
+	}
+
+	current, err := SchemaVersion(ctx, backend)
+	if err != nil {
+		return 0, err
+	}
+	if current > len(migrations) {
+		return current, fmt.Errorf("%w: stored version %d, latest known %d", ErrSchemaTooNew, current, len(migrations))
+	}
+
+	for _, m := range migrations[current:] {
+		err := backend.Update(ctx, func(tx Tx) error {
+			// another process may have migrated since the version was read
+			version, err := readVersion(tx)
+			if err != nil {
+				return err
+			}
+			if version >= m.Version {
+				return nil
+			}
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Put(BucketMeta, schemaVersionKey, []byte(strconv.Itoa(m.Version)))
+		})
+		if err != nil {
+			return current, fmt.Errorf("store: migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		current = m.Version
+	}
+	return current, nil
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"MyUSCISgo/pkg/audit"
+	"MyUSCISgo/pkg/jobs"
+	"MyUSCISgo/pkg/types"
+	"MyUSCISgo/pkg/watchlist"
+	"MyUSCISgo/pkg/webhook"
+)
+
+// CaseRepository stores the latest snapshot of each case
+type CaseRepository interface {
+	Get(ctx context.Context, receiptNumber string) (*types.CaseRecord, error)
+	Put(ctx context.Context, record *types.CaseRecord) error
+	Delete(ctx context.Context, receiptNumber string) error
+	List(ctx context.Context) ([]*types.CaseRecord, error)
+}
+
+// TimelineRepository accumulates the status history of each case
+type TimelineRepository interface {
+	Get(ctx context.Context, receiptNumber string) (*types.CaseTimeline, error)
+	Merge(ctx context.Context, timeline *types.CaseTimeline) (int, error)
+	Delete(ctx context.Context, receiptNumber string) error
+}
+
+// TokenRepository tracks issued and revoked tokens
+type TokenRepository interface {
+	Get(ctx context.Context, id string) (*Token, error)
+	Put(ctx context.Context, token *Token) error
+	Revoke(ctx context.Context, id string) error
+	IsRevoked(ctx context.Context, id string) (bool, error)
+	IsValid(ctx context.Context, id string) (bool, error)
+	Purge(ctx context.Context) (int, error)
+}
+
+// JobRepository keeps snapshots of background jobs
+type JobRepository interface {
+	Get(ctx context.Context, id string) (*jobs.Snapshot, error)
+	Put(ctx context.Context, snapshot jobs.Snapshot) error
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context, filter jobs.Filter) ([]jobs.Snapshot, error)
+	Purge(ctx context.Context, olderThan time.Duration) (int, error)
+}
+
+var (
+	_ CaseRepository     = (*Cases)(nil)
+	_ TimelineRepository = (*Timelines)(nil)
+	_ TokenRepository    = (*Tokens)(nil)
+	_ JobRepository      = (*Jobs)(nil)
+	_ watchlist.Storage  = (*Watchlist)(nil)
+	_ audit.Log          = (*AuditLog)(nil)
+	_ webhook.Store      = (*Webhooks)(nil)
+)
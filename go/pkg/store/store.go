@@ -0,0 +1,210 @@
+// Package store persists cases, timelines, tokens, watchlists, jobs, audit
+// entries and webhook deliveries on top of a small ordered key-value Backend, so the same
+// repositories work in memory, in a file and in the browser.
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"MyUSCISgo/pkg/clock"
+)
+
+// Buckets group records of one kind. Backends only accept these names,
+// which lets backends such as IndexedDB declare them up front.
+const (
+	BucketMeta      = "meta"
+	BucketCases     = "cases"
+	BucketTimelines = "timelines"
+	BucketTokens    = "tokens"
+	BucketWatchlist = "watchlist"
+	BucketJobs      = "jobs"
+	BucketAudit     = "audit"
+	BucketWebhooks  = "webhooks"
+)
+
+// Buckets returns every bucket name in a fixed order
+func Buckets() []string {
+	return []string{BucketMeta, BucketCases, BucketTimelines, BucketTokens, BucketWatchlist, BucketJobs, BucketAudit, BucketWebhooks}
+}
+
+// IsBucket reports whether name is a known bucket
+func IsBucket(name string) bool {
+	return slices.Contains(Buckets(), name)
+}
+
+var (
+	// ErrNotFound is returned when a key does not exist
+	ErrNotFound = errors.New("store: not found")
+	// ErrUnknownBucket is returned for a bucket not listed in Buckets
+	ErrUnknownBucket = errors.New("store: unknown bucket")
+	// ErrReadOnly is returned when writing in a View transaction
+	ErrReadOnly = errors.New("store: read-only transaction")
+	// ErrClosed is returned after a backend has been closed
+	ErrClosed = errors.New("store: closed")
+	// ErrEmptyKey is returned when writing an empty key
+	ErrEmptyKey = errors.New("store: empty key")
+	// ErrQuotaExceeded is returned when a backend has no room left for a
+	// commit, such as a browser over its storage quota
+	ErrQuotaExceeded = errors.New("store: storage quota exceeded")
+)
+
+// Range selects keys in a bucket. Start is inclusive and End exclusive;
+// empty bounds are open. Keys are compared as byte strings.
+type Range struct {
+	Prefix  string
+	Start   string
+	End     string
+	Reverse bool
+	// Limit caps the number of keys visited; zero means no limit
+	Limit int
+}
+
+// Contains reports whether key falls inside the range bounds
+func (r Range) Contains(key string) bool {
+	if len(key) < len(r.Prefix) || key[:len(r.Prefix)] != r.Prefix {
+		return false
+	}
+	if r.Start != "" && key < r.Start {
+		return false
+	}
+	if r.End != "" && key >= r.End {
+		return false
+	}
+	return true
+}
+
+// Tx reads and writes a backend inside a transaction. Writes are visible to
+// later reads in the same transaction and to no one else until it commits.
+// Values passed to and returned from a Tx are copies.
+type Tx interface {
+	Get(bucket, key string) ([]byte, error)
+	Put(bucket, key string, value []byte) error
+	Delete(bucket, key string) error
+	// Scan calls fn for each key in r in key order, or reverse order when
+	// r.Reverse is set. An error from fn stops the scan and is returned.
+	Scan(bucket string, r Range, fn func(key string, value []byte) error) error
+}
+
+// Backend is an ordered key-value store with transactions. Update commits
+// when fn returns nil and discards every write otherwise.
+type Backend interface {
+	View(ctx context.Context, fn func(Tx) error) error
+	Update(ctx context.Context, fn func(Tx) error) error
+	Close() error
+}
+
+// Store gives typed access to the records kept in a backend
+type Store struct {
+	backend Backend
+	clock   clock.Clock
+	extra   []Migration
+}
+
+// Option configures a Store
+type Option func(*Store)
+
+// WithClock sets the clock used for timestamps and expiry checks
+func WithClock(c clock.Clock) Option {
+	return func(s *Store) {
+		s.clock = c
+	}
+}
+
+// WithMigrations appends migrations after the built-in ones
+func WithMigrations(migrations ...Migration) Option {
+	return func(s *Store) {
+		s.extra = append(s.extra, migrations...)
+	}
+}
+
+// Open wraps a backend and migrates it to the latest schema version
+func Open(ctx context.Context, backend Backend, opts ...Option) (*Store, error) {
+	s := &Store{backend: backend, clock: clock.Real()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if _, err := Migrate(ctx, backend, append(Migrations(), s.extra...)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Backend returns the underlying backend
+func (s *Store) Backend() Backend {
+	return s.backend
+}
+
+// Close closes the backend
+func (s *Store) Close() error {
+	return s.backend.Close()
+}
+
+// Cases returns the case record repository
+func (s *Store) Cases() *Cases {
+	return &Cases{s: s}
+}
+
+// Timelines returns the case timeline repository
+func (s *Store) Timelines() *Timelines {
+	return &Timelines{s: s}
+}
+
+// Tokens returns the token repository
+func (s *Store) Tokens() *Tokens {
+	return &Tokens{s: s}
+}
+
+// Watchlist returns the watchlist repository
+func (s *Store) Watchlist() *Watchlist {
+	return &Watchlist{s: s}
+}
+
+// Jobs returns the job snapshot repository
+func (s *Store) Jobs() *Jobs {
+	return &Jobs{s: s}
+}
+
+// Audit returns the audit log repository
+func (s *Store) Audit() *AuditLog {
+	return &AuditLog{s: s}
+}
+
+// Webhooks returns the webhook delivery repository
+func (s *Store) Webhooks() *Webhooks {
+	return &Webhooks{s: s}
+}
+
+// getJSON decodes the value stored under key
+func getJSON(tx Tx, bucket, key string, v any) error {
+	data, err := tx.Get(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("store: failed to decode %s/%s: %w", bucket, key, err)
+	}
+	return nil
+}
+
+// putJSON encodes v and stores it under key
+func putJSON(tx Tx, bucket, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("store: failed to encode %s/%s: %w", bucket, key, err)
+	}
+	return tx.Put(bucket, key, data)
+}
+
+// scanJSON decodes every value in a range with decode
+func scanJSON(tx Tx, bucket string, r Range, decode func(key string, data []byte) error) error {
+	return tx.Scan(bucket, r, func(key string, data []byte) error {
+		if err := decode(key, data); err != nil {
+			return fmt.Errorf("store: failed to decode %s/%s: %w", bucket, key, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,626 @@
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"MyUSCISgo/pkg/store"
+	"MyUSCISgo/pkg/store/storetest"
+)
+
+func TestMemory(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Backend {
+		return store.NewMemory()
+	})
+}
+
+func TestFile(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Backend {
+		f, err := store.OpenFile(filepath.Join(t.TempDir(), "store.db"), store.WithSync(false))
+		if err != nil {
+			t.Fatalf("OpenFile() error = %v", err)
+		}
+		return f
+	})
+}
+
+func TestCache(t *testing.T) {
+	t.Run("Unattached", func(t *testing.T) {
+		storetest.Run(t, func(t *testing.T) store.Backend {
+			return store.NewCache()
+		})
+	})
+	t.Run("Attached", func(t *testing.T) {
+		storetest.Run(t, func(t *testing.T) store.Backend {
+			c := store.NewCache()
+			if err := c.Attach(context.Background(), store.NewMemory()); err != nil {
+				t.Fatalf("Attach() error = %v", err)
+			}
+			return c
+		})
+	})
+}
+
+func TestCacheAttach(t *testing.T) {
+	ctx := context.Background()
+	target := store.NewMemory()
+	putKeys(t, target, 3, "persisted")
+
+	c := store.NewCache()
+	defer c.Close()
+	err := c.Update(ctx, func(tx store.Tx) error {
+		if err := tx.Put(store.BucketCases, "key-001", []byte("cached")); err != nil {
+			return err
+		}
+		return tx.Put(store.BucketTokens, "session", []byte("cached"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Attach(ctx, target); err != nil {
+		t.Fatalf("Attach() error = %v", err)
+	}
+	if err := c.Attach(ctx, store.NewMemory()); !errors.Is(err, store.ErrAttached) {
+		t.Errorf("second Attach() error = %v, want ErrAttached", err)
+	}
+
+	err = c.Update(ctx, func(tx store.Tx) error {
+		if err := tx.Delete(store.BucketCases, "key-002"); err != nil {
+			return err
+		}
+		return tx.Put(store.BucketCases, "key-100", []byte("new"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	want := map[string]string{
+		"cases/key-000":  "persisted",
+		"cases/key-001":  "persisted",
+		"cases/key-100":  "new",
+		"tokens/session": "cached",
+		"cases/key-002":  "",
+	}
+	for _, b := range []store.Backend{c, target} {
+		for path, w := range want {
+			bucket, key, _ := strings.Cut(path, "/")
+			err := b.View(ctx, func(tx store.Tx) error {
+				v, err := tx.Get(bucket, key)
+				if w == "" {
+					if !errors.Is(err, store.ErrNotFound) {
+						return fmt.Errorf("Get(%s) error = %v, want ErrNotFound", path, err)
+					}
+					return nil
+				}
+				if err != nil || string(v) != w {
+					return fmt.Errorf("Get(%s) = %q, %v; want %q", path, v, err, w)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Errorf("%T: %v", b, err)
+			}
+		}
+	}
+	if ok, err := storetest.Equal(ctx, c, target); err != nil || !ok {
+		t.Errorf("cache and target differ after Flush (err = %v)", err)
+	}
+}
+
+// failingBackend rejects every commit
+type failingBackend struct {
+	*store.Memory
+	err error
+}
+
+func (b failingBackend) Update(ctx context.Context, fn func(store.Tx) error) error {
+	return b.err
+}
+
+func TestCacheWriteError(t *testing.T) {
+	ctx := context.Background()
+	var reported []error
+	c := store.NewCache(store.OnWriteError(func(err error) {
+		reported = append(reported, err)
+	}))
+	if err := c.Attach(ctx, failingBackend{store.NewMemory(), store.ErrQuotaExceeded}); err != nil {
+		t.Fatal(err)
+	}
+	putKeys(t, c, 1, "kept in memory")
+	if err := c.Flush(ctx); !errors.Is(err, store.ErrQuotaExceeded) {
+		t.Errorf("Flush() error = %v, want ErrQuotaExceeded", err)
+	}
+	if len(reported) != 1 {
+		t.Errorf("OnWriteError called %d times, want 1", len(reported))
+	}
+	if err := c.Flush(ctx); err != nil {
+		t.Errorf("second Flush() error = %v, want nil once reported", err)
+	}
+	err := c.View(ctx, func(tx store.Tx) error {
+		_, err := tx.Get(store.BucketCases, "key-000")
+		return err
+	})
+	if err != nil {
+		t.Errorf("Get() after failed write-through error = %v", err)
+	}
+}
+
+func putKeys(t *testing.T, b store.Backend, n int, value string) {
+	t.Helper()
+	err := b.Update(context.Background(), func(tx store.Tx) error {
+		for i := 0; i < n; i++ {
+			if err := tx.Put(store.BucketCases, fmt.Sprintf("key-%03d", i), []byte(value)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+}
+
+func TestFileReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.db")
+	f, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putKeys(t, f, 10, "v1")
+	err = f.Update(ctx, func(tx store.Tx) error {
+		return tx.Delete(store.BucketCases, "key-003")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := store.NewMemory()
+	putKeys(t, want, 10, "v1")
+	_ = want.Update(ctx, func(tx store.Tx) error { return tx.Delete(store.BucketCases, "key-003") })
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	reopened, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile() reopen error = %v", err)
+	}
+	defer reopened.Close()
+	if ok, err := storetest.Equal(ctx, reopened, want); err != nil || !ok {
+		t.Errorf("reopened file differs from what was written (err = %v)", err)
+	}
+}
+
+func TestFileTornWrite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.db")
+	f, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putKeys(t, f, 3, "committed")
+	size := f.Size()
+	putKeys(t, f, 1, "torn")
+	f.Close()
+
+	// cut the last frame in half, as a crash mid-write would
+	full, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, full[:size+(int64(len(full))-size)/2], 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	if reopened.Size() != size {
+		t.Errorf("Size() = %d, want the torn frame dropped (%d)", reopened.Size(), size)
+	}
+	err = reopened.View(ctx, func(tx store.Tx) error {
+		v, err := tx.Get(store.BucketCases, "key-000")
+		if err != nil || string(v) != "committed" {
+			return fmt.Errorf("Get(key-000) = %q, %v; want the committed value", v, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// the next commit must be readable after the truncated tail
+	putKeys(t, reopened, 1, "after")
+	reopened.Close()
+	again, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer again.Close()
+	err = again.View(ctx, func(tx store.Tx) error {
+		v, err := tx.Get(store.BucketCases, "key-000")
+		if err != nil || string(v) != "after" {
+			return fmt.Errorf("Get(key-000) = %q, %v; want after", v, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFileCorruptFrame(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	f, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putKeys(t, f, 1, "first")
+	first := f.Size()
+	putKeys(t, f, 1, "second")
+	second := f.Size()
+	putKeys(t, f, 1, "third")
+	f.Close()
+	full, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a damaged frame followed by committed ones is not a torn write, even
+	// when its damaged length runs past the end of the file
+	for _, at := range []struct {
+		name   string
+		offset int64
+	}{
+		{"payload", first + 10},
+		{"length", first + 1},
+	} {
+		damaged := bytes.Clone(full)
+		damaged[at.offset] ^= 0xff
+		if err := os.WriteFile(path, damaged, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.OpenFile(path); !errors.Is(err, store.ErrCorrupt) {
+			t.Fatalf("OpenFile() with a damaged frame %s error = %v, want ErrCorrupt", at.name, err)
+		}
+		if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, damaged) {
+			t.Errorf("OpenFile() changed a file with a damaged frame %s (err = %v)", at.name, err)
+		}
+	}
+
+	// the same damage in the final frame is discarded like a torn write
+	damaged := bytes.Clone(full)
+	damaged[second+10] ^= 0xff
+	if err := os.WriteFile(path, damaged, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile() with a damaged final frame error = %v", err)
+	}
+	defer reopened.Close()
+	if reopened.Size() != second {
+		t.Errorf("Size() = %d, want the damaged final frame dropped (%d)", reopened.Size(), second)
+	}
+}
+
+func TestFileCompact(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.db")
+	f, err := store.OpenFile(path, store.WithSync(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		putKeys(t, f, 50, strings.Repeat("x", i+1))
+	}
+	before := f.Size()
+	if err := f.Compact(); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	if after := f.Size(); after >= before/4 {
+		t.Errorf("Size() after Compact = %d, want well below %d", after, before)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != f.Size() {
+		t.Errorf("file size = %v, %v; want %d", info.Size(), err, f.Size())
+	}
+
+	// writes after compaction go to the new file
+	putKeys(t, f, 1, "final")
+	f.Close()
+	reopened, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	want := map[string]string{"key-000": "final", "key-049": strings.Repeat("x", 20)}
+	err = reopened.View(ctx, func(tx store.Tx) error {
+		for k, w := range want {
+			v, err := tx.Get(store.BucketCases, k)
+			if err != nil || string(v) != w {
+				return fmt.Errorf("Get(%s) = %q, %v; want %q", k, v, err, w)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFileAutoCompact(t *testing.T) {
+	f, err := store.OpenFile(filepath.Join(t.TempDir(), "store.db"), store.WithSync(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	value := strings.Repeat("y", 4096)
+	for i := 0; i < 600; i++ {
+		putKeys(t, f, 1, value)
+	}
+	// 600 overwrites of one 4 KiB value would be ~3.3 MiB without compaction
+	if size := f.Size(); size > 2<<20 {
+		t.Errorf("Size() = %d, want automatic compaction to keep the log small", size)
+	}
+}
+
+func TestFileRejectsForeignFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	if err := os.WriteFile(path, []byte("not a store file"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.OpenFile(path); !errors.Is(err, store.ErrCorrupt) {
+		t.Errorf("OpenFile() error = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestRange(t *testing.T) {
+	tests := []struct {
+		r    store.Range
+		key  string
+		want bool
+	}{
+		{store.Range{}, "anything", true},
+		{store.Range{Prefix: "a/"}, "a/1", true},
+		{store.Range{Prefix: "a/"}, "a", false},
+		{store.Range{Start: "b"}, "b", true},
+		{store.Range{Start: "b"}, "a", false},
+		{store.Range{End: "b"}, "b", false},
+		{store.Range{End: "b"}, "az", true},
+	}
+	for _, tt := range tests {
+		if got := tt.r.Contains(tt.key); got != tt.want {
+			t.Errorf("%+v.Contains(%q) = %v, want %v", tt.r, tt.key, got, tt.want)
+		}
+	}
+}
+
+// testKey returns key material filled with b
+func testKey(id string, b byte) store.Key {
+	return store.Key{ID: id, Material: bytes.Repeat([]byte{b}, store.KeySize)}
+}
+
+func keyring(t *testing.T, primary store.Key, previous ...store.Key) *store.Keyring {
+	t.Helper()
+	keys, err := store.NewKeyring(primary, previous...)
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	return keys
+}
+
+func openEncrypted(t *testing.T, b store.Backend, keys *store.Keyring, opts ...store.EncryptOption) *store.Encrypted {
+	t.Helper()
+	e, err := store.OpenEncrypted(context.Background(), b, keys, opts...)
+	if err != nil {
+		t.Fatalf("OpenEncrypted() error = %v", err)
+	}
+	return e
+}
+
+// rawRecords returns the values of a bucket as the wrapped backend stores them
+func rawRecords(t *testing.T, b store.Backend, bucket string) map[string][]byte {
+	t.Helper()
+	records := make(map[string][]byte)
+	err := b.View(context.Background(), func(tx store.Tx) error {
+		return tx.Scan(bucket, store.Range{}, func(key string, value []byte) error {
+			records[key] = value
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestEncrypted(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Backend {
+		return openEncrypted(t, store.NewMemory(), keyring(t, testKey("k1", 1)))
+	})
+}
+
+func TestEncryptedAtRest(t *testing.T) {
+	ctx := context.Background()
+	raw := store.NewMemory()
+	e := openEncrypted(t, raw, keyring(t, testKey("k1", 1)))
+	putKeys(t, e, 3, "A123456789")
+	err := e.Update(ctx, func(tx store.Tx) error {
+		return tx.Put(store.BucketAudit, "2025-03-01/a", []byte("A123456789"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bucket := range store.Buckets() {
+		for key, value := range rawRecords(t, raw, bucket) {
+			if strings.Contains(key, "key-") || bytes.Contains(value, []byte("A123456789")) {
+				t.Errorf("%s/%s is stored in the clear", bucket, key)
+			}
+		}
+	}
+	if _, ok := rawRecords(t, raw, store.BucketAudit)["2025-03-01/a"]; !ok {
+		t.Errorf("audit keys should stay ordered and readable")
+	}
+
+	reopened := openEncrypted(t, raw, keyring(t, testKey("k1", 1)))
+	err = reopened.View(ctx, func(tx store.Tx) error {
+		value, err := tx.Get(store.BucketCases, "key-001")
+		if err == nil && string(value) != "A123456789" {
+			err = fmt.Errorf("Get() = %q", value)
+		}
+		return err
+	})
+	if err != nil {
+		t.Errorf("reading after reopen: %v", err)
+	}
+}
+
+func TestEncryptedRotate(t *testing.T) {
+	ctx := context.Background()
+	raw := store.NewMemory()
+	old, current := testKey("k1", 1), testKey("k2", 2)
+	putKeys(t, openEncrypted(t, raw, keyring(t, old)), 5, "value")
+	before := rawRecords(t, raw, store.BucketCases)
+
+	if _, err := store.OpenEncrypted(ctx, raw, keyring(t, current)); !errors.Is(err, store.ErrWrongKey) {
+		t.Fatalf("OpenEncrypted() with a new key error = %v, want ErrWrongKey", err)
+	}
+	e := openEncrypted(t, raw, keyring(t, current, old))
+	n, err := e.Rotate(ctx)
+	if err != nil || n != 5 {
+		t.Fatalf("Rotate() = %d, %v; want 5", n, err)
+	}
+	if n, err := e.Rotate(ctx); err != nil || n != 0 {
+		t.Errorf("second Rotate() = %d, %v; want 0", n, err)
+	}
+
+	// records keep their payload: only the wrapped data key changes
+	const header = 2 + 2 + 12 + store.KeySize + 16
+	for key, value := range rawRecords(t, raw, store.BucketCases) {
+		if !bytes.Equal(value[header:], before[key][header:]) {
+			t.Errorf("payload of %s was rewritten", key)
+		}
+		if bytes.Equal(value[:header], before[key][:header]) {
+			t.Errorf("data key of %s was not rewrapped", key)
+		}
+	}
+
+	if _, err := store.OpenEncrypted(ctx, raw, keyring(t, old)); !errors.Is(err, store.ErrWrongKey) {
+		t.Errorf("OpenEncrypted() with the retired key error = %v, want ErrWrongKey", err)
+	}
+	rotated := openEncrypted(t, raw, keyring(t, current))
+	err = rotated.View(ctx, func(tx store.Tx) error {
+		return tx.Scan(store.BucketCases, store.Range{}, func(string, []byte) error { return nil })
+	})
+	if err != nil {
+		t.Errorf("reading with only the new key: %v", err)
+	}
+}
+
+func TestEncryptedTamper(t *testing.T) {
+	ctx := context.Background()
+	keys := keyring(t, testKey("k1", 1))
+	scan := func(e *store.Encrypted) error {
+		return e.View(ctx, func(tx store.Tx) error {
+			return tx.Scan(store.BucketCases, store.Range{}, func(string, []byte) error { return nil })
+		})
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(records map[string][]byte, tx store.Tx) error
+		want   error
+	}{
+		{"flipped bit", func(records map[string][]byte, tx store.Tx) error {
+			for key, value := range records {
+				value[len(value)-1] ^= 1
+				return tx.Put(store.BucketCases, key, value)
+			}
+			return nil
+		}, store.ErrDecrypt},
+		{"swapped records", func(records map[string][]byte, tx store.Tx) error {
+			var keys []string
+			for key := range records {
+				keys = append(keys, key)
+			}
+			return tx.Put(store.BucketCases, keys[0], records[keys[1]])
+		}, store.ErrDecrypt},
+		{"unknown key", func(records map[string][]byte, tx store.Tx) error {
+			for key, value := range records {
+				value[3] = 'x' // the key ID "k1" becomes "kx"
+				return tx.Put(store.BucketCases, key, value)
+			}
+			return nil
+		}, store.ErrUnknownKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := store.NewMemory()
+			e := openEncrypted(t, raw, keys)
+			putKeys(t, e, 2, "value")
+			records := rawRecords(t, raw, store.BucketCases)
+			if err := raw.Update(ctx, func(tx store.Tx) error { return tt.tamper(records, tx) }); err != nil {
+				t.Fatal(err)
+			}
+			if err := scan(e); !errors.Is(err, tt.want) {
+				t.Errorf("Scan() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncryptExisting(t *testing.T) {
+	ctx := context.Background()
+	raw, want := store.NewMemory(), store.NewMemory()
+	for _, b := range []store.Backend{raw, want} {
+		putKeys(t, b, 4, "plaintext")
+		if _, err := store.Migrate(ctx, b, store.Migrations()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys := keyring(t, testKey("k1", 1))
+	if _, err := store.OpenEncrypted(ctx, raw, keys); !errors.Is(err, store.ErrNotEncrypted) {
+		t.Fatalf("OpenEncrypted() error = %v, want ErrNotEncrypted", err)
+	}
+	e := openEncrypted(t, raw, keys, store.EncryptExisting())
+	if e.Migrated() != 5 {
+		t.Errorf("Migrated() = %d, want 4 cases and the schema version", e.Migrated())
+	}
+	if equal, err := storetest.Equal(ctx, e, want); err != nil || !equal {
+		t.Errorf("Equal() = %v, %v; want the plaintext data back", equal, err)
+	}
+	for key, value := range rawRecords(t, raw, store.BucketCases) {
+		if strings.HasPrefix(key, "key-") || bytes.Contains(value, []byte("plaintext")) {
+			t.Errorf("%s was left unencrypted", key)
+		}
+	}
+}
+
+func TestNewKeyring(t *testing.T) {
+	tests := []struct {
+		name     string
+		primary  store.Key
+		previous []store.Key
+	}{
+		{"no ID", store.Key{Material: make([]byte, store.KeySize)}, nil},
+		{"short key", store.Key{ID: "k1", Material: make([]byte, 16)}, nil},
+		{"duplicate ID", testKey("k1", 1), []store.Key{testKey("k1", 2)}},
+	}
+	for _, tt := range tests {
+		if _, err := store.NewKeyring(tt.primary, tt.previous...); err == nil {
+			t.Errorf("%s: NewKeyring() succeeded", tt.name)
+		}
+	}
+}
@@ -0,0 +1,772 @@
+// Package storetest is the conformance suite every store.Backend must pass.
+// Backend packages call Run from their tests with a function that opens an
+// empty backend.
+package storetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"MyUSCISgo/pkg/audit"
+	"MyUSCISgo/pkg/changes"
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/jobs"
+	"MyUSCISgo/pkg/store"
+	"MyUSCISgo/pkg/types"
+	"MyUSCISgo/pkg/watchlist"
+	"MyUSCISgo/pkg/webhook"
+)
+
+// Opener returns a new, empty backend. The suite closes it.
+type Opener func(t *testing.T) store.Backend
+
+// Run runs the backend and repository conformance tests
+func Run(t *testing.T, open Opener) {
+	t.Run("Backend", func(t *testing.T) { RunBackend(t, open) })
+	t.Run("Repositories", func(t *testing.T) { RunRepositories(t, open) })
+}
+
+// RunBackend tests the key-value semantics of a backend
+func RunBackend(t *testing.T, open Opener) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, b store.Backend)
+	}{
+		{"GetPutDelete", testGetPutDelete},
+		{"InvalidWrites", testInvalidWrites},
+		{"ReadOnlyView", testReadOnlyView},
+		{"Rollback", testRollback},
+		{"ReadYourWrites", testReadYourWrites},
+		{"ValuesAreCopies", testValuesAreCopies},
+		{"Scan", testScan},
+		{"ScanSeesPendingWrites", testScanSeesPendingWrites},
+		{"CancelledContext", testCancelledContext},
+		{"Close", testClose},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := open(t)
+			defer b.Close()
+			tt.fn(t, b)
+		})
+	}
+}
+
+func put(t *testing.T, b store.Backend, bucket string, kv ...string) {
+	t.Helper()
+	err := b.Update(context.Background(), func(tx store.Tx) error {
+		for i := 0; i+1 < len(kv); i += 2 {
+			if err := tx.Put(bucket, kv[i], []byte(kv[i+1])); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+}
+
+func get(t *testing.T, b store.Backend, bucket, key string) (string, error) {
+	t.Helper()
+	var value []byte
+	err := b.View(context.Background(), func(tx store.Tx) error {
+		var err error
+		value, err = tx.Get(bucket, key)
+		return err
+	})
+	return string(value), err
+}
+
+func scan(t *testing.T, b store.Backend, bucket string, r store.Range) []string {
+	t.Helper()
+	var keys []string
+	err := b.View(context.Background(), func(tx store.Tx) error {
+		return tx.Scan(bucket, r, func(key string, value []byte) error {
+			keys = append(keys, key+"="+string(value))
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	return keys
+}
+
+func testGetPutDelete(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	if _, err := get(t, b, store.BucketCases, "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+
+	put(t, b, store.BucketCases, "a", "1", "b", "")
+	put(t, b, store.BucketTimelines, "a", "other bucket")
+	if v, err := get(t, b, store.BucketCases, "a"); err != nil || v != "1" {
+		t.Errorf("Get(a) = %q, %v; want 1", v, err)
+	}
+	if v, err := get(t, b, store.BucketCases, "b"); err != nil || v != "" {
+		t.Errorf("Get(b) = %q, %v; want empty value", v, err)
+	}
+
+	put(t, b, store.BucketCases, "a", "2")
+	if v, _ := get(t, b, store.BucketCases, "a"); v != "2" {
+		t.Errorf("Get(a) after overwrite = %q, want 2", v)
+	}
+
+	err := b.Update(ctx, func(tx store.Tx) error {
+		return tx.Delete(store.BucketCases, "a")
+	})
+	if err != nil {
+		t.Fatalf("Delete(a) error = %v", err)
+	}
+	if _, err := get(t, b, store.BucketCases, "a"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get(a) after delete error = %v, want ErrNotFound", err)
+	}
+	if v, _ := get(t, b, store.BucketTimelines, "a"); v != "other bucket" {
+		t.Errorf("delete leaked into another bucket: %q", v)
+	}
+
+	err = b.Update(ctx, func(tx store.Tx) error {
+		return tx.Delete(store.BucketCases, "a")
+	})
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Delete(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testInvalidWrites(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	err := b.Update(ctx, func(tx store.Tx) error {
+		return tx.Put("no-such-bucket", "k", []byte("v"))
+	})
+	if !errors.Is(err, store.ErrUnknownBucket) {
+		t.Errorf("Put(unknown bucket) error = %v, want ErrUnknownBucket", err)
+	}
+	err = b.View(ctx, func(tx store.Tx) error {
+		_, err := tx.Get("no-such-bucket", "k")
+		return err
+	})
+	if !errors.Is(err, store.ErrUnknownBucket) {
+		t.Errorf("Get(unknown bucket) error = %v, want ErrUnknownBucket", err)
+	}
+	err = b.Update(ctx, func(tx store.Tx) error {
+		return tx.Put(store.BucketCases, "", []byte("v"))
+	})
+	if !errors.Is(err, store.ErrEmptyKey) {
+		t.Errorf("Put(empty key) error = %v, want ErrEmptyKey", err)
+	}
+}
+
+func testReadOnlyView(t *testing.T, b store.Backend) {
+	ctx := context.Background()
+	put(t, b, store.BucketCases, "a", "1")
+	err := b.View(ctx, func(tx store.Tx) error {
+		return tx.Put(store.BucketCases, "b", []byte("2"))
+	})
+	if !errors.Is(err, store.ErrReadOnly) {
+		t.Errorf("Put in View error = %v, want ErrReadOnly", err)
+	}
+	err = b.View(ctx, func(tx store.Tx) error {
+		return tx.Delete(store.BucketCases, "a")
+	})
+	if !errors.Is(err, store.ErrReadOnly) {
+		t.Errorf("Delete in View error = %v, want ErrReadOnly", err)
+	}
+	if _, err := get(t, b, store.BucketCases, "a"); err != nil {
+		t.Errorf("Get(a) after read-only delete error = %v", err)
+	}
+}
+
+func testRollback(t *testing.T, b store.Backend) {
+	put(t, b, store.BucketCases, "keep", "1")
+	failure := errors.New("abort")
+	err := b.Update(context.Background(), func(tx store.Tx) error {
+		if err := tx.Put(store.BucketCases, "new", []byte("x")); err != nil {
+			return err
+		}
+		if err := tx.Put(store.BucketCases, "keep", []byte("changed")); err != nil {
+			return err
+		}
+		if err := tx.Delete(store.BucketCases, "keep"); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Update() error = %v, want the function's error", err)
+	}
+	if _, err := get(t, b, store.BucketCases, "new"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("rolled back Put is visible: %v", err)
+	}
+	if v, err := get(t, b, store.BucketCases, "keep"); err != nil || v != "1" {
+		t.Errorf("Get(keep) = %q, %v; want 1 after rollback", v, err)
+	}
+}
+
+func testReadYourWrites(t *testing.T, b store.Backend) {
+	put(t, b, store.BucketCases, "a", "1")
+	err := b.Update(context.Background(), func(tx store.Tx) error {
+		if err := tx.Put(store.BucketCases, "a", []byte("2")); err != nil {
+			return err
+		}
+		if v, err := tx.Get(store.BucketCases, "a"); err != nil || string(v) != "2" {
+			return fmt.Errorf("Get(a) in tx = %q, %v; want 2", v, err)
+		}
+		if err := tx.Delete(store.BucketCases, "a"); err != nil {
+			return err
+		}
+		if _, err := tx.Get(store.BucketCases, "a"); !errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("Get(a) after delete in tx error = %v, want ErrNotFound", err)
+		}
+		return tx.Put(store.BucketCases, "a", []byte("3"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := get(t, b, store.BucketCases, "a"); v != "3" {
+		t.Errorf("Get(a) = %q, want 3", v)
+	}
+}
+
+func testValuesAreCopies(t *testing.T, b store.Backend) {
+	value := []byte("original")
+	err := b.Update(context.Background(), func(tx store.Tx) error {
+		if err := tx.Put(store.BucketCases, "a", value); err != nil {
+			return err
+		}
+		copy(value, "mutated!")
+		got, err := tx.Get(store.BucketCases, "a")
+		if err != nil {
+			return err
+		}
+		copy(got, "mutated!")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := get(t, b, store.BucketCases, "a"); v != "original" {
+		t.Errorf("stored value was aliased: %q", v)
+	}
+}
+
+func testScan(t *testing.T, b store.Backend) {
+	put(t, b, store.BucketAudit, "b/2", "4", "a/1", "1", "b/1", "3", "a/2", "2", "c", "5")
+	put(t, b, store.BucketJobs, "a/0", "other")
+
+	tests := []struct {
+		name string
+		r    store.Range
+		want []string
+	}{
+		{"all", store.Range{}, []string{"a/1=1", "a/2=2", "b/1=3", "b/2=4", "c=5"}},
+		{"prefix", store.Range{Prefix: "b/"}, []string{"b/1=3", "b/2=4"}},
+		{"start inclusive", store.Range{Start: "a/2"}, []string{"a/2=2", "b/1=3", "b/2=4", "c=5"}},
+		{"end exclusive", store.Range{End: "b/1"}, []string{"a/1=1", "a/2=2"}},
+		{"bounds", store.Range{Start: "a/2", End: "b/2"}, []string{"a/2=2", "b/1=3"}},
+		{"reverse", store.Range{Reverse: true}, []string{"c=5", "b/2=4", "b/1=3", "a/2=2", "a/1=1"}},
+		{"reverse bounds", store.Range{Start: "a/2", End: "c", Reverse: true}, []string{"b/2=4", "b/1=3", "a/2=2"}},
+		{"limit", store.Range{Limit: 2}, []string{"a/1=1", "a/2=2"}},
+		{"reverse limit", store.Range{Prefix: "a/", Reverse: true, Limit: 1}, []string{"a/2=2"}},
+		{"empty", store.Range{Prefix: "z"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scan(t, b, store.BucketAudit, tt.r)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Scan(%+v) = %v, want %v", tt.r, got, tt.want)
+			}
+		})
+	}
+
+	stop := errors.New("stop")
+	visited := 0
+	err := b.View(context.Background(), func(tx store.Tx) error {
+		return tx.Scan(store.BucketAudit, store.Range{}, func(string, []byte) error {
+			visited++
+			return stop
+		})
+	})
+	if !errors.Is(err, stop) || visited != 1 {
+		t.Errorf("Scan() callback error = %v after %d keys, want stop after 1", err, visited)
+	}
+}
+
+func testScanSeesPendingWrites(t *testing.T, b store.Backend) {
+	put(t, b, store.BucketJobs, "a", "1", "b", "2", "c", "3")
+	var keys []string
+	err := b.Update(context.Background(), func(tx store.Tx) error {
+		if err := tx.Delete(store.BucketJobs, "b"); err != nil {
+			return err
+		}
+		if err := tx.Put(store.BucketJobs, "bb", []byte("new")); err != nil {
+			return err
+		}
+		if err := tx.Put(store.BucketJobs, "c", []byte("changed")); err != nil {
+			return err
+		}
+		return tx.Scan(store.BucketJobs, store.Range{}, func(key string, value []byte) error {
+			keys = append(keys, key+"="+string(value))
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a=1,bb=new,c=changed"
+	if got := strings.Join(keys, ","); got != want {
+		t.Errorf("Scan() in tx = %s, want %s", got, want)
+	}
+}
+
+func testCancelledContext(t *testing.T, b store.Backend) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	called := false
+	err := b.Update(ctx, func(tx store.Tx) error {
+		called = true
+		return tx.Put(store.BucketCases, "a", []byte("1"))
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Update(cancelled) error = %v, want context.Canceled", err)
+	}
+	if called {
+		t.Errorf("Update ran its function with a cancelled context")
+	}
+	if _, err := get(t, b, store.BucketCases, "a"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("write with cancelled context is visible: %v", err)
+	}
+}
+
+func testClose(t *testing.T, b store.Backend) {
+	put(t, b, store.BucketCases, "a", "1")
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := get(t, b, store.BucketCases, "a"); !errors.Is(err, store.ErrClosed) {
+		t.Errorf("Get after Close error = %v, want ErrClosed", err)
+	}
+	err := b.Update(context.Background(), func(tx store.Tx) error { return nil })
+	if !errors.Is(err, store.ErrClosed) {
+		t.Errorf("Update after Close error = %v, want ErrClosed", err)
+	}
+}
+
+// RunRepositories tests the typed repositories on top of a backend
+func RunRepositories(t *testing.T, open Opener) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s *store.Store, fake *clock.Fake)
+	}{
+		{"Migrations", testMigrations},
+		{"Cases", testCases},
+		{"Tokens", testTokens},
+		{"Watchlist", testWatchlist},
+		{"Jobs", testJobs},
+		{"Audit", testAudit},
+		{"Webhooks", testWebhooks},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := clock.NewFake(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+			s, err := store.Open(context.Background(), open(t), store.WithClock(fake))
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer s.Close()
+			tt.fn(t, s, fake)
+		})
+	}
+}
+
+func testMigrations(t *testing.T, s *store.Store, _ *clock.Fake) {
+	ctx := context.Background()
+	b := s.Backend()
+	latest := len(store.Migrations())
+	if v, err := store.SchemaVersion(ctx, b); err != nil || v != latest {
+		t.Fatalf("SchemaVersion() = %d, %v; want %d", v, err, latest)
+	}
+
+	var ran []int
+	migrations := append(store.Migrations(),
+		store.Migration{Version: latest + 1, Name: "add", Up: func(tx store.Tx) error {
+			ran = append(ran, latest+1)
+			return tx.Put(store.BucketMeta, "migrated", []byte("yes"))
+		}},
+		store.Migration{Version: latest + 2, Name: "fail", Up: func(tx store.Tx) error {
+			ran = append(ran, latest+2)
+			if err := tx.Put(store.BucketMeta, "partial", []byte("yes")); err != nil {
+				return err
+			}
+			return errors.New("boom")
+		}},
+	)
+	v, err := store.Migrate(ctx, b, migrations)
+	if err == nil || v != latest+1 {
+		t.Fatalf("Migrate() = %d, %v; want %d and an error", v, err, latest+1)
+	}
+	if _, err := get(t, b, store.BucketMeta, "partial"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("failed migration was not rolled back: %v", err)
+	}
+
+	migrations[len(migrations)-1].Up = func(tx store.Tx) error {
+		ran = append(ran, latest+2)
+		return nil
+	}
+	if v, err := store.Migrate(ctx, b, migrations); err != nil || v != latest+2 {
+		t.Fatalf("Migrate() retry = %d, %v; want %d", v, err, latest+2)
+	}
+	if want := []int{latest + 1, latest + 2, latest + 2}; fmt.Sprint(ran) != fmt.Sprint(want) {
+		t.Errorf("migrations ran %v, want %v", ran, want)
+	}
+
+	if _, err := store.Migrate(ctx, b, store.Migrations()); !errors.Is(err, store.ErrSchemaTooNew) {
+		t.Errorf("Migrate() with older migrations error = %v, want ErrSchemaTooNew", err)
+	}
+	gap := []store.Migration{{Version: 2, Name: "gap", Up: func(store.Tx) error { return nil }}}
+	if _, err := store.Migrate(ctx, b, gap); err == nil {
+		t.Errorf("Migrate() accepted migrations with a gap")
+	}
+}
+
+func testCases(t *testing.T, s *store.Store, _ *clock.Fake) {
+	ctx := context.Background()
+	cases, timelines := s.Cases(), s.Timelines()
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	if _, err := cases.Get(ctx, "IOE0912345678"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+
+	first := &types.CaseRecord{
+		ReceiptNumber: "ioe-0912345678",
+		FormType:      "I-485",
+		Status:        types.ParseCaseStatus("Case Was Received"),
+		UpdatedAt:     day(2),
+		Timeline: types.NewCaseTimeline("IOE0912345678",
+			types.NewTimelineEvent(day(2), "Case Was Received", types.SourceHistory)),
+	}
+	if err := cases.Put(ctx, first); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	second := &types.CaseRecord{
+		ReceiptNumber: "IOE0912345678",
+		FormType:      "I-485",
+		Status:        types.ParseCaseStatus("Case Was Approved"),
+		UpdatedAt:     day(20),
+		Timeline: types.NewCaseTimeline("IOE0912345678",
+			types.NewTimelineEvent(day(20), "Case Was Approved", types.SourceCurrent)),
+	}
+	if err := cases.Put(ctx, second); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	got, err := cases.Get(ctx, "IOE0912345678")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.ReceiptNumber != "IOE0912345678" || got.Status.Code != types.StatusApproved || !got.UpdatedAt.Equal(day(20)) {
+		t.Errorf("Get() = %+v", got)
+	}
+	if got.Timeline.Len() != 2 {
+		t.Errorf("timeline has %d events, want the merged 2", got.Timeline.Len())
+	}
+
+	added, err := timelines.Merge(ctx, types.NewCaseTimeline("IOE0912345678",
+		types.NewTimelineEvent(day(2), "Case Was Received", types.SourceHistory),
+		types.NewTimelineEvent(day(10), "Interview Was Scheduled", types.SourceHistory)))
+	if err != nil || added != 1 {
+		t.Errorf("Merge() = %d, %v; want 1 new event", added, err)
+	}
+	if tl, err := timelines.Get(ctx, "IOE0912345678"); err != nil || tl.Len() != 3 {
+		t.Errorf("Timelines.Get() = %d events, %v; want 3", tl.Len(), err)
+	}
+
+	if err := cases.Put(ctx, &types.CaseRecord{ReceiptNumber: "EAC2190000001", Status: types.ParseCaseStatus("Case Was Received")}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	list, err := cases.List(ctx)
+	if err != nil || len(list) != 2 {
+		t.Fatalf("List() = %d records, %v; want 2", len(list), err)
+	}
+	if list[0].ReceiptNumber != "EAC2190000001" || list[1].Timeline.Len() != 3 {
+		t.Errorf("List() = %s, %s (%d events)", list[0].ReceiptNumber, list[1].ReceiptNumber, list[1].Timeline.Len())
+	}
+	if list[0].Timeline == nil {
+		t.Errorf("List() returned a record without a timeline")
+	}
+
+	if err := cases.Delete(ctx, "IOE0912345678"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := timelines.Get(ctx, "IOE0912345678"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("timeline survived case deletion: %v", err)
+	}
+	if err := cases.Delete(ctx, "IOE0912345678"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Delete(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testTokens(t *testing.T, s *store.Store, fake *clock.Fake) {
+	ctx := context.Background()
+	tokens := s.Tokens()
+	check := func(id string, wantValid, wantRevoked bool) {
+		t.Helper()
+		valid, err := tokens.IsValid(ctx, id)
+		if err != nil || valid != wantValid {
+			t.Errorf("IsValid(%s) = %v, %v; want %v", id, valid, err, wantValid)
+		}
+		revoked, err := tokens.IsRevoked(ctx, id)
+		if err != nil || revoked != wantRevoked {
+			t.Errorf("IsRevoked(%s) = %v, %v; want %v", id, revoked, err, wantRevoked)
+		}
+	}
+
+	now := fake.Now()
+	if err := tokens.Put(ctx, &store.Token{ID: "short", ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tokens.Put(ctx, &store.Token{ID: "long", CaseNumber: "IOE0912345678", ExpiresAt: now.Add(48 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	check("short", true, false)
+	check("unknown", false, false)
+
+	if err := tokens.Revoke(ctx, "long"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tokens.Revoke(ctx, "never-issued"); err != nil {
+		t.Fatal(err)
+	}
+	check("long", false, true)
+	check("never-issued", false, true)
+
+	// re-registering a revoked token must not clear the revocation
+	if err := tokens.Put(ctx, &store.Token{ID: "long", ExpiresAt: now.Add(48 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	check("long", false, true)
+	if tok, err := tokens.Get(ctx, "long"); err != nil || tok.IssuedAt.IsZero() || !tok.RevokedAt.Equal(now) {
+		t.Errorf("Get(long) = %+v, %v", tok, err)
+	}
+
+	fake.Advance(2 * time.Hour)
+	check("short", false, false)
+	removed, err := tokens.Purge(ctx)
+	if err != nil || removed != 1 {
+		t.Errorf("Purge() = %d, %v; want 1", removed, err)
+	}
+	check("long", false, true)
+	check("never-issued", false, true)
+}
+
+func testWatchlist(t *testing.T, s *store.Store, fake *clock.Fake) {
+	ctx := context.Background()
+	svc := watchlist.NewService(s.Watchlist(), watchlist.WithClock(fake))
+
+	if _, err := svc.Add(ctx, watchlist.AddRequest{ReceiptNumber: "IOE0912345678", Label: "Mine"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if _, err := svc.Add(ctx, watchlist.AddRequest{ReceiptNumber: "IOE0912345678"}); !errors.Is(err, watchlist.ErrAlreadyWatched) {
+		t.Errorf("Add(duplicate) error = %v, want ErrAlreadyWatched", err)
+	}
+	record := &types.CaseRecord{
+		ReceiptNumber: "IOE0912345678",
+		Status:        types.ParseCaseStatus("Case Was Received"),
+		Timeline: types.NewCaseTimeline("IOE0912345678",
+			types.NewTimelineEvent(fake.Now(), "Case Was Received", types.SourceHistory)),
+	}
+	if _, err := svc.RecordStatus(ctx, record); err != nil {
+		t.Fatalf("RecordStatus() error = %v", err)
+	}
+	entry, err := svc.Get(ctx, "IOE0912345678")
+	if err != nil || entry.Label != "Mine" || entry.LastStatus == nil || entry.Timeline.Len() != 1 {
+		t.Errorf("Get() = %+v, %v", entry, err)
+	}
+	if err := svc.Remove(ctx, "IOE0912345678"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := svc.Remove(ctx, "IOE0912345678"); !errors.Is(err, watchlist.ErrNotFound) {
+		t.Errorf("Remove(missing) error = %v, want watchlist.ErrNotFound", err)
+	}
+}
+
+func testJobs(t *testing.T, s *store.Store, fake *clock.Fake) {
+	ctx := context.Background()
+	repo := s.Jobs()
+	now := fake.Now()
+	finished := now.Add(-time.Hour)
+	snapshots := []jobs.Snapshot{
+		{ID: "job-b", Kind: "certify", State: jobs.StateSucceeded, CreatedAt: now.Add(-2 * time.Hour), FinishedAt: &finished},
+		{ID: "job-a", Kind: "process", State: jobs.StateRunning, CreatedAt: now.Add(-time.Minute)},
+		{ID: "job-c", Kind: "process", State: jobs.StateFailed, Error: "boom", CreatedAt: now.Add(-3 * time.Hour), FinishedAt: &now},
+	}
+	for _, snap := range snapshots {
+		if err := repo.Put(ctx, snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := repo.List(ctx, jobs.Filter{})
+	if err != nil || len(all) != 3 || all[0].ID != "job-c" || all[2].ID != "job-a" {
+		t.Errorf("List() = %v, %v; want oldest first", all, err)
+	}
+	if got, _ := repo.List(ctx, jobs.Filter{Kind: "process", State: jobs.StateFailed}); len(got) != 1 || got[0].Error != "boom" {
+		t.Errorf("List(filter) = %v", got)
+	}
+
+	removed, err := repo.Purge(ctx, 30*time.Minute)
+	if err != nil || removed != 1 {
+		t.Errorf("Purge() = %d, %v; want 1", removed, err)
+	}
+	if _, err := repo.Get(ctx, "job-b"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("purged job still stored: %v", err)
+	}
+	if snap, err := repo.Get(ctx, "job-a"); err != nil || snap.State != jobs.StateRunning {
+		t.Errorf("Get(job-a) = %+v, %v", snap, err)
+	}
+}
+
+func testWebhooks(t *testing.T, s *store.Store, fake *clock.Fake) {
+	ctx := context.Background()
+	repo := s.Webhooks()
+	outbox := webhook.New(repo, webhook.WithClock(fake))
+	if err := outbox.SetEndpoint(webhook.Endpoint{Name: "hook", URL: "https://example.com/hook", Secret: []byte("secret")}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"evt-2", "evt-1"} {
+		if _, err := outbox.Enqueue(ctx, "hook", changes.Event{ID: id, Type: changes.StatusChanged, ReceiptNumber: "EAC2190000001"}); err != nil {
+			t.Fatalf("Enqueue(%s) error = %v", id, err)
+		}
+		fake.Advance(time.Second)
+	}
+
+	pending, err := outbox.Deliveries(ctx, webhook.StatusPending)
+	if err != nil || len(pending) != 2 || pending[0].EventID != "evt-2" {
+		t.Fatalf("Deliveries(pending) = %v, %v; want oldest first", pending, err)
+	}
+	if dead, err := outbox.DeadLetters(ctx); err != nil || len(dead) != 0 {
+		t.Errorf("DeadLetters() = %v, %v", dead, err)
+	}
+	if d, err := repo.Get(ctx, pending[1].ID); err != nil || string(d.Payload) != string(pending[1].Payload) {
+		t.Errorf("Get() = %+v, %v", d, err)
+	}
+	if err := repo.Delete(ctx, pending[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Get(ctx, pending[0].ID); !errors.Is(err, webhook.ErrNotFound) {
+		t.Errorf("Get() of a deleted delivery error = %v, want %v", err, webhook.ErrNotFound)
+	}
+	if err := repo.Delete(ctx, pending[0].ID); !errors.Is(err, webhook.ErrNotFound) {
+		t.Errorf("second Delete() error = %v, want %v", err, webhook.ErrNotFound)
+	}
+}
+
+func testAudit(t *testing.T, s *store.Store, fake *clock.Fake) {
+	ctx := context.Background()
+	log := s.Audit()
+	if err := log.Append(ctx, audit.Entry{}); !errors.Is(err, audit.ErrInvalidEntry) {
+		t.Errorf("Append(empty) error = %v, want ErrInvalidEntry", err)
+	}
+
+	start := fake.Now()
+	for i := 0; i < 5; i++ {
+		subject := "IOE0912345678"
+		if i%2 == 1 {
+			subject = "EAC2190000001"
+		}
+		err := log.Append(ctx, audit.Entry{
+			Action:  "case.status_changed",
+			Subject: subject,
+			Details: map[string]string{"n": fmt.Sprint(i)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fake.Advance(time.Minute)
+	}
+
+	n := func(entries []audit.Entry) string {
+		var out []string
+		for _, e := range entries {
+			out = append(out, e.Details["n"])
+		}
+		return strings.Join(out, ",")
+	}
+	tests := []struct {
+		name  string
+		query audit.Query
+		want  string
+	}{
+		{"all", audit.Query{}, "0,1,2,3,4"},
+		{"subject", audit.Query{Subject: "IOE0912345678"}, "0,2,4"},
+		{"since", audit.Query{Since: start.Add(3 * time.Minute)}, "3,4"},
+		{"limit keeps newest", audit.Query{Limit: 2}, "3,4"},
+		{"limit with filter", audit.Query{Subject: "EAC2190000001", Limit: 1}, "3"},
+	}
+	for _, tt := range tests {
+		entries, err := log.List(ctx, tt.query)
+		if err != nil {
+			t.Fatalf("%s: List() error = %v", tt.name, err)
+		}
+		if got := n(entries); got != tt.want {
+			t.Errorf("%s: List() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	entries, _ := log.List(ctx, audit.Query{Limit: 1})
+	if e := entries[0]; e.Actor != audit.ActorSystem || e.ID == "" || !e.Time.Equal(start.Add(4*time.Minute)) {
+		t.Errorf("appended entry = %+v", e)
+	}
+
+	removed, err := log.Prune(ctx, start.Add(2*time.Minute))
+	if err != nil || removed != 2 {
+		t.Errorf("Prune() = %d, %v; want 2", removed, err)
+	}
+	if entries, _ := log.List(ctx, audit.Query{}); n(entries) != "2,3,4" {
+		t.Errorf("List() after Prune = %s, want 2,3,4", n(entries))
+	}
+}
+
+// Equal reports whether two backends hold the same keys and values in
+// every bucket. Backend tests use it to compare a reopened backend with the
+// one that wrote it.
+func Equal(ctx context.Context, a, b store.Backend) (bool, error) {
+	dump := func(backend store.Backend) (map[string]string, error) {
+		out := map[string]string{}
+		err := backend.View(ctx, func(tx store.Tx) error {
+			for _, bucket := range store.Buckets() {
+				if err := tx.Scan(bucket, store.Range{}, func(key string, value []byte) error {
+					out[bucket+"/"+key] = string(value)
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		return out, err
+	}
+	left, err := dump(a)
+	if err != nil {
+		return false, err
+	}
+	right, err := dump(b)
+	if err != nil {
+		return false, err
+	}
+	if len(left) != len(right) {
+		return false, nil
+	}
+	for k, v := range left {
+		if w, ok := right[k]; !ok || !bytes.Equal([]byte(v), []byte(w)) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
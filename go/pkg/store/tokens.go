@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Token is an issued or revoked token. A zero ExpiresAt never expires.
+type Token struct {
+	ID         string    `json:"id"`
+	CaseNumber string    `json:"caseNumber,omitempty"`
+	IssuedAt   time.Time `json:"issuedAt,omitzero"`
+	ExpiresAt  time.Time `json:"expiresAt,omitzero"`
+	RevokedAt  time.Time `json:"revokedAt,omitzero"`
+}
+
+// Revoked reports whether the token has been revoked
+func (t *Token) Revoked() bool {
+	return !t.RevokedAt.IsZero()
+}
+
+// Expired reports whether the token has expired at now
+func (t *Token) Expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+// Tokens stores issued tokens and revocations so both survive restarts
+type Tokens struct {
+	s *Store
+}
+
+// Get returns a token by ID
+func (r *Tokens) Get(ctx context.Context, id string) (*Token, error) {
+	var token *Token
+	err := r.s.backend.View(ctx, func(tx Tx) error {
+		token = &Token{}
+		return getJSON(tx, BucketTokens, id, token)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// Put stores a token, keeping an existing revocation
+func (r *Tokens) Put(ctx context.Context, token *Token) error {
+	if token == nil || token.ID == "" {
+		return ErrEmptyKey
+	}
+	return r.s.backend.Update(ctx, func(tx Tx) error {
+		stored := *token
+		var existing Token
+		switch err := getJSON(tx, BucketTokens, token.ID, &existing); {
+		case err == nil:
+			if stored.RevokedAt.IsZero() {
+				stored.RevokedAt = existing.RevokedAt
+			}
+		case !errors.Is(err, ErrNotFound):
+			return err
+		}
+		if stored.IssuedAt.IsZero() {
+			stored.IssuedAt = r.s.clock.Now().UTC()
+		}
+		return putJSON(tx, BucketTokens, token.ID, &stored)
+	})
+}
+
+// Revoke marks a token as revoked. Unknown tokens are recorded as revoked
+// so a token revoked before it is first seen is still rejected.
+func (r *Tokens) Revoke(ctx context.Context, id string) error {
+	if id == "" {
+		return ErrEmptyKey
+	}
+	return r.s.backend.Update(ctx, func(tx Tx) error {
+		token := Token{ID: id}
+		if err := getJSON(tx, BucketTokens, id, &token); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if token.Revoked() {
+			return nil
+		}
+		token.RevokedAt = r.s.clock.Now().UTC()
+		return putJSON(tx, BucketTokens, id, &token)
+	})
+}
+
+// IsRevoked reports whether a token has been revoked
+func (r *Tokens) IsRevoked(ctx context.Context, id string) (bool, error) {
+	token, err := r.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return token.Revoked(), nil
+}
+
+// IsValid reports whether a token was issued and is neither revoked nor
+// expired
+func (r *Tokens) IsValid(ctx context.Context, id string) (bool, error) {
+	token, err := r.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !token.Revoked() && !token.Expired(r.s.clock.Now()), nil
+}
+
+// Purge removes expired tokens and returns the number removed. Revocations
+// are kept until the token they revoke expires.
+func (r *Tokens) Purge(ctx context.Context) (int, error) {
+	now := r.s.clock.Now()
+	removed := 0
+	err := r.s.backend.Update(ctx, func(tx Tx) error {
+		var expired []string
+		err := scanJSON(tx, BucketTokens, Range{}, func(key string, data []byte) error {
+			var token Token
+			if err := json.Unmarshal(data, &token); err != nil {
+				return err
+			}
+			if token.Expired(now) {
+				expired = append(expired, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range expired {
+			if err := tx.Delete(BucketTokens, key); err != nil {
+				return err
+			}
+		}
+		removed = len(expired)
+		return nil
+	})
+	return removed, err
+}
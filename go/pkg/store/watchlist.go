@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"MyUSCISgo/pkg/watchlist"
+)
+
+// Watchlist stores watchlist entries. It implements watchlist.Storage, so
+// it can back a watchlist.Service directly.
+type Watchlist struct {
+	s *Store
+}
+
+// Get returns the entry for a receipt number
+func (r *Watchlist) Get(ctx context.Context, receiptNumber string) (*watchlist.Entry, error) {
+	var entry *watchlist.Entry
+	err := r.s.backend.View(ctx, func(tx Tx) error {
+		entry = &watchlist.Entry{}
+		return getJSON(tx, BucketWatchlist, receiptNumber, entry)
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil, watchlist.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Put inserts or replaces an entry
+func (r *Watchlist) Put(ctx context.Context, entry *watchlist.Entry) error {
+	if entry.ReceiptNumber == "" {
+		return ErrEmptyKey
+	}
+	return r.s.backend.Update(ctx, func(tx Tx) error {
+		return putJSON(tx, BucketWatchlist, entry.ReceiptNumber, entry)
+	})
+}
+
+// Delete removes an entry
+func (r *Watchlist) Delete(ctx context.Context, receiptNumber string) error {
+	err := r.s.backend.Update(ctx, func(tx Tx) error {
+		return tx.Delete(BucketWatchlist, receiptNumber)
+	})
+	if errors.Is(err, ErrNotFound) {
+		return watchlist.ErrNotFound
+	}
+	return err
+}
+
+// List returns every entry ordered by receipt number
+func (r *Watchlist) List(ctx context.Context) ([]*watchlist.Entry, error) {
+	var entries []*watchlist.Entry
+	err := r.s.backend.View(ctx, func(tx Tx) error {
+		return scanJSON(tx, BucketWatchlist, Range{}, func(_ string, data []byte) error {
+			entry := &watchlist.Entry{}
+			if err := json.Unmarshal(data, entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
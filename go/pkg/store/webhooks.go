@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"MyUSCISgo/pkg/webhook"
+)
+
+// Webhooks stores webhook deliveries. It implements webhook.Store, so the
+// outbox's pending deliveries and dead-letter queue survive restarts.
+type Webhooks struct {
+	s *Store
+}
+
+// Get returns a delivery by ID
+func (r *Webhooks) Get(ctx context.Context, id string) (*webhook.Delivery, error) {
+	var d *webhook.Delivery
+	err := r.s.backend.View(ctx, func(tx Tx) error {
+		d = &webhook.Delivery{}
+		return getJSON(tx, BucketWebhooks, id, d)
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil, webhook.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Put inserts or replaces a delivery
+func (r *Webhooks) Put(ctx context.Context, d *webhook.Delivery) error {
+	if d == nil || d.ID == "" {
+		return ErrEmptyKey
+	}
+	return r.s.backend.Update(ctx, func(tx Tx) error {
+		return putJSON(tx, BucketWebhooks, d.ID, d)
+	})
+}
+
+// Delete removes a delivery
+func (r *Webhooks) Delete(ctx context.Context, id string) error {
+	err := r.s.backend.Update(ctx, func(tx Tx) error {
+		return tx.Delete(BucketWebhooks, id)
+	})
+	if errors.Is(err, ErrNotFound) {
+		return webhook.ErrNotFound
+	}
+	return err
+}
+
+// List returns deliveries with the given status, oldest first; an empty
+// status lists every delivery
+func (r *Webhooks) List(ctx context.Context, status webhook.Status) ([]*webhook.Delivery, error) {
+	var out []*webhook.Delivery
+	err := r.s.backend.View(ctx, func(tx Tx) error {
+		return scanJSON(tx, BucketWebhooks, Range{}, func(_ string, data []byte) error {
+			d := &webhook.Delivery{}
+			if err := json.Unmarshal(data, d); err != nil {
+				return err
+			}
+			if status == "" || d.Status == status {
+				out = append(out, d)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out, nil
+}
@@ -0,0 +1,252 @@
+package types
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// CaseStage groups case statuses into the phases of a USCIS case
+type CaseStage string
+
+const (
+	StageUnknown        CaseStage = "unknown"
+	StageReceived       CaseStage = "received"
+	StageBiometrics     CaseStage = "biometrics"
+	StageInterview      CaseStage = "interview"
+	StageRFE            CaseStage = "rfe"
+	StageDecision       CaseStage = "decision"
+	StageCardProduction CaseStage = "card_production"
+	StageClosed         CaseStage = "closed"
+)
+
+// stageOrder ranks stages in the order a case normally moves through them
+var stageOrder = map[CaseStage]int{
+	StageUnknown:        0,
+	StageReceived:       1,
+	StageBiometrics:     2,
+	StageInterview:      3,
+	StageRFE:            4,
+	StageDecision:       5,
+	StageCardProduction: 6,
+	StageClosed:         7,
+}
+
+// String returns the string representation of the stage
+func (s CaseStage) String() string {
+	return string(s)
+}
+
+// Order returns the stage's position in the normal case lifecycle
+func (s CaseStage) Order() int {
+	return stageOrder[s]
+}
+
+// CaseStatusCode is the canonical identifier of a USCIS case status
+type CaseStatusCode string
+
+const (
+	StatusUnknown             CaseStatusCode = "unknown"
+	StatusReceived            CaseStatusCode = "case_received"
+	StatusFeeWaived           CaseStatusCode = "fee_waived"
+	StatusTransferred         CaseStatusCode = "case_transferred"
+	StatusActivelyReviewed    CaseStatusCode = "actively_reviewed"
+	StatusReopened            CaseStatusCode = "case_reopened"
+	StatusCorrespondence      CaseStatusCode = "correspondence_received"
+	StatusNoticeMailed        CaseStatusCode = "notice_mailed"
+	StatusExpediteReceived    CaseStatusCode = "expedite_request_received"
+	StatusExpediteDenied      CaseStatusCode = "expedite_request_denied"
+	StatusBiometricsScheduled CaseStatusCode = "biometrics_scheduled"
+	StatusFingerprintsTaken   CaseStatusCode = "fingerprints_taken"
+	StatusInterviewReady      CaseStatusCode = "interview_ready"
+	StatusInterviewScheduled  CaseStatusCode = "interview_scheduled"
+	StatusInterviewCompleted  CaseStatusCode = "interview_completed"
+	StatusRFESent             CaseStatusCode = "rfe_sent"
+	StatusRIESent             CaseStatusCode = "initial_evidence_requested"
+	StatusNOIDSent            CaseStatusCode = "noid_sent"
+	StatusRFEResponseReceived CaseStatusCode = "rfe_response_received"
+	StatusOathScheduled       CaseStatusCode = "oath_scheduled"
+	StatusApproved            CaseStatusCode = "case_approved"
+	StatusDenied              CaseStatusCode = "case_denied"
+	StatusSentToDOS           CaseStatusCode = "sent_to_department_of_state"
+	StatusCardProduced        CaseStatusCode = "card_being_produced"
+	StatusCardMailed          CaseStatusCode = "card_mailed"
+	StatusCardPickedUp        CaseStatusCode = "card_picked_up_by_usps"
+	StatusCardDelivered       CaseStatusCode = "card_delivered"
+	StatusCardReturned        CaseStatusCode = "card_returned"
+	StatusRejected            CaseStatusCode = "case_rejected"
+	StatusWithdrawn           CaseStatusCode = "case_withdrawn"
+	StatusClosed              CaseStatusCode = "case_closed"
+)
+
+// String returns the string representation of the status code
+func (c CaseStatusCode) String() string {
+	return string(c)
+}
+
+// Title returns the canonical USCIS title for the status code
+func (c CaseStatusCode) Title() string {
+	if def, ok := caseStatusDefinitions[c]; ok {
+		return def.Title
+	}
+	return ""
+}
+
+// CaseStatus is a USCIS case status title mapped onto the canonical taxonomy
+type CaseStatus struct {
+	Code           CaseStatusCode `json:"code"`
+	Title          string         `json:"title"`
+	Stage          CaseStage      `json:"stage"`
+	Terminal       bool           `json:"terminal"`
+	ActionRequired bool           `json:"actionRequired"`
+}
+
+// caseStatusDefinitions holds the canonical definition of each status code.
+// An approval is not terminal: most forms go on to card production, an oath
+// ceremony or a transfer to the Department of State.
+var caseStatusDefinitions = map[CaseStatusCode]CaseStatus{
+	StatusReceived:            {Title: "Case Was Received", Stage: StageReceived},
+	StatusFeeWaived:           {Title: "Fee Was Waived", Stage: StageReceived},
+	StatusTransferred:         {Title: "Case Was Transferred And A New Office Has Jurisdiction", Stage: StageReceived},
+	StatusActivelyReviewed:    {Title: "Case Is Being Actively Reviewed By USCIS", Stage: StageReceived},
+	StatusReopened:            {Title: "Case Was Reopened", Stage: StageReceived},
+	StatusCorrespondence:      {Title: "Correspondence Was Received And USCIS Is Reviewing It", Stage: StageReceived},
+	StatusNoticeMailed:        {Title: "Notice Explaining USCIS Actions Was Mailed", Stage: StageReceived},
+	StatusExpediteReceived:    {Title: "Expedite Request Received", Stage: StageReceived},
+	StatusExpediteDenied:      {Title: "Expedite Request Denied", Stage: StageReceived},
+	StatusBiometricsScheduled: {Title: "Request for Applicant to Appear for Fingerprinting Was Mailed", Stage: StageBiometrics, ActionRequired: true},
+	StatusFingerprintsTaken:   {Title: "Case Was Updated To Show Fingerprints Were Taken", Stage: StageBiometrics},
+	StatusInterviewReady:      {Title: "Ready to Be Scheduled for An Interview", Stage: StageInterview},
+	StatusInterviewScheduled:  {Title: "Interview Was Scheduled", Stage: StageInterview, ActionRequired: true},
+	StatusInterviewCompleted:  {Title: "Interview Was Completed And My Case Must Be Reviewed", Stage: StageInterview},
+	StatusRFESent:             {Title: "Request for Evidence Was Sent", Stage: StageRFE, ActionRequired: true},
+	StatusRIESent:             {Title: "Request for Initial Evidence Was Sent", Stage: StageRFE, ActionRequired: true},
+	StatusNOIDSent:            {Title: "Notice of Intent to Deny Was Sent", Stage: StageRFE, ActionRequired: true},
+	StatusRFEResponseReceived: {Title: "Response To USCIS' Request For Evidence Was Received", Stage: StageRFE},
+	StatusOathScheduled:       {Title: "Oath Ceremony Was Scheduled", Stage: StageDecision, ActionRequired: true},
+	StatusApproved:            {Title: "Case Was Approved", Stage: StageDecision},
+	StatusDenied:              {Title: "Case Was Denied", Stage: StageDecision, Terminal: true},
+	StatusSentToDOS:           {Title: "Case Was Sent To The Department of State", Stage: StageDecision, Terminal: true},
+	StatusCardProduced:        {Title: "Card Is Being Produced", Stage: StageCardProduction},
+	StatusCardMailed:          {Title: "Card Was Mailed To Me", Stage: StageCardProduction},
+	StatusCardPickedUp:        {Title: "Card Was Picked Up By The United States Postal Service", Stage: StageCardProduction},
+	StatusCardDelivered:       {Title: "Card Was Delivered To Me By The Post Office", Stage: StageCardProduction, Terminal: true},
+	StatusCardReturned:        {Title: "Card Was Returned To USCIS", Stage: StageCardProduction, ActionRequired: true},
+	StatusRejected:            {Title: "Case Was Rejected", Stage: StageClosed, Terminal: true},
+	StatusWithdrawn:           {Title: "Withdrawal Acknowledgement Notice Was Sent", Stage: StageClosed, Terminal: true},
+	StatusClosed:              {Title: "Case Closed Benefit Received By Other Means", Stage: StageClosed, Terminal: true},
+}
+
+// caseStatusAliases maps additional USCIS titles onto canonical codes. Keys
+// are normalized with normalizeStatusTitle.
+var caseStatusAliases = map[string]CaseStatusCode{
+	"case was received and a receipt notice was emailed":                         StatusReceived,
+	"case was received and a receipt notice was sent":                            StatusReceived,
+	"case was transferred":                                                       StatusTransferred,
+	"case transferred to another office":                                         StatusTransferred,
+	"case is being actively reviewed":                                            StatusActivelyReviewed,
+	"case is ready to be scheduled for an interview":                             StatusInterviewReady,
+	"fingerprint fee was received":                                               StatusReceived,
+	"biometrics appointment was scheduled":                                       StatusBiometricsScheduled,
+	"request for applicant to appear for fingerprinting":                         StatusBiometricsScheduled,
+	"fingerprint review was completed":                                           StatusFingerprintsTaken,
+	"interview was rescheduled":                                                  StatusInterviewScheduled,
+	"request for additional evidence was sent":                                   StatusRFESent,
+	"request for evidence was received":                                          StatusRFEResponseReceived,
+	"request for evidence response was received":                                 StatusRFEResponseReceived,
+	"response to uscis' request for evidence was received":                       StatusRFEResponseReceived,
+	"case was approved and my decision was emailed":                              StatusApproved,
+	"case approval was certified by uscis":                                       StatusApproved,
+	"new card is being produced":                                                 StatusCardProduced,
+	"document is being produced":                                                 StatusCardProduced,
+	"card was mailed to me":                                                      StatusCardMailed,
+	"document was mailed to me":                                                  StatusCardMailed,
+	"new card was mailed to me":                                                  StatusCardMailed,
+	"card was delivered to me by the post office":                                StatusCardDelivered,
+	"document was delivered to me by the post office":                            StatusCardDelivered,
+	"case rejected because i sent an incorrect fee":                              StatusRejected,
+	"case rejected because the version of the form i sent is no longer accepted": StatusRejected,
+	"case was withdrawn":                                                         StatusWithdrawn,
+	"case was closed":                                                            StatusClosed,
+	"duplicate notice was mailed":                                                StatusNoticeMailed,
+}
+
+// caseStatusIndex maps normalized titles to codes; built at init from the
+// canonical definitions and aliases
+var caseStatusIndex = map[string]CaseStatusCode{}
+
+func init() {
+	for code, def := range caseStatusDefinitions {
+		def.Code = code
+		caseStatusDefinitions[code] = def
+		caseStatusIndex[normalizeStatusTitle(def.Title)] = code
+	}
+	for title, code := range caseStatusAliases {
+		caseStatusIndex[normalizeStatusTitle(title)] = code
+	}
+}
+
+// normalizeStatusTitle lowercases a title, collapses whitespace and drops
+// trailing punctuation so that minor formatting differences still match
+func normalizeStatusTitle(title string) string {
+	title = strings.ToLower(strings.Join(strings.Fields(title), " "))
+	title = strings.ReplaceAll(title, "’", "'")
+	return strings.TrimRight(title, ".!")
+}
+
+// ParseCaseStatus maps a USCIS status title onto the canonical taxonomy. The
+// original title is preserved; unrecognized titles map to StatusUnknown.
+func ParseCaseStatus(title string) CaseStatus {
+	title = strings.TrimSpace(title)
+	code, ok := caseStatusIndex[normalizeStatusTitle(title)]
+	if !ok {
+		return CaseStatus{Code: StatusUnknown, Title: title, Stage: StageUnknown}
+	}
+	status := caseStatusDefinitions[code]
+	status.Title = title
+	return status
+}
+
+// CaseStatusFromCode returns the canonical status for a code
+func CaseStatusFromCode(code CaseStatusCode) (CaseStatus, bool) {
+	status, ok := caseStatusDefinitions[code]
+	return status, ok
+}
+
+// Decided reports whether the case has been decided: the status is terminal
+// or at the decision stage or later, so no decision is pending
+func (s CaseStatus) Decided() bool {
+	return s.Terminal || s.Stage.Order() >= StageDecision.Order()
+}
+
+// IsKnown reports whether the status maps to a canonical code
+func (s CaseStatus) IsKnown() bool {
+	return s.Code != "" && s.Code != StatusUnknown
+}
+
+// UnmarshalJSON accepts either a full status object or a bare title string
+func (s *CaseStatus) UnmarshalJSON(data []byte) error {
+	var title string
+	if err := json.Unmarshal(data, &title); err == nil {
+		*s = ParseCaseStatus(title)
+		return nil
+	}
+
+	type rawStatus CaseStatus
+	var raw rawStatus
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	// Re-derive the classification from the code so stale or hand-edited
+	// flags cannot drift from the taxonomy
+	if def, ok := caseStatusDefinitions[raw.Code]; ok {
+		def.Title = raw.Title
+		if def.Title == "" {
+			def.Title = raw.Code.Title()
+		}
+		*s = def
+		return nil
+	}
+	*s = ParseCaseStatus(raw.Title)
+	return nil
+}
@@ -0,0 +1,126 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseCaseStatus(t *testing.T) {
+	tests := []struct {
+		title          string
+		wantCode       CaseStatusCode
+		wantStage      CaseStage
+		wantTerminal   bool
+		wantActionReqd bool
+	}{
+		{"Case Was Received", StatusReceived, StageReceived, false, false},
+		{"case was received and a receipt notice was emailed.", StatusReceived, StageReceived, false, false},
+		{"Case Is Being Actively Reviewed By USCIS", StatusActivelyReviewed, StageReceived, false, false},
+		{"Case Was Updated To Show Fingerprints Were Taken", StatusFingerprintsTaken, StageBiometrics, false, false},
+		{"Interview Was Scheduled", StatusInterviewScheduled, StageInterview, false, true},
+		{"Request for Evidence Was Sent", StatusRFESent, StageRFE, false, true},
+		{"Request for Additional Evidence Was Sent", StatusRFESent, StageRFE, false, true},
+		{"Response To USCIS’ Request For Evidence Was Received", StatusRFEResponseReceived, StageRFE, false, false},
+		{"Case Was Approved", StatusApproved, StageDecision, false, false},
+		{"Case Was Denied", StatusDenied, StageDecision, true, false},
+		{"New Card Is Being Produced", StatusCardProduced, StageCardProduction, false, false},
+		{"Card Was Delivered To Me By The Post Office", StatusCardDelivered, StageCardProduction, true, false},
+		{"Case Was Rejected", StatusRejected, StageClosed, true, false},
+		{"  Case   Was   Transferred  ", StatusTransferred, StageReceived, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			s := ParseCaseStatus(tt.title)
+			if s.Code != tt.wantCode || s.Stage != tt.wantStage {
+				t.Errorf("ParseCaseStatus() = %s/%s, want %s/%s", s.Code, s.Stage, tt.wantCode, tt.wantStage)
+			}
+			if s.Terminal != tt.wantTerminal || s.ActionRequired != tt.wantActionReqd {
+				t.Errorf("flags terminal=%t action=%t, want %t/%t", s.Terminal, s.ActionRequired, tt.wantTerminal, tt.wantActionReqd)
+			}
+			if !s.IsKnown() {
+				t.Error("IsKnown() = false for a mapped title")
+			}
+		})
+	}
+}
+
+func TestParseCaseStatusUnknownRoundTrip(t *testing.T) {
+	title := "Case Was Teleported To Mars"
+	s := ParseCaseStatus(title)
+	if s.Code != StatusUnknown || s.Stage != StageUnknown || s.IsKnown() {
+		t.Fatalf("ParseCaseStatus() = %+v, want unknown", s)
+	}
+	if s.Title != title {
+		t.Errorf("Title = %q, want original %q", s.Title, title)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var decoded CaseStatus
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if decoded != s {
+		t.Errorf("round trip = %+v, want %+v", decoded, s)
+	}
+}
+
+func TestCaseStatusUnmarshal(t *testing.T) {
+	var fromString CaseStatus
+	if err := json.Unmarshal([]byte(`"Case Was Approved"`), &fromString); err != nil {
+		t.Fatalf("Unmarshal(string) error = %v", err)
+	}
+	if fromString.Code != StatusApproved || fromString.Stage != StageDecision {
+		t.Errorf("Unmarshal(string) = %+v", fromString)
+	}
+
+	var tampered CaseStatus
+	if err := json.Unmarshal([]byte(`{"code":"rfe_sent","title":"Request for Evidence Was Sent","stage":"closed","terminal":true}`), &tampered); err != nil {
+		t.Fatalf("Unmarshal(object) error = %v", err)
+	}
+	if tampered.Stage != StageRFE || tampered.Terminal || !tampered.ActionRequired {
+		t.Errorf("flags should be re-derived from code, got %+v", tampered)
+	}
+}
+
+func TestCaseStatusDefinitionsComplete(t *testing.T) {
+	for code, def := range caseStatusDefinitions {
+		if def.Code != code {
+			t.Errorf("definition for %s has code %s", code, def.Code)
+		}
+		if got := ParseCaseStatus(code.Title()); got.Code != code {
+			t.Errorf("canonical title %q parsed to %s, want %s", code.Title(), got.Code, code)
+		}
+		if _, ok := stageOrder[def.Stage]; !ok {
+			t.Errorf("status %s has unordered stage %s", code, def.Stage)
+		}
+	}
+	if StageReceived.Order() >= StageDecision.Order() {
+		t.Error("received should come before decision")
+	}
+}
+
+func TestCaseStatusDecided(t *testing.T) {
+	tests := []struct {
+		code CaseStatusCode
+		want bool
+	}{
+		{StatusReceived, false},
+		{StatusRFESent, false},
+		{StatusApproved, true},
+		{StatusOathScheduled, true},
+		{StatusDenied, true},
+		{StatusCardDelivered, true},
+		{StatusWithdrawn, true},
+		{StatusUnknown, false},
+	}
+	for _, tt := range tests {
+		status, _ := CaseStatusFromCode(tt.code)
+		if got := status.Decided(); got != tt.want {
+			t.Errorf("%s.Decided() = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package types
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// EventSource records where a timeline event came from
+type EventSource string
+
+const (
+	// SourceHistory marks events from the upstream status history
+	SourceHistory EventSource = "history"
+	// SourceCurrent marks the upstream current status
+	SourceCurrent EventSource = "current"
+	// SourceGenerated marks events produced locally for development and testing
+	SourceGenerated EventSource = "generated"
+)
+
+// noticeTypes maps status codes to the notice USCIS mails for them
+var noticeTypes = map[CaseStatusCode]string{
+	StatusReceived:            "Receipt Notice (I-797C)",
+	StatusTransferred:         "Transfer Notice (I-797C)",
+	StatusBiometricsScheduled: "Biometrics Appointment Notice (I-797C)",
+	StatusInterviewScheduled:  "Interview Notice (I-797C)",
+	StatusRFESent:             "Request for Evidence (I-797E)",
+	StatusRIESent:             "Request for Initial Evidence (I-797E)",
+	StatusNOIDSent:            "Notice of Intent to Deny",
+	StatusOathScheduled:       "Oath Ceremony Notice (N-445)",
+	StatusApproved:            "Approval Notice (I-797)",
+	StatusDenied:              "Decision Notice",
+	StatusRejected:            "Rejection Notice (I-797C)",
+	StatusWithdrawn:           "Withdrawal Acknowledgement Notice",
+}
+
+// NoticeType returns the notice USCIS mails for the status, if any
+func (c CaseStatusCode) NoticeType() string {
+	return noticeTypes[c]
+}
+
+// TimelineEvent is a single dated status change in a case's history
+type TimelineEvent struct {
+	Date       time.Time   `json:"date"`
+	Status     CaseStatus  `json:"status"`
+	NoticeType string      `json:"noticeType,omitempty"`
+	Source     EventSource `json:"source"`
+}
+
+// NewTimelineEvent creates an event for a status title, deriving the notice type
+func NewTimelineEvent(date time.Time, title string, source EventSource) TimelineEvent {
+	status := ParseCaseStatus(title)
+	return TimelineEvent{
+		Date:       date.UTC(),
+		Status:     status,
+		NoticeType: status.Code.NoticeType(),
+		Source:     source,
+	}
+}
+
+// Key identifies an event for de-duplication: the same status on the same day
+func (e TimelineEvent) Key() string {
+	status := string(e.Status.Code)
+	if !e.Status.IsKnown() {
+		status = normalizeStatusTitle(e.Status.Title)
+	}
+	return e.Date.UTC().Format("2006-01-02") + "|" + status
+}
+
+// CaseTimeline is the ordered status history of a case
+type CaseTimeline struct {
+	ReceiptNumber string          `json:"receiptNumber"`
+	Events        []TimelineEvent `json:"events"`
+}
+
+// NewCaseTimeline creates a timeline from events, ordering them and
+// dropping duplicates
+func NewCaseTimeline(receiptNumber string, events ...TimelineEvent) *CaseTimeline {
+	t := &CaseTimeline{ReceiptNumber: receiptNumber}
+	for _, e := range events {
+		t.Add(e)
+	}
+	return t
+}
+
+// Add inserts an event in date order and reports whether it was new. When a
+// duplicate arrives, missing details on the stored event are filled in and
+// history entries take precedence over current-status snapshots.
+func (t *CaseTimeline) Add(e TimelineEvent) bool {
+	e.Date = e.Date.UTC()
+	k := e.Key()
+	for i := range t.Events {
+		if t.Events[i].Key() != k {
+			continue
+		}
+		existing := &t.Events[i]
+		if existing.NoticeType == "" {
+			existing.NoticeType = e.NoticeType
+		}
+		if existing.Source == SourceCurrent && e.Source == SourceHistory {
+			existing.Source = SourceHistory
+		}
+		return false
+	}
+
+	idx := sort.Search(len(t.Events), func(i int) bool {
+		return t.Events[i].Date.After(e.Date)
+	})
+	t.Events = append(t.Events, TimelineEvent{})
+	copy(t.Events[idx+1:], t.Events[idx:])
+	t.Events[idx] = e
+	return true
+}
+
+// Merge adds the events of other to t and returns the number of new events
+func (t *CaseTimeline) Merge(other *CaseTimeline) int {
+	if other == nil {
+		return 0
+	}
+	if t.ReceiptNumber == "" {
+		t.ReceiptNumber = other.ReceiptNumber
+	}
+	added := 0
+	for _, e := range other.Events {
+		if t.Add(e) {
+			added++
+		}
+	}
+	return added
+}
+
+// Len returns the number of events
+func (t *CaseTimeline) Len() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.Events)
+}
+
+// Latest returns the most recent event
+func (t *CaseTimeline) Latest() (TimelineEvent, bool) {
+	if t.Len() == 0 {
+		return TimelineEvent{}, false
+	}
+	return t.Events[len(t.Events)-1], true
+}
+
+// First returns the earliest event
+func (t *CaseTimeline) First() (TimelineEvent, bool) {
+	if t.Len() == 0 {
+		return TimelineEvent{}, false
+	}
+	return t.Events[0], true
+}
+
+// CurrentStage returns the stage of the most recent event
+func (t *CaseTimeline) CurrentStage() CaseStage {
+	latest, ok := t.Latest()
+	if !ok {
+		return StageUnknown
+	}
+	return latest.Status.Stage
+}
+
+// TimeSinceLastUpdate returns how long ago the most recent event happened
+func (t *CaseTimeline) TimeSinceLastUpdate(now time.Time) time.Duration {
+	latest, ok := t.Latest()
+	if !ok {
+		return 0
+	}
+	return nonNegative(now.Sub(latest.Date))
+}
+
+// TimeInCurrentStage returns how long the case has been in its current
+// stage, measured from the earliest event of the latest unbroken run of
+// events in that stage
+func (t *CaseTimeline) TimeInCurrentStage(now time.Time) time.Duration {
+	if t.Len() == 0 {
+		return 0
+	}
+	stage := t.CurrentStage()
+	start := t.Events[len(t.Events)-1].Date
+	for i := len(t.Events) - 1; i >= 0; i-- {
+		if t.Events[i].Status.Stage != stage {
+			break
+		}
+		start = t.Events[i].Date
+	}
+	return nonNegative(now.Sub(start))
+}
+
+// EventsWithStatus returns the events matching any of the given codes
+func (t *CaseTimeline) EventsWithStatus(codes ...CaseStatusCode) []TimelineEvent {
+	var matched []TimelineEvent
+	for _, e := range t.Events {
+		for _, c := range codes {
+			if e.Status.Code == c {
+				matched = append(matched, e)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// nonNegative clamps durations caused by clock skew to zero
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// CaseRecord is a snapshot of a case as returned by a status call
+type CaseRecord struct {
+	ReceiptNumber string        `json:"receiptNumber"`
+	FormType      string        `json:"formType,omitempty"`
+	Status        CaseStatus    `json:"status"`
+	Description   string        `json:"description,omitempty"`
+	ServiceCenter string        `json:"serviceCenter,omitempty"`
+	SubmittedAt   time.Time     `json:"submittedAt,omitzero"`
+	UpdatedAt     time.Time     `json:"updatedAt"`
+	Timeline      *CaseTimeline `json:"timeline"`
+}
+
+// Decided reports whether the case's status or its latest timeline event
+// shows a decision
+func (r *CaseRecord) Decided() bool {
+	if r.Status.Decided() {
+		return true
+	}
+	latest, ok := r.Timeline.Latest()
+	return ok && latest.Status.Decided()
+}
+
+// upstreamDateLayouts are the date formats seen in case-status payloads
+var upstreamDateLayouts = []string{
+	"01-02-2006 15:04:05",
+	"01-02-2006",
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"January 2, 2006",
+}
+
+// ParseUpstreamDate parses a date from a case-status payload
+func ParseUpstreamDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range upstreamDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,175 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+const samplePayload = `{
+  "case_status": {
+    "receiptNumber": "EAC2190050123",
+    "formType": "I-485",
+    "submittedDate": "01-15-2024 10:12:00",
+    "modifiedDate": "06-03-2024 14:11:46",
+    "current_case_status_text_en": "Request for Evidence Was Sent",
+    "current_case_status_desc_en": "We sent a request for evidence.",
+    "hist_case_status": [
+      {"completed_text_en": "Case Was Received", "date": "01-15-2024"},
+      {"completed_text_en": "Case Was Updated To Show Fingerprints Were Taken", "date": "02-20-2024"},
+      {"completed_text_en": "Case Is Being Actively Reviewed By USCIS", "date": "04-01-2024"},
+      {"completed_text_en": "Some Brand New Status", "date": "not a date"}
+    ]
+  },
+  "message": "OK"
+}`
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestCaseStatusResponseTimeline(t *testing.T) {
+	resp, err := ParseCaseStatusResponse([]byte(samplePayload))
+	if err != nil {
+		t.Fatalf("ParseCaseStatusResponse() error = %v", err)
+	}
+
+	timeline := resp.Timeline()
+	if timeline.Len() != 4 {
+		t.Fatalf("timeline has %d events, want 4 (bad date skipped)", timeline.Len())
+	}
+
+	wantCodes := []CaseStatusCode{StatusReceived, StatusFingerprintsTaken, StatusActivelyReviewed, StatusRFESent}
+	for i, want := range wantCodes {
+		if got := timeline.Events[i].Status.Code; got != want {
+			t.Errorf("event %d = %s, want %s", i, got, want)
+		}
+	}
+	if timeline.Events[0].NoticeType != "Receipt Notice (I-797C)" || timeline.Events[0].Source != SourceHistory {
+		t.Errorf("first event = %+v", timeline.Events[0])
+	}
+	if latest, _ := timeline.Latest(); latest.Source != SourceCurrent {
+		t.Errorf("latest source = %s, want current", latest.Source)
+	}
+
+	record := resp.Record()
+	if record.Status.Code != StatusRFESent || record.FormType != "I-485" {
+		t.Errorf("record = %+v", record)
+	}
+	if !record.SubmittedAt.Equal(time.Date(2024, 1, 15, 10, 12, 0, 0, time.UTC)) {
+		t.Errorf("SubmittedAt = %v", record.SubmittedAt)
+	}
+}
+
+func TestParseCaseStatusResponseErrors(t *testing.T) {
+	if _, err := ParseCaseStatusResponse([]byte(`{`)); err == nil {
+		t.Error("expected error for malformed JSON")
+	}
+	if _, err := ParseCaseStatusResponse([]byte(`{"case_status":{}}`)); err == nil {
+		t.Error("expected error for missing receipt number")
+	}
+}
+
+func TestCaseStatusResponseOffice(t *testing.T) {
+	const transferred = "We transferred your Form I-485 to our National Benefits Center location, which now has jurisdiction over your case."
+	tests := []struct {
+		name        string
+		status      string
+		description string
+		office      string
+		want        string
+	}{
+		{"named office", "Case Was Approved", "", "Texas Service Center", "Texas Service Center"},
+		{"named office wins", "Case Was Transferred", transferred, "Texas Service Center", "Texas Service Center"},
+		{"transfer notice", "Case Was Transferred And A New Office Has Jurisdiction", transferred, "", "National Benefits Center"},
+		{"transfer without office", "Case Was Transferred", "We transferred your case.", "", ""},
+		{"no office", "Case Was Approved", "We approved your case.", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &CaseStatusResponse{CaseStatus: UpstreamCaseStatus{
+				ReceiptNumber:     "EAC2190050123",
+				CurrentStatus:     tt.status,
+				StatusDescription: tt.description,
+				Office:            tt.office,
+			}}
+			if got := resp.Record().ServiceCenter; got != tt.want {
+				t.Errorf("ServiceCenter = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimelineMergeWithoutDuplicates(t *testing.T) {
+	first := NewCaseTimeline("EAC2190050123",
+		NewTimelineEvent(date(2024, 1, 15), "Case Was Received", SourceHistory),
+		NewTimelineEvent(date(2024, 4, 1), "Case Is Being Actively Reviewed By USCIS", SourceCurrent),
+	)
+
+	// A later fetch repeats earlier events (one with a different time of day
+	// and a variant title) and adds a new one
+	second := NewCaseTimeline("EAC2190050123",
+		NewTimelineEvent(date(2024, 1, 15).Add(10*time.Hour), "Case Was Received And A Receipt Notice Was Sent", SourceHistory),
+		NewTimelineEvent(date(2024, 4, 1), "Case Is Being Actively Reviewed By USCIS", SourceHistory),
+		NewTimelineEvent(date(2024, 6, 3), "Case Was Approved", SourceCurrent),
+	)
+
+	if added := first.Merge(second); added != 1 {
+		t.Errorf("Merge() added %d events, want 1", added)
+	}
+	if first.Len() != 3 {
+		t.Fatalf("timeline has %d events, want 3", first.Len())
+	}
+	if first.Events[1].Source != SourceHistory {
+		t.Errorf("history should take precedence over current snapshot, got %s", first.Events[1].Source)
+	}
+	if added := first.Merge(second); added != 0 {
+		t.Errorf("second Merge() added %d events, want 0", added)
+	}
+}
+
+func TestTimelineOrdering(t *testing.T) {
+	timeline := NewCaseTimeline("EAC2190050123")
+	timeline.Add(NewTimelineEvent(date(2024, 6, 3), "Case Was Approved", SourceHistory))
+	timeline.Add(NewTimelineEvent(date(2024, 1, 15), "Case Was Received", SourceHistory))
+	timeline.Add(NewTimelineEvent(date(2024, 3, 1), "Interview Was Scheduled", SourceHistory))
+
+	for i := 1; i < timeline.Len(); i++ {
+		if timeline.Events[i].Date.Before(timeline.Events[i-1].Date) {
+			t.Fatalf("events out of order: %v", timeline.Events)
+		}
+	}
+	if first, _ := timeline.First(); first.Status.Code != StatusReceived {
+		t.Errorf("First() = %s", first.Status.Code)
+	}
+}
+
+func TestTimelineQueries(t *testing.T) {
+	timeline := NewCaseTimeline("EAC2190050123",
+		NewTimelineEvent(date(2024, 1, 15), "Case Was Received", SourceHistory),
+		NewTimelineEvent(date(2024, 3, 1), "Request for Evidence Was Sent", SourceHistory),
+		NewTimelineEvent(date(2024, 4, 1), "Response To USCIS' Request For Evidence Was Received", SourceHistory),
+	)
+	now := date(2024, 5, 1)
+
+	if got := timeline.CurrentStage(); got != StageRFE {
+		t.Errorf("CurrentStage() = %s, want rfe", got)
+	}
+	if got := timeline.TimeSinceLastUpdate(now); got != 30*24*time.Hour {
+		t.Errorf("TimeSinceLastUpdate() = %v, want 30 days", got)
+	}
+	// The RFE stage started with the RFE on March 1
+	if got := timeline.TimeInCurrentStage(now); got != 61*24*time.Hour {
+		t.Errorf("TimeInCurrentStage() = %v, want 61 days", got)
+	}
+	if got := timeline.EventsWithStatus(StatusRFESent, StatusNOIDSent); len(got) != 1 {
+		t.Errorf("EventsWithStatus() returned %d events", len(got))
+	}
+
+	var empty *CaseTimeline
+	if empty.Len() != 0 {
+		t.Error("nil timeline should have zero length")
+	}
+	if got := NewCaseTimeline("X").TimeSinceLastUpdate(now); got != 0 {
+		t.Errorf("empty TimeSinceLastUpdate() = %v", got)
+	}
+}
@@ -35,6 +35,7 @@ type WASMResponse struct {
 	Success bool              `json:"success,omitempty"`
 	Result  *ProcessingResult `json:"result,omitempty"`
 	Error   string            `json:"error,omitempty"`
+	JobID   string            `json:"jobId,omitempty"`
 }
 
 // Environment represents the supported environments
@@ -77,14 +78,16 @@ func ToEnvironment(s string) Environment {
 
 // MarshalJSON implements custom JSON marshaling for WASMResponse
 func (r WASMResponse) MarshalJSON() ([]byte, error) {
+	response := map[string]interface{}{
+		"success": r.Success,
+	}
 	if r.Success {
-		return json.Marshal(map[string]interface{}{
-			"success": true,
-			"result":  r.Result,
-		})
+		response["result"] = r.Result
+	} else {
+		response["error"] = r.Error
+	}
+	if r.JobID != "" {
+		response["jobId"] = r.JobID
 	}
-	return json.Marshal(map[string]interface{}{
-		"success": false,
-		"error":   r.Error,
-	})
+	return json.Marshal(response)
 }
@@ -0,0 +1,111 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// CaseStatusHistoryEntry is one entry of the upstream status history
+type CaseStatusHistoryEntry struct {
+	Title string `json:"completed_text_en"`
+	Date  string `json:"date"`
+}
+
+// UpstreamCaseStatus is the case portion of the USCIS case-status payload
+type UpstreamCaseStatus struct {
+	ReceiptNumber     string                   `json:"receiptNumber"`
+	FormType          string                   `json:"formType"`
+	SubmittedDate     string                   `json:"submittedDate"`
+	ModifiedDate      string                   `json:"modifiedDate"`
+	CurrentStatus     string                   `json:"current_case_status_text_en"`
+	StatusDescription string                   `json:"current_case_status_desc_en"`
+	Office            string                   `json:"office,omitempty"`
+	History           []CaseStatusHistoryEntry `json:"hist_case_status"`
+}
+
+// CaseStatusResponse is the payload returned by the USCIS case-status API
+type CaseStatusResponse struct {
+	CaseStatus UpstreamCaseStatus `json:"case_status"`
+	Message    string             `json:"message,omitempty"`
+}
+
+// ParseCaseStatusResponse decodes an upstream case-status payload
+func ParseCaseStatusResponse(data []byte) (*CaseStatusResponse, error) {
+	var resp CaseStatusResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse case status response: %w", err)
+	}
+	if resp.CaseStatus.ReceiptNumber == "" {
+		return nil, fmt.Errorf("case status response has no receipt number")
+	}
+	return &resp, nil
+}
+
+// Timeline builds a case timeline from the history and current status.
+// History entries with unparseable dates are skipped.
+func (r *CaseStatusResponse) Timeline() *CaseTimeline {
+	cs := r.CaseStatus
+	timeline := NewCaseTimeline(cs.ReceiptNumber)
+
+	for _, h := range cs.History {
+		date, ok := ParseUpstreamDate(h.Date)
+		if !ok || h.Title == "" {
+			continue
+		}
+		timeline.Add(NewTimelineEvent(date, h.Title, SourceHistory))
+	}
+
+	if cs.CurrentStatus != "" {
+		if date, ok := ParseUpstreamDate(cs.ModifiedDate); ok {
+			timeline.Add(NewTimelineEvent(date, cs.CurrentStatus, SourceCurrent))
+		}
+	}
+
+	return timeline
+}
+
+// Record converts the payload into a case record. ServiceCenter is the
+// office named by the payload or, for a transfer, by its description, and
+// is empty when neither names one.
+func (r *CaseStatusResponse) Record() *CaseRecord {
+	cs := r.CaseStatus
+	record := &CaseRecord{
+		ReceiptNumber: cs.ReceiptNumber,
+		FormType:      cs.FormType,
+		Status:        ParseCaseStatus(cs.CurrentStatus),
+		Description:   cs.StatusDescription,
+		ServiceCenter: strings.TrimSpace(cs.Office),
+		Timeline:      r.Timeline(),
+	}
+	if record.ServiceCenter == "" && record.Status.Code == StatusTransferred {
+		record.ServiceCenter = TransferOffice(cs.StatusDescription)
+	}
+	if submitted, ok := ParseUpstreamDate(cs.SubmittedDate); ok {
+		record.SubmittedAt = submitted
+	}
+	if modified, ok := ParseUpstreamDate(cs.ModifiedDate); ok {
+		record.UpdatedAt = modified
+	} else if latest, ok := record.Timeline.Latest(); ok {
+		record.UpdatedAt = latest.Date
+	}
+	return record
+}
+
+// TransferOffice returns the office a transfer notice moved the case to,
+// as in "We transferred your case to our National Benefits Center
+// location", or "" if the text names none
+func TransferOffice(description string) string {
+	_, rest, ok := strings.Cut(description, "to our ")
+	if !ok {
+		return ""
+	}
+	if end := strings.IndexAny(rest, ".,;"); end >= 0 {
+		rest = rest[:end]
+	}
+	office := strings.TrimSpace(rest)
+	for _, suffix := range []string{" location", " office"} {
+		office = strings.TrimSuffix(office, suffix)
+	}
+	return office
+}
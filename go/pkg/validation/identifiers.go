@@ -0,0 +1,210 @@
+package validation
+
+import (
+	"strings"
+)
+
+// IdentifierKind names a category of immigration identifier
+type IdentifierKind string
+
+const (
+	// KindANumber is an Alien Registration Number such as A-123-456-789
+	KindANumber IdentifierKind = "a_number"
+	// KindUSCISAccount is a 12 digit USCIS Online Account Number
+	KindUSCISAccount IdentifierKind = "uscis_online_account_number"
+	// KindI94 is an 11 character I-94 admission record number
+	KindI94 IdentifierKind = "i94_number"
+	// KindPassport is a passport or travel document number
+	KindPassport IdentifierKind = "passport_number"
+)
+
+// identifierFields maps field and log keys, lowercased with separators
+// removed, onto the identifier they hold
+var identifierFields = map[string]IdentifierKind{
+	"anumber":                  KindANumber,
+	"aliennumber":              KindANumber,
+	"alienregistrationnumber":  KindANumber,
+	"uscisnumber":              KindANumber,
+	"uscisonlineaccountnumber": KindUSCISAccount,
+	"onlineaccountnumber":      KindUSCISAccount,
+	"uscisaccountnumber":       KindUSCISAccount,
+	"i94":                      KindI94,
+	"i94number":                KindI94,
+	"admissionnumber":          KindI94,
+	"passport":                 KindPassport,
+	"passportnumber":           KindPassport,
+	"traveldocumentnumber":     KindPassport,
+}
+
+// IdentifierKindForField reports which identifier a field or log key holds,
+// e.g. "aNumber", "a_number" and "Alien-Number" all map to KindANumber
+func IdentifierKindForField(field string) (IdentifierKind, bool) {
+	key := strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.ToLower(field))
+	kind, ok := identifierFields[key]
+	return kind, ok
+}
+
+// compact uppercases s and strips whitespace, dashes and dots
+func compact(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == ' ', r == '\t', r == '-', r == '.':
+			return -1
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return r
+	}, strings.TrimSpace(s))
+}
+
+func allDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func allZeros(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
+
+// NormalizeANumber validates an Alien Registration Number and returns it
+// as "A" followed by nine digits. The "A" prefix, a "#" and separators are
+// optional on input; eight digit numbers are zero-padded.
+func NormalizeANumber(s string) (string, error) {
+	v := compact(s)
+	if v == "" {
+		return "", ValidationError{Field: "aNumber", Message: "A-Number cannot be empty"}
+	}
+	v = strings.TrimPrefix(v, "A")
+	v = strings.TrimPrefix(v, "#")
+	if !allDigits(v) {
+		return "", ValidationError{Field: "aNumber", Message: "A-Number must contain only digits after the A prefix"}
+	}
+	if len(v) != 8 && len(v) != 9 {
+		return "", ValidationError{Field: "aNumber", Message: "A-Number must have 8 or 9 digits"}
+	}
+	if allZeros(v) {
+		return "", ValidationError{Field: "aNumber", Message: "A-Number cannot be all zeros"}
+	}
+	if len(v) == 8 {
+		v = "0" + v
+	}
+	return "A" + v, nil
+}
+
+// NormalizeUSCISAccountNumber validates a USCIS Online Account Number and
+// returns its 12 digits without separators
+func NormalizeUSCISAccountNumber(s string) (string, error) {
+	v := compact(s)
+	if v == "" {
+		return "", ValidationError{Field: "uscisOnlineAccountNumber", Message: "USCIS online account number cannot be empty"}
+	}
+	if !allDigits(v) || len(v) != 12 {
+		return "", ValidationError{Field: "uscisOnlineAccountNumber", Message: "USCIS online account number must be exactly 12 digits"}
+	}
+	if allZeros(v) {
+		return "", ValidationError{Field: "uscisOnlineAccountNumber", Message: "USCIS online account number cannot be all zeros"}
+	}
+	return v, nil
+}
+
+// NormalizeI94Number validates an I-94 admission record number. Both the
+// legacy 11 digit format and the current format of nine digits, a letter
+// and a digit (e.g. 123456789A1) are accepted.
+func NormalizeI94Number(s string) (string, error) {
+	v := compact(s)
+	if v == "" {
+		return "", ValidationError{Field: "i94Number", Message: "I-94 number cannot be empty"}
+	}
+	if len(v) != 11 {
+		return "", ValidationError{Field: "i94Number", Message: "I-94 number must be 11 characters"}
+	}
+	if !allDigits(v[:9]) || !allDigits(v[10:]) {
+		return "", ValidationError{Field: "i94Number", Message: "I-94 number must be 11 digits or 9 digits, a letter and a digit"}
+	}
+	if c := v[9]; !(c >= '0' && c <= '9') && !(c >= 'A' && c <= 'Z') {
+		return "", ValidationError{Field: "i94Number", Message: "I-94 number must be 11 digits or 9 digits, a letter and a digit"}
+	}
+	if allZeros(v) {
+		return "", ValidationError{Field: "i94Number", Message: "I-94 number cannot be all zeros"}
+	}
+	return v, nil
+}
+
+// NormalizePassportNumber validates a passport-style document number: 6 to
+// 12 letters and digits including at least one digit. Formats vary by
+// issuing country, so no country-specific rules are applied.
+func NormalizePassportNumber(s string) (string, error) {
+	v := compact(s)
+	if v == "" {
+		return "", ValidationError{Field: "passportNumber", Message: "passport number cannot be empty"}
+	}
+	if len(v) < 6 || len(v) > 12 {
+		return "", ValidationError{Field: "passportNumber", Message: "passport number must be between 6 and 12 characters"}
+	}
+	hasDigit := false
+	for _, r := range v {
+		switch {
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		case r >= 'A' && r <= 'Z':
+		default:
+			return "", ValidationError{Field: "passportNumber", Message: "passport number must contain only letters and digits"}
+		}
+	}
+	if !hasDigit {
+		return "", ValidationError{Field: "passportNumber", Message: "passport number must contain at least one digit"}
+	}
+	return v, nil
+}
+
+// NormalizeIdentifier validates and normalizes an identifier of the given kind
+func NormalizeIdentifier(kind IdentifierKind, s string) (string, error) {
+	switch kind {
+	case KindANumber:
+		return NormalizeANumber(s)
+	case KindUSCISAccount:
+		return NormalizeUSCISAccountNumber(s)
+	case KindI94:
+		return NormalizeI94Number(s)
+	case KindPassport:
+		return NormalizePassportNumber(s)
+	default:
+		return "", ValidationError{Field: string(kind), Message: "unknown identifier kind"}
+	}
+}
+
+// ValidateIdentifier reports whether s is a valid identifier of the given kind
+func ValidateIdentifier(kind IdentifierKind, s string) error {
+	_, err := NormalizeIdentifier(kind, s)
+	return err
+}
+
+// MaskIdentifier hides all but the last few characters of an identifier so
+// it can be logged or exported, e.g. "A*****6789". Valid identifiers are
+// normalized first; the A-Number prefix is kept since it is not sensitive.
+// Invalid input is masked as-is and never returned in full.
+func MaskIdentifier(kind IdentifierKind, s string) string {
+	v, err := NormalizeIdentifier(kind, s)
+	if err != nil {
+		v = compact(s)
+	}
+	if v == "" {
+		return ""
+	}
+
+	prefix := ""
+	if kind == KindANumber && strings.HasPrefix(v, "A") {
+		prefix, v = "A", v[1:]
+	}
+
+	// Show four characters of typical values and a third of short ones
+	visible := 4
+	if len(v) < 8 {
+		visible = len(v) / 3
+	}
+	return prefix + strings.Repeat("*", len(v)-visible) + v[len(v)-visible:]
+}
@@ -0,0 +1,114 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeIdentifier(t *testing.T) {
+	tests := []struct {
+		name   string
+		kind   IdentifierKind
+		input  string
+		want   string
+		errMsg string
+	}{
+		{"A-Number with prefix", KindANumber, "A123456789", "A123456789", ""},
+		{"A-Number with separators", KindANumber, "a-123-456-789", "A123456789", ""},
+		{"A-Number with hash", KindANumber, "A# 123 456 789", "A123456789", ""},
+		{"eight digit A-Number padded", KindANumber, "12345678", "A012345678", ""},
+		{"A-Number too short", KindANumber, "A1234567", "", "must have 8 or 9 digits"},
+		{"A-Number too long", KindANumber, "A1234567890", "", "must have 8 or 9 digits"},
+		{"A-Number with letters", KindANumber, "A12345678B", "", "only digits"},
+		{"A-Number all zeros", KindANumber, "A000000000", "", "cannot be all zeros"},
+		{"empty A-Number", KindANumber, "  ", "", "cannot be empty"},
+
+		{"account number", KindUSCISAccount, "1234 5678 9012", "123456789012", ""},
+		{"account number short", KindUSCISAccount, "12345678901", "", "exactly 12 digits"},
+		{"account number letters", KindUSCISAccount, "12345678901A", "", "exactly 12 digits"},
+
+		{"legacy I-94", KindI94, "12345678901", "12345678901", ""},
+		{"current I-94", KindI94, "123456789a1", "123456789A1", ""},
+		{"I-94 wrong length", KindI94, "123456789A", "", "must be 11 characters"},
+		{"I-94 letter misplaced", KindI94, "A2345678901", "", "9 digits, a letter and a digit"},
+
+		{"passport", KindPassport, "x1234567", "X1234567", ""},
+		{"passport with space", KindPassport, "AB 123 456", "AB123456", ""},
+		{"passport too short", KindPassport, "A1234", "", "between 6 and 12 characters"},
+		{"passport symbols", KindPassport, "AB12#456", "", "only letters and digits"},
+		{"passport without digits", KindPassport, "ABCDEFG", "", "at least one digit"},
+
+		{"unknown kind", "ssn", "123456789", "", "unknown identifier kind"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeIdentifier(tt.kind, tt.input)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Fatalf("NormalizeIdentifier() error = %v", err)
+				}
+				if got != tt.want {
+					t.Errorf("NormalizeIdentifier() = %q, want %q", got, tt.want)
+				}
+				return
+			}
+
+			var verr ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("NormalizeIdentifier() error = %v, want ValidationError", err)
+			}
+			if verr.Field == "" || !strings.Contains(verr.Message, tt.errMsg) {
+				t.Errorf("NormalizeIdentifier() error = %+v, want message containing %q", verr, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestMaskIdentifier(t *testing.T) {
+	tests := []struct {
+		kind  IdentifierKind
+		input string
+		want  string
+	}{
+		{KindANumber, "a-123-456-789", "A*****6789"},
+		{KindANumber, "12345678", "A*****5678"},
+		{KindUSCISAccount, "123456789012", "********9012"},
+		{KindI94, "123456789A1", "*******89A1"},
+		{KindPassport, "X1234567", "****4567"},
+		{KindPassport, "AB1234", "****34"},
+		{KindANumber, "not-an-id", "*****ID"},
+		{KindPassport, "", ""},
+	}
+
+	for _, tt := range tests {
+		got := MaskIdentifier(tt.kind, tt.input)
+		if got != tt.want {
+			t.Errorf("MaskIdentifier(%s, %q) = %q, want %q", tt.kind, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestIdentifierKindForField(t *testing.T) {
+	tests := []struct {
+		field  string
+		want   IdentifierKind
+		wantOK bool
+	}{
+		{"aNumber", KindANumber, true},
+		{"a_number", KindANumber, true},
+		{"Alien-Number", KindANumber, true},
+		{"uscisOnlineAccountNumber", KindUSCISAccount, true},
+		{"i94_number", KindI94, true},
+		{"PassportNumber", KindPassport, true},
+		{"caseNumber", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := IdentifierKindForField(tt.field)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("IdentifierKindForField(%q) = %q, %v; want %q, %v", tt.field, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
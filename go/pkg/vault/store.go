@@ -0,0 +1,61 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"MyUSCISgo/pkg/store"
+)
+
+// Keyring returns the vault's master keys as a store keyring that
+// encrypts with the current key and still reads records wrapped with the
+// previous ones
+func (v *Vault) Keyring() (*store.Keyring, error) {
+	current := v.CurrentKey()
+	var previous []store.Key
+	for _, k := range v.PreviousKeys() {
+		previous = append(previous, store.Key{ID: k.ID, Material: k.Material})
+	}
+	return store.NewKeyring(store.Key{ID: current.ID, Material: current.Material}, previous...)
+}
+
+// OpenBackend opens the store file at path, creating it and its directory
+// if needed, and encrypts it with the master keys of v unless v is nil.
+// Records written before the store was encrypted are encrypted and dropped
+// from the file's log. The file is returned as well so callers can compact
+// it, for example after rotating the keys.
+func OpenBackend(ctx context.Context, path string, v *Vault) (store.Backend, *store.File, error) {
+	var keys *store.Keyring
+	if v != nil {
+		var err error
+		if keys, err = v.Keyring(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+	file, err := store.OpenFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if keys == nil {
+		return file, file, nil
+	}
+	encrypted, err := store.OpenEncrypted(ctx, file, keys, store.EncryptExisting())
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if encrypted.Migrated() > 0 {
+		// drop the unencrypted records from the log
+		if err := file.Compact(); err != nil {
+			encrypted.Close()
+			return nil, nil, err
+		}
+	}
+	return encrypted, file, nil
+}
@@ -0,0 +1,381 @@
+// Package vault keeps secrets, such as USCIS API credentials and the master
+// keys that encrypt the case store, in a file sealed with a passphrase.
+//
+// The passphrase is stretched with PBKDF2-HMAC-SHA256 and the contents are
+// sealed with AES-256-GCM. Master keys are random and stored inside the
+// vault, so changing the passphrase does not change them; rotating a key
+// adds a new one and keeps the old ones until they are retired.
+//
+// OpenBackend opens the case store file encrypted with those keys; the
+// server and uscisctl both open their stores through it.
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"MyUSCISgo/pkg/clock"
+)
+
+const (
+	// DefaultIterations is the PBKDF2 iteration count for new vaults
+	DefaultIterations = 600_000
+	// MinPassphraseLength is the shortest passphrase accepted
+	MinPassphraseLength = 8
+	// KeySize is the length of master keys
+	KeySize = 32
+
+	fileVersion = 1
+	kdfName     = "pbkdf2-sha256"
+	saltSize    = 16
+)
+
+var (
+	// ErrWrongPassphrase is returned when a vault cannot be unsealed, either
+	// because the passphrase is wrong or the file was modified
+	ErrWrongPassphrase = errors.New("vault: wrong passphrase or damaged vault")
+	// ErrWeakPassphrase is returned for a passphrase shorter than
+	// MinPassphraseLength
+	ErrWeakPassphrase = errors.New("vault: passphrase is too short")
+	// ErrExists is returned when creating a vault over an existing file
+	ErrExists = errors.New("vault: file already exists")
+	// ErrNotFound is returned for a missing secret or key
+	ErrNotFound = errors.New("vault: not found")
+)
+
+// sealAAD binds the sealed contents to the vault format
+var sealAAD = []byte("myuscis/vault/v1")
+
+// Key is a master key kept in the vault
+type Key struct {
+	ID        string    `json:"id"`
+	Material  []byte    `json:"material"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// sealedFile is the vault as written to disk
+type sealedFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Sealed     []byte `json:"sealed"`
+}
+
+// contents is what the vault seals
+type contents struct {
+	Secrets map[string]string `json:"secrets"`
+	// Keys are ordered oldest first; the last one is current
+	Keys []Key `json:"keys"`
+}
+
+// Vault is an unsealed vault. Every change is written to its file before
+// the method returns.
+type Vault struct {
+	mu         sync.Mutex
+	path       string
+	iterations int
+	clock      clock.Clock
+	salt       []byte
+	aead       cipher.AEAD
+	contents   contents
+}
+
+// Option configures a Vault
+type Option func(*Vault)
+
+// WithIterations sets the PBKDF2 iteration count used when the passphrase
+// is set
+func WithIterations(n int) Option {
+	return func(v *Vault) {
+		v.iterations = n
+	}
+}
+
+// WithClock sets the clock used to date new keys
+func WithClock(c clock.Clock) Option {
+	return func(v *Vault) {
+		v.clock = c
+	}
+}
+
+func newVault(path string, opts []Option) *Vault {
+	v := &Vault{path: path, iterations: DefaultIterations, clock: clock.Real()}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Create creates a vault at path with a first master key
+func Create(path, passphrase string, opts ...Option) (*Vault, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrExists, path)
+	}
+	v := newVault(path, opts)
+	v.contents = contents{Secrets: make(map[string]string)}
+	if err := v.setPassphrase(passphrase); err != nil {
+		return nil, err
+	}
+	v.contents.Keys = []Key{v.newKey()}
+	if err := v.save(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Open unseals the vault at path
+func Open(path, passphrase string, opts ...Option) (*Vault, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("vault: failed to read %s: %w", path, err)
+	}
+	var f sealedFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("vault: %s is not a vault: %w", path, err)
+	}
+	if f.Version != fileVersion || f.KDF != kdfName {
+		return nil, fmt.Errorf("vault: unsupported vault version %d (%s)", f.Version, f.KDF)
+	}
+
+	v := newVault(path, opts)
+	v.iterations, v.salt = f.Iterations, f.Salt
+	if v.aead, err = deriveAEAD(passphrase, f.Salt, f.Iterations); err != nil {
+		return nil, err
+	}
+	plaintext, err := v.aead.Open(nil, nil, f.Sealed, sealAAD)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	if err := json.Unmarshal(plaintext, &v.contents); err != nil {
+		return nil, fmt.Errorf("vault: invalid contents: %w", err)
+	}
+	if v.contents.Secrets == nil {
+		v.contents.Secrets = make(map[string]string)
+	}
+	if len(v.contents.Keys) == 0 {
+		return nil, fmt.Errorf("vault: %s has no master key", path)
+	}
+	return v, nil
+}
+
+// Path returns the vault file
+func (v *Vault) Path() string {
+	return v.path
+}
+
+// Secret returns a stored secret
+func (v *Vault) Secret(name string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	value, ok := v.contents.Secrets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: secret %q", ErrNotFound, name)
+	}
+	return value, nil
+}
+
+// SetSecret stores a secret, replacing any previous value
+func (v *Vault) SetSecret(name, value string) error {
+	if name == "" {
+		return errors.New("vault: secret name is empty")
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	previous, had := v.contents.Secrets[name]
+	v.contents.Secrets[name] = value
+	if err := v.save(); err != nil {
+		if had {
+			v.contents.Secrets[name] = previous
+		} else {
+			delete(v.contents.Secrets, name)
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteSecret removes a secret
+func (v *Vault) DeleteSecret(name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	previous, ok := v.contents.Secrets[name]
+	if !ok {
+		return fmt.Errorf("%w: secret %q", ErrNotFound, name)
+	}
+	delete(v.contents.Secrets, name)
+	if err := v.save(); err != nil {
+		v.contents.Secrets[name] = previous
+		return err
+	}
+	return nil
+}
+
+// Secrets returns the names of the stored secrets in order
+func (v *Vault) Secrets() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	names := make([]string, 0, len(v.contents.Secrets))
+	for name := range v.contents.Secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CurrentKey returns the master key for new data
+func (v *Vault) CurrentKey() Key {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.contents.Keys[len(v.contents.Keys)-1]
+}
+
+// PreviousKeys returns the retained older master keys, newest first
+func (v *Vault) PreviousKeys() []Key {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	keys := slices.Clone(v.contents.Keys[:len(v.contents.Keys)-1])
+	slices.Reverse(keys)
+	return keys
+}
+
+// RotateKey adds a new current master key. The previous keys are kept so
+// data wrapped with them can still be read and rewrapped.
+func (v *Vault) RotateKey() (Key, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key := v.newKey()
+	v.contents.Keys = append(v.contents.Keys, key)
+	if err := v.save(); err != nil {
+		v.contents.Keys = v.contents.Keys[:len(v.contents.Keys)-1]
+		return Key{}, err
+	}
+	return key, nil
+}
+
+// RetireKeys removes every master key except the current one. Call it
+// once all data has been rewrapped with the current key; it returns the
+// number of keys removed.
+func (v *Vault) RetireKeys() (int, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	previous := v.contents.Keys
+	v.contents.Keys = previous[len(previous)-1:]
+	if err := v.save(); err != nil {
+		v.contents.Keys = previous
+		return 0, err
+	}
+	return len(previous) - 1, nil
+}
+
+// ChangePassphrase reseals the vault with a new passphrase and salt
+func (v *Vault) ChangePassphrase(passphrase string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	salt, aead := v.salt, v.aead
+	if err := v.setPassphrase(passphrase); err != nil {
+		return err
+	}
+	if err := v.save(); err != nil {
+		v.salt, v.aead = salt, aead
+		return err
+	}
+	return nil
+}
+
+// setPassphrase derives the sealing key from a passphrase and a new salt
+func (v *Vault) setPassphrase(passphrase string) error {
+	if len(passphrase) < MinPassphraseLength {
+		return fmt.Errorf("%w: use at least %d characters", ErrWeakPassphrase, MinPassphraseLength)
+	}
+	salt := make([]byte, saltSize)
+	rand.Read(salt)
+	aead, err := deriveAEAD(passphrase, salt, v.iterations)
+	if err != nil {
+		return err
+	}
+	v.salt, v.aead = salt, aead
+	return nil
+}
+
+// newKey generates a master key dated by the vault clock
+func (v *Vault) newKey() Key {
+	now := v.clock.Now().UTC()
+	material := make([]byte, KeySize)
+	rand.Read(material)
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return Key{
+		ID:        now.Format("20060102") + "-" + hex.EncodeToString(suffix),
+		Material:  material,
+		CreatedAt: now,
+	}
+}
+
+// save seals the contents and replaces the vault file atomically
+func (v *Vault) save() error {
+	plaintext, err := json.Marshal(v.contents)
+	if err != nil {
+		return fmt.Errorf("vault: failed to encode contents: %w", err)
+	}
+	data, err := json.MarshalIndent(sealedFile{
+		Version:    fileVersion,
+		KDF:        kdfName,
+		Iterations: v.iterations,
+		Salt:       v.salt,
+		Sealed:     v.aead.Seal(nil, nil, plaintext, sealAAD),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("vault: failed to encode file: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(v.path), "."+filepath.Base(v.path)+"-*")
+	if err != nil {
+		return fmt.Errorf("vault: failed to write %s: %w", v.path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("vault: failed to write %s: %w", v.path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("vault: failed to write %s: %w", v.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("vault: failed to write %s: %w", v.path, err)
+	}
+	if err := os.Rename(tmp.Name(), v.path); err != nil {
+		return fmt.Errorf("vault: failed to write %s: %w", v.path, err)
+	}
+	return nil
+}
+
+// deriveAEAD stretches a passphrase into an AES-256-GCM sealing key
+func deriveAEAD(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	if iterations < 1 {
+		return nil, fmt.Errorf("vault: invalid iteration count %d", iterations)
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("vault: failed to derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCMWithRandomNonce(block)
+}
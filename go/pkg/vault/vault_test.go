@@ -0,0 +1,245 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/store"
+)
+
+// fast keeps PBKDF2 cheap in tests
+var fast = WithIterations(1000)
+
+func create(t *testing.T) (*Vault, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "vault.json")
+	v, err := Create(path, "correct horse", fast)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return v, path
+}
+
+func TestCreateOpen(t *testing.T) {
+	v, path := create(t)
+	if err := v.SetSecret("client_secret", "s3cr3t-value"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("s3cr3t-value")) || bytes.Contains(data, []byte("client_secret")) {
+		t.Errorf("vault file holds secrets in the clear:\n%s", data)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("vault file mode = %v, %v; want 0600", info.Mode(), err)
+	}
+
+	opened, err := Open(path, "correct horse")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if got, err := opened.Secret("client_secret"); err != nil || got != "s3cr3t-value" {
+		t.Errorf("Secret() = %q, %v", got, err)
+	}
+	if got, want := opened.CurrentKey(), v.CurrentKey(); got.ID != want.ID || !bytes.Equal(got.Material, want.Material) {
+		t.Errorf("CurrentKey() = %s, want %s", got.ID, want.ID)
+	}
+	if len(opened.CurrentKey().Material) != KeySize {
+		t.Errorf("master key is %d bytes, want %d", len(opened.CurrentKey().Material), KeySize)
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	_, path := create(t)
+	if _, err := Create(path, "correct horse", fast); !errors.Is(err, ErrExists) {
+		t.Errorf("Create() over an existing vault error = %v, want ErrExists", err)
+	}
+	if _, err := Create(filepath.Join(t.TempDir(), "v"), "short", fast); !errors.Is(err, ErrWeakPassphrase) {
+		t.Errorf("Create() with a short passphrase error = %v, want ErrWeakPassphrase", err)
+	}
+	if _, err := Open(path, "wrong horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Open() with the wrong passphrase error = %v, want ErrWrongPassphrase", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	tampered := strings.Replace(string(data), `"sealed": "`, `"sealed": "AAAA`, 1)
+	if err := os.WriteFile(path, []byte(tampered), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path, "correct horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Open() of a modified vault error = %v, want ErrWrongPassphrase", err)
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "missing"), "correct horse"); err == nil {
+		t.Errorf("Open() of a missing vault succeeded")
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	v, path := create(t)
+	for _, name := range []string{"b", "a"} {
+		if err := v.SetSecret(name, "value "+name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := v.SetSecret("", "x"); err == nil {
+		t.Errorf("SetSecret() accepted an empty name")
+	}
+	if err := v.DeleteSecret("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.DeleteSecret("b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteSecret() of a missing secret error = %v, want ErrNotFound", err)
+	}
+
+	opened, err := Open(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := opened.Secrets(); len(got) != 1 || got[0] != "a" {
+		t.Errorf("Secrets() = %v, want [a]", got)
+	}
+	if _, err := opened.Secret("b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Secret() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	fake := clock.NewFake(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	path := filepath.Join(t.TempDir(), "vault.json")
+	v, err := Create(path, "correct horse", fast, WithClock(fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := v.CurrentKey()
+	if !strings.HasPrefix(first.ID, "20250301-") {
+		t.Errorf("key ID = %q, want it dated", first.ID)
+	}
+
+	second, err := v.RotateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.CurrentKey().ID != second.ID || bytes.Equal(second.Material, first.Material) {
+		t.Errorf("CurrentKey() = %s after rotating to %s", v.CurrentKey().ID, second.ID)
+	}
+	if prev := v.PreviousKeys(); len(prev) != 1 || prev[0].ID != first.ID {
+		t.Errorf("PreviousKeys() = %v, want the first key", prev)
+	}
+
+	opened, err := Open(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := opened.RetireKeys(); err != nil || n != 1 {
+		t.Errorf("RetireKeys() = %d, %v; want 1", n, err)
+	}
+	if len(opened.PreviousKeys()) != 0 || opened.CurrentKey().ID != second.ID {
+		t.Errorf("after RetireKeys() current = %s, previous = %v", opened.CurrentKey().ID, opened.PreviousKeys())
+	}
+}
+
+func TestChangePassphrase(t *testing.T) {
+	v, path := create(t)
+	key := v.CurrentKey()
+	if err := v.ChangePassphrase("short"); !errors.Is(err, ErrWeakPassphrase) {
+		t.Errorf("ChangePassphrase() error = %v, want ErrWeakPassphrase", err)
+	}
+	if err := v.ChangePassphrase("battery staple"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path, "correct horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("old passphrase still opens the vault: %v", err)
+	}
+	opened, err := Open(path, "battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened.CurrentKey().Material, key.Material) {
+		t.Errorf("changing the passphrase changed the master key")
+	}
+}
+
+func TestOpenBackend(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "data", "store.db")
+	put := func(backend store.Backend, key, value string) {
+		t.Helper()
+		err := backend.Update(ctx, func(tx store.Tx) error {
+			return tx.Put(store.BucketCases, key, []byte(value))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	get := func(backend store.Backend, key string) string {
+		t.Helper()
+		var value []byte
+		err := backend.View(ctx, func(tx store.Tx) error {
+			var err error
+			value, err = tx.Get(store.BucketCases, key)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", key, err)
+		}
+		return string(value)
+	}
+
+	// without a vault the store is a plain file, its directory created
+	backend, file, err := OpenBackend(ctx, path, nil)
+	if err != nil {
+		t.Fatalf("OpenBackend() error = %v", err)
+	}
+	if backend != store.Backend(file) {
+		t.Errorf("OpenBackend() without a vault = %T, want the file", backend)
+	}
+	put(backend, "plain", "EAC2190050123 in the clear")
+	backend.Close()
+	// the file log keeps values base64 encoded
+	clear := []byte(base64.StdEncoding.EncodeToString([]byte("EAC2190050123 in the clear")))
+	if data, err := os.ReadFile(path); err != nil || !bytes.Contains(data, clear) {
+		t.Fatalf("unencrypted store file = %q, %v", data, err)
+	}
+
+	// opening it with a vault encrypts what was there and compacts it away
+	v, _ := create(t)
+	backend, _, err = OpenBackend(ctx, path, v)
+	if err != nil {
+		t.Fatalf("OpenBackend() with a vault error = %v", err)
+	}
+	if _, ok := backend.(*store.Encrypted); !ok {
+		t.Fatalf("OpenBackend() with a vault = %T, want *store.Encrypted", backend)
+	}
+	backend.Close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, clear) {
+		t.Error("store file still holds the unencrypted record")
+	}
+
+	// records wrapped with a previous key stay readable after a rotation
+	if _, err := v.RotateKey(); err != nil {
+		t.Fatal(err)
+	}
+	backend, _, err = OpenBackend(ctx, path, v)
+	if err != nil {
+		t.Fatalf("OpenBackend() after rotation error = %v", err)
+	}
+	defer backend.Close()
+	if got := get(backend, "plain"); got != "EAC2190050123 in the clear" {
+		t.Errorf("Get() after rotation = %q", got)
+	}
+}
@@ -0,0 +1,136 @@
+package visabulletin
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotEnoughHistory is returned when a movement rate needs more bulletins
+var ErrNotEnoughHistory = errors.New("not enough dated bulletins to compute movement")
+
+// History keeps bulletins ordered by month. It is safe for concurrent use.
+type History struct {
+	mu        sync.RWMutex
+	bulletins []*Bulletin
+}
+
+// NewHistory creates a history from the given bulletins
+func NewHistory(bulletins ...*Bulletin) *History {
+	h := &History{}
+	for _, b := range bulletins {
+		h.Add(b)
+	}
+	return h
+}
+
+// Add stores a bulletin, replacing any bulletin for the same month
+func (h *History) Add(b *Bulletin) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	i := sort.Search(len(h.bulletins), func(i int) bool { return !h.bulletins[i].Month.Before(b.Month) })
+	if i < len(h.bulletins) && h.bulletins[i].Month.Equal(b.Month) {
+		h.bulletins[i] = b
+		return
+	}
+	h.bulletins = append(h.bulletins, nil)
+	copy(h.bulletins[i+1:], h.bulletins[i:])
+	h.bulletins[i] = b
+}
+
+// Len returns the number of bulletins
+func (h *History) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.bulletins)
+}
+
+// Latest returns the most recent bulletin
+func (h *History) Latest() (*Bulletin, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.bulletins) == 0 {
+		return nil, false
+	}
+	return h.bulletins[len(h.bulletins)-1], true
+}
+
+// Month returns the bulletin for the month containing t
+func (h *History) Month(t time.Time) (*Bulletin, bool) {
+	month := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, b := range h.bulletins {
+		if b.Month.Equal(month) {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// Movement summarizes how a cutoff date moved across bulletins
+type Movement struct {
+	Chart    Chart     `json:"chart"`
+	Category Category  `json:"category"`
+	Country  Country   `json:"country"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Months   int       `json:"months"`
+	// DaysAdvanced is the net change of the cutoff date; negative means retrogression
+	DaysAdvanced int `json:"daysAdvanced"`
+	// DaysPerMonth is the average movement per bulletin month
+	DaysPerMonth float64 `json:"daysPerMonth"`
+}
+
+// Movement computes the movement rate of a cutoff over the last window
+// bulletins (all bulletins when window <= 0). Months where the category
+// was current or unavailable are skipped.
+func (h *History) Movement(chart Chart, category Category, country Country, window int) (*Movement, error) {
+	h.mu.RLock()
+	bulletins := h.bulletins
+	if window > 0 && len(bulletins) > window {
+		bulletins = bulletins[len(bulletins)-window:]
+	}
+	type point struct{ month, cutoff time.Time }
+	var points []point
+	for _, b := range bulletins {
+		c, ok := b.Cutoff(chart, category, country)
+		if ok && !c.Current && !c.Unavailable {
+			points = append(points, point{b.Month, c.Date})
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(points) < 2 {
+		return nil, ErrNotEnoughHistory
+	}
+	first, last := points[0], points[len(points)-1]
+	months := (last.month.Year()-first.month.Year())*12 + int(last.month.Month()-first.month.Month())
+	days := int(last.cutoff.Sub(first.cutoff).Hours() / 24)
+	return &Movement{
+		Chart:        chart,
+		Category:     category,
+		Country:      country,
+		From:         first.month,
+		To:           last.month,
+		Months:       months,
+		DaysAdvanced: days,
+		DaysPerMonth: float64(days) / float64(months),
+	}, nil
+}
+
+// ProjectCurrent estimates the bulletin month in which a currency check
+// would become current at the given movement rate. It returns false when
+// the cutoff is not advancing.
+func (m *Movement) ProjectCurrent(c *Currency) (time.Time, bool) {
+	if c.Current {
+		return c.Month, true
+	}
+	if m.DaysPerMonth <= 0 || c.ShortByDays == 0 {
+		return time.Time{}, false
+	}
+	months := int(float64(c.ShortByDays)/m.DaysPerMonth + 0.999999)
+	return c.Month.AddDate(0, months, 0), true
+}
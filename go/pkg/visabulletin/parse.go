@@ -0,0 +1,210 @@
+package visabulletin
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	tableRegex   = regexp.MustCompile(`(?is)<table\b.*?</table>`)
+	rowRegex     = regexp.MustCompile(`(?is)<tr\b.*?</tr>`)
+	cellRegex    = regexp.MustCompile(`(?is)<t[dh]\b[^>]*>(.*?)</t[dh]>`)
+	tagRegex     = regexp.MustCompile(`(?s)<[^>]*>`)
+	monthRegex   = regexp.MustCompile(`(?i)visa bulletin for\s+([a-z]+)\s+(\d{4})`)
+	finalAction  = regexp.MustCompile(`(?i)final\s+action\s+dates`)
+	datesForFile = regexp.MustCompile(`(?i)dates\s+for\s+filing`)
+)
+
+// ErrNoTables is returned when a document contains no recognizable charts
+var ErrNoTables = errors.New("no visa bulletin charts found")
+
+// ParseHTML parses a saved visa bulletin page. The chart of each table is
+// taken from the nearest preceding "Final Action Dates" or "Dates for
+// Filing" heading. When month is zero it is read from the page title.
+func ParseHTML(r io.Reader, month time.Time) (*Bulletin, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bulletin: %w", err)
+	}
+	doc := string(data)
+
+	if month.IsZero() {
+		m := monthRegex.FindStringSubmatch(plainText(doc))
+		if m == nil {
+			return nil, errors.New("bulletin month not found; pass it explicitly")
+		}
+		month, err = time.Parse("January 2006", m[1]+" "+m[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulletin month %q: %w", m[0], err)
+		}
+	}
+
+	bulletin := NewBulletin(month)
+	var chart Chart
+	last := 0
+	for _, loc := range tableRegex.FindAllStringIndex(doc, -1) {
+		chart = chartFromHeading(plainText(doc[last:loc[0]]), chart)
+		last = loc[1]
+		if chart == "" {
+			continue
+		}
+		if err := parseTable(bulletin, chart, doc[loc[0]:loc[1]]); err != nil {
+			return nil, err
+		}
+	}
+	if len(bulletin.Charts) == 0 {
+		return nil, ErrNoTables
+	}
+	return bulletin, nil
+}
+
+// chartFromHeading returns the chart named last in text, or current if none is
+func chartFromHeading(text string, current Chart) Chart {
+	fa := lastIndex(finalAction, text)
+	df := lastIndex(datesForFile, text)
+	switch {
+	case fa < 0 && df < 0:
+		return current
+	case fa > df:
+		return ChartFinalAction
+	default:
+		return ChartDatesForFiling
+	}
+}
+
+func lastIndex(re *regexp.Regexp, s string) int {
+	all := re.FindAllStringIndex(s, -1)
+	if len(all) == 0 {
+		return -1
+	}
+	return all[len(all)-1][0]
+}
+
+// parseTable reads a chart whose first row holds the country headers.
+// Tables that are not preference charts and columns of unknown countries
+// are skipped.
+func parseTable(b *Bulletin, chart Chart, table string) error {
+	rows := rowRegex.FindAllString(table, -1)
+	if len(rows) < 2 {
+		return nil
+	}
+	header := cells(rows[0])
+	if len(header) < 2 {
+		return nil
+	}
+	kind := strings.ToLower(header[0])
+	if !strings.Contains(kind, "family") && !strings.Contains(kind, "employment") {
+		return nil
+	}
+
+	countries := make([]Country, len(header))
+	for i, h := range header[1:] {
+		countries[i+1], _ = NormalizeCountry(h)
+	}
+
+	for _, row := range rows[1:] {
+		cols := cells(row)
+		if len(cols) < 2 {
+			continue
+		}
+		category, ok := NormalizeCategory(cols[0])
+		if !ok {
+			continue
+		}
+		for i := 1; i < len(cols) && i < len(countries); i++ {
+			if countries[i] == "" {
+				continue
+			}
+			cutoff, err := ParseCutoff(cols[i])
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", category, countries[i], err)
+			}
+			b.Set(chart, category, countries[i], cutoff)
+		}
+	}
+	return nil
+}
+
+func cells(row string) []string {
+	matches := cellRegex.FindAllStringSubmatch(row, -1)
+	out := make([]string, len(matches))
+	for i, m := range matches {
+		out[i] = plainText(m[1])
+	}
+	return out
+}
+
+func plainText(s string) string {
+	s = tagRegex.ReplaceAllString(s, " ")
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
+
+// ParseCSV parses cutoffs with the columns month, chart, category, country
+// and cutoff, for example "2025-06,final_action,F2A,MEXICO,01FEB22". A
+// header row is optional. Rows are grouped into one bulletin per month,
+// returned oldest first.
+func ParseCSV(r io.Reader) ([]*Bulletin, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 5
+	reader.TrimLeadingSpace = true
+
+	byMonth := make(map[time.Time]*Bulletin)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bulletin CSV: %w", err)
+		}
+		if line == 1 && strings.EqualFold(record[0], "month") {
+			continue
+		}
+
+		month, err := time.Parse("2006-01", record[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid month %q", line, record[0])
+		}
+		chart := Chart(strings.ToLower(record[1]))
+		if chart != ChartFinalAction && chart != ChartDatesForFiling {
+			return nil, fmt.Errorf("line %d: unknown chart %q", line, record[1])
+		}
+		category, ok := NormalizeCategory(record[2])
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown category %q", line, record[2])
+		}
+		country, ok := NormalizeCountry(record[3])
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown country %q", line, record[3])
+		}
+		cutoff, err := ParseCutoff(record[4])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		b, ok := byMonth[month]
+		if !ok {
+			b = NewBulletin(month)
+			byMonth[month] = b
+		}
+		b.Set(chart, category, country, cutoff)
+	}
+	if len(byMonth) == 0 {
+		return nil, ErrNoTables
+	}
+
+	out := make([]*Bulletin, 0, len(byMonth))
+	for _, b := range byMonth {
+		out = append(out, b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Month.Before(out[j].Month) })
+	return out, nil
+}
@@ -0,0 +1,228 @@
+package visabulletin
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Chart identifies one of the two charts published in each bulletin
+type Chart string
+
+const (
+	// ChartFinalAction lists the dates when a visa or green card can be issued
+	ChartFinalAction Chart = "final_action"
+	// ChartDatesForFiling lists the dates when an application can be filed
+	ChartDatesForFiling Chart = "dates_for_filing"
+)
+
+// Category is a preference category such as F2A or EB2
+type Category string
+
+const (
+	F1  Category = "F1"
+	F2A Category = "F2A"
+	F2B Category = "F2B"
+	F3  Category = "F3"
+	F4  Category = "F4"
+	EB1 Category = "EB1"
+	EB2 Category = "EB2"
+	EB3 Category = "EB3"
+	EW  Category = "EW"
+	EB4 Category = "EB4"
+	RW  Category = "RW"
+	EB5 Category = "EB5"
+)
+
+// Country is a chargeability area column in the bulletin
+type Country string
+
+const (
+	CountryAll         Country = "ALL"
+	CountryChina       Country = "CHINA"
+	CountryIndia       Country = "INDIA"
+	CountryMexico      Country = "MEXICO"
+	CountryPhilippines Country = "PHILIPPINES"
+	// CountryElSalvador covers the combined El Salvador, Guatemala and Honduras column
+	CountryElSalvador Country = "EL_SALVADOR_GUATEMALA_HONDURAS"
+)
+
+// NormalizeCategory maps bulletin row labels and user input onto a Category
+func NormalizeCategory(s string) (Category, bool) {
+	key := strings.ToUpper(strings.Join(strings.Fields(s), " "))
+	key = strings.TrimSuffix(key, "*")
+	switch {
+	case key == "F1" || key == "F-1":
+		return F1, true
+	case key == "F2A" || key == "F-2A":
+		return F2A, true
+	case key == "F2B" || key == "F-2B":
+		return F2B, true
+	case key == "F3" || key == "F-3":
+		return F3, true
+	case key == "F4" || key == "F-4":
+		return F4, true
+	case key == "EB1" || key == "EB-1" || key == "1ST":
+		return EB1, true
+	case key == "EB2" || key == "EB-2" || key == "2ND":
+		return EB2, true
+	case key == "EB3" || key == "EB-3" || key == "3RD":
+		return EB3, true
+	case key == "EW" || strings.HasPrefix(key, "OTHER WORKERS"):
+		return EW, true
+	case key == "EB4" || key == "EB-4" || key == "4TH":
+		return EB4, true
+	case key == "RW" || strings.HasPrefix(key, "CERTAIN RELIGIOUS WORKERS"):
+		return RW, true
+	case key == "EB5" || key == "EB-5" || strings.HasPrefix(key, "5TH UNRESERVED"):
+		return EB5, true
+	}
+	return "", false
+}
+
+// NormalizeCountry maps bulletin column headers and user input onto a Country.
+// It reports false for names that are not a column of the bulletin; callers
+// decide whether those are charged to CountryAll.
+func NormalizeCountry(s string) (Country, bool) {
+	key := strings.ToUpper(strings.Join(strings.Fields(s), " "))
+	switch {
+	case key == string(CountryAll), strings.HasPrefix(key, "ALL CHARGEABILITY"):
+		return CountryAll, true
+	case strings.HasPrefix(key, "CHINA"), key == "CN":
+		return CountryChina, true
+	case key == "INDIA", key == "IN":
+		return CountryIndia, true
+	case key == "MEXICO", key == "MX":
+		return CountryMexico, true
+	case key == "PHILIPPINES", key == "PH":
+		return CountryPhilippines, true
+	case strings.HasPrefix(key, "EL SALVADOR"), key == string(CountryElSalvador),
+		key == "GUATEMALA", key == "HONDURAS", key == "SV", key == "GT", key == "HN":
+		return CountryElSalvador, true
+	}
+	return "", false
+}
+
+// Cutoff is a single cell of a bulletin chart
+type Cutoff struct {
+	// Current means the category is current ("C") for all priority dates
+	Current bool `json:"current,omitempty"`
+	// Unavailable means no visas are available ("U")
+	Unavailable bool      `json:"unavailable,omitempty"`
+	Date        time.Time `json:"date,omitzero"`
+}
+
+// String formats the cutoff the way the bulletin prints it
+func (c Cutoff) String() string {
+	switch {
+	case c.Current:
+		return "C"
+	case c.Unavailable:
+		return "U"
+	default:
+		return strings.ToUpper(c.Date.Format("02Jan06"))
+	}
+}
+
+// ParseCutoff parses a bulletin cell such as "C", "U", "01JAN23" or "2023-01-01"
+func ParseCutoff(s string) (Cutoff, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	switch s {
+	case "C":
+		return Cutoff{Current: true}, nil
+	case "U":
+		return Cutoff{Unavailable: true}, nil
+	}
+	for _, layout := range []string{"02Jan06", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return Cutoff{Date: t}, nil
+		}
+	}
+	return Cutoff{}, fmt.Errorf("invalid cutoff %q", s)
+}
+
+// Table holds the cutoffs of one chart keyed by category and country
+type Table map[Category]map[Country]Cutoff
+
+// Bulletin is a single month's visa bulletin
+type Bulletin struct {
+	Month  time.Time       `json:"month"`
+	Charts map[Chart]Table `json:"charts"`
+}
+
+// NewBulletin creates an empty bulletin for the month containing t
+func NewBulletin(t time.Time) *Bulletin {
+	return &Bulletin{
+		Month:  time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC),
+		Charts: make(map[Chart]Table),
+	}
+}
+
+// Set records a cutoff
+func (b *Bulletin) Set(chart Chart, category Category, country Country, cutoff Cutoff) {
+	table, ok := b.Charts[chart]
+	if !ok {
+		table = make(Table)
+		b.Charts[chart] = table
+	}
+	row, ok := table[category]
+	if !ok {
+		row = make(map[Country]Cutoff)
+		table[category] = row
+	}
+	row[country] = cutoff
+}
+
+// Cutoff returns the cutoff for a category and country, falling back to
+// the all-chargeability column for countries without their own column
+func (b *Bulletin) Cutoff(chart Chart, category Category, country Country) (Cutoff, bool) {
+	row, ok := b.Charts[chart][category]
+	if !ok {
+		return Cutoff{}, false
+	}
+	if c, ok := row[country]; ok {
+		return c, true
+	}
+	c, ok := row[CountryAll]
+	return c, ok
+}
+
+// Currency answers whether a priority date is current in a bulletin
+type Currency struct {
+	Month    time.Time `json:"month"`
+	Chart    Chart     `json:"chart"`
+	Category Category  `json:"category"`
+	Country  Country   `json:"country"`
+	Cutoff   string    `json:"cutoff"`
+	Current  bool      `json:"current"`
+	// ShortByDays is how many days the priority date is behind the cutoff;
+	// zero when current or when no visas are available
+	ShortByDays int `json:"shortByDays"`
+}
+
+// Check reports whether a priority date is current. Per the bulletin, a
+// case is current when its priority date is earlier than the listed date.
+func (b *Bulletin) Check(priorityDate time.Time, chart Chart, category Category, country Country) (*Currency, error) {
+	cutoff, ok := b.Cutoff(chart, category, country)
+	if !ok {
+		return nil, fmt.Errorf("bulletin for %s has no %s cutoff for %s", b.Month.Format("January 2006"), chart, category)
+	}
+
+	result := &Currency{
+		Month:    b.Month,
+		Chart:    chart,
+		Category: category,
+		Country:  country,
+		Cutoff:   cutoff.String(),
+	}
+	switch {
+	case cutoff.Current:
+		result.Current = true
+	case cutoff.Unavailable:
+	case priorityDate.Before(cutoff.Date):
+		result.Current = true
+	default:
+		result.ShortByDays = int(priorityDate.Sub(cutoff.Date).Hours()/24) + 1
+	}
+	return result, nil
+}
@@ -0,0 +1,298 @@
+package visabulletin
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleHTML = `<html><head><title>Visa Bulletin For June 2025</title></head><body>
+<p><b>A.  FINAL ACTION DATES FOR FAMILY-SPONSORED PREFERENCE CASES</b></p>
+<table border="1">
+<tr><td>Family-<br>Sponsored</td><td>All Chargeability Areas Except Those Listed</td><td>CHINA-mainland born</td><td>INDIA</td><td>MEXICO</td><td>PHILIPPINES</td></tr>
+<tr><td>F1</td><td>08NOV16</td><td>08NOV16</td><td>08NOV16</td><td>22APR05</td><td>01MAR12</td></tr>
+<tr><td>F2A</td><td>01JAN22</td><td>01JAN22</td><td>01JAN22</td><td>01FEB21</td><td>01JAN22</td></tr>
+</table>
+<p><b>B.  DATES FOR FILING FAMILY-SPONSORED VISA APPLICATIONS</b></p>
+<table>
+<tr><th>Family-Sponsored</th><th>All Chargeability Areas Except Those Listed</th><th>CHINA-mainland born</th><th>INDIA</th><th>MEXICO</th><th>PHILIPPINES</th></tr>
+<tr><td>F2A</td><td>C</td><td>C</td><td>C</td><td>01JAN22</td><td>C</td></tr>
+</table>
+<p>A. FINAL ACTION DATES FOR EMPLOYMENT-BASED PREFERENCE CASES</p>
+<table>
+<tr><td>Employment-based</td><td>All Chargeability Areas Except Those Listed</td><td>CHINA-mainland born</td><td>EL SALVADOR GUATEMALA HONDURAS</td><td>INDIA</td><td>MEXICO</td><td>PHILIPPINES</td></tr>
+<tr><td>1st</td><td>C</td><td>15NOV22</td><td>C</td><td>15FEB22</td><td>C</td><td>C</td></tr>
+<tr><td>2nd</td><td>01APR23</td><td>01JAN21</td><td>01APR23</td><td>01JAN13</td><td>01APR23</td><td>01APR23</td></tr>
+<tr><td>Certain Religious Workers</td><td>U</td><td>U</td><td>U</td><td>U</td><td>U</td><td>U</td></tr>
+<tr><td>5th Set Aside:<br>Rural (20%)</td><td>C</td><td>C</td><td>C</td><td>C</td><td>C</td><td>C</td></tr>
+</table>
+</body></html>`
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseCutoff(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Cutoff
+		wantErr bool
+	}{
+		{"C", Cutoff{Current: true}, false},
+		{" u ", Cutoff{Unavailable: true}, false},
+		{"01JAN23", Cutoff{Date: date("2023-01-01")}, false},
+		{"15feb22", Cutoff{Date: date("2022-02-15")}, false},
+		{"2021-06-08", Cutoff{Date: date("2021-06-08")}, false},
+		{"soon", Cutoff{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseCutoff(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCutoff(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCutoff(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	categories := map[string]Category{
+		"F2A":                       F2A,
+		"eb-2":                      EB2,
+		"2nd":                       EB2,
+		"Other Workers":             EW,
+		"5th Unreserved (C5)":       EB5,
+		"Certain Religious Workers": RW,
+	}
+	for in, want := range categories {
+		if got, ok := NormalizeCategory(in); !ok || got != want {
+			t.Errorf("NormalizeCategory(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+	if _, ok := NormalizeCategory("5th Set Aside: Rural (20%)"); ok {
+		t.Error("set-aside rows should not map to a category")
+	}
+
+	countries := map[string]Country{
+		"CHINA-mainland born":            CountryChina,
+		"in":                             CountryIndia,
+		"EL SALVADOR GUATEMALA HONDURAS": CountryElSalvador,
+		"All Chargeability Areas Except Those Listed": CountryAll,
+		"all": CountryAll,
+	}
+	for in, want := range countries {
+		if got, ok := NormalizeCountry(in); !ok || got != want {
+			t.Errorf("NormalizeCountry(%q) = %q, %v, want %q", in, got, ok, want)
+		}
+	}
+	for _, in := range []string{"Brazil", "VIETNAM", ""} {
+		if got, ok := NormalizeCountry(in); ok {
+			t.Errorf("NormalizeCountry(%q) = %q, want not ok", in, got)
+		}
+	}
+}
+
+func TestParseHTML(t *testing.T) {
+	b, err := ParseHTML(strings.NewReader(sampleHTML), time.Time{})
+	if err != nil {
+		t.Fatalf("ParseHTML() error = %v", err)
+	}
+	if want := date("2025-06-01"); !b.Month.Equal(want) {
+		t.Errorf("Month = %v, want %v", b.Month, want)
+	}
+
+	tests := []struct {
+		chart    Chart
+		category Category
+		country  Country
+		want     string
+	}{
+		{ChartFinalAction, F1, CountryMexico, "22APR05"},
+		{ChartFinalAction, F2A, CountryAll, "01JAN22"},
+		{ChartFinalAction, F2A, "BRAZIL", "01JAN22"},
+		{ChartDatesForFiling, F2A, CountryIndia, "C"},
+		{ChartDatesForFiling, F2A, CountryMexico, "01JAN22"},
+		{ChartFinalAction, EB1, CountryChina, "15NOV22"},
+		{ChartFinalAction, EB2, CountryIndia, "01JAN13"},
+		{ChartFinalAction, EB2, CountryElSalvador, "01APR23"},
+		{ChartFinalAction, RW, CountryAll, "U"},
+	}
+	for _, tt := range tests {
+		got, ok := b.Cutoff(tt.chart, tt.category, tt.country)
+		if !ok {
+			t.Errorf("Cutoff(%s, %s, %s) missing", tt.chart, tt.category, tt.country)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("Cutoff(%s, %s, %s) = %s, want %s", tt.chart, tt.category, tt.country, got, tt.want)
+		}
+	}
+
+	if _, ok := b.Cutoff(ChartDatesForFiling, F1, CountryAll); ok {
+		t.Error("F1 should not be present in the dates-for-filing chart")
+	}
+
+	// a column for a country the parser does not know is skipped rather
+	// than overwriting the worldwide cutoffs
+	withNewColumn := strings.Replace(sampleHTML, "<td>PHILIPPINES</td></tr>", "<td>PHILIPPINES</td><td>VIETNAM</td></tr>", 1)
+	withNewColumn = strings.Replace(withNewColumn, "<td>01MAR12</td></tr>", "<td>01MAR12</td><td>01JAN99</td></tr>", 1)
+	b, err = ParseHTML(strings.NewReader(withNewColumn), time.Time{})
+	if err != nil {
+		t.Fatalf("ParseHTML() with an unknown column error = %v", err)
+	}
+	if got, _ := b.Cutoff(ChartFinalAction, F1, CountryAll); got.String() != "08NOV16" {
+		t.Errorf("Cutoff(F1, ALL) = %s after an unknown column, want 08NOV16", got)
+	}
+	if n := len(b.Charts[ChartFinalAction][F1]); n != 5 {
+		t.Errorf("F1 has %d countries, want 5", n)
+	}
+}
+
+func TestParseHTMLErrors(t *testing.T) {
+	if _, err := ParseHTML(strings.NewReader("<table></table>"), time.Time{}); err == nil {
+		t.Error("expected an error when the month cannot be found")
+	}
+	if _, err := ParseHTML(strings.NewReader("<p>nothing here</p>"), date("2025-06-01")); !errors.Is(err, ErrNoTables) {
+		t.Errorf("expected ErrNoTables, got %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	b, err := ParseHTML(strings.NewReader(sampleHTML), time.Time{})
+	if err != nil {
+		t.Fatalf("ParseHTML() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		pd          string
+		chart       Chart
+		category    Category
+		country     Country
+		wantCurrent bool
+		wantShortBy int
+		wantErr     bool
+	}{
+		{"before cutoff", "2021-12-31", ChartFinalAction, F2A, CountryAll, true, 0, false},
+		{"on cutoff", "2022-01-01", ChartFinalAction, F2A, CountryAll, false, 1, false},
+		{"after cutoff", "2022-01-31", ChartFinalAction, F2A, CountryAll, false, 31, false},
+		{"country column dated", "2022-03-01", ChartFinalAction, EB1, CountryIndia, false, 15, false},
+		{"listed current", "2024-05-01", ChartFinalAction, EB1, CountryAll, true, 0, false},
+		{"unavailable", "2000-01-01", ChartFinalAction, RW, CountryAll, false, 0, false},
+		{"missing category", "2020-01-01", ChartDatesForFiling, EB5, CountryAll, false, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := b.Check(date(tt.pd), tt.chart, tt.category, tt.country)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Current != tt.wantCurrent || got.ShortByDays != tt.wantShortBy {
+				t.Errorf("Check() = current %v short by %d, want current %v short by %d",
+					got.Current, got.ShortByDays, tt.wantCurrent, tt.wantShortBy)
+			}
+		})
+	}
+}
+
+const sampleCSV = `month,chart,category,country,cutoff
+# EB2 India final action dates
+2025-01,final_action,EB2,INDIA,01JAN12
+2025-03,final_action,EB2,INDIA,15JUL12
+2025-02,final_action,EB2,INDIA,01APR12
+2025-04,final_action,EB2,INDIA,01JAN13
+2025-04,final_action,EB2,ALL,C
+`
+
+func TestParseCSV(t *testing.T) {
+	bulletins, err := ParseCSV(strings.NewReader(sampleCSV))
+	if err != nil {
+		t.Fatalf("ParseCSV() error = %v", err)
+	}
+	if len(bulletins) != 4 {
+		t.Fatalf("expected 4 bulletins, got %d", len(bulletins))
+	}
+	for i := 1; i < len(bulletins); i++ {
+		if !bulletins[i-1].Month.Before(bulletins[i].Month) {
+			t.Errorf("bulletins not sorted at %d", i)
+		}
+	}
+
+	bad := []string{
+		"2025-13,final_action,EB2,INDIA,C\n",
+		"2025-01,approved,EB2,INDIA,C\n",
+		"2025-01,final_action,EB9,INDIA,C\n",
+		"2025-01,final_action,EB2,INDIA,later\n",
+		"2025-01,final_action,EB2,VIETNAM,C\n",
+		"",
+	}
+	for _, in := range bad {
+		if _, err := ParseCSV(strings.NewReader(in)); err == nil {
+			t.Errorf("ParseCSV(%q) expected error", in)
+		}
+	}
+}
+
+func TestHistoryMovement(t *testing.T) {
+	bulletins, err := ParseCSV(strings.NewReader(sampleCSV))
+	if err != nil {
+		t.Fatalf("ParseCSV() error = %v", err)
+	}
+	// Add out of order to exercise sorting and replacement
+	h := NewHistory(bulletins[3], bulletins[0], bulletins[2], bulletins[1], bulletins[0])
+	if h.Len() != 4 {
+		t.Fatalf("Len() = %d, want 4", h.Len())
+	}
+	latest, ok := h.Latest()
+	if !ok || !latest.Month.Equal(date("2025-04-01")) {
+		t.Fatalf("Latest() = %v, want April 2025", latest)
+	}
+	if _, ok := h.Month(date("2025-02-17")); !ok {
+		t.Error("Month() should find February 2025")
+	}
+
+	m, err := h.Movement(ChartFinalAction, EB2, CountryIndia, 0)
+	if err != nil {
+		t.Fatalf("Movement() error = %v", err)
+	}
+	if m.Months != 3 || m.DaysAdvanced != 366 {
+		t.Errorf("Movement() = %d months, %d days; want 3 months, 366 days", m.Months, m.DaysAdvanced)
+	}
+	if m.DaysPerMonth != 122 {
+		t.Errorf("DaysPerMonth = %v, want 122", m.DaysPerMonth)
+	}
+
+	windowed, err := h.Movement(ChartFinalAction, EB2, CountryIndia, 2)
+	if err != nil {
+		t.Fatalf("Movement(window 2) error = %v", err)
+	}
+	if windowed.Months != 1 || windowed.DaysAdvanced != 170 {
+		t.Errorf("Movement(window 2) = %d months, %d days; want 1 month, 170 days", windowed.Months, windowed.DaysAdvanced)
+	}
+
+	if _, err := h.Movement(ChartFinalAction, EB2, CountryAll, 0); !errors.Is(err, ErrNotEnoughHistory) {
+		t.Errorf("expected ErrNotEnoughHistory for a current category, got %v", err)
+	}
+
+	c, err := latest.Check(date("2013-03-01"), ChartFinalAction, EB2, CountryIndia)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	projected, ok := m.ProjectCurrent(c)
+	if !ok || !projected.Equal(date("2025-05-01")) {
+		t.Errorf("ProjectCurrent() = %v, %v; want May 2025", projected, ok)
+	}
+}
@@ -0,0 +1,75 @@
+package watchlist
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrNotFound is returned when a receipt number is not on the watchlist
+	ErrNotFound = errors.New("receipt is not on the watchlist")
+	// ErrAlreadyWatched is returned when adding a receipt that is already watched
+	ErrAlreadyWatched = errors.New("receipt is already on the watchlist")
+)
+
+// Storage persists watchlist entries keyed by receipt number. Get and List
+// return copies, so callers may modify the entries they receive.
+type Storage interface {
+	Get(ctx context.Context, receiptNumber string) (*Entry, error)
+	Put(ctx context.Context, entry *Entry) error
+	Delete(ctx context.Context, receiptNumber string) error
+	List(ctx context.Context) ([]*Entry, error)
+}
+
+// MemoryStorage is an in-memory Storage that is safe for concurrent use
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	entries map[string]*Entry
+}
+
+// NewMemoryStorage creates an empty in-memory store
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{entries: make(map[string]*Entry)}
+}
+
+// Get returns the entry for a receipt number
+func (m *MemoryStorage) Get(ctx context.Context, receiptNumber string) (*Entry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, ok := m.entries[receiptNumber]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return e.clone(), nil
+}
+
+// Put inserts or replaces an entry
+func (m *MemoryStorage) Put(ctx context.Context, entry *Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[entry.ReceiptNumber] = entry.clone()
+	return nil
+}
+
+// Delete removes an entry
+func (m *MemoryStorage) Delete(ctx context.Context, receiptNumber string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.entries[receiptNumber]; !ok {
+		return ErrNotFound
+	}
+	delete(m.entries, receiptNumber)
+	return nil
+}
+
+// List returns every entry in no particular order
+func (m *MemoryStorage) List(ctx context.Context) ([]*Entry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]*Entry, 0, len(m.entries))
+	for _, e := range m.entries {
+		out = append(out, e.clone())
+	}
+	return out, nil
+}
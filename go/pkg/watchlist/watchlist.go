@@ -0,0 +1,339 @@
+// Package watchlist tracks the cases a user follows, together with the last
+// known status and timeline of each.
+package watchlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/receipt"
+	"MyUSCISgo/pkg/types"
+	"MyUSCISgo/pkg/validation"
+)
+
+const (
+	// MaxLabelLength bounds entry labels
+	MaxLabelLength = 100
+	// MaxNotesLength bounds entry notes
+	MaxNotesLength = 2000
+	// MaxOwnerLength bounds entry owners
+	MaxOwnerLength = 100
+)
+
+// Entry is a single watched case
+type Entry struct {
+	ReceiptNumber string              `json:"receiptNumber"`
+	Label         string              `json:"label,omitempty"`
+	Notes         string              `json:"notes,omitempty"`
+	Owner         string              `json:"owner,omitempty"`
+	FormType      string              `json:"formType,omitempty"`
+	ServiceCenter string              `json:"serviceCenter,omitempty"`
+	LastStatus    *types.CaseStatus   `json:"lastStatus,omitempty"`
+	Timeline      *types.CaseTimeline `json:"timeline,omitempty"`
+	AddedAt       time.Time           `json:"addedAt"`
+	UpdatedAt     time.Time           `json:"updatedAt"`
+	LastCheckedAt time.Time           `json:"lastCheckedAt,omitzero"`
+}
+
+// clone returns a deep copy so stored entries are never shared
+func (e *Entry) clone() *Entry {
+	c := *e
+	if e.LastStatus != nil {
+		status := *e.LastStatus
+		c.LastStatus = &status
+	}
+	if e.Timeline != nil {
+		c.Timeline = types.NewCaseTimeline(e.Timeline.ReceiptNumber, e.Timeline.Events...)
+	}
+	return &c
+}
+
+// Record returns the last recorded status of the entry as a case snapshot,
+// or nil if no status has been recorded yet
+func (e *Entry) Record() *types.CaseRecord {
+	if e == nil || e.LastStatus == nil {
+		return nil
+	}
+	record := &types.CaseRecord{
+		ReceiptNumber: e.ReceiptNumber,
+		FormType:      e.FormType,
+		Status:        *e.LastStatus,
+		ServiceCenter: e.ServiceCenter,
+		UpdatedAt:     e.LastCheckedAt,
+		Timeline:      types.NewCaseTimeline(e.ReceiptNumber),
+	}
+	if e.Timeline != nil {
+		record.Timeline.Merge(e.Timeline)
+	}
+	return record
+}
+
+// AddRequest describes a case to start watching
+type AddRequest struct {
+	ReceiptNumber string `json:"receiptNumber"`
+	Label         string `json:"label"`
+	Notes         string `json:"notes"`
+	Owner         string `json:"owner"`
+}
+
+// Update changes an entry's user-supplied fields; nil fields are left as is
+type Update struct {
+	Label *string `json:"label,omitempty"`
+	Notes *string `json:"notes,omitempty"`
+	Owner *string `json:"owner,omitempty"`
+}
+
+// Filter selects entries in List. Zero fields match everything.
+type Filter struct {
+	Owner  string               `json:"owner,omitempty"`
+	Label  string               `json:"label,omitempty"`
+	Form   string               `json:"form,omitempty"`
+	Stage  types.CaseStage      `json:"stage,omitempty"`
+	Status types.CaseStatusCode `json:"status,omitempty"`
+	// ActionRequired limits the list to cases waiting on the applicant
+	ActionRequired bool `json:"actionRequired,omitempty"`
+	// Query matches receipt numbers, labels and notes case-insensitively
+	Query string `json:"query,omitempty"`
+}
+
+func (f Filter) matches(e *Entry) bool {
+	if f.Owner != "" && !strings.EqualFold(e.Owner, f.Owner) {
+		return false
+	}
+	if f.Label != "" && !strings.EqualFold(e.Label, f.Label) {
+		return false
+	}
+	if f.Form != "" && !strings.EqualFold(e.FormType, f.Form) {
+		return false
+	}
+	if f.Stage != "" && (e.LastStatus == nil || e.LastStatus.Stage != f.Stage) {
+		return false
+	}
+	if f.Status != "" && (e.LastStatus == nil || e.LastStatus.Code != f.Status) {
+		return false
+	}
+	if f.ActionRequired && (e.LastStatus == nil || !e.LastStatus.ActionRequired) {
+		return false
+	}
+	if f.Query != "" {
+		q := strings.ToLower(f.Query)
+		if !strings.Contains(strings.ToLower(e.ReceiptNumber), q) &&
+			!strings.Contains(strings.ToLower(e.Label), q) &&
+			!strings.Contains(strings.ToLower(e.Notes), q) {
+			return false
+		}
+	}
+	return true
+}
+
+// Service manages the watchlist on top of a Storage
+type Service struct {
+	storage Storage
+	clock   clock.Clock
+
+	// mu serializes read-modify-write cycles against the storage
+	mu sync.Mutex
+}
+
+// Option configures a Service
+type Option func(*Service)
+
+// WithClock sets the clock used for entry timestamps
+func WithClock(c clock.Clock) Option {
+	return func(s *Service) { s.clock = c }
+}
+
+// NewService creates a watchlist service. A nil storage uses an in-memory store.
+func NewService(storage Storage, opts ...Option) *Service {
+	if storage == nil {
+		storage = NewMemoryStorage()
+	}
+	s := &Service{storage: storage, clock: clock.Real()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Add starts watching a receipt number
+func (s *Service) Add(ctx context.Context, req AddRequest) (*Entry, error) {
+	r, err := receipt.Parse(req.ReceiptNumber)
+	if err != nil {
+		return nil, err
+	}
+	entry := &Entry{ReceiptNumber: r.Number, ServiceCenter: r.Center.Name}
+	if err := applyUpdate(entry, Update{Label: &req.Label, Notes: &req.Notes, Owner: &req.Owner}); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.storage.Get(ctx, r.Number); err == nil {
+		return nil, ErrAlreadyWatched
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	now := s.clock.Now().UTC()
+	entry.AddedAt = now
+	entry.UpdatedAt = now
+	if err := s.storage.Put(ctx, entry); err != nil {
+		return nil, fmt.Errorf("failed to store watchlist entry: %w", err)
+	}
+	return entry, nil
+}
+
+// Edit changes the label, notes or owner of a watched receipt
+func (s *Service) Edit(ctx context.Context, receiptNumber string, u Update) (*Entry, error) {
+	return s.modify(ctx, receiptNumber, func(e *Entry) error {
+		return applyUpdate(e, u)
+	})
+}
+
+// RecordStatus stores the latest known status of a watched case and merges
+// its timeline into the stored history
+func (s *Service) RecordStatus(ctx context.Context, record *types.CaseRecord) (*Entry, error) {
+	return s.modify(ctx, record.ReceiptNumber, func(e *Entry) error {
+		status := record.Status
+		e.LastStatus = &status
+		if record.FormType != "" {
+			e.FormType = record.FormType
+		}
+		if record.ServiceCenter != "" {
+			e.ServiceCenter = record.ServiceCenter
+		}
+		if e.Timeline == nil {
+			e.Timeline = types.NewCaseTimeline(e.ReceiptNumber)
+		}
+		e.Timeline.Merge(record.Timeline)
+		e.LastCheckedAt = s.clock.Now().UTC()
+		return nil
+	})
+}
+
+// Remove stops watching a receipt number
+func (s *Service) Remove(ctx context.Context, receiptNumber string) error {
+	number, err := normalize(receiptNumber)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.storage.Delete(ctx, number)
+}
+
+// Get returns a watched receipt
+func (s *Service) Get(ctx context.Context, receiptNumber string) (*Entry, error) {
+	number, err := normalize(receiptNumber)
+	if err != nil {
+		return nil, err
+	}
+	return s.storage.Get(ctx, number)
+}
+
+// Contains reports whether a receipt number is watched
+func (s *Service) Contains(ctx context.Context, receiptNumber string) bool {
+	_, err := s.Get(ctx, receiptNumber)
+	return err == nil
+}
+
+// List returns the entries matching filter, oldest first
+func (s *Service) List(ctx context.Context, filter Filter) ([]*Entry, error) {
+	all, err := s.storage.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Entry, 0, len(all))
+	for _, e := range all {
+		if filter.matches(e) {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].AddedAt.Equal(out[j].AddedAt) {
+			return out[i].AddedAt.Before(out[j].AddedAt)
+		}
+		return out[i].ReceiptNumber < out[j].ReceiptNumber
+	})
+	return out, nil
+}
+
+// modify applies fn to a stored entry and saves the result
+func (s *Service) modify(ctx context.Context, receiptNumber string, fn func(*Entry) error) (*Entry, error) {
+	number, err := normalize(receiptNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, err := s.storage.Get(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(entry); err != nil {
+		return nil, err
+	}
+	entry.UpdatedAt = s.clock.Now().UTC()
+	if err := s.storage.Put(ctx, entry); err != nil {
+		return nil, fmt.Errorf("failed to store watchlist entry: %w", err)
+	}
+	return entry, nil
+}
+
+func normalize(receiptNumber string) (string, error) {
+	r, err := receipt.Parse(receiptNumber)
+	if err != nil {
+		return "", err
+	}
+	return r.Number, nil
+}
+
+func applyUpdate(e *Entry, u Update) error {
+	if u.Label != nil {
+		label, err := cleanText("label", *u.Label, MaxLabelLength, false)
+		if err != nil {
+			return err
+		}
+		e.Label = label
+	}
+	if u.Notes != nil {
+		notes, err := cleanText("notes", *u.Notes, MaxNotesLength, true)
+		if err != nil {
+			return err
+		}
+		e.Notes = notes
+	}
+	if u.Owner != nil {
+		owner, err := cleanText("owner", *u.Owner, MaxOwnerLength, false)
+		if err != nil {
+			return err
+		}
+		e.Owner = owner
+	}
+	return nil
+}
+
+// cleanText trims a free-text field and rejects control characters;
+// multiline fields may contain line breaks and tabs
+func cleanText(field, s string, maxLen int, multiline bool) (string, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > maxLen {
+		return "", validation.ValidationError{Field: field, Message: fmt.Sprintf("%s must be at most %d characters", field, maxLen)}
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) && !(multiline && (r == '\n' || r == '\r' || r == '\t')) {
+			return "", validation.ValidationError{Field: field, Message: fmt.Sprintf("%s must not contain control characters", field)}
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,244 @@
+package watchlist
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/types"
+	"MyUSCISgo/pkg/validation"
+)
+
+var testEpoch = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestService() (*Service, *clock.Fake) {
+	fc := clock.NewFake(testEpoch)
+	return NewService(NewMemoryStorage(), WithClock(fc)), fc
+}
+
+func TestAdd(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService()
+
+	entry, err := s.Add(ctx, AddRequest{ReceiptNumber: "eac-2190000001", Label: "  Mom's I-130 ", Owner: "alex"})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if entry.ReceiptNumber != "EAC2190000001" || entry.Label != "Mom's I-130" || !entry.AddedAt.Equal(testEpoch) {
+		t.Errorf("Add() = %+v", entry)
+	}
+
+	tests := []struct {
+		name    string
+		req     AddRequest
+		wantErr error
+		field   string
+	}{
+		{"duplicate", AddRequest{ReceiptNumber: "EAC2190000001"}, ErrAlreadyWatched, ""},
+		{"invalid receipt", AddRequest{ReceiptNumber: "ABC123"}, nil, ""},
+		{"long label", AddRequest{ReceiptNumber: "EAC2190000002", Label: strings.Repeat("x", MaxLabelLength+1)}, nil, "label"},
+		{"control characters", AddRequest{ReceiptNumber: "EAC2190000002", Owner: "bob\x00"}, nil, "owner"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Add(ctx, tt.req)
+			if err == nil {
+				t.Fatal("Add() expected error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Add() error = %v, want %v", err, tt.wantErr)
+			}
+			var verr validation.ValidationError
+			if tt.field != "" && (!errors.As(err, &verr) || verr.Field != tt.field) {
+				t.Errorf("Add() error = %v, want ValidationError on %s", err, tt.field)
+			}
+		})
+	}
+
+	if _, err := s.Add(ctx, AddRequest{ReceiptNumber: "EAC2190000003", Notes: "line one\nline two"}); err != nil {
+		t.Errorf("multiline notes should be accepted: %v", err)
+	}
+}
+
+func TestEditAndRemove(t *testing.T) {
+	ctx := context.Background()
+	s, fc := newTestService()
+	if _, err := s.Add(ctx, AddRequest{ReceiptNumber: "LIN2312351234", Label: "EAD"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	fc.Advance(time.Hour)
+	notes := "biometrics done"
+	entry, err := s.Edit(ctx, "lin2312351234", Update{Notes: &notes})
+	if err != nil {
+		t.Fatalf("Edit() error = %v", err)
+	}
+	if entry.Label != "EAD" || entry.Notes != notes || !entry.UpdatedAt.Equal(testEpoch.Add(time.Hour)) {
+		t.Errorf("Edit() = %+v", entry)
+	}
+
+	if err := s.Remove(ctx, "LIN2312351234"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if s.Contains(ctx, "LIN2312351234") {
+		t.Error("receipt still watched after Remove()")
+	}
+	if err := s.Remove(ctx, "LIN2312351234"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Remove() error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Edit(ctx, "LIN2312351234", Update{Notes: &notes}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Edit() of removed entry error = %v, want ErrNotFound", err)
+	}
+}
+
+func caseRecord(number, form string, events ...types.TimelineEvent) *types.CaseRecord {
+	timeline := types.NewCaseTimeline(number, events...)
+	latest, _ := timeline.Latest()
+	return &types.CaseRecord{ReceiptNumber: number, FormType: form, Status: latest.Status, Timeline: timeline}
+}
+
+func TestRecordStatusMergesTimeline(t *testing.T) {
+	ctx := context.Background()
+	s, fc := newTestService()
+	if _, err := s.Add(ctx, AddRequest{ReceiptNumber: "EAC2190000001"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	received := types.NewTimelineEvent(testEpoch.AddDate(0, -2, 0), types.StatusReceived.Title(), types.SourceHistory)
+	rfe := types.NewTimelineEvent(testEpoch.AddDate(0, -1, 0), types.StatusRFESent.Title(), types.SourceCurrent)
+	if _, err := s.RecordStatus(ctx, caseRecord("EAC2190000001", "I-130", received, rfe)); err != nil {
+		t.Fatalf("RecordStatus() error = %v", err)
+	}
+
+	fc.Advance(24 * time.Hour)
+	approved := types.NewTimelineEvent(testEpoch, types.StatusApproved.Title(), types.SourceCurrent)
+	entry, err := s.RecordStatus(ctx, caseRecord("EAC2190000001", "", approved))
+	if err != nil {
+		t.Fatalf("RecordStatus() error = %v", err)
+	}
+	if entry.Timeline.Len() != 3 || entry.LastStatus.Code != types.StatusApproved || entry.FormType != "I-130" {
+		t.Errorf("entry = %+v with %d events", entry, entry.Timeline.Len())
+	}
+	if !entry.LastCheckedAt.Equal(testEpoch.Add(24 * time.Hour)) {
+		t.Errorf("LastCheckedAt = %v", entry.LastCheckedAt)
+	}
+
+	// Entries handed out must not alias storage
+	entry.Timeline.Add(types.NewTimelineEvent(testEpoch.AddDate(0, 1, 0), types.StatusCardMailed.Title(), types.SourceCurrent))
+	stored, _ := s.Get(ctx, "EAC2190000001")
+	if stored.Timeline.Len() != 3 {
+		t.Errorf("stored timeline changed through a returned entry: %d events", stored.Timeline.Len())
+	}
+
+	if _, err := s.RecordStatus(ctx, caseRecord("EAC2190000009", "I-130", approved)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RecordStatus() for unwatched receipt error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestEntryRecord(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService()
+	entry, err := s.Add(ctx, AddRequest{ReceiptNumber: "EAC2190000001"})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if entry.Record() != nil {
+		t.Error("Record() of an entry without a status should be nil")
+	}
+
+	record := caseRecord("EAC2190000001", "I-130", types.NewTimelineEvent(testEpoch, types.StatusRFESent.Title(), types.SourceCurrent))
+	record.ServiceCenter = "Vermont Service Center"
+	entry, err = s.RecordStatus(ctx, record)
+	if err != nil {
+		t.Fatalf("RecordStatus() error = %v", err)
+	}
+	got := entry.Record()
+	if got == nil || got.Status.Code != types.StatusRFESent || got.ServiceCenter != "Vermont Service Center" || got.Timeline.Len() != 1 {
+		t.Fatalf("Record() = %+v", got)
+	}
+	got.Timeline.Add(types.NewTimelineEvent(testEpoch.AddDate(0, 0, 1), types.StatusApproved.Title(), types.SourceCurrent))
+	if entry.Timeline.Len() != 1 {
+		t.Error("Record() shares its timeline with the entry")
+	}
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	s, fc := newTestService()
+
+	adds := []AddRequest{
+		{ReceiptNumber: "EAC2190000001", Label: "Parents", Owner: "alex", Notes: "filed by attorney"},
+		{ReceiptNumber: "LIN2312351234", Label: "EAD", Owner: "sam"},
+		{ReceiptNumber: "IOE0912345678", Label: "Parents", Owner: "ALEX"},
+	}
+	for _, req := range adds {
+		if _, err := s.Add(ctx, req); err != nil {
+			t.Fatalf("Add(%s) error = %v", req.ReceiptNumber, err)
+		}
+		fc.Advance(time.Minute)
+	}
+	rfe := types.NewTimelineEvent(testEpoch, types.StatusRFESent.Title(), types.SourceCurrent)
+	if _, err := s.RecordStatus(ctx, caseRecord("LIN2312351234", "I-765", rfe)); err != nil {
+		t.Fatalf("RecordStatus() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"all", Filter{}, []string{"EAC2190000001", "LIN2312351234", "IOE0912345678"}},
+		{"owner", Filter{Owner: "alex"}, []string{"EAC2190000001", "IOE0912345678"}},
+		{"label", Filter{Label: "ead"}, []string{"LIN2312351234"}},
+		{"form", Filter{Form: "I-765"}, []string{"LIN2312351234"}},
+		{"stage", Filter{Stage: types.StageRFE}, []string{"LIN2312351234"}},
+		{"status", Filter{Status: types.StatusApproved}, nil},
+		{"action required", Filter{ActionRequired: true}, []string{"LIN2312351234"}},
+		{"query notes", Filter{Query: "ATTORNEY"}, []string{"EAC2190000001"}},
+		{"query receipt", Filter{Query: "ioe09"}, []string{"IOE0912345678"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.List(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("List() returned %d entries, want %d", len(got), len(tt.want))
+			}
+			for i, e := range got {
+				if e.ReceiptNumber != tt.want[i] {
+					t.Errorf("List()[%d] = %s, want %s", i, e.ReceiptNumber, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestConcurrentAdd(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	added := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Add(ctx, AddRequest{ReceiptNumber: "EAC2190000001"}); err == nil {
+				mu.Lock()
+				added++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if added != 1 {
+		t.Errorf("%d concurrent adds of the same receipt succeeded, want 1", added)
+	}
+}
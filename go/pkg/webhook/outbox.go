@@ -0,0 +1,686 @@
+// Package webhook delivers case change events to HTTP endpoints at least
+// once. Events are written to an outbox before any network call; a delivery
+// loop posts them with a timestamped HMAC signature, retries failures with
+// exponential backoff and moves deliveries that keep failing to a
+// dead-letter queue, from which they can be replayed.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"MyUSCISgo/pkg/changes"
+	"MyUSCISgo/pkg/clock"
+	"MyUSCISgo/pkg/logging"
+)
+
+// MaxPayloadBytes bounds the size of a delivery body
+const MaxPayloadBytes = 256 << 10
+
+// maxAttemptHistory bounds the attempts recorded per delivery
+const maxAttemptHistory = 20
+
+var (
+	// ErrUnknownEndpoint is returned when enqueuing for an unregistered endpoint
+	ErrUnknownEndpoint = errors.New("unknown webhook endpoint")
+	// ErrNotDead is returned when replaying a delivery that is not in the
+	// dead-letter queue
+	ErrNotDead = errors.New("webhook delivery is not in the dead-letter queue")
+	// ErrRunning is returned by Start when the delivery loop is already running
+	ErrRunning = errors.New("webhook outbox is already running")
+)
+
+// Status is the state of a delivery
+type Status string
+
+const (
+	// StatusPending deliveries are waiting for their next attempt
+	StatusPending Status = "pending"
+	// StatusDelivered deliveries were accepted by the endpoint
+	StatusDelivered Status = "delivered"
+	// StatusDead deliveries exhausted their attempts or were rejected
+	// permanently; they stay in the dead-letter queue until replayed
+	StatusDead Status = "dead"
+)
+
+// Endpoint is a receiver of webhook deliveries
+type Endpoint struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Secret []byte `json:"-"`
+}
+
+// Attempt records one try at delivering
+type Attempt struct {
+	At         time.Time     `json:"at"`
+	StatusCode int           `json:"statusCode,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// Delivery is one event queued for one endpoint
+type Delivery struct {
+	// ID is derived from the endpoint and event, so enqueuing the same
+	// event twice yields one delivery
+	ID            string          `json:"id"`
+	Endpoint      string          `json:"endpoint"`
+	EventID       string          `json:"eventId"`
+	EventType     changes.Type    `json:"eventType"`
+	ReceiptNumber string          `json:"receiptNumber"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        Status          `json:"status"`
+	// Failures counts failed attempts since the delivery was created or
+	// last replayed
+	Failures      int       `json:"failures"`
+	Attempts      []Attempt `json:"attempts,omitempty"`
+	LastError     string    `json:"lastError,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	NextAttemptAt time.Time `json:"nextAttemptAt,omitzero"`
+	DeliveredAt   time.Time `json:"deliveredAt,omitzero"`
+	DeadAt        time.Time `json:"deadAt,omitzero"`
+}
+
+// clone returns a deep copy so stored deliveries are never shared
+func (d *Delivery) clone() *Delivery {
+	c := *d
+	c.Payload = bytes.Clone(d.Payload)
+	c.Attempts = append([]Attempt(nil), d.Attempts...)
+	return &c
+}
+
+// Payload is the JSON body posted for each delivery
+type Payload struct {
+	changes.Event
+	Summary string `json:"summary"`
+}
+
+// Policy tunes delivery. Zero fields take the defaults from DefaultPolicy.
+type Policy struct {
+	// MaxAttempts is the number of failures after which a delivery is
+	// moved to the dead-letter queue
+	MaxAttempts int
+	// BackoffBase is the delay after the first failure; it doubles after
+	// each further failure up to BackoffMax
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// Tick is how often the delivery loop looks for due deliveries
+	Tick time.Duration
+	// Batch caps the deliveries attempted per pass
+	Batch int
+	// Retention is how long delivered deliveries are kept
+	Retention time.Duration
+	// RatePerMinute caps the attempts per endpoint in any minute; due
+	// deliveries over it wait for a later pass. Zero means no limit.
+	RatePerMinute int
+}
+
+// DefaultPolicy returns the default delivery policy
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts: 8,
+		BackoffBase: 30 * time.Second,
+		BackoffMax:  time.Hour,
+		Tick:        15 * time.Second,
+		Batch:       20,
+		Retention:   7 * 24 * time.Hour,
+	}
+}
+
+func (p Policy) withDefaults() Policy {
+	d := DefaultPolicy()
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = d.MaxAttempts
+	}
+	if p.BackoffBase <= 0 {
+		p.BackoffBase = d.BackoffBase
+	}
+	if p.BackoffMax <= 0 {
+		p.BackoffMax = d.BackoffMax
+	}
+	if p.Tick <= 0 {
+		p.Tick = d.Tick
+	}
+	if p.Batch <= 0 {
+		p.Batch = d.Batch
+	}
+	if p.Retention <= 0 {
+		p.Retention = d.Retention
+	}
+	return p
+}
+
+// backoff returns the delay after the given number of failures
+func (p Policy) backoff(failures int) time.Duration {
+	d := p.BackoffBase
+	for i := 1; i < failures && d < p.BackoffMax; i++ {
+		d *= 2
+	}
+	return min(d, p.BackoffMax)
+}
+
+// HTTPDoer is the subset of *http.Client used for deliveries
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Stats summarizes a delivery pass
+type Stats struct {
+	Attempted int `json:"attempted"`
+	Delivered int `json:"delivered"`
+	Retrying  int `json:"retrying"`
+	Dead      int `json:"dead"`
+	// Deferred counts due deliveries held back by the endpoint's rate
+	Deferred int `json:"deferred"`
+}
+
+// Outbox queues and delivers webhook events
+type Outbox struct {
+	store  Store
+	client HTTPDoer
+	clock  clock.Clock
+	logger *logging.Logger
+	policy Policy
+
+	mu        sync.RWMutex
+	endpoints map[string]Endpoint
+
+	// passMu keeps delivery passes from overlapping and guards attempted
+	passMu sync.Mutex
+	// attempted holds the recent attempt times per endpoint for pacing
+	attempted map[string][]time.Time
+
+	runMu  sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Option configures an Outbox
+type Option func(*Outbox)
+
+// WithPolicy sets the delivery policy
+func WithPolicy(p Policy) Option {
+	return func(o *Outbox) { o.policy = p }
+}
+
+// WithHTTPClient sets the HTTP client used for deliveries
+func WithHTTPClient(client HTTPDoer) Option {
+	return func(o *Outbox) {
+		if client != nil {
+			o.client = client
+		}
+	}
+}
+
+// WithClock sets the time source
+func WithClock(c clock.Clock) Option {
+	return func(o *Outbox) {
+		if c != nil {
+			o.clock = c
+		}
+	}
+}
+
+// WithLogger sets the logger
+func WithLogger(logger *logging.Logger) Option {
+	return func(o *Outbox) {
+		if logger != nil {
+			o.logger = logger
+		}
+	}
+}
+
+// New creates an outbox backed by store. A nil store keeps deliveries in
+// memory only.
+func New(store Store, opts ...Option) *Outbox {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	o := &Outbox{
+		store:     store,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		clock:     clock.Real(),
+		logger:    logging.NewLogger(logging.LogLevelInfo),
+		endpoints: make(map[string]Endpoint),
+		attempted: make(map[string][]time.Time),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	o.policy = o.policy.withDefaults()
+	return o
+}
+
+// Validate checks that the endpoint has a name, an HTTP(S) URL and a secret
+func (ep Endpoint) Validate() error {
+	if ep.Name == "" {
+		return errors.New("webhook endpoint name cannot be empty")
+	}
+	u, err := url.Parse(ep.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("webhook %s: invalid URL %q", ep.Name, ep.URL)
+	}
+	if len(ep.Secret) == 0 {
+		return fmt.Errorf("webhook %s: signing secret cannot be empty", ep.Name)
+	}
+	return nil
+}
+
+// SetEndpoint adds or replaces an endpoint
+func (o *Outbox) SetEndpoint(ep Endpoint) error {
+	if err := ep.Validate(); err != nil {
+		return err
+	}
+	ep.Secret = bytes.Clone(ep.Secret)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.endpoints[ep.Name] = ep
+	return nil
+}
+
+// RemoveEndpoint removes an endpoint and reports whether it existed.
+// Pending deliveries for it move to the dead-letter queue when next due.
+func (o *Outbox) RemoveEndpoint(name string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, ok := o.endpoints[name]
+	delete(o.endpoints, name)
+	return ok
+}
+
+// Endpoints returns the names of the registered endpoints, sorted
+func (o *Outbox) Endpoints() []string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	names := make([]string, 0, len(o.endpoints))
+	for name := range o.endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// endpoint looks up an endpoint by name
+func (o *Outbox) endpoint(name string) (Endpoint, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	ep, ok := o.endpoints[name]
+	return ep, ok
+}
+
+// Enqueue stores e for delivery to endpoint. Enqueuing an event that is
+// already queued for the endpoint returns the existing delivery.
+func (o *Outbox) Enqueue(ctx context.Context, endpoint string, e changes.Event) (*Delivery, error) {
+	if _, ok := o.endpoint(endpoint); !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownEndpoint, endpoint)
+	}
+	id := deliveryID(endpoint, e.ID)
+	if existing, err := o.store.Get(ctx, id); err == nil {
+		return existing, nil
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(Payload{Event: e, Summary: e.Summary()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+	if len(payload) > MaxPayloadBytes {
+		return nil, fmt.Errorf("webhook payload exceeds %d bytes", MaxPayloadBytes)
+	}
+	now := o.clock.Now().UTC()
+	d := &Delivery{
+		ID:            id,
+		Endpoint:      endpoint,
+		EventID:       e.ID,
+		EventType:     e.Type,
+		ReceiptNumber: e.ReceiptNumber,
+		Payload:       payload,
+		Status:        StatusPending,
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}
+	if err := o.store.Put(ctx, d); err != nil {
+		return nil, fmt.Errorf("failed to store webhook delivery: %w", err)
+	}
+	return d, nil
+}
+
+// Publish enqueues e for every endpoint
+func (o *Outbox) Publish(ctx context.Context, e changes.Event) error {
+	var errs []error
+	for _, name := range o.Endpoints() {
+		if _, err := o.Enqueue(ctx, name, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// EndpointNotifier enqueues events for a single endpoint. Its Name and
+// Notify methods satisfy notify.Notifier, so an outbox endpoint can be used
+// as a routed notification channel.
+type EndpointNotifier struct {
+	outbox   *Outbox
+	endpoint string
+}
+
+// Notifier returns a notifier that enqueues events for endpoint
+func (o *Outbox) Notifier(endpoint string) *EndpointNotifier {
+	return &EndpointNotifier{outbox: o, endpoint: endpoint}
+}
+
+// Name returns the endpoint name
+func (n *EndpointNotifier) Name() string {
+	return n.endpoint
+}
+
+// Notify enqueues e; delivery happens in the outbox loop
+func (n *EndpointNotifier) Notify(ctx context.Context, e changes.Event) error {
+	_, err := n.outbox.Enqueue(ctx, n.endpoint, e)
+	return err
+}
+
+// DeliverDue attempts every pending delivery whose next attempt is due,
+// oldest first and up to the policy's batch size. Deliveries to an endpoint
+// over the policy's rate stay pending for a later pass.
+func (o *Outbox) DeliverDue(ctx context.Context) (Stats, error) {
+	o.passMu.Lock()
+	defer o.passMu.Unlock()
+
+	var stats Stats
+	pending, err := o.store.List(ctx, StatusPending)
+	if err != nil {
+		return stats, fmt.Errorf("failed to list pending webhook deliveries: %w", err)
+	}
+	now := o.clock.Now()
+	for _, d := range pending {
+		if stats.Attempted >= o.policy.Batch {
+			break
+		}
+		if d.NextAttemptAt.After(now) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		if !o.pace(d.Endpoint, now) {
+			stats.Deferred++
+			continue
+		}
+		stats.Attempted++
+		o.attempt(ctx, d)
+		switch d.Status {
+		case StatusDelivered:
+			stats.Delivered++
+		case StatusDead:
+			stats.Dead++
+		default:
+			stats.Retrying++
+		}
+		if err := o.store.Put(ctx, d); err != nil {
+			return stats, fmt.Errorf("failed to update webhook delivery: %w", err)
+		}
+	}
+	return stats, nil
+}
+
+// pace reports whether endpoint may take another attempt at now under the
+// policy's rate, and counts the attempt if so
+func (o *Outbox) pace(endpoint string, now time.Time) bool {
+	if o.policy.RatePerMinute <= 0 {
+		return true
+	}
+	var recent []time.Time
+	for _, at := range o.attempted[endpoint] {
+		if now.Sub(at) < time.Minute {
+			recent = append(recent, at)
+		}
+	}
+	if len(recent) >= o.policy.RatePerMinute {
+		o.attempted[endpoint] = recent
+		return false
+	}
+	o.attempted[endpoint] = append(recent, now)
+	return true
+}
+
+// attempt posts d once and updates its state
+func (o *Outbox) attempt(ctx context.Context, d *Delivery) {
+	start := o.clock.Now()
+	ep, ok := o.endpoint(d.Endpoint)
+	if !ok {
+		o.recordFailure(d, Attempt{At: start.UTC(), Error: "endpoint removed"}, true)
+		return
+	}
+
+	code, err := o.post(ctx, ep, d, start)
+	a := Attempt{At: start.UTC(), StatusCode: code, Duration: o.clock.Now().Sub(start)}
+	if err == nil {
+		d.Attempts = appendAttempt(d.Attempts, a)
+		d.Status = StatusDelivered
+		d.DeliveredAt = start.UTC()
+		d.NextAttemptAt = time.Time{}
+		d.LastError = ""
+		return
+	}
+	a.Error = err.Error()
+	// Client errors other than timeouts and throttling will not succeed on
+	// retry; the receiver has to change first, which is what replay is for
+	permanent := code >= 400 && code < 500 &&
+		code != http.StatusRequestTimeout && code != http.StatusTooManyRequests
+	o.recordFailure(d, a, permanent)
+}
+
+// recordFailure notes a failed attempt and schedules a retry or moves d to
+// the dead-letter queue
+func (o *Outbox) recordFailure(d *Delivery, a Attempt, permanent bool) {
+	d.Attempts = appendAttempt(d.Attempts, a)
+	d.Failures++
+	d.LastError = a.Error
+	if permanent || d.Failures >= o.policy.MaxAttempts {
+		d.Status = StatusDead
+		d.DeadAt = a.At
+		d.NextAttemptAt = time.Time{}
+		o.logger.Warn("Webhook delivery moved to dead-letter queue", map[string]interface{}{
+			"endpoint":   d.Endpoint,
+			"deliveryId": d.ID,
+			"failures":   d.Failures,
+			"error":      a.Error,
+		})
+		return
+	}
+	d.NextAttemptAt = a.At.Add(o.policy.backoff(d.Failures))
+	o.logger.Debug("Webhook delivery failed, will retry", map[string]interface{}{
+		"endpoint":   d.Endpoint,
+		"deliveryId": d.ID,
+		"failures":   d.Failures,
+		"retryAt":    d.NextAttemptAt.Format(time.RFC3339),
+		"error":      a.Error,
+	})
+}
+
+// appendAttempt appends a, keeping the most recent maxAttemptHistory
+func appendAttempt(attempts []Attempt, a Attempt) []Attempt {
+	attempts = append(attempts, a)
+	if len(attempts) > maxAttemptHistory {
+		attempts = attempts[len(attempts)-maxAttemptHistory:]
+	}
+	return attempts
+}
+
+// post sends d to ep and returns the response status
+func (o *Outbox) post(ctx context.Context, ep Endpoint, d *Delivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, d.ID)
+	req.Header.Set(EventHeader, string(d.EventType))
+	req.Header.Set(SignatureHeader, Sign(ep.Secret, now, d.Payload))
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Deliveries lists deliveries with the given status, oldest first; an
+// empty status lists all of them
+func (o *Outbox) Deliveries(ctx context.Context, status Status) ([]*Delivery, error) {
+	return o.store.List(ctx, status)
+}
+
+// DeadLetters lists the dead-letter queue, oldest first
+func (o *Outbox) DeadLetters(ctx context.Context) ([]*Delivery, error) {
+	return o.store.List(ctx, StatusDead)
+}
+
+// Replay moves a dead delivery back to the queue for immediate delivery.
+// Its attempt history is kept; its failure count starts again from zero.
+func (o *Outbox) Replay(ctx context.Context, id string) (*Delivery, error) {
+	d, err := o.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if d.Status != StatusDead {
+		return nil, ErrNotDead
+	}
+	d.Status = StatusPending
+	d.Failures = 0
+	d.DeadAt = time.Time{}
+	d.NextAttemptAt = o.clock.Now().UTC()
+	if err := o.store.Put(ctx, d); err != nil {
+		return nil, fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	o.logger.Info("Webhook delivery replayed", map[string]interface{}{
+		"endpoint":   d.Endpoint,
+		"deliveryId": d.ID,
+	})
+	return d, nil
+}
+
+// ReplayAll replays every dead delivery, optionally only those for one
+// endpoint, and returns how many were replayed
+func (o *Outbox) ReplayAll(ctx context.Context, endpoint string) (int, error) {
+	dead, err := o.DeadLetters(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, d := range dead {
+		if endpoint != "" && d.Endpoint != endpoint {
+			continue
+		}
+		if _, err := o.Replay(ctx, d.ID); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Prune deletes delivered deliveries older than the retention period and
+// returns how many were removed. Dead deliveries are kept until replayed.
+func (o *Outbox) Prune(ctx context.Context) (int, error) {
+	delivered, err := o.store.List(ctx, StatusDelivered)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := o.clock.Now().Add(-o.policy.Retention)
+	n := 0
+	for _, d := range delivered {
+		if d.DeliveredAt.Before(cutoff) {
+			if err := o.store.Delete(ctx, d.ID); err != nil && !errors.Is(err, ErrNotFound) {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Start runs the delivery loop in the background until Stop is called or
+// ctx is cancelled
+func (o *Outbox) Start(ctx context.Context) error {
+	o.runMu.Lock()
+	defer o.runMu.Unlock()
+	if o.cancel != nil {
+		return ErrRunning
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	o.cancel, o.done = cancel, done
+	go func() {
+		defer close(done)
+		o.loop(ctx)
+	}()
+	return nil
+}
+
+// Stop halts the delivery loop and waits for an in-flight pass to finish.
+// It is safe to call when the loop is not running.
+func (o *Outbox) Stop() {
+	o.runMu.Lock()
+	cancel, done := o.cancel, o.done
+	o.cancel, o.done = nil, nil
+	o.runMu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Running reports whether the delivery loop is active
+func (o *Outbox) Running() bool {
+	o.runMu.Lock()
+	defer o.runMu.Unlock()
+	return o.cancel != nil
+}
+
+func (o *Outbox) loop(ctx context.Context) {
+	for {
+		if _, err := o.DeliverDue(ctx); err != nil && ctx.Err() == nil {
+			o.logger.Warn("Webhook delivery pass failed", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		if _, err := o.Prune(ctx); err != nil && ctx.Err() == nil {
+			o.logger.Warn("Webhook outbox prune failed", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.clock.After(o.policy.Tick):
+		}
+	}
+}
+
+// deliveryID derives a stable delivery ID from the endpoint and event
+func deliveryID(endpoint, eventID string) string {
+	sum := sha256.Sum256([]byte(endpoint + "|" + eventID))
+	return "dlv-" + hex.EncodeToString(sum[:12])
+}
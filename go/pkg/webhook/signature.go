@@ -0,0 +1,116 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SignatureHeader carries the delivery signature, "t=<unix>,v1=<hex>"
+	SignatureHeader = "X-MyUSCIS-Signature"
+	// DeliveryHeader carries the delivery ID; it is stable across retries so
+	// receivers can discard duplicates
+	DeliveryHeader = "X-MyUSCIS-Delivery"
+	// EventHeader carries the event type
+	EventHeader = "X-MyUSCIS-Event"
+
+	// DefaultTolerance is how old a signature Verify accepts by default
+	DefaultTolerance = 5 * time.Minute
+
+	// signatureScheme is the only scheme currently produced
+	signatureScheme = "v1"
+)
+
+var (
+	// ErrMalformedSignature is returned for a header that cannot be parsed
+	ErrMalformedSignature = errors.New("malformed webhook signature")
+	// ErrSignatureMismatch is returned when no signature matches the body
+	ErrSignatureMismatch = errors.New("webhook signature does not match")
+	// ErrSignatureExpired is returned when the timestamp is outside the
+	// tolerance
+	ErrSignatureExpired = errors.New("webhook signature timestamp outside tolerance")
+)
+
+// Sign returns the signature header value for body sent at t. The
+// signature is the hex HMAC-SHA256 of the Unix timestamp, a dot and the
+// body, so a captured request cannot be replayed with a new timestamp.
+func Sign(secret []byte, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + "," + signatureScheme + "=" + computeSignature(secret, ts, body)
+}
+
+// computeSignature returns the hex HMAC of timestamp.body
+func computeSignature(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header against body. The header may carry
+// several v1 signatures, as happens while a secret is being rotated; any
+// match is accepted. A tolerance of zero uses DefaultTolerance.
+func Verify(secret []byte, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	var (
+		timestamp  string
+		signatures []string
+	)
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrMalformedSignature
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case signatureScheme:
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return ErrMalformedSignature
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrMalformedSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: signed %s ago", ErrSignatureExpired, age.Round(time.Second))
+	}
+
+	expected := []byte(computeSignature(secret, timestamp, body))
+	for _, sig := range signatures {
+		if hmac.Equal(expected, []byte(sig)) {
+			return nil
+		}
+	}
+	return ErrSignatureMismatch
+}
+
+// VerifyRequest reads and verifies a webhook request, returning its body.
+// It is meant for receivers written in Go.
+func VerifyRequest(r *http.Request, secret []byte, tolerance time.Duration) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, MaxPayloadBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook body: %w", err)
+	}
+	if len(body) > MaxPayloadBytes {
+		return nil, fmt.Errorf("webhook body exceeds %d bytes", MaxPayloadBytes)
+	}
+	if err := Verify(secret, r.Header.Get(SignatureHeader), body, time.Now(), tolerance); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
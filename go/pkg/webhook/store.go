@@ -0,0 +1,180 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// ErrNotFound is returned when a delivery does not exist
+var ErrNotFound = errors.New("webhook delivery not found")
+
+// Store persists deliveries. Get and List return copies, so callers may
+// modify the deliveries they receive.
+type Store interface {
+	Get(ctx context.Context, id string) (*Delivery, error)
+	Put(ctx context.Context, d *Delivery) error
+	Delete(ctx context.Context, id string) error
+	// List returns deliveries with the given status, oldest first; an
+	// empty status lists every delivery
+	List(ctx context.Context, status Status) ([]*Delivery, error)
+}
+
+// MemoryStore is an in-memory Store that is safe for concurrent use
+type MemoryStore struct {
+	mu         sync.RWMutex
+	deliveries map[string]*Delivery
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{deliveries: make(map[string]*Delivery)}
+}
+
+// Get returns a delivery by ID
+func (m *MemoryStore) Get(ctx context.Context, id string) (*Delivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	d, ok := m.deliveries[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return d.clone(), nil
+}
+
+// Put inserts or replaces a delivery
+func (m *MemoryStore) Put(ctx context.Context, d *Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries[d.ID] = d.clone()
+	return nil
+}
+
+// Delete removes a delivery
+func (m *MemoryStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.deliveries[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.deliveries, id)
+	return nil
+}
+
+// List returns deliveries with the given status, oldest first
+func (m *MemoryStore) List(ctx context.Context, status Status) ([]*Delivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []*Delivery
+	for _, d := range m.deliveries {
+		if status == "" || d.Status == status {
+			out = append(out, d.clone())
+		}
+	}
+	sortDeliveries(out)
+	return out, nil
+}
+
+// sortDeliveries orders deliveries by creation time, then ID
+func sortDeliveries(ds []*Delivery) {
+	sort.Slice(ds, func(i, j int) bool {
+		if !ds[i].CreatedAt.Equal(ds[j].CreatedAt) {
+			return ds[i].CreatedAt.Before(ds[j].CreatedAt)
+		}
+		return ds[i].ID < ds[j].ID
+	})
+}
+
+// FileStore is a Store that keeps deliveries in memory and writes them to
+// a JSON file after every change, so pending deliveries survive a restart.
+// Writes replace the file atomically.
+type FileStore struct {
+	path string
+	mem  *MemoryStore
+	// mu serializes writes to the file
+	mu sync.Mutex
+}
+
+// NewFileStore opens the store at path, loading any deliveries saved there
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, mem: NewMemoryStore()}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook outbox: %w", err)
+	}
+	var deliveries []*Delivery
+	if err := json.Unmarshal(data, &deliveries); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook outbox %s: %w", path, err)
+	}
+	for _, d := range deliveries {
+		s.mem.deliveries[d.ID] = d
+	}
+	return s, nil
+}
+
+// Get returns a delivery by ID
+func (s *FileStore) Get(ctx context.Context, id string) (*Delivery, error) {
+	return s.mem.Get(ctx, id)
+}
+
+// List returns deliveries with the given status, oldest first
+func (s *FileStore) List(ctx context.Context, status Status) ([]*Delivery, error) {
+	return s.mem.List(ctx, status)
+}
+
+// Put inserts or replaces a delivery and saves the store
+func (s *FileStore) Put(ctx context.Context, d *Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.mem.Put(ctx, d); err != nil {
+		return err
+	}
+	return s.save(ctx)
+}
+
+// Delete removes a delivery and saves the store
+func (s *FileStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.mem.Delete(ctx, id); err != nil {
+		return err
+	}
+	return s.save(ctx)
+}
+
+// save writes every delivery to a temporary file and renames it over path
+func (s *FileStore) save(ctx context.Context) error {
+	all, _ := s.mem.List(ctx, "")
+	data, err := json.Marshal(all)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook outbox: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to save webhook outbox: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save webhook outbox: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save webhook outbox: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save webhook outbox: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to save webhook outbox: %w", err)
+	}
+	return nil
+}
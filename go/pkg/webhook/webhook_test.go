@@ -0,0 +1,352 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"MyUSCISgo/pkg/changes"
+	"MyUSCISgo/pkg/clock"
+)
+
+var testEpoch = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func testEvent(id string) changes.Event {
+	return changes.Event{
+		ID:            id,
+		Type:          changes.RFEIssued,
+		ReceiptNumber: "EAC2190000001",
+		Before:        "case_received",
+		After:         "rfe_sent",
+		OccurredAt:    testEpoch.AddDate(0, 0, -1),
+		DetectedAt:    testEpoch,
+	}
+}
+
+func TestVerify(t *testing.T) {
+	secret := []byte("s3cret")
+	body := []byte(`{"id":"evt-1"}`)
+	header := Sign(secret, testEpoch, body)
+	if !strings.HasPrefix(header, "t=1748779200,v1=") {
+		t.Fatalf("Sign() = %q", header)
+	}
+
+	tests := []struct {
+		name    string
+		secret  []byte
+		header  string
+		body    []byte
+		now     time.Time
+		wantErr error
+	}{
+		{"valid", secret, header, body, testEpoch.Add(time.Minute), nil},
+		{"rotated secret", secret, Sign([]byte("old"), testEpoch, body) + ",v1=" + header[strings.Index(header, "v1=")+3:], body, testEpoch, nil},
+		{"tampered body", secret, header, []byte(`{"id":"evt-2"}`), testEpoch, ErrSignatureMismatch},
+		{"wrong secret", []byte("other"), header, body, testEpoch, ErrSignatureMismatch},
+		{"too old", secret, header, body, testEpoch.Add(10 * time.Minute), ErrSignatureExpired},
+		{"from the future", secret, header, body, testEpoch.Add(-10 * time.Minute), ErrSignatureExpired},
+		{"missing timestamp", secret, "v1=abcd", body, testEpoch, ErrMalformedSignature},
+		{"missing signature", secret, "t=1748779200", body, testEpoch, ErrMalformedSignature},
+		{"garbage", secret, "sha256=abcd", body, testEpoch, ErrMalformedSignature},
+		{"empty", secret, "", body, testEpoch, ErrMalformedSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, tt.now, 0)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// receiver is a test endpoint that verifies signatures and answers with a
+// scripted sequence of status codes
+type receiver struct {
+	t      *testing.T
+	secret []byte
+	now    func() time.Time
+
+	mu       sync.Mutex
+	statuses []int
+	received []string
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	if err := Verify(r.secret, req.Header.Get(SignatureHeader), body, r.now(), 0); err != nil {
+		r.t.Errorf("receiver: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	var p Payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		r.t.Errorf("receiver: bad payload: %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	if status < 300 {
+		r.received = append(r.received, req.Header.Get(DeliveryHeader)+"/"+p.ID)
+	}
+	w.WriteHeader(status)
+}
+
+func (r *receiver) script(statuses ...int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses = statuses
+}
+
+func (r *receiver) deliveries() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.received...)
+}
+
+func newTestOutbox(t *testing.T, store Store, opts ...Option) (*Outbox, *receiver, *clock.Fake) {
+	t.Helper()
+	fc := clock.NewFake(testEpoch)
+	rcv := &receiver{t: t, secret: []byte("s3cret"), now: fc.Now}
+	srv := httptest.NewServer(rcv)
+	t.Cleanup(srv.Close)
+
+	o := New(store, append([]Option{
+		WithClock(fc),
+		WithHTTPClient(srv.Client()),
+		WithPolicy(Policy{MaxAttempts: 3, BackoffBase: time.Minute, BackoffMax: 10 * time.Minute}),
+	}, opts...)...)
+	if err := o.SetEndpoint(Endpoint{Name: "crm", URL: srv.URL, Secret: rcv.secret}); err != nil {
+		t.Fatalf("SetEndpoint() error = %v", err)
+	}
+	return o, rcv, fc
+}
+
+func TestOutboxRetryDeadLetterReplay(t *testing.T) {
+	ctx := context.Background()
+	o, rcv, fc := newTestOutbox(t, nil)
+
+	if _, err := o.Enqueue(ctx, "missing", testEvent("evt-1")); !errors.Is(err, ErrUnknownEndpoint) {
+		t.Errorf("Enqueue() to unknown endpoint error = %v", err)
+	}
+	d, err := o.Enqueue(ctx, "crm", testEvent("evt-1"))
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if again, _ := o.Enqueue(ctx, "crm", testEvent("evt-1")); again.ID != d.ID {
+		t.Errorf("re-enqueue created delivery %s, want %s", again.ID, d.ID)
+	}
+	if all, _ := o.Deliveries(ctx, ""); len(all) != 1 {
+		t.Errorf("Deliveries() = %d, want 1 after duplicate enqueue", len(all))
+	}
+
+	rcv.script(http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusInternalServerError)
+	steps := []struct {
+		advance time.Duration
+		want    Stats
+	}{
+		{0, Stats{Attempted: 1, Retrying: 1}},
+		{30 * time.Second, Stats{}}, // backoff not elapsed
+		{30 * time.Second, Stats{Attempted: 1, Retrying: 1}},
+		{2 * time.Minute, Stats{Attempted: 1, Dead: 1}},
+	}
+	for i, step := range steps {
+		fc.Advance(step.advance)
+		stats, err := o.DeliverDue(ctx)
+		if err != nil {
+			t.Fatalf("step %d: DeliverDue() error = %v", i, err)
+		}
+		if stats != step.want {
+			t.Errorf("step %d: stats = %+v, want %+v", i, stats, step.want)
+		}
+	}
+
+	dead, _ := o.DeadLetters(ctx)
+	if len(dead) != 1 || dead[0].Failures != 3 || len(dead[0].Attempts) != 3 || dead[0].Attempts[2].StatusCode != 500 {
+		t.Fatalf("DeadLetters() = %+v", dead)
+	}
+	if _, err := o.Replay(ctx, "dlv-missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Replay() of missing delivery error = %v", err)
+	}
+
+	replayed, err := o.Replay(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if replayed.Status != StatusPending || replayed.Failures != 0 {
+		t.Errorf("replayed = %+v", replayed)
+	}
+	if _, err := o.Replay(ctx, d.ID); !errors.Is(err, ErrNotDead) {
+		t.Errorf("second Replay() error = %v, want ErrNotDead", err)
+	}
+	if stats, _ := o.DeliverDue(ctx); stats.Delivered != 1 {
+		t.Errorf("after replay stats = %+v, want 1 delivered", stats)
+	}
+	if got := rcv.deliveries(); len(got) != 1 || got[0] != d.ID+"/evt-1" {
+		t.Errorf("receiver got %v", got)
+	}
+
+	stored, _ := o.Deliveries(ctx, StatusDelivered)
+	if len(stored) != 1 || len(stored[0].Attempts) != 4 || stored[0].DeliveredAt.IsZero() {
+		t.Errorf("delivered = %+v", stored)
+	}
+
+	fc.Advance(8 * 24 * time.Hour)
+	if n, err := o.Prune(ctx); err != nil || n != 1 {
+		t.Errorf("Prune() = %d, %v; want 1", n, err)
+	}
+}
+
+func TestOutboxPermanentFailures(t *testing.T) {
+	ctx := context.Background()
+	o, rcv, fc := newTestOutbox(t, nil)
+
+	rcv.script(http.StatusGone, http.StatusTooManyRequests)
+	if _, err := o.Enqueue(ctx, "crm", testEvent("evt-gone")); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	fc.Advance(time.Second)
+	if _, err := o.Enqueue(ctx, "crm", testEvent("evt-throttled")); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	stats, err := o.DeliverDue(ctx)
+	if err != nil {
+		t.Fatalf("DeliverDue() error = %v", err)
+	}
+	if stats.Dead != 1 || stats.Retrying != 1 {
+		t.Errorf("stats = %+v, want 410 dead and 429 retrying", stats)
+	}
+
+	// Deliveries for a removed endpoint are dead-lettered, not lost
+	o.RemoveEndpoint("crm")
+	if err := o.Publish(ctx, testEvent("evt-orphan")); err != nil {
+		t.Errorf("Publish() with no endpoints error = %v", err)
+	}
+	dead, _ := o.DeadLetters(ctx)
+	if len(dead) != 1 {
+		t.Fatalf("DeadLetters() = %d entries", len(dead))
+	}
+	fc.Advance(time.Hour)
+	if stats, _ := o.DeliverDue(ctx); stats.Dead != 1 {
+		t.Errorf("stats after endpoint removal = %+v", stats)
+	}
+	if n, err := o.ReplayAll(ctx, "crm"); err != nil || n != 2 {
+		t.Errorf("ReplayAll() = %d, %v; want 2", n, err)
+	}
+}
+
+func TestOutboxPacesEndpoints(t *testing.T) {
+	ctx := context.Background()
+	o, rcv, fc := newTestOutbox(t, nil, WithPolicy(Policy{MaxAttempts: 3, RatePerMinute: 2}))
+
+	// a burst is queued in full and delivered at the endpoint's pace
+	for i := range 5 {
+		if _, err := o.Enqueue(ctx, "crm", testEvent(fmt.Sprintf("evt-%d", i))); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+		fc.Advance(time.Second)
+	}
+	steps := []struct {
+		advance time.Duration
+		want    Stats
+	}{
+		{0, Stats{Attempted: 2, Delivered: 2, Deferred: 3}},
+		{30 * time.Second, Stats{Deferred: 3}},
+		{30 * time.Second, Stats{Attempted: 2, Delivered: 2, Deferred: 1}},
+		{time.Minute, Stats{Attempted: 1, Delivered: 1}},
+	}
+	for i, step := range steps {
+		fc.Advance(step.advance)
+		stats, err := o.DeliverDue(ctx)
+		if err != nil {
+			t.Fatalf("step %d: DeliverDue() error = %v", i, err)
+		}
+		if stats != step.want {
+			t.Errorf("step %d: stats = %+v, want %+v", i, stats, step.want)
+		}
+	}
+	if got := rcv.deliveries(); len(got) != 5 {
+		t.Errorf("receiver got %d deliveries, want 5", len(got))
+	}
+}
+
+func TestFileStorePersistsOutbox(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "outbox.json")
+
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	o, rcv, fc := newTestOutbox(t, store)
+	if _, err := o.Enqueue(ctx, "crm", testEvent("evt-1")); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	fc.Advance(time.Second)
+	if _, err := o.Enqueue(ctx, "crm", testEvent("evt-2")); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	rcv.script(http.StatusOK, http.StatusServiceUnavailable)
+	if _, err := o.DeliverDue(ctx); err != nil {
+		t.Fatalf("DeliverDue() error = %v", err)
+	}
+
+	// A restarted process sees the same queue
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	pending, _ := reopened.List(ctx, StatusPending)
+	delivered, _ := reopened.List(ctx, StatusDelivered)
+	if len(pending) != 1 || pending[0].EventID != "evt-2" || pending[0].Failures != 1 {
+		t.Errorf("pending after reopen = %+v", pending)
+	}
+	if len(delivered) != 1 || delivered[0].EventID != "evt-1" {
+		t.Errorf("delivered after reopen = %+v", delivered)
+	}
+	if err := reopened.Delete(ctx, "dlv-missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete() of missing delivery error = %v", err)
+	}
+}
+
+func TestOutboxLoop(t *testing.T) {
+	o, rcv, fc := newTestOutbox(t, nil)
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer o.Stop()
+	if err := o.Start(context.Background()); !errors.Is(err, ErrRunning) {
+		t.Errorf("second Start() error = %v, want ErrRunning", err)
+	}
+
+	if err := o.Notifier("crm").Notify(context.Background(), testEvent("evt-loop")); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(rcv.deliveries()) == 0 && time.Now().Before(deadline) {
+		if fc.Waiters() > 0 {
+			fc.Advance(DefaultPolicy().Tick)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if len(rcv.deliveries()) != 1 {
+		t.Errorf("loop delivered %v", rcv.deliveries())
+	}
+	o.Stop()
+	if o.Running() {
+		t.Error("Running() after Stop()")
+	}
+}